@@ -103,12 +103,7 @@ func startServer() (port int, shutdown func()) {
 
 func connect(port int) *pgx.Conn {
 	connStr := fmt.Sprintf("host=127.0.0.1 port=%d user=admin password=test sslmode=disable", port)
-	cfg, err := pgx.ParseConfig(connStr)
-	if err != nil {
-		fatalf("parse config: %v", err)
-	}
-	cfg.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
-	conn, err := pgx.ConnectConfig(context.Background(), cfg)
+	conn, err := pgx.Connect(context.Background(), connStr)
 	if err != nil {
 		fatalf("connect: %v", err)
 	}
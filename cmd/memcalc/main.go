@@ -1,9 +1,11 @@
 // cmd/memcalc calculates the estimated memory consumption of a realistic
 // ~1 GB web shop database stored in mulldb's in-memory engine.
 //
-// It models the Go memory layout of both the old map[int64][]any storage
-// and the current dense [][]any array storage, showing the per-row savings
-// from eliminating map bucket overhead.
+// It models the Go memory layout of the old map[int64][]any storage, the
+// dense [][]any array that replaced it, and the current typed row store,
+// showing the per-row savings from eliminating map bucket overhead and
+// then the interface boxing of INTEGER values. A last model adds
+// dictionary encoding of the low-cardinality TEXT columns.
 //
 // Usage: go run cmd/memcalc/main.go
 package main
@@ -40,6 +42,10 @@ const (
 	// time.Time struct: wall(8) + ext(8) + loc-ptr(8) = 24 bytes.
 	timeTimeSize = 24
 
+	// Dictionary-encoded TEXT: a uint32 index per row into the column's
+	// dictionary, which holds each distinct string once.
+	dictIndexSize = 4
+
 	// B-tree entry (btreeEntry): key(16 any) + rowID(8) = 24 bytes.
 	btreeEntry = 24
 
@@ -63,6 +69,7 @@ const (
 	colInt colType = iota
 	colText
 	colTimestamp
+	colEnumText // TEXT with a handful of distinct values, e.g. a status
 )
 
 type column struct {
@@ -90,7 +97,7 @@ func rawRowSize(cols []column) int {
 		switch c.typ {
 		case colInt:
 			size += 8
-		case colText:
+		case colText, colEnumText:
 			size += c.avgSize
 		case colTimestamp:
 			size += 8 // raw: 8-byte Unix micros
@@ -108,7 +115,7 @@ func goRowOverheadMap(cols []column) int {
 		switch c.typ {
 		case colInt:
 			overhead += int64Overhead
-		case colText:
+		case colText, colEnumText:
 			overhead += stringHeader // + avgSize counted in raw
 		case colTimestamp:
 			overhead += timeTimeSize // full struct, not just 8 bytes
@@ -118,7 +125,8 @@ func goRowOverheadMap(cols []column) int {
 }
 
 // goRowOverheadDense returns the per-row Go memory overhead for the
-// dense array storage (slot pointer, slice header, interface boxes).
+// dense [][]any array storage (slot pointer, slice header, interface
+// boxes).
 func goRowOverheadDense(cols []column) int {
 	overhead := denseSlotOverhead + sliceHeader
 	for _, c := range cols {
@@ -126,7 +134,7 @@ func goRowOverheadDense(cols []column) int {
 		switch c.typ {
 		case colInt:
 			overhead += int64Overhead
-		case colText:
+		case colText, colEnumText:
 			overhead += stringHeader
 		case colTimestamp:
 			overhead += timeTimeSize
@@ -135,6 +143,63 @@ func goRowOverheadDense(cols []column) int {
 	return overhead
 }
 
+// goRowOverheadTyped returns the per-row Go memory overhead for the typed
+// row store. INTEGER values sit unboxed in a []int64 per column, whose 8
+// bytes are the raw size, plus a NULL bit each; the other columns are
+// boxed in a []any per row, which a table of integers does not have.
+func goRowOverheadTyped(cols []column) int {
+	overhead := 0
+	bits := 1 // live bitmap
+	boxed := false
+	for _, c := range cols {
+		switch c.typ {
+		case colInt:
+			bits++
+		case colText, colEnumText:
+			boxed = true
+			overhead += ifaceBox + stringHeader
+		case colTimestamp:
+			boxed = true
+			overhead += ifaceBox + timeTimeSize
+		}
+	}
+	if boxed {
+		overhead += sliceHeader
+	}
+	return overhead + (bits+7)/8
+}
+
+// goRowOverheadDict returns the per-row Go memory overhead for the typed
+// row store with the low-cardinality TEXT columns dictionary-encoded, as
+// STORAGE DICTIONARY or VACUUM does. Such a column keeps a uint32 index
+// per row instead of a boxed string; the string bytes, which the raw size
+// counts per row, are stored once in the dictionary, so they are taken off
+// again. The dictionaries themselves are a few values each and are left
+// out.
+func goRowOverheadDict(cols []column) int {
+	overhead := 0
+	bits := 1 // live bitmap
+	boxed := false
+	for _, c := range cols {
+		switch c.typ {
+		case colInt:
+			bits++
+		case colEnumText:
+			overhead += dictIndexSize - c.avgSize
+		case colText:
+			boxed = true
+			overhead += ifaceBox + stringHeader
+		case colTimestamp:
+			boxed = true
+			overhead += ifaceBox + timeTimeSize
+		}
+	}
+	if boxed {
+		overhead += sliceHeader
+	}
+	return overhead + (bits+7)/8
+}
+
 // indexEntrySize returns the per-entry memory cost of one index.
 func indexEntrySize(unique bool) int {
 	size := btreeEntry + btreeNodeOverhead
@@ -198,7 +263,7 @@ func webShopSchema() []table {
 			columns: []column{
 				{"id", colInt, 0},
 				{"user_id", colInt, 0},
-				{"status", colText, 10},
+				{"status", colEnumText, 10},
 				{"total_cents", colInt, 0},
 				{"created_at", colTimestamp, 0},
 			},
@@ -344,23 +409,36 @@ func main() {
 	mapRaw, mapOverhead, mapIndex, mapTotal := printTable(
 		schema, "Map-Based Storage (old)", goRowOverheadMap)
 
-	// New dense array storage.
+	// Dense array storage.
 	denseRaw, denseOverhead, denseIndex, denseTotal := printTable(
-		schema, "Dense Array Storage (current)", goRowOverheadDense)
+		schema, "Dense Array Storage (previous)", goRowOverheadDense)
+
+	// Typed row store.
+	typedRaw, typedOverhead, typedIndex, typedTotal := printTable(
+		schema, "Typed Row Store (current)", goRowOverheadTyped)
+
+	// Typed row store with dictionary-encoded TEXT columns.
+	dictRaw, dictOverhead, dictIndex, dictTotal := printTable(
+		schema, "Typed Row Store with Dictionary Encoding", goRowOverheadDict)
 
 	// Comparison summary.
 	fmt.Println("Comparison")
 	fmt.Println("----------")
-	fmt.Printf("  %-28s %10s   %10s\n", "", "Map", "Dense Array")
-	fmt.Printf("  %-28s %10s   %10s\n", "Raw data:", fmtBytes(mapRaw), fmtBytes(denseRaw))
-	fmt.Printf("  %-28s %10s   %10s\n", "Go overhead:", fmtBytes(mapOverhead), fmtBytes(denseOverhead))
-	fmt.Printf("  %-28s %10s   %10s\n", "Index overhead:", fmtBytes(mapIndex), fmtBytes(denseIndex))
-	fmt.Printf("  %-28s %10s   %10s\n", "Total memory:", fmtBytes(mapTotal), fmtBytes(denseTotal))
-	fmt.Printf("  %-28s %9.2fx   %9.2fx\n", "Overhead ratio:",
+	fmt.Printf("  %-28s %10s   %10s   %10s   %10s\n", "", "Map", "Dense Array", "Typed", "Dictionary")
+	fmt.Printf("  %-28s %10s   %10s   %10s   %10s\n", "Raw data:", fmtBytes(mapRaw), fmtBytes(denseRaw), fmtBytes(typedRaw), fmtBytes(dictRaw))
+	fmt.Printf("  %-28s %10s   %10s   %10s   %10s\n", "Go overhead:", fmtBytes(mapOverhead), fmtBytes(denseOverhead), fmtBytes(typedOverhead), fmtBytes(dictOverhead))
+	fmt.Printf("  %-28s %10s   %10s   %10s   %10s\n", "Index overhead:", fmtBytes(mapIndex), fmtBytes(denseIndex), fmtBytes(typedIndex), fmtBytes(dictIndex))
+	fmt.Printf("  %-28s %10s   %10s   %10s   %10s\n", "Total memory:", fmtBytes(mapTotal), fmtBytes(denseTotal), fmtBytes(typedTotal), fmtBytes(dictTotal))
+	fmt.Printf("  %-28s %9.2fx   %9.2fx   %9.2fx   %9.2fx\n", "Overhead ratio:",
 		float64(mapTotal)/float64(mapRaw),
-		float64(denseTotal)/float64(denseRaw))
-	fmt.Printf("  %-28s %10s\n", "Savings:", fmtBytes(mapTotal-denseTotal))
-	fmt.Printf("  %-28s %d bytes/row\n", "Per-row savings:", mapEntryOverhead-denseSlotOverhead)
+		float64(denseTotal)/float64(denseRaw),
+		float64(typedTotal)/float64(typedRaw),
+		float64(dictTotal)/float64(dictRaw))
+	fmt.Printf("  %-28s %10s\n", "Savings (map → dense):", fmtBytes(mapTotal-denseTotal))
+	fmt.Printf("  %-28s %10s\n", "Savings (dense → typed):", fmtBytes(denseTotal-typedTotal))
+	fmt.Printf("  %-28s %10s\n", "Savings (typed → dict):", fmtBytes(typedTotal-dictTotal))
+	fmt.Printf("  %-28s %d bytes/row\n", "Per-row savings (map):", mapEntryOverhead-denseSlotOverhead)
+	fmt.Printf("  %-28s %d bytes/INTEGER value\n", "Per-value savings (typed):", ifaceBox+int64Overhead)
 
 	fmt.Println()
 	fmt.Println("Assumptions")
@@ -368,7 +446,9 @@ func main() {
 	fmt.Println("  - 64-bit platform, Go 1.22+ map implementation")
 	fmt.Println("  - Map entry overhead ~72 bytes (amortised bucket cost)")
 	fmt.Println("  - Dense array slot overhead ~8 bytes (pointer in outer slice)")
-	fmt.Println("  - All int64 values heap-allocated (conservative; small ints may be inlined)")
+	fmt.Println("  - Boxed int64 values heap-allocated (conservative; small ints may be inlined)")
+	fmt.Println("  - Typed store: INTEGER columns unboxed with NULL bitmaps, no slice for all-INTEGER rows")
+	fmt.Println("  - Dictionary: low-cardinality TEXT as a uint32 per row; dictionaries not counted")
 	fmt.Println("  - String backing arrays exactly avgSize bytes (no allocator rounding)")
 	fmt.Println("  - B-tree order=32 (63 max keys/node), ~10 bytes amortised node overhead")
 	fmt.Println("  - No GC metadata, goroutine stacks, or runtime overhead included")
@@ -29,6 +29,7 @@ import (
 	"hash/crc32"
 	"io"
 	"math"
+	"math/big"
 	"os"
 	"strconv"
 	"strings"
@@ -63,6 +64,9 @@ const (
 	tagBoolean   byte = 3
 	tagTimestamp byte = 4
 	tagFloat     byte = 5
+	tagBytea     byte = 6
+	tagNumeric   byte = 7
+	tagTime      byte = 8
 )
 
 // Data types
@@ -72,6 +76,9 @@ const (
 	typeBoolean   byte = 2
 	typeTimestamp byte = 3
 	typeFloat     byte = 4
+	typeBytea     byte = 5
+	typeNumeric   byte = 6
+	typeTime      byte = 7
 )
 
 // Entry represents a single WAL entry
@@ -381,9 +388,16 @@ func decodeCreateTable(data []byte) string {
 		pkFlag := r[1] != 0
 		notNullFlag := r[2] != 0
 		ordinal := binary.BigEndian.Uint16(r[3:5])
-		rest = r[5:]
+		def, r, err := decodeString(r[5:])
+		if err != nil {
+			return fmt.Sprintf("[error reading col %d default: %v]", i, err)
+		}
+		if len(r) < 4 {
+			return fmt.Sprintf("[truncated column %d precision/scale]", i)
+		}
+		typeStr := typeWithModifier(dataType, r[:4])
+		rest = r[4:]
 
-		typeStr := dataTypeName(dataType)
 		var attrs []string
 		if pkFlag {
 			attrs = append(attrs, "PK")
@@ -394,6 +408,9 @@ func decodeCreateTable(data []byte) string {
 		if ordinal != uint16(i) {
 			attrs = append(attrs, fmt.Sprintf("ord=%d", ordinal))
 		}
+		if def != "" {
+			attrs = append(attrs, "DEFAULT "+def)
+		}
 
 		col := fmt.Sprintf("%s %s", colName, typeStr)
 		if len(attrs) > 0 {
@@ -525,8 +542,14 @@ func decodeAddColumn(data []byte) string {
 	pkFlag := r[1] != 0
 	notNullFlag := r[2] != 0
 	ordinal := binary.BigEndian.Uint16(r[3:5])
-
-	typeStr := dataTypeName(dataType)
+	def, r, err := decodeString(r[5:])
+	if err != nil {
+		return fmt.Sprintf("[error reading default: %v]", err)
+	}
+	if len(r) < 4 {
+		return "[truncated precision/scale]"
+	}
+	typeStr := typeWithModifier(dataType, r[:4])
 	var attrs []string
 	if pkFlag {
 		attrs = append(attrs, "PK")
@@ -534,6 +557,9 @@ func decodeAddColumn(data []byte) string {
 	if notNullFlag {
 		attrs = append(attrs, "NOT NULL")
 	}
+	if def != "" {
+		attrs = append(attrs, "DEFAULT "+def)
+	}
 
 	details := fmt.Sprintf("%s %s ord=%d", colName, typeStr, ordinal)
 	if len(attrs) > 0 {
@@ -655,6 +681,16 @@ func decodeValue(data []byte) (any, []byte, error) {
 			return nil, nil, fmt.Errorf("truncated boolean")
 		}
 		return data[0] != 0, data[1:], nil
+	case tagBytea:
+		if len(data) < 4 {
+			return nil, nil, fmt.Errorf("truncated bytea length")
+		}
+		n := binary.BigEndian.Uint32(data[:4])
+		data = data[4:]
+		if uint32(len(data)) < n {
+			return nil, nil, fmt.Errorf("truncated bytea")
+		}
+		return data[:n], data[n:], nil
 	case tagFloat:
 		if len(data) < 8 {
 			return nil, nil, fmt.Errorf("truncated float")
@@ -667,11 +703,58 @@ func decodeValue(data []byte) (any, []byte, error) {
 		}
 		usec := int64(binary.BigEndian.Uint64(data[:8]))
 		return time.UnixMicro(usec).UTC(), data[8:], nil
+	case tagNumeric:
+		if len(data) < 5 {
+			return nil, nil, fmt.Errorf("truncated numeric header")
+		}
+		scale := int(binary.BigEndian.Uint16(data[:2]))
+		neg := data[2] != 0
+		n := int(binary.BigEndian.Uint16(data[3:5]))
+		data = data[5:]
+		if len(data) < n {
+			return nil, nil, fmt.Errorf("truncated numeric")
+		}
+		return formatNumeric(new(big.Int).SetBytes(data[:n]), scale, neg), data[n:], nil
+	case tagTime:
+		if len(data) < 8 {
+			return nil, nil, fmt.Errorf("truncated time")
+		}
+		return timeValue(binary.BigEndian.Uint64(data[:8])), data[8:], nil
 	default:
 		return nil, nil, fmt.Errorf("unknown tag %d", tag)
 	}
 }
 
+// numericValue is a decoded NUMERIC, already in its display form.
+type numericValue string
+
+// formatNumeric renders coef × 10^-scale with scale fractional digits.
+func formatNumeric(coef *big.Int, scale int, neg bool) numericValue {
+	digits := coef.String()
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if neg {
+		digits = "-" + digits
+	}
+	return numericValue(digits)
+}
+
+// timeValue is a decoded TIME, in microseconds since midnight.
+type timeValue int64
+
+func (t timeValue) String() string {
+	sec := int64(t) / 1e6
+	s := fmt.Sprintf("%02d:%02d:%02d", sec/3600, sec/60%60, sec%60)
+	if frac := int64(t) % 1e6; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+	}
+	return s
+}
+
 func dataTypeName(t byte) string {
 	switch t {
 	case typeInteger:
@@ -684,11 +767,28 @@ func dataTypeName(t byte) string {
 		return "TIMESTAMP"
 	case typeFloat:
 		return "FLOAT"
+	case typeBytea:
+		return "BYTEA"
+	case typeNumeric:
+		return "NUMERIC"
+	case typeTime:
+		return "TIME"
 	default:
 		return fmt.Sprintf("UNKNOWN(%d)", t)
 	}
 }
 
+// typeWithModifier returns the type name with a NUMERIC column's
+// precision and scale, read from the [precision:u16][scale:u16] bytes.
+func typeWithModifier(t byte, mod []byte) string {
+	precision := binary.BigEndian.Uint16(mod[:2])
+	scale := binary.BigEndian.Uint16(mod[2:4])
+	if t != typeNumeric || precision == 0 {
+		return dataTypeName(t)
+	}
+	return fmt.Sprintf("NUMERIC(%d,%d)", precision, scale)
+}
+
 func formatValues(values []any) string {
 	if len(values) == 0 {
 		return "[]"
@@ -723,6 +823,16 @@ func formatValue(v any) string {
 		return "FALSE"
 	case time.Time:
 		return val.Format(time.RFC3339Nano)
+	case numericValue:
+		return string(val)
+	case timeValue:
+		return val.String()
+	case []byte:
+		// Truncate long values for display
+		if len(val) > 32 {
+			return fmt.Sprintf("\\x%x...(%d bytes)", val[:32], len(val))
+		}
+		return fmt.Sprintf("\\x%x", val)
 	default:
 		return fmt.Sprintf("%v", v)
 	}
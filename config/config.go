@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -13,7 +14,15 @@ type Config struct {
 	Password string
 	LogLevel int
 	Migrate  bool
+	Restore  string // backup file restored into DataDir before opening it
 	Fsync    bool
+	TLSCert  string // PEM certificate file; TLS is offered when set with TLSKey
+	TLSKey   string // PEM private key file
+
+	StatementTimeout time.Duration // default per-statement limit; 0 for none
+	ScanBatchSize    int           // rows a scan hands to the executor per call
+	SeqScanNotice    int64         // table rows from which unindexed filtered scans are reported; 0 for never
+	CopyProgress     int64         // rows between COPY FROM STDIN progress notices; 0 for none
 }
 
 func Parse() *Config {
@@ -24,7 +33,14 @@ func Parse() *Config {
 	flag.StringVar(&cfg.Password, "password", envStr("MULLDB_PASSWORD", ""), "auth password")
 	flag.IntVar(&cfg.LogLevel, "log-level", envInt("MULLDB_LOG_LEVEL", 0), "log verbosity (0=off, 1=SQL statements)")
 	flag.BoolVar(&cfg.Migrate, "migrate", false, "migrate WAL file format if needed")
+	flag.StringVar(&cfg.Restore, "restore", "", "restore this backup file into the empty data directory before starting")
 	flag.BoolVar(&cfg.Fsync, "fsync", envBool("MULLDB_FSYNC", true), "enable fsync on WAL writes (disable for speed at risk of data loss on crash)")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", envStr("MULLDB_TLS_CERT", ""), "TLS certificate file (PEM)")
+	flag.StringVar(&cfg.TLSKey, "tls-key", envStr("MULLDB_TLS_KEY", ""), "TLS private key file (PEM)")
+	flag.DurationVar(&cfg.StatementTimeout, "statement-timeout", envDuration("MULLDB_STATEMENT_TIMEOUT", 0), "cancel statements running longer than this (e.g. 30s; 0 disables)")
+	flag.IntVar(&cfg.ScanBatchSize, "scan-batch-size", envInt("MULLDB_SCAN_BATCH_SIZE", 256), "rows fetched per call in table scans")
+	flag.Int64Var(&cfg.SeqScanNotice, "seq-scan-notice", int64(envInt("MULLDB_SEQ_SCAN_NOTICE", 0)), "send a NOTICE and log when a query scans a table of at least this many rows filtering on an unindexed column (0 disables)")
+	flag.Int64Var(&cfg.CopyProgress, "copy-progress", int64(envInt("MULLDB_COPY_PROGRESS", 0)), "send a NOTICE every this many rows loaded by COPY FROM STDIN (0 disables)")
 	flag.Parse()
 	return cfg
 }
@@ -53,3 +69,12 @@ func envInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
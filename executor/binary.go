@@ -0,0 +1,153 @@
+package executor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"mulldb/storage"
+)
+
+// pgEpoch is the zero point of binary timestamps.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// formatValueBinary converts a storage value to the binary wire format of
+// a column of type oid, the counterpart of formatValue. nil means SQL NULL,
+// as does a value the type cannot hold.
+func formatValueBinary(v any, oid int32) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch oid {
+	case OIDInt8:
+		if n, ok := v.(int64); ok {
+			return binary.BigEndian.AppendUint64(nil, uint64(n)), nil
+		}
+	case OIDFloat8:
+		if f, ok := toFloat64(v); ok {
+			return binary.BigEndian.AppendUint64(nil, math.Float64bits(f)), nil
+		}
+	case OIDBool:
+		if b, ok := v.(bool); ok {
+			if b {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		}
+	case OIDTimestampTZ:
+		if t, ok := v.(time.Time); ok {
+			return binary.BigEndian.AppendUint64(nil, uint64(t.Sub(pgEpoch).Microseconds())), nil
+		}
+	case OIDTime:
+		if t, ok := v.(storage.TimeOfDay); ok {
+			return binary.BigEndian.AppendUint64(nil, uint64(t)), nil
+		}
+	case OIDBytea:
+		if b, ok := v.([]byte); ok {
+			return b, nil
+		}
+	case OIDNumeric:
+		switch n := v.(type) {
+		case storage.Numeric:
+			return binaryNumeric(n), nil
+		case int64:
+			return binaryNumeric(storage.NumericFromInt(n)), nil
+		}
+	default:
+		// Text and untyped columns are sent as their text.
+		return formatValue(v)
+	}
+	return nil, nil
+}
+
+// EncodeBinary converts a text-encoded result value of a column of type
+// oid to the binary wire format. Results are built in text format, so a
+// client asking for binary columns gets them converted from it.
+func EncodeBinary(text []byte, oid int32) ([]byte, error) {
+	if text == nil {
+		return nil, nil
+	}
+	v, err := parseTextValue(string(text), oid)
+	if err != nil {
+		return nil, &QueryError{Code: "22P03", Message: fmt.Sprintf("cannot send %q in binary format: %v", text, err)}
+	}
+	return formatValueBinary(v, oid)
+}
+
+// parseTextValue reverses formatValue for a column of type oid.
+func parseTextValue(s string, oid int32) (any, error) {
+	switch oid {
+	case OIDInt8:
+		return strconv.ParseInt(s, 10, 64)
+	case OIDFloat8:
+		return strconv.ParseFloat(s, 64)
+	case OIDBool:
+		switch s {
+		case "t":
+			return true, nil
+		case "f":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid boolean")
+	case OIDTimestampTZ:
+		return time.Parse(timestampLayout, s)
+	case OIDTime:
+		return storage.ParseTime(s)
+	case OIDBytea:
+		return storage.ParseBytea(s)
+	case OIDNumeric:
+		return storage.ParseNumeric(s)
+	}
+	return s, nil
+}
+
+// binaryNumeric encodes n as PostgreSQL's binary NUMERIC: a digit count,
+// the weight of the first digit, a sign word and the display scale,
+// followed by base-10000 digits.
+func binaryNumeric(n storage.Numeric) []byte {
+	s := strings.TrimPrefix(n.String(), "-")
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	intPart = strings.TrimLeft(intPart, "0")
+
+	// Pad both parts to whole groups of four digits around the point.
+	if r := len(intPart) % 4; r != 0 {
+		intPart = strings.Repeat("0", 4-r) + intPart
+	}
+	if r := len(fracPart) % 4; r != 0 {
+		fracPart += strings.Repeat("0", 4-r)
+	}
+	all := intPart + fracPart
+	weight := len(intPart)/4 - 1
+
+	var digits []uint16
+	for i := 0; i < len(all); i += 4 {
+		d, _ := strconv.Atoi(all[i : i+4])
+		digits = append(digits, uint16(d))
+	}
+	for len(digits) > 0 && digits[0] == 0 {
+		digits = digits[1:]
+		weight--
+	}
+	for len(digits) > 0 && digits[len(digits)-1] == 0 {
+		digits = digits[:len(digits)-1]
+	}
+	if len(digits) == 0 {
+		weight = 0
+	}
+
+	var sign uint16
+	if n.Sign() < 0 {
+		sign = 0x4000
+	}
+	buf := binary.BigEndian.AppendUint16(nil, uint16(len(digits)))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(int16(weight)))
+	buf = binary.BigEndian.AppendUint16(buf, sign)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(n.Scale()))
+	for _, d := range digits {
+		buf = binary.BigEndian.AppendUint16(buf, d)
+	}
+	return buf
+}
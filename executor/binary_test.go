@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"mulldb/storage"
+)
+
+func TestFormatValueBinary(t *testing.T) {
+	num := func(s string) storage.Numeric {
+		n, err := storage.ParseNumeric(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	tests := []struct {
+		v    any
+		oid  int32
+		want string // hex
+	}{
+		{int64(-2), OIDInt8, "fffffffffffffffe"},
+		{1.5, OIDFloat8, "3ff8000000000000"},
+		{int64(2), OIDFloat8, "4000000000000000"},
+		{true, OIDBool, "01"},
+		{time.Date(2000, 1, 1, 0, 0, 1, 0, time.UTC), OIDTimestampTZ, "00000000000f4240"},
+		{time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC), OIDTimestampTZ, "fffffffffff0bdc0"},
+		{storage.TimeOfDay(1_000_000), OIDTime, "00000000000f4240"},
+		{[]byte{0xde, 0xad}, OIDBytea, "dead"},
+		{"hi", OIDText, "6869"},
+		// ndigits, weight, sign, dscale, then base-10000 digits.
+		{num("0"), OIDNumeric, "0000000000000000"},
+		{num("0.00"), OIDNumeric, "0000000000000002"},
+		{num("1234.5"), OIDNumeric, "000200000000000104d21388"},
+		{num("-10000"), OIDNumeric, "0001000140000000" + "0001"},
+		{num("0.001"), OIDNumeric, "0001ffff00000003" + "000a"},
+		{int64(7), OIDNumeric, "0001000000000000" + "0007"},
+		{nil, OIDInt8, ""},
+		{"x", OIDInt8, ""}, // the type cannot hold it
+	}
+	for _, tt := range tests {
+		got, err := formatValueBinary(tt.v, tt.oid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(got) != tt.want || (tt.want == "") != (got == nil) {
+			t.Errorf("formatValueBinary(%v, %d) = %x, want %s", tt.v, tt.oid, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeBinary(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (i INTEGER, f FLOAT, b BOOLEAN, ts TIMESTAMP, n NUMERIC, raw BYTEA, s TEXT)")
+	exec(t, e, `INSERT INTO t VALUES (42, 0.25, FALSE, '2000-01-02 00:00:00', 1.5, '\x01ff', 'text')`)
+	r := exec(t, e, "SELECT * FROM t")
+
+	want := []string{
+		"000000000000002a",
+		"3fd0000000000000",
+		"00",
+		"000000141dd76000",
+		"00020000000000010001" + "1388",
+		"01ff",
+		"74657874",
+	}
+	for i, col := range r.Columns {
+		got, err := EncodeBinary(r.Rows[0][i], col.TypeOID)
+		if err != nil {
+			t.Errorf("column %s: %v", col.Name, err)
+			continue
+		}
+		if hex.EncodeToString(got) != want[i] {
+			t.Errorf("column %s = %x, want %s", col.Name, got, want[i])
+		}
+	}
+
+	if got, err := EncodeBinary(nil, OIDInt8); got != nil || err != nil {
+		t.Errorf("EncodeBinary(NULL) = %x, %v; want NULL", got, err)
+	}
+	_, err := EncodeBinary([]byte("2.5"), OIDInt8)
+	assertSQLSTATE(t, err, "22P03")
+}
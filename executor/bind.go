@@ -0,0 +1,166 @@
+package executor
+
+import (
+	"strings"
+
+	"mulldb/parser"
+)
+
+// callBinder returns the expression to substitute for the function call
+// fn, or nil to leave the call in place.
+type callBinder func(fn *parser.FunctionCallExpr) parser.Expr
+
+// exprBinder returns the expression to substitute for expr, or nil to keep
+// expr and go on to its operands. It sees every expression of a statement,
+// outermost first.
+type exprBinder func(expr parser.Expr) parser.Expr
+
+// bindStatement replaces the function calls in stmt that bind substitutes,
+// before the statement is planned. The executor uses it for calls whose
+// value depends on the statement or session rather than on their
+// arguments, such as NOW() and RANDOM().
+func bindStatement(stmt parser.Statement, bind callBinder) {
+	bindStatementExprs(stmt, func(expr parser.Expr) parser.Expr {
+		if fn, ok := expr.(*parser.FunctionCallExpr); ok {
+			return bind(fn)
+		}
+		return nil
+	})
+}
+
+// bindStatementExprs walks every expression of stmt, replacing those bind
+// substitutes.
+func bindStatementExprs(stmt parser.Statement, bind exprBinder) {
+	switch s := stmt.(type) {
+	case *parser.SelectStmt:
+		bindSelect(s, bind)
+	case *parser.SetOpStmt:
+		bindStatementExprs(s.Left, bind)
+		bindStatementExprs(s.Right, bind)
+		for i := range s.OrderBy {
+			s.OrderBy[i].Expr = bindExpr(s.OrderBy[i].Expr, bind)
+		}
+		s.LimitExpr = bindExpr(s.LimitExpr, bind)
+		s.OffsetExpr = bindExpr(s.OffsetExpr, bind)
+	case *parser.InsertStmt:
+		for _, row := range s.Values {
+			for i, v := range row {
+				row[i] = bindExpr(v, bind)
+			}
+		}
+		if s.OnConflict != nil {
+			bindSets(s.OnConflict.Sets, bind)
+		}
+		bindList(s.Returning, bind)
+	case *parser.ValuesStmt:
+		for _, row := range s.Rows {
+			for i, v := range row {
+				row[i] = bindExpr(v, bind)
+			}
+		}
+	case *parser.UpdateStmt:
+		bindSets(s.Sets, bind)
+		if s.From != nil {
+			for _, row := range s.From.Rows {
+				for i, v := range row {
+					row[i] = bindExpr(v, bind)
+				}
+			}
+		}
+		s.Where = bindExpr(s.Where, bind)
+		bindList(s.Returning, bind)
+	case *parser.DeleteStmt:
+		s.Where = bindExpr(s.Where, bind)
+		bindList(s.Returning, bind)
+	case *parser.ExplainStmt:
+		bindStatementExprs(s.Stmt, bind)
+	case *parser.CopyStmt:
+		bindStatementExprs(s.Query, bind)
+	}
+}
+
+// bindSelect binds the expressions in s.
+func bindSelect(s *parser.SelectStmt, bind exprBinder) {
+	bindList(s.Columns, bind)
+	for i := range s.Joins {
+		s.Joins[i].On = bindExpr(s.Joins[i].On, bind)
+	}
+	s.Where = bindExpr(s.Where, bind)
+	for i, g := range s.GroupBy {
+		s.GroupBy[i] = bindExpr(g, bind)
+	}
+	for i := range s.OrderBy {
+		s.OrderBy[i].Expr = bindExpr(s.OrderBy[i].Expr, bind)
+	}
+	s.LimitExpr = bindExpr(s.LimitExpr, bind)
+	s.OffsetExpr = bindExpr(s.OffsetExpr, bind)
+}
+
+// bindList binds a select list or RETURNING list. An item that is itself
+// a bound function call keeps the function's name as its column name.
+func bindList(cols []parser.Expr, bind exprBinder) {
+	for i, col := range cols {
+		bound := bindExpr(col, bind)
+		if fn, ok := col.(*parser.FunctionCallExpr); ok && bound != col {
+			bound = &parser.AliasExpr{Expr: bound, Alias: strings.ToLower(fn.Name)}
+		}
+		cols[i] = bound
+	}
+}
+
+func bindSets(sets []parser.SetClause, bind exprBinder) {
+	for i := range sets {
+		sets[i].Value = bindExpr(sets[i].Value, bind)
+	}
+}
+
+// bindExpr returns expr with its sub-expressions bound. nil is returned
+// unchanged.
+func bindExpr(expr parser.Expr, bind exprBinder) parser.Expr {
+	if expr == nil {
+		return nil
+	}
+	if bound := bind(expr); bound != nil {
+		return bound
+	}
+	switch e := expr.(type) {
+	case *parser.FunctionCallExpr:
+		for i, a := range e.Args {
+			e.Args[i] = bindExpr(a, bind)
+		}
+	case *parser.UnaryExpr:
+		e.Expr = bindExpr(e.Expr, bind)
+	case *parser.BinaryExpr:
+		e.Left = bindExpr(e.Left, bind)
+		e.Right = bindExpr(e.Right, bind)
+	case *parser.AliasExpr:
+		e.Expr = bindExpr(e.Expr, bind)
+	case *parser.IsNullExpr:
+		e.Expr = bindExpr(e.Expr, bind)
+	case *parser.NotExpr:
+		e.Expr = bindExpr(e.Expr, bind)
+	case *parser.LikeExpr:
+		e.Expr = bindExpr(e.Expr, bind)
+		e.Pattern = bindExpr(e.Pattern, bind)
+		e.Escape = bindExpr(e.Escape, bind)
+	case *parser.InExpr:
+		e.Expr = bindExpr(e.Expr, bind)
+		for i, v := range e.Values {
+			e.Values[i] = bindExpr(v, bind)
+		}
+		if e.Subquery != nil {
+			bindExpr(e.Subquery, bind) // binders keep a subquery in place
+		}
+	case *parser.BetweenExpr:
+		e.Expr = bindExpr(e.Expr, bind)
+		e.Low = bindExpr(e.Low, bind)
+		e.High = bindExpr(e.High, bind)
+	case *parser.CastExpr:
+		e.Expr = bindExpr(e.Expr, bind)
+	case *parser.NestExpr:
+		bindSelect(e.Query, bind)
+	case *parser.SubqueryExpr:
+		bindSelect(e.Query, bind)
+	}
+	return expr
+}
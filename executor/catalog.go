@@ -23,7 +23,10 @@ func init() {
 	registerPGDatabase()
 	registerPGNamespace()
 	registerPGClass()
+	registerPGAttribute()
+	registerPGIndexes()
 	registerInformationSchemaTables()
+	registerInformationSchemaViews()
 	registerInformationSchemaColumns()
 	registerInformationSchemaTableConstraints()
 	registerInformationSchemaKeyColumnUsage()
@@ -108,46 +111,111 @@ func registerPGClass() {
 		},
 		rows: func(eng storage.Engine) []storage.Row {
 			var rows []storage.Row
-			var id int64
-			oid := int64(16384) // PostgreSQL convention for user objects
-
-			// User tables from the storage engine.
-			if eng != nil {
-				defs := eng.ListTables()
-				sort.Slice(defs, func(i, j int) bool {
-					return defs[i].Name < defs[j].Name
+			for i, rel := range listRelations(eng) {
+				var count int64
+				if rel.kind == relKindTable {
+					count, _ = eng.RowCount(rel.name)
+				}
+				rows = append(rows, storage.Row{
+					ID:     int64(i + 1),
+					Values: []any{relationOID(i), rel.name, namespaceOID(rel.schema), rel.kind, count},
 				})
-				for _, def := range defs {
-					id++
-					count, _ := eng.RowCount(def.Name)
+			}
+			return rows
+		},
+	}
+}
+
+// registerPGAttribute adds the pg_attribute catalog table: one row per
+// column of each table and catalog table, whose attrelid is the
+// relation's oid in pg_class. User views are left out, as their columns
+// are only known once their query runs.
+func registerPGAttribute() {
+	catalogTables["pg_catalog.pg_attribute"] = &catalogTable{
+		def: &storage.TableDef{
+			Name:        "pg_attribute",
+			NextOrdinal: 7,
+			Columns: []storage.ColumnDef{
+				{Name: "attrelid", DataType: storage.TypeInteger, Ordinal: 0},
+				{Name: "attname", DataType: storage.TypeText, Ordinal: 1},
+				{Name: "atttypid", DataType: storage.TypeInteger, Ordinal: 2},
+				{Name: "attnum", DataType: storage.TypeInteger, Ordinal: 3},
+				{Name: "attnotnull", DataType: storage.TypeBoolean, Ordinal: 4},
+				{Name: "atttypmod", DataType: storage.TypeInteger, Ordinal: 5},
+				{Name: "attisdropped", DataType: storage.TypeBoolean, Ordinal: 6},
+			},
+		},
+		rows: func(eng storage.Engine) []storage.Row {
+			var rows []storage.Row
+			for i, rel := range listRelations(eng) {
+				var cols []storage.ColumnDef
+				switch {
+				case rel.schema != "public":
+					cols = catalogTables[rel.schema+"."+rel.name].def.Columns
+				case rel.kind == relKindTable:
+					def, ok := eng.GetTable(rel.name)
+					if !ok {
+						continue
+					}
+					cols = def.Columns
+				}
+				for j, col := range cols {
 					rows = append(rows, storage.Row{
-						ID: id,
-						Values: []any{oid, def.Name, int64(2200), "r", count},
+						ID: int64(len(rows) + 1),
+						Values: []any{
+							relationOID(i),
+							col.Name,
+							int64(typeOID(col.DataType)),
+							int64(j + 1),
+							col.NotNull || col.PrimaryKey,
+							typeMod(col),
+							false,
+						},
 					})
-					oid++
 				}
 			}
+			return rows
+		},
+	}
+}
 
-			// Catalog tables themselves.
-			var keys []string
-			for k := range catalogTables {
-				keys = append(keys, k)
+// registerPGIndexes adds the pg_indexes catalog view. The implicit primary
+// key index is listed first for each table, followed by secondary indexes.
+func registerPGIndexes() {
+	catalogTables["pg_catalog.pg_indexes"] = &catalogTable{
+		def: &storage.TableDef{
+			Name:        "pg_indexes",
+			NextOrdinal: 4,
+			Columns: []storage.ColumnDef{
+				{Name: "schemaname", DataType: storage.TypeText, Ordinal: 0},
+				{Name: "tablename", DataType: storage.TypeText, Ordinal: 1},
+				{Name: "indexname", DataType: storage.TypeText, Ordinal: 2},
+				{Name: "indexdef", DataType: storage.TypeText, Ordinal: 3},
+			},
+		},
+		rows: func(eng storage.Engine) []storage.Row {
+			var rows []storage.Row
+			var id int64
+			if eng == nil {
+				return rows
 			}
-			sort.Strings(keys)
-			for _, key := range keys {
-				id++
-				parts := strings.SplitN(key, ".", 2)
-				nsOID := int64(11) // pg_catalog
-				if parts[0] == "information_schema" {
-					nsOID = 13183
+			defs := eng.ListTables()
+			sort.Slice(defs, func(i, j int) bool {
+				return defs[i].Name < defs[j].Name
+			})
+			for _, def := range defs {
+				idxs := def.Indexes
+				if pk, ok := primaryKeyIndex(def); ok {
+					idxs = append([]storage.IndexDef{pk}, idxs...)
+				}
+				for _, idx := range idxs {
+					id++
+					rows = append(rows, storage.Row{
+						ID:     id,
+						Values: []any{"public", def.Name, idx.Name, indexDefinition(def, idx)},
+					})
 				}
-				rows = append(rows, storage.Row{
-					ID:     id,
-					Values: []any{oid, parts[1], nsOID, "v", int64(0)},
-				})
-				oid++
 			}
-
 			return rows
 		},
 	}
@@ -167,49 +235,134 @@ func registerInformationSchemaTables() {
 		},
 		rows: func(eng storage.Engine) []storage.Row {
 			var rows []storage.Row
-			var id int64
-
-			// User tables from the storage engine.
-			if eng != nil {
-				defs := eng.ListTables()
-				sort.Slice(defs, func(i, j int) bool {
-					return defs[i].Name < defs[j].Name
+			for i, rel := range listRelations(eng) {
+				rows = append(rows, storage.Row{
+					ID:     int64(i + 1),
+					Values: []any{rel.schema, rel.name, tableType(rel.kind)},
 				})
-				for _, def := range defs {
-					id++
-					rows = append(rows, storage.Row{
-						ID:     id,
-						Values: []any{"public", def.Name, "BASE TABLE"},
-					})
-				}
 			}
+			return rows
+		},
+	}
+}
 
-			// Catalog tables themselves.
-			var keys []string
-			for k := range catalogTables {
-				keys = append(keys, k)
+// registerInformationSchemaViews adds the information_schema.views catalog
+// table, which lists the user views with the SELECT that defines them.
+func registerInformationSchemaViews() {
+	catalogTables["information_schema.views"] = &catalogTable{
+		def: &storage.TableDef{
+			Name:        "views",
+			NextOrdinal: 4,
+			Columns: []storage.ColumnDef{
+				{Name: "table_schema", DataType: storage.TypeText, Ordinal: 0},
+				{Name: "table_name", DataType: storage.TypeText, Ordinal: 1},
+				{Name: "view_definition", DataType: storage.TypeText, Ordinal: 2},
+				{Name: "is_updatable", DataType: storage.TypeText, Ordinal: 3},
+			},
+		},
+		rows: func(eng storage.Engine) []storage.Row {
+			var rows []storage.Row
+			if eng == nil {
+				return rows
 			}
-			sort.Strings(keys)
-			for _, key := range keys {
-				id++
-				parts := strings.SplitN(key, ".", 2)
+			defs := eng.ListViews()
+			sort.Slice(defs, func(i, j int) bool {
+				return defs[i].Name < defs[j].Name
+			})
+			for i, def := range defs {
 				rows = append(rows, storage.Row{
-					ID:     id,
-					Values: []any{parts[0], parts[1], "SYSTEM VIEW"},
+					ID:     int64(i + 1),
+					Values: []any{"public", def.Name, def.Query, "NO"},
 				})
 			}
-
 			return rows
 		},
 	}
 }
 
+// Relation kinds, as in pg_class.relkind.
+const (
+	relKindTable = "r"
+	relKindView  = "v"
+)
+
+// relation is a table-like object listed in pg_class and
+// information_schema.tables.
+type relation struct {
+	schema string
+	name   string
+	kind   string // relKindTable or relKindView
+}
+
+// listRelations returns the user tables and views sorted by name,
+// followed by the catalog tables sorted by qualified name. Catalog tables
+// are computed on every read, so they are listed as views.
+func listRelations(eng storage.Engine) []relation {
+	var rels []relation
+	if eng != nil {
+		for _, def := range eng.ListTables() {
+			rels = append(rels, relation{schema: "public", name: def.Name, kind: relKindTable})
+		}
+		for _, def := range eng.ListViews() {
+			rels = append(rels, relation{schema: "public", name: def.Name, kind: relKindView})
+		}
+		sort.Slice(rels, func(i, j int) bool {
+			return rels[i].name < rels[j].name
+		})
+	}
+
+	var keys []string
+	for k := range catalogTables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		schema, name, _ := strings.Cut(key, ".")
+		rels = append(rels, relation{schema: schema, name: name, kind: relKindView})
+	}
+	return rels
+}
+
+// relationOID is the pg_class.oid of the i-th relation of listRelations,
+// counting up from 16384, the first oid PostgreSQL gives user objects.
+func relationOID(i int) int64 {
+	return 16384 + int64(i)
+}
+
+// typeMod is the pg_attribute.atttypmod of col: for NUMERIC(p, s) the
+// precision and scale packed as PostgreSQL does, and -1 for other types.
+func typeMod(col storage.ColumnDef) int64 {
+	if col.DataType == storage.TypeNumeric && col.Precision > 0 {
+		return int64(col.Precision)<<16 | int64(col.Scale) + 4
+	}
+	return -1
+}
+
+// tableType is the information_schema.tables.table_type of a relation kind.
+func tableType(kind string) string {
+	if kind == relKindView {
+		return "VIEW"
+	}
+	return "BASE TABLE"
+}
+
+// namespaceOID is the pg_namespace.oid of schema.
+func namespaceOID(schema string) int64 {
+	switch schema {
+	case "pg_catalog":
+		return 11
+	case "information_schema":
+		return 13183
+	}
+	return 2200 // public
+}
+
 // registerInformationSchemaColumns adds the information_schema.columns catalog table.
 func registerInformationSchemaColumns() {
 	catalogTables["information_schema.columns"] = &catalogTable{
 		def: &storage.TableDef{
 			Name:        "columns",
-			NextOrdinal: 6,
+			NextOrdinal: 9,
 			Columns: []storage.ColumnDef{
 				{Name: "table_schema", DataType: storage.TypeText, Ordinal: 0},
 				{Name: "table_name", DataType: storage.TypeText, Ordinal: 1},
@@ -217,6 +370,9 @@ func registerInformationSchemaColumns() {
 				{Name: "ordinal_position", DataType: storage.TypeInteger, Ordinal: 3},
 				{Name: "data_type", DataType: storage.TypeText, Ordinal: 4},
 				{Name: "is_nullable", DataType: storage.TypeText, Ordinal: 5},
+				{Name: "column_default", DataType: storage.TypeText, Ordinal: 6},
+				{Name: "is_identity", DataType: storage.TypeText, Ordinal: 7},
+				{Name: "identity_generation", DataType: storage.TypeText, Ordinal: 8},
 			},
 		},
 		rows: func(eng storage.Engine) []storage.Row {
@@ -230,6 +386,16 @@ func registerInformationSchemaColumns() {
 					if col.NotNull {
 						nullable = "NO"
 					}
+					var colDefault any
+					if col.Default != "" {
+						colDefault = col.Default
+					}
+					isIdentity := "NO"
+					var generation any
+					if col.Identity != storage.IdentityNone {
+						isIdentity = "YES"
+						generation = col.Identity.String()
+					}
 					rows = append(rows, storage.Row{
 						ID: id,
 						Values: []any{
@@ -239,6 +405,9 @@ func registerInformationSchemaColumns() {
 							int64(i + 1),
 							strings.ToLower(col.DataType.String()),
 							nullable,
+							colDefault,
+							isIdentity,
+							generation,
 						},
 					})
 				}
@@ -342,6 +511,26 @@ func registerInformationSchemaTableConstraints() {
 						})
 					}
 				}
+				// FOREIGN KEY constraints.
+				for _, col := range def.Columns {
+					if col.References != nil {
+						id++
+						rows = append(rows, storage.Row{
+							ID: id,
+							Values: []any{
+								"mulldb",
+								"public",
+								fkConstraintName(def.Name, col.Name),
+								"mulldb",
+								"public",
+								def.Name,
+								"FOREIGN KEY",
+								"NO",
+								"NO",
+							},
+						})
+					}
+				}
 			}
 			return rows
 		},
@@ -399,7 +588,10 @@ func registerInformationSchemaKeyColumnUsage() {
 				}
 				// UNIQUE index columns.
 				for _, idx := range def.Indexes {
-					if idx.Unique {
+					if !idx.Unique {
+						continue
+					}
+					for i, col := range idx.Columns {
 						id++
 						rows = append(rows, storage.Row{
 							ID: id,
@@ -410,7 +602,26 @@ func registerInformationSchemaKeyColumnUsage() {
 								"mulldb",
 								"public",
 								def.Name,
-								idx.Column,
+								col,
+								int64(i + 1),
+							},
+						})
+					}
+				}
+				// FOREIGN KEY columns.
+				for _, col := range def.Columns {
+					if col.References != nil {
+						id++
+						rows = append(rows, storage.Row{
+							ID: id,
+							Values: []any{
+								"mulldb",
+								"public",
+								fkConstraintName(def.Name, col.Name),
+								"mulldb",
+								"public",
+								def.Name,
+								col.Name,
 								int64(1),
 							},
 						})
@@ -484,6 +695,13 @@ func (it *catalogIterator) Next() (storage.Row, bool) {
 	return r, true
 }
 
+func (it *catalogIterator) NextBatch(n int) []storage.Row {
+	end := min(it.pos+n, len(it.rows))
+	rows := it.rows[it.pos:end]
+	it.pos = end
+	return rows
+}
+
 func (it *catalogIterator) Close() error {
 	return nil
 }
@@ -2,7 +2,11 @@ package executor
 
 import (
 	"errors"
+	"fmt"
+	"slices"
 	"testing"
+
+	"mulldb/parser"
 )
 
 func TestCatalog_SelectStar(t *testing.T) {
@@ -217,6 +221,36 @@ func TestCatalog_InformationSchemaTablesWherePublic(t *testing.T) {
 	}
 }
 
+func TestCatalog_InformationSchemaTablesTableType(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE items (id INTEGER)")
+
+	r := exec(t, e, "SELECT table_schema, table_type FROM information_schema.tables WHERE table_name = 'items'")
+	if len(r.Rows) != 1 {
+		t.Fatalf("rows = %d, want 1", len(r.Rows))
+	}
+	if string(r.Rows[0][0]) != "public" || string(r.Rows[0][1]) != "BASE TABLE" {
+		t.Errorf("items = [%s, %s], want [public, BASE TABLE]", r.Rows[0][0], r.Rows[0][1])
+	}
+
+	// Catalog tables keep their own schema and are listed as views, like
+	// their relkind in pg_class.
+	r = exec(t, e, "SELECT table_schema, table_name, table_type FROM information_schema.tables WHERE table_name = 'pg_type' OR table_name = 'columns'")
+	want := [][3]string{
+		{"information_schema", "columns", "VIEW"},
+		{"pg_catalog", "pg_type", "VIEW"},
+	}
+	if len(r.Rows) != len(want) {
+		t.Fatalf("rows = %d, want %d", len(r.Rows), len(want))
+	}
+	for i, w := range want {
+		got := [3]string{string(r.Rows[i][0]), string(r.Rows[i][1]), string(r.Rows[i][2])}
+		if got != w {
+			t.Errorf("row %d = %v, want %v", i, got, w)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // information_schema.columns
 // ---------------------------------------------------------------------------
@@ -333,6 +367,23 @@ func TestCatalog_TableConstraintsUnique(t *testing.T) {
 	}
 }
 
+func TestCatalog_ForeignKeyConstraints(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE users (id INTEGER PRIMARY KEY)")
+	exec(t, e, "CREATE TABLE posts (id INTEGER PRIMARY KEY, author INTEGER REFERENCES users ON DELETE CASCADE)")
+
+	r := exec(t, e, `SELECT tc.constraint_name, tc.constraint_type, kc.column_name
+		FROM information_schema.table_constraints tc JOIN information_schema.key_column_usage kc
+		ON tc.constraint_name = kc.constraint_name
+		WHERE tc.table_name = 'posts' ORDER BY tc.constraint_name`)
+	if len(r.Rows) != 2 {
+		t.Fatalf("rows = %d, want 2", len(r.Rows))
+	}
+	if string(r.Rows[0][0]) != "posts_author_fkey" || string(r.Rows[0][1]) != "FOREIGN KEY" || string(r.Rows[0][2]) != "author" {
+		t.Errorf("row 0 = %s, want [posts_author_fkey FOREIGN KEY author]", r.Rows[0])
+	}
+}
+
 func TestCatalog_TableConstraintsNoPK(t *testing.T) {
 	e := setup(t)
 	exec(t, e, "CREATE TABLE logs (msg TEXT)")
@@ -497,6 +548,58 @@ func TestCatalog_PGClassCatalogTables(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// pg_attribute
+// ---------------------------------------------------------------------------
+
+func TestCatalog_PGAttributeJoinClass(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE other (x TEXT)")
+	exec(t, e, "CREATE TABLE prices (id INTEGER PRIMARY KEY, label TEXT NOT NULL, amount NUMERIC(10,2), ratio FLOAT)")
+	exec(t, e, "ALTER TABLE prices DROP COLUMN ratio")
+	exec(t, e, "ALTER TABLE prices ADD COLUMN seen TIMESTAMP")
+
+	// The query drivers use to list a table's columns.
+	r := exec(t, e, `SELECT a.attname, a.attnum, a.atttypid, a.attnotnull, a.atttypmod
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid
+		WHERE c.relname = 'prices' AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`)
+	want := []string{
+		"id 1 20 t -1",
+		"label 2 25 t -1",
+		"amount 3 1700 f 655366",
+		"seen 4 1184 f -1",
+	}
+	var got []string
+	for _, row := range r.Rows {
+		got = append(got, fmt.Sprintf("%s %s %s %s %s", row[0], row[1], row[2], row[3], row[4]))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("columns = %q, want %q", got, want)
+	}
+
+	// atttypid is the OID a query reports for the column.
+	sel := exec(t, e, "SELECT * FROM prices")
+	for i, col := range sel.Columns {
+		if want := fmt.Sprint(col.TypeOID); string(r.Rows[i][2]) != want {
+			t.Errorf("%s: atttypid = %s, want %s", col.Name, r.Rows[i][2], want)
+		}
+	}
+
+	// Catalog tables have their columns too; views do not.
+	exec(t, e, "CREATE VIEW v AS SELECT id FROM prices")
+	r = exec(t, e, `SELECT c.relname, a.attname FROM pg_class c JOIN pg_attribute a ON a.attrelid = c.oid
+		WHERE c.relname = 'pg_type' OR c.relname = 'v' ORDER BY a.attnum`)
+	got = nil
+	for _, row := range r.Rows {
+		got = append(got, string(row[0])+"."+string(row[1]))
+	}
+	if want := []string{"pg_type.oid", "pg_type.typname"}; !slices.Equal(got, want) {
+		t.Errorf("columns = %q, want %q", got, want)
+	}
+}
+
 func TestCatalog_InformationSchemaInsertReadOnly(t *testing.T) {
 	e := setup(t)
 	_, err := e.Execute("INSERT INTO information_schema.tables (table_schema, table_name, table_type) VALUES ('public', 'fake', 'BASE TABLE')")
@@ -511,3 +614,83 @@ func TestCatalog_InformationSchemaInsertReadOnly(t *testing.T) {
 		t.Errorf("SQLSTATE = %q, want 42809", qe.Code)
 	}
 }
+
+func TestCatalog_PGIndexes(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, age INTEGER)")
+	exec(t, e, "CREATE UNIQUE INDEX idx_email ON users(email)")
+	exec(t, e, "CREATE INDEX ON users(age)")
+
+	r := exec(t, e, "SELECT tablename, indexname, indexdef FROM pg_indexes WHERE tablename = 'users'")
+	want := [][3]string{
+		{"users", "users_pkey", "CREATE UNIQUE INDEX users_pkey ON users (id)"},
+		{"users", "idx_email", "CREATE UNIQUE INDEX idx_email ON users (email)"},
+		{"users", "idx_age", "CREATE INDEX idx_age ON users (age)"},
+	}
+	if len(r.Rows) != len(want) {
+		t.Fatalf("rows = %d, want %d", len(r.Rows), len(want))
+	}
+	for i, w := range want {
+		for j := range w {
+			if string(r.Rows[i][j]) != w[j] {
+				t.Errorf("row %d col %d = %q, want %q", i, j, r.Rows[i][j], w[j])
+			}
+		}
+	}
+}
+
+func TestCatalog_PGIndexesComposite(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, status TEXT)")
+	exec(t, e, "CREATE UNIQUE INDEX idx_user_status ON orders (user_id, status)")
+
+	r := exec(t, e, "SELECT indexdef FROM pg_indexes WHERE indexname = 'idx_user_status'")
+	if len(r.Rows) != 1 {
+		t.Fatalf("rows = %d, want 1", len(r.Rows))
+	}
+	if want := "CREATE UNIQUE INDEX idx_user_status ON orders (user_id, status)"; string(r.Rows[0][0]) != want {
+		t.Errorf("indexdef = %q, want %q", r.Rows[0][0], want)
+	}
+
+	r = exec(t, e, "SELECT column_name, ordinal_position FROM information_schema.key_column_usage WHERE constraint_name = 'idx_user_status'")
+	if len(r.Rows) != 2 {
+		t.Fatalf("key_column_usage rows = %d, want 2", len(r.Rows))
+	}
+	for i, want := range []string{"user_id", "status"} {
+		if string(r.Rows[i][0]) != want || string(r.Rows[i][1]) != itoa(i+1) {
+			t.Errorf("row %d = %s, %s; want %s, %d", i, r.Rows[i][0], r.Rows[i][1], want, i+1)
+		}
+	}
+}
+
+func TestCatalog_IndexDefRoundTrip(t *testing.T) {
+	e := setup(t)
+	exec(t, e, `CREATE TABLE "Order" (id INTEGER, "select" TEXT)`)
+	exec(t, e, `CREATE UNIQUE INDEX "Idx Select" ON "Order"("select")`)
+
+	r := exec(t, e, `SELECT indexdef FROM pg_indexes WHERE indexname = 'Idx Select'`)
+	if len(r.Rows) != 1 {
+		t.Fatalf("rows = %d, want 1", len(r.Rows))
+	}
+	indexdef := string(r.Rows[0][0])
+	if want := `CREATE UNIQUE INDEX "Idx Select" ON "Order" ("select")`; indexdef != want {
+		t.Errorf("indexdef = %q, want %q", indexdef, want)
+	}
+
+	stmt, err := parser.Parse(indexdef)
+	if err != nil {
+		t.Fatalf("indexdef does not parse: %v", err)
+	}
+	ci, ok := stmt.(*parser.CreateIndexStmt)
+	if !ok {
+		t.Fatalf("parsed %T, want *parser.CreateIndexStmt", stmt)
+	}
+	if ci.Name != "Idx Select" || ci.Table.Name != "Order" || len(ci.Columns) != 1 || ci.Columns[0] != "select" || !ci.Unique {
+		t.Errorf("round trip = %+v", ci)
+	}
+
+	// Re-creating the index from its definition on a fresh database works.
+	e2 := setup(t)
+	exec(t, e2, `CREATE TABLE "Order" (id INTEGER, "select" TEXT)`)
+	exec(t, e2, indexdef)
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	"mulldb/parser"
 	"mulldb/storage"
@@ -39,6 +40,8 @@ func coerceLiteral(val any, target storage.DataType) (any, error) {
 			return v, nil
 		case int64:
 			return float64(v), nil
+		case storage.Numeric:
+			return v.Float64(), nil
 		case string:
 			f, err := strconv.ParseFloat(v, 64)
 			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
@@ -92,6 +95,48 @@ func coerceLiteral(val any, target storage.DataType) (any, error) {
 		default:
 			return nil, &QueryError{Code: "22P02", Message: fmt.Sprintf("invalid input syntax for type timestamp: %q", fmt.Sprint(val))}
 		}
+
+	case storage.TypeTime:
+		switch v := val.(type) {
+		case storage.TimeOfDay:
+			return v, nil
+		case string:
+			t, err := storage.ParseTime(v)
+			if err != nil {
+				return nil, &QueryError{Code: "22P02", Message: fmt.Sprintf("invalid input syntax for type time: %q", v)}
+			}
+			return t, nil
+		default:
+			return nil, &QueryError{Code: "22P02", Message: fmt.Sprintf("invalid input syntax for type time: %q", fmt.Sprint(val))}
+		}
+
+	case storage.TypeBytea:
+		switch v := val.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			b, err := storage.ParseBytea(v)
+			if err != nil {
+				return nil, &QueryError{Code: "22P02", Message: fmt.Sprintf("invalid input syntax for type bytea: %q", v)}
+			}
+			return b, nil
+		default:
+			return nil, &QueryError{Code: "22P02", Message: fmt.Sprintf("invalid input syntax for type bytea: %q", fmt.Sprint(val))}
+		}
+
+	case storage.TypeNumeric:
+		switch val.(type) {
+		case storage.Numeric, int64, float64, string:
+			// The column's precision and scale are applied by the storage
+			// engine; here the value only has to be a number.
+			n, err := storage.CoerceNumeric(val, 0, 0)
+			if err != nil {
+				return nil, &QueryError{Code: "22P02", Message: fmt.Sprintf("invalid input syntax for type numeric: %q", fmt.Sprint(val))}
+			}
+			return n, nil
+		default:
+			return nil, &QueryError{Code: "22P02", Message: fmt.Sprintf("invalid input syntax for type numeric: %q", fmt.Sprint(val))}
+		}
 	}
 
 	return nil, &QueryError{Code: "22P02", Message: fmt.Sprintf("cannot cast %T to %s", val, target)}
@@ -138,6 +183,8 @@ func literalValue(expr parser.Expr) (any, bool) {
 		return e.Value, true
 	case *parser.BoolLit:
 		return e.Value, true
+	case *parser.TimestampLit:
+		return e.Value, true
 	default:
 		return nil, false
 	}
@@ -160,8 +207,19 @@ func goTypeMatchesDataType(val any, dt storage.DataType) bool {
 		_, ok := val.(bool)
 		return ok
 	case storage.TypeTimestamp:
-		// Literals are never time.Time from the parser, so always needs coercion.
-		return false
+		// Only bound NOW()/CURRENT_TIMESTAMP values arrive as time.Time;
+		// parser literals are strings and need coercion.
+		_, ok := val.(time.Time)
+		return ok
+	case storage.TypeBytea:
+		_, ok := val.([]byte)
+		return ok
+	case storage.TypeNumeric:
+		_, ok := val.(storage.Numeric)
+		return ok
+	case storage.TypeTime:
+		_, ok := val.(storage.TimeOfDay)
+		return ok
 	default:
 		return false
 	}
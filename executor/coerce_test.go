@@ -285,3 +285,44 @@ func TestCoercion_DeleteWhere(t *testing.T) {
 		t.Fatalf("expected 1 row after delete, got %d", len(r.Rows))
 	}
 }
+
+func TestCoercion_InsertValues(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, n INTEGER, s TEXT, b BOOLEAN)")
+
+	// Values convert to the column's type as PostgreSQL's assignment
+	// casts do, including explicit casts.
+	exec(t, e, "INSERT INTO t VALUES ('1', ' 42 ', 12, 'yes')")
+	exec(t, e, "INSERT INTO t VALUES (2, CAST('7' AS INTEGER), 1.5, 'off')")
+	exec(t, e, "INSERT INTO t VALUES (3, '8'::integer, true, NULL)")
+	exec(t, e, "INSERT INTO t (id, n) VALUES (4, 2.5)")
+	r := exec(t, e, "SELECT id, n, s, b FROM t ORDER BY id")
+	want := [][]string{
+		{"1", "42", "12", "t"},
+		{"2", "7", "1.5", "f"},
+		{"3", "8", "true", ""},
+		{"4", "3", "", ""},
+	}
+	for i, row := range r.Rows {
+		for j, v := range row {
+			if string(v) != want[i][j] {
+				t.Errorf("row %d column %d = %q, want %q", i, j, v, want[i][j])
+			}
+		}
+	}
+
+	_, err := e.Execute("INSERT INTO t (id, n) VALUES (5, 'abc')")
+	assertSQLSTATE(t, err, "22P02")
+	_, err = e.Execute("INSERT INTO t (id, b) VALUES (5, 'maybe')")
+	assertSQLSTATE(t, err, "22P02")
+	_, err = e.Execute("INSERT INTO t (id, n) VALUES (5, true)")
+	assertSQLSTATE(t, err, "42804")
+	_, err = e.Execute("INSERT INTO t (id, b) VALUES (5, 1)")
+	assertSQLSTATE(t, err, "42804")
+	_, err = e.Execute("UPDATE t SET n = 'zz' WHERE id = 1")
+	assertSQLSTATE(t, err, "22P02")
+	r = exec(t, e, "SELECT COUNT(*) FROM t")
+	if string(r.Rows[0][0]) != "4" {
+		t.Errorf("COUNT(*) = %s, want 4", r.Rows[0][0])
+	}
+}
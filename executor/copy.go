@@ -0,0 +1,424 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// copyBatchRows is how many rows of a COPY ... FROM STDIN go to the engine
+// in one insert.
+const copyBatchRows = 1000
+
+// execCopy runs COPY ... TO STDOUT, and checks a COPY ... FROM STDIN
+// before the server collects its data. A table is copied as SELECT of its
+// columns; a query runs as it would on its own, so its ORDER BY, LIMIT
+// and OFFSET decide which rows are copied and in what order. Each row
+// then becomes a line of the COPY format, after a line of the column
+// names with HEADER.
+func (e *Executor) execCopy(s *parser.CopyStmt, tr *Trace) (*Result, error) {
+	null, err := copyNull(s)
+	if err != nil {
+		return nil, err
+	}
+	if s.From {
+		_, cols, err := e.copyTarget(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{CopyIn: &CopyIn{Format: s.Format, Columns: len(cols)}}, nil
+	}
+
+	query := s.Query
+	if query == nil {
+		q := &parser.SelectStmt{From: s.Table}
+		for _, c := range s.Columns {
+			q.Columns = append(q.Columns, &parser.ColumnRef{Name: c})
+		}
+		if q.Columns == nil {
+			q.Columns = []parser.Expr{&parser.StarExpr{}}
+		}
+		query = q
+	}
+	res, err := e.dispatch(query, tr)
+	if err != nil {
+		return nil, err
+	}
+	line := copyTextLine
+	if s.Format == "csv" {
+		line = copyCSVLine
+	}
+	out := &CopyOut{Format: s.Format, Columns: len(res.Columns), Lines: make([][]byte, 0, len(res.Rows)+1)}
+	if s.Header {
+		names := make([][]byte, len(res.Columns))
+		for i, c := range res.Columns {
+			names[i] = []byte(c.Name)
+		}
+		out.Lines = append(out.Lines, line(names, null))
+	}
+	for _, row := range res.Rows {
+		out.Lines = append(out.Lines, line(row, null))
+	}
+	return &Result{CopyOut: out, Tag: fmt.Sprintf("COPY %d", len(res.Rows))}, nil
+}
+
+// CopyFrom loads the data the client sent for the COPY ... FROM STDIN
+// statement sql into its table. The rows are inserted as by INSERT, in
+// batches, and all or none of them are stored. With SetCopyProgress on,
+// progress, if not nil, is called with the number of rows loaded so far
+// each time another interval's worth is in, while the load goes on. The
+// statement timeout covers the whole load.
+func (e *Executor) CopyFrom(sql string, data []byte, progress func(rows int64)) (*Result, error) {
+	stmt, err := parser.Parse(sql)
+	if err != nil {
+		return nil, &QueryError{Code: "42601", Message: err.Error()}
+	}
+	s, ok := stmt.(*parser.CopyStmt)
+	if !ok || !s.From {
+		return nil, &QueryError{Code: "42601", Message: "not a COPY ... FROM STDIN statement"}
+	}
+	ctx, cancel := e.statementContext(e.settings.statementTimeout)
+	defer cancel()
+	if ctx != nil {
+		e = e.withInterrupt(ctx)
+		defer func() { e.intr.done = true }()
+	}
+	null, err := copyNull(s)
+	if err != nil {
+		return nil, err
+	}
+	_, cols, err := e.copyTarget(s)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := copyRows(s.Format, s.Header, null, data, cols)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := e.loadRows(s.Table, cols, rows, progress)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Tag: fmt.Sprintf("COPY %d", n)}, nil
+}
+
+// loadRows inserts rows of field text, nil for NULL, into the columns cols
+// of table as INSERT would, in batches of copyBatchRows, and all or none
+// of them. progress is as for CopyFrom.
+func (e *Executor) loadRows(table parser.TableRef, cols []string, rows [][]*string, progress func(rows int64)) (int64, error) {
+	every := int(e.settings.copyProgress)
+	if progress == nil {
+		every = 0
+	}
+	load := func(x *Executor) (*Result, error) {
+		for start := 0; start < len(rows); {
+			// A batch ends at the next progress report, if that comes first.
+			end := min(start+copyBatchRows, len(rows))
+			if every > 0 {
+				end = min(end, (start/every+1)*every)
+			}
+			batch := rows[start:end]
+			ins := &parser.InsertStmt{Table: table, Columns: cols, Values: make([][]parser.Expr, len(batch))}
+			for i, row := range batch {
+				exprs := make([]parser.Expr, len(row))
+				for j, v := range row {
+					if v == nil {
+						exprs[j] = &parser.NullLit{}
+					} else {
+						exprs[j] = &parser.StringLit{Value: *v}
+					}
+				}
+				ins.Values[i] = exprs
+			}
+			if _, err := x.execInsert(ins, nil); err != nil {
+				return nil, err
+			}
+			if x.intr.stoppedAfter(len(batch)) {
+				return nil, x.intr.err
+			}
+			start = end
+			if every > 0 && end%every == 0 {
+				progress(int64(end))
+			}
+		}
+		return nil, nil
+	}
+	var err error
+	if e.inTransaction() {
+		_, err = load(e)
+	} else {
+		_, err = e.atomically(load)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(rows)), nil
+}
+
+// copyTarget returns the table a COPY ... FROM STDIN loads and the
+// columns its lines hold: those listed, or else all of them in order.
+func (e *Executor) copyTarget(s *parser.CopyStmt) (*storage.TableDef, []string, error) {
+	if isCatalogTable(s.Table.Schema, s.Table.Name) {
+		return nil, nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot copy to catalog table %q", s.Table.String())}
+	}
+	if _, ok := e.engine.GetView(s.Table.Name); ok {
+		return nil, nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot copy to view %q", s.Table.String())}
+	}
+	def, ok := e.engine.GetTable(s.Table.Name)
+	if !ok {
+		return nil, nil, WrapError(&storage.TableNotFoundError{Name: s.Table.String()})
+	}
+	if s.Columns == nil {
+		cols := make([]string, len(def.Columns))
+		for i, c := range def.Columns {
+			cols[i] = c.Name
+		}
+		return def, cols, nil
+	}
+	for i, name := range s.Columns {
+		if columnIndex(def, name) < 0 {
+			return nil, nil, &QueryError{Code: "42703", Message: fmt.Sprintf("column %q of relation %q does not exist", name, def.Name)}
+		}
+		for _, prev := range s.Columns[:i] {
+			if strings.EqualFold(prev, name) {
+				return nil, nil, &QueryError{Code: "42701", Message: fmt.Sprintf("column %q specified more than once", name)}
+			}
+		}
+	}
+	return def, s.Columns, nil
+}
+
+// copyNull returns the NULL marker of a COPY: the one given, or \N in
+// text and "" in CSV. Like PostgreSQL, it rejects markers that would be
+// ambiguous with the line and field separators.
+func copyNull(s *parser.CopyStmt) (string, error) {
+	delim := "\t"
+	if s.Format == "csv" {
+		delim = ","
+	}
+	switch {
+	case s.Null == nil && s.Format == "csv":
+		return "", nil
+	case s.Null == nil:
+		return `\N`, nil
+	case strings.ContainsAny(*s.Null, "\r\n"):
+		return "", &QueryError{Code: "0A000", Message: "COPY null representation cannot use newline or carriage return"}
+	case strings.Contains(*s.Null, delim):
+		return "", &QueryError{Code: "0A000", Message: "COPY delimiter must not appear in the NULL specification"}
+	}
+	return *s.Null, nil
+}
+
+// copyRows splits the data of a COPY ... FROM STDIN into rows with a
+// value for each of cols, nil for NULL: a field that reads null, unquoted
+// in CSV. With header the first line names the columns and is skipped
+// unread. The data ends at its end or at a
+// line holding only \.; a line with too few or too many values is
+// SQLSTATE 22P04.
+func copyRows(format string, header bool, null string, data []byte, cols []string) ([][]*string, error) {
+	var rows [][]*string
+	add := func(line int, fields []*string) error {
+		switch {
+		case header:
+			header = false
+			return nil
+		case len(fields) < len(cols):
+			return &QueryError{Code: "22P04", Message: fmt.Sprintf("line %d: missing data for column %q", line, cols[len(fields)])}
+		case len(fields) > len(cols):
+			return &QueryError{Code: "22P04", Message: fmt.Sprintf("line %d: extra data after last expected column", line)}
+		}
+		rows = append(rows, fields)
+		return nil
+	}
+	if format == "csv" {
+		return rows, splitCSV(data, null, add)
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1] // after the final newline
+	}
+	for i, line := range lines {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if bytes.Equal(line, []byte(`\.`)) {
+			break
+		}
+		var fields []*string
+		for _, f := range bytes.Split(line, []byte("\t")) {
+			if string(f) == null {
+				fields = append(fields, nil)
+				continue
+			}
+			v := unescapeCopyText(f)
+			fields = append(fields, &v)
+		}
+		if err := add(i+1, fields); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// unescapeCopyText reverses the backslash escapes of COPY's text format:
+// \b, \f, \n, \r, \t and \v, up to three octal digits, and a backslash
+// before any other character, which stands for that character.
+func unescapeCopyText(f []byte) string {
+	if bytes.IndexByte(f, '\\') < 0 {
+		return string(f)
+	}
+	var b strings.Builder
+	for i := 0; i < len(f); i++ {
+		c := f[i]
+		if c != '\\' || i+1 == len(f) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch c = f[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			n := c - '0'
+			for k := 0; k < 2 && i+1 < len(f) && f[i+1] >= '0' && f[i+1] <= '7'; k++ {
+				i++
+				n = n<<3 | (f[i] - '0')
+			}
+			b.WriteByte(n)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// splitCSV splits CSV data into records and passes each, with the line it
+// starts on, to add. An unquoted field equal to null is NULL, while a
+// quoted one is always a string; quoted fields may hold commas, doubled
+// quotes and line breaks.
+func splitCSV(data []byte, null string, add func(line int, fields []*string) error) error {
+	var fields []*string
+	var field []byte
+	quoted, inQuotes, started := false, false, false
+	line, start := 1, 1
+	endField := func() {
+		if !quoted && string(field) == null {
+			fields = append(fields, nil)
+		} else {
+			v := string(field)
+			fields = append(fields, &v)
+		}
+		field, quoted = field[:0], false
+	}
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if !started {
+			rest := data[i:]
+			if bytes.HasPrefix(rest, []byte(`\.`)) && (len(rest) == 2 || rest[2] == '\n' || rest[2] == '\r') {
+				return nil
+			}
+			started, start = true, line
+		}
+		switch {
+		case inQuotes && c == '"' && i+1 < len(data) && data[i+1] == '"':
+			field = append(field, '"')
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case inQuotes:
+			field = append(field, c)
+			if c == '\n' {
+				line++
+			}
+		case c == ',':
+			endField()
+		case c == '\r' && i+1 < len(data) && data[i+1] == '\n':
+		case c == '\n':
+			endField()
+			if err := add(start, fields); err != nil {
+				return err
+			}
+			fields, started = nil, false
+			line++
+		default:
+			field = append(field, c)
+		}
+	}
+	if inQuotes {
+		return &QueryError{Code: "22P04", Message: fmt.Sprintf("line %d: unterminated CSV quoted field", start)}
+	}
+	if started {
+		endField()
+		return add(start, fields)
+	}
+	return nil
+}
+
+// copyTextLine formats row in COPY's text format: values separated by
+// tabs, NULL as null, and backslashes and line breaks escaped.
+func copyTextLine(row [][]byte, null string) []byte {
+	var b bytes.Buffer
+	for i, v := range row {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		if v == nil {
+			b.WriteString(null)
+			continue
+		}
+		for _, c := range v {
+			switch c {
+			case '\\':
+				b.WriteString(`\\`)
+			case '\t':
+				b.WriteString(`\t`)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			default:
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// copyCSVLine formats row as a CSV line. NULL is null unquoted, and a
+// value that reads null is quoted; so are values containing a comma,
+// quote or line break, with their quotes doubled.
+func copyCSVLine(row [][]byte, null string) []byte {
+	var b bytes.Buffer
+	for i, v := range row {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if v == nil {
+			b.WriteString(null)
+			continue
+		}
+		if string(v) != null && !bytes.ContainsAny(v, ",\"\r\n") && !bytes.Equal(v, []byte(`\.`)) {
+			b.Write(v)
+			continue
+		}
+		b.WriteByte('"')
+		b.Write(bytes.ReplaceAll(v, []byte(`"`), []byte(`""`)))
+		b.WriteByte('"')
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
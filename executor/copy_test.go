@@ -0,0 +1,421 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"mulldb/storage"
+)
+
+// copyLines returns the lines of a COPY result as strings.
+func copyLines(t *testing.T, r *Result) []string {
+	t.Helper()
+	if r.CopyOut == nil {
+		t.Fatalf("result has no COPY data: %+v", r)
+	}
+	var out []string
+	for _, l := range r.CopyOut.Lines {
+		out = append(out, string(l))
+	}
+	return out
+}
+
+func TestCopy_QueryToStdout(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE scores (id INTEGER, name TEXT, score INTEGER)")
+	exec(t, e, `INSERT INTO scores VALUES (1, 'ann', 70), (2, 'bob', 95), (3, 'cy', 80),
+		(4, 'dee', 95), (5, NULL, 60)`)
+
+	// The top three by score, ties by id, in the order the query gives.
+	r := exec(t, e, "COPY (SELECT id, name, score FROM scores ORDER BY score DESC, id LIMIT 3) TO STDOUT WITH (FORMAT csv)")
+	if got, want := copyLines(t, r), []string{"2,bob,95\n", "4,dee,95\n", "3,cy,80\n"}; !slices.Equal(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	if r.Tag != "COPY 3" || r.CopyOut.Columns != 3 || r.Columns != nil {
+		t.Errorf("tag %q, %d columns, result columns %v, want COPY 3 with 3 columns", r.Tag, r.CopyOut.Columns, r.Columns)
+	}
+	// OFFSET too, and NULL in text format.
+	r = exec(t, e, "COPY (SELECT id, name FROM scores ORDER BY score LIMIT 2 OFFSET 0) TO STDOUT")
+	if got, want := copyLines(t, r), []string{"5\t\\N\n", "1\tann\n"}; !slices.Equal(got, want) {
+		t.Errorf("text lines = %q, want %q", got, want)
+	}
+	r = exec(t, e, "COPY (SELECT id FROM scores WHERE id > 10) TO STDOUT")
+	if r.Tag != "COPY 0" || len(r.CopyOut.Lines) != 0 {
+		t.Errorf("empty copy: tag %q, lines %q", r.Tag, r.CopyOut.Lines)
+	}
+
+	_, err := e.Execute("COPY (SELECT id FROM missing) TO STDOUT")
+	assertSQLSTATE(t, err, "42P01")
+	_, err = e.Execute("COPY (SELECT id FROM scores LIMIT -1) TO STDOUT")
+	assertSQLSTATE(t, err, "2201W")
+}
+
+// copyFrom runs a COPY ... FROM STDIN with data, as the server does.
+func copyFrom(t *testing.T, e *Executor, sql, data string) (*Result, error) {
+	t.Helper()
+	r, err := e.Execute(sql)
+	if err != nil {
+		return nil, err
+	}
+	if r.CopyIn == nil {
+		t.Fatalf("%s: result has no CopyIn: %+v", sql, r)
+	}
+	return e.CopyFrom(sql, []byte(data), nil)
+}
+
+func TestCopy_FromStdin(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE orders (id SERIAL PRIMARY KEY, customer TEXT NOT NULL, total NUMERIC(10,2), paid BOOLEAN DEFAULT false, placed TIMESTAMP)")
+
+	r, err := e.Execute("COPY orders FROM STDIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.CopyIn.Columns != 5 || r.CopyIn.Format != "text" {
+		t.Errorf("CopyIn = %+v, want 5 text columns", r.CopyIn)
+	}
+
+	// Text format, all columns, with NULL, escapes and the end marker.
+	r, err = copyFrom(t, e, "COPY orders FROM STDIN",
+		"1\tann\\tx\\\\y\t12.5\tt\t2024-01-02 03:04:05+00\n"+
+			"2\tbob\t\\N\tf\t\\N\n"+
+			"\\.\n"+
+			"ignored\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Tag != "COPY 2" {
+		t.Errorf("tag = %q, want COPY 2", r.Tag)
+	}
+	// CSV with a column list: the others take their defaults. An empty
+	// quoted field is an empty string, an unquoted one NULL.
+	r, err = copyFrom(t, e, "COPY orders (customer, total) FROM STDIN WITH (FORMAT csv)",
+		"\"cy, \"\"the\"\" third\",7\r\n"+
+			"dee,\n"+
+			"\"\",\n"+
+			"\"multi\nline\",1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Tag != "COPY 4" {
+		t.Errorf("tag = %q, want COPY 4", r.Tag)
+	}
+
+	r = exec(t, e, "SELECT id, customer, total, paid, placed FROM orders ORDER BY id")
+	var got []string
+	for _, row := range r.Rows {
+		got = append(got, string(copyCSVLine(row, "")))
+	}
+	want := []string{
+		"1,ann\tx\\y,12.50,t,2024-01-02 03:04:05+00\n",
+		"2,bob,,f,\n",
+		"3,\"cy, \"\"the\"\" third\",7.00,f,\n",
+		"4,dee,,f,\n",
+		"5,\"\",,f,\n",
+		"6,\"multi\nline\",1.00,f,\n",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("rows =\n%q\nwant\n%q", got, want)
+	}
+
+	// The table copies back out, all columns or those listed.
+	r = exec(t, e, "COPY orders (customer, id) TO STDOUT WITH CSV")
+	if got := copyLines(t, r); len(got) != 6 || got[0] != "ann\tx\\y,1\n" || got[4] != "\"\",5\n" {
+		t.Errorf("lines = %q", got)
+	}
+	r = exec(t, e, "COPY orders TO STDOUT")
+	if got := copyLines(t, r); len(got) != 6 || got[0] != "1\tann\\tx\\\\y\t12.50\tt\t2024-01-02 03:04:05+00\n" {
+		t.Errorf("lines = %q", got)
+	}
+}
+
+func TestCopy_FromStdinErrors(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "CREATE VIEW v AS SELECT id FROM t")
+
+	for sql, code := range map[string]string{
+		"COPY missing FROM STDIN":            "42P01",
+		"COPY t (id, nope) FROM STDIN":       "42703",
+		"COPY t (id, ID) FROM STDIN":         "42701",
+		"COPY v FROM STDIN":                  "42809",
+		"COPY pg_catalog.pg_type FROM STDIN": "42809",
+	} {
+		_, err := e.Execute(sql)
+		assertSQLSTATE(t, err, code)
+	}
+
+	for data, code := range map[string]string{
+		"1\ta\n2\n":          "22P04", // missing column
+		"1\ta\textra\n":      "22P04",
+		"x\ta\n":             "22P02",
+		"1\ta\n2\tb\n1\tc\n": "23505",
+	} {
+		_, err := copyFrom(t, e, "COPY t FROM STDIN", data)
+		assertSQLSTATE(t, err, code)
+	}
+	_, err := copyFrom(t, e, "COPY t FROM STDIN (FORMAT csv)", "1,\"open\n")
+	assertSQLSTATE(t, err, "22P04")
+	// An empty quoted field is an empty string, not NULL.
+	_, err = copyFrom(t, e, "COPY t FROM STDIN (FORMAT csv)", "\"\",a\n")
+	assertSQLSTATE(t, err, "22P02")
+
+	// A failed COPY stores nothing, not even the batches before the one
+	// that failed.
+	var data strings.Builder
+	for i := range copyBatchRows + 10 {
+		fmt.Fprintf(&data, "%d\tn\n", i%(copyBatchRows+5))
+	}
+	_, err = copyFrom(t, e, "COPY t FROM STDIN", data.String())
+	assertSQLSTATE(t, err, "23505")
+	if r := exec(t, e, "SELECT COUNT(*) FROM t"); string(r.Rows[0][0]) != "0" {
+		t.Errorf("rows after failed COPY = %s, want 0", r.Rows[0][0])
+	}
+}
+
+func TestCopy_Escaping(t *testing.T) {
+	row := [][]byte{[]byte("a\tb\\c\nd"), nil, []byte(""), []byte(`say "hi", bye`), []byte(`\.`)}
+	if got, want := string(copyTextLine(row, `\N`)), "a\\tb\\\\c\\nd\t\\N\t\tsay \"hi\", bye\t\\\\.\n"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+	if got, want := string(copyCSVLine(row, "")), "\"a\tb\\c\nd\",,\"\",\"say \"\"hi\"\", bye\",\"\\.\"\n"; got != want {
+		t.Errorf("csv = %q, want %q", got, want)
+	}
+}
+
+func TestCopy_UnescapeText(t *testing.T) {
+	for in, want := range map[string]string{
+		`plain`:      "plain",
+		`a\tb\nc\\d`: "a\tb\nc\\d",
+		`\101\102\x`: "ABx",
+		`trailing\`:  `trailing\`,
+		`\b\f\r\v\.`: "\b\f\r\v.",
+	} {
+		if got := unescapeCopyText([]byte(in)); got != want {
+			t.Errorf("unescape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCopy_FromStdinProgress(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE items (id INTEGER PRIMARY KEY)")
+	lines := func(from, to int) []byte {
+		var b strings.Builder
+		for i := from; i <= to; i++ {
+			fmt.Fprintf(&b, "%d\n", i)
+		}
+		return []byte(b.String())
+	}
+
+	// Reports come at every multiple of the interval, whether it is
+	// smaller or larger than an insert batch.
+	for _, tt := range []struct {
+		every int64
+		rows  int
+		want  []int64
+	}{
+		{300, 1000, []int64{300, 600, 900}},
+		{2500, 5000, []int64{2500, 5000}},
+		{0, 1000, nil},
+	} {
+		exec(t, e, "TRUNCATE items")
+		e.SetCopyProgress(tt.every)
+		var got []int64
+		r, err := e.CopyFrom("COPY items FROM STDIN", lines(1, tt.rows), func(n int64) { got = append(got, n) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Tag != fmt.Sprintf("COPY %d", tt.rows) || !slices.Equal(got, tt.want) {
+			t.Errorf("every %d: tag %q, reports %v, want %v", tt.every, r.Tag, got, tt.want)
+		}
+	}
+
+	// Without a progress function nothing is reported.
+	exec(t, e, "TRUNCATE items")
+	e.SetCopyProgress(10)
+	if _, err := e.CopyFrom("COPY items FROM STDIN", lines(1, 100), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopy_FromStdinWALReplay(t *testing.T) {
+	dir := tempDir(t)
+
+	eng, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(eng)
+	exec(t, e, "CREATE TABLE t (id SERIAL PRIMARY KEY, name TEXT)")
+	// Several batches, committed together as one WAL group.
+	var data strings.Builder
+	for i := range 2*copyBatchRows + 5 {
+		fmt.Fprintf(&data, "n%d\n", i)
+	}
+	if _, err := copyFrom(t, e, "COPY t (name) FROM STDIN", data.String()); err != nil {
+		t.Fatal(err)
+	}
+	eng.Close()
+
+	eng, err = storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+	e = New(eng)
+	r := exec(t, e, "SELECT COUNT(*), MAX(id) FROM t")
+	if want := fmt.Sprint(2*copyBatchRows + 5); string(r.Rows[0][0]) != want || string(r.Rows[0][1]) != want {
+		t.Errorf("after restart: count, max(id) = %q, want %s", r.Rows[0], want)
+	}
+}
+
+func TestCopy_Header(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER, \"full name\" TEXT, note TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (1, 'ann', NULL), (2, 'bob, jr', 'x')")
+
+	// The header names the copied columns, quoted as CSV values are; it
+	// is not counted in the tag.
+	r := exec(t, e, "COPY t (\"full name\", id) TO STDOUT WITH CSV HEADER")
+	want := []string{"full name,id\n", "ann,1\n", "\"bob, jr\",2\n"}
+	if got := copyLines(t, r); !slices.Equal(got, want) {
+		t.Errorf("CSV lines = %q, want %q", got, want)
+	}
+	if r.Tag != "COPY 2" {
+		t.Errorf("tag = %q, want COPY 2", r.Tag)
+	}
+	r = exec(t, e, "COPY (SELECT id AS n, note FROM t ORDER BY id DESC) TO STDOUT (HEADER)")
+	want = []string{"n\tnote\n", "2\tx\n", "1\t\\N\n"}
+	if got := copyLines(t, r); !slices.Equal(got, want) {
+		t.Errorf("text lines = %q, want %q", got, want)
+	}
+
+	// On the way in the header line is skipped, whatever it says.
+	r, err := copyFrom(t, e, "COPY t (id, note) FROM STDIN (FORMAT csv, HEADER)", "anything,at all,here\n3,y\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Tag != "COPY 1" {
+		t.Errorf("tag = %q, want COPY 1", r.Tag)
+	}
+	if _, err := copyFrom(t, e, "COPY t (id) FROM STDIN (HEADER)", "id\n4\n5\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(exec(t, e, "SELECT id FROM t ORDER BY id")); !slices.Equal(got, []string{"1", "2", "3", "4", "5"}) {
+		t.Errorf("ids = %v, want 1..5", got)
+	}
+}
+
+func TestCopy_Null(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER, note TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (1, NULL), (2, 'NULL'), (3, '')")
+
+	// NULL is written as the marker; in CSV a value that reads like it is
+	// quoted, so it comes back as a string.
+	for _, tt := range []struct {
+		sql  string
+		want []string
+	}{
+		{"COPY t TO STDOUT", []string{"1\t\\N\n", "2\tNULL\n", "3\t\n"}},
+		{"COPY t TO STDOUT (NULL '-')", []string{"1\t-\n", "2\tNULL\n", "3\t\n"}},
+		{"COPY t TO STDOUT CSV", []string{"1,\n", "2,NULL\n", "3,\"\"\n"}},
+		{"COPY t TO STDOUT (FORMAT csv, NULL 'NULL')", []string{"1,NULL\n", "2,\"NULL\"\n", "3,\n"}},
+	} {
+		if got := copyLines(t, exec(t, e, tt.sql)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: lines = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+
+	// Loading what was exported with the same marker gives the same rows.
+	const check = "SELECT id, note IS NULL, note FROM %s ORDER BY id"
+	want := groupRows(exec(t, e, fmt.Sprintf(check, "t")))
+	for i, opts := range []string{"", "(NULL '-')", "CSV", "(FORMAT csv, NULL 'NULL')", "WITH NULL AS '' CSV"} {
+		name := fmt.Sprintf("t%d", i)
+		exec(t, e, "CREATE TABLE "+name+" (id INTEGER, note TEXT)")
+		out := strings.Join(copyLines(t, exec(t, e, "COPY t TO STDOUT "+opts)), "")
+		if _, err := copyFrom(t, e, "COPY "+name+" FROM STDIN "+opts, out); err != nil {
+			t.Fatalf("%s: %v", opts, err)
+		}
+		if got := groupRows(exec(t, e, fmt.Sprintf(check, name))); !slices.Equal(got, want) {
+			t.Errorf("%s: rows = %v, want %v", opts, got, want)
+		}
+	}
+
+	// The marker cannot contain the delimiter or a line break.
+	for _, sql := range []string{
+		"COPY t TO STDOUT (FORMAT csv, NULL 'a,b')",
+		"COPY t TO STDOUT (NULL '	')",
+		"COPY t FROM STDIN (NULL '\n')",
+	} {
+		_, err := e.Execute(sql)
+		assertSQLSTATE(t, err, "0A000")
+	}
+}
+
+func TestExecutor_ExportImportCSV(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER, note TEXT, score FLOAT, ok BOOLEAN)")
+	exec(t, e, `INSERT INTO t VALUES (1, NULL, 1.5, true), (2, '', NULL, false), (3, 'a,"b"', 2, NULL), (4, 'two
+lines', -0.25, true)`)
+
+	var buf strings.Builder
+	n, err := e.ExportCSV("t", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "id,note,score,ok\n" +
+		"1,,1.5,t\n" +
+		"2,\"\",,f\n" +
+		"3,\"a,\"\"b\"\"\",2,\n" +
+		"4,\"two\nlines\",-0.25,t\n"
+	if n != 4 || buf.String() != want {
+		t.Fatalf("ExportCSV = %d, %q; want 4, %q", n, buf.String(), want)
+	}
+
+	// Importing the export gives the same rows, NULL and '' kept apart.
+	exec(t, e, "CREATE TABLE u (id INTEGER, note TEXT, score FLOAT, ok BOOLEAN)")
+	if n, err := e.ImportCSV("u", strings.NewReader(buf.String())); err != nil || n != 4 {
+		t.Fatalf("ImportCSV = %d, %v; want 4", n, err)
+	}
+	const check = "SELECT id, note IS NULL, note, score, ok FROM %s ORDER BY id"
+	if got, want := groupRows(exec(t, e, fmt.Sprintf(check, "u"))), groupRows(exec(t, e, fmt.Sprintf(check, "t"))); !slices.Equal(got, want) {
+		t.Errorf("imported rows = %v, want %v", got, want)
+	}
+
+	// The header maps fields to columns; the others get their defaults.
+	exec(t, e, "CREATE TABLE v (id INTEGER, note TEXT DEFAULT 'none', score FLOAT)")
+	if _, err := e.ImportCSV("v", strings.NewReader("score,id\n2.5,7\n,8\n")); err != nil {
+		t.Fatal(err)
+	}
+	got := groupRows(exec(t, e, "SELECT id, note, score FROM v ORDER BY id"))
+	if want := []string{"7|none|2.5", "8|none|-"}; !slices.Equal(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+
+	// An error loads nothing.
+	for _, tt := range []struct {
+		table, data, code string
+	}{
+		{"v", "id,nope\n1,2\n", "42703"},
+		{"v", "id,id\n1,2\n", "42701"},
+		{"v", "id,score\n9,1\n10\n", "22P04"},
+		{"v", "id,score\n9,1\nx,2\n", "22P02"},
+		{"missing", "id\n1\n", "42P01"},
+	} {
+		_, err := e.ImportCSV(tt.table, strings.NewReader(tt.data))
+		assertSQLSTATE(t, err, tt.code)
+	}
+	if got := groupRows(exec(t, e, "SELECT count(*) FROM v")); !slices.Equal(got, []string{"2"}) {
+		t.Errorf("count after failed imports = %v, want [2]", got)
+	}
+
+	exec(t, e, "CREATE VIEW w AS SELECT id FROM t")
+	_, err = e.ExportCSV("w", &buf)
+	assertSQLSTATE(t, err, "42809")
+	_, err = e.ExportCSV("missing", &buf)
+	assertSQLSTATE(t, err, "42P01")
+}
@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// ExportCSV writes the rows of table to w as CSV: a header line of column
+// names, then one line per row with the values as SELECT returns them.
+// Fields are quoted as RFC 4180 describes, and NULL is an empty unquoted
+// field, so an empty string reads "". The rows are streamed from a scan of
+// the table rather than collected first. ExportCSV returns the number of
+// rows written.
+func (e *Executor) ExportCSV(table string, w io.Writer) (int64, error) {
+	if _, ok := e.engine.GetView(table); ok {
+		return 0, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot export view %q", table)}
+	}
+	def, ok := e.engine.GetTable(table)
+	if !ok {
+		return 0, WrapError(&storage.TableNotFoundError{Name: table})
+	}
+	it, err := e.engine.Scan(table)
+	if err != nil {
+		return 0, WrapError(err)
+	}
+	defer it.Close()
+
+	bw := bufio.NewWriter(w)
+	fields := make([][]byte, len(def.Columns))
+	for i, c := range def.Columns {
+		fields[i] = []byte(c.Name)
+	}
+	if _, err := bw.Write(copyCSVLine(fields, "")); err != nil {
+		return 0, err
+	}
+	var n int64
+	for {
+		row, ok := it.Next()
+		if !ok {
+			break
+		}
+		for i, c := range def.Columns {
+			if fields[i], err = formatValue(storage.RowValue(row.Values, c.Ordinal)); err != nil {
+				return n, err
+			}
+		}
+		if _, err := bw.Write(copyCSVLine(fields, "")); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, bw.Flush()
+}
+
+// errCSVHeader stops splitCSV after the header line.
+var errCSVHeader = errors.New("header read")
+
+// ImportCSV loads CSV data as ExportCSV writes it into table. The header
+// line names the columns the fields go to, in any order; columns it leaves
+// out get their defaults. Empty unquoted fields are NULL, and every other
+// field is converted to its column's type as an INSERT of the text would.
+// The rows are inserted in batches, all or none of them, as by COPY FROM.
+// ImportCSV returns the number of rows loaded.
+func (e *Executor) ImportCSV(table string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := e.statementContext(e.settings.statementTimeout)
+	defer cancel()
+	if ctx != nil {
+		e = e.withInterrupt(ctx)
+		defer func() { e.intr.done = true }()
+	}
+
+	var header []string
+	err = splitCSV(data, "", func(line int, fields []*string) error {
+		header = make([]string, len(fields))
+		for i, f := range fields {
+			if f == nil {
+				return &QueryError{Code: "22P04", Message: fmt.Sprintf("line %d: empty column name in header", line)}
+			}
+			header[i] = *f
+		}
+		return errCSVHeader
+	})
+	if err != nil && err != errCSVHeader {
+		return 0, err
+	}
+	if header == nil {
+		return 0, nil
+	}
+	s := &parser.CopyStmt{Table: parser.TableRef{Name: table}, Columns: header}
+	_, cols, err := e.copyTarget(s)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := copyRows("csv", true, "", data, cols)
+	if err != nil {
+		return 0, err
+	}
+	return e.loadRows(s.Table, cols, rows, nil)
+}
@@ -0,0 +1,215 @@
+package executor
+
+import (
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// Cursor is an open SELECT whose rows are handed out in batches, as with
+// DECLARE ... CURSOR and FETCH. A plain scan of one table keeps its storage
+// iterator paused between fetches and evaluates WHERE and the select list
+// only for the rows it returns. Queries that need every row before the
+// first can be produced (joins, GROUP BY, ORDER BY, aggregates, catalog
+// tables, index lookups) run to completion when the cursor is opened and
+// are paged from the buffered result. A streamed SELECT DISTINCT remembers
+// the rows it has returned, so it holds one key per distinct row rather
+// than the whole result.
+type Cursor struct {
+	Columns []Column
+
+	next  func() ([][]byte, bool, error)
+	close func() error
+	done  bool
+	err   error           // the failure that ended the cursor, see Err
+	seen  map[string]bool // keys of the rows a streamed DISTINCT returned
+}
+
+// OpenCursor parses sql, which must be a SELECT or a set operation over
+// SELECTs, and opens a cursor over its result. The cursor reads through
+// the executor's engine, so a cursor opened on a transaction-scoped
+// executor sees that transaction's writes.
+func (e *Executor) OpenCursor(sql string) (*Cursor, error) {
+	stmt, err := parser.Parse(sql)
+	if err != nil {
+		return nil, &QueryError{Code: "42601", Message: err.Error()}
+	}
+	if err := bindParams(stmt, nil); err != nil {
+		return nil, err
+	}
+	bindStatementTime(stmt, e.statementTime())
+	if err := bindStatementRandom(stmt, e.rand); err != nil {
+		return nil, err
+	}
+	if err := bindStatementSizes(stmt, e.engine); err != nil {
+		return nil, err
+	}
+
+	switch stmt.(type) {
+	case *parser.SelectStmt, *parser.SetOpStmt:
+	default:
+		return nil, &QueryError{Code: "42P11", Message: "cursor query must be a SELECT"}
+	}
+	e = e.withViews()
+	runs, err := e.bindSubqueries(stmt)
+	if err != nil {
+		return nil, e.viewFailure(err)
+	}
+	if s, ok := stmt.(*parser.SelectStmt); ok && streamable(s) {
+		c, err := e.openScanCursor(s)
+		if err = e.viewFailure(err); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	result, err := e.dispatch(stmt, nil)
+	if err = e.viewFailure(runs.failure(err)); err != nil {
+		return nil, err
+	}
+	rows := result.Rows
+	return &Cursor{
+		Columns: result.Columns,
+		next: func() ([][]byte, bool, error) {
+			if len(rows) == 0 {
+				return nil, false, nil
+			}
+			row := rows[0]
+			rows = rows[1:]
+			return row, true, nil
+		},
+	}, nil
+}
+
+// streamable reports whether s can be answered row by row straight from a
+// table scan. A query with subqueries is not: a correlated one could only
+// report its errors once every row has been fetched.
+func streamable(s *parser.SelectStmt) bool {
+	if s.From.IsEmpty() || isCatalogTable(s.From.Schema, s.From.Name) || hasSubquery(s) {
+		return false
+	}
+	if len(s.Joins) > 0 || grouped(s) || len(s.OrderBy) > 0 || s.IndexedBy != "" {
+		return false
+	}
+	for _, col := range s.Columns {
+		if a, ok := col.(*parser.AliasExpr); ok {
+			col = a.Expr
+		}
+		if fn, ok := col.(*parser.FunctionCallExpr); ok && isAggFunc(fn.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// openScanCursor opens a cursor that pulls rows from a paused table scan.
+func (e *Executor) openScanCursor(s *parser.SelectStmt) (*Cursor, error) {
+	def, ok := e.engine.GetTable(s.From.Name)
+	if !ok {
+		return nil, WrapError(&storage.TableNotFoundError{Name: s.From.String()})
+	}
+	if err := resolveLimitOffset(s); err != nil {
+		return nil, err
+	}
+	colEvals, cols, err := e.resolveSelectColumns(s.Columns, def, s.FromAlias)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	var filter func(storage.Row) bool
+	if s.Where != nil {
+		filter, err = buildFilter(s.Where, def)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+	}
+
+	it, err := e.engine.Scan(s.From.Name)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+
+	var skip int64
+	if s.Offset != nil {
+		skip = *s.Offset
+	}
+	remaining := int64(-1) // -1 = no limit
+	if s.Limit != nil {
+		remaining = *s.Limit
+	}
+
+	c := &Cursor{Columns: cols, close: it.Close}
+	if s.Distinct {
+		c.seen = make(map[string]bool)
+	}
+	c.next = func() ([][]byte, bool, error) {
+		for remaining != 0 {
+			row, ok := it.Next()
+			if !ok {
+				return nil, false, nil
+			}
+			if filter != nil && !filter(row) {
+				continue
+			}
+			if c.seen == nil && skip > 0 {
+				skip--
+				continue
+			}
+			textRow, err := formatRow(row, colEvals)
+			if err != nil {
+				return nil, false, err
+			}
+			if c.seen != nil {
+				k := distinctKey(textRow, cols)
+				if c.seen[k] {
+					continue
+				}
+				c.seen[k] = true
+				if skip > 0 {
+					skip--
+					continue
+				}
+			}
+			if remaining > 0 {
+				remaining--
+			}
+			return textRow, true, nil
+		}
+		return nil, false, nil
+	}
+	return c, nil
+}
+
+// Fetch returns up to n further rows; n < 0 fetches all remaining rows. An
+// exhausted cursor returns no rows. A row that cannot be produced ends the
+// cursor; Err then reports why.
+func (c *Cursor) Fetch(n int64) [][][]byte {
+	var rows [][][]byte
+	for !c.done && (n < 0 || int64(len(rows)) < n) {
+		row, ok, err := c.next()
+		if err != nil {
+			c.err = err
+		}
+		if !ok {
+			c.done = true
+			break
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Err returns the error that ended the cursor, if any.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Close releases the cursor's scan. Fetching after Close returns no rows.
+func (c *Cursor) Close() error {
+	c.done = true
+	c.seen = nil
+	if c.close == nil {
+		return nil
+	}
+	err := c.close()
+	c.close = nil
+	return err
+}
@@ -0,0 +1,169 @@
+package executor
+
+import (
+	"fmt"
+	"testing"
+
+	"mulldb/storage"
+)
+
+// fetchAll drains c in batches of n and returns the first column of every
+// row, failing if any batch is larger than n.
+func fetchAll(t *testing.T, c *Cursor, n int64) []string {
+	t.Helper()
+	var got []string
+	for {
+		batch := c.Fetch(n)
+		if int64(len(batch)) > n {
+			t.Fatalf("batch of %d rows, want at most %d", len(batch), n)
+		}
+		if len(batch) == 0 {
+			return got
+		}
+		for _, row := range batch {
+			got = append(got, string(row[0]))
+		}
+	}
+}
+
+func TestCursor_FetchBatches(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER, grp TEXT)")
+	for i := 1; i <= 10; i++ {
+		exec(t, e, fmt.Sprintf("INSERT INTO t VALUES (%d, 'g%d')", i, i%3))
+	}
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		// Streamed from a paused scan.
+		{"SELECT id FROM t", "[1 2 3 4 5 6 7 8 9 10]"},
+		{"SELECT id FROM t WHERE id % 2 = 0", "[2 4 6 8 10]"},
+		{"SELECT id FROM t LIMIT 4 OFFSET 3", "[4 5 6 7]"},
+		// Buffered when the cursor opens.
+		{"SELECT id FROM t ORDER BY id DESC", "[10 9 8 7 6 5 4 3 2 1]"},
+		{"SELECT grp FROM t GROUP BY grp ORDER BY grp", "[g0 g1 g2]"},
+		{"SELECT COUNT(*) FROM t", "[10]"},
+	}
+	for _, tt := range tests {
+		c, err := e.OpenCursor(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := fmt.Sprint(fetchAll(t, c, 3)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.sql, got, tt.want)
+		}
+		if err := c.Close(); err != nil {
+			t.Errorf("%s: close: %v", tt.sql, err)
+		}
+	}
+}
+
+func TestCursor_FetchAllAndClose(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER, name TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+
+	c, err := e.OpenCursor("SELECT id, UPPER(name) AS n FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Columns) != 2 || c.Columns[1].Name != "n" {
+		t.Fatalf("columns = %v, want id, n", c.Columns)
+	}
+	if rows := c.Fetch(1); len(rows) != 1 || string(rows[0][1]) != "A" {
+		t.Fatalf("first fetch = %q, want [1 A]", rows)
+	}
+	if rows := c.Fetch(-1); len(rows) != 2 {
+		t.Fatalf("fetch all = %d rows, want the remaining 2", len(rows))
+	}
+	if rows := c.Fetch(5); len(rows) != 0 {
+		t.Errorf("fetch after end = %d rows, want 0", len(rows))
+	}
+
+	c, err = e.OpenCursor("SELECT id FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if rows := c.Fetch(5); len(rows) != 0 {
+		t.Errorf("fetch after close = %d rows, want 0", len(rows))
+	}
+}
+
+func TestCursor_StreamsDistinct(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (n INTEGER)")
+	fillTable(t, e, "t", 1000)
+
+	// The first rows come straight from the scan, before it has read the
+	// rest of the table, and the cursor keeps only the keys of the rows
+	// it has returned.
+	c, err := e.OpenCursor("SELECT DISTINCT n % 4 FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.seen == nil {
+		t.Fatal("SELECT DISTINCT without ORDER BY was not streamed")
+	}
+	if rows := c.Fetch(1); len(rows) != 1 || string(rows[0][0]) != "1" || len(c.seen) != 1 {
+		t.Fatalf("first fetch = %q with %d keys, want [1] and 1 key", rows, len(c.seen))
+	}
+	if got := fmt.Sprint(fetchAll(t, c, 3)); got != "[2 3 0]" {
+		t.Errorf("rest = %s, want [2 3 0]", got)
+	}
+	if len(c.seen) != 4 {
+		t.Errorf("distinct set holds %d keys, want 4", len(c.seen))
+	}
+	c.Close()
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT DISTINCT n % 4 FROM t WHERE n > 1", "[2 3 0 1]"},
+		{"SELECT DISTINCT n % 4 FROM t LIMIT 2 OFFSET 1", "[2 3]"},
+		{"SELECT DISTINCT n % 4 FROM t ORDER BY 1", "[0 1 2 3]"},
+	}
+	for _, tt := range tests {
+		c, err := e.OpenCursor(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := fmt.Sprint(fetchAll(t, c, 3)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.sql, got, tt.want)
+		}
+		c.Close()
+	}
+}
+
+func TestCursor_Errors(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER)")
+
+	_, err := e.OpenCursor("DELETE FROM t")
+	assertSQLSTATE(t, err, "42P11")
+	_, err = e.OpenCursor("SELECT id FROM missing")
+	assertSQLSTATE(t, err, "42P01")
+	_, err = e.OpenCursor("SELECT id FROM t LIMIT NULL")
+	assertSQLSTATE(t, err, "2201W")
+	_, err = e.OpenCursor("SELEC id FROM t")
+	assertSQLSTATE(t, err, "42601")
+}
+
+func TestCursor_SeesTransactionWrites(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER)")
+	exec(t, e, "INSERT INTO t VALUES (1)")
+
+	txe := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	exec(t, txe, "INSERT INTO t VALUES (2)")
+	c, err := txe.OpenCursor("SELECT id FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(fetchAll(t, c, 1)); got != "[1 2]" {
+		t.Errorf("cursor in transaction got %s, want [1 2]", got)
+	}
+}
@@ -0,0 +1,165 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// columnDefault is a parsed DEFAULT expression. A literal default is
+// evaluated once, when it is parsed; any other, such as NOW(), is
+// evaluated each time it is used.
+type columnDefault struct {
+	expr     parser.Expr
+	value    any // the literal's value when constant
+	constant bool
+}
+
+// defaultCache maps DEFAULT expression text, as stored in the catalog, to
+// its parsed form, so that an INSERT does not parse the text again for
+// every row. Few distinct texts exist, so entries are never evicted.
+var defaultCache sync.Map // string → *columnDefault
+
+// loadDefault returns the parsed form of the DEFAULT expression sql.
+func loadDefault(sql string) (*columnDefault, error) {
+	if d, ok := defaultCache.Load(sql); ok {
+		return d.(*columnDefault), nil
+	}
+	expr, err := parser.ParseExpr(sql)
+	if err != nil {
+		return nil, err
+	}
+	d := &columnDefault{expr: expr}
+	if v, ok := literalValue(expr); ok {
+		d.value, d.constant = v, true
+	} else if _, ok := expr.(*parser.NullLit); ok {
+		d.constant = true
+	}
+	defaultCache.Store(sql, d)
+	return d, nil
+}
+
+// defaultValue evaluates the default of col, converted to the column's
+// type. Columns without a default yield NULL.
+func defaultValue(col storage.ColumnDef) (any, error) {
+	if col.Default == "" {
+		return nil, nil
+	}
+	d, err := loadDefault(col.Default)
+	if err != nil {
+		return nil, fmt.Errorf("DEFAULT for column %q: %w", col.Name, err)
+	}
+	val := d.value
+	if !d.constant {
+		if val, err = evalLiteral(d.expr); err != nil {
+			return nil, fmt.Errorf("DEFAULT for column %q: %w", col.Name, err)
+		}
+	}
+	if val == nil || goTypeMatchesDataType(val, col.DataType) {
+		return val, nil
+	}
+	return coerceLiteral(val, col.DataType)
+}
+
+// evalInsertValue evaluates the value at position j of an INSERT row. The
+// DEFAULT keyword yields the default of the column the value goes to;
+// a position past the end of the column list is left for the engine to
+// reject.
+func evalInsertValue(def *storage.TableDef, columns []string, j int, expr parser.Expr) (any, error) {
+	if _, ok := expr.(*parser.DefaultExpr); !ok {
+		return evalLiteral(expr)
+	}
+	switch {
+	case columns != nil && j < len(columns):
+		return namedDefault(def, columns[j])
+	case columns == nil && j < len(def.Columns):
+		return defaultValue(def.Columns[j])
+	}
+	return nil, nil
+}
+
+// evalSetValue evaluates the value of an UPDATE or ON CONFLICT assignment,
+// where DEFAULT yields the column's default.
+func evalSetValue(def *storage.TableDef, sc parser.SetClause) (any, error) {
+	if _, ok := sc.Value.(*parser.DefaultExpr); ok {
+		return namedDefault(def, sc.Column)
+	}
+	return evalLiteral(sc.Value)
+}
+
+// namedDefault returns the default of the column name. An unknown column
+// yields NULL; the engine reports it when the row is written.
+func namedDefault(def *storage.TableDef, name string) (any, error) {
+	for _, c := range def.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return defaultValue(c)
+		}
+	}
+	return nil, nil
+}
+
+// applyDefaults fills in the columns an INSERT leaves out, those missing
+// from its column list or, without a list, those past the end of a short
+// row, with their defaults. It returns the column list and rows to hand to
+// the engine. Rows whose length does not fit are passed through for the
+// engine to reject.
+func applyDefaults(def *storage.TableDef, columns []string, rows [][]any) ([]string, [][]any, error) {
+	if columns == nil {
+		for i, row := range rows {
+			if len(row) >= len(def.Columns) {
+				continue
+			}
+			full := make([]any, len(def.Columns))
+			copy(full, row)
+			for j := len(row); j < len(def.Columns); j++ {
+				v, err := defaultValue(def.Columns[j])
+				if err != nil {
+					return nil, nil, err
+				}
+				full[j] = v
+			}
+			rows[i] = full
+		}
+		return nil, rows, nil
+	}
+
+	// Columns left out of the list default to NULL in the engine, so only
+	// those with a DEFAULT need adding.
+	listed := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		listed[strings.ToLower(c)] = true
+	}
+	var missing []storage.ColumnDef
+	for _, c := range def.Columns {
+		if c.Default != "" && !listed[strings.ToLower(c.Name)] {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) == 0 {
+		return columns, rows, nil
+	}
+	for _, row := range rows {
+		if len(row) != len(columns) {
+			return columns, rows, nil
+		}
+	}
+
+	cols := append(append([]string(nil), columns...), make([]string, len(missing))...)
+	for i, c := range missing {
+		cols[len(columns)+i] = c.Name
+	}
+	for i, row := range rows {
+		for _, c := range missing {
+			v, err := defaultValue(c)
+			if err != nil {
+				return nil, nil, err
+			}
+			row = append(row, v)
+		}
+		rows[i] = row
+	}
+	return cols, rows, nil
+}
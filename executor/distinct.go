@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"mulldb/parser"
+)
+
+// execSelectDistinct runs SELECT DISTINCT: the query runs without its
+// LIMIT and OFFSET, the first of each set of equal result rows is kept and
+// LIMIT and OFFSET then apply to what remains. As in PostgreSQL, ORDER BY
+// may only sort by the select list, so equal rows sort together and
+// keeping the first of them leaves the order intact.
+func (e *Executor) execSelectDistinct(s *parser.SelectStmt, tr *Trace) (*Result, error) {
+	if err := checkDistinctOrderBy(s); err != nil {
+		return nil, err
+	}
+	if err := resolveLimitOffset(s); err != nil {
+		return nil, err
+	}
+	q := *s
+	q.Distinct, q.Limit, q.Offset = false, nil, nil
+	res, err := e.execSelect(&q, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	var skip int64
+	if s.Offset != nil {
+		skip = *s.Offset
+	}
+	seen := make(map[string]bool)
+	var rows [][][]byte
+	for _, row := range res.Rows {
+		if s.Limit != nil && int64(len(rows)) == *s.Limit {
+			break
+		}
+		k := distinctKey(row, res.Columns)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		if skip > 0 {
+			skip--
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if tr != nil {
+		tr.RowsReturned = int64(len(rows))
+	}
+	return &Result{
+		Columns: res.Columns,
+		Rows:    rows,
+		Tag:     fmt.Sprintf("SELECT %d", len(rows)),
+	}, nil
+}
+
+// checkDistinctOrderBy rejects an ORDER BY of a SELECT DISTINCT that sorts
+// by something other than the select list: a position, the name or alias
+// of a result column, or an expression that appears in the list.
+func checkDistinctOrderBy(s *parser.SelectStmt) error {
+	for _, ob := range s.OrderBy {
+		if ob.Position > 0 || selectListHas(s.Columns, ob) {
+			continue
+		}
+		return &QueryError{Code: "42P10", Message: "for SELECT DISTINCT, ORDER BY expressions must appear in select list"}
+	}
+	return nil
+}
+
+// selectListHas reports whether the ORDER BY key ob is one of cols.
+func selectListHas(cols []parser.Expr, ob parser.OrderByClause) bool {
+	for _, col := range cols {
+		expr := col
+		if a, ok := col.(*parser.AliasExpr); ok {
+			if ob.Column != "" && ob.Table == "" && strings.EqualFold(a.Alias, ob.Column) {
+				return true
+			}
+			expr = a.Expr
+		}
+		switch c := expr.(type) {
+		case *parser.StarExpr:
+			if ob.Column != "" {
+				return true
+			}
+		case *parser.ColumnRef:
+			if ob.Column != "" && strings.EqualFold(c.Name, ob.Column) &&
+				(ob.Table == "" || c.Table == "" || strings.EqualFold(c.Table, ob.Table)) {
+				return true
+			}
+		}
+		if ob.Expr != nil && reflect.DeepEqual(expr, ob.Expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// distinctKey returns the key by which SELECT DISTINCT tells result rows
+// apart. Each cell is length-prefixed, so a NULL differs from an empty
+// string; NUMERICs that differ only in trailing zeros are the same value.
+func distinctKey(row [][]byte, cols []Column) string {
+	var b strings.Builder
+	for i, cell := range row {
+		if cell == nil {
+			b.WriteString("-;")
+			continue
+		}
+		if cols[i].TypeOID == OIDNumeric && bytes.IndexByte(cell, '.') >= 0 {
+			cell = bytes.TrimRight(bytes.TrimRight(cell, "0"), ".")
+		}
+		b.WriteString(strconv.Itoa(len(cell)))
+		b.WriteByte(':')
+		b.Write(cell)
+	}
+	return b.String()
+}
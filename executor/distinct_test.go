@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSelect_Distinct(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER, grp TEXT, n NUMERIC)")
+	exec(t, e, `INSERT INTO t VALUES (1, 'a', 1.5), (2, 'b', 1.50), (3, 'a', 2),
+		(4, NULL, 2), (5, '', NULL), (6, NULL, NULL), (7, 'b', 1.5)`)
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT DISTINCT grp FROM t", "[a b - ]"},
+		{"SELECT DISTINCT grp FROM t ORDER BY grp", "[ a b -]"},
+		{"SELECT DISTINCT grp, n FROM t ORDER BY 1, 2", "[|- a|1.5 a|2 b|1.5 -|2 -|-]"},
+		{"SELECT DISTINCT n FROM t WHERE n IS NOT NULL ORDER BY n", "[1.5 2]"},
+		{"SELECT DISTINCT grp AS g FROM t ORDER BY grp DESC LIMIT 2", "[- b]"},
+		{"SELECT DISTINCT grp FROM t LIMIT 2 OFFSET 1", "[b -]"},
+		{"SELECT DISTINCT grp FROM t WHERE grp IN (SELECT DISTINCT grp FROM t WHERE id > 4)", "[b ]"},
+		{"SELECT DISTINCT COUNT(*) FROM t", "[7]"},
+		{"SELECT DISTINCT grp FROM t GROUP BY grp, n ORDER BY grp", "[ a b -]"},
+		{"SELECT DISTINCT 1, 'x'", "[1|x]"},
+		{"SELECT ALL grp FROM t WHERE id < 4", "[a b a]"},
+	}
+	for _, tt := range tests {
+		r, err := e.Execute(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := fmt.Sprint(groupRows(r)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.sql, got, tt.want)
+		}
+		if want := fmt.Sprintf("SELECT %d", len(r.Rows)); r.Tag != want {
+			t.Errorf("%s: tag %q, want %q", tt.sql, r.Tag, want)
+		}
+	}
+
+	// As in PostgreSQL, ORDER BY may only sort by the select list.
+	_, err := e.Execute("SELECT DISTINCT grp FROM t ORDER BY id")
+	assertSQLSTATE(t, err, "42P10")
+}
@@ -1,8 +1,11 @@
 package executor
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,18 +18,32 @@ import (
 // Executor takes a parsed SQL statement and executes it against the
 // storage engine, returning a Result suitable for the wire protocol.
 type Executor struct {
-	engine storage.Engine
+	engine   storage.Engine
+	rand     *sessionRand     // RANDOM() generator, shared with derived executors
+	settings *sessionSettings // session parameters, shared with derived executors
+	ctx      context.Context  // cancels the statements run through it; nil for none
+	intr     *interrupt       // cancellation of the running statement; nil for none
+	txTime   time.Time        // start of the transaction it runs in; zero outside one
 }
 
 // New creates an Executor backed by the given storage engine.
 func New(engine storage.Engine) *Executor {
-	return &Executor{engine: engine}
+	return &Executor{engine: engine, rand: newSessionRand(), settings: &sessionSettings{}}
 }
 
 // WithEngine returns a new Executor backed by the given engine.
-// Used to create a transaction-scoped executor.
+// Used to create a transaction-scoped executor: NOW() and CURRENT_TIMESTAMP
+// in its statements return the time WithEngine was called, as they return
+// the start of the transaction in PostgreSQL.
 func (e *Executor) WithEngine(eng storage.Engine) *Executor {
-	return &Executor{engine: eng}
+	return &Executor{engine: eng, rand: e.rand, settings: e.settings, txTime: currentTime()}
+}
+
+// NewSession returns an Executor on the same engine with its own session
+// state: the generator behind RANDOM() and SETSEED(), and the session
+// parameters. The server creates one per connection.
+func (e *Executor) NewSession() *Executor {
+	return &Executor{engine: e.engine, rand: newSessionRand(), settings: &sessionSettings{}}
 }
 
 // Engine returns the underlying storage engine.
@@ -44,33 +61,81 @@ func (e *Executor) GetFsync() bool {
 
 // Execute runs a single SQL statement (no tracing overhead).
 func (e *Executor) Execute(sql string) (*Result, error) {
-	return e.execute(sql, nil)
+	return e.execute(sql, nil, nil)
 }
 
 // ExecuteTraced runs a single SQL statement with timing instrumentation.
 func (e *Executor) ExecuteTraced(sql string) (*Result, *Trace, error) {
 	tr := &Trace{}
 	start := time.Now()
-	result, err := e.execute(sql, tr)
+	result, err := e.execute(sql, nil, tr)
 	tr.Total = time.Since(start)
 	return result, tr, err
 }
 
-func (e *Executor) execute(sql string, tr *Trace) (*Result, error) {
-	var parseStart time.Time
-	if tr != nil {
-		parseStart = time.Now()
-	}
-
-	stmt, err := parser.Parse(sql)
+func (e *Executor) execute(sql string, params []any, tr *Trace) (*Result, error) {
+	// Parse time is always measured: EXPLAIN ANALYZE reports it even when
+	// the caller did not ask for a trace.
+	parseStart := time.Now()
+	stmt, hints, err := parser.ParseWithHints(sql)
+	parseTime := time.Since(parseStart)
 
 	if tr != nil {
-		tr.Parse = time.Since(parseStart)
+		tr.Parse = parseTime
 	}
 	if err != nil {
 		return nil, &QueryError{Code: "42601", Message: err.Error()} // syntax_error
 	}
+	if err := bindParams(stmt, params); err != nil {
+		return nil, err
+	}
+	bindStatementTime(stmt, e.statementTime())
+	if err := bindStatementRandom(stmt, e.rand); err != nil {
+		return nil, err
+	}
+	if err := bindStatementSizes(stmt, e.engine); err != nil {
+		return nil, err
+	}
+
+	timeout := e.settings.statementTimeout
+	if hints.Timeout > 0 {
+		timeout = hints.Timeout
+	}
+	ctx, cancel := e.statementContext(timeout)
+	defer cancel()
+	if ctx != nil {
+		e = e.withInterrupt(ctx)
+		defer func() { e.intr.done = true }()
+	}
+	e = e.withViews()
+	runs, err := e.bindSubqueries(stmt)
+	if err != nil {
+		return nil, e.viewFailure(err)
+	}
+
+	var result *Result
+	if s, ok := stmt.(*parser.ExplainStmt); ok {
+		if tr != nil {
+			tr.StmtType = "EXPLAIN"
+		}
+		result, err = e.execExplain(s, parseTime, tr)
+	} else {
+		result, err = e.dispatch(stmt, tr)
+	}
+	if e.intr != nil && e.intr.err != nil {
+		return nil, e.intr.err
+	}
+	if err = e.viewFailure(runs.failure(err)); err != nil {
+		return nil, err
+	}
+	if msg := e.seqScanNotice(stmt); msg != "" {
+		result.Notices = append(result.Notices, msg)
+	}
+	return result, nil
+}
 
+// dispatch runs an already-parsed statement.
+func (e *Executor) dispatch(stmt parser.Statement, tr *Trace) (*Result, error) {
 	switch s := stmt.(type) {
 	case *parser.CreateTableStmt:
 		if tr != nil {
@@ -98,6 +163,17 @@ func (e *Executor) execute(sql string, tr *Trace) (*Result, error) {
 			}
 		}
 		return e.execSelect(s, tr)
+	case *parser.SetOpStmt:
+		if tr != nil {
+			tr.StmtType = "SELECT"
+		}
+		return e.execSetOp(s, tr)
+	case *parser.CopyStmt:
+		result, err := e.execCopy(s, tr)
+		if tr != nil {
+			tr.StmtType = "COPY"
+		}
+		return result, err
 	case *parser.UpdateStmt:
 		if tr != nil {
 			tr.StmtType = "UPDATE"
@@ -110,6 +186,49 @@ func (e *Executor) execute(sql string, tr *Trace) (*Result, error) {
 			tr.Table = s.Table.Name
 		}
 		return e.execDelete(s, tr)
+	case *parser.TruncateStmt:
+		if tr != nil {
+			tr.StmtType = "TRUNCATE"
+			names := make([]string, len(s.Tables))
+			for i, t := range s.Tables {
+				names[i] = t.Name
+			}
+			tr.Table = strings.Join(names, ", ")
+		}
+		return e.execTruncate(s, tr)
+	case *parser.AnalyzeStmt:
+		if tr != nil {
+			tr.StmtType = "ANALYZE"
+			tr.Table = s.Table.Name
+		}
+		return e.execAnalyze(s, tr)
+	case *parser.VacuumStmt:
+		if tr != nil {
+			tr.StmtType = "VACUUM"
+			tr.Table = s.Table.Name
+		}
+		return e.execVacuum(s, tr)
+	case *parser.CheckpointStmt:
+		if tr != nil {
+			tr.StmtType = "CHECKPOINT"
+		}
+		return e.execCheckpoint(tr)
+	case *parser.ListenStmt:
+		return channelResult(&ChannelCommand{Op: "LISTEN", Channel: s.Channel}, tr)
+	case *parser.UnlistenStmt:
+		return channelResult(&ChannelCommand{Op: "UNLISTEN", Channel: s.Channel}, tr)
+	case *parser.NotifyStmt:
+		return execNotify(s, tr)
+	case *parser.BackupStmt:
+		if tr != nil {
+			tr.StmtType = "BACKUP"
+		}
+		return e.execBackup(s, tr)
+	case *parser.ValuesStmt:
+		if tr != nil {
+			tr.StmtType = "VALUES"
+		}
+		return execValues(s)
 	case *parser.BeginStmt:
 		if tr != nil {
 			tr.StmtType = "BEGIN"
@@ -149,6 +268,18 @@ func (e *Executor) execute(sql string, tr *Trace) (*Result, error) {
 			tr.Table = s.Table.Name
 		}
 		return e.execDropIndex(s, tr)
+	case *parser.CreateViewStmt:
+		if tr != nil {
+			tr.StmtType = "CREATE VIEW"
+			tr.Table = s.Name.Name
+		}
+		return e.execCreateView(s)
+	case *parser.DropViewStmt:
+		if tr != nil {
+			tr.StmtType = "DROP VIEW"
+			tr.Table = s.Name.Name
+		}
+		return e.execDropView(s)
 	case *parser.ShowMemoryStmt:
 		if tr != nil {
 			tr.StmtType = "SHOW MEMORY"
@@ -175,7 +306,22 @@ func (e *Executor) execCreateTable(s *parser.CreateTableStmt, tr *Trace) (*Resul
 		if err != nil {
 			return nil, WrapError(err)
 		}
-		cols[i] = storage.ColumnDef{Name: c.Name, DataType: dt, PrimaryKey: c.PrimaryKey, NotNull: c.NotNull || c.PrimaryKey}
+		cols[i] = storage.ColumnDef{Name: c.Name, DataType: dt, PrimaryKey: c.PrimaryKey, NotNull: c.NotNull || c.PrimaryKey, Default: c.DefaultSQL, Precision: c.Precision, Scale: c.Scale, Identity: parseIdentity(c.Identity), Dictionary: c.Dictionary}
+		// Evaluate the default once so that a default that can never
+		// be stored is rejected now rather than on every INSERT.
+		if _, err := defaultValue(cols[i]); err != nil {
+			return nil, WrapError(err)
+		}
+	}
+	for i, c := range s.Columns {
+		if c.References == nil {
+			continue
+		}
+		fk, err := e.resolveForeignKey(s, cols, i)
+		if err != nil {
+			return nil, err
+		}
+		cols[i].References = fk
 	}
 
 	if tr != nil {
@@ -191,6 +337,19 @@ func (e *Executor) execCreateTable(s *parser.CreateTableStmt, tr *Trace) (*Resul
 		return nil, WrapError(err)
 	}
 
+	// UNIQUE column constraints become unique indexes, named the way
+	// PostgreSQL names them. A primary key is unique already.
+	for _, c := range s.Columns {
+		if !c.Unique || c.PrimaryKey {
+			continue
+		}
+		idx := storage.IndexDef{Name: s.Name.Name + "_" + c.Name + "_key", Columns: []string{c.Name}, Unique: true}
+		if err := e.engine.CreateIndex(s.Name.Name, idx); err != nil {
+			e.engine.DropTable(s.Name.Name)
+			return nil, WrapError(err)
+		}
+	}
+
 	if tr != nil {
 		tr.Exec = time.Since(execStart)
 	}
@@ -204,6 +363,15 @@ func (e *Executor) execDropTable(s *parser.DropTableStmt, tr *Trace) (*Result, e
 		execStart = time.Now()
 	}
 
+	if def, ok := e.engine.GetTable(s.Name.Name); ok {
+		for _, ref := range e.referencesTo(def, nil) {
+			if ref.child.Name != def.Name {
+				return nil, &QueryError{Code: "2BP01", Message: fmt.Sprintf(
+					"cannot drop table %s because constraint %s on table %s depends on it", def.Name, ref.name(), ref.child.Name)}
+			}
+		}
+	}
+
 	if err := e.engine.DropTable(s.Name.Name); err != nil {
 		return nil, WrapError(err)
 	}
@@ -219,9 +387,11 @@ func (e *Executor) execAlterTableAddColumn(s *parser.AlterTableAddColumnStmt, tr
 	if isCatalogTable(s.Table.Schema, s.Table.Name) {
 		return nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot alter catalog table %q", s.Table.String())}
 	}
-
-	if s.Column.NotNull {
-		return nil, &QueryError{Code: "0A000", Message: "cannot add a NOT NULL column without a default value"}
+	if s.Column.References != nil {
+		return nil, &QueryError{Code: "0A000", Message: "ADD COLUMN with REFERENCES is not supported"}
+	}
+	if s.Column.Identity != "" {
+		return nil, &QueryError{Code: "0A000", Message: "ADD COLUMN of an identity column is not supported"}
 	}
 
 	dt, err := parseDataType(s.Column.DataType)
@@ -229,8 +399,18 @@ func (e *Executor) execAlterTableAddColumn(s *parser.AlterTableAddColumnStmt, tr
 		return nil, WrapError(err)
 	}
 	col := storage.ColumnDef{
-		Name:     s.Column.Name,
-		DataType: dt,
+		Name:       s.Column.Name,
+		DataType:   dt,
+		NotNull:    s.Column.NotNull,
+		Default:    s.Column.DefaultSQL,
+		Precision:  s.Column.Precision,
+		Scale:      s.Column.Scale,
+		Dictionary: s.Column.Dictionary,
+	}
+	// The default is evaluated once and stored in the rows that already
+	// exist, so a default such as NOW() gives them all the same value.
+	if col.Fill, err = defaultValue(col); err != nil {
+		return nil, WrapError(err)
 	}
 
 	var execStart time.Time
@@ -259,6 +439,13 @@ func (e *Executor) execAlterTableDropColumn(s *parser.AlterTableDropColumnStmt,
 		execStart = time.Now()
 	}
 
+	if def, ok := e.engine.GetTable(s.Table.Name); ok {
+		for _, ref := range e.referencesTo(def, []string{s.Column}) {
+			return nil, &QueryError{Code: "2BP01", Message: fmt.Sprintf(
+				"cannot drop column %s of table %s because constraint %s on table %s depends on it", s.Column, def.Name, ref.name(), ref.child.Name)}
+		}
+	}
+
 	if err := e.engine.DropColumn(s.Table.Name, s.Column); err != nil {
 		return nil, WrapError(err)
 	}
@@ -277,7 +464,7 @@ func (e *Executor) execCreateIndex(s *parser.CreateIndexStmt, tr *Trace) (*Resul
 
 	name := s.Name
 	if name == "" {
-		name = "idx_" + s.Column
+		name = "idx_" + strings.Join(s.Columns, "_")
 	}
 
 	var execStart time.Time
@@ -286,9 +473,9 @@ func (e *Executor) execCreateIndex(s *parser.CreateIndexStmt, tr *Trace) (*Resul
 	}
 
 	idx := storage.IndexDef{
-		Name:   name,
-		Column: s.Column,
-		Unique: s.Unique,
+		Name:    name,
+		Columns: s.Columns,
+		Unique:  s.Unique,
 	}
 	if err := e.engine.CreateIndex(s.Table.Name, idx); err != nil {
 		return nil, WrapError(err)
@@ -337,11 +524,27 @@ func (e *Executor) execInsert(s *parser.InsertStmt, tr *Trace) (*Result, error)
 		return nil, WrapError(&storage.TableNotFoundError{Name: s.Table.String()})
 	}
 
+	// Rows that reference other tables must find their parents, and an
+	// upsert that changes a referenced key must not strand its children.
+	checkFK := hasReferences(def, nil)
+	var refs []fkRef
+	if s.OnConflict != nil && len(s.OnConflict.Sets) > 0 {
+		refs = e.referencesTo(def, setColumns(s.OnConflict.Sets))
+	}
+	if (checkFK || len(refs) > 0) && !e.inTransaction() {
+		return e.atomically(func(x *Executor) (*Result, error) { return x.execInsert(s, tr) })
+	}
+
+	ret, err := e.compileReturning(s.Returning, def)
+	if err != nil {
+		return nil, err
+	}
+
 	rows := make([][]any, len(s.Values))
 	for i, exprRow := range s.Values {
 		vals := make([]any, len(exprRow))
 		for j, expr := range exprRow {
-			v, err := evalLiteral(expr)
+			v, err := evalInsertValue(def, s.Columns, j, expr)
 			if err != nil {
 				return nil, WrapError(fmt.Errorf("row %d, value %d: %w", i, j, err))
 			}
@@ -350,6 +553,28 @@ func (e *Executor) execInsert(s *parser.InsertStmt, tr *Trace) (*Result, error)
 		rows[i] = vals
 	}
 
+	columns, rows, err := applyDefaults(def, s.Columns, rows)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+
+	// ON CONFLICT DO UPDATE takes constants only, like UPDATE ... SET;
+	// EXCLUDED is not supported.
+	var oc *storage.OnConflict
+	if s.OnConflict != nil {
+		oc = &storage.OnConflict{Column: s.OnConflict.Column}
+		if s.OnConflict.Sets != nil {
+			oc.Sets = make(map[string]any, len(s.OnConflict.Sets))
+		}
+		for _, sc := range s.OnConflict.Sets {
+			v, err := evalSetValue(def, sc)
+			if err != nil {
+				return nil, WrapError(fmt.Errorf("SET %s: %w", sc.Column, err))
+			}
+			oc.Sets[sc.Column] = v
+		}
+	}
+
 	if tr != nil {
 		tr.Plan = time.Since(planStart)
 	}
@@ -359,27 +584,94 @@ func (e *Executor) execInsert(s *parser.InsertStmt, tr *Trace) (*Result, error)
 		execStart = time.Now()
 	}
 
-	n, err := e.engine.Insert(s.Table.Name, s.Columns, rows)
+	var n int64
+	var inserted []storage.Row
+	if oc != nil {
+		inserted, err = e.engine.Upsert(s.Table.Name, columns, rows, *oc)
+		n = int64(len(inserted))
+	} else if ret != nil || checkFK {
+		inserted, err = e.engine.InsertReturning(s.Table.Name, columns, rows)
+		n = int64(len(inserted))
+	} else {
+		n, err = e.engine.Insert(s.Table.Name, columns, rows)
+	}
 	if err != nil {
 		return nil, WrapError(err)
 	}
+	if checkFK {
+		if err := e.checkForeignKeys(def, inserted); err != nil {
+			return nil, err
+		}
+	}
+	if len(refs) > 0 {
+		if err := e.checkReferencingRows(def, refs); err != nil {
+			return nil, err
+		}
+	}
 
 	if tr != nil {
 		tr.Exec = time.Since(execStart)
 		tr.RowsReturned = int64(n)
 	}
 
-	_ = def // used above for context; insert delegates column resolution to engine
-	return &Result{Tag: fmt.Sprintf("INSERT 0 %d", n)}, nil
+	return ret.result(inserted, fmt.Sprintf("INSERT 0 %d", n))
+}
+
+// resolveLimitOffset evaluates LIMIT and OFFSET expressions into s.Limit and
+// s.Offset and validates the result. A negative or NULL LIMIT is SQLSTATE
+// 2201W, a negative or NULL OFFSET is 2201X. Resolved expressions are
+// cleared, so calling it again is cheap.
+func resolveLimitOffset(s *parser.SelectStmt) error {
+	if s.LimitExpr != nil {
+		v, err := evalLimitExpr(s.LimitExpr, "LIMIT", "2201W")
+		if err != nil {
+			return err
+		}
+		s.Limit, s.LimitExpr = &v, nil
+	}
+	if s.OffsetExpr != nil {
+		v, err := evalLimitExpr(s.OffsetExpr, "OFFSET", "2201X")
+		if err != nil {
+			return err
+		}
+		s.Offset, s.OffsetExpr = &v, nil
+	}
+	if s.Limit != nil && *s.Limit < 0 {
+		return &QueryError{Code: "2201W", Message: "LIMIT must not be negative"}
+	}
+	if s.Offset != nil && *s.Offset < 0 {
+		return &QueryError{Code: "2201X", Message: "OFFSET must not be negative"}
+	}
+	return nil
+}
+
+// evalLimitExpr evaluates a LIMIT or OFFSET expression, which may not
+// reference columns, to an integer.
+func evalLimitExpr(expr parser.Expr, clause, nullCode string) (int64, error) {
+	v, _, err := evalStaticExpr(expr)
+	if err != nil {
+		return 0, err
+	}
+	switch x := v.(type) {
+	case nil:
+		return 0, &QueryError{Code: nullCode, Message: clause + " must not be null"}
+	case int64:
+		return x, nil
+	default:
+		return 0, &QueryError{Code: "42804", Message: fmt.Sprintf("argument of %s must be type integer", clause)}
+	}
 }
 
 func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error) {
+	if s.Distinct {
+		return e.execSelectDistinct(s, tr)
+	}
 	if s.From.IsEmpty() {
-		return execSelectStatic(s.Columns)
+		return e.execSelectStatic(s.Columns)
 	}
 
 	// Reject GROUP BY with JOINs early.
-	if len(s.GroupBy) > 0 && len(s.Joins) > 0 {
+	if grouped(s) && len(s.Joins) > 0 {
 		return nil, &QueryError{Code: "0A000", Message: "GROUP BY is not supported with JOINs"}
 	}
 
@@ -404,22 +696,11 @@ func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error)
 		}
 	}
 
-	// Validate LIMIT/OFFSET values.
-	if s.Limit != nil && *s.Limit < 0 {
-		return nil, &QueryError{Code: "2201W", Message: "LIMIT must not be negative"}
-	}
-	if s.Offset != nil && *s.Offset < 0 {
-		return nil, &QueryError{Code: "2201X", Message: "OFFSET must not be negative"}
+	if err := resolveLimitOffset(s); err != nil {
+		return nil, err
 	}
 
 	// Detect aggregate vs non-aggregate columns.
-	isAggFunc := func(name string) bool {
-		switch name {
-		case "COUNT", "SUM", "MIN", "MAX", "AVG":
-			return true
-		}
-		return false
-	}
 	hasAgg, hasNonAgg := false, false
 	for _, col := range s.Columns {
 		expr := col
@@ -432,9 +713,17 @@ func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error)
 			hasNonAgg = true
 		}
 	}
-	if len(s.GroupBy) > 0 {
+	if grouped(s) {
 		return e.execSelectGroupBy(s, def, hasAgg, tr)
 	}
+	for _, col := range s.Columns {
+		if a, ok := col.(*parser.AliasExpr); ok {
+			col = a.Expr
+		}
+		if fn, ok := col.(*parser.FunctionCallExpr); ok && fn.Name == "GROUPING" {
+			return nil, &QueryError{Code: "42803", Message: "GROUPING must be used with GROUP BY"}
+		}
+	}
 	if hasAgg && hasNonAgg {
 		return nil, &QueryError{
 			Code:    "42803",
@@ -466,18 +755,35 @@ func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error)
 		}
 	}
 
-	// Validate ORDER BY columns and resolve their indices.
-	type orderKey struct {
-		colIdx int
-		desc   bool
-	}
-	var orderKeys []orderKey
+	// Validate ORDER BY columns and resolve their indices. Expression keys
+	// and positions are sorted on as extra columns past the table's own;
+	// see appendSortKeys.
+	var orderKeys []sortKey
+	var exprKeys []exprFunc
 	for _, ob := range s.OrderBy {
+		if ob.Position > 0 {
+			i, err := orderPosition(ob, len(colEvals))
+			if err != nil {
+				return nil, err
+			}
+			orderKeys = append(orderKeys, valueSortKey(ordinalEnd(def)+len(exprKeys), ob.Desc, nullsFirst(ob)))
+			exprKeys = append(exprKeys, colEvals[i])
+			continue
+		}
+		if ob.Expr != nil {
+			eval, err := compileExpr(ob.Expr, def)
+			if err != nil {
+				return nil, WrapError(err)
+			}
+			orderKeys = append(orderKeys, valueSortKey(ordinalEnd(def)+len(exprKeys), ob.Desc, nullsFirst(ob)))
+			exprKeys = append(exprKeys, eval)
+			continue
+		}
 		idx := columnIndex(def, ob.Column)
 		if idx < 0 {
 			return nil, WrapError(fmt.Errorf("column %q not found in table %q", ob.Column, def.Name))
 		}
-		orderKeys = append(orderKeys, orderKey{colIdx: idx, desc: ob.Desc})
+		orderKeys = append(orderKeys, columnSortKey(idx, ob.Desc, nullsFirst(ob), columnByOrdinal(def, idx).DataType))
 	}
 
 	if tr != nil {
@@ -489,44 +795,26 @@ func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error)
 		execStart = time.Now()
 	}
 
-	// Try PK index lookup for simple equality on the primary key column.
-	if !isCatalog && s.Where != nil {
-		if row, ok := e.tryPKLookup(s.Where, def); ok {
-			if tr != nil {
-				tr.IndexName = "PRIMARY"
-				tr.RowsScanned = 1
-			}
-			// Apply OFFSET/LIMIT to the single-row result.
-			var resultRows [][][]byte
-			skip := s.Offset != nil && *s.Offset > 0
-			empty := s.Limit != nil && *s.Limit == 0
-			if !skip && !empty {
-				textRow := make([][]byte, len(colEvals))
-				for i, eval := range colEvals {
-					textRow[i] = formatValue(eval(*row))
-				}
-				resultRows = [][][]byte{textRow}
-			}
-			if tr != nil {
-				tr.RowsReturned = int64(len(resultRows))
-				tr.Exec = time.Since(execStart)
-			}
-			return &Result{
-				Columns: resultCols,
-				Rows:    resultRows,
-				Tag:     fmt.Sprintf("SELECT %d", len(resultRows)),
-			}, nil
-		}
+	// Read the rows chooseAccess finds through an index: a primary key
+	// lookup, the index INDEXED BY names, or the planner's probes of an
+	// index once per value of an equality or IN list, or walk of a range.
+	sorted := pkSorted(s, def)
+	path, err := e.chooseAccess(s.From.Name, def, isCatalog, s.IndexedBy, s.Where, sorted)
+	if err != nil {
+		return nil, err
 	}
-
-	// Explicit INDEXED BY: use named secondary index.
-	if !isCatalog && s.IndexedBy != "" {
-		rows, err := e.lookupByNamedIndex(s.IndexedBy, s.Where, def)
-		if err != nil {
+	var indexRows []storage.Row
+	var usedIndex string
+	if path.readsIndex() {
+		if indexRows, err = e.readIndex(def, path); err != nil {
 			return nil, err
 		}
+		usedIndex = path.index
+	}
+	if usedIndex != "" {
+		rows := indexRows
+		tr.setAccess(usedIndex)
 		if tr != nil {
-			tr.IndexName = s.IndexedBy
 			tr.RowsScanned = int64(len(rows))
 		}
 		var resultRows [][][]byte
@@ -539,32 +827,14 @@ func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error)
 			limit = *s.Limit
 		}
 
-		// Optionally sort.
+		// Optionally sort. Rows come out of the index in key order, so
+		// ORDER BY the indexed column needs no sort.
+		if namedIndexSorted(s, def) || indexUnionSorted(s, def, usedIndex) {
+			orderKeys = nil
+		}
 		if len(orderKeys) > 0 {
-			sort.SliceStable(rows, func(i, j int) bool {
-				for _, ok := range orderKeys {
-					vi := storage.RowValue(rows[i].Values, ok.colIdx)
-					vj := storage.RowValue(rows[j].Values, ok.colIdx)
-					c := storage.CompareValues(vi, vj)
-					if c == -2 {
-						if vi == nil && vj == nil {
-							continue
-						}
-						if vi == nil {
-							return false
-						}
-						return true
-					}
-					if c == 0 {
-						continue
-					}
-					if ok.desc {
-						return c > 0
-					}
-					return c < 0
-				}
-				return false
-			})
+			appendSortKeys(rows, ordinalEnd(def), exprKeys)
+			sortRows(rows, orderKeys, e.intr.stopped)
 		}
 
 		var skipped int64
@@ -579,9 +849,9 @@ func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error)
 			if limit >= 0 && int64(len(resultRows)) >= limit {
 				break
 			}
-			textRow := make([][]byte, len(colEvals))
-			for i, eval := range colEvals {
-				textRow[i] = formatValue(eval(row))
+			textRow, err := formatRow(row, colEvals)
+			if err != nil {
+				return nil, err
 			}
 			resultRows = append(resultRows, textRow)
 		}
@@ -596,12 +866,17 @@ func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error)
 		}, nil
 	}
 
-	// Scan and filter rows.
+	// Scan and filter rows. A range on the primary key, or ORDER BY the
+	// key, walks the key index instead; rows then arrive in key order.
 	var it storage.RowIterator
 	if isCatalog {
+		tr.setAccess("")
 		it, err = scanCatalogTable(s.From.Schema, s.From.Name, e.engine)
 	} else {
-		it, err = e.engine.Scan(s.From.Name)
+		if sorted && path.pkRange() {
+			orderKeys = nil
+		}
+		it, err = e.scanPath(s.From.Name, def, s.Where, path, tr)
 	}
 	if err != nil {
 		return nil, WrapError(err)
@@ -624,50 +899,23 @@ func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error)
 	if len(orderKeys) > 0 {
 		// ORDER BY path: collect all matching rows, sort, then apply LIMIT/OFFSET.
 		var matched []storage.Row
-		for {
-			row, ok := it.Next()
-			if !ok {
-				break
-			}
-			scanned++
-			if filter != nil && !filter(row) {
-				continue
+		for batch := e.nextBatch(it); len(batch) > 0; batch = e.nextBatch(it) {
+			for _, row := range batch {
+				scanned++
+				if filter != nil && !filter(row) {
+					continue
+				}
+				matched = append(matched, row)
 			}
-			matched = append(matched, row)
 		}
+		appendSortKeys(matched, ordinalEnd(def), exprKeys)
 
 		// Sort using stable sort to preserve insertion order for equal keys.
 		var sortStart time.Time
 		if tr != nil {
 			sortStart = time.Now()
 		}
-		sort.SliceStable(matched, func(i, j int) bool {
-			for _, key := range orderKeys {
-				av := storage.RowValue(matched[i].Values, key.colIdx)
-				bv := storage.RowValue(matched[j].Values, key.colIdx)
-
-				// NULLs always sort last regardless of direction.
-				if av == nil && bv == nil {
-					continue
-				}
-				if av == nil {
-					return false // NULL sorts last
-				}
-				if bv == nil {
-					return true // NULL sorts last
-				}
-
-				cmp := storage.CompareValues(av, bv)
-				if cmp == 0 {
-					continue
-				}
-				if key.desc {
-					return cmp > 0
-				}
-				return cmp < 0
-			}
-			return false
-		})
+		sortRows(matched, orderKeys, e.intr.stopped)
 		if tr != nil {
 			tr.Sort = time.Since(sortStart)
 		}
@@ -688,42 +936,42 @@ func (e *Executor) execSelect(s *parser.SelectStmt, tr *Trace) (*Result, error)
 		}
 
 		for _, row := range matched[start:end] {
-			textRow := make([][]byte, len(colEvals))
-			for i, eval := range colEvals {
-				textRow[i] = formatValue(eval(row))
+			textRow, err := formatRow(row, colEvals)
+			if err != nil {
+				return nil, err
 			}
 			resultRows = append(resultRows, textRow)
 		}
 	} else {
 		// No ORDER BY: streaming path with early LIMIT termination.
 		var matched int64
-		for {
-			row, ok := it.Next()
-			if !ok {
-				break
-			}
-			scanned++
-			if filter != nil && !filter(row) {
-				continue
-			}
-			matched++
-			if matched <= offset {
-				continue
-			}
-			if limit == 0 {
-				break
-			}
-			textRow := make([][]byte, len(colEvals))
-			for i, eval := range colEvals {
-				textRow[i] = formatValue(eval(row))
-			}
-			resultRows = append(resultRows, textRow)
-			if limit > 0 && int64(len(resultRows)) >= limit {
-				break
+	scan:
+		for batch := e.nextBatch(it); len(batch) > 0; batch = e.nextBatch(it) {
+			for _, row := range batch {
+				scanned++
+				if filter != nil && !filter(row) {
+					continue
+				}
+				matched++
+				if matched <= offset {
+					continue
+				}
+				if limit == 0 {
+					break scan
+				}
+				textRow, err := formatRow(row, colEvals)
+				if err != nil {
+					return nil, err
+				}
+				resultRows = append(resultRows, textRow)
+				if limit > 0 && int64(len(resultRows)) >= limit {
+					break scan
+				}
 			}
 		}
 	}
 
+	scanned = rowsScanned(it, scanned)
 	if tr != nil {
 		tr.RowsScanned = scanned
 		tr.RowsReturned = int64(len(resultRows))
@@ -744,12 +992,13 @@ func (e *Executor) execSelectAggregate(s *parser.SelectStmt, def *storage.TableD
 	}
 
 	type aggAcc struct {
-		funcName  string
-		colIdx    int // -1 for COUNT(*)
-		inputType storage.DataType
-		count     int64
-		sumI      int64
-		sumF      float64
+		funcName     string
+		colIdx       int // -1 for COUNT(*)
+		inputType    storage.DataType
+		count        int64
+		sumI         int64
+		sumF         float64
+		sumN         storage.Numeric
 		minV         any
 		maxV         any
 		hasV         bool
@@ -789,15 +1038,15 @@ func (e *Executor) execSelectAggregate(s *parser.SelectStmt, def *storage.TableD
 			if acc.colIdx < 0 {
 				return nil, &QueryError{Code: "42883", Message: "SUM requires a column argument"}
 			}
-			if acc.inputType != storage.TypeInteger && acc.inputType != storage.TypeFloat {
-				return nil, &QueryError{Code: "42883", Message: fmt.Sprintf("SUM: column must be INTEGER or FLOAT, got %s", acc.inputType)}
+			if !isSummable(acc.inputType) {
+				return nil, &QueryError{Code: "42883", Message: fmt.Sprintf("SUM: column must be INTEGER, FLOAT or NUMERIC, got %s", acc.inputType)}
 			}
 		case "AVG":
 			if acc.colIdx < 0 {
 				return nil, &QueryError{Code: "42883", Message: "AVG requires a column argument"}
 			}
-			if acc.inputType != storage.TypeInteger && acc.inputType != storage.TypeFloat {
-				return nil, &QueryError{Code: "42883", Message: fmt.Sprintf("AVG: column must be INTEGER or FLOAT, got %s", acc.inputType)}
+			if !isSummable(acc.inputType) {
+				return nil, &QueryError{Code: "42883", Message: fmt.Sprintf("AVG: column must be INTEGER, FLOAT or NUMERIC, got %s", acc.inputType)}
 			}
 		case "MIN", "MAX":
 			if acc.colIdx < 0 {
@@ -844,22 +1093,15 @@ func (e *Executor) execSelectAggregate(s *parser.SelectStmt, def *storage.TableD
 	isCatalog := isCatalogTable(s.From.Schema, s.From.Name)
 	var indexRows []storage.Row
 	var usedIndex string
-
-	if !isCatalog && s.Where != nil {
-		// Try PK index lookup.
-		if row, ok := e.tryPKLookup(s.Where, def); ok {
-			indexRows = []storage.Row{*row}
-			usedIndex = "PRIMARY"
-		}
-		// Try explicit INDEXED BY.
-		if usedIndex == "" && s.IndexedBy != "" {
-			rows, ierr := e.lookupByNamedIndex(s.IndexedBy, s.Where, def)
-			if ierr != nil {
-				return nil, ierr
-			}
-			indexRows = rows
-			usedIndex = s.IndexedBy
+	path, err := e.chooseAccess(s.From.Name, def, isCatalog, s.IndexedBy, s.Where, false)
+	if err != nil {
+		return nil, err
+	}
+	if path.readsIndex() {
+		if indexRows, err = e.readIndex(def, path); err != nil {
+			return nil, err
 		}
+		usedIndex = path.index
 	}
 
 	// accumulate applies one row to all aggregate accumulators.
@@ -877,6 +1119,8 @@ func (e *Executor) execSelectAggregate(s *parser.SelectStmt, def *storage.TableD
 					acc.sumI += v
 				case float64:
 					acc.sumF += v
+				case storage.Numeric:
+					acc.sumN = acc.sumN.Add(v)
 				}
 			case "MIN":
 				v := storage.RowValue(row.Values, acc.colIdx)
@@ -903,6 +1147,9 @@ func (e *Executor) execSelectAggregate(s *parser.SelectStmt, def *storage.TableD
 				case float64:
 					acc.sumF += v
 					acc.countNonNull++
+				case storage.Numeric:
+					acc.sumN = acc.sumN.Add(v)
+					acc.countNonNull++
 				}
 			}
 		}
@@ -911,9 +1158,7 @@ func (e *Executor) execSelectAggregate(s *parser.SelectStmt, def *storage.TableD
 	// Scan rows and accumulate.
 	var scanned int64
 	if usedIndex != "" {
-		if tr != nil {
-			tr.IndexName = usedIndex
-		}
+		tr.setAccess(usedIndex)
 		for _, row := range indexRows {
 			scanned++
 			if filter != nil && !filter(row) {
@@ -925,53 +1170,61 @@ func (e *Executor) execSelectAggregate(s *parser.SelectStmt, def *storage.TableD
 		var it storage.RowIterator
 		var err error
 		if isCatalog {
+			tr.setAccess("")
 			it, err = scanCatalogTable(s.From.Schema, s.From.Name, e.engine)
 		} else {
-			it, err = e.engine.Scan(s.From.Name)
+			it, err = e.scanPath(s.From.Name, def, s.Where, path, tr)
 		}
 		if err != nil {
 			return nil, WrapError(err)
 		}
 		defer it.Close()
-		for {
-			row, ok := it.Next()
-			if !ok {
-				break
-			}
-			scanned++
-			if filter != nil && !filter(row) {
-				continue
+		for batch := e.nextBatch(it); len(batch) > 0; batch = e.nextBatch(it) {
+			for _, row := range batch {
+				scanned++
+				if filter != nil && !filter(row) {
+					continue
+				}
+				accumulate(row)
 			}
-			accumulate(row)
 		}
+		scanned = rowsScanned(it, scanned)
 	}
 
 	// Build the single result row.
-	resultRow := make([][]byte, len(accs))
+	vals := make([]any, len(accs))
 	for i, acc := range accs {
 		switch acc.funcName {
 		case "COUNT":
-			resultRow[i] = formatValue(acc.count)
+			vals[i] = acc.count
 		case "SUM":
 			if acc.inputType == storage.TypeFloat {
-				resultRow[i] = formatValue(acc.sumF)
+				vals[i] = acc.sumF
+			} else if acc.inputType == storage.TypeNumeric {
+				vals[i] = acc.sumN
 			} else {
-				resultRow[i] = formatValue(acc.sumI)
+				vals[i] = acc.sumI
 			}
 		case "MIN":
-			resultRow[i] = formatValue(acc.minV)
+			vals[i] = acc.minV
 		case "MAX":
-			resultRow[i] = formatValue(acc.maxV)
+			vals[i] = acc.maxV
 		case "AVG":
 			if acc.countNonNull == 0 {
-				resultRow[i] = nil
+				vals[i] = nil
 			} else if acc.inputType == storage.TypeFloat {
-				resultRow[i] = formatValue(acc.sumF / float64(acc.countNonNull))
+				vals[i] = acc.sumF / float64(acc.countNonNull)
+			} else if acc.inputType == storage.TypeNumeric {
+				vals[i] = acc.sumN.Div(storage.NumericFromInt(acc.countNonNull))
 			} else {
-				resultRow[i] = formatValue(float64(acc.sumI) / float64(acc.countNonNull))
+				vals[i] = float64(acc.sumI) / float64(acc.countNonNull)
 			}
 		}
 	}
+	resultRow, err := formatValues(vals)
+	if err != nil {
+		return nil, err
+	}
 
 	// Apply LIMIT/OFFSET to the single aggregate result row.
 	rows := [][][]byte{resultRow}
@@ -995,51 +1248,86 @@ func (e *Executor) execSelectAggregate(s *parser.SelectStmt, def *storage.TableD
 	}, nil
 }
 
-// execSelectGroupBy handles SELECT ... GROUP BY ...
+// grouped reports whether s has a GROUP BY clause. GROUP BY () has no
+// items but still groups, into one group of all rows.
+func grouped(s *parser.SelectStmt) bool {
+	return len(s.GroupBy) > 0 || s.GroupingSets != nil
+}
+
+// execSelectGroupBy handles SELECT ... GROUP BY ..., running the
+// accumulation once per grouping set of a ROLLUP, CUBE or GROUPING SETS.
 func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef, hasAgg bool, tr *Trace) (*Result, error) {
 	var planStart time.Time
 	if tr != nil {
 		planStart = time.Now()
 	}
 
-	// Validate LIMIT/OFFSET values.
-	if s.Limit != nil && *s.Limit < 0 {
-		return nil, &QueryError{Code: "2201W", Message: "LIMIT must not be negative"}
-	}
-	if s.Offset != nil && *s.Offset < 0 {
-		return nil, &QueryError{Code: "2201X", Message: "OFFSET must not be negative"}
+	if err := resolveLimitOffset(s); err != nil {
+		return nil, err
 	}
 
-	// Resolve GROUP BY columns to ordinals.
+	// Resolve GROUP BY items. Each is a column reference, a 1-based
+	// position in the select list, or an expression such as
+	// DATE_TRUNC('month', ts); all of them are evaluated per row.
 	type groupCol struct {
-		name    string
-		ordinal int
+		name string      // column name, or the output name of an expression
+		expr parser.Expr // nil for column references
+		eval exprFunc
+		col  Column
 	}
 	var groupCols []groupCol
 	for _, expr := range s.GroupBy {
-		ref, ok := expr.(*parser.ColumnRef)
-		if !ok {
-			return nil, &QueryError{Code: "42803", Message: "GROUP BY expressions must be column references"}
+		if pos, ok := expr.(*parser.IntegerLit); ok {
+			if pos.Value < 1 || pos.Value > int64(len(s.Columns)) {
+				return nil, &QueryError{Code: "42P10", Message: fmt.Sprintf("GROUP BY position %d is not in select list", pos.Value)}
+			}
+			expr = s.Columns[pos.Value-1]
+			if a, ok := expr.(*parser.AliasExpr); ok {
+				expr = a.Expr
+			}
 		}
-		idx := columnIndex(def, ref.Name)
-		if idx < 0 {
-			return nil, WrapError(fmt.Errorf("column %q not found in table %q", ref.Name, def.Name))
+		switch g := expr.(type) {
+		case *parser.ColumnRef:
+			idx := columnIndex(def, g.Name)
+			if idx < 0 {
+				return nil, WrapError(fmt.Errorf("column %q not found in table %q", g.Name, def.Name))
+			}
+			c := columnByOrdinal(def, idx)
+			groupCols = append(groupCols, groupCol{
+				name: g.Name,
+				eval: func(r storage.Row) any { return storage.RowValue(r.Values, idx) },
+				col:  Column{Name: c.Name, TypeOID: typeOID(c.DataType), TypeSize: typeSize(c.DataType)},
+			})
+		case *parser.StarExpr:
+			return nil, &QueryError{Code: "42803", Message: "SELECT * is not allowed with GROUP BY"}
+		default:
+			if fn, ok := expr.(*parser.FunctionCallExpr); ok && isAggFunc(fn.Name) {
+				return nil, &QueryError{Code: "42803", Message: "aggregate functions are not allowed in GROUP BY"}
+			}
+			evals, cols, err := e.resolveSelectColumns([]parser.Expr{expr}, def, s.FromAlias)
+			if err != nil {
+				return nil, WrapError(err)
+			}
+			groupCols = append(groupCols, groupCol{name: cols[0].Name, expr: expr, eval: evals[0], col: cols[0]})
 		}
-		groupCols = append(groupCols, groupCol{name: ref.Name, ordinal: idx})
+	}
+
+	// A plain GROUP BY has the one grouping set of all its items.
+	sets := s.GroupingSets
+	if sets == nil {
+		all := make([]int, len(groupCols))
+		for i := range all {
+			all[i] = i
+		}
+		sets = [][]int{all}
 	}
 
 	// Build a set of GROUP BY column names for validation.
 	groupByNames := make(map[string]bool)
 	for _, gc := range groupCols {
-		groupByNames[strings.ToLower(gc.name)] = true
-	}
-
-	isAggFunc := func(name string) bool {
-		switch name {
-		case "COUNT", "SUM", "MIN", "MAX", "AVG":
-			return true
+		if gc.expr == nil {
+			groupByNames[strings.ToLower(gc.name)] = true
 		}
-		return false
 	}
 
 	// aggAcc is a per-group aggregate accumulator.
@@ -1050,6 +1338,7 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 		count        int64
 		sumI         int64
 		sumF         float64
+		sumN         storage.Numeric
 		minV         any
 		maxV         any
 		hasV         bool
@@ -1059,7 +1348,8 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 	// Describe each SELECT column: is it a group-by ref or an aggregate?
 	type selectCol struct {
 		isAgg    bool
-		groupIdx int // index into groupCols (when !isAgg)
+		groupIdx int   // index into groupCols (when !isAgg)
+		grouping []int // GROUPING() arguments as indexes into groupCols
 		aggTmpl  aggAcc
 		alias    string
 	}
@@ -1075,7 +1365,32 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 			inner = a.Expr
 		}
 
-		if fn, ok := inner.(*parser.FunctionCallExpr); ok && isAggFunc(fn.Name) {
+		if fn, ok := inner.(*parser.FunctionCallExpr); ok && fn.Name == "GROUPING" {
+			sc := selectCol{isAgg: false, groupIdx: -1, alias: alias}
+			for _, arg := range fn.Args {
+				gIdx := -1
+				for i, gc := range groupCols {
+					if ref, ok := arg.(*parser.ColumnRef); ok && gc.expr == nil && strings.EqualFold(gc.name, ref.Name) ||
+						gc.expr != nil && reflect.DeepEqual(gc.expr, arg) {
+						gIdx = i
+						break
+					}
+				}
+				if gIdx < 0 {
+					return nil, &QueryError{Code: "42803", Message: "arguments to GROUPING must be grouping expressions of the associated query level"}
+				}
+				sc.grouping = append(sc.grouping, gIdx)
+			}
+			if len(sc.grouping) == 0 || len(sc.grouping) > 31 {
+				return nil, &QueryError{Code: "42883", Message: "GROUPING takes 1 to 31 arguments"}
+			}
+			colName := "grouping"
+			if alias != "" {
+				colName = alias
+			}
+			selectCols = append(selectCols, sc)
+			resultCols = append(resultCols, Column{Name: colName, TypeOID: OIDInt8, TypeSize: 8})
+		} else if fn, ok := inner.(*parser.FunctionCallExpr); ok && isAggFunc(fn.Name) {
 			tmpl := aggAcc{funcName: fn.Name, colIdx: -1}
 			if len(fn.Args) == 1 {
 				switch arg := fn.Args[0].(type) {
@@ -1096,15 +1411,15 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 				if tmpl.colIdx < 0 {
 					return nil, &QueryError{Code: "42883", Message: "SUM requires a column argument"}
 				}
-				if tmpl.inputType != storage.TypeInteger && tmpl.inputType != storage.TypeFloat {
-					return nil, &QueryError{Code: "42883", Message: fmt.Sprintf("SUM: column must be INTEGER or FLOAT, got %s", tmpl.inputType)}
+				if !isSummable(tmpl.inputType) {
+					return nil, &QueryError{Code: "42883", Message: fmt.Sprintf("SUM: column must be INTEGER, FLOAT or NUMERIC, got %s", tmpl.inputType)}
 				}
 			case "AVG":
 				if tmpl.colIdx < 0 {
 					return nil, &QueryError{Code: "42883", Message: "AVG requires a column argument"}
 				}
-				if tmpl.inputType != storage.TypeInteger && tmpl.inputType != storage.TypeFloat {
-					return nil, &QueryError{Code: "42883", Message: fmt.Sprintf("AVG: column must be INTEGER or FLOAT, got %s", tmpl.inputType)}
+				if !isSummable(tmpl.inputType) {
+					return nil, &QueryError{Code: "42883", Message: fmt.Sprintf("AVG: column must be INTEGER, FLOAT or NUMERIC, got %s", tmpl.inputType)}
 				}
 			case "MIN", "MAX":
 				if tmpl.colIdx < 0 {
@@ -1132,48 +1447,70 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 			}
 			gIdx := -1
 			for i, gc := range groupCols {
-				if strings.EqualFold(gc.name, ref.Name) {
+				if gc.expr == nil && strings.EqualFold(gc.name, ref.Name) {
 					gIdx = i
 					break
 				}
 			}
-			c := columnByOrdinal(def, groupCols[gIdx].ordinal)
-			colName := c.Name
+			col := groupCols[gIdx].col
 			if alias != "" {
-				colName = alias
+				col.Name = alias
 			}
 			selectCols = append(selectCols, selectCol{isAgg: false, groupIdx: gIdx, alias: alias})
-			resultCols = append(resultCols, Column{
-				Name:     colName,
-				TypeOID:  typeOID(c.DataType),
-				TypeSize: typeSize(c.DataType),
-			})
+			resultCols = append(resultCols, col)
 		} else if _, ok := inner.(*parser.StarExpr); ok {
 			return nil, &QueryError{
 				Code:    "42803",
 				Message: "SELECT * is not allowed with GROUP BY",
 			}
 		} else {
-			return nil, &QueryError{
-				Code:    "42803",
-				Message: "non-aggregate expressions in SELECT must be column references that appear in GROUP BY",
+			gIdx := -1
+			for i, gc := range groupCols {
+				if gc.expr != nil && reflect.DeepEqual(gc.expr, inner) {
+					gIdx = i
+					break
+				}
 			}
+			if gIdx < 0 {
+				return nil, &QueryError{
+					Code:    "42803",
+					Message: "non-aggregate expressions in SELECT must appear in GROUP BY",
+				}
+			}
+			col := groupCols[gIdx].col
+			if alias != "" {
+				col.Name = alias
+			}
+			selectCols = append(selectCols, selectCol{isAgg: false, groupIdx: gIdx, alias: alias})
+			resultCols = append(resultCols, col)
 		}
 	}
 
 	// Validate ORDER BY columns.
 	type orderKey struct {
-		groupIdx int // index into groupCols, or -1 for result column alias
-		colIdx   int // result column index (for alias-based ordering)
-		desc     bool
+		groupIdx   int // index into groupCols, or -1 for result column alias
+		colIdx     int // result column index (for alias-based ordering)
+		desc       bool
+		nullsFirst bool
 	}
 	var orderKeys []orderKey
 	for _, ob := range s.OrderBy {
+		if ob.Position > 0 {
+			i, err := orderPosition(ob, len(selectCols))
+			if err != nil {
+				return nil, err
+			}
+			orderKeys = append(orderKeys, orderKey{groupIdx: -1, colIdx: i, desc: ob.Desc, nullsFirst: nullsFirst(ob)})
+			continue
+		}
+		if ob.Expr != nil {
+			return nil, &QueryError{Code: "0A000", Message: "ORDER BY expressions are not supported with GROUP BY"}
+		}
 		// Check if it matches a GROUP BY column by name.
 		found := false
 		for i, gc := range groupCols {
 			if strings.EqualFold(gc.name, ob.Column) {
-				orderKeys = append(orderKeys, orderKey{groupIdx: i, colIdx: -1, desc: ob.Desc})
+				orderKeys = append(orderKeys, orderKey{groupIdx: i, colIdx: -1, desc: ob.Desc, nullsFirst: nullsFirst(ob)})
 				found = true
 				break
 			}
@@ -1182,7 +1519,7 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 			// Check if it matches a result column alias.
 			for i, sc := range selectCols {
 				if strings.EqualFold(sc.alias, ob.Column) {
-					orderKeys = append(orderKeys, orderKey{groupIdx: -1, colIdx: i, desc: ob.Desc})
+					orderKeys = append(orderKeys, orderKey{groupIdx: -1, colIdx: i, desc: ob.Desc, nullsFirst: nullsFirst(ob)})
 					found = true
 					break
 				}
@@ -1215,31 +1552,37 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 		execStart = time.Now()
 	}
 
-	// Group map: string key → group state.
+	// Group map: string key → group state. Each grouping set has groups
+	// of its own; the columns a set leaves out are NULL in its groups.
 	type group struct {
+		set     int      // index into sets
 		keyVals []any    // one value per groupCol
 		accs    []aggAcc // one per aggregate selectCol
 	}
 	groups := make(map[string]*group)
-	var groupOrder []string // insertion order for deterministic output
+	groupOrder := make([][]string, len(sets)) // insertion order per set for deterministic output
 
 	const nullSentinel = "\x00NULL"
 	const sep = "\x1f"
 
-	buildKey := func(row storage.Row) string {
-		if len(groupCols) == 1 {
-			v := storage.RowValue(row.Values, groupCols[0].ordinal)
+	buildKey := func(row storage.Row, set int) string {
+		cols := sets[set]
+		if len(sets) == 1 && len(cols) == 1 {
+			v := groupCols[cols[0]].eval(row)
 			if v == nil {
 				return nullSentinel
 			}
 			return fmt.Sprintf("%v", v)
 		}
 		var b strings.Builder
-		for i, gc := range groupCols {
-			if i > 0 {
+		if len(sets) > 1 {
+			fmt.Fprintf(&b, "%d", set)
+		}
+		for i, gi := range cols {
+			if i > 0 || len(sets) > 1 {
 				b.WriteString(sep)
 			}
-			v := storage.RowValue(row.Values, gc.ordinal)
+			v := groupCols[gi].eval(row)
 			if v == nil {
 				b.WriteString(nullSentinel)
 			} else {
@@ -1249,12 +1592,13 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 		return b.String()
 	}
 
-	newGroup := func(row storage.Row) *group {
+	newGroup := func(row storage.Row, set int) *group {
 		g := &group{
+			set:     set,
 			keyVals: make([]any, len(groupCols)),
 		}
-		for i, gc := range groupCols {
-			g.keyVals[i] = storage.RowValue(row.Values, gc.ordinal)
+		for _, gi := range sets[set] {
+			g.keyVals[gi] = groupCols[gi].eval(row)
 		}
 		// Create accumulators for aggregate columns.
 		for _, sc := range selectCols {
@@ -1280,6 +1624,8 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 					acc.sumI += v
 				case float64:
 					acc.sumF += v
+				case storage.Numeric:
+					acc.sumN = acc.sumN.Add(v)
 				}
 			case "MIN":
 				v := storage.RowValue(row.Values, acc.colIdx)
@@ -1306,48 +1652,47 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 				case float64:
 					acc.sumF += v
 					acc.countNonNull++
+				case storage.Numeric:
+					acc.sumN = acc.sumN.Add(v)
+					acc.countNonNull++
 				}
 			}
 		}
 	}
 
 	addRow := func(row storage.Row) {
-		key := buildKey(row)
-		g, exists := groups[key]
-		if !exists {
-			g = newGroup(row)
-			groups[key] = g
-			groupOrder = append(groupOrder, key)
+		for set := range sets {
+			key := buildKey(row, set)
+			g, exists := groups[key]
+			if !exists {
+				g = newGroup(row, set)
+				groups[key] = g
+				groupOrder[set] = append(groupOrder[set], key)
+			}
+			accumulate(g, row)
 		}
-		accumulate(g, row)
 	}
 
 	// Try index lookups.
 	isCatalog := isCatalogTable(s.From.Schema, s.From.Name)
 	var scanned int64
 	var usedIndex string
-
-	if !isCatalog && s.Where != nil {
-		if row, ok := e.tryPKLookup(s.Where, def); ok {
-			usedIndex = "PRIMARY"
-			scanned = 1
-			if filter == nil || filter(*row) {
-				addRow(*row)
-			}
+	path, perr := e.chooseAccess(s.From.Name, def, isCatalog, s.IndexedBy, s.Where, false)
+	if perr != nil {
+		return nil, perr
+	}
+	if path.readsIndex() {
+		rows, ierr := e.readIndex(def, path)
+		if ierr != nil {
+			return nil, ierr
 		}
-		if usedIndex == "" && s.IndexedBy != "" {
-			rows, ierr := e.lookupByNamedIndex(s.IndexedBy, s.Where, def)
-			if ierr != nil {
-				return nil, ierr
-			}
-			usedIndex = s.IndexedBy
-			for _, row := range rows {
-				scanned++
-				if filter != nil && !filter(row) {
-					continue
-				}
-				addRow(row)
+		usedIndex = path.index
+		for _, row := range rows {
+			scanned++
+			if filter != nil && !filter(row) {
+				continue
 			}
+			addRow(row)
 		}
 	}
 
@@ -1355,42 +1700,63 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 		var it storage.RowIterator
 		var err error
 		if isCatalog {
+			tr.setAccess("")
 			it, err = scanCatalogTable(s.From.Schema, s.From.Name, e.engine)
 		} else {
-			it, err = e.engine.Scan(s.From.Name)
+			it, err = e.scanPath(s.From.Name, def, s.Where, path, tr)
 		}
 		if err != nil {
 			return nil, WrapError(err)
 		}
 		defer it.Close()
-		for {
-			row, ok := it.Next()
-			if !ok {
-				break
-			}
-			scanned++
-			if filter != nil && !filter(row) {
-				continue
+		for batch := e.nextBatch(it); len(batch) > 0; batch = e.nextBatch(it) {
+			for _, row := range batch {
+				scanned++
+				if filter != nil && !filter(row) {
+					continue
+				}
+				addRow(row)
 			}
-			addRow(row)
 		}
+		scanned = rowsScanned(it, scanned)
+	}
+
+	if usedIndex != "" {
+		tr.setAccess(usedIndex)
 	}
 
-	if tr != nil && usedIndex != "" {
-		tr.IndexName = usedIndex
+	// The empty grouping set has its one group, the grand total, even
+	// when no row was read.
+	for set, cols := range sets {
+		if len(cols) == 0 && len(groupOrder[set]) == 0 {
+			key := buildKey(storage.Row{}, set)
+			groups[key] = newGroup(storage.Row{}, set)
+			groupOrder[set] = append(groupOrder[set], key)
+		}
 	}
 
 	// Build result entries from groups.
 	type resultEntry struct {
 		vals []any // one per selectCol
 	}
-	entries := make([]resultEntry, 0, len(groupOrder))
-	for _, key := range groupOrder {
+	entries := make([]resultEntry, 0, len(groups))
+	for _, key := range slices.Concat(groupOrder...) {
 		g := groups[key]
 		row := make([]any, len(selectCols))
 		aggIdx := 0
 		for i, sc := range selectCols {
-			if sc.isAgg {
+			if sc.grouping != nil {
+				// Bit n, counting from the last argument, is set when the
+				// group's set leaves that argument out.
+				var bits int64
+				for _, gi := range sc.grouping {
+					bits <<= 1
+					if !slices.Contains(sets[g.set], gi) {
+						bits |= 1
+					}
+				}
+				row[i] = bits
+			} else if sc.isAgg {
 				acc := &g.accs[aggIdx]
 				aggIdx++
 				switch acc.funcName {
@@ -1399,6 +1765,8 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 				case "SUM":
 					if acc.inputType == storage.TypeFloat {
 						row[i] = acc.sumF
+					} else if acc.inputType == storage.TypeNumeric {
+						row[i] = acc.sumN
 					} else {
 						row[i] = acc.sumI
 					}
@@ -1411,6 +1779,8 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 						row[i] = nil
 					} else if acc.inputType == storage.TypeFloat {
 						row[i] = acc.sumF / float64(acc.countNonNull)
+					} else if acc.inputType == storage.TypeNumeric {
+						row[i] = acc.sumN.Div(storage.NumericFromInt(acc.countNonNull))
 					} else {
 						row[i] = float64(acc.sumI) / float64(acc.countNonNull)
 					}
@@ -1440,16 +1810,12 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 					vj = entries[j].vals[ok.colIdx]
 				}
 
-				// NULLs always sort last.
-				if vi == nil && vj == nil {
+				if vi == nil || vj == nil {
+					if c := compareNulls(vi, vj, ok.nullsFirst); c != 0 {
+						return c < 0
+					}
 					continue
 				}
-				if vi == nil {
-					return false
-				}
-				if vj == nil {
-					return true
-				}
 
 				cmp := storage.CompareValues(vi, vj)
 				if cmp == 0 {
@@ -1481,9 +1847,9 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 	// Format result rows.
 	resultRows := make([][][]byte, 0, len(entries))
 	for _, entry := range entries {
-		textRow := make([][]byte, len(entry.vals))
-		for i, v := range entry.vals {
-			textRow[i] = formatValue(v)
+		textRow, err := formatValues(entry.vals)
+		if err != nil {
+			return nil, err
 		}
 		resultRows = append(resultRows, textRow)
 	}
@@ -1502,7 +1868,7 @@ func (e *Executor) execSelectGroupBy(s *parser.SelectStmt, def *storage.TableDef
 }
 
 // execSelectStatic handles SELECT with no FROM clause (e.g. SELECT 1, VERSION()).
-func execSelectStatic(exprs []parser.Expr) (*Result, error) {
+func (e *Executor) execSelectStatic(exprs []parser.Expr) (*Result, error) {
 	var cols []Column
 	var row [][]byte
 
@@ -1518,11 +1884,20 @@ func execSelectStatic(exprs []parser.Expr) (*Result, error) {
 		if err != nil {
 			return nil, err
 		}
+		if sq, ok := inner.(*parser.SubqueryExpr); ok {
+			if col, err = e.subqueryColumn(sq); err != nil {
+				return nil, err
+			}
+		}
 		if alias != "" {
 			col.Name = alias
 		}
 		cols = append(cols, col)
-		row = append(row, formatValue(val))
+		b, err := formatValue(val)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, b)
 	}
 
 	return &Result{
@@ -1532,6 +1907,66 @@ func execSelectStatic(exprs []parser.Expr) (*Result, error) {
 	}, nil
 }
 
+// execValues evaluates a standalone VALUES list. Columns are named
+// column1, column2, ... and, as in PostgreSQL, each is typed from all of
+// its rows the way a UNION column is: INTEGER, NUMERIC and FLOAT widen to
+// the widest of them, NULLs and string literals take the type of the other
+// values, and a column of nothing else is TEXT. Values are then converted
+// to their column's type.
+func execValues(s *parser.ValuesStmt) (*Result, error) {
+	oids := make([]int32, len(s.Rows[0]))
+	for i := range oids {
+		oids[i] = OIDUnknown
+	}
+	vals := make([][]any, len(s.Rows))
+	for r, exprs := range s.Rows {
+		vals[r] = make([]any, len(exprs))
+		for i, expr := range exprs {
+			val, err := evalLiteral(expr)
+			if err != nil {
+				return nil, WrapError(err)
+			}
+			vals[r][i] = val
+			if _, lit := expr.(*parser.StringLit); val == nil || lit {
+				continue
+			}
+			oid, _ := valueTypeOID(val)
+			if oids[i], err = setOpType("VALUES", oids[i], oid); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	cols := make([]Column, len(oids))
+	for i, oid := range oids {
+		if oid == OIDUnknown {
+			oid = OIDText
+		}
+		cols[i] = Column{Name: fmt.Sprintf("column%d", i+1), TypeOID: oid, TypeSize: typeSize(oidType(oid))}
+	}
+	rows := make([][][]byte, len(vals))
+	for r, rowVals := range vals {
+		for i, val := range rowVals {
+			if oid, _ := valueTypeOID(val); val != nil && oid != cols[i].TypeOID {
+				v, err := coerceLiteral(val, oidType(cols[i].TypeOID))
+				if err != nil {
+					return nil, err
+				}
+				rowVals[i] = v
+			}
+		}
+		row, err := formatValues(rowVals)
+		if err != nil {
+			return nil, err
+		}
+		rows[r] = row
+	}
+	return &Result{
+		Columns: cols,
+		Rows:    rows,
+		Tag:     fmt.Sprintf("SELECT %d", len(rows)),
+	}, nil
+}
 
 // -------------------------------------------------------------------------
 // JOIN execution
@@ -1539,18 +1974,18 @@ func execSelectStatic(exprs []parser.Expr) (*Result, error) {
 
 // scopeTable represents one table in a join scope.
 type scopeTable struct {
-	schema    string           // schema name ("information_schema", etc.), "" for user tables
-	name      string           // original table name
-	alias     string           // alias (or name if no alias)
+	schema    string // schema name ("information_schema", etc.), "" for user tables
+	name      string // original table name
+	alias     string // alias (or name if no alias)
 	def       *storage.TableDef
-	offset    int              // index into merged row where this table's columns start
-	isCatalog bool             // true for virtual catalog tables
+	offset    int  // index into merged row where this table's columns start
+	isCatalog bool // true for virtual catalog tables
 }
 
 // scopeColumn represents one column in the merged join row.
 type scopeColumn struct {
-	tableIdx int    // index into joinScope.tables
-	colIdx   int    // index into that table's Columns
+	tableIdx int // index into joinScope.tables
+	colIdx   int // index into that table's Columns
 	name     string
 	def      storage.ColumnDef
 }
@@ -1651,6 +2086,13 @@ func (e *Executor) buildJoinScope(s *parser.SelectStmt) (*joinScope, error) {
 		if jalias == "" {
 			jalias = j.Table.Name
 		}
+		// Each table is known by its alias, or else its name, and that
+		// must single it out for qualified column references.
+		for _, t := range scope.tables {
+			if strings.EqualFold(t.alias, jalias) {
+				return nil, &QueryError{Code: "42712", Message: fmt.Sprintf("table name %q specified more than once", jalias)}
+			}
+		}
 		tableIdx := ji + 1
 		scope.tables = append(scope.tables, scopeTable{
 			schema: j.Table.Schema, name: j.Table.Name, alias: jalias,
@@ -1693,6 +2135,22 @@ func compileJoinExpr(expr parser.Expr, scope *joinScope) (exprFunc, error) {
 		v := e.Value
 		return func(storage.Row) any { return v }, nil
 
+	case *parser.TimestampLit:
+		v := e.Value
+		return func(storage.Row) any { return v }, nil
+
+	case *parser.RandomExpr:
+		next := e.Next
+		return func(storage.Row) any { return next() }, nil
+
+	case *parser.OuterRef:
+		return func(storage.Row) any { return e.Value }, nil
+
+	case *parser.SubqueryExpr:
+		return compileScalarSubquery(e, func(ref *parser.ColumnRef) (exprFunc, error) {
+			return compileJoinExpr(ref, scope)
+		})
+
 	case *parser.NullLit:
 		return func(storage.Row) any { return nil }, nil
 
@@ -1724,6 +2182,8 @@ func compileJoinExpr(expr parser.Expr, scope *joinScope) (exprFunc, error) {
 				return -n
 			case float64:
 				return -n
+			case storage.Numeric:
+				return n.Neg()
 			default:
 				return nil
 			}
@@ -1748,6 +2208,11 @@ func compileJoinExpr(expr parser.Expr, scope *joinScope) (exprFunc, error) {
 		})
 
 	case *parser.InExpr:
+		if e.Subquery != nil {
+			return compileInSubquery(e, func(expr parser.Expr) (exprFunc, error) {
+				return compileJoinExpr(expr, scope)
+			})
+		}
 		return compileInExprCoerced(e, func(expr parser.Expr) (exprFunc, error) {
 			return compileJoinExpr(expr, scope)
 		}, func(lhs parser.Expr, values []parser.Expr, valFns []exprFunc) ([]exprFunc, error) {
@@ -1766,8 +2231,13 @@ func compileJoinExpr(expr parser.Expr, scope *joinScope) (exprFunc, error) {
 		if err != nil {
 			return nil, err
 		}
-		typeName := e.TypeName
-		return func(r storage.Row) any { return castValue(inner(r), typeName) }, nil
+		return func(r storage.Row) any {
+			v, err := castTypmod(castValue(inner(r), e.TypeName), e)
+			if err != nil {
+				return nil
+			}
+			return v
+		}, nil
 
 	case *parser.FunctionCallExpr:
 		fn, ok := scalarRegistry[e.Name]
@@ -1910,6 +2380,9 @@ func compileJoinBinaryExpr(e *parser.BinaryExpr, scope *joinScope) (exprFunc, er
 			if lv == nil || rv == nil {
 				return nil
 			}
+			if v, ok, _ := numericArith(op, lv, rv); ok {
+				return v
+			}
 			// Try integer arithmetic first.
 			li, lok := lv.(int64)
 			ri, rok := rv.(int64)
@@ -1980,7 +2453,7 @@ func buildJoinFilter(expr parser.Expr, scope *joinScope) (func(storage.Row) bool
 }
 
 // resolveJoinSelectColumns resolves SELECT column expressions against a join scope.
-func resolveJoinSelectColumns(exprs []parser.Expr, scope *joinScope) ([]exprFunc, []Column, error) {
+func (exec *Executor) resolveJoinSelectColumns(exprs []parser.Expr, scope *joinScope) ([]exprFunc, []Column, error) {
 	var evals []exprFunc
 	var cols []Column
 
@@ -2031,6 +2504,8 @@ func resolveJoinSelectColumns(exprs []parser.Expr, scope *joinScope) ([]exprFunc
 			}
 			if e.Op == "||" {
 				cols = append(cols, Column{Name: name, TypeOID: OIDText, TypeSize: -1})
+			} else if isNumericExpr(e, func(ref parser.Expr) (storage.DataType, bool) { return resolveJoinExprType(ref, scope) }) {
+				cols = append(cols, Column{Name: name, TypeOID: OIDNumeric, TypeSize: -1})
 			} else {
 				cols = append(cols, Column{Name: name, TypeOID: OIDInt8, TypeSize: 8})
 			}
@@ -2045,6 +2520,20 @@ func resolveJoinSelectColumns(exprs []parser.Expr, scope *joinScope) ([]exprFunc
 				name = alias
 			}
 			cols = append(cols, Column{Name: name, TypeOID: castTypeOID(e.TypeName), TypeSize: castTypeSize(e.TypeName)})
+		case *parser.SubqueryExpr:
+			compiled, err := compileJoinExpr(e, scope)
+			if err != nil {
+				return nil, nil, err
+			}
+			col, err := exec.subqueryColumn(e)
+			if err != nil {
+				return nil, nil, err
+			}
+			if alias != "" {
+				col.Name = alias
+			}
+			evals = append(evals, compiled)
+			cols = append(cols, col)
 		default:
 			compiled, err := compileJoinExpr(inner, scope)
 			if err != nil {
@@ -2072,12 +2561,8 @@ func (e *Executor) execSelectJoin(s *parser.SelectStmt, tr *Trace) (*Result, err
 		planStart = time.Now()
 	}
 
-	// Validate LIMIT/OFFSET values.
-	if s.Limit != nil && *s.Limit < 0 {
-		return nil, &QueryError{Code: "2201W", Message: "LIMIT must not be negative"}
-	}
-	if s.Offset != nil && *s.Offset < 0 {
-		return nil, &QueryError{Code: "2201X", Message: "OFFSET must not be negative"}
+	if err := resolveLimitOffset(s); err != nil {
+		return nil, err
 	}
 
 	// Build the join scope.
@@ -2109,23 +2594,36 @@ func (e *Executor) execSelectJoin(s *parser.SelectStmt, tr *Trace) (*Result, err
 	}
 
 	// Resolve SELECT columns.
-	colEvals, resultCols, err := resolveJoinSelectColumns(s.Columns, scope)
+	colEvals, resultCols, err := e.resolveJoinSelectColumns(s.Columns, scope)
 	if err != nil {
 		return nil, WrapError(err)
 	}
 
-	// Resolve ORDER BY columns against scope.
-	type orderKey struct {
-		colIdx int
-		desc   bool
-	}
-	var orderKeys []orderKey
+	// Resolve ORDER BY columns against scope. Expression keys and
+	// positions are sorted on as extra columns past the merged row's own.
+	var orderKeys []sortKey
+	var exprKeys []exprFunc
 	for _, ob := range s.OrderBy {
+		if ob.Position > 0 || ob.Expr != nil {
+			var eval exprFunc
+			if ob.Position > 0 {
+				i, err := orderPosition(ob, len(colEvals))
+				if err != nil {
+					return nil, err
+				}
+				eval = colEvals[i]
+			} else if eval, err = compileJoinExpr(ob.Expr, scope); err != nil {
+				return nil, WrapError(err)
+			}
+			orderKeys = append(orderKeys, valueSortKey(len(scope.columns)+len(exprKeys), ob.Desc, nullsFirst(ob)))
+			exprKeys = append(exprKeys, eval)
+			continue
+		}
 		idx, err := scope.resolveColumn(ob.Table, ob.Column)
 		if err != nil {
 			return nil, WrapError(err)
 		}
-		orderKeys = append(orderKeys, orderKey{colIdx: idx, desc: ob.Desc})
+		orderKeys = append(orderKeys, columnSortKey(idx, ob.Desc, nullsFirst(ob), scope.columns[idx].def.DataType))
 	}
 
 	if tr != nil {
@@ -2151,13 +2649,11 @@ func (e *Executor) execSelectJoin(s *parser.SelectStmt, tr *Trace) (*Result, err
 			return nil, WrapError(err)
 		}
 		var rows []storage.Row
-		for {
-			row, ok := it.Next()
-			if !ok {
-				break
+		for batch := e.nextBatch(it); len(batch) > 0; batch = e.nextBatch(it) {
+			for _, row := range batch {
+				rows = append(rows, row)
+				scanned++
 			}
-			rows = append(rows, row)
-			scanned++
 		}
 		it.Close()
 		tableRows[i] = rows
@@ -2199,6 +2695,9 @@ func (e *Executor) execSelectJoin(s *parser.SelectStmt, tr *Trace) (*Result, err
 		off := scope.tables[tableIdx].offset
 		tableCols := scope.tables[tableIdx].def.Columns
 		for _, row := range tableRows[tableIdx] {
+			if e.intr.stopped() {
+				return
+			}
 			// Place this table's values into the merged row.
 			for j, col := range tableCols {
 				current[off+j] = storage.RowValue(row.Values, col.Ordinal)
@@ -2220,30 +2719,8 @@ func (e *Executor) execSelectJoin(s *parser.SelectStmt, tr *Trace) (*Result, err
 		if tr != nil {
 			sortStart = time.Now()
 		}
-		sort.SliceStable(matched, func(i, j int) bool {
-			for _, key := range orderKeys {
-				av := storage.RowValue(matched[i].Values, key.colIdx)
-				bv := storage.RowValue(matched[j].Values, key.colIdx)
-				if av == nil && bv == nil {
-					continue
-				}
-				if av == nil {
-					return false
-				}
-				if bv == nil {
-					return true
-				}
-				cmp := storage.CompareValues(av, bv)
-				if cmp == 0 {
-					continue
-				}
-				if key.desc {
-					return cmp > 0
-				}
-				return cmp < 0
-			}
-			return false
-		})
+		appendSortKeys(matched, totalCols, exprKeys)
+		sortRows(matched, orderKeys, e.intr.stopped)
 		if tr != nil {
 			tr.Sort = time.Since(sortStart)
 		}
@@ -2267,9 +2744,9 @@ func (e *Executor) execSelectJoin(s *parser.SelectStmt, tr *Trace) (*Result, err
 	// Build result rows.
 	var resultRows [][][]byte
 	for _, row := range matched[start:end] {
-		textRow := make([][]byte, len(colEvals))
-		for i, eval := range colEvals {
-			textRow[i] = formatValue(eval(row))
+		textRow, err := formatRow(row, colEvals)
+		if err != nil {
+			return nil, err
 		}
 		resultRows = append(resultRows, textRow)
 	}
@@ -2291,153 +2768,423 @@ func (e *Executor) execUpdate(s *parser.UpdateStmt, tr *Trace) (*Result, error)
 	if isCatalogTable(s.Table.Schema, s.Table.Name) {
 		return nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot update catalog table %q", s.Table.String())}
 	}
+	if s.From != nil {
+		return e.execUpdateFrom(s, tr)
+	}
+
+	var planStart time.Time
+	if tr != nil {
+		planStart = time.Now()
+	}
+
+	def, ok := e.engine.GetTable(s.Table.Name)
+	if !ok {
+		return nil, WrapError(&storage.TableNotFoundError{Name: s.Table.String()})
+	}
+
+	// New values of referencing columns must find their parents, and a
+	// referenced key must not change while rows refer to it.
+	setCols := setColumns(s.Sets)
+	checkFK := hasReferences(def, setCols)
+	refs := e.referencesTo(def, setCols)
+	if (checkFK || len(refs) > 0) && !e.inTransaction() {
+		return e.atomically(func(x *Executor) (*Result, error) { return x.execUpdate(s, tr) })
+	}
+
+	ret, err := e.compileReturning(s.Returning, def)
+	if err != nil {
+		return nil, err
+	}
+
+	// Evaluate SET values.
+	sets := make(map[string]any, len(s.Sets))
+	for _, sc := range s.Sets {
+		v, err := evalSetValue(def, sc)
+		if err != nil {
+			return nil, WrapError(fmt.Errorf("SET %s: %w", sc.Column, err))
+		}
+		sets[sc.Column] = v
+	}
+
+	// Build WHERE filter.
+	var filter func(storage.Row) bool
+	if s.Where != nil {
+		filter, err = buildFilter(s.Where, def)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+	}
+
+	// If INDEXED BY is specified, wrap the filter to only consider rows from the index lookup.
+	path, err := modifyAccess(def, s.IndexedBy, s.Where)
+	if err != nil {
+		return nil, err
+	}
+	if path.readsIndex() {
+		rows, err := e.readIndex(def, path)
+		if err != nil {
+			return nil, err
+		}
+		if tr != nil {
+			tr.IndexName = s.IndexedBy
+		}
+		idSet := make(map[int64]struct{}, len(rows))
+		for _, r := range rows {
+			idSet[r.ID] = struct{}{}
+		}
+		baseFilter := filter
+		filter = func(r storage.Row) bool {
+			if _, ok := idSet[r.ID]; !ok {
+				return false
+			}
+			if baseFilter != nil {
+				return baseFilter(r)
+			}
+			return true
+		}
+	}
+
+	if tr != nil {
+		tr.Plan = time.Since(planStart)
+	}
+
+	var execStart time.Time
+	if tr != nil {
+		execStart = time.Now()
+	}
+
+	var n int64
+	var updated []storage.Row
+	if ret != nil || checkFK {
+		updated, err = e.engine.UpdateReturning(s.Table.Name, sets, filter)
+		n = int64(len(updated))
+	} else {
+		n, err = e.engine.Update(s.Table.Name, sets, filter)
+	}
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if checkFK {
+		if err := e.checkForeignKeys(def, updated); err != nil {
+			return nil, err
+		}
+	}
+	if len(refs) > 0 && n > 0 {
+		if err := e.checkReferencingRows(def, refs); err != nil {
+			return nil, err
+		}
+	}
+
+	if tr != nil {
+		tr.RowsReturned = int64(n)
+		tr.Exec = time.Since(execStart)
+	}
+
+	return ret.result(updated, fmt.Sprintf("UPDATE %d", n))
+}
+
+func (e *Executor) execDelete(s *parser.DeleteStmt, tr *Trace) (*Result, error) {
+	if isCatalogTable(s.Table.Schema, s.Table.Name) {
+		return nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot delete from catalog table %q", s.Table.String())}
+	}
+
+	var planStart time.Time
+	if tr != nil {
+		planStart = time.Now()
+	}
+
+	def, ok := e.engine.GetTable(s.Table.Name)
+	if !ok {
+		return nil, WrapError(&storage.TableNotFoundError{Name: s.Table.String()})
+	}
+
+	// Rows of other tables that reference the deleted rows are deleted
+	// or updated along with them, or keep the delete from happening.
+	refs := e.referencesTo(def, nil)
+	if len(refs) > 0 && !e.inTransaction() {
+		return e.atomically(func(x *Executor) (*Result, error) { return x.execDelete(s, tr) })
+	}
+
+	ret, err := e.compileReturning(s.Returning, def)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter func(storage.Row) bool
+	if s.Where != nil {
+		filter, err = buildFilter(s.Where, def)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+	}
+
+	// If INDEXED BY is specified, only the rows from the index lookup are
+	// candidates, and they are deleted by ID.
+	var ids []int64
+	path, err := modifyAccess(def, s.IndexedBy, s.Where)
+	if err != nil {
+		return nil, err
+	}
+	if path.readsIndex() {
+		rows, err := e.readIndex(def, path)
+		if err != nil {
+			return nil, err
+		}
+		if tr != nil {
+			tr.IndexName = s.IndexedBy
+		}
+		ids = make([]int64, len(rows))
+		for i, r := range rows {
+			ids[i] = r.ID
+		}
+	}
+
+	if tr != nil {
+		tr.Plan = time.Since(planStart)
+	}
+
+	var execStart time.Time
+	if tr != nil {
+		execStart = time.Now()
+	}
+
+	var n int64
+	var deleted []storage.Row
+	switch {
+	case s.IndexedBy != "":
+		deleted, err = e.engine.DeleteRows(s.Table.Name, ids, filter)
+		n = int64(len(deleted))
+	case ret != nil || len(refs) > 0:
+		deleted, err = e.engine.DeleteReturning(s.Table.Name, filter)
+		n = int64(len(deleted))
+	default:
+		n, err = e.engine.Delete(s.Table.Name, filter)
+	}
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	if len(refs) > 0 {
+		if err := e.cascadeDelete(def, deleted); err != nil {
+			return nil, err
+		}
+	}
 
-	var planStart time.Time
 	if tr != nil {
-		planStart = time.Now()
+		tr.RowsReturned = int64(n)
+		tr.Exec = time.Since(execStart)
 	}
 
-	def, ok := e.engine.GetTable(s.Table.Name)
-	if !ok {
-		return nil, WrapError(&storage.TableNotFoundError{Name: s.Table.String()})
-	}
+	return ret.result(deleted, fmt.Sprintf("DELETE %d", n))
+}
 
-	// Evaluate SET values.
-	sets := make(map[string]any, len(s.Sets))
-	for _, sc := range s.Sets {
-		v, err := evalLiteral(sc.Value)
-		if err != nil {
-			return nil, WrapError(fmt.Errorf("SET %s: %w", sc.Column, err))
-		}
-		sets[sc.Column] = v
-	}
+// returningList is a compiled RETURNING clause. A nil *returningList stands
+// for a statement without one.
+type returningList struct {
+	evals []exprFunc
+	cols  []Column
+}
 
-	// Build WHERE filter.
-	var filter func(storage.Row) bool
-	var err error
-	if s.Where != nil {
-		filter, err = buildFilter(s.Where, def)
-		if err != nil {
-			return nil, WrapError(err)
+// compileReturning resolves a RETURNING list against the target table. It
+// runs before the statement touches any rows, so a bad column reference
+// leaves the table unchanged. Returns nil when exprs is empty.
+func (e *Executor) compileReturning(exprs []parser.Expr, def *storage.TableDef) (*returningList, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	for _, expr := range exprs {
+		if a, ok := expr.(*parser.AliasExpr); ok {
+			expr = a.Expr
+		}
+		if fn, ok := expr.(*parser.FunctionCallExpr); ok && isAggFunc(fn.Name) {
+			return nil, &QueryError{Code: "42803", Message: "aggregate functions are not allowed in RETURNING"}
 		}
 	}
+	evals, cols, err := e.resolveSelectColumns(exprs, def, "")
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return &returningList{evals: evals, cols: cols}, nil
+}
 
-	// If INDEXED BY is specified, wrap the filter to only consider rows from the index lookup.
-	if s.IndexedBy != "" {
-		rows, err := e.lookupByNamedIndex(s.IndexedBy, s.Where, def)
+// result builds the statement result. Without RETURNING it carries only the
+// command tag; with it, one output row per affected row. UPDATE passes the
+// rows as stored after the update, DELETE the rows as they were before
+// removal.
+func (r *returningList) result(rows []storage.Row, tag string) (*Result, error) {
+	if r == nil {
+		return &Result{Tag: tag}, nil
+	}
+	out := make([][][]byte, len(rows))
+	for i, row := range rows {
+		textRow, err := formatRow(row, r.evals)
 		if err != nil {
 			return nil, err
 		}
-		if tr != nil {
-			tr.IndexName = s.IndexedBy
+		out[i] = textRow
+	}
+	return &Result{Columns: r.cols, Rows: out, Tag: tag}, nil
+}
+
+// execTruncate empties the listed tables, all of them or none. Unlike an
+// unfiltered DELETE it does not visit the rows, so the tag carries no row
+// count. A table referenced by a foreign key can only be truncated along
+// with the tables that reference it.
+func (e *Executor) execTruncate(s *parser.TruncateStmt, tr *Trace) (*Result, error) {
+	names := make([]string, len(s.Tables))
+	for i, t := range s.Tables {
+		if isCatalogTable(t.Schema, t.Name) {
+			return nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot truncate catalog table %q", t.String())}
 		}
-		idSet := make(map[int64]struct{}, len(rows))
-		for _, r := range rows {
-			idSet[r.ID] = struct{}{}
+		names[i] = t.Name
+	}
+	for _, name := range names {
+		def, ok := e.engine.GetTable(name)
+		if !ok {
+			continue
 		}
-		baseFilter := filter
-		filter = func(r storage.Row) bool {
-			if _, ok := idSet[r.ID]; !ok {
-				return false
+		for _, ref := range e.referencesTo(def, nil) {
+			if !slices.Contains(names, ref.child.Name) {
+				return nil, &QueryError{Code: "0A000", Message: fmt.Sprintf(
+					"cannot truncate a table referenced in a foreign key constraint: table %q references %q", ref.child.Name, def.Name)}
 			}
-			if baseFilter != nil {
-				return baseFilter(r)
-			}
-			return true
 		}
 	}
 
-	if tr != nil {
-		tr.Plan = time.Since(planStart)
-	}
-
 	var execStart time.Time
 	if tr != nil {
 		execStart = time.Now()
 	}
 
-	n, err := e.engine.Update(s.Table.Name, sets, filter)
-	if err != nil {
+	if err := e.engine.Truncate(names, s.RestartIdentity); err != nil {
 		return nil, WrapError(err)
 	}
 
 	if tr != nil {
-		tr.RowsReturned = int64(n)
 		tr.Exec = time.Since(execStart)
 	}
 
-	return &Result{Tag: fmt.Sprintf("UPDATE %d", n)}, nil
+	return &Result{Tag: "TRUNCATE TABLE"}, nil
 }
 
-func (e *Executor) execDelete(s *parser.DeleteStmt, tr *Trace) (*Result, error) {
+// execAnalyze refreshes planner statistics for one table, or for every
+// table when none is named.
+func (e *Executor) execAnalyze(s *parser.AnalyzeStmt, tr *Trace) (*Result, error) {
 	if isCatalogTable(s.Table.Schema, s.Table.Name) {
-		return nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot delete from catalog table %q", s.Table.String())}
+		return nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot analyze catalog table %q", s.Table.String())}
 	}
 
-	var planStart time.Time
+	var execStart time.Time
 	if tr != nil {
-		planStart = time.Now()
+		execStart = time.Now()
 	}
 
-	def, ok := e.engine.GetTable(s.Table.Name)
-	if !ok {
-		return nil, WrapError(&storage.TableNotFoundError{Name: s.Table.String()})
+	var tables []string
+	if s.Table.IsEmpty() {
+		for _, def := range e.engine.ListTables() {
+			tables = append(tables, def.Name)
+		}
+	} else {
+		tables = []string{s.Table.Name}
 	}
-
-	var filter func(storage.Row) bool
-	var err error
-	if s.Where != nil {
-		filter, err = buildFilter(s.Where, def)
-		if err != nil {
+	for _, name := range tables {
+		if err := e.engine.Analyze(name); err != nil {
 			return nil, WrapError(err)
 		}
 	}
 
-	// If INDEXED BY is specified, wrap the filter to only consider rows from the index lookup.
-	if s.IndexedBy != "" {
-		rows, err := e.lookupByNamedIndex(s.IndexedBy, s.Where, def)
-		if err != nil {
-			return nil, err
+	if tr != nil {
+		tr.Exec = time.Since(execStart)
+	}
+
+	return &Result{Tag: "ANALYZE"}, nil
+}
+
+// execVacuum compacts the rows of one table, or of every table when none
+// is named, and reports the slots each gave back in a notice. Each table's
+// WAL is then checkpointed, so that the file shrinks as well.
+func (e *Executor) execVacuum(s *parser.VacuumStmt, tr *Trace) (*Result, error) {
+	if isCatalogTable(s.Table.Schema, s.Table.Name) {
+		return nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot vacuum catalog table %q", s.Table.String())}
+	}
+
+	var execStart time.Time
+	if tr != nil {
+		execStart = time.Now()
+	}
+
+	var tables []string
+	if s.Table.IsEmpty() {
+		for _, def := range e.engine.ListTables() {
+			tables = append(tables, def.Name)
 		}
-		if tr != nil {
-			tr.IndexName = s.IndexedBy
+		slices.Sort(tables)
+	} else {
+		tables = []string{s.Table.Name}
+	}
+	res := &Result{Tag: "VACUUM"}
+	for _, name := range tables {
+		n, err := e.engine.Vacuum(name)
+		if err != nil {
+			return nil, WrapError(err)
 		}
-		idSet := make(map[int64]struct{}, len(rows))
-		for _, r := range rows {
-			idSet[r.ID] = struct{}{}
+		if err := e.engine.Checkpoint(name); err != nil {
+			return nil, WrapError(err)
 		}
-		baseFilter := filter
-		filter = func(r storage.Row) bool {
-			if _, ok := idSet[r.ID]; !ok {
-				return false
-			}
-			if baseFilter != nil {
-				return baseFilter(r)
-			}
-			return true
+		res.Notices = append(res.Notices, fmt.Sprintf("table %q: reclaimed %d row slots", name, n))
+	}
+
+	if tr != nil {
+		tr.Exec = time.Since(execStart)
+	}
+
+	return res, nil
+}
+
+// execCheckpoint replaces the WAL of every table with a snapshot of its
+// rows.
+func (e *Executor) execCheckpoint(tr *Trace) (*Result, error) {
+	var execStart time.Time
+	if tr != nil {
+		execStart = time.Now()
+	}
+
+	for _, def := range e.engine.ListTables() {
+		if err := e.engine.Checkpoint(def.Name); err != nil {
+			return nil, WrapError(err)
 		}
 	}
 
 	if tr != nil {
-		tr.Plan = time.Since(planStart)
+		tr.Exec = time.Since(execStart)
 	}
 
+	return &Result{Tag: "CHECKPOINT"}, nil
+}
+
+// execBackup writes a snapshot of the database to the server's disk: a
+// backup file for storage.Restore, or a directory that can be opened as a
+// data directory.
+func (e *Executor) execBackup(s *parser.BackupStmt, tr *Trace) (*Result, error) {
 	var execStart time.Time
 	if tr != nil {
 		execStart = time.Now()
 	}
 
-	n, err := e.engine.Delete(s.Table.Name, filter)
+	var err error
+	if s.Directory {
+		err = e.engine.Snapshot(s.Path)
+	} else {
+		err = storage.BackupToFile(e.engine, s.Path)
+	}
 	if err != nil {
 		return nil, WrapError(err)
 	}
 
 	if tr != nil {
-		tr.RowsReturned = int64(n)
 		tr.Exec = time.Since(execStart)
 	}
 
-	return &Result{Tag: fmt.Sprintf("DELETE %d", n)}, nil
+	return &Result{Tag: "BACKUP"}, nil
 }
 
 // -------------------------------------------------------------------------
@@ -2516,6 +3263,22 @@ func (exec *Executor) resolveSelectColumns(exprs []parser.Expr, def *storage.Tab
 				name = alias
 			}
 			cols = append(cols, Column{Name: name, TypeOID: OIDBool, TypeSize: 1})
+		case *parser.TimestampLit:
+			v := e.Value
+			evals = append(evals, func(r storage.Row) any { return v })
+			name := "?column?"
+			if alias != "" {
+				name = alias
+			}
+			cols = append(cols, Column{Name: name, TypeOID: OIDTimestampTZ, TypeSize: 8})
+		case *parser.RandomExpr:
+			next := e.Next
+			evals = append(evals, func(r storage.Row) any { return next() })
+			name := "?column?"
+			if alias != "" {
+				name = alias
+			}
+			cols = append(cols, Column{Name: name, TypeOID: OIDFloat8, TypeSize: 8})
 		case *parser.NullLit:
 			evals = append(evals, func(r storage.Row) any { return nil })
 			name := "?column?"
@@ -2529,11 +3292,30 @@ func (exec *Executor) resolveSelectColumns(exprs []parser.Expr, def *storage.Tab
 				return nil, nil, err
 			}
 			evals = append(evals, compiled)
-			// Get column metadata from the scalar function.
+			// Get column metadata from the scalar function by probing it
+			// with its literal arguments and NULL for the rest, since some
+			// result types depend on a literal (EXTRACT's field). If the
+			// literals alone make the call fail, fall back to all NULLs.
 			col := Column{Name: "?column?", TypeOID: OIDUnknown, TypeSize: -1}
 			if fn, ok := scalarRegistry[e.Name]; ok {
-				if _, meta, err := fn([]any{nil}); err == nil {
+				probe := make([]any, len(e.Args))
+				for i, a := range e.Args {
+					probe[i], _ = literalValue(a)
+				}
+				if _, meta, err := fn(probe); err == nil {
 					col = meta
+				} else if _, meta, err := fn(make([]any, len(e.Args))); err == nil {
+					col = meta
+				}
+			}
+			// The probe types the result by whichever argument it picked;
+			// functions returning one of their arguments report the type
+			// common to all of them instead.
+			if args := unifiedArgs(e); args != nil {
+				if _, argCols, err := exec.resolveSelectColumns(args, def, fromAlias); err == nil {
+					if u, ok := unifyColumnTypes(argCols); ok {
+						col.TypeOID, col.TypeSize = u.TypeOID, u.TypeSize
+					}
 				}
 			}
 			if alias != "" {
@@ -2552,6 +3334,8 @@ func (exec *Executor) resolveSelectColumns(exprs []parser.Expr, def *storage.Tab
 			}
 			if e.Op == "||" {
 				cols = append(cols, Column{Name: name, TypeOID: OIDText, TypeSize: -1})
+			} else if isNumericExpr(e, func(ref parser.Expr) (storage.DataType, bool) { return resolveExprType(ref, def) }) {
+				cols = append(cols, Column{Name: name, TypeOID: OIDNumeric, TypeSize: -1})
 			} else {
 				cols = append(cols, Column{Name: name, TypeOID: OIDInt8, TypeSize: 8})
 			}
@@ -2566,6 +3350,20 @@ func (exec *Executor) resolveSelectColumns(exprs []parser.Expr, def *storage.Tab
 				name = alias
 			}
 			cols = append(cols, Column{Name: name, TypeOID: castTypeOID(e.TypeName), TypeSize: castTypeSize(e.TypeName)})
+		case *parser.SubqueryExpr:
+			compiled, err := compileExpr(e, def)
+			if err != nil {
+				return nil, nil, err
+			}
+			col, err := exec.subqueryColumn(e)
+			if err != nil {
+				return nil, nil, err
+			}
+			if alias != "" {
+				col.Name = alias
+			}
+			evals = append(evals, compiled)
+			cols = append(cols, col)
 		case *parser.NestExpr:
 			eval, col, err := exec.compileNestColumn(e, def, fromAlias)
 			if err != nil {
@@ -2637,6 +3435,22 @@ func compileExpr(expr parser.Expr, def *storage.TableDef) (exprFunc, error) {
 		v := e.Value
 		return func(storage.Row) any { return v }, nil
 
+	case *parser.TimestampLit:
+		v := e.Value
+		return func(storage.Row) any { return v }, nil
+
+	case *parser.RandomExpr:
+		next := e.Next
+		return func(storage.Row) any { return next() }, nil
+
+	case *parser.OuterRef:
+		return func(storage.Row) any { return e.Value }, nil
+
+	case *parser.SubqueryExpr:
+		return compileScalarSubquery(e, func(ref *parser.ColumnRef) (exprFunc, error) {
+			return compileExpr(ref, def)
+		})
+
 	case *parser.NullLit:
 		return func(storage.Row) any { return nil }, nil
 
@@ -2668,6 +3482,8 @@ func compileExpr(expr parser.Expr, def *storage.TableDef) (exprFunc, error) {
 				return -n
 			case float64:
 				return -n
+			case storage.Numeric:
+				return n.Neg()
 			default:
 				return nil
 			}
@@ -2692,6 +3508,11 @@ func compileExpr(expr parser.Expr, def *storage.TableDef) (exprFunc, error) {
 		})
 
 	case *parser.InExpr:
+		if e.Subquery != nil {
+			return compileInSubquery(e, func(expr parser.Expr) (exprFunc, error) {
+				return compileExpr(expr, def)
+			})
+		}
 		return compileInExprCoerced(e, func(expr parser.Expr) (exprFunc, error) {
 			return compileExpr(expr, def)
 		}, func(lhs parser.Expr, values []parser.Expr, valFns []exprFunc) ([]exprFunc, error) {
@@ -2710,8 +3531,13 @@ func compileExpr(expr parser.Expr, def *storage.TableDef) (exprFunc, error) {
 		if err != nil {
 			return nil, err
 		}
-		typeName := e.TypeName
-		return func(r storage.Row) any { return castValue(inner(r), typeName) }, nil
+		return func(r storage.Row) any {
+			v, err := castTypmod(castValue(inner(r), e.TypeName), e)
+			if err != nil {
+				return nil
+			}
+			return v
+		}, nil
 
 	case *parser.FunctionCallExpr:
 		fn, ok := scalarRegistry[e.Name]
@@ -2776,7 +3602,7 @@ func compileLikeExpr(e *parser.LikeExpr, compile func(parser.Expr) (exprFunc, er
 
 	// Static pattern optimization: pre-compile regex if pattern is a string literal.
 	if lit, ok := e.Pattern.(*parser.StringLit); ok && escFn == nil {
-		re, reErr := likeToRegex(lit.Value, escRune, hasEscape, e.CaseInsensitive)
+		re, reErr := patternRegex(e, lit.Value, escRune, hasEscape)
 		if reErr != nil {
 			return nil, reErr
 		}
@@ -2800,7 +3626,6 @@ func compileLikeExpr(e *parser.LikeExpr, compile func(parser.Expr) (exprFunc, er
 
 	// Dynamic pattern: compile regex per-row.
 	not := e.Not
-	ci := e.CaseInsensitive
 	return func(r storage.Row) any {
 		val, pat := valFn(r), patFn(r)
 		if val == nil || pat == nil {
@@ -2825,7 +3650,7 @@ func compileLikeExpr(e *parser.LikeExpr, compile func(parser.Expr) (exprFunc, er
 			}
 			he = true
 		}
-		re, err := likeToRegex(ps, esc, he, ci)
+		re, err := patternRegex(e, ps, esc, he)
 		if err != nil {
 			return nil
 		}
@@ -3125,6 +3950,9 @@ func compileBinaryExpr(e *parser.BinaryExpr, def *storage.TableDef) (exprFunc, e
 			if lv == nil || rv == nil {
 				return nil
 			}
+			if v, ok, _ := numericArith(op, lv, rv); ok {
+				return v
+			}
 			// Try integer arithmetic first.
 			li, lok := lv.(int64)
 			ri, rok := rv.(int64)
@@ -3181,15 +4009,13 @@ func compileBinaryExpr(e *parser.BinaryExpr, def *storage.TableDef) (exprFunc, e
 	}
 }
 
-
 // -------------------------------------------------------------------------
 // PK index lookup
 // -------------------------------------------------------------------------
 
-// tryPKLookup checks if the WHERE expression is a simple "pk_column = literal"
-// equality and if so, performs an indexed lookup. Returns the row and true if
-// found via index, or (nil, false) if not applicable or no match.
-func (e *Executor) tryPKLookup(where parser.Expr, def *storage.TableDef) (*storage.Row, bool) {
+// pkLookupValue reports whether where is a simple equality on the primary key
+// column and, if so, returns the key value to look up.
+func pkLookupValue(where parser.Expr, def *storage.TableDef) (any, bool) {
 	pkCol := def.PrimaryKeyColumn()
 	if pkCol < 0 {
 		return nil, false
@@ -3215,12 +4041,16 @@ func (e *Executor) tryPKLookup(where parser.Expr, def *storage.TableDef) (*stora
 	if err != nil || val == nil {
 		return nil, false
 	}
-
-	row, err := e.engine.LookupByPK(def.Name, val)
-	if err != nil || row == nil {
-		return nil, false
+	// Convert the literal to the key's type, as the row filter does when
+	// it compares them, so that id = '1' finds the row with key 1.
+	for _, c := range def.Columns {
+		if c.Ordinal == pkCol {
+			if val, err = coerceLiteral(val, c.DataType); err != nil {
+				return nil, false
+			}
+		}
 	}
-	return row, true
+	return val, true
 }
 
 // extractEqualityValue walks a WHERE tree (descending into AND nodes) to find
@@ -3257,37 +4087,73 @@ func extractEqualityValue(expr parser.Expr, colName string) any {
 	return nil
 }
 
-// lookupByNamedIndex validates a named index exists and is applicable to the WHERE clause,
-// then performs the index lookup. Returns error if the index is not found or not applicable.
-func (e *Executor) lookupByNamedIndex(indexName string, where parser.Expr, def *storage.TableDef) ([]storage.Row, error) {
-	// Find the named index in the table definition.
-	var found bool
-	var idxColumn string
-	for _, idx := range def.Indexes {
-		if strings.EqualFold(idx.Name, indexName) {
-			found = true
-			idxColumn = idx.Column
+// namedIndexAccess checks that indexName exists on def and that where
+// allows reading it. If where has an equality predicate on each indexed
+// column, it returns the key to look up: the value for a single-column
+// index, one value per column ([]any) for a composite one. Otherwise the
+// index is walked between bounds, and the key is nil. A single-column
+// index is bounded by the <, <=, >, >= or BETWEEN predicates on its
+// column. A composite index is bounded by a prefix of its columns: the
+// leading columns that have an equality, optionally followed by one
+// column with a range. Its bounds are tuples of the prefix's values,
+// which compare equal to every key that starts with them.
+func namedIndexAccess(indexName string, where parser.Expr, def *storage.TableDef) (key any, lo, hi *storage.KeyBound, err error) {
+	idx, err := namedIndex(indexName, def)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if where == nil {
+		return nil, nil, nil, &QueryError{Code: "0A000", Message: fmt.Sprintf("INDEXED BY %q requires a WHERE clause with an equality or range predicate on column %q", indexName, idx.Columns[0])}
+	}
+
+	var vals []any
+	for _, col := range idx.Columns {
+		v := extractEqualityValue(where, col)
+		if v == nil {
 			break
 		}
+		vals = append(vals, v)
 	}
-	if !found {
-		return nil, &QueryError{Code: "42704", Message: fmt.Sprintf("index %q not found on table %q", indexName, def.Name)}
+	switch {
+	case len(vals) == len(idx.Columns) && len(vals) == 1:
+		return vals[0], nil, nil, nil
+	case len(vals) == len(idx.Columns):
+		return vals, nil, nil, nil
+	case len(idx.Columns) == 1:
+		if lo, hi = keyRangeBounds(where, def, columnIndex(def, idx.Columns[0])); lo != nil || hi != nil {
+			return nil, lo, hi, nil
+		}
+		return nil, nil, nil, &QueryError{Code: "0A000", Message: fmt.Sprintf("INDEXED BY %q requires an equality or range predicate on column %q in WHERE clause", indexName, idx.Columns[0])}
 	}
 
-	if where == nil {
-		return nil, &QueryError{Code: "0A000", Message: fmt.Sprintf("INDEXED BY %q requires a WHERE clause with an equality predicate on column %q", indexName, idxColumn)}
+	lo, hi = keyRangeBounds(where, def, columnIndex(def, idx.Columns[len(vals)]))
+	if len(vals) == 0 && lo == nil && hi == nil {
+		return nil, nil, nil, &QueryError{Code: "0A000", Message: fmt.Sprintf("INDEXED BY %q requires an equality or range predicate on its leading column %q in WHERE clause", indexName, idx.Columns[0])}
 	}
-
-	val := extractEqualityValue(where, idxColumn)
-	if val == nil {
-		return nil, &QueryError{Code: "0A000", Message: fmt.Sprintf("INDEXED BY %q requires an equality predicate on column %q in WHERE clause", indexName, idxColumn)}
+	prefix := func(b *storage.KeyBound) *storage.KeyBound {
+		if b == nil {
+			return &storage.KeyBound{Value: vals, Inclusive: true}
+		}
+		return &storage.KeyBound{Value: append(append([]any(nil), vals...), b.Value), Inclusive: b.Inclusive}
+	}
+	if len(vals) > 0 || lo != nil {
+		lo = prefix(lo)
+	}
+	if len(vals) > 0 || hi != nil {
+		hi = prefix(hi)
 	}
+	return nil, lo, hi, nil
+}
 
-	rows, err := e.engine.LookupByIndex(def.Name, indexName, val)
-	if err != nil {
-		return nil, WrapError(err)
+// namedIndex returns the definition of the index indexName on def.
+func namedIndex(indexName string, def *storage.TableDef) (*storage.IndexDef, error) {
+	for i := range def.Indexes {
+		if strings.EqualFold(def.Indexes[i].Name, indexName) {
+			return &def.Indexes[i], nil
+		}
 	}
-	return rows, nil
+	return nil, &QueryError{Code: "42704", Message: fmt.Sprintf("index %q not found on table %q", indexName, def.Name)}
 }
 
 // extractColumnAndLiteral checks if a binary expression has a ColumnRef on one
@@ -3308,7 +4174,7 @@ func extractColumnAndLiteral(bin *parser.BinaryExpr) (*parser.ColumnRef, parser.
 
 func isLiteralExpr(e parser.Expr) bool {
 	switch e.(type) {
-	case *parser.IntegerLit, *parser.StringLit, *parser.BoolLit:
+	case *parser.IntegerLit, *parser.StringLit, *parser.BoolLit, *parser.OuterRef:
 		return true
 	}
 	return false
@@ -3328,6 +4194,12 @@ func evalLiteral(expr parser.Expr) (any, error) {
 		return e.Value, nil
 	case *parser.BoolLit:
 		return e.Value, nil
+	case *parser.TimestampLit:
+		return e.Value, nil
+	case *parser.RandomExpr:
+		return e.Next(), nil
+	case *parser.OuterRef:
+		return e.Value, nil
 	case *parser.NullLit:
 		return nil, nil
 	case *parser.BinaryExpr:
@@ -3346,7 +4218,7 @@ func evalLiteral(expr parser.Expr) (any, error) {
 		if err != nil {
 			return nil, err
 		}
-		return castValue(val, e.TypeName), nil
+		return castTypmod(castValue(val, e.TypeName), e)
 	default:
 		return nil, fmt.Errorf("expected literal value, got %T", expr)
 	}
@@ -3364,11 +4236,29 @@ func parseDataType(s string) (storage.DataType, error) {
 		return storage.TypeTimestamp, nil
 	case "FLOAT":
 		return storage.TypeFloat, nil
+	case "BYTEA":
+		return storage.TypeBytea, nil
+	case "NUMERIC":
+		return storage.TypeNumeric, nil
+	case "TIME":
+		return storage.TypeTime, nil
 	default:
 		return 0, fmt.Errorf("unknown data type %q", s)
 	}
 }
 
+// parseIdentity maps the parser's identity kind to the storage one.
+func parseIdentity(s string) storage.Identity {
+	switch s {
+	case "ALWAYS":
+		return storage.IdentityAlways
+	case "BY DEFAULT":
+		return storage.IdentityByDefault
+	default:
+		return storage.IdentityNone
+	}
+}
+
 func columnIndex(def *storage.TableDef, name string) int {
 	for _, c := range def.Columns {
 		if strings.EqualFold(c.Name, name) {
@@ -3378,6 +4268,36 @@ func columnIndex(def *storage.TableDef, name string) int {
 	return -1
 }
 
+// ordinalEnd returns one past the highest column ordinal of def.
+func ordinalEnd(def *storage.TableDef) int {
+	end := 0
+	for _, c := range def.Columns {
+		if c.Ordinal >= end {
+			end = c.Ordinal + 1
+		}
+	}
+	return end
+}
+
+// appendSortKeys evaluates the ORDER BY expressions evals for each row and
+// stores the results after the table's columns, from ordinal base on, so
+// that the sort can treat them like columns. Each expression is evaluated
+// once per row, which keeps keys such as RANDOM() stable while sorting.
+// The rows' values are copied, not modified in place.
+func appendSortKeys(rows []storage.Row, base int, evals []exprFunc) {
+	if len(evals) == 0 {
+		return
+	}
+	for i, row := range rows {
+		vals := make([]any, base+len(evals))
+		copy(vals, row.Values)
+		for k, eval := range evals {
+			vals[base+k] = eval(row)
+		}
+		rows[i].Values = vals
+	}
+}
+
 // columnByOrdinal returns the ColumnDef with the given ordinal, or a zero value.
 func columnByOrdinal(def *storage.TableDef, ordinal int) storage.ColumnDef {
 	for _, c := range def.Columns {
@@ -3388,16 +4308,36 @@ func columnByOrdinal(def *storage.TableDef, ordinal int) storage.ColumnDef {
 	return storage.ColumnDef{}
 }
 
+// isSummable reports whether SUM and AVG accept a column of type dt.
+func isSummable(dt storage.DataType) bool {
+	return dt == storage.TypeInteger || dt == storage.TypeFloat || dt == storage.TypeNumeric
+}
+
+// isAggFunc reports whether name is one of the supported aggregate functions.
+func isAggFunc(name string) bool {
+	switch name {
+	case "COUNT", "SUM", "MIN", "MAX", "AVG":
+		return true
+	}
+	return false
+}
+
 func aggregateTypeOID(funcName string, inputType storage.DataType) int32 {
 	switch funcName {
 	case "COUNT":
 		return OIDInt8
 	case "SUM":
-		if inputType == storage.TypeFloat {
+		switch inputType {
+		case storage.TypeFloat:
 			return OIDFloat8
+		case storage.TypeNumeric:
+			return OIDNumeric
 		}
 		return OIDInt8
 	case "AVG":
+		if inputType == storage.TypeNumeric {
+			return OIDNumeric
+		}
 		return OIDFloat8
 	case "MIN", "MAX":
 		return typeOID(inputType)
@@ -3408,8 +4348,13 @@ func aggregateTypeOID(funcName string, inputType storage.DataType) int32 {
 
 func aggregateTypeSize(funcName string, inputType storage.DataType) int16 {
 	switch funcName {
-	case "COUNT", "SUM", "AVG":
+	case "SUM", "AVG":
+		if inputType == storage.TypeNumeric {
+			return -1
+		}
 		return 8 // int64 and float64 are both 8 bytes
+	case "COUNT":
+		return 8
 	case "MIN", "MAX":
 		return typeSize(inputType)
 	default:
@@ -3429,11 +4374,42 @@ func typeOID(dt storage.DataType) int32 {
 		return OIDTimestampTZ
 	case storage.TypeFloat:
 		return OIDFloat8
+	case storage.TypeBytea:
+		return OIDBytea
+	case storage.TypeNumeric:
+		return OIDNumeric
+	case storage.TypeTime:
+		return OIDTime
 	default:
 		return OIDUnknown
 	}
 }
 
+// valueTypeOID returns the type OID and size for a Go value as produced by
+// evalLiteral.
+func valueTypeOID(v any) (int32, int16) {
+	switch v.(type) {
+	case int64:
+		return OIDInt8, 8
+	case float64:
+		return OIDFloat8, 8
+	case string:
+		return OIDText, -1
+	case bool:
+		return OIDBool, 1
+	case time.Time:
+		return OIDTimestampTZ, 8
+	case []byte:
+		return OIDBytea, -1
+	case storage.Numeric:
+		return OIDNumeric, -1
+	case storage.TimeOfDay:
+		return OIDTime, 8
+	default:
+		return OIDUnknown, -1
+	}
+}
+
 func typeSize(dt storage.DataType) int16 {
 	switch dt {
 	case storage.TypeInteger:
@@ -3444,34 +4420,79 @@ func typeSize(dt storage.DataType) int16 {
 		return 8
 	case storage.TypeFloat:
 		return 8
+	case storage.TypeTime:
+		return 8
 	default:
 		return -1 // variable length
 	}
 }
 
+// timestampLayout is the text form of a TIMESTAMP value, as PostgreSQL
+// prints a timestamptz in UTC: microseconds only when there are any, with
+// trailing zeros dropped.
+const timestampLayout = "2006-01-02 15:04:05.999999+00"
+
 // formatValue converts a storage value to its text-encoded wire format.
-// nil means SQL NULL.
-func formatValue(v any) []byte {
+// nil means SQL NULL. Every type the engine stores has a case, which
+// TestFormatValue_EveryType checks; any other value is a bug in whatever
+// produced it and fails the query with SQLSTATE XX000.
+func formatValue(v any) ([]byte, error) {
 	if v == nil {
-		return nil
+		return nil, nil
 	}
 	switch val := v.(type) {
 	case int64:
-		return []byte(strconv.FormatInt(val, 10))
+		return []byte(strconv.FormatInt(val, 10)), nil
 	case float64:
-		return []byte(strconv.FormatFloat(val, 'g', -1, 64))
+		return []byte(strconv.FormatFloat(val, 'g', -1, 64)), nil
 	case string:
-		return []byte(val)
+		return []byte(val), nil
 	case bool:
 		if val {
-			return []byte("t")
+			return []byte("t"), nil
 		}
-		return []byte("f")
+		return []byte("f"), nil
 	case time.Time:
-		return []byte(val.Format("2006-01-02 15:04:05+00"))
+		return []byte(val.Format(timestampLayout)), nil
+	case []byte:
+		return []byte(storage.FormatBytea(val)), nil
+	case storage.Numeric:
+		return []byte(val.String()), nil
+	case storage.TimeOfDay:
+		return []byte(val.String()), nil
+	case error:
+		// An eval with no other way to fail, such as NEST's, returns
+		// its error as the value.
+		return nil, val
 	default:
-		return []byte(fmt.Sprintf("%v", v))
+		return nil, &QueryError{Code: "XX000", Message: fmt.Sprintf("cannot format value of type %T", v)}
+	}
+}
+
+// formatRow evaluates each of evals on row and formats the results.
+func formatRow(row storage.Row, evals []exprFunc) ([][]byte, error) {
+	textRow := make([][]byte, len(evals))
+	for i, eval := range evals {
+		b, err := formatValue(eval(row))
+		if err != nil {
+			return nil, err
+		}
+		textRow[i] = b
+	}
+	return textRow, nil
+}
+
+// formatValues formats vals as a result row.
+func formatValues(vals []any) ([][]byte, error) {
+	textRow := make([][]byte, len(vals))
+	for i, v := range vals {
+		b, err := formatValue(v)
+		if err != nil {
+			return nil, err
+		}
+		textRow[i] = b
 	}
+	return textRow, nil
 }
 
 // toFloat64 converts a numeric value to float64.
@@ -3482,6 +4503,8 @@ func toFloat64(v any) (float64, bool) {
 		return n, true
 	case int64:
 		return float64(n), true
+	case storage.Numeric:
+		return n.Float64(), true
 	default:
 		return 0, false
 	}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -150,6 +152,567 @@ func TestExecutor_Delete(t *testing.T) {
 	}
 }
 
+func TestExecutor_Truncate(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (1, 'alice'), (2, 'bob')")
+
+	r := exec(t, e, "TRUNCATE TABLE t")
+	if r.Tag != "TRUNCATE TABLE" {
+		t.Errorf("tag = %q, want TRUNCATE TABLE", r.Tag)
+	}
+	r = exec(t, e, "SELECT * FROM t")
+	if len(r.Rows) != 0 {
+		t.Fatalf("after truncate: %d rows, want 0", len(r.Rows))
+	}
+
+	// The primary key index was reset along with the rows.
+	exec(t, e, "INSERT INTO t VALUES (1, 'carol')")
+	exec(t, e, "TRUNCATE t")
+	r = exec(t, e, "SELECT * FROM t")
+	if len(r.Rows) != 0 {
+		t.Fatalf("after TRUNCATE without TABLE: %d rows, want 0", len(r.Rows))
+	}
+
+	_, err := e.Execute("TRUNCATE TABLE pg_catalog.pg_class")
+	assertSQLSTATE(t, err, "42809")
+	_, err = e.Execute("TRUNCATE TABLE missing")
+	assertSQLSTATE(t, err, "42P01")
+}
+
+func TestExecutor_TruncateMany(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE customers (id SERIAL PRIMARY KEY, name TEXT)")
+	exec(t, e, "CREATE TABLE orders (id SERIAL PRIMARY KEY, customer_id INTEGER REFERENCES customers(id))")
+	exec(t, e, "CREATE TABLE notes (body TEXT)")
+	exec(t, e, "INSERT INTO customers (name) VALUES ('a'), ('b')")
+	exec(t, e, "INSERT INTO orders (customer_id) VALUES (1), (2)")
+	exec(t, e, "INSERT INTO notes VALUES ('x')")
+	count := func(table string) string {
+		t.Helper()
+		return string(exec(t, e, "SELECT COUNT(*) FROM "+table).Rows[0][0])
+	}
+
+	// A failure leaves every listed table untouched: a missing table, a
+	// view, or a referenced table without the tables referencing it.
+	exec(t, e, "CREATE VIEW v AS SELECT * FROM notes")
+	for _, tt := range []struct {
+		sql, code string
+	}{
+		{"TRUNCATE notes, missing", "42P01"},
+		{"TRUNCATE notes, v", "42809"},
+		{"TRUNCATE notes, customers", "0A000"},
+	} {
+		_, err := e.Execute(tt.sql)
+		assertSQLSTATE(t, err, tt.code)
+	}
+	if count("customers") != "2" || count("orders") != "2" || count("notes") != "1" {
+		t.Fatalf("after failed truncates: %s customers, %s orders, %s notes", count("customers"), count("orders"), count("notes"))
+	}
+
+	r := exec(t, e, "TRUNCATE TABLE orders, customers RESTART IDENTITY")
+	if r.Tag != "TRUNCATE TABLE" {
+		t.Errorf("tag = %q, want TRUNCATE TABLE", r.Tag)
+	}
+	if count("customers") != "0" || count("orders") != "0" || count("notes") != "1" {
+		t.Errorf("after truncate: %s customers, %s orders, %s notes", count("customers"), count("orders"), count("notes"))
+	}
+	r = exec(t, e, "INSERT INTO customers (name) VALUES ('c') RETURNING id")
+	if got := string(r.Rows[0][0]); got != "1" {
+		t.Errorf("id after RESTART IDENTITY = %s, want 1", got)
+	}
+}
+
+func TestExecutor_TruncateIdentity(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id SERIAL PRIMARY KEY, name TEXT)")
+	exec(t, e, "INSERT INTO t (name) VALUES ('a'), ('b')")
+
+	exec(t, e, "TRUNCATE t")
+	r := exec(t, e, "INSERT INTO t (name) VALUES ('c') RETURNING id")
+	if got := string(r.Rows[0][0]); got != "3" {
+		t.Errorf("id after TRUNCATE = %s, want 3", got)
+	}
+	exec(t, e, "TRUNCATE t CONTINUE IDENTITY")
+	r = exec(t, e, "INSERT INTO t (name) VALUES ('d') RETURNING id")
+	if got := string(r.Rows[0][0]); got != "4" {
+		t.Errorf("id after CONTINUE IDENTITY = %s, want 4", got)
+	}
+	exec(t, e, "TRUNCATE TABLE t RESTART IDENTITY")
+	r = exec(t, e, "INSERT INTO t (name) VALUES ('e') RETURNING id")
+	if got := string(r.Rows[0][0]); got != "1" {
+		t.Errorf("id after RESTART IDENTITY = %s, want 1", got)
+	}
+}
+
+func TestExecutor_TruncateInTransaction(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER)")
+
+	txe := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	_, err := txe.Execute("TRUNCATE TABLE t")
+	assertSQLSTATE(t, err, "25001")
+}
+
+func TestExecutor_Vacuum(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "CREATE TABLE u (id INTEGER)")
+	exec(t, e, "CREATE VIEW v AS SELECT id FROM t")
+	exec(t, e, "INSERT INTO t VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd')")
+	exec(t, e, "DELETE FROM t WHERE id < 3")
+
+	r := exec(t, e, "VACUUM t")
+	if r.Tag != "VACUUM" {
+		t.Errorf("tag = %q, want VACUUM", r.Tag)
+	}
+	if fmt.Sprint(r.Notices) != `[table "t": reclaimed 2 row slots]` {
+		t.Errorf("notices = %q", r.Notices)
+	}
+	r = exec(t, e, "SELECT id, name FROM t WHERE id = 4")
+	if got := fmt.Sprint(groupRows(r)); got != "[4|d]" {
+		t.Errorf("lookup after VACUUM = %s, want [4|d]", got)
+	}
+
+	// Without a table every table is vacuumed, in name order.
+	r = exec(t, e, "VACUUM")
+	if fmt.Sprint(r.Notices) != `[table "t": reclaimed 0 row slots table "u": reclaimed 0 row slots]` {
+		t.Errorf("notices = %q", r.Notices)
+	}
+
+	_, err := e.Execute("VACUUM missing")
+	assertSQLSTATE(t, err, "42P01")
+	_, err = e.Execute("VACUUM v")
+	assertSQLSTATE(t, err, "42809")
+	_, err = e.Execute("VACUUM pg_catalog.pg_class")
+	assertSQLSTATE(t, err, "42809")
+	txe := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	_, err = txe.Execute("VACUUM t")
+	assertSQLSTATE(t, err, "25001")
+}
+
+func TestExecutor_Checkpoint(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "CREATE VIEW v AS SELECT id FROM t")
+	exec(t, e, "INSERT INTO t VALUES (1, 'a'), (2, 'b')")
+	exec(t, e, "UPDATE t SET name = 'c' WHERE id = 2")
+
+	r := exec(t, e, "CHECKPOINT")
+	if r.Tag != "CHECKPOINT" {
+		t.Errorf("tag = %q, want CHECKPOINT", r.Tag)
+	}
+	r = exec(t, e, "SELECT id, name FROM t")
+	if got := fmt.Sprint(groupRows(r)); got != "[1|a 2|c]" {
+		t.Errorf("rows after CHECKPOINT = %s, want [1|a 2|c]", got)
+	}
+
+	// Inside a transaction the committed tables are checkpointed; one the
+	// transaction created has no WAL yet.
+	txe := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	exec(t, txe, "CREATE TABLE scratch (id INTEGER)")
+	exec(t, txe, "CHECKPOINT")
+}
+
+func TestExecutor_Backup(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "CREATE VIEW v AS SELECT name FROM t")
+	exec(t, e, "INSERT INTO t VALUES (1, 'a'), (2, 'b')")
+
+	dir := filepath.Join(t.TempDir(), "backup")
+	file := dir + ".mbak"
+	r := exec(t, e, fmt.Sprintf("BACKUP TO DIRECTORY '%s'", dir))
+	if r.Tag != "BACKUP" {
+		t.Errorf("tag = %q, want BACKUP", r.Tag)
+	}
+	if _, err := e.Execute(fmt.Sprintf("BACKUP TO DIRECTORY '%s'", dir)); err == nil {
+		t.Error("expected error for a backup into a non-empty directory")
+	}
+	exec(t, e, fmt.Sprintf("BACKUP TO '%s'", file))
+	if _, err := e.Execute(fmt.Sprintf("BACKUP TO '%s'", file)); err == nil {
+		t.Error("expected error for a backup over an existing file")
+	}
+	exec(t, e, "DELETE FROM t")
+
+	restored := filepath.Join(t.TempDir(), "restored")
+	if err := storage.Restore(file, restored); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{dir, restored} {
+		eng, err := storage.Open(d, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = exec(t, New(eng), "SELECT name FROM v ORDER BY name")
+		eng.Close()
+		if got := fmt.Sprint(groupRows(r)); got != "[a b]" {
+			t.Errorf("rows of the backup in %s = %s, want [a b]", filepath.Base(d), got)
+		}
+	}
+}
+
+func TestExecutor_ListenNotify(t *testing.T) {
+	e := setup(t)
+	r := exec(t, e, "NOTIFY cache, 'users:42'")
+	want := ChannelCommand{Op: "NOTIFY", Channel: "cache", Payload: "users:42"}
+	if r.Tag != "NOTIFY" || r.Channel == nil || *r.Channel != want {
+		t.Errorf("got tag %q, channel %+v; want NOTIFY, %+v", r.Tag, r.Channel, want)
+	}
+	if r := exec(t, e, "UNLISTEN *"); r.Channel == nil || *r.Channel != (ChannelCommand{Op: "UNLISTEN"}) {
+		t.Errorf("UNLISTEN *: channel %+v", r.Channel)
+	}
+	_, err := e.Execute("NOTIFY cache, '" + strings.Repeat("x", 8000) + "'")
+	assertSQLSTATE(t, err, "22023")
+}
+
+func TestExecutor_DDLInTransaction(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (1, 'alice')")
+
+	// Rolled back: the transaction discards its engine.
+	txe := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	exec(t, txe, "CREATE TABLE scratch (id INTEGER PRIMARY KEY)")
+	exec(t, txe, "INSERT INTO scratch VALUES (1), (2)")
+	exec(t, txe, "ALTER TABLE t ADD COLUMN score INTEGER DEFAULT 5")
+	r := exec(t, txe, "SELECT COUNT(*) FROM scratch")
+	if string(r.Rows[0][0]) != "2" {
+		t.Errorf("COUNT(*) in transaction = %s, want 2", r.Rows[0][0])
+	}
+	r = exec(t, txe, "SELECT score FROM t")
+	if string(r.Rows[0][0]) != "5" {
+		t.Errorf("score in transaction = %s, want 5", r.Rows[0][0])
+	}
+	_, err := e.Execute("SELECT * FROM scratch")
+	assertSQLSTATE(t, err, "42P01")
+	if _, err := e.Execute("SELECT score FROM t"); err == nil {
+		t.Error("column added in the transaction is visible outside it")
+	}
+
+	// Committed.
+	tx := storage.NewTxEngine(e.Engine())
+	txe = e.WithEngine(tx)
+	exec(t, txe, "CREATE TABLE scratch (id INTEGER PRIMARY KEY, t_id INTEGER)")
+	exec(t, txe, "CREATE INDEX idx_t_id ON scratch(t_id)")
+	exec(t, txe, "INSERT INTO scratch VALUES (1, 1)")
+	exec(t, txe, "DROP TABLE t")
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+	r = exec(t, e, "SELECT id FROM scratch WHERE t_id = 1")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "1" {
+		t.Errorf("rows = %q, want [1]", r.Rows)
+	}
+	_, err = e.Execute("SELECT * FROM t")
+	assertSQLSTATE(t, err, "42P01")
+
+	// A table another session altered in the meantime fails the commit.
+	tx = storage.NewTxEngine(e.Engine())
+	exec(t, e.WithEngine(tx), "ALTER TABLE scratch ADD COLUMN a TEXT")
+	exec(t, e, "ALTER TABLE scratch ADD COLUMN b TEXT")
+	assertSQLSTATE(t, WrapError(tx.CommitOverlay()), "40001")
+}
+
+func TestExecutor_ReadOnlyTransaction(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (1, 'alice')")
+
+	tx := storage.NewTxEngine(e.Engine())
+	tx.SetReadOnly(true)
+	txe := e.WithEngine(tx)
+
+	r := exec(t, txe, "SELECT name FROM t WHERE id = 1")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "alice" {
+		t.Errorf("read in read-only transaction returned %q, want [alice]", r.Rows)
+	}
+	for _, sql := range []string{
+		"INSERT INTO t VALUES (2, 'bob')",
+		"INSERT INTO t VALUES (1, 'x') ON CONFLICT DO NOTHING",
+		"UPDATE t SET name = 'x'",
+		"DELETE FROM t",
+		"ANALYZE t",
+	} {
+		_, err := txe.Execute(sql)
+		assertSQLSTATE(t, err, "25006")
+	}
+
+	// Switching back to read-write allows writes again.
+	tx.SetReadOnly(false)
+	exec(t, txe, "INSERT INTO t VALUES (2, 'bob')")
+}
+
+func TestExecutor_Returning(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, score FLOAT)")
+
+	r := exec(t, e, "INSERT INTO t VALUES (1, 'alice', 1), (2, 'bob', 2) RETURNING *")
+	if r.Tag != "INSERT 0 2" {
+		t.Errorf("insert tag = %q, want INSERT 0 2", r.Tag)
+	}
+	if len(r.Columns) != 3 || len(r.Rows) != 2 {
+		t.Fatalf("insert returned %d columns, %d rows; want 3, 2", len(r.Columns), len(r.Rows))
+	}
+	// The integer literal is returned as the stored FLOAT value.
+	if got := string(r.Rows[1][2]); got != "2" || r.Columns[2].TypeOID != OIDFloat8 {
+		t.Errorf("score = %q (oid %d), want 2 as float8", got, r.Columns[2].TypeOID)
+	}
+
+	// UPDATE returns the values after the update.
+	r = exec(t, e, "UPDATE t SET name = 'carol' WHERE id = 2 RETURNING id, name AS new_name, UPPER(name)")
+	if r.Tag != "UPDATE 1" {
+		t.Errorf("update tag = %q, want UPDATE 1", r.Tag)
+	}
+	if r.Columns[1].Name != "new_name" {
+		t.Errorf("column name = %q, want new_name", r.Columns[1].Name)
+	}
+	if len(r.Rows) != 1 || string(r.Rows[0][1]) != "carol" || string(r.Rows[0][2]) != "CAROL" {
+		t.Fatalf("update returned %q, want [2 carol CAROL]", r.Rows)
+	}
+
+	// DELETE returns the removed rows.
+	r = exec(t, e, "DELETE FROM t WHERE id = 1 RETURNING name")
+	if r.Tag != "DELETE 1" || len(r.Rows) != 1 || string(r.Rows[0][0]) != "alice" {
+		t.Fatalf("delete returned %q (tag %q), want [alice]", r.Rows, r.Tag)
+	}
+
+	// No affected rows still describes the columns.
+	r = exec(t, e, "DELETE FROM t WHERE id = 99 RETURNING id")
+	if len(r.Columns) != 1 || len(r.Rows) != 0 || r.Tag != "DELETE 0" {
+		t.Errorf("empty delete: columns %v, rows %q, tag %q", r.Columns, r.Rows, r.Tag)
+	}
+
+	// Without RETURNING, only a tag.
+	r = exec(t, e, "INSERT INTO t VALUES (3, 'dave', 3)")
+	if r.Columns != nil {
+		t.Errorf("columns = %v, want nil without RETURNING", r.Columns)
+	}
+
+	// Columns filled in from their DEFAULT are returned as stored.
+	exec(t, e, "CREATE TABLE d (id INTEGER, status TEXT DEFAULT 'new', created_at TIMESTAMP DEFAULT NOW())")
+	r = exec(t, e, "INSERT INTO d (id) VALUES (1), (2) RETURNING id, status, created_at")
+	if len(r.Rows) != 2 || string(r.Rows[1][1]) != "new" || r.Rows[1][2] == nil {
+		t.Errorf("insert with defaults returned %q, want status new and a created_at", r.Rows)
+	}
+}
+
+func TestExecutor_ReturningErrors(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER, name TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (1, 'alice')")
+
+	// A bad RETURNING list is rejected before any row changes.
+	if _, err := e.Execute("DELETE FROM t RETURNING nope"); err == nil {
+		t.Error("expected error for unknown RETURNING column")
+	}
+	_, err := e.Execute("INSERT INTO t VALUES (2, 'bob') RETURNING COUNT(*)")
+	assertSQLSTATE(t, err, "42803")
+	r := exec(t, e, "SELECT * FROM t")
+	if len(r.Rows) != 1 {
+		t.Errorf("rows = %d, want 1 after rejected statements", len(r.Rows))
+	}
+
+	txe := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	r = exec(t, txe, "UPDATE t SET name = 'x' RETURNING name")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "x" {
+		t.Errorf("update in transaction returned %q, want [x]", r.Rows)
+	}
+}
+
+func TestExecutor_InsertOnConflict(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, email TEXT)")
+	exec(t, e, "CREATE UNIQUE INDEX idx_email ON t (email)")
+	exec(t, e, "INSERT INTO t VALUES (1, 'alice', 'a@x')")
+
+	r := exec(t, e, "INSERT INTO t VALUES (1, 'x', 'x@x'), (2, 'bob', 'b@x') ON CONFLICT DO NOTHING")
+	if r.Tag != "INSERT 0 1" {
+		t.Errorf("do nothing tag = %q, want INSERT 0 1", r.Tag)
+	}
+	// Without a target, a unique index conflict is skipped too.
+	r = exec(t, e, "INSERT INTO t VALUES (3, 'y', 'a@x') ON CONFLICT DO NOTHING")
+	if r.Tag != "INSERT 0 0" {
+		t.Errorf("unique index conflict tag = %q, want INSERT 0 0", r.Tag)
+	}
+
+	r = exec(t, e, "INSERT INTO t VALUES (2, 'b', 'b@x'), (4, 'dave', 'd@x') ON CONFLICT (id) DO UPDATE SET name = 'robert' RETURNING id, name")
+	if r.Tag != "INSERT 0 2" || len(r.Rows) != 2 {
+		t.Fatalf("do update = %q %q, want 2 rows", r.Tag, r.Rows)
+	}
+	if string(r.Rows[0][1]) != "dave" || string(r.Rows[1][1]) != "robert" {
+		t.Errorf("do update returned %q, want dave then robert", r.Rows)
+	}
+
+	r = exec(t, e, "INSERT INTO t VALUES (9, 'z', 'a@x') ON CONFLICT (email) DO UPDATE SET name = 'ally' RETURNING id")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "1" {
+		t.Errorf("conflict on email returned %q, want id 1", r.Rows)
+	}
+
+	_, err := e.Execute("INSERT INTO t VALUES (2, 'a', 'q@x'), (2, 'b', 'r@x') ON CONFLICT (id) DO UPDATE SET name = 'x'")
+	assertSQLSTATE(t, err, "21000")
+	_, err = e.Execute("INSERT INTO t VALUES (5, 'e', 'e@x') ON CONFLICT (name) DO NOTHING")
+	assertSQLSTATE(t, err, "42P10")
+	_, err = e.Execute("INSERT INTO t VALUES (5, 'e', 'e@x') ON CONFLICT DO UPDATE SET name = 'x'")
+	assertSQLSTATE(t, err, "42601")
+
+	r = exec(t, e, "SELECT id, name FROM t ORDER BY id")
+	want := []string{"1 ally", "2 robert", "4 dave"}
+	if len(r.Rows) != len(want) {
+		t.Fatalf("rows = %q, want %v", r.Rows, want)
+	}
+	for i, w := range want {
+		if got := string(r.Rows[i][0]) + " " + string(r.Rows[i][1]); got != w {
+			t.Errorf("row %d = %q, want %q", i, got, w)
+		}
+	}
+
+	// Inside a transaction, conflicts include the transaction's own rows.
+	txe := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	exec(t, txe, "INSERT INTO t VALUES (6, 'f', 'f@x')")
+	r = exec(t, txe, "INSERT INTO t VALUES (6, 'f', 'f@x') ON CONFLICT (id) DO UPDATE SET name = 'frank' RETURNING name")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "frank" {
+		t.Errorf("upsert in transaction returned %q, want [frank]", r.Rows)
+	}
+}
+
+func TestExecutor_ColumnDefault(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER, created_at TIMESTAMP DEFAULT NOW(), status TEXT DEFAULT 'new', score FLOAT DEFAULT 1)")
+
+	exec(t, e, "INSERT INTO t (id) VALUES (1)")
+	exec(t, e, "INSERT INTO t (id, status) VALUES (2, 'old'), (3, NULL)")
+	exec(t, e, "INSERT INTO t VALUES (4)") // short row
+
+	r := exec(t, e, "SELECT id, status, score, created_at IS NOT NULL FROM t ORDER BY id")
+	want := []string{"1 new 1 t", "2 old 1 t", "3 <nil> 1 t", "4 new 1 t"}
+	if len(r.Rows) != len(want) {
+		t.Fatalf("rows = %q, want %v", r.Rows, want)
+	}
+	for i, w := range want {
+		var parts []string
+		for _, v := range r.Rows[i] {
+			if v == nil {
+				parts = append(parts, "<nil>")
+			} else {
+				parts = append(parts, string(v))
+			}
+		}
+		if got := strings.Join(parts, " "); got != w {
+			t.Errorf("row %d = %q, want %q", i, got, w)
+		}
+	}
+
+	// NOW() is evaluated on each use, not once when first parsed.
+	col := storage.ColumnDef{Name: "created_at", DataType: storage.TypeTimestamp, Default: "NOW()"}
+	v1, _ := defaultValue(col)
+	time.Sleep(time.Millisecond)
+	v2, _ := defaultValue(col)
+	if !v2.(time.Time).After(v1.(time.Time)) {
+		t.Errorf("NOW() default = %v then %v, want a later time", v1, v2)
+	}
+
+	r = exec(t, e, "SELECT column_name, column_default FROM information_schema.columns WHERE table_name = 't' ORDER BY ordinal_position")
+	if r.Rows[0][1] != nil || string(r.Rows[2][1]) != "'new'" || string(r.Rows[1][1]) != "NOW()" {
+		t.Errorf("column_default = %q, want NULL, NOW(), 'new'", r.Rows)
+	}
+
+	// A default that cannot be stored in the column is rejected up front.
+	_, err := e.Execute("CREATE TABLE bad (n INTEGER DEFAULT 'abc')")
+	assertSQLSTATE(t, err, "22P02")
+	_, err = e.Execute("ALTER TABLE t ADD COLUMN x INTEGER DEFAULT 'abc'")
+	assertSQLSTATE(t, err, "22P02")
+}
+
+func TestExecutor_DefaultKeyword(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER DEFAULT 0, status TEXT DEFAULT 'new', note TEXT)")
+
+	exec(t, e, "INSERT INTO t VALUES (1, DEFAULT, DEFAULT)")
+	exec(t, e, "INSERT INTO t (status, id) VALUES (DEFAULT, 2)")
+	r := exec(t, e, "INSERT INTO t DEFAULT VALUES RETURNING id, status, note")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "0" || string(r.Rows[0][1]) != "new" || r.Rows[0][2] != nil {
+		t.Errorf("DEFAULT VALUES returned %q, want [[0 new NULL]]", r.Rows)
+	}
+
+	exec(t, e, "UPDATE t SET status = 'done', note = 'x' WHERE id = 1")
+	exec(t, e, "UPDATE t SET status = DEFAULT, note = DEFAULT WHERE id = 1")
+	r = exec(t, e, "SELECT id, status, note FROM t ORDER BY id")
+	want := [][]any{{"0", "new", nil}, {"1", "new", nil}, {"2", "new", nil}}
+	for i, w := range want {
+		for j, v := range w {
+			if got := r.Rows[i][j]; (got == nil) != (v == nil) || v != nil && string(got) != v {
+				t.Errorf("row %d column %d = %q, want %v", i, j, got, v)
+			}
+		}
+	}
+}
+
+func TestExecutor_Serial(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id SERIAL PRIMARY KEY, name TEXT)")
+
+	r := exec(t, e, "INSERT INTO t (name) VALUES ('a'), ('b') RETURNING id")
+	if len(r.Rows) != 2 || string(r.Rows[0][0]) != "1" || string(r.Rows[1][0]) != "2" {
+		t.Fatalf("RETURNING id = %q, want 1, 2", r.Rows)
+	}
+	exec(t, e, "INSERT INTO t VALUES (DEFAULT, 'c')")
+	exec(t, e, "INSERT INTO t VALUES (10, 'd')")
+	r = exec(t, e, "INSERT INTO t (name) VALUES ('e') RETURNING id")
+	if string(r.Rows[0][0]) != "11" {
+		t.Errorf("id after explicit 10 = %q, want 11", r.Rows[0][0])
+	}
+
+	// Ids of deleted rows are not handed out again.
+	exec(t, e, "DELETE FROM t WHERE id = 11")
+	r = exec(t, e, "INSERT INTO t (name) VALUES ('f') RETURNING id")
+	if string(r.Rows[0][0]) != "12" {
+		t.Errorf("id after deleting 11 = %q, want 12", r.Rows[0][0])
+	}
+
+	r = exec(t, e, "SELECT is_nullable, is_identity, identity_generation FROM information_schema.columns WHERE table_name = 't' ORDER BY ordinal_position")
+	if string(r.Rows[0][0]) != "NO" || string(r.Rows[0][1]) != "YES" || string(r.Rows[0][2]) != "BY DEFAULT" {
+		t.Errorf("id column = %q, want NO YES BY DEFAULT", r.Rows[0])
+	}
+	if string(r.Rows[1][1]) != "NO" || r.Rows[1][2] != nil {
+		t.Errorf("name column = %q, want NO NULL", r.Rows[1][1:])
+	}
+}
+
+func TestExecutor_IdentityAlways(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER GENERATED ALWAYS AS IDENTITY, name TEXT)")
+
+	exec(t, e, "INSERT INTO t (name) VALUES ('a')")
+	exec(t, e, "INSERT INTO t VALUES (DEFAULT, 'b')")
+	_, err := e.Execute("INSERT INTO t (id, name) VALUES (5, 'c')")
+	assertSQLSTATE(t, err, "428C9")
+
+	r := exec(t, e, "SELECT id, name FROM t ORDER BY id")
+	if len(r.Rows) != 2 || string(r.Rows[0][0]) != "1" || string(r.Rows[1][0]) != "2" {
+		t.Errorf("rows = %q, want ids 1, 2", r.Rows)
+	}
+
+	_, err = e.Execute("ALTER TABLE t ADD COLUMN n SERIAL")
+	assertSQLSTATE(t, err, "0A000")
+}
+
+func TestExecutor_SerialInTransaction(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id SERIAL PRIMARY KEY, name TEXT)")
+
+	tx := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	r := exec(t, tx, "INSERT INTO t (name) VALUES ('a') RETURNING id")
+	if string(r.Rows[0][0]) != "1" {
+		t.Fatalf("id in transaction = %q, want 1", r.Rows[0][0])
+	}
+	// Values handed to the transaction are not handed out again.
+	r = exec(t, e, "INSERT INTO t (name) VALUES ('b') RETURNING id")
+	if string(r.Rows[0][0]) != "2" {
+		t.Errorf("id outside transaction = %q, want 2", r.Rows[0][0])
+	}
+}
+
 func TestExecutor_DropTable(t *testing.T) {
 	e := setup(t)
 	exec(t, e, "CREATE TABLE t (id INTEGER)")
@@ -196,6 +759,80 @@ func TestExecutor_TypeOIDs(t *testing.T) {
 	}
 }
 
+func TestFormatValue(t *testing.T) {
+	num, err := storage.ParseNumeric("-12.50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{int64(-42), "-42"},
+		{1.5, "1.5"},
+		{1e20, "1e+20"},
+		{"it's", "it's"},
+		{true, "t"},
+		{false, "f"},
+		{time.Date(2024, 3, 1, 9, 5, 0, 0, time.UTC), "2024-03-01 09:05:00+00"},
+		{time.Date(2024, 3, 1, 9, 5, 0, 120000000, time.UTC), "2024-03-01 09:05:00.12+00"},
+		{time.Date(2024, 3, 1, 9, 5, 0, 123456000, time.UTC), "2024-03-01 09:05:00.123456+00"},
+		{[]byte{0xde, 0xad}, `\xdead`},
+		{num, "-12.50"},
+	}
+	for _, tt := range tests {
+		if got, err := formatValue(tt.v); err != nil || string(got) != tt.want {
+			t.Errorf("formatValue(%#v) = %q, %v; want %q", tt.v, got, err, tt.want)
+		}
+	}
+	if got, err := formatValue(nil); got != nil || err != nil {
+		t.Errorf("formatValue(nil) = %q, %v; want NULL", got, err)
+	}
+
+	// A value of a type no column has is a bug: it fails the query rather
+	// than reaching the client in its Go formatting.
+	_, err = formatValue(int32(1))
+	assertSQLSTATE(t, err, "XX000")
+	_, err = formatRow(storage.Row{}, []exprFunc{func(storage.Row) any { return int32(1) }})
+	assertSQLSTATE(t, err, "XX000")
+	_, err = formatNest([][]any{{"a"}, {int32(1)}})
+	assertSQLSTATE(t, err, "XX000")
+}
+
+func TestFormatValue_EveryType(t *testing.T) {
+	// A literal of each column type and its text. A new type fails here
+	// until it has a sample, and then until formatValue handles it.
+	samples := map[storage.DataType][2]string{
+		storage.TypeInteger:   {"7", "7"},
+		storage.TypeText:      {"'x'", "x"},
+		storage.TypeBoolean:   {"TRUE", "t"},
+		storage.TypeTimestamp: {"'2024-03-01 09:05:00.5'", "2024-03-01 09:05:00.5+00"},
+		storage.TypeFloat:     {"0.25", "0.25"},
+		storage.TypeBytea:     {`'\x01ff'`, `\x01ff`},
+		storage.TypeNumeric:   {"1.25", "1.25"},
+		storage.TypeTime:      {"'09:05:00.5'", "09:05:00.5"},
+	}
+	var cols, lits, want []string
+	for dt := storage.DataType(0); dt.String() != "UNKNOWN"; dt++ {
+		s, ok := samples[dt]
+		if !ok {
+			t.Fatalf("no sample for type %s", dt)
+		}
+		cols = append(cols, fmt.Sprintf("c%d %s", dt, dt))
+		lits = append(lits, s[0])
+		want = append(want, s[1])
+	}
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t ("+strings.Join(cols, ", ")+")")
+	exec(t, e, "INSERT INTO t VALUES ("+strings.Join(lits, ", ")+")")
+	r := exec(t, e, "SELECT * FROM t")
+	for i, cell := range r.Rows[0] {
+		if string(cell) != want[i] {
+			t.Errorf("%s = %q, want %q", cols[i], cell, want[i])
+		}
+	}
+}
+
 func TestExecutor_Errors(t *testing.T) {
 	e := setup(t)
 
@@ -277,6 +914,67 @@ func TestExecutor_StaticSelect_MultipleTypes(t *testing.T) {
 	}
 }
 
+func TestExecutor_Values(t *testing.T) {
+	e := setup(t)
+	r := exec(t, e, "VALUES (1, NULL, 'x'), (2, 2.5, 'y'), (1 + 2, 0.5, UPPER('z'))")
+	if r.Tag != "SELECT 3" {
+		t.Errorf("tag = %q, want SELECT 3", r.Tag)
+	}
+	wantCols := []Column{
+		{Name: "column1", TypeOID: OIDInt8, TypeSize: 8},
+		{Name: "column2", TypeOID: OIDFloat8, TypeSize: 8},
+		{Name: "column3", TypeOID: OIDText, TypeSize: -1},
+	}
+	for i, want := range wantCols {
+		if r.Columns[i] != want {
+			t.Errorf("column %d = %+v, want %+v", i, r.Columns[i], want)
+		}
+	}
+	if r.Rows[0][1] != nil {
+		t.Errorf("row 0 column2 = %q, want NULL", r.Rows[0][1])
+	}
+	if got := string(r.Rows[2][0]) + " " + string(r.Rows[2][1]) + " " + string(r.Rows[2][2]); got != "3 0.5 Z" {
+		t.Errorf("row 2 = %q, want 3 0.5 Z", got)
+	}
+
+	_, err := e.Execute("VALUES (1), (2, 3)")
+	assertSQLSTATE(t, err, "42601")
+}
+
+func TestExecutor_Values_MixedRowTypes(t *testing.T) {
+	e := setup(t)
+
+	// Each column's type comes from all of its rows, not the first.
+	r := exec(t, e, "VALUES (NULL, 1, '1', NULL), (2, 2.5, 2, NULL), (3, 3, '3', 'x')")
+	wantCols := []Column{
+		{Name: "column1", TypeOID: OIDInt8, TypeSize: 8},
+		{Name: "column2", TypeOID: OIDFloat8, TypeSize: 8},
+		{Name: "column3", TypeOID: OIDInt8, TypeSize: 8},
+		{Name: "column4", TypeOID: OIDText, TypeSize: -1},
+	}
+	for i, want := range wantCols {
+		if r.Columns[i] != want {
+			t.Errorf("column %d = %+v, want %+v", i, r.Columns[i], want)
+		}
+	}
+	if got := string(r.Rows[0][1]) + " " + string(r.Rows[2][1]); got != "1 3" {
+		t.Errorf("column2 = %q, want 1 3", got)
+	}
+	if got := string(r.Rows[0][2]) + " " + string(r.Rows[2][2]); got != "1 3" {
+		t.Errorf("column3 = %q, want 1 3", got)
+	}
+
+	r = exec(t, e, "VALUES (NULL), (NULL)")
+	if r.Columns[0].TypeOID != OIDText {
+		t.Errorf("all-NULL column type = %d, want TEXT", r.Columns[0].TypeOID)
+	}
+
+	_, err := e.Execute("VALUES (1), (TRUE)")
+	assertSQLSTATE(t, err, "42804")
+	_, err = e.Execute("VALUES (1), ('x')")
+	assertSQLSTATE(t, err, "22P02")
+}
+
 func TestExecutor_StaticSelect_Version(t *testing.T) {
 	e := setup(t)
 	r := exec(t, e, "SELECT VERSION()")
@@ -765,11 +1463,8 @@ func TestExecutor_SelectNegativeLimit(t *testing.T) {
 	exec(t, e, "CREATE TABLE t (id INTEGER)")
 	exec(t, e, "INSERT INTO t VALUES (1)")
 
-	// Negative values are rejected at parse time (lexer produces ILLEGAL for '-').
 	_, err := e.Execute("SELECT * FROM t LIMIT -1")
-	if err == nil {
-		t.Fatal("expected error for negative LIMIT")
-	}
+	assertSQLSTATE(t, err, "2201W")
 }
 
 func TestExecutor_SelectNegativeOffset(t *testing.T) {
@@ -778,8 +1473,35 @@ func TestExecutor_SelectNegativeOffset(t *testing.T) {
 	exec(t, e, "INSERT INTO t VALUES (1)")
 
 	_, err := e.Execute("SELECT * FROM t OFFSET -1")
-	if err == nil {
-		t.Fatal("expected error for negative OFFSET")
+	assertSQLSTATE(t, err, "2201X")
+}
+
+func TestExecutor_SelectLimitExpr(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER)")
+	exec(t, e, "INSERT INTO t VALUES (1), (2), (3), (4), (5)")
+
+	r := exec(t, e, "SELECT * FROM t LIMIT 1 + 1 OFFSET ABS(-2)")
+	if len(r.Rows) != 2 {
+		t.Fatalf("rows = %d, want 2", len(r.Rows))
+	}
+
+	tests := []struct {
+		sql  string
+		code string
+	}{
+		{"SELECT * FROM t LIMIT 1 - 3", "2201W"},
+		{"SELECT * FROM t LIMIT NULL", "2201W"},
+		{"SELECT * FROM t LIMIT ABS(NULL)", "2201W"},
+		{"SELECT * FROM t OFFSET 0 - 1", "2201X"},
+		{"SELECT * FROM t OFFSET NULL", "2201X"},
+		{"SELECT * FROM t LIMIT 'a'", "42804"},
+		{"SELECT id, COUNT(*) FROM t GROUP BY id LIMIT 2 - 5", "2201W"},
+		{"SELECT * FROM t a JOIN t b ON a.id = b.id OFFSET NULL", "2201X"},
+	}
+	for _, tt := range tests {
+		_, err := e.Execute(tt.sql)
+		assertSQLSTATE(t, err, tt.code)
 	}
 }
 
@@ -909,6 +1631,98 @@ func TestExecutor_PrimaryKey_LimitWithIndex(t *testing.T) {
 	}
 }
 
+func TestExecutor_PrimaryKey_Range(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, v TEXT)")
+	// Keys 1 to 30, inserted out of key order, so that key order differs
+	// from row order; enough rows for the planner to prefer the index.
+	var values []string
+	for i := 1; i <= 30; i++ {
+		id := i * 7 % 31
+		values = append(values, fmt.Sprintf("(%d, 'v%d')", id, id))
+	}
+	exec(t, e, "INSERT INTO t VALUES "+strings.Join(values, ", "))
+	exec(t, e, "DELETE FROM t WHERE id = 6")
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT id FROM t WHERE id > 26", "27,28,29,30"},
+		{"SELECT id FROM t WHERE id >= 27", "27,28,29,30"},
+		{"SELECT id FROM t WHERE id < 3", "1,2"},
+		{"SELECT id FROM t WHERE 3 >= id", "1,2,3"},
+		{"SELECT id FROM t WHERE id BETWEEN 4 AND 7", "4,5,7"},
+		{"SELECT id FROM t WHERE id > 2 AND id <= 5 AND v <> 'v4'", "3,5"},
+		{"SELECT id FROM t WHERE id > 2 AND id > 27", "28,29,30"},
+		{"SELECT id FROM t WHERE id > '27'", "28,29,30"},
+		{"SELECT id FROM t WHERE id > 5 AND id < 5", ""},
+		{"SELECT id FROM t WHERE id >= 2 LIMIT 2 OFFSET 1", "3,4"},
+		{"SELECT id FROM t ORDER BY id LIMIT 3", "1,2,3"},
+		{"SELECT id FROM t WHERE id < 4 ORDER BY id DESC", "3,2,1"},
+		{"SELECT id FROM t WHERE id NOT BETWEEN 2 AND 28 ORDER BY id", "1,29,30"},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		ids := make([]string, len(r.Rows))
+		for i, row := range r.Rows {
+			ids[i] = string(row[0])
+		}
+		if got := strings.Join(ids, ","); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.sql, got, tt.want)
+		}
+	}
+
+	_, tr, err := e.ExecuteTraced("SELECT * FROM t WHERE id > 26")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.IndexName != "PRIMARY" || tr.RowsScanned != 4 {
+		t.Errorf("trace: IndexName = %q, RowsScanned = %d; want PRIMARY, 4", tr.IndexName, tr.RowsScanned)
+	}
+
+	// Aggregates and GROUP BY read only the range as well.
+	aggTests := []struct {
+		sql     string
+		want    string
+		scanned int64
+	}{
+		{"SELECT COUNT(*) FROM t WHERE id > 1 AND id < 5", "3", 3},
+		{"SELECT SUM(id) FROM t WHERE id BETWEEN 27 AND 100", "114", 4},
+		{"SELECT v, COUNT(*) FROM t WHERE id <= 2 GROUP BY v ORDER BY v", "v1", 2},
+	}
+	for _, tt := range aggTests {
+		r, tr, err := e.ExecuteTraced(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := string(r.Rows[0][0]); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.sql, got, tt.want)
+		}
+		if tr.IndexName != "PRIMARY" || tr.RowsScanned != tt.scanned {
+			t.Errorf("%s: IndexName = %q, RowsScanned = %d; want PRIMARY, %d", tt.sql, tr.IndexName, tr.RowsScanned, tt.scanned)
+		}
+	}
+
+	// Inside a transaction the range sees the transaction's own changes.
+	tx := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	exec(t, tx, "INSERT INTO t VALUES (31, 'v31')")
+	exec(t, tx, "UPDATE t SET id = 0 WHERE id = 28")
+	exec(t, tx, "DELETE FROM t WHERE id = 29")
+	r := exec(t, tx, "SELECT id FROM t WHERE id > 26")
+	ids := make([]string, len(r.Rows))
+	for i, row := range r.Rows {
+		ids[i] = string(row[0])
+	}
+	if got := strings.Join(ids, ","); got != "27,30,31" {
+		t.Errorf("in transaction: ids = %s, want 27,30,31", got)
+	}
+	r = exec(t, tx, "SELECT id FROM t WHERE id < 2 ORDER BY id")
+	if len(r.Rows) != 2 || string(r.Rows[0][0]) != "0" || string(r.Rows[1][0]) != "1" {
+		t.Errorf("in transaction: rows = %v, want 0 and 1", r.Rows)
+	}
+}
+
 // -------------------------------------------------------------------------
 // ExecuteTraced
 // -------------------------------------------------------------------------
@@ -1248,42 +2062,38 @@ func TestExecutor_OrderBy_MultiColumn(t *testing.T) {
 	}
 }
 
-func TestExecutor_OrderBy_NullsLast(t *testing.T) {
+func TestExecutor_OrderBy_Nulls(t *testing.T) {
 	e := setup(t)
 	exec(t, e, "CREATE TABLE t (id INTEGER, score INTEGER)")
 	exec(t, e, "INSERT INTO t VALUES (1, 90), (2, NULL), (3, 70), (4, NULL)")
+	exec(t, e, "CREATE TABLE u (id INTEGER, t_id INTEGER)")
+	exec(t, e, "INSERT INTO u VALUES (10, 1), (20, 2), (30, 3), (40, 4)")
 
-	// ASC: NULLs should be last.
-	r := exec(t, e, "SELECT * FROM t ORDER BY score")
-	if len(r.Rows) != 4 {
-		t.Fatalf("rows = %d, want 4", len(r.Rows))
-	}
-	if string(r.Rows[0][1]) != "70" {
-		t.Errorf("row[0].score = %q, want 70", r.Rows[0][1])
-	}
-	if string(r.Rows[1][1]) != "90" {
-		t.Errorf("row[1].score = %q, want 90", r.Rows[1][1])
-	}
-	if r.Rows[2][1] != nil {
-		t.Errorf("row[2].score = %q, want NULL", r.Rows[2][1])
-	}
-	if r.Rows[3][1] != nil {
-		t.Errorf("row[3].score = %q, want NULL", r.Rows[3][1])
-	}
-
-	// DESC: NULLs should still be last.
-	r = exec(t, e, "SELECT * FROM t ORDER BY score DESC")
-	if string(r.Rows[0][1]) != "90" {
-		t.Errorf("desc row[0].score = %q, want 90", r.Rows[0][1])
-	}
-	if string(r.Rows[1][1]) != "70" {
-		t.Errorf("desc row[1].score = %q, want 70", r.Rows[1][1])
-	}
-	if r.Rows[2][1] != nil {
-		t.Errorf("desc row[2].score = %q, want NULL", r.Rows[2][1])
+	// By default NULLs sort as larger than any value: last ascending and
+	// first descending. NULLS FIRST and NULLS LAST override that per key.
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id FROM t ORDER BY score, id", []string{"3", "1", "2", "4"}},
+		{"SELECT id FROM t ORDER BY score DESC, id", []string{"2", "4", "1", "3"}},
+		{"SELECT id FROM t ORDER BY score NULLS FIRST, id", []string{"2", "4", "3", "1"}},
+		{"SELECT id FROM t ORDER BY score ASC NULLS LAST, id DESC", []string{"3", "1", "4", "2"}},
+		{"SELECT id FROM t ORDER BY score DESC NULLS LAST, id", []string{"1", "3", "2", "4"}},
+		{"SELECT id FROM t ORDER BY score + 0 DESC NULLS LAST, id", []string{"1", "3", "2", "4"}},
+		{"SELECT u.id FROM u JOIN t ON u.t_id = t.id ORDER BY t.score NULLS FIRST, u.id", []string{"20", "40", "30", "10"}},
+		{"SELECT score FROM t GROUP BY score ORDER BY score DESC", []string{"", "90", "70"}},
+		{"SELECT score FROM t GROUP BY score ORDER BY score NULLS FIRST", []string{"", "70", "90"}},
 	}
-	if r.Rows[3][1] != nil {
-		t.Errorf("desc row[3].score = %q, want NULL", r.Rows[3][1])
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		var got []string
+		for _, row := range r.Rows {
+			got = append(got, string(row[0]))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
 	}
 }
 
@@ -1302,6 +2112,52 @@ func TestExecutor_OrderBy_WithLimitOffset(t *testing.T) {
 	}
 }
 
+func TestExecutor_OrderBy_PositionAndExpr(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price INTEGER, qty INTEGER)")
+	exec(t, e, "INSERT INTO items VALUES (1, 'pen', 2, 10), (2, 'ink', 15, 1), (3, 'pad', 4, 3), (4, 'cap', 1, NULL)")
+	exec(t, e, "CREATE TABLE sales (item_id INTEGER, region TEXT)")
+	exec(t, e, "INSERT INTO sales VALUES (1, 'north'), (2, 'south'), (3, 'north'), (1, 'south')")
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id, name FROM items ORDER BY 2", []string{"4", "2", "3", "1"}},
+		{"SELECT id, price * qty AS total FROM items ORDER BY 2 DESC", []string{"4", "1", "2", "3"}},
+		{"SELECT * FROM items ORDER BY 3 DESC LIMIT 2", []string{"2", "3"}},
+		{"SELECT id FROM items ORDER BY price * qty DESC NULLS LAST", []string{"1", "2", "3", "4"}},
+		{"SELECT id FROM items WHERE id > 1 ORDER BY price * qty", []string{"3", "2", "4"}},
+		{"SELECT region, COUNT(*) FROM sales GROUP BY region ORDER BY 1 DESC", []string{"south", "north"}},
+		{"SELECT s.region, i.id FROM sales s JOIN items i ON i.id = s.item_id ORDER BY 1, 2 DESC", []string{"north", "north", "south", "south"}},
+		{"SELECT i.id FROM sales s JOIN items i ON i.id = s.item_id ORDER BY i.price * i.qty DESC, s.region", []string{"1", "1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		if got := ids(r); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+
+	r := exec(t, e, "SELECT s.region, i.id FROM sales s JOIN items i ON i.id = s.item_id ORDER BY 1, 2 DESC")
+	var got []string
+	for _, row := range r.Rows {
+		got = append(got, string(row[1]))
+	}
+	if want := []string{"3", "1", "2", "1"}; !slices.Equal(got, want) {
+		t.Errorf("join ORDER BY 1, 2 DESC ids = %v, want %v", got, want)
+	}
+
+	for _, sql := range []string{
+		"SELECT id, name FROM items ORDER BY 3",
+		"SELECT region, COUNT(*) FROM sales GROUP BY region ORDER BY 3",
+		"SELECT s.region FROM sales s JOIN items i ON i.id = s.item_id ORDER BY 2",
+	} {
+		_, err := e.Execute(sql)
+		assertSQLSTATE(t, err, "42P10")
+	}
+}
+
 func TestExecutor_OrderBy_NonexistentColumn(t *testing.T) {
 	e := setup(t)
 	exec(t, e, "CREATE TABLE t (id INTEGER, name TEXT)")
@@ -1843,6 +2699,28 @@ func TestExecutor_JoinAmbiguousColumn(t *testing.T) {
 	}
 }
 
+func TestExecutor_JoinDuplicateAlias(t *testing.T) {
+	e := setup(t)
+	setupJoinTables(t, e)
+
+	for _, sql := range []string{
+		"SELECT a.id FROM orders a JOIN items a ON a.id = a.order_id",
+		"SELECT o.id FROM orders o, items O",
+		"SELECT orders.id FROM orders JOIN orders ON orders.id = orders.id",
+		"SELECT orders.id FROM orders JOIN items orders ON orders.id = orders.order_id",
+		"EXPLAIN SELECT x.id FROM orders x JOIN items y ON x.id = y.order_id JOIN orders x ON x.id = y.id",
+	} {
+		_, err := e.Execute(sql)
+		assertSQLSTATE(t, err, "42712")
+	}
+
+	// A self-join with distinct aliases is fine.
+	r := exec(t, e, "SELECT a.id, b.id FROM orders a JOIN orders b ON a.id = b.id")
+	if len(r.Rows) == 0 {
+		t.Error("self-join returned no rows")
+	}
+}
+
 func TestExecutor_JoinOrderBy(t *testing.T) {
 	e := setup(t)
 	setupJoinTables(t, e)
@@ -2252,6 +3130,36 @@ func TestExecutor_LikeDynamicPattern(t *testing.T) {
 	}
 }
 
+func TestExecutor_SimilarTo(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (name TEXT, pat TEXT)")
+	exec(t, e, "INSERT INTO t (name, pat) VALUES ('apple', '(a|b)%'), ('banana', 'b_'), ('cherry', NULL), ('a.c', 'a.c')")
+
+	names := func(r *Result) string {
+		var out []string
+		for _, row := range r.Rows {
+			out = append(out, string(row[0]))
+		}
+		return strings.Join(out, ",")
+	}
+	for _, tc := range []struct{ sql, want string }{
+		{"SELECT name FROM t WHERE name SIMILAR TO '(a|b)%' ORDER BY name", "a.c,apple,banana"},
+		{"SELECT name FROM t WHERE name NOT SIMILAR TO '(a|b)%' ORDER BY name", "cherry"},
+		{"SELECT name FROM t WHERE name SIMILAR TO '[a-c][a-z]+' ORDER BY name", "apple,banana,cherry"},
+		{"SELECT name FROM t WHERE name SIMILAR TO pat ORDER BY name", "a.c,apple"},
+		{"SELECT name FROM t WHERE name SIMILAR TO 'a#.c' ESCAPE '#'", "a.c"},
+		// A NULL operand yields NULL, so cherry matches neither way.
+		{"SELECT name FROM t WHERE pat NOT SIMILAR TO 'zzz' ORDER BY name", "a.c,apple,banana"},
+	} {
+		if got := names(exec(t, e, tc.sql)); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.sql, got, tc.want)
+		}
+	}
+
+	_, err := e.Execute("SELECT name FROM t WHERE name SIMILAR TO '(a'")
+	assertSQLSTATE(t, err, "2201B")
+}
+
 // -------------------------------------------------------------------------
 // IN / NOT IN predicate
 // -------------------------------------------------------------------------
@@ -2826,6 +3734,336 @@ func TestExecutor_FloatConcatCoercion(t *testing.T) {
 	}
 }
 
+func TestExecutor_Bytea(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE blobs (id INTEGER PRIMARY KEY, data BYTEA)")
+	exec(t, e, `INSERT INTO blobs VALUES (1, '\xdeadbeef'), (2, '\x0102'), (3, 'ab\\c')`)
+
+	r := exec(t, e, "SELECT data FROM blobs ORDER BY data")
+	if r.Columns[0].TypeOID != OIDBytea {
+		t.Errorf("column OID = %d, want %d (OIDBytea)", r.Columns[0].TypeOID, OIDBytea)
+	}
+	want := []string{`\x0102`, `\x61625c63`, `\xdeadbeef`}
+	if len(r.Rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(r.Rows), len(want))
+	}
+	for i, w := range want {
+		if string(r.Rows[i][0]) != w {
+			t.Errorf("row %d = %q, want %q", i, r.Rows[i][0], w)
+		}
+	}
+
+	r = exec(t, e, `SELECT id FROM blobs WHERE data = '\xDEADBEEF'`)
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "1" {
+		t.Errorf("equality filter = %q, want id 1", r.Rows)
+	}
+	r = exec(t, e, `SELECT '\x41'::bytea`)
+	if r.Columns[0].TypeOID != OIDBytea || string(r.Rows[0][0]) != `\x41` {
+		t.Errorf("cast = %q (OID %d), want \\x41", r.Rows[0][0], r.Columns[0].TypeOID)
+	}
+
+	_, err := e.Execute(`INSERT INTO blobs VALUES (4, '\xabc')`)
+	if err == nil {
+		t.Error("expected error for odd-length hex input")
+	}
+
+	// BYTEA works as a key: duplicates are rejected.
+	exec(t, e, "CREATE TABLE keyed (k BYTEA PRIMARY KEY)")
+	_, err = e.Execute(`INSERT INTO keyed VALUES ('\x01'), ('\x01')`)
+	assertSQLSTATE(t, err, "23505")
+}
+
+func TestExecutor_Numeric(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE items (id INTEGER PRIMARY KEY, price NUMERIC(8,2), qty DECIMAL)")
+	exec(t, e, "INSERT INTO items VALUES (1, 0.1, 3), (2, 0.2, '1.5'), (3, '19.999', 2), (4, NULL, NULL)")
+
+	r := exec(t, e, "SELECT price, qty FROM items ORDER BY id")
+	if r.Columns[0].TypeOID != OIDNumeric {
+		t.Errorf("column OID = %d, want %d (OIDNumeric)", r.Columns[0].TypeOID, OIDNumeric)
+	}
+	want := [][2]string{{"0.10", "3"}, {"0.20", "1.5"}, {"20.00", "2"}}
+	for i, w := range want {
+		if string(r.Rows[i][0]) != w[0] || string(r.Rows[i][1]) != w[1] {
+			t.Errorf("row %d = %q, want %q", i, r.Rows[i], w)
+		}
+	}
+
+	// Arithmetic stays exact and keeps the operands' scale.
+	r = exec(t, e, "SELECT price + 0.2, price * qty, -price, price / 3, price % 0.15 FROM items WHERE id = 1")
+	for i, w := range []string{"0.30", "0.30", "-0.10", "0.03333333333333333333", "0.10"} {
+		if got := string(r.Rows[0][i]); got != w {
+			t.Errorf("expression %d = %s, want %s", i, got, w)
+		}
+	}
+	if r.Columns[0].TypeOID != OIDNumeric {
+		t.Errorf("price + 0.2 OID = %d, want %d", r.Columns[0].TypeOID, OIDNumeric)
+	}
+	// A decimal literal multiplies exactly rather than through float64.
+	r = exec(t, e, "SELECT price * 1.1, price * 1.1 * 3 FROM items WHERE id = 3")
+	if string(r.Rows[0][0]) != "22.000" || string(r.Rows[0][1]) != "66.000" {
+		t.Errorf("price * 1.1 = %q, want 22.000 and 66.000", r.Rows[0])
+	}
+	r = exec(t, e, "SELECT price / 0 FROM items WHERE id = 1")
+	if r.Rows[0][0] != nil {
+		t.Errorf("price / 0 = %q, want NULL", r.Rows[0][0])
+	}
+
+	// Comparisons against literals are exact.
+	r = exec(t, e, "SELECT id FROM items WHERE price + 0.2 = 0.3 OR price = 20")
+	if len(r.Rows) != 2 || string(r.Rows[0][0]) != "1" || string(r.Rows[1][0]) != "3" {
+		t.Errorf("filter = %q, want ids 1 and 3", r.Rows)
+	}
+
+	// SUM and AVG do not drift through float64.
+	exec(t, e, "CREATE TABLE cents (v NUMERIC(4,2))")
+	for i := 0; i < 10; i++ {
+		exec(t, e, "INSERT INTO cents VALUES (0.1)")
+	}
+	r = exec(t, e, "SELECT SUM(v), AVG(v), MIN(v), MAX(v) FROM cents")
+	for i, w := range []string{"1.00", "0.10000000000000000000", "0.10", "0.10"} {
+		if got := string(r.Rows[0][i]); got != w {
+			t.Errorf("aggregate %d = %s, want %s", i, got, w)
+		}
+	}
+	if r.Columns[0].TypeOID != OIDNumeric || r.Columns[1].TypeOID != OIDNumeric {
+		t.Errorf("SUM/AVG OIDs = %d/%d, want NUMERIC", r.Columns[0].TypeOID, r.Columns[1].TypeOID)
+	}
+	r = exec(t, e, "SELECT v, SUM(v), AVG(v) FROM cents GROUP BY v")
+	if len(r.Rows) != 1 || string(r.Rows[0][1]) != "1.00" || string(r.Rows[0][2]) != "0.10000000000000000000" {
+		t.Errorf("grouped SUM/AVG = %q, want 1.00 and 0.1", r.Rows)
+	}
+
+	// Casts.
+	r = exec(t, e, "SELECT '1.50'::numeric, 2.5::decimal, price::integer FROM items WHERE id = 3")
+	if string(r.Rows[0][0]) != "1.50" || string(r.Rows[0][1]) != "2.5" || string(r.Rows[0][2]) != "20" {
+		t.Errorf("casts = %q", r.Rows[0])
+	}
+
+	// Values outside the declared precision are rejected.
+	_, err := e.Execute("INSERT INTO items VALUES (5, 1000000, 1)")
+	assertSQLSTATE(t, err, "22003")
+	if _, err = e.Execute("INSERT INTO items VALUES (5, 'abc', 1)"); err == nil {
+		t.Error("expected error for non-numeric input")
+	}
+	_, err = e.Execute("SELECT SUM(id) FROM items WHERE price = 'abc'")
+	assertSQLSTATE(t, err, "22P02")
+
+	// Equal values with different scales are duplicates.
+	exec(t, e, "CREATE TABLE keyed (k NUMERIC PRIMARY KEY)")
+	_, err = e.Execute("INSERT INTO keyed VALUES ('1.0'), ('1.00')")
+	assertSQLSTATE(t, err, "23505")
+}
+
+func TestExecutor_NumericMixedComparison(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE m (id INTEGER PRIMARY KEY, n NUMERIC, f FLOAT, i INTEGER)")
+	exec(t, e, `INSERT INTO m VALUES
+		(1, '0.30000000000000001', 0.3, 0),
+		(2, '9007199254740993', 9007199254740992, 9007199254740992),
+		(3, '0.5', 0.5, 1)`)
+
+	// Converted to float64, n would equal f and i in rows 1 and 2.
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		{"n = f", []string{"3"}},
+		{"n > f", []string{"1", "2"}},
+		{"f < n", []string{"1", "2"}},
+		{"n > i", []string{"1", "2"}},
+		{"n = i", nil},
+		{"n > 0.3 * 1", []string{"1", "2", "3"}},
+		{"n = 0.3", nil},
+		{"n IN (0.5, 2)", []string{"3"}},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, "SELECT id FROM m WHERE "+tt.where+" ORDER BY id")
+		if got := ids(r); !slices.Equal(got, tt.want) {
+			t.Errorf("WHERE %s: ids = %v, want %v", tt.where, got, tt.want)
+		}
+	}
+}
+
+func TestExecutor_NumericCastTypmod(t *testing.T) {
+	e := setup(t)
+
+	// A cast to NUMERIC(p,s) rounds to the scale, in both spellings.
+	r := exec(t, e, "SELECT CAST(0.125 AS NUMERIC(10,2)), 0.1::NUMERIC(10,2), CAST('7' AS DECIMAL(3))")
+	for i, want := range []string{"0.13", "0.10", "7"} {
+		if got := string(r.Rows[0][i]); got != want {
+			t.Errorf("column %d = %s, want %s", i, got, want)
+		}
+	}
+	_, err := e.Execute("SELECT CAST(1000 AS NUMERIC(3,1))")
+	assertSQLSTATE(t, err, "22003")
+
+	exec(t, e, "CREATE TABLE p (id INTEGER PRIMARY KEY, price NUMERIC)")
+	exec(t, e, "INSERT INTO p VALUES (1, CAST(2.345 AS NUMERIC(5,2)))")
+	r = exec(t, e, "SELECT price, CAST(price AS NUMERIC(4,1)) FROM p")
+	if string(r.Rows[0][0]) != "2.35" || string(r.Rows[0][1]) != "2.4" {
+		t.Errorf("row = %q, want 2.35 and 2.4", r.Rows[0])
+	}
+}
+
+func TestExecutor_NumericArithErrors(t *testing.T) {
+	e := setup(t)
+
+	// Only + - * / % are NUMERIC arithmetic; other operators are left to
+	// the caller's rules rather than yielding NULL.
+	if _, ok, _ := numericArith("=", storage.NumericFromInt(1), int64(1)); ok {
+		t.Error("numericArith(=) handled a comparison")
+	}
+	_, err := e.Execute("SELECT (0.1::NUMERIC + 0.2) = 0.3")
+	assertSQLSTATE(t, err, "42601")
+
+	// An operand that is not a number is an error, not NULL.
+	_, err = e.Execute("SELECT 1.5::NUMERIC + TRUE")
+	assertSQLSTATE(t, err, "42883")
+	_, err = e.Execute("SELECT 'x' * 2.5::NUMERIC")
+	assertSQLSTATE(t, err, "42883")
+}
+
+func TestExecutor_Time(t *testing.T) {
+	dir := tempDir(t)
+	eng, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(eng)
+	exec(t, e, "CREATE TABLE shifts (id INTEGER PRIMARY KEY, starts TIME, ends TIME WITHOUT TIME ZONE)")
+	exec(t, e, "INSERT INTO shifts VALUES (1, '09:00', '17:30:00'), (2, '22:15:00.5', '24:00:00'), (3, TIME '06:05:04', NULL)")
+
+	r := exec(t, e, "SELECT starts, ends FROM shifts ORDER BY starts")
+	if r.Columns[0].TypeOID != OIDTime || r.Columns[0].TypeSize != 8 {
+		t.Errorf("column OID = %d, size %d, want %d (OIDTime), 8", r.Columns[0].TypeOID, r.Columns[0].TypeSize, OIDTime)
+	}
+	want := []string{"06:05:04|-", "09:00:00|17:30:00", "22:15:00.5|24:00:00"}
+	if got := groupRows(r); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+
+	// Literals compared with a TIME column are read as times.
+	r = exec(t, e, "SELECT id FROM shifts WHERE starts < '9:30' AND starts >= TIME '06:05:04' ORDER BY id")
+	if got := groupRows(r); len(got) != 2 || got[0] != "1" || got[1] != "3" {
+		t.Errorf("filter = %v, want ids 1 and 3", got)
+	}
+	r = exec(t, e, "SELECT MIN(starts), MAX(ends) FROM shifts")
+	if string(r.Rows[0][0]) != "06:05:04" || string(r.Rows[0][1]) != "24:00:00" {
+		t.Errorf("MIN/MAX = %q, want 06:05:04 and 24:00:00", r.Rows[0])
+	}
+
+	// Casts.
+	r = exec(t, e, "SELECT TIME '12:30:00', '2024-01-15 08:45:10.25'::time, CAST('bogus' AS TIME)")
+	if r.Columns[0].TypeOID != OIDTime || string(r.Rows[0][0]) != "12:30:00" {
+		t.Errorf("TIME literal = %q (OID %d), want 12:30:00", r.Rows[0][0], r.Columns[0].TypeOID)
+	}
+	if string(r.Rows[0][1]) != "08:45:10.25" || r.Rows[0][2] != nil {
+		t.Errorf("casts = %q, want 08:45:10.25 and NULL", r.Rows[0][1:])
+	}
+
+	for _, v := range []string{"25:00:00", "12:60", "noon", "12:30:00:00"} {
+		_, err := e.Execute("INSERT INTO shifts VALUES (9, '" + v + "', NULL)")
+		assertSQLSTATE(t, err, "22P02")
+	}
+
+	// The values come back from the WAL.
+	eng.Close()
+	eng, err = storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+	e = New(eng)
+	r = exec(t, e, "SELECT starts FROM shifts WHERE id = 2")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "22:15:00.5" {
+		t.Errorf("after restart: %q, want 22:15:00.5", r.Rows)
+	}
+}
+
+func TestExecutor_DictionaryColumn(t *testing.T) {
+	dir := tempDir(t)
+	eng, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+	e := New(eng)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT STORAGE DICTIONARY NOT NULL, amount INTEGER)")
+	exec(t, e, "INSERT INTO orders VALUES (1, 'open', 10), (2, 'shipped', 20), (3, 'open', 30), (4, 'cancelled', 40)")
+	exec(t, e, "ALTER TABLE orders ADD COLUMN region TEXT STORAGE DICTIONARY DEFAULT 'eu'")
+	exec(t, e, "UPDATE orders SET region = 'us' WHERE id >= 3")
+	exec(t, e, "CREATE VIEW order_status AS SELECT id, status FROM orders")
+
+	// Equality on the column narrows the scan in storage; the rest of the
+	// WHERE clause still applies, and so do aggregates over it.
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id FROM orders WHERE status = 'open' ORDER BY id", []string{"1", "3"}},
+		{"SELECT id FROM orders WHERE 'open' = status AND amount > 10", []string{"3"}},
+		{"SELECT id FROM orders WHERE status = 'pending'", nil},
+		{"SELECT id FROM orders WHERE status = 'open' OR status = 'shipped' ORDER BY id", []string{"1", "2", "3"}},
+		{"SELECT id FROM orders WHERE region = 'us' AND status = 'open'", []string{"3"}},
+		{"SELECT SUM(amount) FROM orders WHERE status = 'open'", []string{"40"}},
+		{"SELECT id FROM order_status WHERE status = 'shipped'", []string{"2"}},
+		{"SELECT region, COUNT(*) FROM orders WHERE status <> 'cancelled' GROUP BY region ORDER BY region", []string{"eu|2", "us|1"}},
+	}
+	for _, tt := range tests {
+		if got := groupRows(exec(t, e, tt.sql)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+
+	// A transaction sees its own changes to the column.
+	txe := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	exec(t, txe, "UPDATE orders SET status = 'open' WHERE id = 2")
+	exec(t, txe, "INSERT INTO orders VALUES (5, 'open', 50, 'eu')")
+	exec(t, txe, "DELETE FROM orders WHERE id = 1")
+	if got := groupRows(exec(t, txe, "SELECT id FROM orders WHERE status = 'open' ORDER BY id")); !slices.Equal(got, []string{"2", "3", "5"}) {
+		t.Errorf("in transaction: ids %v, want [2 3 5]", got)
+	}
+	if got := groupRows(exec(t, e, "SELECT id FROM orders WHERE status = 'open' ORDER BY id")); !slices.Equal(got, []string{"1", "3"}) {
+		t.Errorf("outside the transaction: ids %v, want [1 3]", got)
+	}
+
+	_, err = e.Execute("CREATE TABLE bad (n INTEGER STORAGE DICTIONARY)")
+	if err == nil {
+		t.Error("STORAGE DICTIONARY on an INTEGER column succeeded")
+	}
+}
+
+func TestExecutor_SerialWALReplay(t *testing.T) {
+	dir := tempDir(t)
+
+	eng, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(eng)
+	exec(t, e, "CREATE TABLE t (id SERIAL PRIMARY KEY, code INTEGER GENERATED ALWAYS AS IDENTITY, name TEXT)")
+	exec(t, e, "INSERT INTO t (name) VALUES ('a'), ('b'), ('c')")
+	exec(t, e, "INSERT INTO t (id, name) VALUES (20, 'd')")
+	exec(t, e, "DELETE FROM t WHERE id = 20")
+	eng.Close()
+
+	// The high-water marks, 20 and 4, come back with the rows that set
+	// them, and so do the columns' kinds.
+	eng, err = storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+	e = New(eng)
+	r := exec(t, e, "INSERT INTO t (name) VALUES ('e') RETURNING id, code")
+	if string(r.Rows[0][0]) != "21" || string(r.Rows[0][1]) != "5" {
+		t.Errorf("after restart: id, code = %q, want 21, 5", r.Rows[0])
+	}
+	_, err = e.Execute("INSERT INTO t (code, name) VALUES (1, 'f')")
+	assertSQLSTATE(t, err, "428C9")
+}
+
 func TestExecutor_AlterTableWALReplay(t *testing.T) {
 	dir := tempDir(t)
 
@@ -2948,6 +4186,35 @@ func TestExecutor_CreateUniqueIndex(t *testing.T) {
 	}
 }
 
+func TestExecutor_CreateTableUnique(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT UNIQUE)")
+	exec(t, e, "INSERT INTO users VALUES (1, 'alice@test.com')")
+	exec(t, e, "INSERT INTO users VALUES (2, NULL)")
+	exec(t, e, "INSERT INTO users VALUES (3, NULL)") // NULLs never conflict
+
+	_, err := e.Execute("INSERT INTO users VALUES (4, 'alice@test.com')")
+	var qe *QueryError
+	if !errors.As(err, &qe) || qe.Code != "23505" {
+		t.Fatalf("expected SQLSTATE 23505, got %v", err)
+	}
+	if !strings.Contains(qe.Message, "email") {
+		t.Errorf("message %q should name the column", qe.Message)
+	}
+
+	_, err = e.Execute("UPDATE users SET email = 'alice@test.com' WHERE id = 2")
+	if !errors.As(err, &qe) || qe.Code != "23505" {
+		t.Fatalf("UPDATE: expected SQLSTATE 23505, got %v", err)
+	}
+
+	// The constraint is backed by an ordinary unique index.
+	r := exec(t, e, "DROP INDEX users_email_key ON users")
+	if r.Tag != "DROP INDEX" {
+		t.Errorf("tag = %q, want DROP INDEX", r.Tag)
+	}
+	exec(t, e, "INSERT INTO users VALUES (4, 'alice@test.com')")
+}
+
 func TestExecutor_DropIndex(t *testing.T) {
 	e := setup(t)
 	exec(t, e, "CREATE TABLE t (id INTEGER, val TEXT)")
@@ -3147,13 +4414,81 @@ func TestExecutor_NotNull_InformationSchema(t *testing.T) {
 	}
 }
 
-func TestExecutor_NotNull_AlterTableReject(t *testing.T) {
-	e := setup(t)
+func TestExecutor_NotNull_AlterTableWithoutDefault(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	exec(t, e, "CREATE TABLE empty (id INTEGER PRIMARY KEY)")
+	exec(t, e, "INSERT INTO t VALUES (1)")
+
+	// Existing rows would hold NULL in the new column.
+	_, err := e.Execute("ALTER TABLE t ADD COLUMN name TEXT NOT NULL")
+	assertSQLSTATE(t, err, "23502")
+	_, err = e.Execute("ALTER TABLE t ADD COLUMN name TEXT NOT NULL DEFAULT NULL")
+	assertSQLSTATE(t, err, "23502")
+
+	exec(t, e, "ALTER TABLE empty ADD COLUMN name TEXT NOT NULL")
+	_, err = e.Execute("INSERT INTO empty (id) VALUES (1)")
+	assertSQLSTATE(t, err, "23502")
+}
+
+func TestExecutor_AddColumnDefault(t *testing.T) {
+	dir := tempDir(t)
+	eng, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(eng)
 	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	exec(t, e, "INSERT INTO t VALUES (1), (2)")
+	exec(t, e, "ALTER TABLE t ADD COLUMN status TEXT NOT NULL DEFAULT 'active'")
+	exec(t, e, "ALTER TABLE t ADD COLUMN score FLOAT DEFAULT 1")
+	exec(t, e, "INSERT INTO t (id) VALUES (3)")
+	exec(t, e, "INSERT INTO t VALUES (4, 'closed', NULL)")
+	exec(t, e, "UPDATE t SET status = 'paused' WHERE id = 2")
+
+	// Later writes must still satisfy NOT NULL.
+	_, err = e.Execute("INSERT INTO t VALUES (5, NULL, 0)")
+	assertSQLSTATE(t, err, "23502")
+	_, err = e.Execute("UPDATE t SET status = NULL WHERE id = 1")
+	assertSQLSTATE(t, err, "23502")
 
-	_, err := e.Execute("ALTER TABLE t ADD COLUMN name TEXT NOT NULL")
-	if err == nil {
-		t.Fatal("expected error for ALTER TABLE ADD COLUMN ... NOT NULL")
+	want := [][]string{
+		{"1", "active", "1"},
+		{"2", "paused", "1"},
+		{"3", "active", "1"},
+		{"4", "closed", "NULL"},
+	}
+	check := func(phase string) {
+		t.Helper()
+		r := exec(t, e, "SELECT id, status, score FROM t ORDER BY id")
+		if len(r.Rows) != len(want) {
+			t.Fatalf("%s: rows = %d, want %d", phase, len(r.Rows), len(want))
+		}
+		for i, w := range want {
+			for j, v := range w {
+				got := "NULL"
+				if r.Rows[i][j] != nil {
+					got = string(r.Rows[i][j])
+				}
+				if got != v {
+					t.Errorf("%s: row %d column %d = %s, want %s", phase, i, j, got, v)
+				}
+			}
+		}
+	}
+	check("before restart")
+	eng.Close()
+
+	eng, err = storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer eng.Close()
+	e = New(eng)
+	check("after restart")
+	r := exec(t, e, "SELECT COUNT(*) FROM t WHERE status = 'active'")
+	if string(r.Rows[0][0]) != "2" {
+		t.Errorf("active rows = %s, want 2", r.Rows[0][0])
 	}
 }
 
@@ -3206,6 +4541,123 @@ func TestExecutor_IndexedBy_CompoundAnd(t *testing.T) {
 	}
 }
 
+func TestExecutor_IndexedBy_Range(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, created_at TIMESTAMP, total INTEGER)")
+	exec(t, e, "CREATE INDEX idx_created ON orders(created_at)")
+	exec(t, e, `INSERT INTO orders VALUES
+		(1, '2024-03-01 00:00:00', 10),
+		(2, '2023-12-31 23:59:59', 20),
+		(3, '2024-01-01 00:00:00', 30),
+		(4, '2024-02-01 00:00:00', 40),
+		(5, NULL, 50)`)
+
+	ids := func(sql string) string {
+		t.Helper()
+		var out []string
+		for _, row := range exec(t, e, sql).Rows {
+			out = append(out, string(row[0]))
+		}
+		return strings.Join(out, ",")
+	}
+	// Rows come back in index order.
+	if got := ids("SELECT id FROM orders INDEXED BY idx_created WHERE created_at >= '2024-01-01'"); got != "3,4,1" {
+		t.Errorf(">= 2024-01-01: ids = %s, want 3,4,1", got)
+	}
+	if got := ids("SELECT id FROM orders INDEXED BY idx_created WHERE created_at > '2024-01-01' AND created_at < '2024-03-01' AND total > 0"); got != "4" {
+		t.Errorf("open range: ids = %s, want 4", got)
+	}
+	if got := ids("SELECT id FROM orders INDEXED BY idx_created WHERE created_at BETWEEN '2023-01-01' AND '2024-01-01' ORDER BY created_at"); got != "2,3" {
+		t.Errorf("BETWEEN: ids = %s, want 2,3", got)
+	}
+	if got := ids("SELECT id FROM orders INDEXED BY idx_created WHERE created_at < '2025-01-01' ORDER BY created_at DESC"); got != "1,4,3,2" {
+		t.Errorf("DESC: ids = %s, want 1,4,3,2", got)
+	}
+	if got := ids("SELECT COUNT(*) FROM orders INDEXED BY idx_created WHERE created_at >= '2024-01-01'"); got != "3" {
+		t.Errorf("COUNT(*) = %s, want 3", got)
+	}
+
+	exec(t, e, "UPDATE orders INDEXED BY idx_created SET total = 0 WHERE created_at < '2024-01-01'")
+	if got := ids("SELECT total FROM orders WHERE id = 2"); got != "0" {
+		t.Errorf("total after range UPDATE = %s, want 0", got)
+	}
+	exec(t, e, "DELETE FROM orders INDEXED BY idx_created WHERE created_at >= '2024-02-01'")
+	if got := ids("SELECT id FROM orders ORDER BY id"); got != "2,3,5" {
+		t.Errorf("ids after range DELETE = %s, want 2,3,5", got)
+	}
+
+	// A composite index can be walked over a range of its leading column,
+	// but not of a later one alone.
+	exec(t, e, "CREATE INDEX idx_pair ON orders(created_at, total)")
+	if got := ids("SELECT id FROM orders INDEXED BY idx_pair WHERE created_at >= '2024-01-01'"); got != "3" {
+		t.Errorf("ids for leading-column range = %s, want 3", got)
+	}
+	_, err := e.Execute("SELECT id FROM orders INDEXED BY idx_pair WHERE total > 10")
+	assertSQLSTATE(t, err, "0A000")
+}
+
+func TestExecutor_IndexUnion(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE items (id INTEGER PRIMARY KEY, sku TEXT, qty INTEGER)")
+	exec(t, e, "CREATE INDEX idx_sku ON items(sku)")
+	var values []string
+	for i := 1; i <= 50; i++ {
+		values = append(values, fmt.Sprintf("(%d, 'sku%d', %d)", i, i%10, i))
+	}
+	exec(t, e, "INSERT INTO items VALUES "+strings.Join(values, ", "))
+
+	tests := []struct {
+		sql     string
+		index   string
+		scanned int64
+		want    string
+	}{
+		// Repeated values are probed once and rows are returned once.
+		{"SELECT id FROM items WHERE id = 3 OR id = 1 OR id = 3", "PRIMARY", 2, "1,3"},
+		{"SELECT id FROM items WHERE id IN (7, 99, 5)", "PRIMARY", 2, "5,7"},
+		{"SELECT id FROM items WHERE (id = 4 OR id = 2) AND qty > 2", "PRIMARY", 2, "4"},
+		{"SELECT id FROM items WHERE sku = 'sku3' OR sku = 'sku1' OR sku = 'sku3' ORDER BY sku, id",
+			"idx_sku", 10, "1,11,21,31,41,3,13,23,33,43"},
+		{"SELECT id FROM items WHERE sku IN ('sku0') AND id < 25", "idx_sku", 5, "10,20"},
+		{"SELECT COUNT(*) FROM items WHERE sku = 'sku2' OR sku = 'sku4'", "idx_sku", 10, "10"},
+		{"SELECT sku, COUNT(*) FROM items WHERE id IN (1, 2, 11) GROUP BY sku ORDER BY sku", "PRIMARY", 3, "sku1,sku2"},
+		// Mixed columns, unindexed columns and NOT IN still scan.
+		{"SELECT id FROM items WHERE id = 1 OR sku = 'sku2' AND id < 10", "", 50, "1,2"},
+		{"SELECT id FROM items WHERE qty = 1 OR qty = 2", "", 50, "1,2"},
+		{"SELECT COUNT(*) FROM items WHERE id NOT IN (1, 2)", "", 50, "48"},
+	}
+	for _, tt := range tests {
+		r, tr, err := e.ExecuteTraced(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		var ids []string
+		for _, row := range r.Rows {
+			ids = append(ids, string(row[0]))
+		}
+		if got := strings.Join(ids, ","); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.sql, got, tt.want)
+		}
+		if tr.IndexName != tt.index || tr.RowsScanned != tt.scanned {
+			t.Errorf("%s: IndexName = %q, RowsScanned = %d; want %q, %d",
+				tt.sql, tr.IndexName, tr.RowsScanned, tt.index, tt.scanned)
+		}
+	}
+
+	// Inside a transaction the probes see the transaction's own changes.
+	tx := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	exec(t, tx, "UPDATE items SET sku = 'sku1' WHERE id = 2")
+	exec(t, tx, "DELETE FROM items WHERE id = 11")
+	r := exec(t, tx, "SELECT id FROM items WHERE sku IN ('sku1') AND id < 25 ORDER BY id")
+	var ids []string
+	for _, row := range r.Rows {
+		ids = append(ids, string(row[0]))
+	}
+	if got := strings.Join(ids, ","); got != "1,2,21" {
+		t.Errorf("in transaction: ids = %s, want 1,2,21", got)
+	}
+}
+
 func TestExecutor_IndexedBy_Update(t *testing.T) {
 	e := setup(t)
 	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, email TEXT, name TEXT)")
@@ -3242,6 +4694,11 @@ func TestExecutor_IndexedBy_Delete(t *testing.T) {
 	if string(r2.Rows[0][0]) != "1" {
 		t.Errorf("count = %q, want 1", r2.Rows[0][0])
 	}
+
+	r = exec(t, e, "DELETE FROM t INDEXED BY idx_email WHERE email = 'c@d.com' RETURNING id")
+	if r.Tag != "DELETE 1" || len(r.Rows) != 1 || string(r.Rows[0][0]) != "2" {
+		t.Errorf("RETURNING = %q (%s), want [[2]] (DELETE 1)", r.Rows, r.Tag)
+	}
 }
 
 func TestExecutor_IndexedBy_JoinRejected(t *testing.T) {
@@ -3254,25 +4711,175 @@ func TestExecutor_IndexedBy_JoinRejected(t *testing.T) {
 	assertSQLSTATE(t, err, "0A000")
 }
 
-func TestExecutor_IndexedBy_NoAutoSecondaryIndex(t *testing.T) {
+func TestExecutor_AutoSecondaryIndex(t *testing.T) {
 	e := setup(t)
-	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, email TEXT)")
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, email TEXT, n INTEGER)")
 	exec(t, e, "CREATE INDEX idx_email ON t(email)")
+	exec(t, e, "CREATE INDEX idx_n ON t(n)")
 	for i := 1; i <= 50; i++ {
-		exec(t, e, "INSERT INTO t VALUES ("+itoa(i)+", 'user"+itoa(i)+"@test.com')")
+		exec(t, e, "INSERT INTO t VALUES ("+itoa(i)+", 'user"+itoa(i)+"@test.com', "+itoa(i%5)+")")
+	}
+
+	// Without INDEXED BY, an equality on an indexed column reads the index.
+	r, tr, err := e.ExecuteTraced("SELECT id FROM t WHERE email = 'user25@test.com'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.IndexName != "idx_email" {
+		t.Errorf("IndexName = %q, want idx_email", tr.IndexName)
+	}
+	if tr.RowsScanned != 1 {
+		t.Errorf("RowsScanned = %d, want 1", tr.RowsScanned)
+	}
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "25" {
+		t.Errorf("rows = %q, want [[25]]", r.Rows)
+	}
+
+	// The rest of the WHERE clause still filters the rows found, and the
+	// literal is converted to the column's type.
+	r, tr, err = e.ExecuteTraced("SELECT COUNT(*) FROM t WHERE n = '3' AND id > 20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.IndexName != "idx_n" || tr.RowsScanned != 10 {
+		t.Errorf("IndexName = %q, RowsScanned = %d, want idx_n, 10", tr.IndexName, tr.RowsScanned)
+	}
+	if string(r.Rows[0][0]) != "6" {
+		t.Errorf("COUNT(*) = %s, want 6", r.Rows[0][0])
+	}
+
+	// An unindexed column still scans.
+	_, tr, err = e.ExecuteTraced("SELECT * FROM t WHERE id + 0 = 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.IndexName != "" || tr.RowsScanned < 50 {
+		t.Errorf("IndexName = %q, RowsScanned = %d, want full scan", tr.IndexName, tr.RowsScanned)
+	}
+}
+
+func TestExecutor_ChooseAccess(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, n INTEGER)")
+	exec(t, e, "CREATE INDEX idx_n ON t(n)")
+	exec(t, e, "INSERT INTO t VALUES (1, 10), (2, 20), (3, 30)")
+
+	trace := func(sql string) (*Result, *Trace) {
+		t.Helper()
+		r, tr, err := e.ExecuteTraced(sql)
+		if err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		return r, tr
+	}
+
+	// A small table is scanned, except for a primary key equality.
+	if _, tr := trace("SELECT id FROM t WHERE n > 15"); tr.Access != "seq scan" || tr.IndexName != "" {
+		t.Errorf("small table: Access = %q, IndexName = %q; want seq scan", tr.Access, tr.IndexName)
+	}
+	if _, tr := trace("SELECT id FROM t WHERE id = 2"); tr.Access != "index scan" || tr.IndexName != "PRIMARY" {
+		t.Errorf("key lookup: Access = %q, IndexName = %q; want index scan of PRIMARY", tr.Access, tr.IndexName)
+	}
+
+	for i := 4; i <= 100; i++ {
+		exec(t, e, fmt.Sprintf("INSERT INTO t VALUES (%d, %d)", i, i*10))
+	}
+	// A range of a secondary index is read through it, in key order, by
+	// plain SELECTs, aggregates and GROUP BY alike.
+	r, tr := trace("SELECT id FROM t WHERE n BETWEEN 200 AND 240 AND id <> 22 ORDER BY n")
+	if got := groupRows(r); !slices.Equal(got, []string{"20", "21", "23", "24"}) {
+		t.Errorf("rows = %q, want 20, 21, 23, 24", got)
 	}
+	if tr.Access != "index scan" || tr.IndexName != "idx_n" || tr.RowsScanned != 5 {
+		t.Errorf("range: Access = %q, IndexName = %q, RowsScanned = %d; want index scan of idx_n, 5", tr.Access, tr.IndexName, tr.RowsScanned)
+	}
+	r, tr = trace("SELECT COUNT(*) FROM t WHERE n < 50")
+	if string(r.Rows[0][0]) != "4" || tr.IndexName != "idx_n" || tr.RowsScanned != 4 {
+		t.Errorf("COUNT(*) = %s, IndexName = %q, RowsScanned = %d; want 4, idx_n, 4", r.Rows[0][0], tr.IndexName, tr.RowsScanned)
+	}
+	r, tr = trace("SELECT n, COUNT(*) FROM t WHERE n >= 990 GROUP BY n ORDER BY n")
+	if got := groupRows(r); !slices.Equal(got, []string{"990|1", "1000|1"}) || tr.IndexName != "idx_n" {
+		t.Errorf("GROUP BY = %q, IndexName = %q; want 990|1, 1000|1 through idx_n", got, tr.IndexName)
+	}
+
+	// Once ANALYZE shows that a predicate matches most rows, it scans.
+	exec(t, e, "ANALYZE t")
+	if _, tr := trace("SELECT id FROM t WHERE n > 100"); tr.Access != "seq scan" || tr.RowsScanned != 100 {
+		t.Errorf("after ANALYZE: Access = %q, RowsScanned = %d; want seq scan, 100", tr.Access, tr.RowsScanned)
+	}
+
+	// Inside a transaction the range sees the transaction's own changes.
+	tx := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	exec(t, tx, "UPDATE t SET n = 5 WHERE id = 50")
+	exec(t, tx, "DELETE FROM t WHERE id = 2")
+	r = exec(t, tx, "SELECT id FROM t WHERE n < 25 ORDER BY n")
+	if got := groupRows(r); !slices.Equal(got, []string{"50", "1"}) {
+		t.Errorf("in transaction: rows = %q, want 50, 1", got)
+	}
+}
 
-	// Without INDEXED BY, a query on an indexed column should do a full scan.
-	_, tr, err := e.ExecuteTraced("SELECT * FROM t WHERE email = 'user25@test.com'")
+func TestExecutor_IndexedBy_Composite(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, status TEXT)")
+	exec(t, e, "CREATE INDEX idx_user_status ON orders (user_id, status)")
+	exec(t, e, "INSERT INTO orders VALUES (1, 7, 'open'), (2, 7, 'shipped'), (3, 7, 'open'), (4, 8, 'open')")
+
+	// Equality on every indexed column, in either order, uses the index.
+	r, tr, err := e.ExecuteTraced("SELECT id FROM orders INDEXED BY idx_user_status WHERE status = 'open' AND user_id = 7 ORDER BY id")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if tr.IndexName != "" {
-		t.Errorf("expected no index used without INDEXED BY, got IndexName=%q", tr.IndexName)
+	if len(r.Rows) != 2 || string(r.Rows[0][0]) != "1" || string(r.Rows[1][0]) != "3" {
+		t.Fatalf("rows = %v, want ids 1 and 3", r.Rows)
+	}
+	if tr.IndexName != "idx_user_status" {
+		t.Errorf("IndexName = %q, want idx_user_status", tr.IndexName)
+	}
+
+	// An equality on the leading column alone matches the keys it is a
+	// prefix of, in key order; one on a later column alone leaves the
+	// index unusable.
+	r = exec(t, e, "SELECT id FROM orders INDEXED BY idx_user_status WHERE user_id = 7")
+	if got := ids(r); !slices.Equal(got, []string{"1", "3", "2"}) {
+		t.Errorf("ids for prefix = %v, want [1 3 2]", got)
+	}
+	r = exec(t, e, "SELECT id FROM orders INDEXED BY idx_user_status WHERE user_id = 7 AND status > 'p'")
+	if got := ids(r); !slices.Equal(got, []string{"2"}) {
+		t.Errorf("ids for prefix and range = %v, want [2]", got)
+	}
+	r = exec(t, e, "SELECT id FROM orders INDEXED BY idx_user_status WHERE user_id >= 8")
+	if got := ids(r); !slices.Equal(got, []string{"4"}) {
+		t.Errorf("ids for leading range = %v, want [4]", got)
+	}
+	_, err = e.Execute("SELECT id FROM orders INDEXED BY idx_user_status WHERE status = 'open'")
+	assertSQLSTATE(t, err, "0A000")
+
+	r = exec(t, e, "UPDATE orders INDEXED BY idx_user_status SET status = 'shipped' WHERE user_id = 7 AND status = 'open'")
+	if r.Tag != "UPDATE 2" {
+		t.Errorf("tag = %q, want UPDATE 2", r.Tag)
 	}
-	if tr.RowsScanned < 50 {
-		t.Errorf("expected full scan (>=50 rows), got RowsScanned=%d", tr.RowsScanned)
+	r = exec(t, e, "DELETE FROM orders INDEXED BY idx_user_status WHERE user_id = 7 AND status = 'shipped'")
+	if r.Tag != "DELETE 3" {
+		t.Errorf("tag = %q, want DELETE 3", r.Tag)
 	}
+	r = exec(t, e, "SELECT id FROM orders")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "4" {
+		t.Errorf("remaining = %v, want id 4", r.Rows)
+	}
+}
+
+func TestExecutor_CompositeUniqueIndex(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, a INTEGER, b TEXT)")
+	exec(t, e, "CREATE UNIQUE INDEX ON t (a, b)")
+	exec(t, e, "INSERT INTO t VALUES (1, 1, 'x'), (2, 1, 'y'), (3, 2, 'x')")
+
+	_, err := e.Execute("INSERT INTO t VALUES (4, 1, 'x')")
+	assertSQLSTATE(t, err, "23505")
+
+	// The auto-generated name joins the column names.
+	_, err = e.Execute("CREATE INDEX ON t (a, b)")
+	assertSQLSTATE(t, err, "42P07")
 }
 
 func TestExecutor_IndexedBy_TraceShowsIndexName(t *testing.T) {
@@ -3634,6 +5241,130 @@ func TestExecutor_GroupBy_StarError(t *testing.T) {
 	}
 }
 
+func TestExecutor_GroupBy_Position(t *testing.T) {
+	e := setupSales(t)
+	r := exec(t, e, "SELECT category, SUM(amount) FROM sales GROUP BY 1 ORDER BY category")
+	if len(r.Rows) != 2 {
+		t.Fatalf("rows = %d, want 2", len(r.Rows))
+	}
+	if r.Columns[0].Name != "category" {
+		t.Errorf("col0 name = %q, want category", r.Columns[0].Name)
+	}
+
+	_, err := e.Execute("SELECT category FROM sales GROUP BY 2")
+	assertSQLSTATE(t, err, "42P10")
+	_, err = e.Execute("SELECT category, COUNT(*) FROM sales GROUP BY 2")
+	assertSQLSTATE(t, err, "42803")
+}
+
+func TestExecutor_GroupBy_Expression(t *testing.T) {
+	e := setupSales(t)
+	r := exec(t, e, "SELECT UPPER(category) AS cat, COUNT(*) FROM sales GROUP BY UPPER(category) ORDER BY cat")
+	if len(r.Rows) != 2 {
+		t.Fatalf("rows = %d, want 2", len(r.Rows))
+	}
+	for _, row := range r.Rows {
+		if s := string(row[0]); s != strings.ToUpper(s) {
+			t.Errorf("group key %q, want upper case", s)
+		}
+	}
+
+	// A select expression that differs from the GROUP BY expression is
+	// still rejected.
+	_, err := e.Execute("SELECT LOWER(category) FROM sales GROUP BY UPPER(category)")
+	assertSQLSTATE(t, err, "42803")
+}
+
+// groupRows joins the cells of each row of r with "|", NULL as "-".
+func groupRows(r *Result) []string {
+	var out []string
+	for _, row := range r.Rows {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			if c == nil {
+				cells[i] = "-"
+			} else {
+				cells[i] = string(c)
+			}
+		}
+		out = append(out, strings.Join(cells, "|"))
+	}
+	return out
+}
+
+func TestExecutor_GroupBy_Rollup(t *testing.T) {
+	e := setupSales(t)
+
+	// Per-group rows, a subtotal per category and the grand total; the
+	// rolled-up columns are NULL and GROUPING tells them from NULL data.
+	r := exec(t, e, `SELECT category, region, SUM(amount), GROUPING(category, region) AS g
+		FROM sales GROUP BY ROLLUP(category, region) ORDER BY category, region`)
+	want := []string{
+		"A|east|50|0", "A|west|20|0", "A|-|70|1",
+		"B|east|30|0", "B|-|30|1",
+		"-|-|100|3",
+	}
+	if got := groupRows(r); !slices.Equal(got, want) {
+		t.Errorf("ROLLUP = %q, want %q", got, want)
+	}
+	if r.Tag != "SELECT 6" || r.Columns[3].Name != "g" || r.Columns[3].TypeOID != OIDInt8 {
+		t.Errorf("tag %q, columns %+v, want SELECT 6 and g INT8", r.Tag, r.Columns)
+	}
+
+	// Plain GROUP BY items are in every set.
+	r = exec(t, e, "SELECT category, region, COUNT(*) FROM sales GROUP BY category, ROLLUP(region) ORDER BY 1, 2")
+	if got, want := groupRows(r), []string{"A|east|2", "A|west|1", "A|-|3", "B|east|1", "B|-|1"}; !slices.Equal(got, want) {
+		t.Errorf("GROUP BY category, ROLLUP(region) = %q, want %q", got, want)
+	}
+
+	// A grand total is returned even for no rows; other groups are not.
+	r = exec(t, e, "SELECT category, COUNT(*), SUM(amount) FROM sales WHERE amount > 100 GROUP BY ROLLUP(category)")
+	if got, want := groupRows(r), []string{"-|0|0"}; !slices.Equal(got, want) {
+		t.Errorf("ROLLUP of no rows = %q, want %q", got, want)
+	}
+	r = exec(t, e, "SELECT COUNT(*) FROM sales GROUP BY ()")
+	if got, want := groupRows(r), []string{"4"}; !slices.Equal(got, want) {
+		t.Errorf("GROUP BY () = %q, want %q", got, want)
+	}
+}
+
+func TestExecutor_GroupBy_GroupingSets(t *testing.T) {
+	e := setupSales(t)
+	exec(t, e, "INSERT INTO sales VALUES ('B', NULL, 5)")
+
+	// A NULL region of its own stays apart from the rolled-up one.
+	r := exec(t, e, `SELECT category, region, SUM(amount), GROUPING(region)
+		FROM sales GROUP BY GROUPING SETS ((category), (region), ()) ORDER BY 4, 1, 2`)
+	want := []string{
+		"-|east|80|0", "-|west|20|0", "-|-|5|0",
+		"A|-|70|1", "B|-|35|1", "-|-|105|1",
+	}
+	if got := groupRows(r); !slices.Equal(got, want) {
+		t.Errorf("GROUPING SETS = %q, want %q", got, want)
+	}
+
+	r = exec(t, e, "SELECT category, region, COUNT(*) FROM sales WHERE region IS NOT NULL GROUP BY CUBE(category, region) ORDER BY 1, 2")
+	want = []string{
+		"A|east|2", "A|west|1", "A|-|3",
+		"B|east|1", "B|-|1",
+		"-|east|3", "-|west|1", "-|-|4",
+	}
+	if got := groupRows(r); !slices.Equal(got, want) {
+		t.Errorf("CUBE = %q, want %q", got, want)
+	}
+
+	for sql, code := range map[string]string{
+		"SELECT GROUPING(amount) FROM sales GROUP BY ROLLUP(category)":         "42803",
+		"SELECT category, GROUPING(category) FROM sales":                       "42803",
+		"SELECT region FROM sales GROUP BY ROLLUP(category)":                   "42803",
+		"SELECT category FROM sales GROUP BY ()":                               "42803",
+		"SELECT category FROM sales s JOIN sales t ON true GROUP BY ROLLUP(1)": "0A000",
+	} {
+		_, err := e.Execute(sql)
+		assertSQLSTATE(t, err, code)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // NEST(SELECT ...) tests
 // ---------------------------------------------------------------------------
@@ -3814,7 +5545,10 @@ func TestFormatNest(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := formatNest(tt.rows)
+			got, err := formatNest(tt.rows)
+			if err != nil {
+				t.Fatal(err)
+			}
 			if tt.want == "" {
 				if got != nil {
 					t.Errorf("formatNest = %q, want nil", string(got))
@@ -4121,3 +5855,161 @@ func TestExecutor_BetweenFloat(t *testing.T) {
 		t.Fatalf("got %d rows, want 1", len(r.Rows))
 	}
 }
+
+// fsyncFailingEngine stands in for an engine whose WAL fsync fails: every
+// write returns the error the real engine returns in that case.
+type fsyncFailingEngine struct{ storage.Engine }
+
+func (fsyncFailingEngine) fail() error {
+	return fmt.Errorf("WAL: %w", &storage.WALIOError{Op: "fsync", Path: "t.wal", Err: errors.New("input/output error")})
+}
+
+func (e fsyncFailingEngine) Insert(string, []string, [][]any) (int64, error) {
+	return 0, e.fail()
+}
+
+func (e fsyncFailingEngine) Update(string, map[string]any, func(storage.Row) bool) (int64, error) {
+	return 0, e.fail()
+}
+
+func (e fsyncFailingEngine) Delete(string, func(storage.Row) bool) (int64, error) {
+	return 0, e.fail()
+}
+
+func (e fsyncFailingEngine) InsertReturning(string, []string, [][]any) ([]storage.Row, error) {
+	return nil, e.fail()
+}
+
+func (e fsyncFailingEngine) UpdateReturning(string, map[string]any, func(storage.Row) bool) ([]storage.Row, error) {
+	return nil, e.fail()
+}
+
+func (e fsyncFailingEngine) DeleteReturning(string, func(storage.Row) bool) ([]storage.Row, error) {
+	return nil, e.fail()
+}
+
+func TestExecutor_WALFsyncFailure(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	failing := e.WithEngine(fsyncFailingEngine{e.Engine()})
+
+	for _, sql := range []string{
+		"INSERT INTO t VALUES (1)",
+		"UPDATE t SET id = 2",
+		"DELETE FROM t",
+		"DELETE FROM t RETURNING id",
+	} {
+		_, err := failing.Execute(sql)
+		assertSQLSTATE(t, err, "58030")
+	}
+}
+
+func TestExecutor_Params(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, score FLOAT, price NUMERIC(6,2), data BYTEA)")
+
+	ins := "INSERT INTO t VALUES ($1, $2, $3, $4, $5)"
+	for _, params := range [][]any{
+		{int64(1), "a'b", 1.5, storage.NumericFromInt(3), []byte{0xde, 0xad}},
+		{int64(2), "c", nil, nil, nil},
+		{int64(3), "d", 2.5, nil, nil},
+	} {
+		if _, err := e.ExecuteParams(ins, params); err != nil {
+			t.Fatalf("ExecuteParams(%v): %v", params, err)
+		}
+	}
+
+	r, err := e.ExecuteParams("SELECT name, price, data FROM t WHERE id = $1", []any{int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "a'b" || string(r.Rows[0][1]) != "3.00" || string(r.Rows[0][2]) != `\xdead` {
+		t.Errorf("rows = %q, want [[a'b 3.00 \\xdead]]", r.Rows)
+	}
+
+	// A text parameter compared with an integer column is coerced, and
+	// LIMIT takes a parameter too.
+	r, err = e.ExecuteParams("SELECT id FROM t WHERE id >= $1 ORDER BY id LIMIT $2", []any{"2", int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "2" {
+		t.Errorf("rows = %q, want [[2]]", r.Rows)
+	}
+
+	r, err = e.ExecuteParams("UPDATE t SET score = $1 WHERE id = $2 RETURNING score", []any{9.5, int64(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Tag != "UPDATE 1" || string(r.Rows[0][0]) != "9.5" {
+		t.Errorf("UPDATE = %s %q, want UPDATE 1 [[9.5]]", r.Tag, r.Rows)
+	}
+
+	_, err = e.ExecuteParams("SELECT $2", []any{int64(1)})
+	assertSQLSTATE(t, err, "42P02")
+	_, err = e.Execute("SELECT * FROM t WHERE id = $1")
+	assertSQLSTATE(t, err, "42P02")
+}
+
+func TestExecutor_PrepareParamTypes(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, score FLOAT, ok BOOLEAN)")
+
+	tests := []struct {
+		sql      string
+		declared []int32
+		want     []int32
+	}{
+		{"SELECT * FROM t WHERE id = $1 AND name LIKE $2 LIMIT $3", nil, []int32{OIDInt8, OIDText, OIDInt8}},
+		{"SELECT * FROM t x WHERE $1 < x.score OR ok = $2", nil, []int32{OIDFloat8, OIDBool}},
+		{"SELECT $1::BOOLEAN, $2", nil, []int32{OIDBool, OIDText}},
+		{"SELECT * FROM t WHERE id IN ($1, $2) AND score BETWEEN $3 AND 10", nil, []int32{OIDInt8, OIDInt8, OIDFloat8}},
+		{"INSERT INTO t VALUES ($1, $2, $3, $4)", nil, []int32{OIDInt8, OIDText, OIDFloat8, OIDBool}},
+		{"INSERT INTO t (score, id) VALUES ($1, $2)", nil, []int32{OIDFloat8, OIDInt8}},
+		{"UPDATE t SET name = $1 WHERE id = $2", []int32{OIDVarchar}, []int32{OIDVarchar, OIDInt8}},
+		{"DELETE FROM t WHERE id = $1", []int32{OIDInt4, OIDText}, []int32{OIDInt4, OIDText}},
+	}
+	for _, tt := range tests {
+		got, err := e.Prepare(tt.sql, tt.declared)
+		if err != nil {
+			t.Fatalf("Prepare(%q): %v", tt.sql, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Prepare(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+
+	_, err := e.Prepare("SELEC 1", nil)
+	assertSQLSTATE(t, err, "42601")
+}
+
+func TestExecutor_DescribeColumns(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (1, 'a')")
+
+	cols, err := e.DescribeColumns("SELECT name, $1 FROM t WHERE id = $2", []int32{OIDBool, OIDInt8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 2 || cols[0].Name != "name" || cols[0].TypeOID != OIDText || cols[1].TypeOID != OIDBool {
+		t.Errorf("columns = %+v, want name TEXT and a BOOLEAN", cols)
+	}
+
+	cols, err = e.DescribeColumns("INSERT INTO t VALUES ($1, $2) RETURNING id", []int32{OIDInt8, OIDText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 1 || cols[0].Name != "id" || cols[0].TypeOID != OIDInt8 {
+		t.Errorf("RETURNING columns = %+v, want id INT8", cols)
+	}
+	// Describing must not run the INSERT.
+	if r := exec(t, e, "SELECT COUNT(*) FROM t"); string(r.Rows[0][0]) != "1" {
+		t.Errorf("COUNT(*) = %s after describe, want 1", r.Rows[0][0])
+	}
+
+	cols, err = e.DescribeColumns("DELETE FROM t WHERE id = $1", []int32{OIDInt8})
+	if err != nil || cols != nil {
+		t.Errorf("DELETE columns = %+v, %v; want none", cols, err)
+	}
+}
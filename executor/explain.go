@@ -0,0 +1,421 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// planNode is one step of the access plan reported by EXPLAIN. The tree is
+// built from the same branch decisions the statement executors make, without
+// touching any rows.
+type planNode struct {
+	Label    string
+	Details  []string
+	Children []*planNode
+}
+
+// lines renders the plan tree in PostgreSQL's EXPLAIN layout: child nodes are
+// prefixed with "->" and indented beneath their parent.
+func (n *planNode) lines() []string {
+	var out []string
+	var walk func(n *planNode, indent string, child bool)
+	walk = func(n *planNode, indent string, child bool) {
+		detailIndent := "  "
+		if child {
+			out = append(out, indent+"->  "+n.Label)
+			detailIndent = indent + "      "
+		} else {
+			out = append(out, n.Label)
+		}
+		for _, d := range n.Details {
+			out = append(out, detailIndent+d)
+		}
+		for _, c := range n.Children {
+			walk(c, detailIndent, true)
+		}
+	}
+	walk(n, "", false)
+	return out
+}
+
+// execExplain describes the plan for s.Stmt. With ANALYZE the statement is
+// also executed under a fresh Trace, and the trace is appended to the plan
+// as "step: value" lines. parseTime is the time spent parsing the whole
+// EXPLAIN statement and is reported as the inner statement's parse time.
+func (e *Executor) execExplain(s *parser.ExplainStmt, parseTime time.Duration, tr *Trace) (*Result, error) {
+	var plan *planNode
+	var err error
+	switch inner := s.Stmt.(type) {
+	case *parser.SelectStmt:
+		if tr != nil && !inner.From.IsEmpty() {
+			tr.Table = inner.From.String()
+		}
+		plan, err = e.planSelect(inner)
+	case *parser.UpdateStmt:
+		if inner.From != nil {
+			return nil, &QueryError{Code: "0A000", Message: "EXPLAIN is not supported for UPDATE ... FROM"}
+		}
+		if tr != nil {
+			tr.Table = inner.Table.Name
+		}
+		plan, err = e.planModify("Update", inner.Table, inner.IndexedBy, inner.Where)
+	case *parser.DeleteStmt:
+		if tr != nil {
+			tr.Table = inner.Table.Name
+		}
+		plan, err = e.planModify("Delete", inner.Table, inner.IndexedBy, inner.Where)
+	default:
+		return nil, &QueryError{Code: "0A000", Message: fmt.Sprintf("EXPLAIN is not supported for %T", s.Stmt)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines := plan.lines()
+	if s.Analyze {
+		atr := &Trace{Parse: parseTime}
+		start := time.Now()
+		if _, err := e.dispatch(s.Stmt, atr); err != nil {
+			return nil, err
+		}
+		atr.Total = parseTime + time.Since(start)
+		for _, row := range TraceToResult(atr).Rows {
+			lines = append(lines, string(row[0])+": "+string(row[1]))
+		}
+		if tr != nil {
+			tr.RowsScanned = atr.RowsScanned
+			tr.IndexName = atr.IndexName
+		}
+	}
+	rows := make([][][]byte, len(lines))
+	for i, l := range lines {
+		rows[i] = [][]byte{[]byte(l)}
+	}
+	return &Result{
+		Columns: []Column{{Name: "QUERY PLAN", TypeOID: OIDText, TypeSize: -1}},
+		Rows:    rows,
+		Tag:     "EXPLAIN",
+	}, nil
+}
+
+// planSelect mirrors the path selection of execSelect and its helpers.
+func (e *Executor) planSelect(s *parser.SelectStmt) (*planNode, error) {
+	if s.From.IsEmpty() {
+		return &planNode{Label: "Result"}, nil
+	}
+	if grouped(s) && len(s.Joins) > 0 {
+		return nil, &QueryError{Code: "0A000", Message: "GROUP BY is not supported with JOINs"}
+	}
+
+	var node *planNode
+	keySorted := false // rows come out of the access path in ORDER BY order
+	if len(s.Joins) > 0 {
+		if s.IndexedBy != "" {
+			return nil, &QueryError{Code: "0A000", Message: "INDEXED BY is not supported with JOIN"}
+		}
+		if _, err := e.buildJoinScope(s); err != nil {
+			return nil, WrapError(err)
+		}
+		// Nested-loop join: each JOIN scans its table once per outer row.
+		node = &planNode{Label: "Sequential Scan on " + s.From.String()}
+		for _, j := range s.Joins {
+			label := "Nested Loop"
+			if j.On == nil {
+				label = "Nested Loop (cross join)"
+			}
+			node = &planNode{
+				Label:    label,
+				Children: []*planNode{node, {Label: "Sequential Scan on " + j.Table.String()}},
+			}
+		}
+	} else {
+		def, isCatalog := getCatalogTable(s.From.Schema, s.From.Name)
+		if !isCatalog {
+			var ok bool
+			def, ok = e.engine.GetTable(s.From.Name)
+			if !ok {
+				return nil, WrapError(&storage.TableNotFoundError{Name: s.From.String()})
+			}
+		}
+		hasAgg := false
+		for _, col := range s.Columns {
+			if a, ok := col.(*parser.AliasExpr); ok {
+				col = a.Expr
+			}
+			if fn, ok := col.(*parser.FunctionCallExpr); ok && isAggFunc(fn.Name) {
+				hasAgg = true
+			}
+		}
+
+		// Plain SELECTs ordered by the primary key walk it instead of
+		// scanning, and a walk of the key needs no sort.
+		plain := !hasAgg && !grouped(s)
+		sorted := plain && pkSorted(s, def)
+		path, err := e.chooseAccess(s.From.Name, def, isCatalog, s.IndexedBy, s.Where, sorted)
+		if err != nil {
+			return nil, err
+		}
+		node = accessNode(path, s.From, def)
+		if plain {
+			if path.readsIndex() {
+				keySorted = namedIndexSorted(s, def) || indexUnionSorted(s, def, path.index)
+			} else {
+				keySorted = sorted && path.pkRange()
+			}
+		}
+		if !isCatalog {
+			e.annotateEstimate(node, s.From.Name, def, s.Where)
+		}
+		if grouped(s) {
+			var keys []string
+			for _, g := range s.GroupBy {
+				switch g := g.(type) {
+				case *parser.ColumnRef:
+					keys = append(keys, g.Name)
+				case *parser.IntegerLit:
+					keys = append(keys, fmt.Sprint(g.Value))
+				case *parser.FunctionCallExpr:
+					keys = append(keys, strings.ToLower(g.Name)+"(...)")
+				default:
+					keys = append(keys, "(...)")
+				}
+			}
+			details := []string{"Group Key: " + strings.Join(keys, ", ")}
+			if s.GroupingSets != nil {
+				// One key line per grouping set.
+				details = nil
+				for _, set := range s.GroupingSets {
+					var setKeys []string
+					for _, i := range set {
+						setKeys = append(setKeys, keys[i])
+					}
+					if len(setKeys) == 0 {
+						details = append(details, "Group Key: ()")
+						continue
+					}
+					details = append(details, "Group Key: "+strings.Join(setKeys, ", "))
+				}
+			}
+			node = &planNode{
+				Label:    "HashAggregate",
+				Details:  details,
+				Children: []*planNode{node},
+			}
+		} else if hasAgg {
+			node = &planNode{Label: "Aggregate", Children: []*planNode{node}}
+		}
+	}
+
+	if len(s.OrderBy) > 0 && !keySorted {
+		keys := make([]string, len(s.OrderBy))
+		for i, ob := range s.OrderBy {
+			k := ob.Column
+			if ob.Expr != nil || ob.Position > 0 {
+				k = ob.ExprSQL
+			} else if ob.Table != "" {
+				k = ob.Table + "." + k
+			}
+			if ob.Desc {
+				k += " DESC"
+			}
+			if nf := nullsFirst(ob); nf != ob.Desc {
+				if nf {
+					k += " NULLS FIRST"
+				} else {
+					k += " NULLS LAST"
+				}
+			}
+			keys[i] = k
+		}
+		node = &planNode{
+			Label:    "Sort",
+			Details:  []string{"Sort Key: " + strings.Join(keys, ", ")},
+			Children: []*planNode{node},
+		}
+	}
+	if s.Limit != nil || s.Offset != nil || s.LimitExpr != nil || s.OffsetExpr != nil {
+		node = &planNode{Label: "Limit", Children: []*planNode{node}}
+	}
+	return node, nil
+}
+
+// planModify describes UPDATE and DELETE, which never use the primary key
+// shortcut — only an explicit INDEXED BY narrows the rows they visit.
+func (e *Executor) planModify(op string, table parser.TableRef, indexedBy string, where parser.Expr) (*planNode, error) {
+	if isCatalogTable(table.Schema, table.Name) {
+		return nil, &QueryError{Code: "42809", Message: fmt.Sprintf("cannot %s catalog table %q", strings.ToLower(op), table.String())}
+	}
+	def, ok := e.engine.GetTable(table.Name)
+	if !ok {
+		return nil, WrapError(&storage.TableNotFoundError{Name: table.String()})
+	}
+	path, err := modifyAccess(def, indexedBy, where)
+	if err != nil {
+		return nil, err
+	}
+	access := accessNode(path, table, def)
+	e.annotateEstimate(access, table.Name, def, where)
+	return &planNode{Label: op + " on " + table.String(), Children: []*planNode{access}}, nil
+}
+
+// accessNode describes p, the access path chooseAccess or modifyAccess
+// picked for the table from.
+func accessNode(p accessPath, from parser.TableRef, def *storage.TableDef) *planNode {
+	switch {
+	case p.lookup:
+		return &planNode{Label: "Primary Key Lookup on " + from.String()}
+	case p.index == "":
+		return &planNode{Label: "Sequential Scan on " + from.String()}
+	}
+	index := p.index
+	if index == "PRIMARY" {
+		index = def.Name + "_pkey"
+	}
+	node := &planNode{Label: fmt.Sprintf("Index Scan using %s on %s", index, from.String())}
+	if p.keys != nil && !p.named {
+		node.Details = []string{fmt.Sprintf("Index Probes: %d", len(p.keys))}
+	}
+	return node
+}
+
+// Selectivities assumed for predicates on columns without statistics,
+// the same defaults PostgreSQL uses.
+const (
+	defaultEqSel    = 0.005
+	defaultRangeSel = 1.0 / 3
+)
+
+// annotateEstimate appends the estimated row count to an access node, as
+// "(rows=N)" in PostgreSQL's manner, once the table has been analyzed.
+func (e *Executor) annotateEstimate(n *planNode, table string, def *storage.TableDef, where parser.Expr) {
+	if est, ok := e.estimateRows(table, def, where); ok {
+		n.Label += fmt.Sprintf("  (rows=%d)", est)
+	}
+}
+
+// estimateRows estimates how many rows of table satisfy where. The
+// selectivity comes from the statistics of the last ANALYZE and is applied
+// to the current row count. ok is false when the table has no statistics.
+func (e *Executor) estimateRows(table string, def *storage.TableDef, where parser.Expr) (int64, bool) {
+	stats := e.engine.Stats(table)
+	if stats == nil {
+		return 0, false
+	}
+	n, err := e.engine.RowCount(table)
+	if err != nil {
+		return 0, false
+	}
+	return int64(math.Round(float64(n) * selectivity(where, def, stats))), true
+}
+
+// selectivity estimates the fraction of rows for which expr is true.
+// Conjunctions are assumed independent; predicates it cannot analyze
+// count as always true.
+func selectivity(expr parser.Expr, def *storage.TableDef, stats *storage.TableStats) float64 {
+	switch x := expr.(type) {
+	case nil:
+		return 1
+	case *parser.BinaryExpr:
+		switch x.Op {
+		case "AND":
+			return selectivity(x.Left, def, stats) * selectivity(x.Right, def, stats)
+		case "OR":
+			l, r := selectivity(x.Left, def, stats), selectivity(x.Right, def, stats)
+			return l + r - l*r
+		}
+		col, op, val, ok := columnComparison(x, def)
+		if !ok {
+			return 1
+		}
+		cs := stats.Columns[col]
+		switch op {
+		case "=":
+			if cs == nil {
+				return defaultEqSel
+			}
+			return cs.EqualFraction(val)
+		case "!=", "<>":
+			if cs == nil {
+				return 1 - defaultEqSel
+			}
+			return 1 - cs.EqualFraction(val)
+		case "<", "<=":
+			if cs == nil {
+				return defaultRangeSel
+			}
+			return cs.RangeFraction(nil, val)
+		case ">", ">=":
+			if cs == nil {
+				return defaultRangeSel
+			}
+			return cs.RangeFraction(val, nil)
+		}
+	case *parser.BetweenExpr:
+		ref, ok := x.Expr.(*parser.ColumnRef)
+		if !ok {
+			return 1
+		}
+		col, lo, ok1 := columnLiteral(ref, x.Low, def)
+		_, hi, ok2 := columnLiteral(ref, x.High, def)
+		if !ok1 || !ok2 {
+			return 1
+		}
+		sel := defaultRangeSel * defaultRangeSel
+		if cs := stats.Columns[col]; cs != nil {
+			sel = cs.RangeFraction(lo, hi)
+		}
+		if x.Not {
+			return 1 - sel
+		}
+		return sel
+	}
+	return 1
+}
+
+// columnComparison matches "column op literal" in either order and returns
+// the column name, the operator as seen from the column, and the literal
+// coerced to the column type.
+func columnComparison(b *parser.BinaryExpr, def *storage.TableDef) (col, op string, val any, ok bool) {
+	flipped := map[string]string{"=": "=", "!=": "!=", "<>": "<>", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+	if _, isCmp := flipped[b.Op]; !isCmp {
+		return "", "", nil, false
+	}
+	if ref, isRef := b.Left.(*parser.ColumnRef); isRef {
+		if col, val, ok := columnLiteral(ref, b.Right, def); ok {
+			return col, b.Op, val, true
+		}
+	}
+	if ref, isRef := b.Right.(*parser.ColumnRef); isRef {
+		if col, val, ok := columnLiteral(ref, b.Left, def); ok {
+			return col, flipped[b.Op], val, true
+		}
+	}
+	return "", "", nil, false
+}
+
+// columnLiteral resolves ref in def and coerces the literal lit to its
+// type.
+func columnLiteral(ref *parser.ColumnRef, lit parser.Expr, def *storage.TableDef) (string, any, bool) {
+	v, ok := literalValue(lit)
+	if !ok {
+		return "", nil, false
+	}
+	for _, c := range def.Columns {
+		if !strings.EqualFold(c.Name, ref.Name) {
+			continue
+		}
+		if !goTypeMatchesDataType(v, c.DataType) {
+			var err error
+			if v, err = coerceLiteral(v, c.DataType); err != nil {
+				return "", nil, false
+			}
+		}
+		return c.Name, v, true
+	}
+	return "", nil, false
+}
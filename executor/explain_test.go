@@ -0,0 +1,425 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// explainPlan runs EXPLAIN and returns the plan lines.
+func explainPlan(t *testing.T, e *Executor, sql string) []string {
+	t.Helper()
+	r := exec(t, e, "EXPLAIN "+sql)
+	if len(r.Columns) != 1 || r.Columns[0].Name != "QUERY PLAN" || r.Columns[0].TypeOID != OIDText {
+		t.Fatalf("unexpected columns %+v", r.Columns)
+	}
+	if r.Tag != "EXPLAIN" {
+		t.Errorf("Tag = %q, want EXPLAIN", r.Tag)
+	}
+	lines := make([]string, len(r.Rows))
+	for i, row := range r.Rows {
+		lines[i] = string(row[0])
+	}
+	return lines
+}
+
+func assertPlan(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("plan mismatch\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func setupExplain(t *testing.T) *Executor {
+	t.Helper()
+	e := setup(t)
+	exec(t, e, "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, age INTEGER)")
+	exec(t, e, "CREATE INDEX idx_email ON users(email)")
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER)")
+	exec(t, e, "INSERT INTO users VALUES (1, 'a@x', 30)")
+	return e
+}
+
+// addUsers fills the users table of setupExplain up to n rows, enough for
+// the planner to consider its indexes.
+func addUsers(t *testing.T, e *Executor, n int) {
+	t.Helper()
+	var values []string
+	for i := 2; i <= n; i++ {
+		values = append(values, fmt.Sprintf("(%d, 'u%d@x', %d)", i, i, 20+i%50))
+	}
+	exec(t, e, "INSERT INTO users VALUES "+strings.Join(values, ", "))
+}
+
+func TestExplain_AccessPaths(t *testing.T) {
+	e := setupExplain(t)
+
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id = 1"),
+		"Primary Key Lookup on users")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users INDEXED BY idx_email WHERE email = 'a@x'"),
+		"Index Scan using idx_email on users")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE age > 3"),
+		"Sequential Scan on users")
+	assertPlan(t, explainPlan(t, e, "SELECT 1"), "Result")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM pg_catalog.pg_type WHERE oid = 20"),
+		"Sequential Scan on pg_catalog.pg_type")
+}
+
+func TestExplain_PKRange(t *testing.T) {
+	e := setupExplain(t)
+	addUsers(t, e, 40)
+
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id > 1000"),
+		"Index Scan using users_pkey on users")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id BETWEEN 1 AND 5 AND age > 3"),
+		"Index Scan using users_pkey on users")
+	// Key order makes the sort unnecessary.
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users ORDER BY id LIMIT 5"),
+		"Limit",
+		"  ->  Index Scan using users_pkey on users")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id < 10 ORDER BY id DESC"),
+		"Sort",
+		"  Sort Key: id DESC",
+		"  ->  Index Scan using users_pkey on users")
+	// Aggregates read the range too, but key order alone does not help them.
+	assertPlan(t, explainPlan(t, e, "SELECT COUNT(*) FROM users WHERE id > 1"),
+		"Aggregate",
+		"  ->  Index Scan using users_pkey on users")
+	assertPlan(t, explainPlan(t, e, "SELECT age, COUNT(*) FROM users WHERE id <= 3 GROUP BY age"),
+		"HashAggregate",
+		"  Group Key: age",
+		"  ->  Index Scan using users_pkey on users")
+	assertPlan(t, explainPlan(t, e, "SELECT COUNT(*) FROM users WHERE age > 1"),
+		"Aggregate",
+		"  ->  Sequential Scan on users")
+	// Other columns keep the sequential scan.
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE age > 3 OR id > 1"),
+		"Sequential Scan on users")
+}
+
+func TestExplain_IndexUnion(t *testing.T) {
+	e := setupExplain(t)
+	addUsers(t, e, 40)
+
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id = 1 OR id = 2 OR id = 1"),
+		"Index Scan using users_pkey on users",
+		"  Index Probes: 2")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE email IN ('a@x', 'b@x') AND age > 3 ORDER BY email"),
+		"Index Scan using idx_email on users",
+		"  Index Probes: 2")
+	assertPlan(t, explainPlan(t, e, "SELECT COUNT(*) FROM users WHERE id IN (1, 2)"),
+		"Aggregate",
+		"  ->  Index Scan using users_pkey on users",
+		"        Index Probes: 2")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id = 1 OR email = 'a@x'"),
+		"Sequential Scan on users")
+}
+
+func TestExplain_IndexRange(t *testing.T) {
+	e := setupExplain(t)
+
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users INDEXED BY idx_email WHERE email >= 'a' ORDER BY email"),
+		"Index Scan using idx_email on users")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users INDEXED BY idx_email WHERE email < 'm' ORDER BY age"),
+		"Sort",
+		"  Sort Key: age",
+		"  ->  Index Scan using idx_email on users")
+}
+
+func TestExplain_ChooseAccess(t *testing.T) {
+	e := setupExplain(t)
+
+	// A table of a few rows is scanned even where an index would serve.
+	for _, sql := range []string{
+		"SELECT * FROM users WHERE id > 1000",
+		"SELECT * FROM users WHERE id IN (1, 2)",
+		"SELECT * FROM users WHERE email >= 'u'",
+	} {
+		assertPlan(t, explainPlan(t, e, sql), "Sequential Scan on users")
+	}
+	// A primary key equality always looks the row up.
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id = 1"),
+		"Primary Key Lookup on users")
+
+	addUsers(t, e, 200)
+	// A range of a secondary index is walked, in key order.
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE email BETWEEN 'u1' AND 'u2' ORDER BY email"),
+		"Index Scan using idx_email on users")
+	// Of several candidates the one estimated to read fewest rows wins:
+	// two equalities are cheaper than a range.
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id > 5 AND email IN ('u7@x', 'u9@x')"),
+		"Index Scan using idx_email on users",
+		"  Index Probes: 2")
+
+	// With statistics, a predicate matching most rows is cheaper to scan.
+	exec(t, e, "ANALYZE users")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id > 20"),
+		"Sequential Scan on users  (rows=180)")
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users WHERE id > 190"),
+		"Index Scan using users_pkey on users  (rows=10)")
+}
+
+func TestExplain_SortLimitAggregate(t *testing.T) {
+	e := setupExplain(t)
+
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM users ORDER BY age DESC, id LIMIT 5"),
+		"Limit",
+		"  ->  Sort",
+		"        Sort Key: age DESC, id",
+		"        ->  Sequential Scan on users",
+	)
+	assertPlan(t, explainPlan(t, e, "SELECT COUNT(*) FROM users WHERE id = 1"),
+		"Aggregate",
+		"  ->  Primary Key Lookup on users",
+	)
+	assertPlan(t, explainPlan(t, e, "SELECT age, COUNT(*) FROM users GROUP BY age"),
+		"HashAggregate",
+		"  Group Key: age",
+		"  ->  Sequential Scan on users",
+	)
+	assertPlan(t, explainPlan(t, e, "SELECT age, email, COUNT(*) FROM users GROUP BY ROLLUP(age, email)"),
+		"HashAggregate",
+		"  Group Key: age, email",
+		"  Group Key: age",
+		"  Group Key: ()",
+		"  ->  Sequential Scan on users",
+	)
+}
+
+func TestExplain_Join(t *testing.T) {
+	e := setupExplain(t)
+
+	assertPlan(t, explainPlan(t, e, "SELECT u.id FROM users u JOIN orders o ON u.id = o.user_id"),
+		"Nested Loop",
+		"  ->  Sequential Scan on users",
+		"  ->  Sequential Scan on orders",
+	)
+}
+
+func TestExplain_UpdateDelete(t *testing.T) {
+	e := setupExplain(t)
+
+	assertPlan(t, explainPlan(t, e, "UPDATE users SET age = 1 WHERE id = 1"),
+		"Update on users",
+		"  ->  Sequential Scan on users",
+	)
+	assertPlan(t, explainPlan(t, e, "DELETE FROM users INDEXED BY idx_email WHERE email = 'a@x'"),
+		"Delete on users",
+		"  ->  Index Scan using idx_email on users",
+	)
+}
+
+func TestExplain_DoesNotExecute(t *testing.T) {
+	e := setupExplain(t)
+
+	exec(t, e, "EXPLAIN DELETE FROM users")
+	r := exec(t, e, "SELECT COUNT(*) FROM users")
+	if string(r.Rows[0][0]) != "1" {
+		t.Errorf("row count after EXPLAIN DELETE = %s, want 1", r.Rows[0][0])
+	}
+}
+
+func TestExplain_Errors(t *testing.T) {
+	e := setupExplain(t)
+
+	_, err := e.Execute("EXPLAIN SELECT * FROM missing")
+	assertSQLSTATE(t, err, "42P01")
+
+	_, err = e.Execute("EXPLAIN SELECT * FROM users INDEXED BY nope WHERE email = 'a'")
+	assertSQLSTATE(t, err, "42704")
+
+	_, err = e.Execute("EXPLAIN SELECT * FROM users INDEXED BY idx_email WHERE age = 1")
+	assertSQLSTATE(t, err, "0A000")
+}
+
+func TestExplainAnalyze(t *testing.T) {
+	e := setupExplain(t)
+	exec(t, e, "INSERT INTO users VALUES (2, 'b@x', 40), (3, 'c@x', 50)")
+
+	lines := explainPlan(t, e, "ANALYZE SELECT * FROM users WHERE age >= 40")
+	if lines[0] != "Sequential Scan on users" {
+		t.Errorf("first line = %q, want the plan", lines[0])
+	}
+	want := map[string]bool{
+		"Statement: SELECT": false,
+		"Table: users":      false,
+		"Rows Scanned: 3":   false,
+		"Rows Returned: 2":  false,
+	}
+	hasTiming := map[string]bool{"Parse: ": false, "Plan: ": false, "Execute: ": false, "Total: ": false}
+	for _, l := range lines[1:] {
+		if _, ok := want[l]; ok {
+			want[l] = true
+		}
+		for prefix := range hasTiming {
+			if strings.HasPrefix(l, prefix) {
+				hasTiming[prefix] = true
+			}
+		}
+	}
+	for l, found := range want {
+		if !found {
+			t.Errorf("missing line %q in %q", l, lines)
+		}
+	}
+	for prefix, found := range hasTiming {
+		if !found {
+			t.Errorf("missing timing line %q in %q", prefix, lines)
+		}
+	}
+}
+
+func TestExplainAnalyze_TextEqualityScan(t *testing.T) {
+	e := setupExplain(t)
+	exec(t, e, "INSERT INTO users VALUES (2, 'b@x', 40), (3, 'c@x', 50)")
+	exec(t, e, "CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT STORAGE DICTIONARY)")
+	exec(t, e, "INSERT INTO tags VALUES (1, 'red'), (2, 'blue'), (3, 'red')")
+
+	// The rows storage skips as unequal count as scanned.
+	tests := []struct {
+		sql      string
+		returned string
+	}{
+		{"SELECT * FROM users WHERE email = 'b@x'", "Rows Returned: 1"},
+		{"SELECT COUNT(*) FROM users WHERE email = 'b@x'", "Rows Returned: 1"},
+		{"SELECT email, COUNT(*) FROM users WHERE email = 'b@x' GROUP BY email", "Rows Returned: 1"},
+		{"SELECT * FROM tags WHERE name = 'red'", "Rows Returned: 2"},
+		{"SELECT * FROM tags WHERE name = 'green'", "Rows Returned: 0"},
+	}
+	for _, tt := range tests {
+		lines := explainPlan(t, e, "ANALYZE "+tt.sql)
+		if !strings.Contains(strings.Join(lines, "\n"), "Sequential Scan on ") {
+			t.Errorf("%s: plan %q has no sequential scan", tt.sql, lines)
+		}
+		for _, want := range []string{"Rows Scanned: 3", tt.returned} {
+			if !slices.Contains(lines, want) {
+				t.Errorf("%s: missing line %q in %q", tt.sql, want, lines)
+			}
+		}
+	}
+}
+
+func TestExplainAnalyze_UsesIndex(t *testing.T) {
+	e := setupExplain(t)
+
+	lines := explainPlan(t, e, "ANALYZE SELECT * FROM users WHERE id = 1")
+	found, access := false, false
+	for _, l := range lines {
+		switch l {
+		case "Used Index: PRIMARY":
+			found = true
+		case "Access Method: index scan":
+			access = true
+		}
+	}
+	if !found {
+		t.Errorf("missing Used Index line in %q", lines)
+	}
+	if !access {
+		t.Errorf("missing Access Method line in %q", lines)
+	}
+}
+
+func TestExplainAnalyze_Executes(t *testing.T) {
+	e := setupExplain(t)
+
+	exec(t, e, "EXPLAIN ANALYZE DELETE FROM users WHERE id = 1")
+	r := exec(t, e, "SELECT COUNT(*) FROM users")
+	if string(r.Rows[0][0]) != "0" {
+		t.Errorf("row count after EXPLAIN ANALYZE DELETE = %s, want 0", r.Rows[0][0])
+	}
+}
+
+func TestExplain_RowEstimates(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE events (id INTEGER PRIMARY KEY, kind INTEGER, note TEXT)")
+	exec(t, e, "CREATE INDEX idx_kind ON events(kind)")
+
+	// Skewed: kind 0 covers half the rows, kinds 1..500 one row each.
+	var values []string
+	for i := 1; i <= 1000; i++ {
+		kind := 0
+		if i%2 == 0 {
+			kind = i / 2
+		}
+		values = append(values, fmt.Sprintf("(%d, %d, 'n')", i, kind))
+	}
+	exec(t, e, "INSERT INTO events VALUES "+strings.Join(values, ", "))
+
+	// No estimates before ANALYZE.
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM events WHERE kind = 0"),
+		"Index Scan using idx_kind on events",
+		"  Index Probes: 1")
+
+	r := exec(t, e, "ANALYZE events")
+	if r.Tag != "ANALYZE" {
+		t.Errorf("tag = %q, want ANALYZE", r.Tag)
+	}
+	if stats := e.Engine().Stats("events"); stats == nil || stats.Columns["kind"] == nil || len(stats.Columns["kind"].Bounds) == 0 {
+		t.Fatalf("ANALYZE built no histogram for kind: %+v", stats)
+	}
+
+	for _, tc := range []struct {
+		where  string
+		actual int
+	}{
+		{"kind = 0", 500},
+		{"kind = 42", 1},
+		{"kind BETWEEN 1 AND 100", 100},
+		{"kind > 400", 100},
+		{"kind = 0 OR kind = 7", 501},
+	} {
+		lines := explainPlan(t, e, "SELECT * FROM events WHERE "+tc.where)
+		// The plan reads idx_kind or scans, whichever is estimated cheaper.
+		var est int
+		_, rows, _ := strings.Cut(lines[0], "  (rows=")
+		if _, err := fmt.Sscanf(rows, "%d)", &est); err != nil {
+			t.Errorf("%s: no estimate in %q", tc.where, lines[0])
+			continue
+		}
+		if est > tc.actual*2+1 || est < tc.actual/2 {
+			t.Errorf("%s: estimated %d rows, actual %d", tc.where, est, tc.actual)
+		}
+	}
+
+	// Unindexed columns have no statistics and get the default selectivity.
+	assertPlan(t, explainPlan(t, e, "SELECT * FROM events WHERE note = 'n'"),
+		"Sequential Scan on events  (rows=5)")
+
+	// Estimates scale with the live row count and reach UPDATE and DELETE.
+	exec(t, e, "DELETE FROM events WHERE id > 500")
+	assertPlan(t, explainPlan(t, e, "DELETE FROM events"),
+		"Delete on events",
+		"  ->  Sequential Scan on events  (rows=500)")
+}
+
+// EXPLAIN and execution take their access path from the same decision,
+// so the plan names the index the statement actually reads.
+func TestExplainAnalyze_PlanMatchesExecution(t *testing.T) {
+	e := setupExplain(t)
+	addUsers(t, e, 200)
+
+	tests := []struct {
+		sql, plan, used string
+	}{
+		{"SELECT * FROM users WHERE id = 1 ORDER BY id", "Primary Key Lookup on users", "PRIMARY"},
+		{"SELECT * FROM users WHERE id = '1'", "Primary Key Lookup on users", "PRIMARY"},
+		{"SELECT * FROM users WHERE email = 'u7@x'", "Index Scan using idx_email on users", "idx_email"},
+		{"SELECT * FROM users WHERE age = 30", "Sequential Scan on users", ""},
+	}
+	for _, tt := range tests {
+		if plan := explainPlan(t, e, tt.sql); plan[0] != tt.plan {
+			t.Errorf("%s: plan = %q, want %q", tt.sql, plan[0], tt.plan)
+		}
+		used := ""
+		for _, l := range explainPlan(t, e, "ANALYZE "+tt.sql) {
+			if strings.HasPrefix(l, "Used Index: ") {
+				used = strings.TrimPrefix(l, "Used Index: ")
+			}
+		}
+		if used != tt.used {
+			t.Errorf("%s: used index = %q, want %q", tt.sql, used, tt.used)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package executor
+
+import "strings"
+
+func init() {
+	RegisterScalar("UPPER", fnUpper)
+	RegisterScalar("LOWER", fnLower)
+}
+
+func fnUpper(args []any) (any, Column, error) {
+	return caseConvert("UPPER", "upper", strings.ToUpper, args)
+}
+
+func fnLower(args []any) (any, Column, error) {
+	return caseConvert("LOWER", "lower", strings.ToLower, args)
+}
+
+// caseConvert applies conv to a single TEXT argument. Case mapping is
+// Unicode-aware but locale-independent.
+func caseConvert(fname, colName string, conv func(string) string, args []any) (any, Column, error) {
+	col := Column{Name: colName, TypeOID: OIDText, TypeSize: -1}
+	if len(args) != 1 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: fname + "() takes exactly one argument"}
+	}
+	if args[0] == nil {
+		return nil, col, nil
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, Column{}, &QueryError{Code: "42883", Message: fname + "() requires a TEXT argument"}
+	}
+	return conv(s), col, nil
+}
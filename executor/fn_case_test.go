@@ -0,0 +1,52 @@
+package executor
+
+import "testing"
+
+func TestUpperLower_Static(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT UPPER('héllo'), LOWER('WORLD')")
+	if string(r.Rows[0][0]) != "HÉLLO" {
+		t.Errorf("UPPER('héllo') = %q, want HÉLLO", r.Rows[0][0])
+	}
+	if string(r.Rows[0][1]) != "world" {
+		t.Errorf("LOWER('WORLD') = %q, want world", r.Rows[0][1])
+	}
+	if r.Columns[0].Name != "upper" || r.Columns[0].TypeOID != OIDText {
+		t.Errorf("UPPER column = %+v, want upper/text", r.Columns[0])
+	}
+}
+
+func TestUpperLower_Null(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT UPPER(NULL), LOWER(NULL)")
+	if r.Rows[0][0] != nil || r.Rows[0][1] != nil {
+		t.Errorf("got %v, want NULLs", r.Rows[0])
+	}
+}
+
+func TestUpperLower_FromTable(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (name TEXT)")
+	exec(t, e, "INSERT INTO t VALUES ('Alice'), ('bob')")
+
+	r := exec(t, e, "SELECT name FROM t WHERE LOWER(name) = 'alice'")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "Alice" {
+		t.Fatalf("got %v, want [Alice]", r.Rows)
+	}
+
+	r = exec(t, e, "SELECT UPPER(name) FROM t WHERE name = 'bob'")
+	if string(r.Rows[0][0]) != "BOB" {
+		t.Errorf("UPPER(name) = %q, want BOB", r.Rows[0][0])
+	}
+	if r.Columns[0].TypeOID != OIDText {
+		t.Errorf("OID = %d, want %d", r.Columns[0].TypeOID, OIDText)
+	}
+}
+
+func TestUpper_WrongType(t *testing.T) {
+	e := setup(t)
+	_, err := e.Execute("SELECT UPPER(1)")
+	assertSQLSTATE(t, err, "42883")
+}
@@ -225,3 +225,30 @@ func TestCoalesce_CaseInsensitive(t *testing.T) {
 		t.Fatalf("Coalesce(NULL, 'test') = %q, want 'test'", r.Rows[0][0])
 	}
 }
+
+func TestCoalesce_UnifiedTypeOID(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (i INTEGER, f FLOAT, s TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (NULL, NULL, NULL)")
+
+	tests := []struct {
+		sql  string
+		want int32
+	}{
+		{"SELECT COALESCE(i, 0) FROM t", OIDInt8},
+		{"SELECT COALESCE(i, NULL) FROM t", OIDInt8},
+		{"SELECT COALESCE(NULL, i) FROM t", OIDInt8},
+		{"SELECT COALESCE(i, 1.5) FROM t", OIDFloat8},
+		{"SELECT COALESCE(i, f) FROM t", OIDFloat8},
+		{"SELECT COALESCE(s, 'none') FROM t", OIDText},
+		{"SELECT COALESCE(s, NULL) FROM t", OIDText},
+		{"SELECT COALESCE(1, 2.5)", OIDFloat8},
+		{"SELECT COALESCE(NULL::INTEGER, NULL)", OIDInt8},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		if r.Columns[0].TypeOID != tt.want {
+			t.Errorf("%s: TypeOID = %d, want %d", tt.sql, r.Columns[0].TypeOID, tt.want)
+		}
+	}
+}
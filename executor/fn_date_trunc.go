@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterScalar("DATE_TRUNC", fnDateTrunc)
+}
+
+// fnDateTrunc implements DATE_TRUNC(unit, ts), which zeroes every field of ts
+// below unit. Weeks start on Monday, and centuries and millennia in a year
+// ending in 1, as in PostgreSQL.
+func fnDateTrunc(args []any) (any, Column, error) {
+	col := Column{Name: "date_trunc", TypeOID: OIDTimestampTZ, TypeSize: 8}
+	if len(args) != 2 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "DATE_TRUNC() takes 2 arguments"}
+	}
+	if args[0] == nil {
+		return nil, col, nil
+	}
+	unit, ok := args[0].(string)
+	if !ok {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "DATE_TRUNC() unit must be TEXT"}
+	}
+	unit = canonicalUnit(unit)
+	switch unit {
+	case "microseconds", "milliseconds", "second", "minute", "hour", "day", "week", "month", "quarter", "year",
+		"decade", "century", "millennium":
+	default:
+		return nil, Column{}, &QueryError{Code: "22023", Message: fmt.Sprintf("unit %q not recognized for type timestamp", unit)}
+	}
+
+	t, ok, err := timestampArg(args[1], "DATE_TRUNC")
+	if err != nil || !ok {
+		return nil, col, err
+	}
+	t = t.UTC()
+	y, m, d := t.Date()
+	switch unit {
+	case "microseconds":
+		return t.Truncate(time.Microsecond), col, nil
+	case "milliseconds":
+		return t.Truncate(time.Millisecond), col, nil
+	case "second":
+		return t.Truncate(time.Second), col, nil
+	case "minute":
+		return t.Truncate(time.Minute), col, nil
+	case "hour":
+		return t.Truncate(time.Hour), col, nil
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), col, nil
+	case "week":
+		back := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(y, m, d-back, 0, 0, 0, 0, time.UTC), col, nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC), col, nil
+	case "quarter":
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, time.UTC), col, nil
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC), col, nil
+	case "decade":
+		return time.Date(y-y%10, 1, 1, 0, 0, 0, 0, time.UTC), col, nil
+	case "century":
+		return time.Date((y-1)/100*100+1, 1, 1, 0, 0, 0, 0, time.UTC), col, nil
+	default: // millennium
+		return time.Date((y-1)/1000*1000+1, 1, 1, 0, 0, 0, 0, time.UTC), col, nil
+	}
+}
+
+// unitAliases maps the other spellings PostgreSQL accepts for a unit of
+// DATE_TRUNC or a field of EXTRACT to the name used here.
+var unitAliases = map[string]string{
+	"us": "microseconds", "usec": "microseconds", "usecs": "microseconds", "useconds": "microseconds", "microsecond": "microseconds",
+	"ms": "milliseconds", "msec": "milliseconds", "msecs": "milliseconds", "mseconds": "milliseconds", "millisecond": "milliseconds",
+	"s": "second", "sec": "second", "secs": "second", "seconds": "second",
+	"m": "minute", "min": "minute", "mins": "minute", "minutes": "minute",
+	"h": "hour", "hr": "hour", "hrs": "hour", "hours": "hour",
+	"d": "day", "days": "day",
+	"w": "week", "weeks": "week",
+	"mon": "month", "mons": "month", "months": "month",
+	"y": "year", "yr": "year", "yrs": "year", "years": "year",
+	"qtr": "quarter",
+	"dec": "decade", "decs": "decade", "decades": "decade",
+	"c": "century", "cent": "century", "centuries": "century",
+	"mil": "millennium", "mils": "millennium", "millennia": "millennium",
+}
+
+// canonicalUnit returns the lowercased unit name, with aliases such as
+// 'hours' or 'mon' replaced by the name they stand for.
+func canonicalUnit(unit string) string {
+	unit = strings.ToLower(unit)
+	if name, ok := unitAliases[unit]; ok {
+		return name
+	}
+	return unit
+}
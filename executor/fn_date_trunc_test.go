@@ -0,0 +1,84 @@
+package executor
+
+import "testing"
+
+func TestDateTrunc_Static(t *testing.T) {
+	e := setup(t)
+
+	const ts = "'2024-05-15 13:45:30'::TIMESTAMP" // a Wednesday
+	tests := []struct {
+		unit string
+		want string
+	}{
+		{"second", "2024-05-15 13:45:30+00"},
+		{"minute", "2024-05-15 13:45:00+00"},
+		{"hour", "2024-05-15 13:00:00+00"},
+		{"day", "2024-05-15 00:00:00+00"},
+		{"week", "2024-05-13 00:00:00+00"},
+		{"month", "2024-05-01 00:00:00+00"},
+		{"QUARTER", "2024-04-01 00:00:00+00"},
+		{"year", "2024-01-01 00:00:00+00"},
+		{"decade", "2020-01-01 00:00:00+00"},
+		{"century", "2001-01-01 00:00:00+00"},
+		{"millennium", "2001-01-01 00:00:00+00"},
+		{"hours", "2024-05-15 13:00:00+00"},
+		{"Mon", "2024-05-01 00:00:00+00"},
+		{"mins", "2024-05-15 13:45:00+00"},
+	}
+	for _, tt := range tests {
+		sql := "SELECT DATE_TRUNC('" + tt.unit + "', " + ts + ")"
+		r := exec(t, e, sql)
+		if string(r.Rows[0][0]) != tt.want {
+			t.Errorf("%s = %q, want %q", sql, r.Rows[0][0], tt.want)
+		}
+		if r.Columns[0].Name != "date_trunc" || r.Columns[0].TypeOID != OIDTimestampTZ {
+			t.Errorf("%s column = %+v, want date_trunc TIMESTAMPTZ", sql, r.Columns[0])
+		}
+	}
+}
+
+func TestDateTrunc_Null(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT DATE_TRUNC('day', NULL), DATE_TRUNC(NULL, NOW())")
+	if r.Rows[0][0] != nil || r.Rows[0][1] != nil {
+		t.Errorf("got %q, want NULLs", r.Rows[0])
+	}
+	if r.Columns[1].TypeOID != OIDTimestampTZ {
+		t.Errorf("NULL unit OID = %d, want %d", r.Columns[1].TypeOID, OIDTimestampTZ)
+	}
+}
+
+func TestDateTrunc_UnknownUnit(t *testing.T) {
+	e := setup(t)
+
+	_, err := e.Execute("SELECT DATE_TRUNC('fortnight', NOW())")
+	assertSQLSTATE(t, err, "22023")
+}
+
+func TestDateTrunc_GroupBy(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, created_at TIMESTAMP)")
+	exec(t, e, `INSERT INTO orders VALUES
+		(1, '2024-01-05 10:00:00'), (2, '2024-01-20 11:00:00'),
+		(3, '2024-02-01 00:00:00'), (4, NULL)`)
+
+	for _, sql := range []string{
+		"SELECT DATE_TRUNC('month', created_at), COUNT(*) FROM orders GROUP BY 1 ORDER BY date_trunc",
+		"SELECT DATE_TRUNC('month', created_at) AS m, COUNT(*) FROM orders GROUP BY DATE_TRUNC('month', created_at) ORDER BY m",
+	} {
+		r := exec(t, e, sql)
+		if len(r.Rows) != 3 {
+			t.Fatalf("%s: got %d rows, want 3", sql, len(r.Rows))
+		}
+		if r.Columns[0].TypeOID != OIDTimestampTZ {
+			t.Errorf("%s: OID = %d, want %d", sql, r.Columns[0].TypeOID, OIDTimestampTZ)
+		}
+		if string(r.Rows[0][0]) != "2024-01-01 00:00:00+00" || string(r.Rows[0][1]) != "2" {
+			t.Errorf("%s: first row = %q, want January with 2 orders", sql, r.Rows[0])
+		}
+		if string(r.Rows[1][0]) != "2024-02-01 00:00:00+00" || string(r.Rows[1][1]) != "1" {
+			t.Errorf("%s: second row = %q, want February with 1 order", sql, r.Rows[1])
+		}
+	}
+}
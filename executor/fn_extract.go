@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"mulldb/storage"
+)
+
+func init() {
+	RegisterScalar("EXTRACT", fnExtract)
+	RegisterScalar("DATE_PART", fnDatePart)
+}
+
+// fnExtract implements EXTRACT(field FROM ts). The parser passes the field as
+// a lowercased string argument. second and epoch include the fractional part
+// and are FLOAT; every other field is an INTEGER. dow counts from Sunday = 0,
+// doy from January 1 = 1, and the 21st century and 3rd millennium begin in
+// 2001. Fields may be spelled as PostgreSQL allows, e.g. 'hours' or 'mon'. A TIME has only hour, minute, second and epoch.
+func fnExtract(args []any) (any, Column, error) {
+	return extractField("extract", args)
+}
+
+// fnDatePart implements DATE_PART(field, ts), the function form of EXTRACT.
+func fnDatePart(args []any) (any, Column, error) {
+	return extractField("date_part", args)
+}
+
+// extractField returns the field args[0] of the timestamp or time args[1],
+// in a column called name.
+func extractField(name string, args []any) (any, Column, error) {
+	fname := strings.ToUpper(name)
+	if len(args) != 2 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: fname + "() takes a field and a timestamp"}
+	}
+	intCol := Column{Name: name, TypeOID: OIDInt8, TypeSize: 8}
+	floatCol := Column{Name: name, TypeOID: OIDFloat8, TypeSize: 8}
+	if args[0] == nil {
+		return nil, intCol, nil
+	}
+	field, ok := args[0].(string)
+	if !ok {
+		return nil, Column{}, &QueryError{Code: "42883", Message: fname + "() field must be TEXT"}
+	}
+	field = canonicalUnit(field)
+	col := intCol
+	switch field {
+	case "year", "month", "day", "hour", "minute", "dow", "doy", "decade", "century", "millennium":
+	case "second", "epoch":
+		col = floatCol
+	default:
+		return nil, Column{}, &QueryError{Code: "22023", Message: fmt.Sprintf("unit %q not recognized for type timestamp", field)}
+	}
+
+	if tod, ok := args[1].(storage.TimeOfDay); ok {
+		usec := int64(tod)
+		switch field {
+		case "hour":
+			return usec / 3600e6, col, nil
+		case "minute":
+			return usec / 60e6 % 60, col, nil
+		case "second":
+			return float64(usec%60e6) / 1e6, col, nil
+		case "epoch":
+			return float64(usec) / 1e6, col, nil
+		default:
+			return nil, Column{}, &QueryError{Code: "22023", Message: fmt.Sprintf("unit %q not supported for type time", field)}
+		}
+	}
+	t, ok, err := timestampArg(args[1], fname)
+	if err != nil || !ok {
+		return nil, col, err
+	}
+	switch field {
+	case "year":
+		return int64(t.Year()), col, nil
+	case "month":
+		return int64(t.Month()), col, nil
+	case "day":
+		return int64(t.Day()), col, nil
+	case "hour":
+		return int64(t.Hour()), col, nil
+	case "minute":
+		return int64(t.Minute()), col, nil
+	case "dow":
+		return int64(t.Weekday()), col, nil
+	case "doy":
+		return int64(t.YearDay()), col, nil
+	case "decade":
+		return int64(t.Year() / 10), col, nil
+	case "century":
+		return int64((t.Year() + 99) / 100), col, nil
+	case "millennium":
+		return int64((t.Year() + 999) / 1000), col, nil
+	case "second":
+		return float64(t.Second()) + float64(t.Nanosecond())/1e9, col, nil
+	default: // epoch
+		return float64(t.UnixMicro()) / 1e6, col, nil
+	}
+}
@@ -0,0 +1,112 @@
+package executor
+
+import "testing"
+
+func TestExtract_Static(t *testing.T) {
+	e := setup(t)
+
+	tests := []struct {
+		sql  string
+		want string
+		oid  int32
+	}{
+		{"SELECT EXTRACT(year FROM '2024-03-15 10:30:45'::TIMESTAMP)", "2024", OIDInt8},
+		{"SELECT EXTRACT(MONTH FROM '2024-03-15 10:30:45'::TIMESTAMP)", "3", OIDInt8},
+		{"SELECT EXTRACT(day FROM '2024-03-15 10:30:45'::TIMESTAMP)", "15", OIDInt8},
+		{"SELECT EXTRACT(hour FROM '2024-03-15 10:30:45'::TIMESTAMP)", "10", OIDInt8},
+		{"SELECT EXTRACT(minute FROM '2024-03-15 10:30:45'::TIMESTAMP)", "30", OIDInt8},
+		{"SELECT EXTRACT(second FROM '2024-03-15 10:30:45'::TIMESTAMP)", "45", OIDFloat8},
+		{"SELECT EXTRACT(dow FROM '2024-03-17 00:00:00'::TIMESTAMP)", "0", OIDInt8}, // Sunday
+		{"SELECT EXTRACT(epoch FROM '1970-01-02 00:00:00'::TIMESTAMP)", "86400", OIDFloat8},
+		{"SELECT EXTRACT(doy FROM '2024-03-15 10:30:45'::TIMESTAMP)", "75", OIDInt8}, // leap year
+		{"SELECT EXTRACT('year' FROM '2024-03-15 10:30:45')", "2024", OIDInt8},
+		{"SELECT EXTRACT(decade FROM '2024-03-15 10:30:45'::TIMESTAMP)", "202", OIDInt8},
+		{"SELECT EXTRACT(century FROM '2000-12-31 00:00:00'::TIMESTAMP)", "20", OIDInt8},
+		{"SELECT EXTRACT(millennium FROM '2001-01-01 00:00:00'::TIMESTAMP)", "3", OIDInt8},
+		{"SELECT EXTRACT(hours FROM '2024-03-15 10:30:45'::TIMESTAMP)", "10", OIDInt8},
+		{"SELECT EXTRACT('mon' FROM '2024-03-15 10:30:45')", "3", OIDInt8},
+		{"SELECT EXTRACT(hour FROM TIME '13:45:30.5')", "13", OIDInt8},
+		{"SELECT EXTRACT(minute FROM TIME '13:45:30.5')", "45", OIDInt8},
+		{"SELECT EXTRACT(second FROM TIME '13:45:30.5')", "30.5", OIDFloat8},
+		{"SELECT EXTRACT(epoch FROM TIME '01:00:00')", "3600", OIDFloat8},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		if string(r.Rows[0][0]) != tt.want {
+			t.Errorf("%s = %q, want %q", tt.sql, r.Rows[0][0], tt.want)
+		}
+		if r.Columns[0].Name != "extract" || r.Columns[0].TypeOID != tt.oid {
+			t.Errorf("%s column = %+v, want extract with OID %d", tt.sql, r.Columns[0], tt.oid)
+		}
+	}
+}
+
+func TestExtract_Null(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT EXTRACT(year FROM NULL)")
+	if r.Rows[0][0] != nil {
+		t.Errorf("got %q, want NULL", r.Rows[0][0])
+	}
+}
+
+func TestExtract_Errors(t *testing.T) {
+	e := setup(t)
+
+	_, err := e.Execute("SELECT EXTRACT(fortnight FROM NOW())")
+	assertSQLSTATE(t, err, "22023")
+	_, err = e.Execute("SELECT EXTRACT(year FROM 'not a time')")
+	assertSQLSTATE(t, err, "22007")
+	_, err = e.Execute("SELECT EXTRACT(year FROM 42)")
+	assertSQLSTATE(t, err, "42883")
+	_, err = e.Execute("SELECT EXTRACT(day FROM TIME '12:00')")
+	assertSQLSTATE(t, err, "22023")
+	_, err = e.Execute("SELECT DATE_PART('month')")
+	assertSQLSTATE(t, err, "42883")
+}
+
+func TestDatePart(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT DATE_PART('Month', '2024-03-15 10:30:45'::TIMESTAMP), date_part('second', '2024-03-15 10:30:45.25'), DATE_PART('dow', NULL)")
+	if string(r.Rows[0][0]) != "3" || string(r.Rows[0][1]) != "45.25" || r.Rows[0][2] != nil {
+		t.Errorf("got %q, want 3, 45.25 and NULL", r.Rows[0])
+	}
+	if r.Columns[0].Name != "date_part" || r.Columns[0].TypeOID != OIDInt8 || r.Columns[1].TypeOID != OIDFloat8 {
+		t.Errorf("columns = %+v, want date_part INTEGER then FLOAT", r.Columns[:2])
+	}
+}
+
+func TestExtract_FromTable(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, created_at TIMESTAMP)")
+	exec(t, e, "INSERT INTO orders VALUES (1, '2023-12-31 23:00:00'), (2, '2024-01-01 08:00:00'), (3, NULL)")
+
+	r := exec(t, e, "SELECT id, EXTRACT(year FROM created_at), EXTRACT(epoch FROM created_at) FROM orders WHERE EXTRACT(year FROM created_at) = 2024")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "2" || string(r.Rows[0][1]) != "2024" {
+		t.Fatalf("got %v, want one row for id 2 in 2024", r.Rows)
+	}
+	if r.Columns[1].TypeOID != OIDInt8 || r.Columns[2].TypeOID != OIDFloat8 {
+		t.Errorf("OIDs = %d, %d, want %d, %d", r.Columns[1].TypeOID, r.Columns[2].TypeOID, OIDInt8, OIDFloat8)
+	}
+
+	r = exec(t, e, "SELECT EXTRACT(month FROM created_at) FROM orders WHERE id = 3")
+	if r.Rows[0][0] != nil {
+		t.Errorf("got %q, want NULL for NULL timestamp", r.Rows[0][0])
+	}
+}
+
+func TestExtract_GroupBy(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, created_at TIMESTAMP)")
+	exec(t, e, "INSERT INTO orders VALUES (1, '2023-12-31 23:00:00'), (2, '2024-01-01 08:00:00'), (3, NULL), (4, '2024-05-01')")
+
+	r := exec(t, e, "SELECT EXTRACT(year FROM created_at), COUNT(*) FROM orders GROUP BY EXTRACT(year FROM created_at) ORDER BY 1")
+	if got := groupRows(r); len(got) != 3 || got[0] != "2023|1" || got[1] != "2024|2" || got[2] != "-|1" {
+		t.Errorf("got %v, want [2023|1 2024|2 -|1]", got)
+	}
+	r = exec(t, e, "SELECT date_part('month', created_at), COUNT(*) FROM orders WHERE created_at IS NOT NULL GROUP BY 1 ORDER BY 1")
+	if got := groupRows(r); len(got) != 3 || got[0] != "1|1" || got[1] != "5|1" || got[2] != "12|1" {
+		t.Errorf("got %v, want [1|1 5|1 12|1]", got)
+	}
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"mulldb/storage"
+)
+
+func init() {
+	RegisterScalar("GREATEST", fnGreatest)
+	RegisterScalar("LEAST", fnLeast)
+}
+
+func fnGreatest(args []any) (any, Column, error) {
+	return extremum("GREATEST", args, 1)
+}
+
+func fnLeast(args []any) (any, Column, error) {
+	return extremum("LEAST", args, -1)
+}
+
+// extremum returns the argument that compares as sign against all others,
+// ignoring NULLs. It is NULL only if every argument is.
+func extremum(name string, args []any, sign int) (any, Column, error) {
+	if len(args) < 1 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: fmt.Sprintf("%s() requires at least one argument", name)}
+	}
+	var best any
+	for _, a := range args {
+		if a == nil {
+			continue
+		}
+		if best == nil {
+			best = a
+			continue
+		}
+		c := storage.CompareValues(a, best)
+		if c == -2 {
+			return nil, Column{}, &QueryError{Code: "42883", Message: fmt.Sprintf("%s() arguments must be of comparable types", name)}
+		}
+		if c == sign {
+			best = a
+		}
+	}
+	oid, size := valueTypeOID(best)
+	return best, Column{Name: strings.ToLower(name), TypeOID: oid, TypeSize: size}, nil
+}
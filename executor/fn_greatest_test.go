@@ -0,0 +1,57 @@
+package executor
+
+import "testing"
+
+func TestGreatestLeast(t *testing.T) {
+	e := setup(t)
+
+	tests := []struct {
+		sql  string
+		want any
+	}{
+		{"SELECT GREATEST(1, 3, 2)", "3"},
+		{"SELECT LEAST(1, 3, 2)", "1"},
+		{"SELECT GREATEST('apple', 'pear')", "pear"},
+		{"SELECT LEAST('apple', 'pear')", "apple"},
+		{"SELECT GREATEST(NULL, 2, NULL)", "2"},
+		{"SELECT LEAST(NULL, NULL)", nil},
+		{"SELECT GREATEST(1, 2.5)", "2.5"},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		got := r.Rows[0][0]
+		if tt.want == nil && got != nil || tt.want != nil && string(got) != tt.want {
+			t.Errorf("%s = %q, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestGreatestLeast_TypeOID(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (i INTEGER, f FLOAT, s TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (NULL, NULL, NULL)")
+
+	tests := []struct {
+		sql  string
+		want int32
+	}{
+		{"SELECT GREATEST(i, NULL) FROM t", OIDInt8},
+		{"SELECT LEAST(i, 5) FROM t", OIDInt8},
+		{"SELECT GREATEST(i, f) FROM t", OIDFloat8},
+		{"SELECT LEAST(s, NULL) FROM t", OIDText},
+		{"SELECT GREATEST(3, 2.5)", OIDFloat8},
+		{"SELECT LEAST(NULL, NULL)", OIDUnknown},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		if r.Columns[0].TypeOID != tt.want {
+			t.Errorf("%s: TypeOID = %d, want %d", tt.sql, r.Columns[0].TypeOID, tt.want)
+		}
+	}
+}
+
+func TestGreatest_Incomparable(t *testing.T) {
+	e := setup(t)
+	_, err := e.Execute("SELECT GREATEST(1, 'a')")
+	assertSQLSTATE(t, err, "42883")
+}
@@ -52,6 +52,10 @@ func fnRound(args []any) (any, Column, error) {
 		return nil, Column{}, &QueryError{Code: "42883", Message: "ROUND() requires a numeric argument"}
 	}
 	if len(args) == 1 {
+		// An integer is already rounded; keep its type.
+		if i, ok := args[0].(int64); ok {
+			return i, intCol, nil
+		}
 		return math.Round(x), floatCol, nil
 	}
 	// ROUND(x, n) — round to n decimal places.
@@ -73,6 +77,9 @@ func fnCeil(args []any) (any, Column, error) {
 	if args[0] == nil {
 		return nil, floatCol, nil
 	}
+	if i, ok := args[0].(int64); ok {
+		return i, intCol, nil
+	}
 	x, ok := toFloat64(args[0])
 	if !ok {
 		return nil, Column{}, &QueryError{Code: "42883", Message: "CEIL() requires a numeric argument"}
@@ -87,6 +94,9 @@ func fnFloor(args []any) (any, Column, error) {
 	if args[0] == nil {
 		return nil, floatCol, nil
 	}
+	if i, ok := args[0].(int64); ok {
+		return i, intCol, nil
+	}
 	x, ok := toFloat64(args[0])
 	if !ok {
 		return nil, Column{}, &QueryError{Code: "42883", Message: "FLOOR() requires a numeric argument"}
@@ -132,6 +142,8 @@ func fnSqrt(args []any) (any, Column, error) {
 	return math.Sqrt(x), floatCol, nil
 }
 
+// fnMod mirrors the % operator: a zero divisor is SQLSTATE 22012, which
+// compiled row expressions surface as NULL just as they do for %.
 func fnMod(args []any) (any, Column, error) {
 	if len(args) != 2 {
 		return nil, Column{}, &QueryError{Code: "42883", Message: "MOD() takes exactly 2 arguments"}
@@ -57,6 +57,14 @@ func TestFnRound(t *testing.T) {
 	if string(r.Rows[0][0]) != "5" {
 		t.Errorf("ROUND(5) = %q, want 5", r.Rows[0][0])
 	}
+	if r.Columns[0].TypeOID != OIDInt8 {
+		t.Errorf("ROUND(5) OID = %d, want %d (int)", r.Columns[0].TypeOID, OIDInt8)
+	}
+
+	r = exec(t, e, "SELECT ROUND(5, 1)")
+	if r.Columns[0].TypeOID != OIDFloat8 {
+		t.Errorf("ROUND(5, 1) OID = %d, want %d (float)", r.Columns[0].TypeOID, OIDFloat8)
+	}
 }
 
 func TestFnCeil(t *testing.T) {
@@ -76,6 +84,9 @@ func TestFnCeil(t *testing.T) {
 	if string(r.Rows[0][0]) != "5" {
 		t.Errorf("CEIL(5) = %q, want 5", r.Rows[0][0])
 	}
+	if r.Columns[0].TypeOID != OIDInt8 {
+		t.Errorf("CEIL(5) OID = %d, want %d (int)", r.Columns[0].TypeOID, OIDInt8)
+	}
 }
 
 func TestFnFloor(t *testing.T) {
@@ -90,6 +101,14 @@ func TestFnFloor(t *testing.T) {
 	if string(r.Rows[0][0]) != "-3" {
 		t.Errorf("FLOOR(-2.3) = %q, want -3", r.Rows[0][0])
 	}
+	if r.Columns[0].TypeOID != OIDFloat8 {
+		t.Errorf("FLOOR(-2.3) OID = %d, want %d (float)", r.Columns[0].TypeOID, OIDFloat8)
+	}
+
+	r = exec(t, e, "SELECT FLOOR(-4)")
+	if string(r.Rows[0][0]) != "-4" || r.Columns[0].TypeOID != OIDInt8 {
+		t.Errorf("FLOOR(-4) = %q (OID %d), want -4 (int)", r.Rows[0][0], r.Columns[0].TypeOID)
+	}
 }
 
 func TestFnPower(t *testing.T) {
@@ -148,14 +167,51 @@ func TestFnMod(t *testing.T) {
 		t.Errorf("MOD(7.5, 2.0) = %q, want 1.5", r.Rows[0][0])
 	}
 
-	// Division by zero.
+	// Division by zero, like the % operator.
 	_, err := e.Execute("SELECT MOD(5, 0)")
-	if err == nil {
-		t.Fatal("expected error for MOD(5, 0)")
+	assertSQLSTATE(t, err, "22012")
+	_, err = e.Execute("SELECT MOD(5.5, 0.0)")
+	assertSQLSTATE(t, err, "22012")
+}
+
+func TestFnMath_InExpressions(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE p (id INTEGER PRIMARY KEY, price_cents INTEGER)")
+	exec(t, e, "INSERT INTO p VALUES (1, 1999), (2, -250)")
+
+	r := exec(t, e, "SELECT ROUND(price_cents / 100.0, 2) FROM p WHERE id = 1")
+	if string(r.Rows[0][0]) != "19.99" {
+		t.Errorf("ROUND(price_cents / 100.0, 2) = %q, want 19.99", r.Rows[0][0])
 	}
-	var qe *QueryError
-	if !errors.As(err, &qe) || qe.Code != "22012" {
-		t.Errorf("expected SQLSTATE 22012, got %v", err)
+	if r.Columns[0].TypeOID != OIDFloat8 {
+		t.Errorf("OID = %d, want %d (float)", r.Columns[0].TypeOID, OIDFloat8)
+	}
+
+	r = exec(t, e, "SELECT id FROM p WHERE ABS(price_cents) < 1000")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "2" {
+		t.Errorf("WHERE ABS(price_cents) < 1000 = %v, want [2]", r.Rows)
+	}
+
+	r = exec(t, e, "SELECT ABS(price_cents) + 1 FROM p WHERE id = 2")
+	if string(r.Rows[0][0]) != "251" {
+		t.Errorf("ABS(price_cents) + 1 = %q, want 251", r.Rows[0][0])
+	}
+
+	r = exec(t, e, "SELECT SQRT(POWER(3, 2) + POWER(4, 2))")
+	if string(r.Rows[0][0]) != "5" {
+		t.Errorf("SQRT(POWER(3, 2) + POWER(4, 2)) = %q, want 5", r.Rows[0][0])
+	}
+
+	// Half away from zero in both directions.
+	r = exec(t, e, "SELECT ROUND(-2.5), ROUND(0.125, 2)")
+	if string(r.Rows[0][0]) != "-3" || string(r.Rows[0][1]) != "0.13" {
+		t.Errorf("ROUND(-2.5), ROUND(0.125, 2) = %q, %q, want -3, 0.13", r.Rows[0][0], r.Rows[0][1])
+	}
+
+	// Per-row MOD by zero yields NULL, matching the % operator in row context.
+	r = exec(t, e, "SELECT MOD(price_cents, 0), price_cents % 0 FROM p WHERE id = 1")
+	if r.Rows[0][0] != nil || r.Rows[0][1] != nil {
+		t.Errorf("MOD(price_cents, 0), price_cents %% 0 = %v, want NULLs", r.Rows[0])
 	}
 }
 
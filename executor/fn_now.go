@@ -1,14 +1,77 @@
 package executor
 
-import "time"
+import (
+	"time"
+
+	"mulldb/parser"
+)
 
 func init() {
 	RegisterScalar("NOW", fnNow)
+	RegisterScalar("CURRENT_TIMESTAMP", fnCurrentTimestamp)
+	RegisterScalar("CURRENT_DATE", fnCurrentDate)
 }
 
 func fnNow(args []any) (any, Column, error) {
 	if len(args) != 0 {
 		return nil, Column{}, &QueryError{Code: "42883", Message: "NOW() takes no arguments"}
 	}
-	return time.Now().UTC(), Column{Name: "now", TypeOID: OIDTimestampTZ, TypeSize: 8}, nil
+	return currentTime(), Column{Name: "now", TypeOID: OIDTimestampTZ, TypeSize: 8}, nil
+}
+
+func fnCurrentTimestamp(args []any) (any, Column, error) {
+	if len(args) != 0 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "CURRENT_TIMESTAMP takes no arguments"}
+	}
+	return currentTime(), Column{Name: "current_timestamp", TypeOID: OIDTimestampTZ, TypeSize: 8}, nil
+}
+
+// fnCurrentDate implements CURRENT_DATE. There is no DATE type, so it is
+// the TIMESTAMP that starts the current day, in UTC.
+func fnCurrentDate(args []any) (any, Column, error) {
+	if len(args) != 0 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "CURRENT_DATE takes no arguments"}
+	}
+	return startOfDay(currentTime()), Column{Name: "current_date", TypeOID: OIDTimestampTZ, TypeSize: 8}, nil
+}
+
+// currentTime returns the time now, in UTC. Storage keeps microseconds;
+// truncating here keeps a bound NOW() equal to the value it was stored as.
+func currentTime() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// startOfDay returns midnight of the day of t, which is in UTC.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// statementTime returns the time NOW() has in the next statement e runs:
+// the start of its transaction, or else the time now.
+func (e *Executor) statementTime() time.Time {
+	if !e.txTime.IsZero() {
+		return e.txTime
+	}
+	return currentTime()
+}
+
+// bindStatementTime replaces every NOW() and CURRENT_TIMESTAMP in stmt with
+// a TimestampLit holding now, and every CURRENT_DATE with one holding the
+// start of its day, so that all rows of a statement — every VALUES row of
+// an INSERT, every row an UPDATE touches — see the same time. SELECT
+// columns keep the function's column name through an alias.
+func bindStatementTime(stmt parser.Statement, now time.Time) {
+	bindStatement(stmt, func(fn *parser.FunctionCallExpr) parser.Expr {
+		if len(fn.Args) != 0 {
+			return nil
+		}
+		switch fn.Name {
+		case "NOW", "CURRENT_TIMESTAMP":
+			return &parser.TimestampLit{Value: now}
+		case "CURRENT_DATE":
+			return &parser.TimestampLit{Value: startOfDay(now)}
+		}
+		return nil
+	})
 }
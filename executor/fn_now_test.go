@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+func TestNow_Columns(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT NOW(), CURRENT_TIMESTAMP, NOW() AS ts")
+	want := []string{"now", "current_timestamp", "ts"}
+	for i, name := range want {
+		if r.Columns[i].Name != name {
+			t.Errorf("column %d name = %q, want %q", i, r.Columns[i].Name, name)
+		}
+		if r.Columns[i].TypeOID != OIDTimestampTZ {
+			t.Errorf("column %d OID = %d, want %d", i, r.Columns[i].TypeOID, OIDTimestampTZ)
+		}
+	}
+	if string(r.Rows[0][0]) != string(r.Rows[0][1]) {
+		t.Errorf("NOW() = %q, CURRENT_TIMESTAMP = %q, want equal", r.Rows[0][0], r.Rows[0][1])
+	}
+}
+
+func TestNow_Arguments(t *testing.T) {
+	e := setup(t)
+
+	_, err := e.Execute("SELECT NOW(1)")
+	assertSQLSTATE(t, err, "42883")
+}
+
+func TestNow_BindStatementTime(t *testing.T) {
+	stmt, err := parser.Parse("SELECT NOW(), CURRENT_TIMESTAMP::TEXT FROM t WHERE ts < NOW() AND id IN (1, 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	bindStatementTime(stmt, now)
+
+	sel := stmt.(*parser.SelectStmt)
+	alias, ok := sel.Columns[0].(*parser.AliasExpr)
+	if !ok || alias.Alias != "now" {
+		t.Fatalf("column 0 = %#v, want alias now", sel.Columns[0])
+	}
+	if lit, ok := alias.Expr.(*parser.TimestampLit); !ok || !lit.Value.Equal(now) {
+		t.Errorf("column 0 expr = %#v, want TimestampLit", alias.Expr)
+	}
+	if v, _, err := evalStaticExpr(sel.Columns[1]); err != nil || v != "2024-03-01 12:30:00+00" {
+		t.Errorf("column 1 = %v (%v), want 2024-03-01 12:30:00+00", v, err)
+	}
+	cmp := sel.Where.(*parser.BinaryExpr).Left.(*parser.BinaryExpr)
+	if _, ok := cmp.Right.(*parser.TimestampLit); !ok {
+		t.Errorf("where rhs = %T, want *parser.TimestampLit", cmp.Right)
+	}
+}
+
+func TestNow_InsertRowsShareTime(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, created_at TIMESTAMP)")
+	exec(t, e, "INSERT INTO orders (id, created_at) VALUES (1, NOW()), (2, NOW()), (3, CURRENT_TIMESTAMP)")
+
+	// Every pair of rows matches only if all three got the same instant.
+	r := exec(t, e, "SELECT a.id FROM orders a JOIN orders b ON a.created_at = b.created_at")
+	if len(r.Rows) != 9 {
+		t.Fatalf("got %d joined rows, want 9", len(r.Rows))
+	}
+
+	r = exec(t, e, "SELECT id FROM orders WHERE created_at <= NOW()")
+	if len(r.Rows) != 3 {
+		t.Errorf("got %d rows with created_at <= NOW(), want 3", len(r.Rows))
+	}
+}
+
+func TestNow_UpdateRowsShareTime(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, created_at TIMESTAMP)")
+	exec(t, e, "INSERT INTO orders VALUES (1, '2020-01-01 00:00:00'), (2, '2021-01-01 00:00:00')")
+	exec(t, e, "UPDATE orders SET created_at = NOW()")
+
+	r := exec(t, e, "SELECT a.id FROM orders a JOIN orders b ON a.created_at = b.created_at")
+	if len(r.Rows) != 4 {
+		t.Fatalf("got %d joined rows, want 4", len(r.Rows))
+	}
+}
+
+func TestNow_CurrentDate(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT CURRENT_DATE, DATE_TRUNC('day', NOW())")
+	if r.Columns[0].Name != "current_date" || r.Columns[0].TypeOID != OIDTimestampTZ {
+		t.Errorf("column = %+v, want current_date TIMESTAMPTZ", r.Columns[0])
+	}
+	if !strings.HasSuffix(string(r.Rows[0][0]), " 00:00:00+00") {
+		t.Errorf("CURRENT_DATE = %q, want midnight", r.Rows[0][0])
+	}
+	if string(r.Rows[0][0]) != string(r.Rows[0][1]) {
+		t.Errorf("CURRENT_DATE = %q, DATE_TRUNC('day', NOW()) = %q, want equal", r.Rows[0][0], r.Rows[0][1])
+	}
+
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, created_at TIMESTAMP)")
+	exec(t, e, "INSERT INTO orders VALUES (1, CURRENT_DATE), (2, '2020-01-01 00:00:00'), (3, NOW())")
+	r = exec(t, e, "SELECT id FROM orders WHERE created_at >= CURRENT_DATE ORDER BY id")
+	if got := groupRows(r); !slices.Equal(got, []string{"1", "3"}) {
+		t.Errorf("rows since CURRENT_DATE = %v, want [1 3]", got)
+	}
+
+	_, err := e.Execute("SELECT CURRENT_DATE(1)")
+	assertSQLSTATE(t, err, "42883")
+}
+
+func TestNow_TransactionTime(t *testing.T) {
+	e := setup(t)
+	txe := e.WithEngine(storage.NewTxEngine(e.Engine()))
+
+	// Every statement of a transaction sees the time it began; statements
+	// outside one each see their own.
+	first := string(exec(t, txe, "SELECT NOW()").Rows[0][0])
+	outside := string(exec(t, e, "SELECT NOW()").Rows[0][0])
+	time.Sleep(2 * time.Millisecond)
+	if got := string(exec(t, txe, "SELECT CURRENT_TIMESTAMP").Rows[0][0]); got != first {
+		t.Errorf("second statement in transaction: NOW() = %q, want %q", got, first)
+	}
+	if got := string(exec(t, e, "SELECT NOW()").Rows[0][0]); got == outside {
+		t.Errorf("outside a transaction NOW() stayed %q", got)
+	}
+
+	exec(t, txe, "CREATE TABLE events (id INTEGER PRIMARY KEY, at TIMESTAMP)")
+	exec(t, txe, "INSERT INTO events VALUES (1, NOW())")
+	r := exec(t, txe, "SELECT id FROM events WHERE at = NOW()")
+	if len(r.Rows) != 1 {
+		t.Errorf("got %d rows with at = NOW(), want 1", len(r.Rows))
+	}
+}
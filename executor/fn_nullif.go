@@ -0,0 +1,21 @@
+package executor
+
+import "mulldb/storage"
+
+func init() {
+	RegisterScalar("NULLIF", fnNullif)
+}
+
+// fnNullif returns NULL if its two arguments are equal, and the first
+// argument otherwise.
+func fnNullif(args []any) (any, Column, error) {
+	if len(args) != 2 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "NULLIF() takes exactly 2 arguments"}
+	}
+	oid, size := valueTypeOID(args[0])
+	col := Column{Name: "nullif", TypeOID: oid, TypeSize: size}
+	if storage.CompareValues(args[0], args[1]) == 0 {
+		return nil, col, nil
+	}
+	return args[0], col, nil
+}
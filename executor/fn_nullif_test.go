@@ -0,0 +1,60 @@
+package executor
+
+import "testing"
+
+func TestNullif(t *testing.T) {
+	e := setup(t)
+
+	tests := []struct {
+		sql  string
+		want any
+	}{
+		{"SELECT NULLIF(1, 1)", nil},
+		{"SELECT NULLIF(1, 2)", "1"},
+		{"SELECT NULLIF('a', 'a')", nil},
+		{"SELECT NULLIF('a', NULL)", "a"},
+		{"SELECT NULLIF(NULL, 1)", nil},
+		{"SELECT NULLIF(2, 2.0)", nil},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		got := r.Rows[0][0]
+		if tt.want == nil && got != nil || tt.want != nil && string(got) != tt.want {
+			t.Errorf("%s = %q, want %v", tt.sql, got, tt.want)
+		}
+		if r.Columns[0].Name != "nullif" {
+			t.Errorf("%s: column name = %q, want nullif", tt.sql, r.Columns[0].Name)
+		}
+	}
+}
+
+func TestNullif_TypeOID(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (i INTEGER, s TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (0, '')")
+
+	tests := []struct {
+		sql  string
+		want int32
+	}{
+		{"SELECT NULLIF(i, 0) FROM t", OIDInt8},
+		{"SELECT NULLIF(s, '') FROM t", OIDText},
+		{"SELECT NULLIF(1, 1)", OIDInt8},
+		{"SELECT NULLIF('a', 'a')", OIDText},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		if r.Rows[0][0] != nil {
+			t.Errorf("%s = %q, want NULL", tt.sql, r.Rows[0][0])
+		}
+		if r.Columns[0].TypeOID != tt.want {
+			t.Errorf("%s: TypeOID = %d, want %d", tt.sql, r.Columns[0].TypeOID, tt.want)
+		}
+	}
+}
+
+func TestNullif_WrongArgCount(t *testing.T) {
+	e := setup(t)
+	_, err := e.Execute("SELECT NULLIF(1)")
+	assertSQLSTATE(t, err, "42883")
+}
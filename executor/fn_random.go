@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"mulldb/parser"
+)
+
+func init() {
+	// Statements bind RANDOM() to the session's generator (see
+	// bindStatementRandom); the registered function only serves
+	// expressions evaluated outside a session, such as column defaults.
+	RegisterScalar("RANDOM", fnRandom)
+}
+
+func fnRandom(args []any) (any, Column, error) {
+	if len(args) != 0 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "RANDOM() takes no arguments"}
+	}
+	return rand.Float64(), Column{Name: "random", TypeOID: OIDFloat8, TypeSize: 8}, nil
+}
+
+// sessionRand is the random number generator behind a session's RANDOM()
+// and SETSEED(). Every connection has its own, so that after SETSEED the
+// connection's RANDOM() values repeat regardless of what other
+// connections do.
+type sessionRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newSessionRand() *sessionRand {
+	return &sessionRand{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Float64 returns the next value in [0, 1).
+func (r *sessionRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Float64()
+}
+
+// Seed restarts the sequence from x, which must lie in [-1, 1] as in
+// PostgreSQL's setseed().
+func (r *sessionRand) Seed(x float64) error {
+	if math.IsNaN(x) || x < -1 || x > 1 {
+		return &QueryError{Code: "22003", Message: fmt.Sprintf("setseed parameter %g is out of allowed range [-1,1]", x)}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rng.Seed(int64(x * math.MaxInt32))
+	return nil
+}
+
+// bindStatementRandom binds the RANDOM() calls in stmt to r and applies
+// its SETSEED() calls, replacing them with NULL. A seed takes effect
+// before any row is read, so SELECT setseed(0.5), random() already draws
+// from the new sequence. SETSEED needs a constant argument.
+func bindStatementRandom(stmt parser.Statement, r *sessionRand) error {
+	var err error
+	bindStatement(stmt, func(fn *parser.FunctionCallExpr) parser.Expr {
+		switch fn.Name {
+		case "RANDOM":
+			if len(fn.Args) != 0 {
+				return nil // left for the registered function to reject
+			}
+			return &parser.RandomExpr{Next: r.Float64}
+		case "SETSEED":
+			if err != nil {
+				return nil
+			}
+			err = applySetseed(fn, r)
+			return &parser.NullLit{}
+		}
+		return nil
+	})
+	return err
+}
+
+func applySetseed(fn *parser.FunctionCallExpr, r *sessionRand) error {
+	if len(fn.Args) != 1 {
+		return &QueryError{Code: "42883", Message: "SETSEED() takes exactly one argument"}
+	}
+	v, err := evalLiteral(fn.Args[0])
+	if err != nil {
+		return &QueryError{Code: "0A000", Message: "SETSEED() requires a constant argument"}
+	}
+	var x float64
+	switch n := v.(type) {
+	case int64:
+		x = float64(n)
+	case float64:
+		x = n
+	default:
+		return &QueryError{Code: "42883", Message: "SETSEED() requires a numeric argument"}
+	}
+	return r.Seed(x)
+}
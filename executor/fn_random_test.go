@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestRandom_Range(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER)")
+	exec(t, e, "INSERT INTO t VALUES (1), (2), (3), (4), (5)")
+
+	r := exec(t, e, "SELECT random(), random() * 10 AS scaled FROM t")
+	if r.Columns[0].Name != "random" || r.Columns[0].TypeOID != OIDFloat8 {
+		t.Errorf("column = %+v, want random float8", r.Columns[0])
+	}
+	seen := map[string]bool{}
+	for _, row := range r.Rows {
+		v, err := strconv.ParseFloat(string(row[0]), 64)
+		if err != nil || v < 0 || v >= 1 {
+			t.Errorf("random() = %q, want a float in [0,1)", row[0])
+		}
+		seen[string(row[0])] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("random() gave %d distinct values over %d rows, want one per row", len(seen), len(r.Rows))
+	}
+
+	_, err := e.Execute("SELECT random(1)")
+	assertSQLSTATE(t, err, "42883")
+}
+
+func TestRandom_Setseed(t *testing.T) {
+	e := setup(t)
+
+	draw := func() []string {
+		var out []string
+		for i := 0; i < 3; i++ {
+			out = append(out, string(exec(t, e, "SELECT random()").Rows[0][0]))
+		}
+		return out
+	}
+
+	r := exec(t, e, "SELECT setseed(0.5)")
+	if r.Columns[0].Name != "setseed" || r.Rows[0][0] != nil {
+		t.Errorf("setseed returned %+v %q, want a NULL setseed column", r.Columns[0], r.Rows[0])
+	}
+	first := draw()
+	exec(t, e, "SELECT setseed(0.5)")
+	if second := draw(); !slices.Equal(first, second) {
+		t.Errorf("after the same seed: %v then %v, want equal", first, second)
+	}
+	exec(t, e, "SELECT setseed(-0.25)")
+	if third := draw(); slices.Equal(first, third) {
+		t.Errorf("different seeds gave the same sequence %v", third)
+	}
+
+	// The seed applies before rows are read, within the same statement.
+	a := exec(t, e, "SELECT setseed(0.5), random()").Rows[0][1]
+	if string(a) != first[0] {
+		t.Errorf("setseed(0.5), random() = %q, want %q", a, first[0])
+	}
+
+	_, err := e.Execute("SELECT setseed(2)")
+	assertSQLSTATE(t, err, "22003")
+	_, err = e.Execute("SELECT setseed('x')")
+	assertSQLSTATE(t, err, "42883")
+}
+
+func TestRandom_SessionsIndependent(t *testing.T) {
+	e := setup(t)
+	s1, s2 := e.NewSession(), e.NewSession()
+
+	exec(t, s1, "SELECT setseed(0.1)")
+	want := string(exec(t, s1, "SELECT random()").Rows[0][0])
+
+	exec(t, s1, "SELECT setseed(0.1)")
+	exec(t, s2, "SELECT random()") // another session drawing in between
+	if got := string(exec(t, s1, "SELECT random()").Rows[0][0]); got != want {
+		t.Errorf("random() = %q, want %q regardless of other sessions", got, want)
+	}
+
+	// A transaction-scoped executor shares its session's generator.
+	exec(t, s1, "SELECT setseed(0.1)")
+	if got := string(exec(t, s1.WithEngine(s1.Engine()), "SELECT random()").Rows[0][0]); got != want {
+		t.Errorf("random() in derived executor = %q, want %q", got, want)
+	}
+}
+
+func TestRandom_OrderBy(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	exec(t, e, "INSERT INTO t VALUES (1), (2), (3), (4), (5), (6), (7), (8), (9), (10)")
+
+	ids := func(r *Result) []string {
+		var out []string
+		for _, row := range r.Rows {
+			out = append(out, string(row[0]))
+		}
+		return out
+	}
+	want := ids(exec(t, e, "SELECT id FROM t ORDER BY id"))
+
+	exec(t, e, "SELECT setseed(0.42)")
+	shuffled := ids(exec(t, e, "SELECT id FROM t ORDER BY random()"))
+	sorted := slices.Clone(shuffled)
+	slices.SortFunc(sorted, func(a, b string) int {
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return x - y
+	})
+	if !slices.Equal(sorted, want) {
+		t.Fatalf("ORDER BY random() returned %v, want a permutation of %v", shuffled, want)
+	}
+	if slices.Equal(shuffled, want) {
+		t.Errorf("ORDER BY random() returned the rows in order")
+	}
+
+	// The same seed shuffles the same way.
+	exec(t, e, "SELECT setseed(0.42)")
+	if again := ids(exec(t, e, "SELECT id FROM t ORDER BY random()")); !slices.Equal(again, shuffled) {
+		t.Errorf("reseeded shuffle = %v, want %v", again, shuffled)
+	}
+
+	// LIMIT after a random order samples rows.
+	if r := exec(t, e, "SELECT id FROM t ORDER BY random() LIMIT 3"); len(r.Rows) != 3 {
+		t.Errorf("sample returned %d rows, want 3", len(r.Rows))
+	}
+
+	r := exec(t, e, "EXPLAIN SELECT id FROM t ORDER BY random() DESC")
+	if got := string(r.Rows[1][0]); got != "  Sort Key: random() DESC" {
+		t.Errorf("EXPLAIN sort key = %q", got)
+	}
+
+	// A join shuffles its merged rows.
+	joined := ids(exec(t, e, "SELECT a.id FROM t a JOIN t b ON a.id = b.id ORDER BY random()"))
+	if sorted := slices.Sorted(slices.Values(joined)); !slices.Equal(sorted, slices.Sorted(slices.Values(shuffled))) {
+		t.Errorf("join shuffle = %v, want the ids of %v", joined, shuffled)
+	}
+}
@@ -0,0 +1,31 @@
+package executor
+
+import "strings"
+
+func init() {
+	RegisterScalar("REPLACE", fnReplace)
+}
+
+// fnReplace replaces every occurrence of from in s with to. An empty from
+// leaves s unchanged, matching PostgreSQL.
+func fnReplace(args []any) (any, Column, error) {
+	col := Column{Name: "replace", TypeOID: OIDText, TypeSize: -1}
+	if len(args) != 3 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "REPLACE() takes exactly 3 arguments"}
+	}
+	strs := make([]string, 3)
+	for i, a := range args {
+		if a == nil {
+			return nil, col, nil
+		}
+		s, ok := a.(string)
+		if !ok {
+			return nil, Column{}, &QueryError{Code: "42883", Message: "REPLACE() requires TEXT arguments"}
+		}
+		strs[i] = s
+	}
+	if strs[1] == "" {
+		return strs[0], col, nil
+	}
+	return strings.ReplaceAll(strs[0], strs[1], strs[2]), col, nil
+}
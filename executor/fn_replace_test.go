@@ -0,0 +1,52 @@
+package executor
+
+import "testing"
+
+func TestReplace_Static(t *testing.T) {
+	e := setup(t)
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT REPLACE('hello world', 'o', '0')", "hell0 w0rld"},
+		{"SELECT REPLACE('aaa', 'a', 'bb')", "bbbbbb"},
+		{"SELECT REPLACE('abc', '', 'x')", "abc"},
+		{"SELECT REPLACE('abc', 'z', 'x')", "abc"},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		if string(r.Rows[0][0]) != tt.want {
+			t.Errorf("%s = %q, want %q", tt.sql, r.Rows[0][0], tt.want)
+		}
+	}
+}
+
+func TestReplace_Null(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT REPLACE('abc', NULL, 'x')")
+	if r.Rows[0][0] != nil {
+		t.Errorf("got %q, want NULL", r.Rows[0][0])
+	}
+}
+
+func TestReplace_FromTable(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (path TEXT)")
+	exec(t, e, "INSERT INTO t VALUES ('/a/b'), ('/c')")
+
+	r := exec(t, e, "SELECT REPLACE(path, '/', '.') FROM t WHERE REPLACE(path, '/', '') = 'ab'")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != ".a.b" {
+		t.Fatalf("got %v, want [.a.b]", r.Rows)
+	}
+	if r.Columns[0].TypeOID != OIDText {
+		t.Errorf("OID = %d, want %d", r.Columns[0].TypeOID, OIDText)
+	}
+}
+
+func TestReplace_ArgCount(t *testing.T) {
+	e := setup(t)
+	_, err := e.Execute("SELECT REPLACE('abc', 'a')")
+	assertSQLSTATE(t, err, "42883")
+}
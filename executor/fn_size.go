@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"fmt"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+func init() {
+	// PG_RELATION_SIZE and PG_TOTAL_RELATION_SIZE read the engine, so
+	// statements bind them to constants (see bindStatementSizes); the
+	// registered functions only reject calls that were left unbound.
+	RegisterScalar("PG_RELATION_SIZE", fnUnboundSize("PG_RELATION_SIZE"))
+	RegisterScalar("PG_TOTAL_RELATION_SIZE", fnUnboundSize("PG_TOTAL_RELATION_SIZE"))
+	RegisterScalar("PG_SIZE_PRETTY", fnSizePretty)
+}
+
+func fnUnboundSize(name string) ScalarFunc {
+	return func(args []any) (any, Column, error) {
+		return nil, Column{}, &QueryError{Code: "0A000", Message: name + "() is not available here"}
+	}
+}
+
+func fnSizePretty(args []any) (any, Column, error) {
+	col := Column{Name: "pg_size_pretty", TypeOID: OIDText, TypeSize: -1}
+	if len(args) != 1 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "PG_SIZE_PRETTY() takes exactly one argument"}
+	}
+	switch x := args[0].(type) {
+	case nil:
+		return nil, col, nil
+	case int64:
+		return humanBytes(x), col, nil
+	default:
+		return nil, Column{}, &QueryError{Code: "42883", Message: "PG_SIZE_PRETTY() requires an INTEGER argument"}
+	}
+}
+
+// bindStatementSizes replaces the PG_RELATION_SIZE and
+// PG_TOTAL_RELATION_SIZE calls in stmt with the current in-memory size of
+// the named table, as SHOW MEMORY reports it: the row data alone, or the
+// row data plus every index. The table name must be a constant.
+func bindStatementSizes(stmt parser.Statement, eng storage.Engine) error {
+	var err error
+	bindStatement(stmt, func(fn *parser.FunctionCallExpr) parser.Expr {
+		if fn.Name != "PG_RELATION_SIZE" && fn.Name != "PG_TOTAL_RELATION_SIZE" {
+			return nil
+		}
+		if err != nil {
+			return nil
+		}
+		var size int64
+		size, err = relationSize(fn, eng)
+		return &parser.IntegerLit{Value: size}
+	})
+	return err
+}
+
+func relationSize(fn *parser.FunctionCallExpr, eng storage.Engine) (int64, error) {
+	if len(fn.Args) != 1 {
+		return 0, &QueryError{Code: "42883", Message: fmt.Sprintf("%s() takes exactly one argument", fn.Name)}
+	}
+	v, err := evalLiteral(fn.Args[0])
+	if err != nil {
+		return 0, &QueryError{Code: "0A000", Message: fmt.Sprintf("%s() requires a constant argument", fn.Name)}
+	}
+	name, ok := v.(string)
+	if !ok {
+		return 0, &QueryError{Code: "42883", Message: fmt.Sprintf("%s() requires a TEXT argument", fn.Name)}
+	}
+	if _, ok := eng.GetTable(name); !ok {
+		return 0, WrapError(&storage.TableNotFoundError{Name: name})
+	}
+	for _, info := range eng.MemoryUsage() {
+		if info.TableName != name {
+			continue
+		}
+		size := info.RowBytes
+		if fn.Name == "PG_TOTAL_RELATION_SIZE" {
+			if info.PKIndex != nil {
+				size += info.PKIndex.Bytes
+			}
+			for _, idx := range info.Indexes {
+				size += idx.Bytes
+			}
+		}
+		return size, nil
+	}
+	// Created in a transaction that has not committed yet: nothing is
+	// stored for it.
+	return 0, nil
+}
@@ -0,0 +1,71 @@
+package executor
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestRelationSize_Populated(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "CREATE INDEX t_name ON t (name)")
+	exec(t, e, "INSERT INTO t (id, name) VALUES (1, 'alice'), (2, 'bob'), (3, 'carol')")
+
+	r := exec(t, e, "SELECT pg_relation_size('t'), pg_total_relation_size('t')")
+	if len(r.Rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(r.Rows))
+	}
+	if r.Columns[0].Name != "pg_relation_size" || r.Columns[0].TypeOID != OIDInt8 {
+		t.Errorf("column 0 = %+v, want pg_relation_size int8", r.Columns[0])
+	}
+	rel, err := strconv.ParseInt(string(r.Rows[0][0]), 10, 64)
+	if err != nil || rel <= 0 {
+		t.Fatalf("pg_relation_size = %q, want > 0", r.Rows[0][0])
+	}
+	total, err := strconv.ParseInt(string(r.Rows[0][1]), 10, 64)
+	if err != nil || total <= rel {
+		t.Fatalf("pg_total_relation_size = %q, want > %d", r.Rows[0][1], rel)
+	}
+}
+
+func TestRelationSize_UnknownTable(t *testing.T) {
+	e := setup(t)
+
+	_, err := e.Execute("SELECT pg_relation_size('nope')")
+	assertSQLSTATE(t, err, "42P01")
+}
+
+func TestRelationSize_NonConstant(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (name TEXT)")
+	exec(t, e, "INSERT INTO t (name) VALUES ('t')")
+
+	_, err := e.Execute("SELECT pg_relation_size(name) FROM t")
+	assertSQLSTATE(t, err, "0A000")
+}
+
+func TestSizePretty(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT pg_size_pretty(2048), pg_size_pretty(10), pg_size_pretty(NULL)")
+	if got := string(r.Rows[0][0]); got != "2.0 KB" {
+		t.Errorf("pg_size_pretty(2048) = %q, want 2.0 KB", got)
+	}
+	if got := string(r.Rows[0][1]); got != "10 B" {
+		t.Errorf("pg_size_pretty(10) = %q, want 10 B", got)
+	}
+	if r.Rows[0][2] != nil {
+		t.Errorf("pg_size_pretty(NULL) = %q, want NULL", r.Rows[0][2])
+	}
+}
+
+func TestSizePretty_OfRelationSize(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER)")
+	exec(t, e, "INSERT INTO t (id) VALUES (1)")
+
+	r := exec(t, e, "SELECT pg_size_pretty(pg_total_relation_size('t'))")
+	if len(r.Rows) != 1 || len(r.Rows[0][0]) == 0 {
+		t.Fatalf("pg_size_pretty(pg_total_relation_size('t')) = %v, want a size", r.Rows)
+	}
+}
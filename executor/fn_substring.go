@@ -0,0 +1,49 @@
+package executor
+
+func init() {
+	RegisterScalar("SUBSTRING", fnSubstring)
+	RegisterScalar("SUBSTR", fnSubstring)
+}
+
+// fnSubstring implements SUBSTRING(s, start [, count]) with 1-based character
+// positions. Like PostgreSQL, positions outside the string are clamped rather
+// than rejected, so SUBSTRING('hello', 0, 3) is 'he'. A negative count is an
+// error (SQLSTATE 22011).
+func fnSubstring(args []any) (any, Column, error) {
+	col := Column{Name: "substring", TypeOID: OIDText, TypeSize: -1}
+	if len(args) < 2 || len(args) > 3 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "SUBSTRING() takes 2 or 3 arguments"}
+	}
+	for _, a := range args {
+		if a == nil {
+			return nil, col, nil
+		}
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "SUBSTRING() requires a TEXT argument"}
+	}
+	start, ok := args[1].(int64)
+	if !ok {
+		return nil, Column{}, &QueryError{Code: "42883", Message: "SUBSTRING() start position must be an integer"}
+	}
+
+	runes := []rune(s)
+	n := int64(len(runes))
+	end := n + 1 // exclusive, 1-based
+	if len(args) == 3 {
+		count, ok := args[2].(int64)
+		if !ok {
+			return nil, Column{}, &QueryError{Code: "42883", Message: "SUBSTRING() length must be an integer"}
+		}
+		if count < 0 {
+			return nil, Column{}, &QueryError{Code: "22011", Message: "negative substring length not allowed"}
+		}
+		end = min(start+count, n+1)
+	}
+	start = max(start, 1)
+	if start >= end {
+		return "", col, nil
+	}
+	return string(runes[start-1 : end-1]), col, nil
+}
@@ -0,0 +1,55 @@
+package executor
+
+import "testing"
+
+func TestSubstring_Static(t *testing.T) {
+	e := setup(t)
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT SUBSTRING('hello', 2, 3)", "ell"},
+		{"SELECT SUBSTRING('hello', 3)", "llo"},
+		{"SELECT SUBSTRING('hello', 0, 3)", "he"},
+		{"SELECT SUBSTRING('hello', -5, 3)", ""},
+		{"SELECT SUBSTRING('hello', 4, 100)", "lo"},
+		{"SELECT SUBSTRING('hello', 10)", ""},
+		{"SELECT SUBSTR('héllo', 2, 2)", "él"},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		if string(r.Rows[0][0]) != tt.want {
+			t.Errorf("%s = %q, want %q", tt.sql, r.Rows[0][0], tt.want)
+		}
+	}
+}
+
+func TestSubstring_Null(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT SUBSTRING(NULL, 1, 2), SUBSTRING('abc', NULL)")
+	if r.Rows[0][0] != nil || r.Rows[0][1] != nil {
+		t.Errorf("got %v, want NULLs", r.Rows[0])
+	}
+}
+
+func TestSubstring_NegativeLength(t *testing.T) {
+	e := setup(t)
+	_, err := e.Execute("SELECT SUBSTRING('hello', 1, -1)")
+	assertSQLSTATE(t, err, "22011")
+}
+
+func TestSubstring_FromTable(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (code TEXT)")
+	exec(t, e, "INSERT INTO t VALUES ('US-123'), ('DE-456')")
+
+	r := exec(t, e, "SELECT SUBSTRING(code, 4) FROM t WHERE SUBSTRING(code, 1, 2) = 'DE'")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "456" {
+		t.Fatalf("got %v, want [456]", r.Rows)
+	}
+	if r.Columns[0].Name != "substring" || r.Columns[0].TypeOID != OIDText {
+		t.Errorf("column = %+v, want substring/text", r.Columns[0])
+	}
+}
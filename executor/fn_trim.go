@@ -0,0 +1,48 @@
+package executor
+
+import "strings"
+
+func init() {
+	RegisterScalar("TRIM", fnTrim)
+	RegisterScalar("BTRIM", fnTrim)
+	RegisterScalar("LTRIM", fnLTrim)
+	RegisterScalar("RTRIM", fnRTrim)
+}
+
+func fnTrim(args []any) (any, Column, error) {
+	return trimText("TRIM", "btrim", strings.Trim, args)
+}
+
+func fnLTrim(args []any) (any, Column, error) {
+	return trimText("LTRIM", "ltrim", strings.TrimLeft, args)
+}
+
+func fnRTrim(args []any) (any, Column, error) {
+	return trimText("RTRIM", "rtrim", strings.TrimRight, args)
+}
+
+// trimText implements the TRIM family. The optional second argument lists
+// the characters to strip; it defaults to a single space, as in PostgreSQL.
+func trimText(fname, colName string, trim func(string, string) string, args []any) (any, Column, error) {
+	col := Column{Name: colName, TypeOID: OIDText, TypeSize: -1}
+	if len(args) < 1 || len(args) > 2 {
+		return nil, Column{}, &QueryError{Code: "42883", Message: fname + "() takes 1 or 2 arguments"}
+	}
+	for _, a := range args {
+		if a == nil {
+			return nil, col, nil
+		}
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, Column{}, &QueryError{Code: "42883", Message: fname + "() requires a TEXT argument"}
+	}
+	cutset := " "
+	if len(args) == 2 {
+		cutset, ok = args[1].(string)
+		if !ok {
+			return nil, Column{}, &QueryError{Code: "42883", Message: fname + "() requires a TEXT argument"}
+		}
+	}
+	return trim(s, cutset), col, nil
+}
@@ -0,0 +1,48 @@
+package executor
+
+import "testing"
+
+func TestTrim_Static(t *testing.T) {
+	e := setup(t)
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT TRIM('  hi  ')", "hi"},
+		{"SELECT LTRIM('  hi  ')", "hi  "},
+		{"SELECT RTRIM('  hi  ')", "  hi"},
+		{"SELECT TRIM('xxhixx', 'x')", "hi"},
+		{"SELECT BTRIM('xyhiyx', 'xy')", "hi"},
+		{"SELECT LTRIM('0042', '0')", "42"},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		if string(r.Rows[0][0]) != tt.want {
+			t.Errorf("%s = %q, want %q", tt.sql, r.Rows[0][0], tt.want)
+		}
+	}
+}
+
+func TestTrim_Null(t *testing.T) {
+	e := setup(t)
+
+	r := exec(t, e, "SELECT TRIM(NULL), TRIM('x', NULL)")
+	if r.Rows[0][0] != nil || r.Rows[0][1] != nil {
+		t.Errorf("got %v, want NULLs", r.Rows[0])
+	}
+}
+
+func TestTrim_InWhere(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (name TEXT)")
+	exec(t, e, "INSERT INTO t VALUES (' padded '), ('plain')")
+
+	r := exec(t, e, "SELECT TRIM(name) FROM t WHERE TRIM(name) = 'padded'")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "padded" {
+		t.Fatalf("got %v, want [padded]", r.Rows)
+	}
+	if r.Columns[0].TypeOID != OIDText {
+		t.Errorf("OID = %d, want %d", r.Columns[0].TypeOID, OIDText)
+	}
+}
@@ -0,0 +1,407 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// Foreign keys are single-column REFERENCES constraints recorded on the
+// referencing column. The storage engine only keeps them in the catalog;
+// the executor checks them after each write and carries out ON DELETE
+// actions, on a transaction overlay so that the statement and everything
+// it triggers commit together.
+
+var fkActions = map[string]storage.FKAction{
+	"NO ACTION": storage.FKNoAction,
+	"RESTRICT":  storage.FKRestrict,
+	"CASCADE":   storage.FKCascade,
+	"SET NULL":  storage.FKSetNull,
+}
+
+// fkConstraintName returns the name PostgreSQL gives the foreign key on
+// column of table.
+func fkConstraintName(table, column string) string {
+	return table + "_" + column + "_fkey"
+}
+
+// resolveForeignKey checks the REFERENCES constraint of column i of the
+// table s creates, whose catalog columns are cols, and returns it in
+// catalog form. The referenced column must be the parent's primary key or
+// UNIQUE, and of the same type. A table may reference itself.
+func (e *Executor) resolveForeignKey(s *parser.CreateTableStmt, cols []storage.ColumnDef, i int) (*storage.ForeignKey, error) {
+	fk := s.Columns[i].References
+	name := fkConstraintName(s.Name.Name, cols[i].Name)
+
+	// Find the referenced column and whether it is unique.
+	var pcol *storage.ColumnDef
+	var unique bool
+	if strings.EqualFold(fk.Table, s.Name.Name) {
+		for j := range cols {
+			if fk.Column == "" && cols[j].PrimaryKey || fk.Column != "" && strings.EqualFold(cols[j].Name, fk.Column) {
+				pcol = &cols[j]
+				unique = cols[j].PrimaryKey || s.Columns[j].Unique
+				break
+			}
+		}
+	} else {
+		parent, ok := e.engine.GetTable(fk.Table)
+		if !ok {
+			return nil, WrapError(&storage.TableNotFoundError{Name: fk.Table})
+		}
+		for j := range parent.Columns {
+			c := &parent.Columns[j]
+			if fk.Column == "" && c.PrimaryKey || fk.Column != "" && strings.EqualFold(c.Name, fk.Column) {
+				pcol = c
+				unique = c.PrimaryKey || uniqueIndexOn(parent, c.Name) != ""
+				break
+			}
+		}
+	}
+	switch {
+	case pcol == nil && fk.Column == "":
+		return nil, &QueryError{Code: "42830", Message: fmt.Sprintf("there is no primary key for referenced table %q", fk.Table)}
+	case pcol == nil:
+		return nil, &QueryError{Code: "42703", Message: fmt.Sprintf("column %q referenced in foreign key constraint does not exist", fk.Column)}
+	case !unique:
+		return nil, &QueryError{Code: "42830", Message: fmt.Sprintf("there is no unique constraint matching given keys for referenced table %q", fk.Table)}
+	case pcol.DataType != cols[i].DataType:
+		return nil, &QueryError{Code: "42804", Message: fmt.Sprintf(
+			"foreign key constraint %q cannot be implemented: key columns %q and %q are of incompatible types: %s and %s",
+			name, cols[i].Name, pcol.Name, cols[i].DataType, pcol.DataType)}
+	}
+	return &storage.ForeignKey{Table: fk.Table, Column: pcol.Name, OnDelete: fkActions[fk.OnDelete]}, nil
+}
+
+// uniqueIndexOn returns the name of a unique index on column alone, or ""
+// when there is none.
+func uniqueIndexOn(def *storage.TableDef, column string) string {
+	for _, idx := range def.Indexes {
+		if idx.Unique && len(idx.Columns) == 1 && strings.EqualFold(idx.Columns[0], column) {
+			return idx.Name
+		}
+	}
+	return ""
+}
+
+// fkRef is a foreign key seen from the table it references.
+type fkRef struct {
+	child  *storage.TableDef
+	column storage.ColumnDef // the referencing column of child
+}
+
+func (r fkRef) name() string {
+	return fkConstraintName(r.child.Name, r.column.Name)
+}
+
+// referencesTo returns the foreign keys that reference def through one of
+// columns, or through any column when columns is nil, ordered by table and
+// column.
+func (e *Executor) referencesTo(def *storage.TableDef, columns []string) []fkRef {
+	var refs []fkRef
+	for _, child := range e.engine.ListTables() {
+		for _, c := range child.Columns {
+			fk := c.References
+			if fk == nil || !strings.EqualFold(fk.Table, def.Name) {
+				continue
+			}
+			if columns != nil && !slices.ContainsFunc(columns, func(col string) bool { return strings.EqualFold(col, fk.Column) }) {
+				continue
+			}
+			refs = append(refs, fkRef{child: child, column: c})
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].child.Name != refs[j].child.Name {
+			return refs[i].child.Name < refs[j].child.Name
+		}
+		return refs[i].column.Ordinal < refs[j].column.Ordinal
+	})
+	return refs
+}
+
+// hasReferences reports whether one of columns of def, or any column when
+// columns is nil, has a REFERENCES constraint.
+func hasReferences(def *storage.TableDef, columns []string) bool {
+	for _, c := range def.Columns {
+		if c.References == nil {
+			continue
+		}
+		if columns == nil || slices.ContainsFunc(columns, func(col string) bool { return strings.EqualFold(col, c.Name) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// inTransaction reports whether the executor runs inside an explicit
+// transaction, whose writes are buffered until COMMIT.
+func (e *Executor) inTransaction() bool {
+	_, ok := baseEngine(e.engine).(*storage.TxEngine)
+	return ok
+}
+
+// baseEngine returns the storage engine under the per-statement wrappers
+// that expand views and poll for cancellation.
+func baseEngine(eng storage.Engine) storage.Engine {
+	for {
+		switch w := eng.(type) {
+		case viewEngine:
+			eng = w.Engine
+		case interruptEngine:
+			eng = w.Engine
+		default:
+			return eng
+		}
+	}
+}
+
+// atomically runs fn on an executor whose writes go to a transaction
+// overlay, and commits them in one step when fn succeeds. A statement
+// whose foreign key check fails, or whose cascade does, leaves nothing
+// behind.
+func (e *Executor) atomically(fn func(*Executor) (*Result, error)) (*Result, error) {
+	tx := storage.NewTxEngine(baseEngine(e.engine))
+	x := *e
+	x.engine = tx
+	if e.intr != nil {
+		x.engine = interruptEngine{Engine: tx, in: e.intr}
+	}
+	xv := x.withViews()
+	res, err := fn(xv)
+	if err = xv.viewFailure(err); err != nil {
+		return nil, err
+	}
+	// A cancelled scan ends early rather than failing; what it found must
+	// not be committed.
+	if e.intr != nil && e.intr.err != nil {
+		return nil, e.intr.err
+	}
+	if err := tx.CommitOverlay(); err != nil {
+		return nil, WrapError(err)
+	}
+	return res, nil
+}
+
+// checkForeignKeys verifies that each non-null value rows hold in a
+// REFERENCES column of def is present in the referenced table.
+func (e *Executor) checkForeignKeys(def *storage.TableDef, rows []storage.Row) error {
+	for _, c := range def.Columns {
+		fk := c.References
+		if fk == nil {
+			continue
+		}
+		keys := distinctKeys(rows, c.Ordinal)
+		if len(keys) == 0 {
+			continue
+		}
+		parent, ok := e.engine.GetTable(fk.Table)
+		if !ok {
+			return WrapError(&storage.TableNotFoundError{Name: fk.Table})
+		}
+		missing, err := e.missingKey(parent, fk.Column, keys)
+		if err != nil {
+			return err
+		}
+		if missing {
+			return &QueryError{Code: "23503", Message: fmt.Sprintf(
+				"insert or update on table %q violates foreign key constraint %q", def.Name, fkConstraintName(def.Name, c.Name))}
+		}
+	}
+	return nil
+}
+
+// checkReferencingRows verifies, after the referenced columns of def
+// changed, that every row referencing def through refs still finds its
+// parent. A referenced key cannot be changed while it is in use.
+func (e *Executor) checkReferencingRows(def *storage.TableDef, refs []fkRef) error {
+	for _, ref := range refs {
+		rows, err := e.scanAll(ref.child)
+		if err != nil {
+			return err
+		}
+		keys := distinctKeys(rows, ref.column.Ordinal)
+		if len(keys) == 0 {
+			continue
+		}
+		missing, err := e.missingKey(def, ref.column.References.Column, keys)
+		if err != nil {
+			return err
+		}
+		if missing {
+			return fkInUseError(def, ref)
+		}
+	}
+	return nil
+}
+
+func fkInUseError(def *storage.TableDef, ref fkRef) error {
+	return &QueryError{Code: "23503", Message: fmt.Sprintf(
+		"update or delete on table %q violates foreign key constraint %q on table %q", def.Name, ref.name(), ref.child.Name)}
+}
+
+// cascadeDelete carries out the ON DELETE actions of the foreign keys
+// referencing def for the rows just deleted from it: CASCADE deletes the
+// referencing rows, in turn cascading from their table, and SET NULL
+// clears their referencing column. RESTRICT fails at once; NO ACTION
+// fails only if referencing rows are left once every cascade has run.
+func (e *Executor) cascadeDelete(def *storage.TableDef, deleted []storage.Row) error {
+	var pending []fkCheck
+	if err := e.applyOnDelete(def, deleted, &pending); err != nil {
+		return err
+	}
+	for _, p := range pending {
+		children, err := e.rowsWithKeys(p.ref.child, p.ref.column.Name, p.keys)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			return fkInUseError(p.parent, p.ref)
+		}
+	}
+	return nil
+}
+
+// fkCheck is a NO ACTION foreign key whose referencing rows must be gone
+// by the end of the statement.
+type fkCheck struct {
+	parent *storage.TableDef
+	ref    fkRef
+	keys   []any
+}
+
+func (e *Executor) applyOnDelete(def *storage.TableDef, deleted []storage.Row, pending *[]fkCheck) error {
+	if len(deleted) == 0 {
+		return nil
+	}
+	for _, ref := range e.referencesTo(def, nil) {
+		fk := ref.column.References
+		keys := distinctKeys(deleted, columnIndex(def, fk.Column))
+		if len(keys) == 0 {
+			continue
+		}
+		if fk.OnDelete == storage.FKNoAction {
+			*pending = append(*pending, fkCheck{parent: def, ref: ref, keys: keys})
+			continue
+		}
+		children, err := e.rowsWithKeys(ref.child, ref.column.Name, keys)
+		if err != nil {
+			return err
+		}
+		if len(children) == 0 {
+			continue
+		}
+		ids := make(map[int64]struct{}, len(children))
+		rowIDs := make([]int64, len(children))
+		for i, r := range children {
+			ids[r.ID] = struct{}{}
+			rowIDs[i] = r.ID
+		}
+		switch fk.OnDelete {
+		case storage.FKCascade:
+			gone, err := e.engine.DeleteRows(ref.child.Name, rowIDs, nil)
+			if err != nil {
+				return WrapError(err)
+			}
+			if err := e.applyOnDelete(ref.child, gone, pending); err != nil {
+				return err
+			}
+		case storage.FKSetNull:
+			sets := map[string]any{ref.column.Name: nil}
+			if _, err := e.engine.Update(ref.child.Name, sets, func(r storage.Row) bool {
+				_, ok := ids[r.ID]
+				return ok
+			}); err != nil {
+				return WrapError(err)
+			}
+		default:
+			return fkInUseError(def, ref)
+		}
+	}
+	return nil
+}
+
+// missingKey reports whether one of keys, distinct and sorted, is not
+// present in column of def.
+func (e *Executor) missingKey(def *storage.TableDef, column string, keys []any) (bool, error) {
+	found, err := e.rowsWithKeys(def, column, keys)
+	if err != nil {
+		return false, err
+	}
+	return len(distinctKeys(found, columnIndex(def, column))) < len(keys), nil
+}
+
+// rowsWithKeys returns the rows of def whose column holds one of keys,
+// distinct and sorted. It probes the primary key or an index on the
+// column when there is one, and scans the table otherwise.
+func (e *Executor) rowsWithKeys(def *storage.TableDef, column string, keys []any) ([]storage.Row, error) {
+	ord := columnIndex(def, column)
+	if ord == def.PrimaryKeyColumn() {
+		return e.lookupIndexUnion(def, "PRIMARY", keys)
+	}
+	for _, idx := range def.Indexes {
+		if len(idx.Columns) == 1 && strings.EqualFold(idx.Columns[0], column) {
+			return e.lookupIndexUnion(def, idx.Name, keys)
+		}
+	}
+	rows, err := e.scanAll(def)
+	if err != nil {
+		return nil, err
+	}
+	var out []storage.Row
+	for _, r := range rows {
+		v := storage.RowValue(r.Values, ord)
+		if v == nil {
+			continue
+		}
+		i := sort.Search(len(keys), func(i int) bool { return storage.CompareValues(keys[i], v) >= 0 })
+		if i < len(keys) && storage.CompareValues(keys[i], v) == 0 {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// scanAll returns every row of def.
+func (e *Executor) scanAll(def *storage.TableDef) ([]storage.Row, error) {
+	it, err := e.engine.Scan(def.Name)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	defer it.Close()
+	var rows []storage.Row
+	for batch := e.nextBatch(it); len(batch) > 0; batch = e.nextBatch(it) {
+		rows = append(rows, batch...)
+	}
+	return rows, nil
+}
+
+// distinctKeys returns the distinct non-null values rows hold at ordinal,
+// sorted.
+func distinctKeys(rows []storage.Row, ordinal int) []any {
+	var keys []any
+	for _, r := range rows {
+		if v := storage.RowValue(r.Values, ordinal); v != nil {
+			keys = append(keys, v)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return storage.CompareValues(keys[i], keys[j]) < 0
+	})
+	return slices.CompactFunc(keys, func(a, b any) bool {
+		return storage.CompareValues(a, b) == 0
+	})
+}
+
+// setColumns returns the columns an UPDATE ... SET list assigns.
+func setColumns(sets []parser.SetClause) []string {
+	cols := make([]string, len(sets))
+	for i, sc := range sets {
+		cols[i] = sc.Column
+	}
+	return cols
+}
@@ -0,0 +1,224 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"mulldb/storage"
+)
+
+// column returns the values of the first column of the rows sql returns,
+// joined with commas; NULL reads as "null".
+func column(t *testing.T, e *Executor, sql string) string {
+	t.Helper()
+	var vals []string
+	for _, r := range exec(t, e, sql).Rows {
+		if r[0] == nil {
+			vals = append(vals, "null")
+		} else {
+			vals = append(vals, string(r[0]))
+		}
+	}
+	return strings.Join(vals, ",")
+}
+
+func TestForeignKey_Create(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE p (id INTEGER PRIMARY KEY, code TEXT UNIQUE, name TEXT)")
+	exec(t, e, "CREATE TABLE nopk (id INTEGER)")
+
+	exec(t, e, "CREATE TABLE c1 (id INTEGER PRIMARY KEY, pid INTEGER REFERENCES p ON DELETE CASCADE, code TEXT REFERENCES p (code))")
+	def, _ := e.Engine().GetTable("c1")
+	if fk := def.Columns[1].References; fk == nil || *fk != (storage.ForeignKey{Table: "p", Column: "id", OnDelete: storage.FKCascade}) {
+		t.Errorf("pid references %+v, want p(id) ON DELETE CASCADE", fk)
+	}
+	if fk := def.Columns[2].References; fk == nil || fk.Column != "code" || fk.OnDelete != storage.FKNoAction {
+		t.Errorf("code references %+v, want p(code)", fk)
+	}
+	exec(t, e, "CREATE TABLE tree (id INTEGER PRIMARY KEY, parent INTEGER, FOREIGN KEY (parent) REFERENCES tree ON DELETE SET NULL)")
+
+	tests := []struct {
+		sql, code string
+	}{
+		{"CREATE TABLE c (pid INTEGER REFERENCES missing)", "42P01"},
+		{"CREATE TABLE c (pid INTEGER REFERENCES nopk)", "42830"},
+		{"CREATE TABLE c (pid TEXT REFERENCES p (name))", "42830"},
+		{"CREATE TABLE c (pid INTEGER REFERENCES p (nope))", "42703"},
+		{"CREATE TABLE c (pid TEXT REFERENCES p)", "42804"},
+		{"CREATE TABLE c (id INTEGER, parent INTEGER REFERENCES c (id))", "42830"},
+		{"ALTER TABLE p ADD COLUMN other INTEGER REFERENCES p", "0A000"},
+	}
+	for _, tt := range tests {
+		_, err := e.Execute(tt.sql)
+		assertSQLSTATE(t, err, tt.code)
+	}
+	if _, ok := e.Engine().GetTable("c"); ok {
+		t.Error("table c created despite an invalid foreign key")
+	}
+
+	// A referenced table, or column, cannot be dropped or truncated from
+	// under the tables that reference it; the referencing side can.
+	_, err := e.Execute("DROP TABLE p")
+	assertSQLSTATE(t, err, "2BP01")
+	_, err = e.Execute("ALTER TABLE p DROP COLUMN code")
+	assertSQLSTATE(t, err, "2BP01")
+	_, err = e.Execute("TRUNCATE p")
+	assertSQLSTATE(t, err, "0A000")
+	exec(t, e, "ALTER TABLE p DROP COLUMN name")
+	exec(t, e, "TRUNCATE tree")
+	exec(t, e, "DROP TABLE tree")
+	exec(t, e, "DROP TABLE c1")
+	exec(t, e, "DROP TABLE p")
+}
+
+func TestForeignKey_InsertUpdate(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE p (id INTEGER PRIMARY KEY, code TEXT UNIQUE)")
+	exec(t, e, "CREATE TABLE c (id INTEGER PRIMARY KEY, pid INTEGER REFERENCES p, code TEXT REFERENCES p (code))")
+	exec(t, e, "INSERT INTO p VALUES (1, 'a'), (2, 'b'), (3, 'c')")
+
+	exec(t, e, "INSERT INTO c VALUES (10, 1, 'a'), (11, NULL, NULL), (12, 2, 'a')")
+	_, err := e.Execute("INSERT INTO c VALUES (13, 1, 'a'), (14, 9, 'a')")
+	assertSQLSTATE(t, err, "23503")
+	_, err = e.Execute("INSERT INTO c (id, code) VALUES (15, 'z')")
+	assertSQLSTATE(t, err, "23503")
+	if got := column(t, e, "SELECT id FROM c ORDER BY id"); got != "10,11,12" {
+		t.Errorf("ids after failed inserts = %s, want 10,11,12: a failed statement inserts nothing", got)
+	}
+
+	exec(t, e, "UPDATE c SET pid = 3 WHERE id = 11")
+	_, err = e.Execute("UPDATE c SET pid = 4")
+	assertSQLSTATE(t, err, "23503")
+	if got := column(t, e, "SELECT pid FROM c ORDER BY id"); got != "1,3,2" {
+		t.Errorf("pid = %s, want 1,3,2", got)
+	}
+
+	// A referenced key cannot change while it is in use; an unused one can.
+	_, err = e.Execute("UPDATE p SET id = 5 WHERE id = 1")
+	assertSQLSTATE(t, err, "23503")
+	_, err = e.Execute("UPDATE p SET code = 'x' WHERE id = 1")
+	assertSQLSTATE(t, err, "23503")
+	exec(t, e, "UPDATE p SET code = 'x' WHERE id = 3")
+	if got := column(t, e, "SELECT code FROM p ORDER BY id"); got != "a,b,x" {
+		t.Errorf("code = %s, want a,b,x", got)
+	}
+	exec(t, e, "INSERT INTO p VALUES (3, 'y') ON CONFLICT (id) DO UPDATE SET code = 'c'")
+	_, err = e.Execute("INSERT INTO p VALUES (1, 'y') ON CONFLICT (id) DO UPDATE SET code = 'y'")
+	assertSQLSTATE(t, err, "23503")
+}
+
+func TestForeignKey_OnDelete(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers ON DELETE CASCADE)")
+	exec(t, e, "CREATE TABLE lines (id INTEGER PRIMARY KEY, order_id INTEGER NOT NULL REFERENCES orders ON DELETE CASCADE, qty INTEGER)")
+	exec(t, e, "CREATE INDEX lines_order_idx ON lines (order_id)")
+	exec(t, e, "CREATE TABLE notes (id INTEGER PRIMARY KEY, order_id INTEGER REFERENCES orders ON DELETE SET NULL)")
+	exec(t, e, "INSERT INTO customers VALUES (1, 'ann'), (2, 'bob')")
+	exec(t, e, "INSERT INTO orders VALUES (10, 1), (11, 1), (20, 2)")
+	exec(t, e, "INSERT INTO lines VALUES (100, 10, 1), (101, 10, 2), (110, 11, 3), (200, 20, 4)")
+	exec(t, e, "INSERT INTO notes VALUES (1000, 10), (1001, 20)")
+
+	// Deleting a customer deletes their orders, the orders' lines, and
+	// detaches the notes on those orders.
+	if r := exec(t, e, "DELETE FROM customers WHERE id = 1"); r.Tag != "DELETE 1" {
+		t.Errorf("tag = %q, want DELETE 1", r.Tag)
+	}
+	if got := column(t, e, "SELECT id FROM orders ORDER BY id"); got != "20" {
+		t.Errorf("orders = %s, want 20", got)
+	}
+	if got := column(t, e, "SELECT id FROM lines ORDER BY id"); got != "200" {
+		t.Errorf("lines = %s, want 200", got)
+	}
+	if got := column(t, e, "SELECT order_id FROM notes ORDER BY id"); got != "null,20" {
+		t.Errorf("notes.order_id = %s, want null,20", got)
+	}
+	// The index on the cascaded table no longer finds the deleted lines.
+	if got := column(t, e, "SELECT id FROM lines INDEXED BY lines_order_idx WHERE order_id = 10"); got != "" {
+		t.Errorf("index lookup = %s, want no rows", got)
+	}
+
+	// NO ACTION and RESTRICT refuse the delete, and so does SET NULL on a
+	// NOT NULL column; a refused delete changes nothing.
+	exec(t, e, "CREATE TABLE invoices (id INTEGER PRIMARY KEY, order_id INTEGER REFERENCES orders)")
+	exec(t, e, "INSERT INTO invoices VALUES (1, 20)")
+	_, err := e.Execute("DELETE FROM customers")
+	assertSQLSTATE(t, err, "23503")
+	if got := column(t, e, "SELECT id FROM lines"); got != "200" {
+		t.Errorf("lines after refused delete = %s, want 200", got)
+	}
+	exec(t, e, "DELETE FROM invoices")
+	exec(t, e, "CREATE TABLE audits (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers ON DELETE RESTRICT)")
+	exec(t, e, "INSERT INTO audits VALUES (1, 2)")
+	_, err = e.Execute("DELETE FROM customers WHERE id = 2")
+	assertSQLSTATE(t, err, "23503")
+	exec(t, e, "DROP TABLE audits")
+	exec(t, e, "CREATE TABLE tags (id INTEGER PRIMARY KEY, customer_id INTEGER NOT NULL REFERENCES customers ON DELETE SET NULL)")
+	exec(t, e, "INSERT INTO tags VALUES (1, 2)")
+	_, err = e.Execute("DELETE FROM customers WHERE id = 2")
+	assertSQLSTATE(t, err, "23502")
+	if got := column(t, e, "SELECT id FROM orders"); got != "20" {
+		t.Errorf("orders after refused delete = %s, want 20", got)
+	}
+	exec(t, e, "DROP TABLE tags")
+
+	// NO ACTION only fails if referencing rows are left once the cascades
+	// ran: here the cascade from orders removes the lines first.
+	exec(t, e, "CREATE TABLE shipments (id INTEGER PRIMARY KEY, line_id INTEGER REFERENCES lines)")
+	exec(t, e, "INSERT INTO shipments VALUES (1, 200)")
+	_, err = e.Execute("DELETE FROM customers WHERE id = 2")
+	assertSQLSTATE(t, err, "23503")
+	exec(t, e, "DELETE FROM shipments")
+	r := exec(t, e, "DELETE FROM customers WHERE id = 2 RETURNING name")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "bob" {
+		t.Errorf("RETURNING = %v, want bob", r.Rows)
+	}
+	for _, table := range []string{"orders", "lines"} {
+		if got := column(t, e, "SELECT COUNT(*) FROM "+table); got != "0" {
+			t.Errorf("%s rows = %s, want 0", table, got)
+		}
+	}
+}
+
+func TestForeignKey_SelfReference(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE dirs (id INTEGER PRIMARY KEY, parent INTEGER REFERENCES dirs ON DELETE CASCADE)")
+	// A row may reference a row inserted by the same statement.
+	exec(t, e, "INSERT INTO dirs VALUES (1, NULL), (2, 1), (3, 2), (4, 3), (5, NULL), (6, 5)")
+	exec(t, e, "DELETE FROM dirs WHERE id = 2")
+	if got := column(t, e, "SELECT id FROM dirs ORDER BY id"); got != "1,5,6" {
+		t.Errorf("dirs = %s, want 1,5,6", got)
+	}
+	exec(t, e, "DELETE FROM dirs")
+	if got := column(t, e, "SELECT COUNT(*) FROM dirs"); got != "0" {
+		t.Errorf("rows = %s, want 0", got)
+	}
+}
+
+func TestForeignKey_Transaction(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE p (id INTEGER PRIMARY KEY)")
+	exec(t, e, "CREATE TABLE c (id INTEGER PRIMARY KEY, pid INTEGER REFERENCES p ON DELETE CASCADE)")
+	exec(t, e, "INSERT INTO p VALUES (1), (2)")
+	exec(t, e, "INSERT INTO c VALUES (10, 1), (20, 2)")
+
+	// Inside a transaction the checks see its own writes, and the cascade
+	// stays in the transaction until it commits.
+	tx := storage.NewTxEngine(e.Engine())
+	te := e.WithEngine(tx)
+	exec(t, te, "INSERT INTO p VALUES (3)")
+	exec(t, te, "INSERT INTO c VALUES (30, 3)")
+	exec(t, te, "DELETE FROM p WHERE id = 1")
+	if got := column(t, te, "SELECT id FROM c ORDER BY id"); got != "20,30" {
+		t.Errorf("c in transaction = %s, want 20,30", got)
+	}
+	if got := column(t, e, "SELECT id FROM c ORDER BY id"); got != "10,20" {
+		t.Errorf("c outside transaction = %s, want 10,20", got)
+	}
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+	if got := column(t, e, "SELECT id FROM c ORDER BY id"); got != "20,30" {
+		t.Errorf("c after commit = %s, want 20,30", got)
+	}
+}
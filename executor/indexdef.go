@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"strings"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// quoteIdent returns name as it must be written in SQL: bare when it is a
+// plain lower-case identifier that is not a keyword, double-quoted otherwise.
+func quoteIdent(name string) string {
+	bare := name != "" && parser.LookupKeyword(name) == parser.TokenIdent
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r == '_' || i > 0 && r >= '0' && r <= '9') {
+			bare = false
+			break
+		}
+	}
+	if bare {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// indexDefinition reconstructs the CREATE INDEX statement for idx, in a
+// form the parser accepts (see pg_indexes.indexdef).
+func indexDefinition(def *storage.TableDef, idx storage.IndexDef) string {
+	var b strings.Builder
+	b.WriteString("CREATE ")
+	if idx.Unique {
+		b.WriteString("UNIQUE ")
+	}
+	b.WriteString("INDEX ")
+	b.WriteString(quoteIdent(idx.Name))
+	b.WriteString(" ON ")
+	b.WriteString(quoteIdent(def.Name))
+	b.WriteString(" (")
+	for i, col := range idx.Columns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(quoteIdent(col))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// primaryKeyIndex returns the implicit unique index backing the primary key,
+// named <table>_pkey as in PostgreSQL, or false if def has no primary key.
+func primaryKeyIndex(def *storage.TableDef) (storage.IndexDef, bool) {
+	for _, col := range def.Columns {
+		if col.PrimaryKey {
+			return storage.IndexDef{Name: def.Name + "_pkey", Columns: []string{col.Name}, Unique: true}, true
+		}
+	}
+	return storage.IndexDef{}, false
+}
@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"sort"
+	"strings"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// indexUnion reports whether where can be answered by probing an index
+// once per value instead of scanning the table. That is the case when one
+// of its AND-ed terms is an equality with a literal, an OR of such
+// equalities on a single column, as in id = 1 OR id = 2 OR id = 3, or an
+// IN list of literals, and the column is the primary key or the only
+// column of a secondary index. It returns the
+// index, "PRIMARY" for the key, and the distinct values in ascending
+// order. The whole WHERE clause must still be applied to the rows found.
+func indexUnion(where parser.Expr, def *storage.TableDef) (index string, keys []any, ok bool) {
+	switch x := where.(type) {
+	case *parser.BinaryExpr:
+		if strings.EqualFold(x.Op, "AND") {
+			if index, keys, ok = indexUnion(x.Left, def); ok {
+				return index, keys, true
+			}
+			return indexUnion(x.Right, def)
+		}
+		if !strings.EqualFold(x.Op, "OR") && x.Op != "=" {
+			return "", nil, false
+		}
+	case *parser.InExpr:
+		if x.Not {
+			return "", nil, false
+		}
+	default:
+		return "", nil, false
+	}
+
+	col, lits, ok := equalityChain(where)
+	if !ok {
+		return "", nil, false
+	}
+	colIdx := columnIndex(def, col)
+	if colIdx < 0 {
+		return "", nil, false
+	}
+	if colIdx == def.PrimaryKeyColumn() {
+		index = "PRIMARY"
+	} else {
+		for _, idx := range def.Indexes {
+			if len(idx.Columns) == 1 && strings.EqualFold(idx.Columns[0], col) {
+				index = idx.Name
+				break
+			}
+		}
+		if index == "" {
+			return "", nil, false
+		}
+	}
+
+	// Convert the literals to the column's type, as the row filter does
+	// when it compares them.
+	var colType storage.DataType
+	for _, c := range def.Columns {
+		if c.Ordinal == colIdx {
+			colType = c.DataType
+		}
+	}
+	for _, lit := range lits {
+		v, err := evalLiteral(lit)
+		if err != nil || v == nil {
+			return "", nil, false
+		}
+		if v, err = coerceLiteral(v, colType); err != nil {
+			return "", nil, false
+		}
+		keys = append(keys, v)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return storage.CompareValues(keys[i], keys[j]) < 0
+	})
+	distinct := keys[:1]
+	for _, k := range keys[1:] {
+		if storage.CompareValues(k, distinct[len(distinct)-1]) != 0 {
+			distinct = append(distinct, k)
+		}
+	}
+	return index, distinct, true
+}
+
+// equalityChain reports whether expr is an OR of col = literal terms, or
+// col IN (literal, ...), all on the same column, and returns the column
+// name and the literals.
+func equalityChain(expr parser.Expr) (col string, lits []parser.Expr, ok bool) {
+	switch x := expr.(type) {
+	case *parser.BinaryExpr:
+		if strings.EqualFold(x.Op, "OR") {
+			lc, ll, lok := equalityChain(x.Left)
+			rc, rl, rok := equalityChain(x.Right)
+			if !lok || !rok || !strings.EqualFold(lc, rc) {
+				return "", nil, false
+			}
+			return lc, append(ll, rl...), true
+		}
+		if x.Op != "=" {
+			return "", nil, false
+		}
+		ref, lit := extractColumnAndLiteral(x)
+		if ref == nil {
+			return "", nil, false
+		}
+		return ref.Name, []parser.Expr{lit}, true
+	case *parser.InExpr:
+		ref, isCol := x.Expr.(*parser.ColumnRef)
+		if x.Not || !isCol || len(x.Values) == 0 {
+			return "", nil, false
+		}
+		for _, v := range x.Values {
+			if !isLiteralExpr(v) {
+				return "", nil, false
+			}
+		}
+		return ref.Name, x.Values, true
+	}
+	return "", nil, false
+}
+
+// lookupIndexUnion probes index once for each key and returns the rows
+// found, each row once, in key order.
+func (e *Executor) lookupIndexUnion(def *storage.TableDef, index string, keys []any) ([]storage.Row, error) {
+	var rows []storage.Row
+	seen := make(map[int64]bool)
+	add := func(r storage.Row) {
+		if !seen[r.ID] {
+			seen[r.ID] = true
+			rows = append(rows, r)
+		}
+	}
+	for _, k := range keys {
+		if index == "PRIMARY" {
+			row, err := e.engine.LookupByPK(def.Name, k)
+			if err != nil {
+				return nil, WrapError(err)
+			}
+			if row != nil {
+				add(*row)
+			}
+			continue
+		}
+		found, err := e.engine.LookupByIndex(def.Name, index, k)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		for _, r := range found {
+			add(r)
+		}
+	}
+	return rows, nil
+}
+
+// indexUnionSorted reports whether the rows of an index union on index
+// are already in ORDER BY order: the query is ordered by the indexed
+// column alone, ascending.
+func indexUnionSorted(s *parser.SelectStmt, def *storage.TableDef, index string) bool {
+	if s.IndexedBy != "" || len(s.OrderBy) != 1 || s.OrderBy[0].Expr != nil || s.OrderBy[0].Desc {
+		return false
+	}
+	col := columnIndex(def, s.OrderBy[0].Column)
+	if index == "PRIMARY" {
+		return col >= 0 && col == def.PrimaryKeyColumn()
+	}
+	idx, err := namedIndex(index, def)
+	return err == nil && len(idx.Columns) == 1 && columnIndex(def, idx.Columns[0]) == col
+}
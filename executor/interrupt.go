@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"context"
+	"time"
+
+	"mulldb/storage"
+)
+
+// interruptPollRows is how many rows a loop processes between checks of
+// the statement's context.
+const interruptPollRows = 1024
+
+// interrupt watches the context of a running statement. Table scans, the
+// join loop and ORDER BY sorts poll it and stop early once the context is cancelled or
+// past its deadline; the statement then fails with err instead of
+// returning the rows it has so far.
+type interrupt struct {
+	ctx   context.Context
+	polls int
+	done  bool // the statement has returned; later cursor fetches run freely
+	err   error
+}
+
+// stopped reports whether the statement has to stop. It is safe on a nil
+// interrupt, which never stops.
+func (in *interrupt) stopped() bool {
+	return in.stoppedAfter(1)
+}
+
+// stoppedAfter is stopped for a loop that has processed n rows since it
+// last asked, such as a scan handing out a batch.
+func (in *interrupt) stoppedAfter(n int) bool {
+	if in == nil || in.done {
+		return false
+	}
+	if in.err != nil {
+		return true
+	}
+	before := in.polls
+	in.polls += n
+	if in.polls/interruptPollRows == before/interruptPollRows {
+		return false
+	}
+	// The deadline is compared directly as well: the timer that cancels
+	// the context may not have fired yet.
+	deadline, hasDeadline := in.ctx.Deadline()
+	switch err := in.ctx.Err(); {
+	case err == context.DeadlineExceeded, err == nil && hasDeadline && time.Now().After(deadline):
+		in.err = &QueryError{Code: "57014", Message: "canceling statement due to statement timeout"}
+	case err != nil:
+		in.err = &QueryError{Code: "57014", Message: "canceling statement due to user request"}
+	default:
+		return false
+	}
+	return true
+}
+
+// WithContext returns an executor whose statements stop with SQLSTATE
+// 57014 when ctx is cancelled or its deadline passes. The server derives
+// one per statement so that a CancelRequest can reach it.
+func (e *Executor) WithContext(ctx context.Context) *Executor {
+	c := *e
+	c.ctx = ctx
+	return &c
+}
+
+// statementContext returns the context a statement runs under: the
+// executor's, limited to timeout if that is positive. It is nil if there
+// is neither, and the statement need not be watched. cancel releases the
+// timer and must be called when the statement ends.
+func (e *Executor) statementContext(timeout time.Duration) (ctx context.Context, cancel context.CancelFunc) {
+	ctx = e.ctx
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// withInterrupt returns an executor for one statement that polls ctx.
+func (e *Executor) withInterrupt(ctx context.Context) *Executor {
+	in := &interrupt{ctx: ctx}
+	return &Executor{
+		engine:   interruptEngine{Engine: e.engine, in: in},
+		rand:     e.rand,
+		settings: e.settings,
+		ctx:      ctx,
+		intr:     in,
+		txTime:   e.txTime,
+	}
+}
+
+// interruptEngine hands out scans that end early when in fires.
+type interruptEngine struct {
+	storage.Engine
+	in *interrupt
+}
+
+func (e interruptEngine) Scan(table string) (storage.RowIterator, error) {
+	it, err := e.Engine.Scan(table)
+	if err != nil {
+		return nil, err
+	}
+	return &interruptIterator{RowIterator: it, in: e.in}, nil
+}
+
+func (e interruptEngine) ScanPKRange(table string, lo, hi *storage.KeyBound) (storage.RowIterator, error) {
+	it, err := e.Engine.ScanPKRange(table, lo, hi)
+	if err != nil {
+		return nil, err
+	}
+	return &interruptIterator{RowIterator: it, in: e.in}, nil
+}
+
+func (e interruptEngine) ScanEqual(table, column string, value any) (storage.RowIterator, error) {
+	it, err := e.Engine.ScanEqual(table, column, value)
+	if err != nil {
+		return nil, err
+	}
+	return &interruptIterator{RowIterator: it, in: e.in}, nil
+}
+
+type interruptIterator struct {
+	storage.RowIterator
+	in *interrupt
+}
+
+func (it *interruptIterator) Next() (storage.Row, bool) {
+	if it.in.stopped() {
+		return storage.Row{}, false
+	}
+	return it.RowIterator.Next()
+}
+
+func (it *interruptIterator) NextBatch(n int) []storage.Row {
+	rows := storage.NextBatch(it.RowIterator, n)
+	if it.in.stoppedAfter(len(rows)) {
+		return nil
+	}
+	return rows
+}
@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fillTable inserts the integers 1..n into the single column of table.
+func fillTable(t *testing.T, e *Executor, table string, n int) {
+	t.Helper()
+	vals := make([]string, n)
+	for i := range vals {
+		vals[i] = fmt.Sprintf("(%d)", i+1)
+	}
+	exec(t, e, fmt.Sprintf("INSERT INTO %s VALUES %s", table, strings.Join(vals, ", ")))
+}
+
+func TestExecutor_TimeoutHint(t *testing.T) {
+	e := setup(t)
+	for _, name := range []string{"a", "b", "c"} {
+		exec(t, e, "CREATE TABLE "+name+" (n INTEGER)")
+		fillTable(t, e, name, 200)
+	}
+
+	// An 8 million row join cannot finish in a millisecond.
+	start := time.Now()
+	_, err := e.Execute("/*+ timeout(1ms) */ SELECT a.n FROM a JOIN b ON a.n = b.n JOIN c ON b.n + c.n = 0")
+	assertSQLSTATE(t, err, "57014")
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("cancelled after %v", d)
+	}
+
+	// A scan stops at its first deadline check.
+	fillTable(t, e, "a", 5000)
+	_, err = e.Execute("/*+ timeout(1ns) */ SELECT * FROM a")
+	assertSQLSTATE(t, err, "57014")
+
+	// A deadline that is not reached changes nothing.
+	r := exec(t, e, "/*+ timeout(1m) */ SELECT COUNT(*) FROM a")
+	if got := string(r.Rows[0][0]); got != "5200" {
+		t.Errorf("count = %s, want 5200", got)
+	}
+
+	// A hint comment after the first token is an ordinary comment.
+	exec(t, e, "SELECT /*+ timeout(1ns) */ COUNT(*) FROM a")
+
+	_, err = e.Execute("/*+ timeout(soon) */ SELECT 1")
+	assertSQLSTATE(t, err, "42601")
+}
+
+func TestExecutor_WithContext(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE a (n INTEGER)")
+	fillTable(t, e, "a", 3000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := exec(t, e.WithContext(ctx), "SELECT COUNT(*) FROM a")
+	if got := string(r.Rows[0][0]); got != "3000" {
+		t.Errorf("count = %s, want 3000", got)
+	}
+
+	cancel()
+	for _, sql := range []string{
+		"SELECT * FROM a",
+		"SELECT COUNT(*) FROM a",
+		"SELECT x.n FROM a x JOIN a y ON x.n = y.n",
+	} {
+		_, err := e.WithContext(ctx).Execute(sql)
+		assertSQLSTATE(t, err, "57014")
+		if err != nil && !strings.Contains(err.Error(), "user request") {
+			t.Errorf("%s: error = %v, want cancellation by user request", sql, err)
+		}
+	}
+
+	// The executor it was derived from is unaffected.
+	exec(t, e, "SELECT * FROM a")
+}
+
+func TestExecutor_WithContextCopyFrom(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (n INTEGER)")
+	var data strings.Builder
+	for i := range 3 * copyBatchRows {
+		fmt.Fprintf(&data, "%d\n", i)
+	}
+
+	// A cancelled load stops between batches and stores nothing.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := e.WithContext(ctx).CopyFrom("COPY t FROM STDIN", []byte(data.String()), nil)
+	assertSQLSTATE(t, err, "57014")
+	if r := exec(t, e, "SELECT COUNT(*) FROM t"); string(r.Rows[0][0]) != "0" {
+		t.Errorf("count after cancel = %s, want 0", r.Rows[0][0])
+	}
+
+	// So does one that runs past the statement timeout.
+	s := e.NewSession()
+	s.SetStatementTimeout(time.Nanosecond)
+	_, err = s.CopyFrom("COPY t FROM STDIN", []byte(data.String()), nil)
+	assertSQLSTATE(t, err, "57014")
+	if err != nil && !strings.Contains(err.Error(), "statement timeout") {
+		t.Errorf("error = %v, want a statement timeout", err)
+	}
+	if r := exec(t, e, "SELECT COUNT(*) FROM t"); string(r.Rows[0][0]) != "0" {
+		t.Errorf("count after timeout = %s, want 0", r.Rows[0][0])
+	}
+}
+
+func TestExecutor_StatementTimeout(t *testing.T) {
+	e := setup(t)
+	for _, name := range []string{"a", "b", "c"} {
+		exec(t, e, "CREATE TABLE "+name+" (n INTEGER)")
+		fillTable(t, e, name, 200)
+	}
+	const join = "SELECT a.n FROM a JOIN b ON a.n = b.n JOIN c ON b.n + c.n = 0"
+
+	s := e.NewSession()
+	s.SetStatementTimeout(time.Millisecond)
+	_, err := s.Execute(join)
+	assertSQLSTATE(t, err, "57014")
+	if err != nil && !strings.Contains(err.Error(), "statement timeout") {
+		t.Errorf("error = %v, want a statement timeout", err)
+	}
+
+	// Executors derived from the session share its timeout.
+	_, err = s.WithContext(context.Background()).Execute(join)
+	assertSQLSTATE(t, err, "57014")
+
+	// A hint overrides the session's timeout.
+	r := exec(t, s, "/*+ timeout(1m) */ SELECT COUNT(*) FROM a")
+	if got := string(r.Rows[0][0]); got != "200" {
+		t.Errorf("count = %s, want 200", got)
+	}
+
+	// Other sessions keep their own setting.
+	exec(t, e.NewSession(), "SELECT COUNT(*) FROM a")
+}
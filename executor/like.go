@@ -5,6 +5,8 @@ import (
 	"regexp"
 	"strings"
 	"unicode/utf8"
+
+	"mulldb/parser"
 )
 
 // likeToRegex converts a SQL LIKE pattern into a compiled Go regexp.
@@ -57,3 +59,73 @@ func resolveEscapeRune(val any) (rune, error) {
 	r, _ := utf8.DecodeRuneInString(s)
 	return r, nil
 }
+
+// similarToRegex converts a SQL SIMILAR TO pattern into a compiled Go
+// regexp. As in LIKE, % and _ are wildcards and the pattern must match the
+// whole string; in addition |, *, +, ?, {m,n}, parentheses and bracket
+// expressions keep their regular-expression meaning. Every other character,
+// including . ^ and $, is literal. Without an ESCAPE clause the escape
+// character is backslash, as in PostgreSQL.
+func similarToRegex(pattern string, escape rune, hasEscape bool) (*regexp.Regexp, error) {
+	if !hasEscape {
+		escape = '\\'
+	}
+	var b strings.Builder
+	b.WriteString("(?s)^(?:")
+
+	runes := []rune(pattern)
+	inBracket := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == escape {
+			i++
+			if i >= len(runes) {
+				return nil, &QueryError{Code: "2201B", Message: "SIMILAR TO pattern ends with escape character"}
+			}
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+			continue
+		}
+		if inBracket {
+			// Bracket expressions are copied through; ] closes them unless
+			// it is the first member.
+			if r == ']' && runes[i-1] != '[' && !(runes[i-1] == '^' && runes[i-2] == '[') {
+				inBracket = false
+			}
+			if r == '\\' {
+				b.WriteString(`\\`)
+			} else {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteByte('.')
+		case '[':
+			inBracket = true
+			b.WriteRune(r)
+		case '|', '*', '+', '?', '{', '}', '(', ')':
+			b.WriteRune(r)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	b.WriteString(")$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, &QueryError{Code: "2201B", Message: fmt.Sprintf("invalid SIMILAR TO pattern %q", pattern)}
+	}
+	return re, nil
+}
+
+// patternRegex compiles pattern as the right-hand side of e, which is a
+// LIKE, ILIKE or SIMILAR TO predicate.
+func patternRegex(e *parser.LikeExpr, pattern string, escape rune, hasEscape bool) (*regexp.Regexp, error) {
+	if e.Similar {
+		return similarToRegex(pattern, escape, hasEscape)
+	}
+	return likeToRegex(pattern, escape, hasEscape, e.CaseInsensitive)
+}
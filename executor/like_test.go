@@ -189,3 +189,61 @@ func TestResolveEscapeRune_Empty(t *testing.T) {
 		t.Error("expected error for empty escape")
 	}
 }
+
+func TestSimilarToRegex(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		input   string
+		want    bool
+	}{
+		{"%foo%", "barfoobar", true},
+		{"%foo%", "bar", false},
+		{"_ob", "Bob", true},
+		{"_ob", "Bobby", false}, // whole-string match
+		{"(a|b)%", "apple", true},
+		{"(a|b)%", "banana", true},
+		{"(a|b)%", "cherry", false},
+		{"a|b", "ab", false}, // alternation binds inside the anchors
+		{"[0-9]+", "2024", true},
+		{"[0-9]+", "20x4", false},
+		{"[^a-z]%", "Zed", true},
+		{"[^a-z]%", "zed", false},
+		{"[]x]*", "]x]", true},
+		{"ab{2}c?", "abb", true},
+		{"a.c", "abc", false}, // . is literal
+		{"a.c", "a.c", true},
+		{"$5^", "$5^", true},
+		{`100\%`, "100%", true},
+		{`100\%`, "1000", false},
+	} {
+		re, err := similarToRegex(tc.pattern, 0, false)
+		if err != nil {
+			t.Fatalf("similarToRegex(%q): %v", tc.pattern, err)
+		}
+		if got := re.MatchString(tc.input); got != tc.want {
+			t.Errorf("%q SIMILAR TO %q = %v, want %v", tc.input, tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestSimilarToRegex_Escape(t *testing.T) {
+	re, err := similarToRegex("a#_b", '#', true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("a_b") || re.MatchString("axb") {
+		t.Error("a#_b ESCAPE '#' should match only a_b")
+	}
+	// With another escape character, backslash is literal.
+	if re, _ := similarToRegex(`a\b`, '#', true); !re.MatchString(`a\b`) {
+		t.Error(`a\b ESCAPE '#' should match a\b`)
+	}
+}
+
+func TestSimilarToRegex_Invalid(t *testing.T) {
+	for _, p := range []string{"(ab", `ab\`} {
+		if _, err := similarToRegex(p, 0, false); err == nil {
+			t.Errorf("similarToRegex(%q): expected error", p)
+		}
+	}
+}
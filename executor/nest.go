@@ -28,7 +28,7 @@ func (e *Executor) compileNestColumn(nest *parser.NestExpr, outerDef *storage.Ta
 		return nil, Column{}, &QueryError{Code: "0A000", Message: "NEST subquery does not support JOINs"}
 	}
 	// No GROUP BY.
-	if len(q.GroupBy) > 0 {
+	if grouped(q) {
 		return nil, Column{}, &QueryError{Code: "0A000", Message: "NEST subquery does not support GROUP BY"}
 	}
 
@@ -86,18 +86,25 @@ func (e *Executor) compileNestColumn(nest *parser.NestExpr, outerDef *storage.Ta
 
 	// Compile ORDER BY for inner query.
 	type orderKey struct {
-		colIdx int
-		desc   bool
+		colIdx     int
+		desc       bool
+		nullsFirst bool
 	}
 	var orderKeys []orderKey
 	for _, ob := range q.OrderBy {
+		if ob.Expr != nil || ob.Position > 0 {
+			return nil, Column{}, &QueryError{Code: "0A000", Message: "ORDER BY expressions are not supported in NEST"}
+		}
 		idx := columnIndex(innerDef, ob.Column)
 		if idx < 0 {
 			return nil, Column{}, WrapError(fmt.Errorf("column %q not found in table %q", ob.Column, innerDef.Name))
 		}
-		orderKeys = append(orderKeys, orderKey{colIdx: idx, desc: ob.Desc})
+		orderKeys = append(orderKeys, orderKey{colIdx: idx, desc: ob.Desc, nullsFirst: nullsFirst(ob)})
 	}
 
+	if err := resolveLimitOffset(q); err != nil {
+		return nil, Column{}, err
+	}
 	innerLimit := q.Limit
 	innerOffset := q.Offset
 	numInnerCols := len(innerColFns)
@@ -145,6 +152,12 @@ func (e *Executor) compileNestColumn(nest *parser.NestExpr, outerDef *storage.Ta
 				for _, k := range orderKeys {
 					vi := storage.RowValue(ri.Values, k.colIdx)
 					vj := storage.RowValue(rj.Values, k.colIdx)
+					if vi == nil || vj == nil {
+						if c := compareNulls(vi, vj, k.nullsFirst); c != 0 {
+							return c < 0
+						}
+						continue
+					}
 					cmp := storage.CompareValues(vi, vj)
 					if cmp == 0 || cmp == -2 {
 						continue
@@ -189,7 +202,11 @@ func (e *Executor) compileNestColumn(nest *parser.NestExpr, outerDef *storage.Ta
 		case "JSONA":
 			return string(formatNestJSONA(rows))
 		default:
-			return string(formatNest(rows))
+			b, err := formatNest(rows)
+			if err != nil {
+				return err // reported by formatValue
+			}
+			return string(b)
 		}
 	}
 
@@ -219,6 +236,14 @@ func compileCorrelatedExpr(expr parser.Expr, innerDef *storage.TableDef, innerAl
 		v := e.Value
 		return func(_, _ storage.Row) any { return v }, nil
 
+	case *parser.TimestampLit:
+		v := e.Value
+		return func(_, _ storage.Row) any { return v }, nil
+
+	case *parser.RandomExpr:
+		next := e.Next
+		return func(_, _ storage.Row) any { return next() }, nil
+
 	case *parser.NullLit:
 		return func(_, _ storage.Row) any { return nil }, nil
 
@@ -263,6 +288,8 @@ func compileCorrelatedExpr(expr parser.Expr, innerDef *storage.TableDef, innerAl
 				return -n
 			case float64:
 				return -n
+			case storage.Numeric:
+				return n.Neg()
 			default:
 				return nil
 			}
@@ -307,8 +334,13 @@ func compileCorrelatedExpr(expr parser.Expr, innerDef *storage.TableDef, innerAl
 		if err != nil {
 			return nil, err
 		}
-		typeName := e.TypeName
-		return func(ir, or storage.Row) any { return castValue(inner(ir, or), typeName) }, nil
+		return func(ir, or storage.Row) any {
+			v, err := castTypmod(castValue(inner(ir, or), e.TypeName), e)
+			if err != nil {
+				return nil
+			}
+			return v
+		}, nil
 
 	default:
 		return nil, fmt.Errorf("unsupported expression type %T in NEST subquery", expr)
@@ -449,6 +481,9 @@ func compileCorrelatedArithmeticExpr(op string, leftFn, rightFn correlatedFunc)
 		if lv == nil || rv == nil {
 			return nil
 		}
+		if v, ok, _ := numericArith(op, lv, rv); ok {
+			return v
+		}
 		li, lok := lv.(int64)
 		ri, rok := rv.(int64)
 		if lok && rok {
@@ -498,7 +533,7 @@ func compileCorrelatedArithmeticExpr(op string, leftFn, rightFn correlatedFunc)
 	}
 }
 
-// compileCorrelatedLikeExpr compiles a LIKE/ILIKE in correlated context.
+// compileCorrelatedLikeExpr compiles a LIKE/ILIKE/SIMILAR TO in correlated context.
 func compileCorrelatedLikeExpr(e *parser.LikeExpr, innerDef *storage.TableDef, innerAlias string, outerDef *storage.TableDef, outerAlias string) (correlatedFunc, error) {
 	valFn, err := compileCorrelatedExpr(e.Expr, innerDef, innerAlias, outerDef, outerAlias)
 	if err != nil {
@@ -516,7 +551,6 @@ func compileCorrelatedLikeExpr(e *parser.LikeExpr, innerDef *storage.TableDef, i
 		}
 	}
 	not := e.Not
-	ci := e.CaseInsensitive
 
 	return func(ir, or storage.Row) any {
 		val := valFn(ir, or)
@@ -540,7 +574,7 @@ func compileCorrelatedLikeExpr(e *parser.LikeExpr, innerDef *storage.TableDef, i
 			escChar = r
 			hasEscape = true
 		}
-		re, err := likeToRegex(ps, escChar, hasEscape, ci)
+		re, err := patternRegex(e, ps, escChar, hasEscape)
 		if err != nil {
 			return nil
 		}
@@ -554,6 +588,9 @@ func compileCorrelatedLikeExpr(e *parser.LikeExpr, innerDef *storage.TableDef, i
 
 // compileCorrelatedInExpr compiles an IN expression in correlated context.
 func compileCorrelatedInExpr(e *parser.InExpr, innerDef *storage.TableDef, innerAlias string, outerDef *storage.TableDef, outerAlias string) (correlatedFunc, error) {
+	if e.Subquery != nil {
+		return nil, fmt.Errorf("unsupported expression type %T in NEST subquery", e.Subquery)
+	}
 	exprFn, err := compileCorrelatedExpr(e.Expr, innerDef, innerAlias, outerDef, outerAlias)
 	if err != nil {
 		return nil, err
@@ -636,9 +673,9 @@ func compileCorrelatedBetweenExpr(e *parser.BetweenExpr, innerDef *storage.Table
 // Empty → nil (SQL NULL).
 // Single column: (val1, val2, ...)
 // Multiple columns: ((v1a, v1b), (v2a, v2b))
-func formatNest(rows [][]any) []byte {
+func formatNest(rows [][]any) ([]byte, error) {
 	if len(rows) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	var b strings.Builder
@@ -650,7 +687,9 @@ func formatNest(rows [][]any) []byte {
 			if i > 0 {
 				b.WriteString(", ")
 			}
-			formatNestValue(&b, row[0])
+			if err := formatNestValue(&b, row[0]); err != nil {
+				return nil, err
+			}
 		}
 		b.WriteByte(')')
 	} else {
@@ -664,22 +703,29 @@ func formatNest(rows [][]any) []byte {
 				if j > 0 {
 					b.WriteString(", ")
 				}
-				formatNestValue(&b, val)
+				if err := formatNestValue(&b, val); err != nil {
+					return nil, err
+				}
 			}
 			b.WriteByte(')')
 		}
 		b.WriteByte(')')
 	}
-	return []byte(b.String())
+	return []byte(b.String()), nil
 }
 
 // formatNestValue writes a single value to the builder.
-func formatNestValue(b *strings.Builder, v any) {
+func formatNestValue(b *strings.Builder, v any) error {
 	if v == nil {
 		b.WriteString("NULL")
-		return
+		return nil
+	}
+	text, err := formatValue(v)
+	if err != nil {
+		return err
 	}
-	b.Write(formatValue(v))
+	b.Write(text)
+	return nil
 }
 
 // nestJSONValue converts a storage value to a JSON-compatible value.
@@ -692,6 +738,12 @@ func nestJSONValue(v any) any {
 		return val
 	case time.Time:
 		return val.Format(time.RFC3339)
+	case []byte:
+		return storage.FormatBytea(val)
+	case storage.Numeric:
+		return json.Number(val.String())
+	case storage.TimeOfDay:
+		return val.String()
 	default:
 		return fmt.Sprintf("%v", v)
 	}
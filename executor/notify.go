@@ -0,0 +1,24 @@
+package executor
+
+import "mulldb/parser"
+
+// maxNotifyPayload is the longest NOTIFY payload, in bytes, as in
+// PostgreSQL.
+const maxNotifyPayload = 7999
+
+// channelResult returns the result of LISTEN, UNLISTEN or NOTIFY. The
+// executor knows nothing of sessions, so the server delivers it.
+func channelResult(cmd *ChannelCommand, tr *Trace) (*Result, error) {
+	if tr != nil {
+		tr.StmtType = cmd.Op
+	}
+	return &Result{Tag: cmd.Op, Channel: cmd}, nil
+}
+
+// execNotify checks the payload of a NOTIFY.
+func execNotify(s *parser.NotifyStmt, tr *Trace) (*Result, error) {
+	if len(s.Payload) > maxNotifyPayload {
+		return nil, &QueryError{Code: "22023", Message: "payload string too long"}
+	}
+	return channelResult(&ChannelCommand{Op: "NOTIFY", Channel: s.Channel, Payload: s.Payload}, tr)
+}
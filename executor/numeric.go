@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"fmt"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// numericArith applies an arithmetic operator when either operand is
+// NUMERIC and the other is an INTEGER, FLOAT or NUMERIC. A FLOAT operand
+// is taken at its shortest decimal form, so price * 1.1 stays exact. ok is
+// false when neither operand is NUMERIC or op is not one of + - * / %,
+// leaving those to the caller. An operand that is not a number returns a
+// 42883 error, and division by zero a 22012 error.
+func numericArith(op string, lv, rv any) (v any, ok bool, err error) {
+	_, lnum := lv.(storage.Numeric)
+	_, rnum := rv.(storage.Numeric)
+	if !lnum && !rnum {
+		return nil, false, nil
+	}
+	switch op {
+	case "+", "-", "*", "/", "%":
+	default:
+		return nil, false, nil
+	}
+	l, lerr := storage.CoerceNumeric(lv, 0, 0)
+	r, rerr := storage.CoerceNumeric(rv, 0, 0)
+	if lerr != nil || rerr != nil {
+		return nil, true, &QueryError{
+			Code:    "42883",
+			Message: fmt.Sprintf("operator %s is not defined for the given types", op),
+		}
+	}
+	switch op {
+	case "+":
+		return l.Add(r), true, nil
+	case "-":
+		return l.Sub(r), true, nil
+	case "*":
+		return l.Mul(r), true, nil
+	case "/":
+		if r.Sign() == 0 {
+			return nil, true, &QueryError{Code: "22012", Message: "division by zero"}
+		}
+		return l.Div(r), true, nil
+	default:
+		if r.Sign() == 0 {
+			return nil, true, &QueryError{Code: "22012", Message: "division by zero"}
+		}
+		return l.Mod(r), true, nil
+	}
+}
+
+// isNumericExpr reports whether expr evaluates to NUMERIC: a NUMERIC
+// column, a cast to NUMERIC, or arithmetic involving either. colType
+// resolves column references in the caller's scope.
+func isNumericExpr(expr parser.Expr, colType func(parser.Expr) (storage.DataType, bool)) bool {
+	switch e := expr.(type) {
+	case *parser.ColumnRef:
+		dt, ok := colType(e)
+		return ok && dt == storage.TypeNumeric
+	case *parser.CastExpr:
+		return e.TypeName == "NUMERIC"
+	case *parser.UnaryExpr:
+		return isNumericExpr(e.Expr, colType)
+	case *parser.BinaryExpr:
+		switch e.Op {
+		case "+", "-", "*", "/", "%":
+			return isNumericExpr(e.Left, colType) || isNumericExpr(e.Right, colType)
+		}
+	}
+	return false
+}
@@ -0,0 +1,348 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// ExecuteParams runs a single SQL statement whose $n placeholders are
+// bound to params[n-1]. A value is nil for NULL, or an int64, float64,
+// bool, string, time.Time, storage.TimeOfDay, []byte or storage.Numeric.
+func (e *Executor) ExecuteParams(sql string, params []any) (*Result, error) {
+	return e.execute(sql, params, nil)
+}
+
+// ExecuteParamsTraced is ExecuteParams with timing instrumentation.
+func (e *Executor) ExecuteParamsTraced(sql string, params []any) (*Result, *Trace, error) {
+	tr := &Trace{}
+	start := time.Now()
+	result, err := e.execute(sql, params, tr)
+	tr.Total = time.Since(start)
+	return result, tr, err
+}
+
+// Prepare parses sql, which may contain $n placeholders, and returns the
+// type OIDs of its parameters. paramOIDs holds the types the client
+// declared, 0 meaning unspecified. An undeclared parameter takes the type
+// of the column it is compared with or assigned to, or of a cast applied
+// to it, and is text otherwise.
+func (e *Executor) Prepare(sql string, paramOIDs []int32) ([]int32, error) {
+	stmt, err := parser.Parse(sql)
+	if err != nil {
+		return nil, &QueryError{Code: "42601", Message: err.Error()}
+	}
+	n := len(paramOIDs)
+	bindStatementExprs(stmt, func(expr parser.Expr) parser.Expr {
+		if p, ok := expr.(*parser.ParamRef); ok {
+			n = max(n, p.Index)
+		}
+		return nil
+	})
+	oids := make([]int32, n)
+	copy(oids, paramOIDs)
+	e.withViews().inferParamTypes(stmt, oids)
+	for i, oid := range oids {
+		if oid == 0 {
+			oids[i] = OIDText
+		}
+	}
+	return oids, nil
+}
+
+// DescribeColumns returns the columns sql returns when run with
+// parameters of the given types, or nil if it returns no rows. Nothing is
+// modified: a query runs with NULL parameters and LIMIT 0, and UPDATE,
+// INSERT and DELETE only have their RETURNING list resolved.
+func (e *Executor) DescribeColumns(sql string, paramOIDs []int32) ([]Column, error) {
+	stmt, err := parser.Parse(sql)
+	if err != nil {
+		return nil, &QueryError{Code: "42601", Message: err.Error()}
+	}
+	bindStatementExprs(stmt, func(expr parser.Expr) parser.Expr {
+		p, ok := expr.(*parser.ParamRef)
+		if !ok {
+			return nil
+		}
+		var oid int32
+		if p.Index <= len(paramOIDs) {
+			oid = paramOIDs[p.Index-1]
+		}
+		return &parser.CastExpr{Expr: &parser.NullLit{}, TypeName: oidTypeName(oid)}
+	})
+
+	var table parser.TableRef
+	var returning []parser.Expr
+	switch s := stmt.(type) {
+	case *parser.SelectStmt:
+		zero := int64(0)
+		s.Limit, s.LimitExpr = &zero, nil
+		s.Offset, s.OffsetExpr = nil, nil
+		e = e.withViews()
+		runs, err := e.bindSubqueries(s)
+		if err != nil {
+			return nil, e.viewFailure(err)
+		}
+		r, err := e.execSelect(s, nil)
+		if err = e.viewFailure(runs.failure(err)); err != nil {
+			return nil, err
+		}
+		return r.Columns, nil
+	case *parser.SetOpStmt:
+		limitZero(s)
+		e = e.withViews()
+		runs, err := e.bindSubqueries(s)
+		if err != nil {
+			return nil, e.viewFailure(err)
+		}
+		r, err := e.execSetOp(s, nil)
+		if err = e.viewFailure(runs.failure(err)); err != nil {
+			return nil, err
+		}
+		return r.Columns, nil
+	case *parser.ValuesStmt:
+		r, err := execValues(s)
+		if err != nil {
+			return nil, err
+		}
+		return r.Columns, nil
+	case *parser.ExplainStmt:
+		return []Column{{Name: "QUERY PLAN", TypeOID: OIDText, TypeSize: -1}}, nil
+	case *parser.ShowMemoryStmt:
+		r, err := e.execShowMemory(nil)
+		if err != nil {
+			return nil, err
+		}
+		return r.Columns, nil
+	case *parser.InsertStmt:
+		table, returning = s.Table, s.Returning
+	case *parser.UpdateStmt:
+		table, returning = s.Table, s.Returning
+	case *parser.DeleteStmt:
+		table, returning = s.Table, s.Returning
+	default:
+		return nil, nil
+	}
+	if len(returning) == 0 {
+		return nil, nil
+	}
+	def, ok := e.engine.GetTable(table.Name)
+	if !ok {
+		return nil, WrapError(&storage.TableNotFoundError{Name: table.String()})
+	}
+	ret, err := e.compileReturning(returning, def)
+	if err != nil {
+		return nil, err
+	}
+	return ret.cols, nil
+}
+
+// limitZero sets LIMIT 0 on each query of a set operation, so that it
+// describes its columns without reading any rows.
+func limitZero(s *parser.SetOpStmt) {
+	zero := int64(0)
+	for _, q := range []parser.Statement{s.Left, s.Right} {
+		switch q := q.(type) {
+		case *parser.SelectStmt:
+			q.Limit, q.LimitExpr = &zero, nil
+			q.Offset, q.OffsetExpr = nil, nil
+		case *parser.SetOpStmt:
+			limitZero(q)
+		}
+	}
+	s.Limit, s.LimitExpr = &zero, nil
+	s.Offset, s.OffsetExpr = nil, nil
+}
+
+// bindParams replaces the $n placeholders in stmt with literals holding
+// params[n-1]. A placeholder without a value is an error.
+func bindParams(stmt parser.Statement, params []any) error {
+	var err error
+	bindStatementExprs(stmt, func(expr parser.Expr) parser.Expr {
+		p, ok := expr.(*parser.ParamRef)
+		if !ok {
+			return nil
+		}
+		if p.Index > len(params) {
+			if err == nil {
+				err = &QueryError{Code: "42P02", Message: fmt.Sprintf("there is no parameter $%d", p.Index)}
+			}
+			return &parser.NullLit{}
+		}
+		return paramLiteral(params[p.Index-1])
+	})
+	return err
+}
+
+// paramLiteral returns the literal for a bound parameter value. Types
+// without a literal of their own are written as a cast from text.
+func paramLiteral(v any) parser.Expr {
+	switch x := v.(type) {
+	case nil:
+		return &parser.NullLit{}
+	case int64:
+		return &parser.IntegerLit{Value: x}
+	case float64:
+		return &parser.FloatLit{Value: x}
+	case bool:
+		return &parser.BoolLit{Value: x}
+	case string:
+		return &parser.StringLit{Value: x}
+	case time.Time:
+		return &parser.TimestampLit{Value: x}
+	case []byte:
+		return &parser.CastExpr{Expr: &parser.StringLit{Value: storage.FormatBytea(x)}, TypeName: "BYTEA"}
+	case storage.Numeric:
+		return &parser.CastExpr{Expr: &parser.StringLit{Value: x.String()}, TypeName: "NUMERIC"}
+	case storage.TimeOfDay:
+		return &parser.CastExpr{Expr: &parser.StringLit{Value: x.String()}, TypeName: "TIME"}
+	}
+	return &parser.StringLit{Value: fmt.Sprint(v)}
+}
+
+// oidTypeName returns the cast type name for a parameter type OID.
+func oidTypeName(oid int32) string {
+	switch oid {
+	case OIDInt2, OIDInt4, OIDInt8:
+		return "INTEGER"
+	case OIDFloat4, OIDFloat8:
+		return "FLOAT"
+	case OIDBool:
+		return "BOOLEAN"
+	case OIDTimestamp, OIDTimestampTZ:
+		return "TIMESTAMP"
+	case OIDBytea:
+		return "BYTEA"
+	case OIDNumeric:
+		return "NUMERIC"
+	case OIDTime:
+		return "TIME"
+	}
+	return "TEXT"
+}
+
+// inferParamTypes fills in the zero entries of oids from the context each
+// parameter appears in.
+func (e *Executor) inferParamTypes(stmt parser.Statement, oids []int32) {
+	var scope []paramTable
+	addTable := func(ref parser.TableRef, alias string) {
+		if def, ok := e.engine.GetTable(ref.Name); ok {
+			scope = append(scope, paramTable{alias: alias, def: def})
+		}
+	}
+	set := func(expr parser.Expr, oid int32) {
+		if p, ok := expr.(*parser.ParamRef); ok && oid != OIDUnknown && oids[p.Index-1] == 0 {
+			oids[p.Index-1] = oid
+		}
+	}
+	setColumn := func(expr parser.Expr, name string) {
+		if dt, ok := scopeColumnType(scope, "", name); ok {
+			set(expr, typeOID(dt))
+		}
+	}
+
+	switch s := stmt.(type) {
+	case *parser.SetOpStmt:
+		// Each query has its own tables.
+		e.inferParamTypes(s.Left, oids)
+		e.inferParamTypes(s.Right, oids)
+		set(s.LimitExpr, OIDInt8)
+		set(s.OffsetExpr, OIDInt8)
+		return
+	case *parser.SelectStmt:
+		addTable(s.From, s.FromAlias)
+		for _, j := range s.Joins {
+			addTable(j.Table, j.Alias)
+		}
+		set(s.LimitExpr, OIDInt8)
+		set(s.OffsetExpr, OIDInt8)
+	case *parser.InsertStmt:
+		addTable(s.Table, "")
+		if len(scope) == 1 {
+			for _, row := range s.Values {
+				for i, v := range row {
+					switch {
+					case s.Columns != nil && i < len(s.Columns):
+						setColumn(v, s.Columns[i])
+					case s.Columns == nil && i < len(scope[0].def.Columns):
+						set(v, typeOID(scope[0].def.Columns[i].DataType))
+					}
+				}
+			}
+		}
+		if s.OnConflict != nil {
+			for _, sc := range s.OnConflict.Sets {
+				setColumn(sc.Value, sc.Column)
+			}
+		}
+	case *parser.UpdateStmt:
+		addTable(s.Table, "")
+		for _, sc := range s.Sets {
+			setColumn(sc.Value, sc.Column)
+		}
+	case *parser.DeleteStmt:
+		addTable(s.Table, "")
+	}
+
+	typeOf := func(expr parser.Expr) int32 {
+		switch x := expr.(type) {
+		case *parser.ColumnRef:
+			if dt, ok := scopeColumnType(scope, x.Table, x.Name); ok {
+				return typeOID(dt)
+			}
+		case *parser.CastExpr:
+			return castTypeOID(x.TypeName)
+		}
+		return OIDUnknown
+	}
+	pair := func(a, b parser.Expr) {
+		set(a, typeOf(b))
+		set(b, typeOf(a))
+	}
+	bindStatementExprs(stmt, func(expr parser.Expr) parser.Expr {
+		switch x := expr.(type) {
+		case *parser.BinaryExpr:
+			pair(x.Left, x.Right)
+		case *parser.InExpr:
+			for _, v := range x.Values {
+				pair(x.Expr, v)
+			}
+		case *parser.BetweenExpr:
+			pair(x.Expr, x.Low)
+			pair(x.Expr, x.High)
+		case *parser.LikeExpr:
+			set(x.Expr, OIDText)
+			set(x.Pattern, OIDText)
+			set(x.Escape, OIDText)
+		case *parser.CastExpr:
+			set(x.Expr, castTypeOID(x.TypeName))
+		}
+		return nil
+	})
+}
+
+// paramTable is a table a statement's parameters may be compared with,
+// under its alias if it has one.
+type paramTable struct {
+	alias string
+	def   *storage.TableDef
+}
+
+// scopeColumnType returns the type of the column name, qualified by table
+// unless table is empty, in the first table of scope that has it.
+func scopeColumnType(scope []paramTable, table, name string) (storage.DataType, bool) {
+	for _, t := range scope {
+		if table != "" && !strings.EqualFold(table, t.alias) && !strings.EqualFold(table, t.def.Name) {
+			continue
+		}
+		for _, c := range t.def.Columns {
+			if strings.EqualFold(c.Name, name) {
+				return c.DataType, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,193 @@
+package executor
+
+import (
+	"strings"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// pkSorted reports whether a plain single-table SELECT is ordered by the
+// primary key alone, ascending. Walking the key index then returns its
+// rows in ORDER BY order, so that the sort can be skipped.
+func pkSorted(s *parser.SelectStmt, def *storage.TableDef) bool {
+	pkCol := def.PrimaryKeyColumn()
+	if pkCol < 0 || s.IndexedBy != "" || len(s.OrderBy) != 1 {
+		return false
+	}
+	ob := s.OrderBy[0]
+	return ob.Expr == nil && !ob.Desc && columnIndex(def, ob.Column) == pkCol
+}
+
+// scanPath opens the rows of a user table that are not read through an
+// index lookup: the primary key range when p walks one, which tr then
+// reports as the PRIMARY index, and every row otherwise.
+func (e *Executor) scanPath(table string, def *storage.TableDef, where parser.Expr, p accessPath, tr *Trace) (storage.RowIterator, error) {
+	if p.pkRange() {
+		tr.setAccess("PRIMARY")
+		return e.engine.ScanPKRange(table, p.lo, p.hi)
+	}
+	tr.setAccess("")
+	return e.scanTable(table, def, where)
+}
+
+// scanTable opens a full scan of table, narrowed by storage to the rows
+// where a TEXT column equals a literal when where has such a term. The
+// engine then compares dictionary indexes instead of strings on a
+// dictionary-encoded column; the row filter still runs on every row.
+// The rows storage examined count as scanned: see rowsScanned.
+func (e *Executor) scanTable(table string, def *storage.TableDef, where parser.Expr) (storage.RowIterator, error) {
+	if column, v, ok := textEquality(where, def); ok {
+		return e.engine.ScanEqual(table, column, v)
+	}
+	return e.engine.Scan(table)
+}
+
+// rowsScanned returns the rows a scan opened by scanPath examined, given
+// that read rows were read from it: ScanEqual examines rows it does not
+// hand out.
+func rowsScanned(it storage.RowIterator, read int64) int64 {
+	if in, ok := it.(*interruptIterator); ok {
+		it = in.RowIterator
+	}
+	return storage.RowsScanned(it, read)
+}
+
+// textEquality finds a term of the AND-ed terms of where that compares a
+// TEXT column with a string literal for equality, and returns the
+// column's name and the literal's value.
+func textEquality(where parser.Expr, def *storage.TableDef) (column, value string, ok bool) {
+	e, isBin := where.(*parser.BinaryExpr)
+	if !isBin {
+		return "", "", false
+	}
+	if strings.EqualFold(e.Op, "AND") {
+		if column, value, ok = textEquality(e.Left, def); ok {
+			return column, value, true
+		}
+		return textEquality(e.Right, def)
+	}
+	if e.Op != "=" {
+		return "", "", false
+	}
+	col, lit := extractColumnAndLiteral(e)
+	str, isStr := lit.(*parser.StringLit)
+	if col == nil || !isStr {
+		return "", "", false
+	}
+	for _, c := range def.Columns {
+		if !strings.EqualFold(c.Name, col.Name) || c.DataType != storage.TypeText {
+			continue
+		}
+		return c.Name, str.Value, true
+	}
+	return "", "", false
+}
+
+// namedIndexSorted reports whether the rows of an INDEXED BY lookup are
+// already in ORDER BY order: the index has a single column and the query
+// is ordered by it alone, ascending. Equality lookups qualify as well, as
+// all their rows share the key.
+func namedIndexSorted(s *parser.SelectStmt, def *storage.TableDef) bool {
+	if s.IndexedBy == "" || len(s.OrderBy) != 1 {
+		return false
+	}
+	idx, err := namedIndex(s.IndexedBy, def)
+	if err != nil || len(idx.Columns) != 1 {
+		return false
+	}
+	ob := s.OrderBy[0]
+	return ob.Expr == nil && !ob.Desc && strings.EqualFold(ob.Column, idx.Columns[0])
+}
+
+// keyRangeBounds collects the comparisons of the key column, given by its
+// ordinal, with a literal from the AND-ed terms of where and returns the
+// tightest bounds they allow. Other terms are left to the row filter,
+// which still runs on every row the range returns.
+func keyRangeBounds(where parser.Expr, def *storage.TableDef, keyCol int) (lo, hi *storage.KeyBound) {
+	var keyType storage.DataType
+	for _, c := range def.Columns {
+		if c.Ordinal == keyCol {
+			keyType = c.DataType
+		}
+	}
+	// bound converts a literal to the key's type, as the row filter does
+	// when it compares the column with it.
+	bound := func(lit parser.Expr, inclusive bool) *storage.KeyBound {
+		v, err := evalLiteral(lit)
+		if err != nil || v == nil {
+			return nil
+		}
+		if v, err = coerceLiteral(v, keyType); err != nil {
+			return nil
+		}
+		return &storage.KeyBound{Value: v, Inclusive: inclusive}
+	}
+	isKey := func(expr parser.Expr) bool {
+		ref, ok := expr.(*parser.ColumnRef)
+		return ok && columnIndex(def, ref.Name) == keyCol
+	}
+
+	var walk func(parser.Expr)
+	walk = func(expr parser.Expr) {
+		switch e := expr.(type) {
+		case *parser.BinaryExpr:
+			if strings.EqualFold(e.Op, "AND") {
+				walk(e.Left)
+				walk(e.Right)
+				return
+			}
+			col, lit := extractColumnAndLiteral(e)
+			if col == nil || columnIndex(def, col.Name) != keyCol {
+				return
+			}
+			op := e.Op
+			if lit == e.Left {
+				// 5 < id reads as id > 5.
+				op = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<="}[op]
+			}
+			switch op {
+			case ">", ">=":
+				lo = tighterLower(lo, bound(lit, op == ">="))
+			case "<", "<=":
+				hi = tighterUpper(hi, bound(lit, op == "<="))
+			}
+		case *parser.BetweenExpr:
+			if e.Not || !isKey(e.Expr) || !isLiteralExpr(e.Low) || !isLiteralExpr(e.High) {
+				return
+			}
+			lo = tighterLower(lo, bound(e.Low, true))
+			hi = tighterUpper(hi, bound(e.High, true))
+		}
+	}
+	walk(where)
+	return lo, hi
+}
+
+// tighterLower returns whichever of two lower bounds admits fewer keys.
+func tighterLower(cur, b *storage.KeyBound) *storage.KeyBound {
+	if cur == nil {
+		return b
+	}
+	if b == nil {
+		return cur
+	}
+	if c := storage.CompareValues(b.Value, cur.Value); c > 0 || c == 0 && !b.Inclusive {
+		return b
+	}
+	return cur
+}
+
+// tighterUpper returns whichever of two upper bounds admits fewer keys.
+func tighterUpper(cur, b *storage.KeyBound) *storage.KeyBound {
+	if cur == nil {
+		return b
+	}
+	if b == nil {
+		return cur
+	}
+	if c := storage.CompareValues(b.Value, cur.Value); c < 0 || c == 0 && !b.Inclusive {
+		return b
+	}
+	return cur
+}
@@ -0,0 +1,199 @@
+package executor
+
+import (
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// Costs of the access planner, in rows read by a sequential scan. A table
+// of fewer than planMinRows rows is always scanned: reading it whole costs
+// next to nothing. Otherwise an index is chosen when the rows it is
+// estimated to find, each costing indexRowCost for the index entry and
+// the row it points to, cost less than scanning the table.
+const (
+	planMinRows  = 16
+	indexRowCost = 2
+)
+
+// accessPath is the index a single-table statement reads instead of
+// scanning, as chosen by chooseAccess. The zero value is a sequential scan.
+type accessPath struct {
+	index  string            // "PRIMARY" for the key, else a secondary index
+	keys   []any             // values to probe the index for; nil for a range
+	lo, hi *storage.KeyBound // bounds of a range, nil for an open end
+	lookup bool              // an equality on the primary key: keys holds its value
+	named  bool              // the index was named by INDEXED BY
+}
+
+// pkRange reports whether p walks a range of the primary key.
+func (p accessPath) pkRange() bool {
+	return p.index == "PRIMARY" && p.keys == nil
+}
+
+// readsIndex reports whether readIndex returns p's rows; the others are
+// read by scanPath.
+func (p accessPath) readsIndex() bool {
+	return p.index != "" && !p.pkRange()
+}
+
+// chooseAccess decides how a single-table SELECT reads its table. The
+// executor reads the table the way it returns, and EXPLAIN describes that.
+// A catalog table is always scanned. Otherwise an equality on the primary
+// key looks up its row, INDEXED BY reads the named index, or fails if
+// where does not allow it, and else cheapestAccess weighs the other
+// indexes against a scan. keyOrder asks for the rows in primary key order,
+// so that a scan walks the key instead.
+func (e *Executor) chooseAccess(table string, def *storage.TableDef, isCatalog bool, indexedBy string, where parser.Expr, keyOrder bool) (accessPath, error) {
+	if isCatalog {
+		return accessPath{}, nil
+	}
+	if where != nil {
+		if val, ok := pkLookupValue(where, def); ok {
+			return accessPath{index: "PRIMARY", keys: []any{val}, lookup: true}, nil
+		}
+	}
+	if indexedBy != "" {
+		return namedAccess(indexedBy, where, def)
+	}
+	p := e.cheapestAccess(table, def, where)
+	if p.index == "" && keyOrder {
+		p.index = "PRIMARY"
+	}
+	return p, nil
+}
+
+// modifyAccess decides how UPDATE and DELETE read their table: through
+// the index INDEXED BY names, and by a sequential scan otherwise.
+func modifyAccess(def *storage.TableDef, indexedBy string, where parser.Expr) (accessPath, error) {
+	if indexedBy == "" {
+		return accessPath{}, nil
+	}
+	return namedAccess(indexedBy, where, def)
+}
+
+// namedAccess is the read of the index INDEXED BY names, as
+// namedIndexAccess allows it.
+func namedAccess(indexName string, where parser.Expr, def *storage.TableDef) (accessPath, error) {
+	key, lo, hi, err := namedIndexAccess(indexName, where, def)
+	if err != nil {
+		return accessPath{}, err
+	}
+	p := accessPath{index: indexName, lo: lo, hi: hi, named: true}
+	if key != nil {
+		p.keys = []any{key}
+	}
+	return p, nil
+}
+
+// cheapestAccess is the cost-based step of chooseAccess. Its candidates
+// are the index reads where allows: probes of the primary key or a
+// single-column index for equalities and IN lists (see indexUnion), and a
+// walk of one of them between the bounds of <, <=, >, >= or BETWEEN. It
+// returns the cheapest, given the estimated number of rows each finds, or
+// a sequential scan if that is cheaper still or the table is small.
+func (e *Executor) cheapestAccess(table string, def *storage.TableDef, where parser.Expr) accessPath {
+	if where == nil {
+		return accessPath{}
+	}
+	var candidates []accessPath
+	if index, keys, ok := indexUnion(where, def); ok {
+		candidates = append(candidates, accessPath{index: index, keys: keys})
+	}
+	if pk := def.PrimaryKeyColumn(); pk >= 0 {
+		if lo, hi := keyRangeBounds(where, def, pk); lo != nil || hi != nil {
+			candidates = append(candidates, accessPath{index: "PRIMARY", lo: lo, hi: hi})
+		}
+	}
+	for _, idx := range def.Indexes {
+		if len(idx.Columns) != 1 {
+			continue
+		}
+		col := columnIndex(def, idx.Columns[0])
+		if col < 0 {
+			continue
+		}
+		if lo, hi := keyRangeBounds(where, def, col); lo != nil || hi != nil {
+			candidates = append(candidates, accessPath{index: idx.Name, lo: lo, hi: hi})
+		}
+	}
+	if len(candidates) == 0 {
+		return accessPath{}
+	}
+
+	n, err := e.engine.RowCount(table)
+	if err != nil || n < planMinRows {
+		return accessPath{}
+	}
+	stats := e.engine.Stats(table)
+	var best accessPath
+	bestFrac := 1.0 / indexRowCost
+	for _, c := range candidates {
+		if f := c.fraction(def, stats); f < bestFrac {
+			best, bestFrac = c, f
+		}
+	}
+	return best
+}
+
+// fraction estimates the share of the rows of def that p reads, from the
+// statistics of the last ANALYZE if there are any and from the default
+// selectivities otherwise.
+func (p accessPath) fraction(def *storage.TableDef, stats *storage.TableStats) float64 {
+	var cs *storage.ColumnStats
+	if stats != nil {
+		cs = stats.Columns[p.column(def)]
+	}
+	if p.keys != nil {
+		var f float64
+		for _, k := range p.keys {
+			if cs == nil {
+				f += defaultEqSel
+			} else {
+				f += cs.EqualFraction(k)
+			}
+		}
+		return f
+	}
+	switch {
+	case cs != nil:
+		return cs.RangeFraction(boundValue(p.lo), boundValue(p.hi))
+	case p.lo != nil && p.hi != nil:
+		return defaultRangeSel * defaultRangeSel
+	default:
+		return defaultRangeSel
+	}
+}
+
+// column returns the name of the column p's index is on.
+func (p accessPath) column(def *storage.TableDef) string {
+	if p.index == "PRIMARY" {
+		return columnByOrdinal(def, def.PrimaryKeyColumn()).Name
+	}
+	idx, err := namedIndex(p.index, def)
+	if err != nil {
+		return ""
+	}
+	return idx.Columns[0]
+}
+
+// boundValue returns the value of b, or nil for an open end.
+func boundValue(b *storage.KeyBound) any {
+	if b == nil {
+		return nil
+	}
+	return b.Value
+}
+
+// readIndex returns the rows p finds through a secondary index, or by
+// probing the primary key, in key order. A primary key range is read by
+// scanPath instead, row by row.
+func (e *Executor) readIndex(def *storage.TableDef, p accessPath) ([]storage.Row, error) {
+	if p.keys != nil {
+		return e.lookupIndexUnion(def, p.index, p.keys)
+	}
+	rows, err := e.engine.LookupByIndexRange(def.Name, p.index, p.lo, p.hi)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return rows, nil
+}
@@ -25,6 +25,42 @@ type Result struct {
 
 	// Tag is the CommandComplete tag, e.g. "SELECT 2", "INSERT 0 1".
 	Tag string
+
+	// CopyOut is set for COPY ... TO STDOUT, whose data goes to the client
+	// in CopyData messages instead of as rows. nil for other statements.
+	CopyOut *CopyOut
+
+	// CopyIn is set for COPY ... FROM STDIN, which waits for the client's
+	// data: the server collects it and passes it to CopyFrom.
+	CopyIn *CopyIn
+
+	// Notices are messages for the client about how the statement ran,
+	// sent as NOTICEs before the result.
+	Notices []string
+
+	// Channel is set for LISTEN, UNLISTEN and NOTIFY, which the server
+	// carries out for the session.
+	Channel *ChannelCommand
+}
+
+// ChannelCommand is a LISTEN, UNLISTEN or NOTIFY.
+type ChannelCommand struct {
+	Op      string // "LISTEN", "UNLISTEN" or "NOTIFY"
+	Channel string // "" for UNLISTEN *
+	Payload string // of a NOTIFY
+}
+
+// CopyOut is the data of a COPY ... TO STDOUT.
+type CopyOut struct {
+	Format  string   // "text" or "csv"
+	Columns int      // number of columns per line
+	Lines   [][]byte // one line per row, each ending in a newline
+}
+
+// CopyIn describes the data a COPY ... FROM STDIN expects.
+type CopyIn struct {
+	Format  string // "text" or "csv"
+	Columns int    // number of columns per line
 }
 
 // PostgreSQL type OIDs for the supported types.
@@ -34,9 +70,22 @@ const (
 	OIDBool        int32 = 16   // BOOLEAN
 	OIDTimestampTZ int32 = 1184 // TIMESTAMPTZ
 	OIDFloat8      int32 = 701  // FLOAT8 / DOUBLE PRECISION
+	OIDBytea       int32 = 17   // BYTEA
+	OIDNumeric     int32 = 1700 // NUMERIC / DECIMAL
+	OIDTime        int32 = 1083 // TIME WITHOUT TIME ZONE
 	OIDUnknown     int32 = 705  // UNKNOWN (used for NULL columns)
 )
 
+// Further type OIDs a client may declare for a bind parameter. Values of
+// these types are converted to the nearest supported type.
+const (
+	OIDInt2      int32 = 21   // INT2 / SMALLINT
+	OIDInt4      int32 = 23   // INT4 / INTEGER
+	OIDFloat4    int32 = 700  // FLOAT4 / REAL
+	OIDVarchar   int32 = 1043 // VARCHAR
+	OIDTimestamp int32 = 1114 // TIMESTAMP WITHOUT TIME ZONE
+)
+
 // -------------------------------------------------------------------------
 // QueryError — wraps errors with a PostgreSQL SQLSTATE code
 // -------------------------------------------------------------------------
@@ -65,6 +114,11 @@ func sqlstateForError(err error) string {
 		return "42P01" // undefined_table
 	}
 
+	var viewNotFound *storage.ViewNotFoundError
+	if errors.As(err, &viewNotFound) {
+		return "42P01" // undefined_table
+	}
+
 	var colNotFound *storage.ColumnNotFoundError
 	if errors.As(err, &colNotFound) {
 		return "42703" // undefined_column
@@ -75,11 +129,31 @@ func sqlstateForError(err error) string {
 		return "22023" // invalid_parameter_value
 	}
 
+	var schemaChanged *storage.SchemaChangedError
+	if errors.As(err, &schemaChanged) {
+		return "40001" // serialization_failure
+	}
+
+	var invalidValue *storage.InvalidValueError
+	if errors.As(err, &invalidValue) {
+		return "22P02" // invalid_text_representation
+	}
+
+	var datatypeMismatch *storage.DatatypeMismatchError
+	if errors.As(err, &datatypeMismatch) {
+		return "42804" // datatype_mismatch
+	}
+
 	var uniqueViolation *storage.UniqueViolationError
 	if errors.As(err, &uniqueViolation) {
 		return "23505" // unique_violation
 	}
 
+	var generatedAlways *storage.GeneratedAlwaysError
+	if errors.As(err, &generatedAlways) {
+		return "428C9" // generated_always
+	}
+
 	var notNullViolation *storage.NotNullViolationError
 	if errors.As(err, &notNullViolation) {
 		return "23502" // not_null_violation
@@ -100,11 +174,36 @@ func sqlstateForError(err error) string {
 		return "42704" // undefined_object
 	}
 
+	var conflictTarget *storage.ConflictTargetError
+	if errors.As(err, &conflictTarget) {
+		return "42P10" // invalid_column_reference
+	}
+
+	var affectedTwice *storage.RowAffectedTwiceError
+	if errors.As(err, &affectedTwice) {
+		return "21000" // cardinality_violation
+	}
+
 	var activeTx *storage.ActiveTxError
 	if errors.As(err, &activeTx) {
 		return "25001" // active_sql_transaction
 	}
 
+	var readOnlyTx *storage.ReadOnlyTxError
+	if errors.As(err, &readOnlyTx) {
+		return "25006" // read_only_sql_transaction
+	}
+
+	var numOverflow *storage.NumericOverflowError
+	if errors.As(err, &numOverflow) {
+		return "22003" // numeric_value_out_of_range
+	}
+
+	var walIO *storage.WALIOError
+	if errors.As(err, &walIO) {
+		return "58030" // io_error
+	}
+
 	// Fallback: syntax error or general error.
 	return "42000"
 }
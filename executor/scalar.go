@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"mulldb/parser"
+	"mulldb/storage"
 )
 
 // coerceToText converts a Go value to its text representation.
@@ -26,17 +27,58 @@ func coerceToText(v any) (string, bool) {
 		}
 		return "false", true
 	case time.Time:
-		return x.Format("2006-01-02 15:04:05+00"), true
+		return x.Format(timestampLayout), true
+	case []byte:
+		return storage.FormatBytea(x), true
+	case storage.Numeric:
+		return x.String(), true
+	case storage.TimeOfDay:
+		return x.String(), true
 	default:
 		return "", false
 	}
 }
 
+// timestampArg converts a scalar function argument to a timestamp. Strings
+// are parsed the way TIMESTAMP columns parse them; a bad string is SQLSTATE
+// 22007. ok is false for NULL.
+func timestampArg(v any, fname string) (t time.Time, ok bool, err error) {
+	switch x := v.(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case time.Time:
+		return x, true, nil
+	case string:
+		t, err := storage.ParseTimestamp(x)
+		if err != nil {
+			return time.Time{}, false, &QueryError{Code: "22007", Message: fmt.Sprintf("invalid input syntax for type timestamp: %q", x)}
+		}
+		return t, true, nil
+	default:
+		return time.Time{}, false, &QueryError{Code: "42883", Message: fname + "() requires a TIMESTAMP argument"}
+	}
+}
+
 // ScalarFunc is the signature all registered scalar functions must implement.
 // args contains pre-evaluated argument values (nil = SQL NULL).
 // Returns the result value and its column descriptor.
 type ScalarFunc func(args []any) (any, Column, error)
 
+// castTypmod applies the NUMERIC(p,s) modifier of cast to v, the result
+// of castValue: the value is rounded to the scale, and one too large for
+// the precision is a 22003 error.
+func castTypmod(v any, cast *parser.CastExpr) (any, error) {
+	n, ok := v.(storage.Numeric)
+	if !ok || cast.Precision == 0 {
+		return v, nil
+	}
+	n, err := storage.CoerceNumeric(n, cast.Precision, cast.Scale)
+	if err != nil {
+		return nil, WrapError(err)
+	}
+	return n, nil
+}
+
 // castValue coerces a Go value to the target SQL type name.
 // Returns nil for nil input. Returns the value unchanged if already the right type.
 func castValue(v any, typeName string) any {
@@ -50,6 +92,11 @@ func castValue(v any, typeName string) any {
 			return x
 		case float64:
 			return int64(x)
+		case storage.Numeric:
+			if n, ok := x.Int64(); ok {
+				return n
+			}
+			return nil
 		case bool:
 			if x {
 				return int64(1)
@@ -87,6 +134,8 @@ func castValue(v any, typeName string) any {
 			return x
 		case int64:
 			return float64(x)
+		case storage.Numeric:
+			return x.Float64()
 		case string:
 			f, err := strconv.ParseFloat(x, 64)
 			if err != nil {
@@ -94,6 +143,34 @@ func castValue(v any, typeName string) any {
 			}
 			return f
 		}
+	case "NUMERIC":
+		switch x := v.(type) {
+		case storage.Numeric, int64, float64, string:
+			n, err := storage.CoerceNumeric(x, 0, 0)
+			if err != nil {
+				return nil
+			}
+			return n
+		}
+	case "BYTEA":
+		if s, ok := v.(string); ok {
+			b, err := storage.ParseBytea(s)
+			if err != nil {
+				return nil
+			}
+			return b
+		}
+	case "TIME":
+		switch x := v.(type) {
+		case time.Time:
+			return storage.TimeOfDayOf(x)
+		case string:
+			t, err := storage.ParseTime(x)
+			if err != nil {
+				return nil
+			}
+			return t
+		}
 	}
 	return v
 }
@@ -110,6 +187,12 @@ func castTypeOID(typeName string) int32 {
 		return OIDFloat8
 	case "TIMESTAMP":
 		return OIDTimestampTZ
+	case "BYTEA":
+		return OIDBytea
+	case "NUMERIC":
+		return OIDNumeric
+	case "TIME":
+		return OIDTime
 	default:
 		return OIDUnknown
 	}
@@ -123,11 +206,67 @@ func castTypeSize(typeName string) int16 {
 		return 1
 	case "FLOAT":
 		return 8
+	case "TIME":
+		return 8
 	default:
 		return -1
 	}
 }
 
+// unifiedArgs returns the arguments whose common type is the result type
+// of call, or nil if call does not return one of its arguments.
+func unifiedArgs(call *parser.FunctionCallExpr) []parser.Expr {
+	switch call.Name {
+	case "COALESCE", "GREATEST", "LEAST":
+		return call.Args
+	case "NULLIF":
+		if len(call.Args) > 0 {
+			return call.Args[:1]
+		}
+	}
+	return nil
+}
+
+// unifyColumnTypes returns the type shared by cols, ignoring untyped
+// (NULL) ones. A mix of INTEGER, NUMERIC and FLOAT unifies to the widest
+// of them, as in PostgreSQL. It reports false if the types have nothing in
+// common or no column is typed.
+func unifyColumnTypes(cols []Column) (Column, bool) {
+	var u Column
+	found := false
+	for _, c := range cols {
+		if c.TypeOID == OIDUnknown {
+			continue
+		}
+		switch {
+		case !found:
+			u, found = c, true
+		case c.TypeOID == u.TypeOID:
+		case numericRank(c.TypeOID) > 0 && numericRank(u.TypeOID) > 0:
+			if numericRank(c.TypeOID) > numericRank(u.TypeOID) {
+				u = c
+			}
+		default:
+			return Column{}, false
+		}
+	}
+	return u, found
+}
+
+// numericRank orders the numeric type OIDs by width, and is 0 for all
+// others.
+func numericRank(oid int32) int {
+	switch oid {
+	case OIDInt8:
+		return 1
+	case OIDNumeric:
+		return 2
+	case OIDFloat8:
+		return 3
+	}
+	return 0
+}
+
 var scalarRegistry = map[string]ScalarFunc{}
 
 // RegisterScalar registers a scalar function by name (case-insensitive).
@@ -146,20 +285,43 @@ func evalStaticExpr(expr parser.Expr) (any, Column, error) {
 		return e.Value, Column{Name: "?column?", TypeOID: OIDText, TypeSize: -1}, nil
 	case *parser.BoolLit:
 		return e.Value, Column{Name: "?column?", TypeOID: OIDBool, TypeSize: 1}, nil
+	case *parser.TimestampLit:
+		return e.Value, Column{Name: "?column?", TypeOID: OIDTimestampTZ, TypeSize: 8}, nil
+	case *parser.RandomExpr:
+		return e.Next(), Column{Name: "?column?", TypeOID: OIDFloat8, TypeSize: 8}, nil
 	case *parser.NullLit:
 		return nil, Column{Name: "?column?", TypeOID: OIDUnknown, TypeSize: -1}, nil
+	case *parser.OuterRef:
+		col := Column{Name: e.Column, TypeOID: OIDUnknown, TypeSize: -1}
+		if e.Value != nil {
+			col.TypeOID, col.TypeSize = valueTypeOID(e.Value)
+		}
+		return e.Value, col, nil
 	case *parser.FunctionCallExpr:
 		return evalScalarFunction(e)
 	case *parser.BinaryExpr:
 		return evalStaticBinaryExpr(e)
 	case *parser.UnaryExpr:
 		return evalStaticUnaryExpr(e)
+	case *parser.SubqueryExpr:
+		eval, err := compileScalarSubquery(e, nil)
+		if err != nil {
+			return nil, Column{}, err
+		}
+		val := eval(storage.Row{})
+		col := Column{Name: "?column?", TypeOID: OIDUnknown, TypeSize: -1}
+		if val != nil {
+			col.TypeOID, col.TypeSize = valueTypeOID(val)
+		}
+		return val, col, nil
 	case *parser.CastExpr:
 		val, col, err := evalStaticExpr(e.Expr)
 		if err != nil {
 			return nil, Column{}, err
 		}
-		val = castValue(val, e.TypeName)
+		if val, err = castTypmod(castValue(val, e.TypeName), e); err != nil {
+			return nil, Column{}, err
+		}
 		col.TypeOID = castTypeOID(e.TypeName)
 		col.TypeSize = castTypeSize(e.TypeName)
 		return val, col, nil
@@ -183,15 +345,24 @@ func evalScalarFunction(e *parser.FunctionCallExpr) (any, Column, error) {
 	}
 
 	args := make([]any, len(e.Args))
+	argCols := make([]Column, len(e.Args))
 	for i, argExpr := range e.Args {
-		val, _, err := evalStaticExpr(argExpr)
+		val, col, err := evalStaticExpr(argExpr)
 		if err != nil {
 			return nil, Column{}, err
 		}
-		args[i] = val
+		args[i], argCols[i] = val, col
 	}
 
-	return fn(args)
+	val, col, err := fn(args)
+	if err == nil {
+		if unified := unifiedArgs(e); unified != nil {
+			if u, ok := unifyColumnTypes(argCols[:len(unified)]); ok {
+				col.TypeOID, col.TypeSize = u.TypeOID, u.TypeSize
+			}
+		}
+	}
+	return val, col, err
 }
 
 func evalStaticBinaryExpr(e *parser.BinaryExpr) (any, Column, error) {
@@ -232,6 +403,13 @@ func evalStaticBinaryExpr(e *parser.BinaryExpr) (any, Column, error) {
 		return nil, Column{Name: "?column?", TypeOID: OIDInt8, TypeSize: 8}, nil
 	}
 
+	if v, ok, err := numericArith(e.Op, lv, rv); ok {
+		if err != nil {
+			return nil, Column{}, err
+		}
+		return v, Column{Name: "?column?", TypeOID: OIDNumeric, TypeSize: -1}, nil
+	}
+
 	// Try integer arithmetic first.
 	li, lok := lv.(int64)
 	ri, rok := rv.(int64)
@@ -310,6 +488,8 @@ func evalStaticUnaryExpr(e *parser.UnaryExpr) (any, Column, error) {
 		return -n, Column{Name: "?column?", TypeOID: OIDInt8, TypeSize: 8}, nil
 	case float64:
 		return -n, Column{Name: "?column?", TypeOID: OIDFloat8, TypeSize: 8}, nil
+	case storage.Numeric:
+		return n.Neg(), Column{Name: "?column?", TypeOID: OIDNumeric, TypeSize: -1}, nil
 	default:
 		return nil, Column{}, &QueryError{
 			Code:    "42883",
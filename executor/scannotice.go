@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// seqScanNotice returns a notice for a statement that read all rows of a
+// table of at least the session's SetSeqScanNotice size while filtering
+// on columns that have no index, naming those columns; otherwise it
+// returns "". It considers single-table SELECTs, UPDATEs and DELETEs. A
+// SELECT that reads through the primary key or a secondary index does not
+// scan, nor does an UPDATE or DELETE with INDEXED BY.
+func (e *Executor) seqScanNotice(stmt parser.Statement) string {
+	if e.settings.seqScanNotice <= 0 {
+		return ""
+	}
+	var table parser.TableRef
+	var alias string
+	var where parser.Expr
+	switch s := stmt.(type) {
+	case *parser.SelectStmt:
+		if len(s.Joins) > 0 || s.Where == nil {
+			return ""
+		}
+		table, alias, where = s.From, s.FromAlias, s.Where
+	case *parser.UpdateStmt:
+		if s.IndexedBy != "" {
+			return ""
+		}
+		table, where = s.Table, s.Where
+	case *parser.DeleteStmt:
+		if s.IndexedBy != "" {
+			return ""
+		}
+		table, where = s.Table, s.Where
+	default:
+		return ""
+	}
+	if where == nil || isCatalogTable(table.Schema, table.Name) {
+		return ""
+	}
+	if _, ok := e.engine.GetView(table.Name); ok {
+		return ""
+	}
+	def, ok := e.engine.GetTable(table.Name)
+	if !ok {
+		return ""
+	}
+	if s, ok := stmt.(*parser.SelectStmt); ok {
+		if path, err := e.chooseAccess(table.Name, def, false, s.IndexedBy, where, false); err != nil || path.index != "" {
+			return ""
+		}
+	}
+
+	cols := unindexedColumns(where, def, table.Name, alias)
+	if len(cols) == 0 {
+		return ""
+	}
+	n, err := e.engine.RowCount(def.Name)
+	if err != nil || n < e.settings.seqScanNotice {
+		return ""
+	}
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = fmt.Sprintf("%q", c)
+	}
+	noun := "column"
+	if len(cols) > 1 {
+		noun = "columns"
+	}
+	return fmt.Sprintf("sequential scan of table %q (%d rows) filters on %s %s without an index",
+		def.Name, n, noun, strings.Join(quoted, ", "))
+}
+
+// unindexedColumns returns the columns of def that where refers to, in
+// order of first use, that are neither the primary key nor the leading
+// column of an index. Columns of subqueries are not def's.
+func unindexedColumns(where parser.Expr, def *storage.TableDef, table, alias string) []string {
+	indexed := func(name string) bool {
+		pk := def.PrimaryKeyColumn()
+		if pk >= 0 && columnIndex(def, name) == pk {
+			return true
+		}
+		for _, idx := range def.Indexes {
+			if strings.EqualFold(idx.Columns[0], name) {
+				return true
+			}
+		}
+		return false
+	}
+	var cols []string
+	bindExpr(where, func(expr parser.Expr) parser.Expr {
+		switch x := expr.(type) {
+		case *parser.SubqueryExpr:
+			return x
+		case *parser.ColumnRef:
+			if x.Table != "" && !strings.EqualFold(x.Table, table) && !strings.EqualFold(x.Table, alias) {
+				return nil
+			}
+			i := columnIndex(def, x.Name)
+			if i < 0 {
+				return nil
+			}
+			name := columnByOrdinal(def, i).Name
+			if !indexed(name) && !slices.Contains(cols, name) {
+				cols = append(cols, name)
+			}
+		}
+		return nil
+	})
+	return cols
+}
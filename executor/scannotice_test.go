@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSeqScanNotice(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE events (id INTEGER PRIMARY KEY, kind INTEGER, note TEXT)")
+	exec(t, e, "CREATE INDEX idx_kind ON events(kind)")
+	var values []string
+	for i := 1; i <= 200; i++ {
+		values = append(values, fmt.Sprintf("(%d, %d, 'n%d')", i, i%5, i))
+	}
+	exec(t, e, "INSERT INTO events VALUES "+strings.Join(values, ", "))
+
+	notices := func(sql string) []string {
+		t.Helper()
+		return exec(t, e, sql).Notices
+	}
+
+	// Off unless configured.
+	if got := notices("SELECT id FROM events WHERE note = 'n7'"); got != nil {
+		t.Fatalf("notices without setting = %v, want none", got)
+	}
+
+	e.SetSeqScanNotice(100)
+	got := notices("SELECT id FROM events WHERE note = 'n7'")
+	want := `sequential scan of table "events" (200 rows) filters on column "note" without an index`
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("notices = %q, want [%q]", got, want)
+	}
+	for _, sql := range []string{
+		"UPDATE events SET note = 'x' WHERE note = 'n7'",
+		"DELETE FROM events WHERE note = 'x'",
+	} {
+		if got := notices(sql); len(got) != 1 {
+			t.Errorf("%s: notices = %q, want one", sql, got)
+		}
+	}
+
+	// Index and primary key reads, scans filtering only on indexed
+	// columns, and statements without a filter pass silently.
+	for _, sql := range []string{
+		"SELECT id FROM events WHERE kind = 3",
+		"SELECT id FROM events WHERE id = 7 AND note = 'n7'",
+		"SELECT id FROM events WHERE id < 10 AND note = 'n7'",
+		"SELECT id FROM events WHERE kind > 2",
+		"SELECT COUNT(*) FROM events",
+		"SELECT id FROM events INDEXED BY idx_kind WHERE kind = 1 AND note = 'n6'",
+		"UPDATE events INDEXED BY idx_kind SET note = 'y' WHERE kind = 1 AND note = 'n6'",
+	} {
+		if got := notices(sql); got != nil {
+			t.Errorf("%s: notices = %q, want none", sql, got)
+		}
+	}
+
+	// Small tables are not worth an index.
+	e.SetSeqScanNotice(1000)
+	if got := notices("SELECT id FROM events WHERE note = 'n7'"); got != nil {
+		t.Errorf("notices below threshold = %q, want none", got)
+	}
+}
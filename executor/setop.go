@@ -0,0 +1,235 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// execSetOp runs UNION, INTERSECT or EXCEPT. Both queries run to completion
+// and their rows are read back as values of the column types the two
+// agree on. Without ALL the result has no duplicate rows; with ALL,
+// INTERSECT and EXCEPT match rows one for one, so a row the left query
+// returns three times and the right one twice appears twice in INTERSECT
+// ALL and once in EXCEPT ALL. NULLs count as equal to each other.
+func (e *Executor) execSetOp(s *parser.SetOpStmt, tr *Trace) (*Result, error) {
+	var execStart time.Time
+	if tr != nil {
+		execStart = time.Now()
+	}
+	left, err := e.dispatch(s.Left, nil)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.dispatch(s.Right, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(left.Columns) != len(right.Columns) {
+		return nil, &QueryError{Code: "42601", Message: fmt.Sprintf("each %s query must have the same number of columns", s.Op)}
+	}
+
+	// The result columns take the names of the left query's.
+	cols := make([]Column, len(left.Columns))
+	for i, lc := range left.Columns {
+		oid, err := setOpType(s.Op, lc.TypeOID, right.Columns[i].TypeOID)
+		if err != nil {
+			return nil, err
+		}
+		cols[i] = lc
+		if oid != lc.TypeOID {
+			cols[i] = Column{Name: lc.Name, TypeOID: oid, TypeSize: typeSize(oidType(oid))}
+		}
+	}
+	lrows, err := setOpRows(left, cols)
+	if err != nil {
+		return nil, err
+	}
+	rrows, err := setOpRows(right, cols)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []storage.Row
+	switch s.Op {
+	case "UNION":
+		if s.All {
+			rows = append(lrows, rrows...)
+			break
+		}
+		seen := make(map[string]bool)
+		for _, r := range append(lrows, rrows...) {
+			if k := setOpKey(r.Values); !seen[k] {
+				seen[k] = true
+				rows = append(rows, r)
+			}
+		}
+	case "INTERSECT", "EXCEPT":
+		counts := make(map[string]int)
+		for _, r := range rrows {
+			counts[setOpKey(r.Values)]++
+		}
+		intersect := s.Op == "INTERSECT"
+		seen := make(map[string]bool)
+		for _, r := range lrows {
+			k := setOpKey(r.Values)
+			matched := counts[k] > 0
+			if matched && s.All {
+				counts[k]--
+			}
+			if matched != intersect || !s.All && seen[k] {
+				continue
+			}
+			seen[k] = true
+			rows = append(rows, r)
+		}
+	}
+
+	if len(s.OrderBy) > 0 {
+		keys, err := setOpSortKeys(s.OrderBy, cols)
+		if err != nil {
+			return nil, err
+		}
+		sortRows(rows, keys, e.intr.stopped)
+	}
+
+	// LIMIT and OFFSET resolve as for a SELECT.
+	tail := &parser.SelectStmt{Limit: s.Limit, Offset: s.Offset, LimitExpr: s.LimitExpr, OffsetExpr: s.OffsetExpr}
+	if err := resolveLimitOffset(tail); err != nil {
+		return nil, err
+	}
+	if tail.Offset != nil {
+		rows = rows[min(*tail.Offset, int64(len(rows))):]
+	}
+	if tail.Limit != nil {
+		rows = rows[:min(*tail.Limit, int64(len(rows)))]
+	}
+
+	resultRows := make([][][]byte, len(rows))
+	for i, r := range rows {
+		textRow, err := formatValues(r.Values)
+		if err != nil {
+			return nil, err
+		}
+		resultRows[i] = textRow
+	}
+	if tr != nil {
+		tr.RowsReturned = int64(len(resultRows))
+		tr.Exec = time.Since(execStart)
+	}
+	return &Result{
+		Columns: cols,
+		Rows:    resultRows,
+		Tag:     fmt.Sprintf("SELECT %d", len(resultRows)),
+	}, nil
+}
+
+// setOpType returns the type of a column of a set operation whose queries
+// return types a and b. The types must be the same, except that INTEGER,
+// NUMERIC and FLOAT combine into the widest of them, and a column of
+// unknown type, such as a bare NULL, takes the other query's type.
+func setOpType(op string, a, b int32) (int32, error) {
+	rank := func(oid int32) int {
+		switch oid {
+		case OIDInt8:
+			return 1
+		case OIDNumeric:
+			return 2
+		case OIDFloat8:
+			return 3
+		}
+		return 0
+	}
+	switch {
+	case a == b && a == OIDUnknown:
+		return OIDText, nil
+	case a == b || b == OIDUnknown:
+		return a, nil
+	case a == OIDUnknown:
+		return b, nil
+	case rank(a) > 0 && rank(b) > 0:
+		if rank(a) > rank(b) {
+			return a, nil
+		}
+		return b, nil
+	}
+	return 0, &QueryError{
+		Code:    "42804",
+		Message: fmt.Sprintf("%s types %s and %s cannot be matched", op, strings.ToLower(oidTypeName(a)), strings.ToLower(oidTypeName(b))),
+	}
+}
+
+// setOpRows reads the rows of res back as values of the types of cols.
+// The text form of an INTEGER is also that of a NUMERIC or FLOAT with the
+// same value, and a NUMERIC's that of a FLOAT, so values of a narrower
+// type are converted by parsing them as the wider one.
+func setOpRows(res *Result, cols []Column) ([]storage.Row, error) {
+	rows := make([]storage.Row, len(res.Rows))
+	for i, r := range res.Rows {
+		vals := make([]any, len(r))
+		for j, cell := range r {
+			if cell == nil {
+				continue
+			}
+			v, err := parseTextValue(string(cell), cols[j].TypeOID)
+			if err != nil {
+				return nil, &QueryError{Code: "22P02", Message: fmt.Sprintf("column %q: %v", cols[j].Name, err)}
+			}
+			vals[j] = v
+		}
+		rows[i] = storage.Row{ID: int64(i + 1), Values: vals}
+	}
+	return rows, nil
+}
+
+// setOpKey returns the key by which a set operation tells rows apart.
+// NUMERICs that differ only in trailing zeros are the same value.
+func setOpKey(vals []any) string {
+	var b strings.Builder
+	for _, v := range vals {
+		if n, ok := v.(storage.Numeric); ok {
+			s := n.String()
+			if strings.Contains(s, ".") {
+				s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+			}
+			v = s
+		}
+		fmt.Fprintf(&b, "%T:%v\x00", v, v)
+	}
+	return b.String()
+}
+
+// setOpSortKeys resolves the ORDER BY of a set operation. As in
+// PostgreSQL, a key is a result column, by name or by position; the
+// columns of the queries' tables are out of scope, and so are expressions.
+func setOpSortKeys(orderBy []parser.OrderByClause, cols []Column) ([]sortKey, error) {
+	keys := make([]sortKey, len(orderBy))
+	for i, ob := range orderBy {
+		var idx int
+		switch {
+		case ob.Position > 0:
+			var err error
+			if idx, err = orderPosition(ob, len(cols)); err != nil {
+				return nil, err
+			}
+		case ob.Expr == nil && ob.Table == "":
+			idx = -1
+			for j, c := range cols {
+				if strings.EqualFold(c.Name, ob.Column) {
+					idx = j
+					break
+				}
+			}
+			if idx < 0 {
+				return nil, &QueryError{Code: "42703", Message: fmt.Sprintf("column %q does not exist", ob.Column)}
+			}
+		default:
+			return nil, &QueryError{Code: "0A000", Message: "invalid UNION/INTERSECT/EXCEPT ORDER BY clause"}
+		}
+		keys[i] = columnSortKey(idx, ob.Desc, nullsFirst(ob), oidType(cols[idx].TypeOID))
+	}
+	return keys, nil
+}
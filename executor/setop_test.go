@@ -0,0 +1,170 @@
+package executor
+
+import (
+	"slices"
+	"testing"
+)
+
+func setupSetOp(t *testing.T) *Executor {
+	t.Helper()
+	e := setup(t)
+	exec(t, e, "CREATE TABLE a (id INTEGER, name TEXT)")
+	exec(t, e, "CREATE TABLE b (id INTEGER, name TEXT)")
+	exec(t, e, "INSERT INTO a VALUES (1, 'one'), (2, 'two'), (2, 'two'), (3, 'three'), (NULL, 'none')")
+	exec(t, e, "INSERT INTO b VALUES (2, 'two'), (3, 'drei'), (4, 'four'), (NULL, 'none')")
+	return e
+}
+
+func TestSetOp_Union(t *testing.T) {
+	e := setupSetOp(t)
+
+	// Duplicates go, within each query too, and NULLs are equal.
+	r := exec(t, e, "SELECT id FROM a UNION SELECT id FROM b ORDER BY id")
+	if got, want := ids(r), []string{"1", "2", "3", "4", ""}; !slices.Equal(got, want) {
+		t.Errorf("UNION = %q, want %q", got, want)
+	}
+	if r.Tag != "SELECT 5" || r.Columns[0].Name != "id" || r.Columns[0].TypeOID != OIDInt8 {
+		t.Errorf("tag %q, columns %+v, want SELECT 5 and id INT8", r.Tag, r.Columns)
+	}
+	// ALL keeps every row, the left query's first.
+	r = exec(t, e, "SELECT id FROM a WHERE id IS NOT NULL UNION ALL SELECT id FROM b WHERE id IS NOT NULL")
+	if got, want := ids(r), []string{"1", "2", "2", "3", "2", "3", "4"}; !slices.Equal(got, want) {
+		t.Errorf("UNION ALL = %q, want %q", got, want)
+	}
+	// Rows are compared whole.
+	r = exec(t, e, "SELECT id, name FROM a UNION SELECT id, name FROM b ORDER BY 1, 2")
+	var got []string
+	for _, row := range r.Rows {
+		got = append(got, string(row[0])+"="+string(row[1]))
+	}
+	if want := []string{"1=one", "2=two", "3=drei", "3=three", "4=four", "=none"}; !slices.Equal(got, want) {
+		t.Errorf("UNION of pairs = %q, want %q", got, want)
+	}
+	// Without FROM, and a chain with LIMIT and OFFSET on the whole.
+	r = exec(t, e, "SELECT 3 AS n UNION SELECT 1 UNION SELECT 2 UNION SELECT 1 ORDER BY n DESC LIMIT 2 OFFSET 1")
+	if got, want := ids(r), []string{"2", "1"}; !slices.Equal(got, want) {
+		t.Errorf("chain = %q, want %q", got, want)
+	}
+	// A parenthesized query keeps its own ORDER BY and LIMIT.
+	r = exec(t, e, "(SELECT id FROM a ORDER BY id DESC NULLS LAST LIMIT 1) UNION ALL (SELECT id FROM b ORDER BY id LIMIT 1) ORDER BY 1")
+	if got, want := ids(r), []string{"2", "3"}; !slices.Equal(got, want) {
+		t.Errorf("parenthesized = %q, want %q", got, want)
+	}
+}
+
+func TestSetOp_IntersectExcept(t *testing.T) {
+	e := setupSetOp(t)
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id FROM a INTERSECT SELECT id FROM b ORDER BY 1", []string{"2", "3", ""}},
+		{"SELECT id FROM a EXCEPT SELECT id FROM b ORDER BY 1", []string{"1"}},
+		{"SELECT id FROM b EXCEPT SELECT id FROM a ORDER BY 1", []string{"4"}},
+		// ALL matches rows one for one.
+		{"SELECT id FROM a INTERSECT ALL SELECT id FROM b ORDER BY 1", []string{"2", "3", ""}},
+		{"SELECT id FROM a EXCEPT ALL SELECT id FROM b ORDER BY 1", []string{"1", "2"}},
+		{"SELECT id FROM a UNION ALL SELECT id FROM a INTERSECT ALL SELECT id FROM a ORDER BY 1",
+			[]string{"1", "1", "2", "2", "2", "2", "3", "3", "", ""}},
+		// INTERSECT binds more tightly than EXCEPT.
+		{"SELECT id FROM a EXCEPT SELECT 1 INTERSECT SELECT id FROM b ORDER BY 1", []string{"1", "2", "3", ""}},
+		{"(SELECT id FROM a EXCEPT SELECT 1) INTERSECT SELECT id FROM b ORDER BY 1", []string{"2", "3", ""}},
+	}
+	for _, tt := range tests {
+		if got := ids(exec(t, e, tt.sql)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSetOp_Types(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE prices (amount NUMERIC(10,2), exact NUMERIC, ratio FLOAT)")
+	exec(t, e, "INSERT INTO prices VALUES (1.5, 1.5, 2.5)")
+
+	// INTEGER and NUMERIC make NUMERIC, which compares by value.
+	r := exec(t, e, "SELECT 1 UNION SELECT amount FROM prices UNION SELECT exact FROM prices ORDER BY 1")
+	if r.Columns[0].TypeOID != OIDNumeric {
+		t.Errorf("OID = %d, want NUMERIC", r.Columns[0].TypeOID)
+	}
+	if got, want := ids(r), []string{"1", "1.50"}; !slices.Equal(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+	// With FLOAT, FLOAT.
+	r = exec(t, e, "SELECT ratio FROM prices UNION ALL SELECT amount FROM prices UNION ALL SELECT 3 ORDER BY 1")
+	if r.Columns[0].TypeOID != OIDFloat8 {
+		t.Errorf("OID = %d, want FLOAT8", r.Columns[0].TypeOID)
+	}
+	if got, want := ids(r), []string{"1.5", "2.5", "3"}; !slices.Equal(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+	// A bare NULL takes the other query's type.
+	r = exec(t, e, "SELECT NULL UNION ALL SELECT true")
+	if r.Columns[0].TypeOID != OIDBool {
+		t.Errorf("OID = %d, want BOOL", r.Columns[0].TypeOID)
+	}
+
+	_, err := e.Execute("SELECT 1 UNION SELECT 'a'")
+	assertSQLSTATE(t, err, "42804")
+	_, err = e.Execute("SELECT 1, 2 INTERSECT SELECT 1")
+	assertSQLSTATE(t, err, "42601")
+}
+
+func TestSetOp_OrderByErrors(t *testing.T) {
+	e := setupSetOp(t)
+
+	for sql, code := range map[string]string{
+		"SELECT id FROM a UNION SELECT id FROM b ORDER BY 2":       "42P10",
+		"SELECT id FROM a UNION SELECT id FROM b ORDER BY name":    "42703",
+		"SELECT id FROM a UNION SELECT id FROM b ORDER BY a.id":    "0A000",
+		"SELECT id FROM a UNION SELECT id FROM b ORDER BY id + 1":  "0A000",
+		"SELECT id FROM a UNION SELECT id FROM b LIMIT -1":         "2201W",
+		"SELECT id FROM a ORDER BY id UNION SELECT id FROM b":      "42601",
+		"SELECT id FROM a UNION SELECT id FROM missing ORDER BY 1": "42P01",
+	} {
+		_, err := e.Execute(sql)
+		assertSQLSTATE(t, err, code)
+	}
+}
+
+func TestSetOp_ViewsAndParams(t *testing.T) {
+	e := setupSetOp(t)
+
+	exec(t, e, "CREATE VIEW everyone AS SELECT name FROM a UNION SELECT name FROM b")
+	r := exec(t, e, "SELECT name FROM everyone ORDER BY name")
+	if got, want := ids(r), []string{"drei", "four", "none", "one", "three", "two"}; !slices.Equal(got, want) {
+		t.Errorf("view = %q, want %q", got, want)
+	}
+
+	r, err := e.ExecuteParams("SELECT name FROM a WHERE id = $1 UNION SELECT name FROM b WHERE id = $2 ORDER BY 1", []any{int64(1), int64(4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(r), []string{"four", "one"}; !slices.Equal(got, want) {
+		t.Errorf("params = %q, want %q", got, want)
+	}
+	oids, err := e.Prepare("SELECT name FROM a WHERE id = $1 UNION SELECT name FROM b WHERE name = $2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(oids, []int32{OIDInt8, OIDText}) {
+		t.Errorf("param OIDs = %v, want INT8, TEXT", oids)
+	}
+	cols, err := e.DescribeColumns("SELECT id, name FROM a EXCEPT SELECT 2.5, name FROM b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 2 || cols[0].Name != "id" || cols[0].TypeOID != OIDFloat8 {
+		t.Errorf("columns = %+v, want id FLOAT8 and name", cols)
+	}
+
+	c, err := e.OpenCursor("SELECT id FROM a INTERSECT SELECT id FROM b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := c.Fetch(10)
+	if len(rows) != 3 {
+		t.Errorf("cursor rows = %q, want 3", rows)
+	}
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"time"
+
+	"mulldb/storage"
+)
+
+// sessionSettings holds the parameters of one session. Like the RANDOM()
+// generator it is shared with the executors derived from the session, so
+// a transaction runs under the settings of its connection.
+type sessionSettings struct {
+	statementTimeout time.Duration // 0 for none
+	scanBatchSize    int           // rows per scan batch; 0 for defaultScanBatchSize
+	seqScanNotice    int64         // table size from which unindexed scans get a notice; 0 for never
+	copyProgress     int64         // rows between COPY FROM progress reports; 0 for none
+}
+
+// defaultScanBatchSize is how many rows scans hand to the executor's loops
+// at a time unless the session sets otherwise.
+const defaultScanBatchSize = 256
+
+// SetStatementTimeout limits how long each statement of the session may
+// run before it fails with SQLSTATE 57014; zero removes the limit. A
+// timeout hint on a statement takes precedence. The server sets it from
+// the configured default when a session starts.
+func (e *Executor) SetStatementTimeout(d time.Duration) {
+	e.settings.statementTimeout = d
+}
+
+// SetScanBatchSize sets how many rows the scans of the session fetch per
+// call to the storage iterator; zero or less restores the default. Larger
+// batches save call overhead on long scans, while a statement timeout or
+// cancellation is noticed between batches.
+func (e *Executor) SetScanBatchSize(n int) {
+	e.settings.scanBatchSize = max(n, 0)
+}
+
+// SetSeqScanNotice makes statements of the session that scan a table of
+// at least minRows rows, while filtering on a column without an index,
+// return a notice saying so; zero or less turns the notices off.
+func (e *Executor) SetSeqScanNotice(minRows int64) {
+	e.settings.seqScanNotice = max(minRows, 0)
+}
+
+// SetCopyProgress makes COPY ... FROM STDIN report to its progress
+// function each time another rows rows are loaded; zero or less turns
+// the reports off.
+func (e *Executor) SetCopyProgress(rows int64) {
+	e.settings.copyProgress = max(rows, 0)
+}
+
+// nextBatch reads the next batch of rows from a scan.
+func (e *Executor) nextBatch(it storage.RowIterator) []storage.Row {
+	n := e.settings.scanBatchSize
+	if n == 0 {
+		n = defaultScanBatchSize
+	}
+	return storage.NextBatch(it, n)
+}
@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"mulldb/storage"
+)
+
+func TestExecutor_ScanBatchSize(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (n INTEGER, g INTEGER)")
+	exec(t, e, "CREATE TABLE u (n INTEGER)")
+	for i := 1; i <= 1000; i++ {
+		if i%100 == 0 {
+			exec(t, e, fmt.Sprintf("INSERT INTO u VALUES (%d)", i))
+		}
+	}
+	vals := make([][]any, 1000)
+	for i := range vals {
+		vals[i] = []any{int64(i + 1), int64(i % 7)}
+	}
+	if _, err := e.Engine().Insert("t", nil, vals); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []string{
+		"SELECT n FROM t WHERE n % 3 = 0 LIMIT 50 OFFSET 5",
+		"SELECT n FROM t WHERE n > 990",
+		"SELECT n FROM t WHERE n < 600 ORDER BY n DESC LIMIT 3",
+		"SELECT COUNT(*), SUM(n) FROM t WHERE g = 2",
+		"SELECT g, COUNT(*) FROM t GROUP BY g ORDER BY g",
+		"SELECT t.n FROM t JOIN u ON t.n = u.n",
+		"SELECT COUNT(*) FROM information_schema.columns",
+	}
+	results := func(e *Executor) [][]string {
+		var out [][]string
+		for _, sql := range queries {
+			var rows []string
+			for _, r := range exec(t, e, sql).Rows {
+				rows = append(rows, fmt.Sprintf("%s", r))
+			}
+			out = append(out, rows)
+		}
+		return out
+	}
+
+	want := results(e)
+	for _, size := range []int{1, 7, 1000, 5000} {
+		s := e.NewSession()
+		s.SetScanBatchSize(size)
+		// Through WithContext the scans are also checked for cancellation.
+		for _, x := range []*Executor{s, s.WithContext(context.Background())} {
+			got := results(x)
+			for i := range queries {
+				if !slices.Equal(got[i], want[i]) {
+					t.Errorf("batch size %d: %s = %v, want %v", size, queries[i], got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkScan compares reading a large table row by row, a batch size
+// of 1, with batched scans, through a statement context as the server
+// runs them.
+func BenchmarkScan(b *testing.B) {
+	eng, err := storage.Open(b.TempDir(), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer eng.Close()
+	e := New(eng)
+	if _, err := e.Execute("CREATE TABLE big (n INTEGER, g INTEGER)"); err != nil {
+		b.Fatal(err)
+	}
+	const rowCount = 500_000
+	vals := make([][]any, rowCount)
+	for i := range vals {
+		vals[i] = []any{int64(i), int64(i % 10)}
+	}
+	if _, err := eng.Insert("big", nil, vals); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, size := range []int{1, 64, 256, 4096} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			s := e.NewSession()
+			s.SetScanBatchSize(size)
+			s = s.WithContext(context.Background())
+			for range b.N {
+				if _, err := s.Execute("SELECT COUNT(*) FROM big WHERE g = 3"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
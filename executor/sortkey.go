@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// sortKey is an ORDER BY key resolved against the rows being sorted: the
+// position of its value in each row, its direction, where NULLs go, and
+// how two non-NULL values are compared.
+type sortKey struct {
+	colIdx     int
+	desc       bool
+	nullsFirst bool
+	kind       sortKind
+}
+
+// sortKind selects the comparison of a sort key. Keys on INTEGER, FLOAT,
+// TEXT and TIMESTAMP columns compare their values directly, so the hot
+// path of a sort does not go through the type switch of
+// storage.CompareValues for every pair of values.
+type sortKind uint8
+
+const (
+	sortAny sortKind = iota // storage.CompareValues
+	sortInt
+	sortFloat
+	sortText
+	sortTime
+)
+
+// nullsFirst reports whether ob sorts NULLs before other values: as NULLS
+// FIRST or NULLS LAST says, or else, as in PostgreSQL, only when it is
+// descending, since NULL sorts as larger than any value.
+func nullsFirst(ob parser.OrderByClause) bool {
+	if ob.NullsFirst != nil {
+		return *ob.NullsFirst
+	}
+	return ob.Desc
+}
+
+// orderPosition returns the index of the select-list column that the
+// ORDER BY position of ob refers to, among n columns.
+func orderPosition(ob parser.OrderByClause, n int) (int, error) {
+	if ob.Position > n {
+		return 0, &QueryError{Code: "42P10", Message: fmt.Sprintf("ORDER BY position %d is not in select list", ob.Position)}
+	}
+	return ob.Position - 1, nil
+}
+
+// compareNulls orders two values of a key of which at least one is NULL.
+func compareNulls(a, b any, nullsFirst bool) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case (a == nil) == nullsFirst:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// columnSortKey returns a sort key on a column of type dt.
+func columnSortKey(colIdx int, desc, nullsFirst bool, dt storage.DataType) sortKey {
+	kind := sortAny
+	switch dt {
+	case storage.TypeInteger:
+		kind = sortInt
+	case storage.TypeFloat:
+		kind = sortFloat
+	case storage.TypeText:
+		kind = sortText
+	case storage.TypeTimestamp:
+		kind = sortTime
+	}
+	return sortKey{colIdx: colIdx, desc: desc, nullsFirst: nullsFirst, kind: kind}
+}
+
+// valueSortKey returns a sort key on values whose type is not known up
+// front, such as ORDER BY expressions.
+func valueSortKey(colIdx int, desc, nullsFirst bool) sortKey {
+	return sortKey{colIdx: colIdx, desc: desc, nullsFirst: nullsFirst, kind: sortAny}
+}
+
+// compare orders two non-NULL values of the key. Stored values always have
+// their column's Go type; any other pair falls back to
+// storage.CompareValues, which returns -2 for incomparable values.
+func (k *sortKey) compare(a, b any) int {
+	switch k.kind {
+	case sortInt:
+		if x, ok := a.(int64); ok {
+			if y, ok := b.(int64); ok {
+				return cmp.Compare(x, y)
+			}
+		}
+	case sortFloat:
+		if x, ok := a.(float64); ok {
+			if y, ok := b.(float64); ok {
+				switch {
+				case x < y:
+					return -1
+				case x > y:
+					return 1
+				default:
+					return 0
+				}
+			}
+		}
+	case sortText:
+		if x, ok := a.(string); ok {
+			if y, ok := b.(string); ok {
+				return strings.Compare(x, y)
+			}
+		}
+	case sortTime:
+		if x, ok := a.(time.Time); ok {
+			if y, ok := b.(time.Time); ok {
+				return x.Compare(y)
+			}
+		}
+	}
+	return storage.CompareValues(a, b)
+}
+
+// sortRows sorts rows by keys, keeping the order of rows with equal keys.
+// NULLs sort first or last as each key says, and incomparable values as
+// equal. Once stopped reports true, every comparison reports equal so that
+// an interrupted sort ends quickly.
+func sortRows(rows []storage.Row, keys []sortKey, stopped func() bool) {
+	slices.SortStableFunc(rows, func(x, y storage.Row) int {
+		if stopped() {
+			return 0
+		}
+		for k := range keys {
+			key := &keys[k]
+			av := storage.RowValue(x.Values, key.colIdx)
+			bv := storage.RowValue(y.Values, key.colIdx)
+			if av == nil || bv == nil {
+				if c := compareNulls(av, bv, key.nullsFirst); c != 0 {
+					return c
+				}
+				continue
+			}
+			c := key.compare(av, bv)
+			if c == 0 || c == -2 {
+				continue
+			}
+			if key.desc {
+				return -c
+			}
+			return c
+		}
+		return 0
+	})
+}
@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"mulldb/storage"
+)
+
+func TestExecutor_OrderByTypedKeys(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE t (id INTEGER, name TEXT, score FLOAT, at TIMESTAMP)")
+	exec(t, e, `INSERT INTO t VALUES
+		(1, 'b', 2.5, '2024-01-02 00:00:00'),
+		(2, 'a', NULL, '2024-01-01 00:00:00'),
+		(3, NULL, 2.5, NULL),
+		(4, 'b', 1.5, '2024-01-03 00:00:00'),
+		(5, 'a', 3.5, '2024-01-01 00:00:00'),
+		(6, NULL, NULL, '2024-01-02 00:00:00')`)
+
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id FROM t ORDER BY name, id DESC", []string{"5", "2", "4", "1", "6", "3"}},
+		{"SELECT id FROM t ORDER BY name DESC, score", []string{"3", "6", "4", "1", "5", "2"}},
+		{"SELECT id FROM t ORDER BY score DESC, id", []string{"2", "6", "5", "1", "3", "4"}},
+		{"SELECT id FROM t ORDER BY at, name DESC", []string{"2", "5", "6", "1", "4", "3"}},
+		{"SELECT id FROM t WHERE id > 1 ORDER BY score, at DESC", []string{"4", "3", "5", "6", "2"}},
+	}
+	for _, tt := range tests {
+		r := exec(t, e, tt.sql)
+		var got []string
+		for _, row := range r.Rows {
+			got = append(got, string(row[0]))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSortRows_MatchesCompareValues(t *testing.T) {
+	rows := sortBenchRows(2000)
+	typed := slices.Clone(rows)
+	generic := slices.Clone(rows)
+	never := func() bool { return false }
+	sortRows(typed, []sortKey{
+		columnSortKey(0, false, false, storage.TypeText),
+		columnSortKey(1, true, true, storage.TypeInteger),
+	}, never)
+	sortRows(generic, []sortKey{
+		valueSortKey(0, false, false),
+		valueSortKey(1, true, true),
+	}, never)
+	for i := range typed {
+		if typed[i].ID != generic[i].ID {
+			t.Fatalf("row %d: typed sort has id %d, generic sort has id %d", i, typed[i].ID, generic[i].ID)
+		}
+	}
+}
+
+func TestSortKey_MixedValues(t *testing.T) {
+	// A value of another type than the column's still compares like
+	// storage.CompareValues does.
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		dt   storage.DataType
+		a, b any
+	}{
+		{storage.TypeInteger, int64(2), 1.5},
+		{storage.TypeFloat, 1.5, int64(2)},
+		{storage.TypeText, "2024-01-02", ts},
+		{storage.TypeTimestamp, ts, "2023-12-31"},
+	}
+	for _, tt := range tests {
+		key := columnSortKey(0, false, false, tt.dt)
+		if got, want := key.compare(tt.a, tt.b), storage.CompareValues(tt.a, tt.b); got != want {
+			t.Errorf("%s: compare(%v, %v) = %d, want %d", tt.dt, tt.a, tt.b, got, want)
+		}
+	}
+}
+
+// sortBenchRows returns n rows of (TEXT, INTEGER) with repeated names, some
+// NULLs, and shuffled order.
+func sortBenchRows(n int) []storage.Row {
+	r := rand.New(rand.NewPCG(1, 2))
+	rows := make([]storage.Row, n)
+	for i := range rows {
+		var name any = fmt.Sprintf("name%04d", r.IntN(1000))
+		if i%50 == 0 {
+			name = nil
+		}
+		rows[i] = storage.Row{ID: int64(i), Values: []any{name, int64(r.IntN(n))}}
+	}
+	return rows
+}
+
+// BenchmarkOrderBy sorts 500k rows by two keys, comparing the comparisons
+// chosen for the column types with storage.CompareValues on every pair.
+func BenchmarkOrderBy(b *testing.B) {
+	rows := sortBenchRows(500_000)
+	never := func() bool { return false }
+	for _, bm := range []struct {
+		name string
+		keys []sortKey
+	}{
+		{"typed", []sortKey{
+			columnSortKey(0, false, false, storage.TypeText),
+			columnSortKey(1, true, true, storage.TypeInteger),
+		}},
+		{"generic", []sortKey{
+			valueSortKey(0, false, false),
+			valueSortKey(1, true, true),
+		}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			work := make([]storage.Row, len(rows))
+			for range b.N {
+				copy(work, rows)
+				sortRows(work, bm.keys, never)
+			}
+		})
+	}
+}
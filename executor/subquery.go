@@ -0,0 +1,339 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// subqueryRuns holds the first error a correlated subquery failed with
+// while its statement ran. Such a subquery runs during the evaluation of
+// an expression, which cannot return an error, so the statement checks
+// for one once it is done.
+type subqueryRuns struct {
+	err error
+}
+
+// failure returns the error a subquery failed with, or else err.
+func (r *subqueryRuns) failure(err error) error {
+	if r.err != nil {
+		return r.err
+	}
+	return err
+}
+
+// bindSubqueries prepares the subqueries of stmt to run on e. In each one,
+// the columns that belong to the enclosing query rather than to the
+// subquery's own tables become OuterRefs, so that the subquery can run
+// again for every outer row. Only a SELECT may have such correlated
+// subqueries: UPDATE and DELETE evaluate their WHERE clause under the
+// table's write lock, where a subquery could not read.
+func (e *Executor) bindSubqueries(stmt parser.Statement) (*subqueryRuns, error) {
+	b := &subqueryBinder{e: e, runs: &subqueryRuns{}}
+	switch s := stmt.(type) {
+	case *parser.SelectStmt:
+		b.bindSelect(s)
+	case *parser.SetOpStmt:
+		b.bindSetOp(s)
+	case *parser.ExplainStmt:
+		return e.bindSubqueries(s.Stmt)
+	case *parser.CopyStmt:
+		return e.bindSubqueries(s.Query)
+	case *parser.UpdateStmt:
+		b.bindStatement(s, b.scope(s.Table, ""))
+	case *parser.DeleteStmt:
+		b.bindStatement(s, b.scope(s.Table, ""))
+	case *parser.InsertStmt:
+		b.bindStatement(s, b.scope(s.Table, ""))
+	case *parser.ValuesStmt:
+		b.bindStatement(s, nil)
+	}
+	return b.runs, b.err
+}
+
+// subqueryBinder binds the subqueries of one statement.
+type subqueryBinder struct {
+	e    *Executor
+	runs *subqueryRuns
+	err  error
+}
+
+// bindSelect binds the subqueries in s, with s's tables as their outer
+// scope.
+func (b *subqueryBinder) bindSelect(s *parser.SelectStmt) {
+	scope := b.scope(s.From, s.FromAlias)
+	for _, j := range s.Joins {
+		scope = append(scope, b.scope(j.Table, j.Alias)...)
+	}
+	bindSelect(s, func(expr parser.Expr) parser.Expr {
+		if sq, ok := expr.(*parser.SubqueryExpr); ok {
+			b.bindSubquery(sq, scope)
+			return sq
+		}
+		return nil
+	})
+}
+
+// bindSetOp binds the subqueries of each query of s, with that query's
+// tables as their outer scope.
+func (b *subqueryBinder) bindSetOp(s *parser.SetOpStmt) {
+	for _, q := range []parser.Statement{s.Left, s.Right} {
+		switch q := q.(type) {
+		case *parser.SelectStmt:
+			b.bindSelect(q)
+		case *parser.SetOpStmt:
+			b.bindSetOp(q)
+		}
+	}
+}
+
+// bindStatement binds the subqueries of an INSERT, UPDATE, DELETE or
+// VALUES statement, which must not refer to scope.
+func (b *subqueryBinder) bindStatement(stmt parser.Statement, scope []paramTable) {
+	bindStatementExprs(stmt, func(expr parser.Expr) parser.Expr {
+		sq, ok := expr.(*parser.SubqueryExpr)
+		if !ok {
+			return nil
+		}
+		b.bindSubquery(sq, scope)
+		if len(sq.Outer) > 0 && b.err == nil {
+			b.err = &QueryError{Code: "0A000", Message: "correlated subqueries are only supported in SELECT"}
+		}
+		return sq
+	})
+}
+
+// scope returns the table ref, read under alias, as a one-table scope, or
+// nil if there is no such table.
+func (b *subqueryBinder) scope(ref parser.TableRef, alias string) []paramTable {
+	if ref.IsEmpty() {
+		return nil
+	}
+	def, ok := getCatalogTable(ref.Schema, ref.Name)
+	if !ok {
+		if def, ok = b.e.engine.GetTable(ref.Name); !ok {
+			return nil
+		}
+	}
+	return []paramTable{{alias: alias, def: def}}
+}
+
+// bindSubquery turns the columns of sq's query that only outer has into
+// OuterRefs, binds the subqueries nested in it, and sets its Run.
+func (b *subqueryBinder) bindSubquery(sq *parser.SubqueryExpr, outer []paramTable) {
+	q := sq.Query
+	inner := b.scope(q.From, q.FromAlias)
+	for _, j := range q.Joins {
+		inner = append(inner, b.scope(j.Table, j.Alias)...)
+	}
+	bindSelect(q, func(expr parser.Expr) parser.Expr {
+		switch x := expr.(type) {
+		case *parser.SubqueryExpr:
+			return x // bound below, against q's own tables
+		case *parser.ColumnRef:
+			if !scopeHasColumn(inner, x.Table, x.Name) && scopeHasColumn(outer, x.Table, x.Name) {
+				ref := &parser.OuterRef{Table: x.Table, Column: x.Name}
+				sq.Outer = append(sq.Outer, ref)
+				return ref
+			}
+		}
+		return nil
+	})
+	b.bindSelect(q)
+
+	e, runs := b.e, b.runs
+	sq.Run = func(maxRows int) ([]any, error) {
+		vals, err := e.runSubquery(q, maxRows)
+		if err != nil && runs.err == nil {
+			runs.err = err
+		}
+		return vals, err
+	}
+}
+
+// scopeHasColumn reports whether a table of scope has the column name,
+// qualified by table unless table is empty. A table with an alias is only
+// known by its alias.
+func scopeHasColumn(scope []paramTable, table, name string) bool {
+	for _, t := range scope {
+		if table != "" {
+			known := t.alias
+			if known == "" {
+				known = t.def.Name
+			}
+			if !strings.EqualFold(table, known) {
+				continue
+			}
+		}
+		if columnIndex(t.def, name) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// runSubquery runs the query of a subquery and returns the values of its
+// single column. More than maxRows rows is an error.
+func (e *Executor) runSubquery(q *parser.SelectStmt, maxRows int) ([]any, error) {
+	res, err := e.execSelect(q, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Columns) != 1 {
+		return nil, &QueryError{Code: "42601", Message: "subquery must return only one column"}
+	}
+	if len(res.Rows) > maxRows {
+		return nil, &QueryError{Code: "21000", Message: "more than one row returned by a subquery used as an expression"}
+	}
+	vals := make([]any, len(res.Rows))
+	for i, r := range res.Rows {
+		if r[0] == nil {
+			continue
+		}
+		v, err := parseTextValue(string(r[0]), res.Columns[0].TypeOID)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// subqueryColumn describes the column sq returns by running its query for
+// no rows, as DescribeColumns does for a statement. A correlated subquery
+// is described with NULL outer values.
+func (e *Executor) subqueryColumn(sq *parser.SubqueryExpr) (Column, error) {
+	q := *sq.Query
+	zero := int64(0)
+	q.Limit, q.LimitExpr = &zero, nil
+	q.Offset, q.OffsetExpr = nil, nil
+	res, err := e.execSelect(&q, nil)
+	if err != nil {
+		return Column{}, err
+	}
+	if len(res.Columns) != 1 {
+		return Column{}, &QueryError{Code: "42601", Message: "subquery must return only one column"}
+	}
+	return res.Columns[0], nil
+}
+
+// compileScalarSubquery compiles a subquery used as a value. It yields
+// the single value the subquery returns, or NULL if it returns no row.
+func compileScalarSubquery(sq *parser.SubqueryExpr, resolve func(*parser.ColumnRef) (exprFunc, error)) (exprFunc, error) {
+	run, err := compileSubqueryRuns(sq, 1, resolve)
+	if err != nil {
+		return nil, err
+	}
+	return func(r storage.Row) any {
+		if vals := run(r); len(vals) == 1 {
+			return vals[0]
+		}
+		return nil
+	}, nil
+}
+
+// compileInSubquery compiles [NOT] IN (SELECT ...). The tested value is
+// compared with every value the subquery returns, with the three-valued
+// logic of a value list: no match is NULL rather than false if the
+// subquery returned a NULL. Nothing is in an empty result, not even NULL.
+// compile compiles the tested value and the outer columns of a correlated
+// subquery.
+func compileInSubquery(e *parser.InExpr, compile func(parser.Expr) (exprFunc, error)) (exprFunc, error) {
+	lhsFn, err := compile(e.Expr)
+	if err != nil {
+		return nil, err
+	}
+	run, err := compileSubqueryRuns(e.Subquery, math.MaxInt, func(ref *parser.ColumnRef) (exprFunc, error) {
+		return compile(ref)
+	})
+	if err != nil {
+		return nil, err
+	}
+	not := e.Not
+	return func(r storage.Row) any {
+		vals := run(r)
+		if len(vals) == 0 {
+			return not
+		}
+		lhs := lhsFn(r)
+		if lhs == nil {
+			return nil
+		}
+		hasNull := false
+		for _, v := range vals {
+			if v == nil {
+				hasNull = true
+				continue
+			}
+			if storage.CompareValues(lhs, v) == 0 {
+				return !not
+			}
+		}
+		if hasNull {
+			return nil
+		}
+		return not
+	}, nil
+}
+
+// compileSubqueryRuns returns a function that yields the values sq returns
+// for an outer row, at most maxRows of them, or nil if it failed. An
+// uncorrelated subquery runs once, here. A correlated one runs for each
+// outer row whose values of the referenced columns have not been seen
+// before; resolve compiles those columns against the outer row.
+func compileSubqueryRuns(sq *parser.SubqueryExpr, maxRows int, resolve func(*parser.ColumnRef) (exprFunc, error)) (func(storage.Row) []any, error) {
+	if sq.Run == nil {
+		return nil, &QueryError{Code: "0A000", Message: "subqueries are not supported here"}
+	}
+	if len(sq.Outer) == 0 {
+		vals, err := sq.Run(maxRows)
+		if err != nil {
+			return nil, err
+		}
+		return func(storage.Row) []any { return vals }, nil
+	}
+
+	outer := make([]exprFunc, len(sq.Outer))
+	for i, ref := range sq.Outer {
+		eval, err := resolve(&parser.ColumnRef{Table: ref.Table, Name: ref.Column})
+		if err != nil {
+			return nil, err
+		}
+		outer[i] = eval
+	}
+	cache := make(map[string][]any)
+	var key strings.Builder
+	return func(r storage.Row) []any {
+		key.Reset()
+		for i, eval := range outer {
+			v := eval(r)
+			sq.Outer[i].Value = v
+			fmt.Fprintf(&key, "%T:%v\x00", v, v)
+		}
+		if vals, ok := cache[key.String()]; ok {
+			return vals
+		}
+		vals, err := sq.Run(maxRows)
+		if err != nil {
+			return nil
+		}
+		cache[key.String()] = vals
+		return vals
+	}, nil
+}
+
+// hasSubquery reports whether s contains a subquery.
+func hasSubquery(s *parser.SelectStmt) bool {
+	found := false
+	bindSelect(s, func(expr parser.Expr) parser.Expr {
+		if _, ok := expr.(*parser.SubqueryExpr); ok {
+			found = true
+			return expr
+		}
+		return nil
+	})
+	return found
+}
@@ -0,0 +1,196 @@
+package executor
+
+import (
+	"slices"
+	"testing"
+)
+
+func setupProducts(t *testing.T) *Executor {
+	t.Helper()
+	e := setup(t)
+	exec(t, e, "CREATE TABLE products (id INTEGER PRIMARY KEY, category_id INTEGER, price INTEGER)")
+	exec(t, e, `INSERT INTO products VALUES
+		(1, 1, 10), (2, 1, 20), (3, 1, 30),
+		(4, 2, 100), (5, 2, 300),
+		(6, 3, 7),
+		(7, NULL, 50)`)
+	return e
+}
+
+// ids returns the first column of r.
+func ids(r *Result) []string {
+	var out []string
+	for _, row := range r.Rows {
+		out = append(out, string(row[0]))
+	}
+	return out
+}
+
+func TestSubquery_CorrelatedAverage(t *testing.T) {
+	e := setupProducts(t)
+
+	// Products priced above the average of their own category. The
+	// product without a category compares with NULL and is left out.
+	r := exec(t, e, `SELECT id FROM products
+		WHERE price > (SELECT AVG(price) FROM products p2 WHERE p2.category_id = products.category_id)
+		ORDER BY id`)
+	if got, want := ids(r), []string{"3", "5"}; !slices.Equal(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+
+	// An alias on the outer table is used the same way, and unqualified
+	// columns resolve to the subquery's own table first.
+	r = exec(t, e, `SELECT p.id FROM products p
+		WHERE p.price = (SELECT MAX(price) FROM products WHERE category_id = p.category_id)
+		ORDER BY p.id`)
+	if got, want := ids(r), []string{"3", "5", "6"}; !slices.Equal(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+}
+
+func TestSubquery_Uncorrelated(t *testing.T) {
+	e := setupProducts(t)
+
+	r := exec(t, e, "SELECT id FROM products WHERE price = (SELECT MAX(price) FROM products)")
+	if got, want := ids(r), []string{"5"}; !slices.Equal(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+	// No row is NULL.
+	r = exec(t, e, "SELECT COUNT(*) FROM products WHERE price > (SELECT price FROM products WHERE id = 99)")
+	if string(r.Rows[0][0]) != "0" {
+		t.Errorf("COUNT(*) = %s, want 0", r.Rows[0][0])
+	}
+	// UPDATE and DELETE run it before touching the table.
+	exec(t, e, "DELETE FROM products WHERE price < (SELECT AVG(price) FROM products)")
+	r = exec(t, e, "SELECT COUNT(*) FROM products")
+	if string(r.Rows[0][0]) != "2" {
+		t.Errorf("rows left = %s, want 2", r.Rows[0][0])
+	}
+}
+
+func TestSubquery_Errors(t *testing.T) {
+	e := setupProducts(t)
+
+	_, err := e.Execute("SELECT id FROM products WHERE price = (SELECT price FROM products)")
+	assertSQLSTATE(t, err, "21000")
+	_, err = e.Execute("SELECT id FROM products WHERE price = (SELECT id, price FROM products WHERE id = 1)")
+	assertSQLSTATE(t, err, "42601")
+	// A correlated subquery that fails for some row fails the statement.
+	_, err = e.Execute(`SELECT id FROM products
+		WHERE price = (SELECT price FROM products p2 WHERE p2.category_id = products.category_id)`)
+	assertSQLSTATE(t, err, "21000")
+	_, err = e.Execute(`DELETE FROM products
+		WHERE price > (SELECT AVG(price) FROM products p2 WHERE p2.category_id = products.category_id)`)
+	assertSQLSTATE(t, err, "0A000")
+}
+
+func TestSubquery_SelectList(t *testing.T) {
+	e := setupProducts(t)
+	exec(t, e, "CREATE TABLE categories (id INTEGER PRIMARY KEY, name TEXT)")
+	exec(t, e, "INSERT INTO categories VALUES (1, 'tools'), (2, 'toys'), (4, 'empty')")
+
+	// A correlated count per outer row, named and typed after the
+	// subquery's column.
+	r := exec(t, e, `SELECT name, (SELECT COUNT(*) FROM products WHERE products.category_id = categories.id)
+		FROM categories ORDER BY id`)
+	if r.Columns[1].Name != "count" || r.Columns[1].TypeOID != OIDInt8 {
+		t.Errorf("column = %+v, want count INT8", r.Columns[1])
+	}
+	var got []string
+	for _, row := range r.Rows {
+		got = append(got, string(row[0])+"="+string(row[1]))
+	}
+	if want := []string{"tools=3", "toys=2", "empty=0"}; !slices.Equal(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+
+	// No row is NULL; an alias names the column.
+	r = exec(t, e, `SELECT c.id, (SELECT MAX(price) FROM products p WHERE p.category_id = c.id) AS top
+		FROM categories c WHERE c.id = 4`)
+	if r.Columns[1].Name != "top" || r.Rows[0][1] != nil {
+		t.Errorf("column %q = %q, want top = NULL", r.Columns[1].Name, r.Rows[0][1])
+	}
+
+	// The select list of a join, and without FROM.
+	r = exec(t, e, `SELECT c.name, p.id, (SELECT name FROM categories WHERE id = p.category_id + 1) AS next
+		FROM categories c JOIN products p ON p.category_id = c.id WHERE p.id = 3`)
+	if len(r.Rows) != 1 || string(r.Rows[0][2]) != "toys" || r.Columns[2].TypeOID != OIDText {
+		t.Errorf("join rows = %q, columns = %+v, want next = toys (TEXT)", r.Rows, r.Columns)
+	}
+	r = exec(t, e, "SELECT (SELECT name FROM categories WHERE id = 2), (SELECT COUNT(*) FROM products) + 1")
+	if string(r.Rows[0][0]) != "toys" || r.Columns[0].Name != "name" || string(r.Rows[0][1]) != "8" {
+		t.Errorf("static = %q %+v, want toys and 8", r.Rows[0], r.Columns)
+	}
+
+	_, err := e.Execute("SELECT id, (SELECT id FROM products p WHERE p.category_id = categories.id) FROM categories")
+	assertSQLSTATE(t, err, "21000")
+}
+
+func TestSubquery_In(t *testing.T) {
+	e := setupProducts(t)
+	exec(t, e, "CREATE TABLE categories (id INTEGER PRIMARY KEY, name TEXT, active BOOLEAN)")
+	exec(t, e, "INSERT INTO categories VALUES (1, 'tools', true), (2, 'toys', false), (3, 'food', true)")
+
+	r := exec(t, e, "SELECT id FROM products WHERE category_id IN (SELECT id FROM categories WHERE active) ORDER BY id")
+	if got, want := ids(r), []string{"1", "2", "3", "6"}; !slices.Equal(got, want) {
+		t.Errorf("IN ids = %v, want %v", got, want)
+	}
+	// The product without a category is neither in nor not in the set.
+	r = exec(t, e, "SELECT id FROM products WHERE category_id NOT IN (SELECT id FROM categories WHERE active) ORDER BY id")
+	if got, want := ids(r), []string{"4", "5"}; !slices.Equal(got, want) {
+		t.Errorf("NOT IN ids = %v, want %v", got, want)
+	}
+	// Nothing is in an empty set, and everything, NULL too, is not in it.
+	r = exec(t, e, "SELECT COUNT(*) FROM products WHERE category_id NOT IN (SELECT id FROM categories WHERE id > 10)")
+	if string(r.Rows[0][0]) != "7" {
+		t.Errorf("NOT IN empty set = %s rows, want 7", r.Rows[0][0])
+	}
+	// A NULL in the set turns every miss into NULL, so NOT IN matches nothing.
+	r = exec(t, e, "SELECT COUNT(*) FROM categories WHERE id NOT IN (SELECT category_id FROM products)")
+	if string(r.Rows[0][0]) != "0" {
+		t.Errorf("NOT IN set with NULL = %s rows, want 0", r.Rows[0][0])
+	}
+	r = exec(t, e, "SELECT id, id IN (SELECT category_id FROM products WHERE price > 20) FROM categories ORDER BY id")
+	var got []string
+	for _, row := range r.Rows {
+		got = append(got, string(row[0])+"="+string(row[1]))
+	}
+	if want := []string{"1=t", "2=t", "3="}; !slices.Equal(got, want) {
+		t.Errorf("IN in select list = %v, want %v", got, want)
+	}
+
+	// Correlated: products that are the cheapest two of their category.
+	r = exec(t, e, `SELECT id FROM products p WHERE p.id IN
+		(SELECT id FROM products WHERE category_id = p.category_id ORDER BY price LIMIT 2)
+		ORDER BY id`)
+	if got, want := ids(r), []string{"1", "2", "4", "5", "6"}; !slices.Equal(got, want) {
+		t.Errorf("correlated IN ids = %v, want %v", got, want)
+	}
+
+	r = exec(t, e, `SELECT p.id FROM products p JOIN categories c ON c.id = p.category_id
+		WHERE p.price IN (SELECT MAX(price) FROM products GROUP BY category_id) AND c.active`)
+	if got, want := ids(r), []string{"3", "6"}; !slices.Equal(got, want) {
+		t.Errorf("join IN ids = %v, want %v", got, want)
+	}
+
+	// An uncorrelated subquery runs once for the whole query: run per row,
+	// a random sample would match a varying number of rows.
+	for range 5 {
+		r = exec(t, e, "SELECT COUNT(*) FROM products WHERE id IN (SELECT id FROM products ORDER BY random() LIMIT 3)")
+		if string(r.Rows[0][0]) != "3" {
+			t.Fatalf("IN random sample of 3 matched %s rows", r.Rows[0][0])
+		}
+	}
+
+	// UPDATE and DELETE run an uncorrelated one first.
+	exec(t, e, "DELETE FROM products WHERE category_id IN (SELECT id FROM categories WHERE NOT active)")
+	r = exec(t, e, "SELECT COUNT(*) FROM products")
+	if string(r.Rows[0][0]) != "5" {
+		t.Errorf("rows left = %s, want 5", r.Rows[0][0])
+	}
+
+	_, err := e.Execute("SELECT id FROM products WHERE id IN (SELECT id, name FROM categories)")
+	assertSQLSTATE(t, err, "42601")
+	_, err = e.Execute("DELETE FROM products WHERE id IN (SELECT id FROM categories WHERE categories.id = products.category_id)")
+	assertSQLSTATE(t, err, "0A000")
+}
@@ -17,10 +17,24 @@ type Trace struct {
 	RowsScanned  int64
 	RowsReturned int64
 	IndexName    string // non-empty when an index was used (e.g. "PRIMARY", "idx_email")
+	Access       string // how a single-table SELECT read its table: "index scan" or "seq scan"
 	Table        string
 	StmtType     string // "SELECT", "INSERT", etc.
 }
 
+// setAccess records that a single-table SELECT read its table through
+// index, or by a sequential scan if index is "".
+func (tr *Trace) setAccess(index string) {
+	if tr == nil {
+		return
+	}
+	tr.IndexName = index
+	tr.Access = "seq scan"
+	if index != "" {
+		tr.Access = "index scan"
+	}
+}
+
 // TraceToResult formats a Trace as a result set with columns "step" and "duration".
 func TraceToResult(tr *Trace) *Result {
 	if tr == nil {
@@ -66,6 +80,9 @@ func TraceToResult(tr *Trace) *Result {
 	rows = append(rows, [][]byte{[]byte("Rows Scanned"), []byte(fmt.Sprintf("%d", tr.RowsScanned))})
 	rows = append(rows, [][]byte{[]byte("Rows Returned"), []byte(fmt.Sprintf("%d", tr.RowsReturned))})
 
+	if tr.Access != "" {
+		rows = append(rows, [][]byte{[]byte("Access Method"), []byte(tr.Access)})
+	}
 	if tr.IndexName != "" {
 		rows = append(rows, [][]byte{[]byte("Used Index"), []byte(tr.IndexName)})
 	}
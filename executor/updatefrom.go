@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// execUpdateFrom runs UPDATE ... FROM (VALUES ...) AS v. Each row of the
+// list in turn updates the target rows the WHERE clause pairs it with,
+// setting them from that row's values: the columns of v become OuterRefs
+// in SET and WHERE, given the row's values before its update runs. As in
+// PostgreSQL, a target row paired with several rows of the list is
+// updated only once, here from the first; the pairing is decided on the
+// values rows had before the statement. The updates run as one atomic
+// step.
+func (e *Executor) execUpdateFrom(s *parser.UpdateStmt, tr *Trace) (*Result, error) {
+	if s.IndexedBy != "" {
+		return nil, &QueryError{Code: "0A000", Message: "INDEXED BY is not supported with UPDATE ... FROM"}
+	}
+	if !e.inTransaction() {
+		return e.atomically(func(x *Executor) (*Result, error) { return x.execUpdateFrom(s, tr) })
+	}
+
+	def, ok := e.engine.GetTable(s.Table.Name)
+	if !ok {
+		return nil, WrapError(&storage.TableNotFoundError{Name: s.Table.String()})
+	}
+	from := s.From
+	cols := make([]string, len(from.Rows[0]))
+	for i := range cols {
+		cols[i] = fmt.Sprintf("column%d", i+1)
+	}
+	copy(cols, from.Columns)
+
+	// refs holds, for each column of v, the OuterRefs that stand for it.
+	refs := make([][]*parser.OuterRef, len(cols))
+	var bindErr error
+	bind := func(expr parser.Expr) parser.Expr {
+		ref, ok := expr.(*parser.ColumnRef)
+		if !ok || (ref.Table != "" && !strings.EqualFold(ref.Table, from.Alias)) {
+			return nil
+		}
+		j := slices.IndexFunc(cols, func(c string) bool { return strings.EqualFold(c, ref.Name) })
+		switch {
+		case j < 0 && ref.Table != "":
+			bindErr = &QueryError{Code: "42703", Message: fmt.Sprintf("column %s.%s does not exist", from.Alias, ref.Name)}
+			return nil
+		case j < 0:
+			return nil
+		case ref.Table == "" && columnIndex(def, ref.Name) >= 0:
+			bindErr = &QueryError{Code: "42702", Message: fmt.Sprintf("column reference %q is ambiguous", ref.Name)}
+			return nil
+		}
+		o := &parser.OuterRef{Table: ref.Table, Column: ref.Name}
+		refs[j] = append(refs[j], o)
+		return o
+	}
+	bindSets(s.Sets, bind)
+	s.Where = bindExpr(s.Where, bind)
+	if bindErr != nil {
+		return nil, bindErr
+	}
+
+	setCols := setColumns(s.Sets)
+	checkFK := hasReferences(def, setCols)
+	refsTo := e.referencesTo(def, setCols)
+	ret, err := e.compileReturning(s.Returning, def)
+	if err != nil {
+		return nil, err
+	}
+	var where func(storage.Row) bool
+	if s.Where != nil {
+		if where, err = buildFilter(s.Where, def); err != nil {
+			return nil, WrapError(err)
+		}
+	}
+
+	done := make(map[int64]bool)
+	filter := func(r storage.Row) bool {
+		return !done[r.ID] && (where == nil || where(r))
+	}
+	var updated []storage.Row
+	for _, row := range from.Rows {
+		for j, expr := range row {
+			v, err := evalLiteral(expr)
+			if err != nil {
+				return nil, WrapError(err)
+			}
+			for _, o := range refs[j] {
+				o.Value = v
+			}
+		}
+		sets := make(map[string]any, len(s.Sets))
+		for _, sc := range s.Sets {
+			v, err := evalSetValue(def, sc)
+			if err != nil {
+				return nil, WrapError(fmt.Errorf("SET %s: %w", sc.Column, err))
+			}
+			sets[sc.Column] = v
+		}
+		rows, err := e.engine.UpdateReturning(s.Table.Name, sets, filter)
+		if err != nil {
+			return nil, WrapError(err)
+		}
+		for _, r := range rows {
+			done[r.ID] = true
+		}
+		updated = append(updated, rows...)
+	}
+	if checkFK {
+		if err := e.checkForeignKeys(def, updated); err != nil {
+			return nil, err
+		}
+	}
+	if len(refsTo) > 0 && len(updated) > 0 {
+		if err := e.checkReferencingRows(def, refsTo); err != nil {
+			return nil, err
+		}
+	}
+
+	if tr != nil {
+		tr.RowsReturned = int64(len(updated))
+	}
+	return ret.result(updated, fmt.Sprintf("UPDATE %d", len(updated)))
+}
@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUpdateFrom_Values(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price INTEGER)")
+	exec(t, e, "INSERT INTO items VALUES (1, 'a', 10), (2, 'b', 20), (3, 'c', 30), (4, 'd', 40)")
+
+	// Each row gets its own values; rows without a partner are untouched.
+	r := exec(t, e, "UPDATE items SET price = v.price, name = v.name FROM (VALUES (1, 100, 'x'), (3, 300, 'z'), (9, 900, 'none')) AS v(id, price, name) WHERE items.id = v.id")
+	if r.Tag != "UPDATE 2" {
+		t.Errorf("tag = %q, want UPDATE 2", r.Tag)
+	}
+	r = exec(t, e, "SELECT id, name, price FROM items ORDER BY id")
+	want := []string{"1|x|100", "2|b|20", "3|z|300", "4|d|40"}
+	if got := groupRows(r); !slices.Equal(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+
+	// Unqualified names that only v has, expressions of its values,
+	// default column names and RETURNING. A row paired with several rows
+	// of the list is updated once, from the first.
+	r = exec(t, e, "UPDATE items SET price = column2 * 2 FROM (VALUES (2, 1), (4, 2), (4, 3)) v WHERE id = column1 RETURNING id, price")
+	if got := groupRows(r); !slices.Equal(got, []string{"2|2", "4|4"}) || r.Tag != "UPDATE 2" {
+		t.Errorf("RETURNING = %q, tag %q, want 2|2 and 4|4, UPDATE 2", got, r.Tag)
+	}
+
+	// Rows are paired on their values before the statement, so keys can
+	// be renumbered without a row being moved twice.
+	exec(t, e, "UPDATE items SET id = v.new FROM (VALUES (1, 11), (2, 1)) AS v(old, new) WHERE id = v.old")
+	r = exec(t, e, "SELECT id, name FROM items ORDER BY id")
+	if got := groupRows(r); !slices.Equal(got, []string{"1|b", "3|z", "4|d", "11|x"}) {
+		t.Errorf("after renumbering: rows = %q", got)
+	}
+}
+
+func TestUpdateFrom_Errors(t *testing.T) {
+	e := setup(t)
+	exec(t, e, "CREATE TABLE items (id INTEGER PRIMARY KEY, price INTEGER)")
+	exec(t, e, "INSERT INTO items VALUES (1, 10), (2, 20)")
+
+	_, err := e.Execute("UPDATE items SET price = v.price FROM (VALUES (1, 5)) v(id, price) WHERE id = v.id")
+	assertSQLSTATE(t, err, "42702")
+	_, err = e.Execute("UPDATE items SET price = v.cost FROM (VALUES (1, 5)) v(id, price) WHERE items.id = v.id")
+	assertSQLSTATE(t, err, "42703")
+
+	// A failing row undoes the rows updated before it.
+	_, err = e.Execute("UPDATE items SET id = v.new FROM (VALUES (1, 5), (2, 5)) v(old, new) WHERE items.id = v.old")
+	assertSQLSTATE(t, err, "23505")
+	r := exec(t, e, "SELECT id, price FROM items ORDER BY id")
+	if got := groupRows(r); !slices.Equal(got, []string{"1|10", "2|20"}) {
+		t.Errorf("after failed update: rows = %q", got)
+	}
+
+	_, err = e.Execute("EXPLAIN UPDATE items SET price = v.p FROM (VALUES (1)) v(p)")
+	assertSQLSTATE(t, err, "0A000")
+}
@@ -0,0 +1,395 @@
+package executor
+
+import (
+	"fmt"
+
+	"mulldb/parser"
+	"mulldb/storage"
+)
+
+// viewRelation is a view expanded for one statement: the columns of its
+// query as a table definition and the rows the query returned.
+type viewRelation struct {
+	def  *storage.TableDef
+	rows []storage.Row
+}
+
+// maxViewDepth is how deeply views may be nested in one another when a
+// statement expands them.
+const maxViewDepth = 32
+
+// viewExpander runs the queries behind the views a statement reads. Each
+// view is expanded at most once per statement, on first use, so a view
+// joined with itself or read twice sees the same rows.
+type viewExpander struct {
+	e         *Executor // runs the view queries; its engine expands views too
+	views     map[string]*viewRelation
+	expanding map[string]bool // views whose query is running, to catch cycles
+	err       error           // first expansion failure; the statement fails with it
+}
+
+// withViews returns an executor for one statement whose engine resolves
+// view names to their expanded rows.
+func (e *Executor) withViews() *Executor {
+	c := *e
+	x := &viewExpander{
+		e:         &c,
+		views:     make(map[string]*viewRelation),
+		expanding: make(map[string]bool),
+	}
+	c.engine = viewEngine{Engine: e.engine, x: x}
+	return &c
+}
+
+// viewFailure returns the error a view expansion failed with while the
+// statement ran, or else err. A failed expansion makes the view look
+// missing to the code that asked for it, so its own error would only say
+// that the relation does not exist.
+func (e *Executor) viewFailure(err error) error {
+	if ve, ok := e.engine.(viewEngine); ok && ve.x.err != nil {
+		return ve.x.err
+	}
+	return err
+}
+
+// expand returns the expanded view name, running its query the first
+// time.
+func (x *viewExpander) expand(v *storage.ViewDef) (*viewRelation, error) {
+	if rel, ok := x.views[v.Name]; ok {
+		return rel, nil
+	}
+	if x.expanding[v.Name] {
+		return nil, &QueryError{
+			Code:    "42P17",
+			Message: fmt.Sprintf("infinite recursion detected in view %q", v.Name),
+		}
+	}
+	if len(x.expanding) >= maxViewDepth {
+		return nil, &QueryError{
+			Code:    "54001",
+			Message: fmt.Sprintf("view %q nests views more than %d deep", v.Name, maxViewDepth),
+		}
+	}
+	x.expanding[v.Name] = true
+	defer delete(x.expanding, v.Name)
+
+	rel, err := x.e.materializeView(v.Name, v.Query)
+	if err != nil {
+		return nil, err
+	}
+	x.views[v.Name] = rel
+	return rel, nil
+}
+
+// materializeView runs the SELECT of a view and converts its result back
+// into typed rows.
+func (e *Executor) materializeView(name, query string) (*viewRelation, error) {
+	stmt, err := parser.Parse(query)
+	if err != nil {
+		return nil, &QueryError{Code: "42601", Message: err.Error()}
+	}
+	switch stmt.(type) {
+	case *parser.SelectStmt, *parser.SetOpStmt:
+	default:
+		return nil, &QueryError{Code: "42809", Message: fmt.Sprintf("view %q is not defined by a SELECT", name)}
+	}
+	bindStatementTime(stmt, e.statementTime())
+	if err := bindStatementRandom(stmt, e.rand); err != nil {
+		return nil, err
+	}
+	if err := bindStatementSizes(stmt, e.engine); err != nil {
+		return nil, err
+	}
+	runs, err := e.bindSubqueries(stmt)
+	if err != nil {
+		return nil, err
+	}
+	res, err := e.dispatch(stmt, nil)
+	if err = runs.failure(err); err != nil {
+		return nil, err
+	}
+
+	def := &storage.TableDef{Name: name, NextOrdinal: len(res.Columns)}
+	for i, col := range res.Columns {
+		for _, prev := range def.Columns {
+			if prev.Name == col.Name {
+				return nil, &QueryError{
+					Code:    "42701",
+					Message: fmt.Sprintf("column %q specified more than once in view %q", col.Name, name),
+				}
+			}
+		}
+		def.Columns = append(def.Columns, storage.ColumnDef{
+			Name:     col.Name,
+			DataType: oidType(col.TypeOID),
+			Ordinal:  i,
+		})
+	}
+	rows := make([]storage.Row, len(res.Rows))
+	for i, r := range res.Rows {
+		vals := make([]any, len(r))
+		for j, cell := range r {
+			if cell == nil {
+				continue
+			}
+			v, err := parseTextValue(string(cell), res.Columns[j].TypeOID)
+			if err != nil {
+				return nil, fmt.Errorf("view %q column %q: %w", name, res.Columns[j].Name, err)
+			}
+			vals[j] = v
+		}
+		rows[i] = storage.Row{ID: int64(i + 1), Values: vals}
+	}
+	return &viewRelation{def: def, rows: rows}, nil
+}
+
+// oidType is the inverse of typeOID. Columns of unknown type, such as a
+// bare NULL, become TEXT.
+func oidType(oid int32) storage.DataType {
+	switch oid {
+	case OIDInt8:
+		return storage.TypeInteger
+	case OIDBool:
+		return storage.TypeBoolean
+	case OIDTimestampTZ:
+		return storage.TypeTimestamp
+	case OIDFloat8:
+		return storage.TypeFloat
+	case OIDBytea:
+		return storage.TypeBytea
+	case OIDNumeric:
+		return storage.TypeNumeric
+	case OIDTime:
+		return storage.TypeTime
+	default:
+		return storage.TypeText
+	}
+}
+
+// viewEngine presents views as read-only tables. Reads of a view name are
+// served from its expansion; writes and DDL aimed at a view fail with
+// SQLSTATE 42809.
+type viewEngine struct {
+	storage.Engine
+	x *viewExpander
+}
+
+// view returns the expansion of name if it is a view. A failed expansion
+// is recorded in x.err and reported as "not a view", so the caller fails
+// and the statement returns the recorded error. Once one has failed, no
+// view is expanded again: the statement is lost anyway, and retrying the
+// failed expansion for every lookup of a view nested in others would take
+// time exponential in the depth.
+func (e viewEngine) view(name string) (*viewRelation, bool) {
+	v, ok := e.Engine.GetView(name)
+	if !ok || e.x.err != nil {
+		return nil, false
+	}
+	rel, err := e.x.expand(v)
+	if err != nil {
+		if e.x.err == nil {
+			e.x.err = err
+		}
+		return nil, false
+	}
+	return rel, true
+}
+
+// isView reports whether name is a view, without expanding it.
+func (e viewEngine) isView(name string) bool {
+	_, ok := e.Engine.GetView(name)
+	return ok
+}
+
+func (e viewEngine) GetTable(name string) (*storage.TableDef, bool) {
+	if def, ok := e.Engine.GetTable(name); ok {
+		return def, true
+	}
+	if rel, ok := e.view(name); ok {
+		return rel.def, true
+	}
+	return nil, false
+}
+
+func (e viewEngine) Scan(table string) (storage.RowIterator, error) {
+	if rel, ok := e.view(table); ok {
+		return &catalogIterator{rows: rel.rows}, nil
+	}
+	return e.Engine.Scan(table)
+}
+
+func (e viewEngine) ScanEqual(table, column string, value any) (storage.RowIterator, error) {
+	rel, ok := e.view(table)
+	if !ok {
+		return e.Engine.ScanEqual(table, column, value)
+	}
+	ord := columnIndex(rel.def, column)
+	if ord < 0 {
+		return nil, &storage.ColumnNotFoundError{Column: column, Table: table}
+	}
+	var rows []storage.Row
+	for _, row := range rel.rows {
+		if v := storage.RowValue(row.Values, ord); v != nil && storage.CompareValues(v, value) == 0 {
+			rows = append(rows, row)
+		}
+	}
+	return &pickedIterator{catalogIterator: catalogIterator{rows: rows}, scanned: int64(len(rel.rows))}, nil
+}
+
+// pickedIterator is a catalogIterator over the rows of a view that
+// ScanEqual picked out of all of them.
+type pickedIterator struct {
+	catalogIterator
+	scanned int64 // rows of the view examined
+}
+
+func (it *pickedIterator) Scanned() int64 { return it.scanned }
+
+func (e viewEngine) RowCount(table string) (int64, error) {
+	if rel, ok := e.view(table); ok {
+		return int64(len(rel.rows)), nil
+	}
+	return e.Engine.RowCount(table)
+}
+
+// notTable is the error for a write or DDL statement naming a view.
+func notTable(name string) error {
+	return &QueryError{Code: "42809", Message: fmt.Sprintf("%q is a view", name)}
+}
+
+func (e viewEngine) DropTable(name string) error {
+	if e.isView(name) {
+		return notTable(name)
+	}
+	return e.Engine.DropTable(name)
+}
+
+func (e viewEngine) AddColumn(table string, col storage.ColumnDef) error {
+	if e.isView(table) {
+		return notTable(table)
+	}
+	return e.Engine.AddColumn(table, col)
+}
+
+func (e viewEngine) DropColumn(table string, colName string) error {
+	if e.isView(table) {
+		return notTable(table)
+	}
+	return e.Engine.DropColumn(table, colName)
+}
+
+func (e viewEngine) CreateIndex(table string, idx storage.IndexDef) error {
+	if e.isView(table) {
+		return notTable(table)
+	}
+	return e.Engine.CreateIndex(table, idx)
+}
+
+func (e viewEngine) Insert(table string, columns []string, values [][]any) (int64, error) {
+	if e.isView(table) {
+		return 0, notTable(table)
+	}
+	return e.Engine.Insert(table, columns, values)
+}
+
+func (e viewEngine) InsertReturning(table string, columns []string, values [][]any) ([]storage.Row, error) {
+	if e.isView(table) {
+		return nil, notTable(table)
+	}
+	return e.Engine.InsertReturning(table, columns, values)
+}
+
+func (e viewEngine) Upsert(table string, columns []string, values [][]any, oc storage.OnConflict) ([]storage.Row, error) {
+	if e.isView(table) {
+		return nil, notTable(table)
+	}
+	return e.Engine.Upsert(table, columns, values, oc)
+}
+
+func (e viewEngine) Update(table string, sets map[string]any, filter func(storage.Row) bool) (int64, error) {
+	if e.isView(table) {
+		return 0, notTable(table)
+	}
+	return e.Engine.Update(table, sets, filter)
+}
+
+func (e viewEngine) UpdateReturning(table string, sets map[string]any, filter func(storage.Row) bool) ([]storage.Row, error) {
+	if e.isView(table) {
+		return nil, notTable(table)
+	}
+	return e.Engine.UpdateReturning(table, sets, filter)
+}
+
+func (e viewEngine) Delete(table string, filter func(storage.Row) bool) (int64, error) {
+	if e.isView(table) {
+		return 0, notTable(table)
+	}
+	return e.Engine.Delete(table, filter)
+}
+
+func (e viewEngine) DeleteReturning(table string, filter func(storage.Row) bool) ([]storage.Row, error) {
+	if e.isView(table) {
+		return nil, notTable(table)
+	}
+	return e.Engine.DeleteReturning(table, filter)
+}
+
+func (e viewEngine) DeleteRows(table string, rowIDs []int64, filter func(storage.Row) bool) ([]storage.Row, error) {
+	if e.isView(table) {
+		return nil, notTable(table)
+	}
+	return e.Engine.DeleteRows(table, rowIDs, filter)
+}
+
+func (e viewEngine) Truncate(tables []string, restartIdentity bool) error {
+	for _, t := range tables {
+		if e.isView(t) {
+			return notTable(t)
+		}
+	}
+	return e.Engine.Truncate(tables, restartIdentity)
+}
+
+func (e viewEngine) Vacuum(table string) (int64, error) {
+	if e.isView(table) {
+		return 0, notTable(table)
+	}
+	return e.Engine.Vacuum(table)
+}
+
+func (e viewEngine) Checkpoint(table string) error {
+	if e.isView(table) {
+		return notTable(table)
+	}
+	return e.Engine.Checkpoint(table)
+}
+
+func (e viewEngine) Analyze(table string) error {
+	if e.isView(table) {
+		return notTable(table)
+	}
+	return e.Engine.Analyze(table)
+}
+
+// -------------------------------------------------------------------------
+// CREATE VIEW / DROP VIEW
+// -------------------------------------------------------------------------
+
+func (e *Executor) execCreateView(s *parser.CreateViewStmt) (*Result, error) {
+	// Running the query once checks that the relations and columns it
+	// names exist and that its output columns are distinct.
+	if _, err := e.materializeView(s.Name.Name, s.QuerySQL); err != nil {
+		return nil, err
+	}
+	if err := e.engine.CreateView(s.Name.Name, s.QuerySQL); err != nil {
+		return nil, WrapError(err)
+	}
+	return &Result{Tag: "CREATE VIEW"}, nil
+}
+
+func (e *Executor) execDropView(s *parser.DropViewStmt) (*Result, error) {
+	if err := e.engine.DropView(s.Name.Name); err != nil {
+		return nil, WrapError(err)
+	}
+	return &Result{Tag: "DROP VIEW"}, nil
+}
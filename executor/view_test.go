@@ -0,0 +1,218 @@
+package executor
+
+import (
+	"fmt"
+	"testing"
+
+	"mulldb/storage"
+)
+
+func setupViewTables(t *testing.T, e *Executor) {
+	t.Helper()
+	exec(t, e, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, age INTEGER, active BOOLEAN)")
+	exec(t, e, "INSERT INTO users VALUES (1, 'alice', 30, true), (2, 'bob', 17, true), (3, 'carol', 45, false), (4, 'dave', 22, true)")
+	exec(t, e, "CREATE VIEW adults AS SELECT id, name, age FROM users WHERE age >= 18 AND active")
+}
+
+func TestExecutor_View_Select(t *testing.T) {
+	e := setup(t)
+	setupViewTables(t, e)
+
+	r := exec(t, e, "SELECT name, age FROM adults WHERE age < 40 ORDER BY name")
+	if len(r.Rows) != 2 || string(r.Rows[0][0]) != "alice" || string(r.Rows[1][0]) != "dave" {
+		t.Fatalf("rows = %v, want alice and dave", r.Rows)
+	}
+	if r.Columns[1].TypeOID != OIDInt8 {
+		t.Errorf("age type = %d, want INT8", r.Columns[1].TypeOID)
+	}
+
+	r = exec(t, e, "SELECT * FROM adults ORDER BY id")
+	if len(r.Columns) != 3 || r.Columns[2].Name != "age" || len(r.Rows) != 2 {
+		t.Fatalf("SELECT * = %v %v, want id, name, age of two rows", r.Columns, r.Rows)
+	}
+	if r := exec(t, e, "SELECT COUNT(*) FROM adults"); string(r.Rows[0][0]) != "2" {
+		t.Errorf("COUNT(*) = %s, want 2", r.Rows[0][0])
+	}
+
+	// The view is expanded per statement, so it sees later writes.
+	exec(t, e, "UPDATE users SET active = true WHERE id = 3")
+	if r := exec(t, e, "SELECT COUNT(*) FROM adults"); string(r.Rows[0][0]) != "3" {
+		t.Errorf("COUNT(*) after update = %s, want 3", r.Rows[0][0])
+	}
+
+	// A view over a view.
+	exec(t, e, "CREATE VIEW adult_names AS SELECT name AS who FROM adults WHERE id > 1")
+	r = exec(t, e, "SELECT who FROM adult_names ORDER BY who")
+	if len(r.Rows) != 2 || string(r.Rows[0][0]) != "carol" || string(r.Rows[1][0]) != "dave" {
+		t.Errorf("nested view rows = %v, want carol and dave", r.Rows)
+	}
+}
+
+func TestExecutor_View_Join(t *testing.T) {
+	e := setup(t)
+	setupViewTables(t, e)
+	exec(t, e, "CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, total INTEGER)")
+	exec(t, e, "INSERT INTO orders VALUES (10, 1, 5), (11, 2, 7), (12, 4, 9), (13, 1, 3)")
+
+	r := exec(t, e, "SELECT a.name, o.total FROM orders o JOIN adults a ON a.id = o.user_id ORDER BY o.id")
+	if len(r.Rows) != 3 {
+		t.Fatalf("rows = %v, want 3 (bob is not an adult)", r.Rows)
+	}
+	want := [][2]string{{"alice", "5"}, {"dave", "9"}, {"alice", "3"}}
+	for i, w := range want {
+		if string(r.Rows[i][0]) != w[0] || string(r.Rows[i][1]) != w[1] {
+			t.Errorf("row %d = %s, %s; want %s, %s", i, r.Rows[i][0], r.Rows[i][1], w[0], w[1])
+		}
+	}
+
+	// Self-join of a view.
+	r = exec(t, e, "SELECT x.name, y.name FROM adults x JOIN adults y ON x.age < y.age")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "dave" || string(r.Rows[0][1]) != "alice" {
+		t.Errorf("self-join rows = %v, want dave, alice", r.Rows)
+	}
+}
+
+func TestExecutor_View_Subquery(t *testing.T) {
+	e := setup(t)
+	setupViewTables(t, e)
+
+	// A view whose query has a correlated subquery.
+	exec(t, e, `CREATE VIEW oldest_active AS SELECT id, name FROM users
+		WHERE age = (SELECT MAX(age) FROM users u2 WHERE u2.active = users.active)`)
+	r := exec(t, e, "SELECT name FROM oldest_active ORDER BY name")
+	if len(r.Rows) != 2 || string(r.Rows[0][0]) != "alice" || string(r.Rows[1][0]) != "carol" {
+		t.Errorf("rows = %v, want alice and carol", r.Rows)
+	}
+
+	// Subqueries over a view, and correlated with one.
+	r = exec(t, e, "SELECT name FROM users WHERE age = (SELECT MIN(age) FROM adults)")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "dave" {
+		t.Errorf("rows = %v, want dave", r.Rows)
+	}
+	r = exec(t, e, "SELECT a.name FROM adults a WHERE a.age < (SELECT age FROM users WHERE id = a.id - 1) ORDER BY a.name")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "dave" {
+		t.Errorf("rows = %v, want dave", r.Rows)
+	}
+}
+
+func TestExecutor_View_Catalog(t *testing.T) {
+	e := setup(t)
+	setupViewTables(t, e)
+
+	r := exec(t, e, "SELECT table_name, table_type FROM information_schema.tables WHERE table_schema = 'public' ORDER BY table_name")
+	if len(r.Rows) != 2 {
+		t.Fatalf("rows = %v, want users and adults", r.Rows)
+	}
+	if string(r.Rows[0][0]) != "adults" || string(r.Rows[0][1]) != "VIEW" {
+		t.Errorf("row 0 = %s %s, want adults VIEW", r.Rows[0][0], r.Rows[0][1])
+	}
+	if string(r.Rows[1][0]) != "users" || string(r.Rows[1][1]) != "BASE TABLE" {
+		t.Errorf("row 1 = %s %s, want users BASE TABLE", r.Rows[1][0], r.Rows[1][1])
+	}
+
+	r = exec(t, e, "SELECT table_schema, table_name, view_definition FROM information_schema.views")
+	if len(r.Rows) != 1 {
+		t.Fatalf("views = %v, want one", r.Rows)
+	}
+	if string(r.Rows[0][1]) != "adults" || string(r.Rows[0][2]) != "SELECT id, name, age FROM users WHERE age >= 18 AND active" {
+		t.Errorf("view row = %s", r.Rows[0])
+	}
+
+	r = exec(t, e, "SELECT relkind FROM pg_class WHERE relname = 'adults'")
+	if len(r.Rows) != 1 || string(r.Rows[0][0]) != "v" {
+		t.Errorf("pg_class relkind = %v, want v", r.Rows)
+	}
+}
+
+func TestExecutor_View_Drop(t *testing.T) {
+	e := setup(t)
+	setupViewTables(t, e)
+
+	if r := exec(t, e, "DROP VIEW adults"); r.Tag != "DROP VIEW" {
+		t.Errorf("tag = %q, want DROP VIEW", r.Tag)
+	}
+	_, err := e.Execute("SELECT * FROM adults")
+	assertSQLSTATE(t, err, "42P01")
+	_, err = e.Execute("DROP VIEW adults")
+	assertSQLSTATE(t, err, "42P01")
+	if r := exec(t, e, "SELECT COUNT(*) FROM information_schema.views"); string(r.Rows[0][0]) != "0" {
+		t.Errorf("views after drop = %s, want 0", r.Rows[0][0])
+	}
+	// The name is free again, for a table as well as a view.
+	exec(t, e, "CREATE TABLE adults (id INTEGER)")
+}
+
+func TestExecutor_View_Errors(t *testing.T) {
+	e := setup(t)
+	setupViewTables(t, e)
+
+	_, err := e.Execute("CREATE VIEW adults AS SELECT 1")
+	assertSQLSTATE(t, err, "42P07")
+	_, err = e.Execute("CREATE VIEW users AS SELECT 1")
+	assertSQLSTATE(t, err, "42P07")
+	_, err = e.Execute("CREATE TABLE adults (id INTEGER)")
+	assertSQLSTATE(t, err, "42P07")
+	_, err = e.Execute("CREATE VIEW v AS SELECT * FROM missing")
+	assertSQLSTATE(t, err, "42P01")
+	if _, err := e.Execute("CREATE VIEW v AS SELECT nope FROM users"); err == nil {
+		t.Error("expected error for a view over a missing column")
+	}
+	_, err = e.Execute("CREATE VIEW v AS SELECT id, id FROM users")
+	assertSQLSTATE(t, err, "42701")
+
+	for _, sql := range []string{
+		"INSERT INTO adults VALUES (9, 'eve', 50)",
+		"UPDATE adults SET age = 1",
+		"DELETE FROM adults",
+		"TRUNCATE adults",
+		"DROP TABLE adults",
+		"CREATE INDEX idx_age ON adults (age)",
+	} {
+		_, err := e.Execute(sql)
+		assertSQLSTATE(t, err, "42809")
+	}
+	if r := exec(t, e, "SELECT COUNT(*) FROM users"); string(r.Rows[0][0]) != "4" {
+		t.Errorf("users = %s rows, want 4", r.Rows[0][0])
+	}
+
+	// A view whose table was dropped fails when it is read.
+	exec(t, e, "CREATE TABLE tmp (id INTEGER)")
+	exec(t, e, "CREATE VIEW over_tmp AS SELECT id FROM tmp")
+	exec(t, e, "DROP TABLE tmp")
+	_, err = e.Execute("SELECT * FROM over_tmp")
+	assertSQLSTATE(t, err, "42P01")
+
+	// CREATE VIEW runs its query first, so it cannot close a cycle; one
+	// stored by the engine directly is caught when either view is read.
+	_, err = e.Execute("CREATE VIEW tmp AS SELECT id FROM over_tmp")
+	assertSQLSTATE(t, err, "42P01")
+	if err := e.Engine().CreateView("tmp", "SELECT id FROM over_tmp"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.Execute("SELECT * FROM over_tmp")
+	assertSQLSTATE(t, err, "42P17")
+	_, err = e.Execute("SELECT COUNT(*) FROM tmp")
+	assertSQLSTATE(t, err, "42P17")
+	exec(t, e, "DROP VIEW tmp")
+
+	// Views nest up to maxViewDepth deep.
+	exec(t, e, "CREATE VIEW nest1 AS SELECT id FROM users")
+	for i := 2; i <= maxViewDepth+1; i++ {
+		exec(t, e, fmt.Sprintf("CREATE VIEW nest%d AS SELECT id FROM nest%d", i, i-1))
+	}
+	if r := exec(t, e, fmt.Sprintf("SELECT COUNT(*) FROM nest%d", maxViewDepth)); string(r.Rows[0][0]) != "4" {
+		t.Errorf("nested views = %s rows, want 4", r.Rows[0][0])
+	}
+	_, err = e.Execute(fmt.Sprintf("SELECT COUNT(*) FROM nest%d", maxViewDepth+1))
+	assertSQLSTATE(t, err, "54001")
+
+	// DDL on views is rejected inside a transaction like other DDL.
+	tx := e.WithEngine(storage.NewTxEngine(e.Engine()))
+	_, err = tx.Execute("CREATE VIEW v AS SELECT 1")
+	if err == nil {
+		t.Error("expected CREATE VIEW to fail inside a transaction")
+	}
+	if r := exec(t, tx, "SELECT COUNT(*) FROM adults"); string(r.Rows[0][0]) != "2" {
+		t.Errorf("COUNT(*) in transaction = %s, want 2", r.Rows[0][0])
+	}
+}
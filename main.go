@@ -17,6 +17,13 @@ import (
 func main() {
 	cfg := config.Parse()
 
+	if cfg.Restore != "" {
+		if err := storage.Restore(cfg.Restore, cfg.DataDir); err != nil {
+			log.Fatalf("restore: %v", err)
+		}
+		log.Printf("restored %s into %s", cfg.Restore, cfg.DataDir)
+	}
+
 	eng, err := storage.Open(cfg.DataDir, cfg.Migrate)
 	if err != nil {
 		log.Fatalf("open storage: %v", err)
@@ -1,5 +1,7 @@
 package parser
 
+import "time"
+
 // Statement is the interface implemented by all SQL statement AST nodes.
 // The unexported marker method restricts implementations to this package.
 type Statement interface {
@@ -41,9 +43,25 @@ func (r TableRef) IsEmpty() bool {
 // ColumnDef describes a column in a CREATE TABLE statement.
 type ColumnDef struct {
 	Name       string
-	DataType   string // "INTEGER", "TEXT", or "BOOLEAN"
+	DataType   string // "INTEGER", "TEXT", "BOOLEAN", "NUMERIC", ...
+	Precision  int    // NUMERIC(p,s) precision; 0 when not given
+	Scale      int    // NUMERIC(p,s) scale
 	PrimaryKey bool
 	NotNull    bool
+	Unique     bool   // UNIQUE column constraint
+	Default    Expr   // DEFAULT expression; nil when none
+	DefaultSQL string // source text of Default, as stored in the catalog
+	References *ForeignKey // REFERENCES constraint; nil when none
+	Identity   string      // "ALWAYS" or "BY DEFAULT" for SERIAL and identity columns; "" otherwise
+	Dictionary bool        // STORAGE DICTIONARY: values are dictionary-encoded in memory
+}
+
+// ForeignKey is a REFERENCES constraint, written after a column or as a
+// table-level FOREIGN KEY (col) REFERENCES ... clause.
+type ForeignKey struct {
+	Table    string
+	Column   string // referenced column; "" for the parent's primary key
+	OnDelete string // "NO ACTION", "RESTRICT", "CASCADE" or "SET NULL"
 }
 
 // SetClause represents a single col = expr assignment in UPDATE ... SET.
@@ -67,11 +85,20 @@ type DropTableStmt struct {
 	Name TableRef
 }
 
-// InsertStmt: INSERT INTO <table> [(<cols>)] VALUES (<exprs>), ...
+// InsertStmt: INSERT INTO <table> [(<cols>)] VALUES (<exprs>), ... [RETURNING <cols>]
 type InsertStmt struct {
-	Table   TableRef
-	Columns []string // nil when omitted
-	Values  [][]Expr
+	Table      TableRef
+	Columns    []string // nil when omitted
+	Values     [][]Expr
+	OnConflict *OnConflictClause // nil when no ON CONFLICT clause
+	Returning  []Expr            // nil when no RETURNING clause; same forms as a select list
+}
+
+// OnConflictClause is the ON CONFLICT [(column)] DO NOTHING | DO UPDATE SET
+// clause of an INSERT. Sets is nil for DO NOTHING.
+type OnConflictClause struct {
+	Column string // conflict target; "" when omitted (DO NOTHING only)
+	Sets   []SetClause
 }
 
 // JoinClause represents a single JOIN in a SELECT statement.
@@ -83,13 +110,21 @@ type JoinClause struct {
 
 // OrderByClause represents a single column in an ORDER BY clause.
 type OrderByClause struct {
-	Table  string // "" when unqualified
-	Column string // column name
-	Desc   bool   // true = DESC, false = ASC (default)
+	Table   string // "" when unqualified
+	Column   string // column name; "" when ordering by Expr or Position
+	Expr     Expr   // non-column sort key (e.g. RANDOM()); nil for a column
+	ExprSQL  string // source text of Expr or Position
+	Position int    // ORDER BY n: 1-based select-list position; 0 otherwise
+	Desc     bool   // true = DESC, false = ASC (default)
+
+	// NullsFirst is set by NULLS FIRST or NULLS LAST; nil when neither
+	// is given, for NULLs last with ASC and first with DESC.
+	NullsFirst *bool
 }
 
 // SelectStmt: SELECT <cols> FROM <table> [INDEXED BY <name>] [JOIN ...] [WHERE <expr>] [GROUP BY ...] [ORDER BY ...] [LIMIT n] [OFFSET n]
 type SelectStmt struct {
+	Distinct  bool   // SELECT DISTINCT: drop duplicate result rows
 	Columns   []Expr // StarExpr for *, ColumnRef for named columns
 	From      TableRef
 	FromAlias string          // "" when no alias
@@ -97,24 +132,110 @@ type SelectStmt struct {
 	Joins     []JoinClause    // nil when no joins
 	Where     Expr            // nil when no WHERE clause
 	GroupBy   []Expr          // nil when no GROUP BY clause
+	// GroupingSets lists the grouping sets of a GROUP BY with ROLLUP,
+	// CUBE, GROUPING SETS or (), each as indexes into GroupBy. It is nil
+	// for a plain GROUP BY, which has the one set of all of GroupBy.
+	GroupingSets [][]int
 	OrderBy   []OrderByClause // nil when no ORDER BY clause
 	Limit     *int64          // nil = no limit
 	Offset    *int64          // nil = no offset
+
+	// LimitExpr and OffsetExpr hold a LIMIT or OFFSET that is not a plain
+	// integer literal, e.g. LIMIT 2 * 3. The executor evaluates them into
+	// Limit and Offset before running the query.
+	LimitExpr  Expr
+	OffsetExpr Expr
+}
+
+// SetOpStmt: <query> UNION|INTERSECT|EXCEPT [ALL|DISTINCT] <query> [ORDER BY ...] [LIMIT n] [OFFSET n]
+// Each query is a SelectStmt or, for a chain of operations, another
+// SetOpStmt. ORDER BY, LIMIT and OFFSET apply to the combined result.
+type SetOpStmt struct {
+	Op      string    // "UNION", "INTERSECT" or "EXCEPT"
+	All     bool      // ALL: keep duplicate rows
+	Left    Statement // *SelectStmt or *SetOpStmt
+	Right   Statement // *SelectStmt or *SetOpStmt
+	OrderBy []OrderByClause
+	Limit   *int64
+	Offset  *int64
+
+	LimitExpr  Expr // as in SelectStmt
+	OffsetExpr Expr
 }
 
-// UpdateStmt: UPDATE <table> [INDEXED BY <name>] SET <sets> [WHERE <expr>]
+// UpdateStmt: UPDATE <table> [INDEXED BY <name>] SET <sets> [FROM <values>] [WHERE <expr>] [RETURNING <cols>]
 type UpdateStmt struct {
 	Table     TableRef
 	IndexedBy string // "" when not specified
 	Sets      []SetClause
-	Where     Expr // nil when no WHERE clause
+	From      *ValuesTable // nil when no FROM clause
+	Where     Expr         // nil when no WHERE clause
+	Returning []Expr       // nil when no RETURNING clause
 }
 
-// DeleteStmt: DELETE FROM <table> [INDEXED BY <name>] [WHERE <expr>]
+// ValuesTable: (VALUES (<exprs>) [, ...]) [AS] <alias> [(<columns>)], a
+// VALUES list read as a table. Columns names the first columns of the
+// list; the others are column1, column2 and so on by position.
+type ValuesTable struct {
+	Rows    [][]Expr
+	Alias   string
+	Columns []string
+}
+
+// DeleteStmt: DELETE FROM <table> [INDEXED BY <name>] [WHERE <expr>] [RETURNING <cols>]
 type DeleteStmt struct {
 	Table     TableRef
 	IndexedBy string // "" when not specified
 	Where     Expr   // nil when no WHERE clause
+	Returning []Expr // nil when no RETURNING clause
+}
+
+// TruncateStmt: TRUNCATE [TABLE] <table> [, ...] [RESTART IDENTITY | CONTINUE IDENTITY]
+type TruncateStmt struct {
+	Tables          []TableRef
+	RestartIdentity bool // RESTART IDENTITY; CONTINUE IDENTITY is the default
+}
+
+// ValuesStmt: VALUES (<exprs>) [, (<exprs>) ...] as a query of its own.
+// All rows have the same number of expressions.
+type ValuesStmt struct {
+	Rows [][]Expr
+}
+
+// AnalyzeStmt: ANALYZE [<table>]. An empty Table analyzes every table.
+type AnalyzeStmt struct {
+	Table TableRef
+}
+
+// VacuumStmt: VACUUM [<table>]. An empty Table vacuums every table.
+type VacuumStmt struct {
+	Table TableRef
+}
+
+// CheckpointStmt: CHECKPOINT. Rewrites the WAL of every table.
+type CheckpointStmt struct{}
+
+// ListenStmt: LISTEN <channel>.
+type ListenStmt struct {
+	Channel string
+}
+
+// UnlistenStmt: UNLISTEN <channel> | *. An empty Channel stands for *.
+type UnlistenStmt struct {
+	Channel string
+}
+
+// NotifyStmt: NOTIFY <channel> [, '<payload>'].
+type NotifyStmt struct {
+	Channel string
+	Payload string
+}
+
+// BackupStmt: BACKUP TO ['<file>' | DIRECTORY '<dir>']. Writes a snapshot
+// of the database to a file or a directory on the server.
+type BackupStmt struct {
+	Path      string
+	Directory bool // Path names a directory to write a data directory to
 }
 
 // BeginStmt: BEGIN (no-op transaction start)
@@ -138,12 +259,12 @@ type AlterTableDropColumnStmt struct {
 	Column string
 }
 
-// CreateIndexStmt: CREATE [UNIQUE] INDEX [name] ON table(column)
+// CreateIndexStmt: CREATE [UNIQUE] INDEX [name] ON table(column [, ...])
 type CreateIndexStmt struct {
-	Name   string // empty if user omitted (auto-generated by executor)
-	Table  TableRef
-	Column string
-	Unique bool
+	Name    string // empty if user omitted (auto-generated by executor)
+	Table   TableRef
+	Columns []string
+	Unique  bool
 }
 
 // DropIndexStmt: DROP INDEX name ON table
@@ -152,15 +273,67 @@ type DropIndexStmt struct {
 	Table TableRef
 }
 
+// CreateViewStmt: CREATE VIEW <name> AS <select>
+type CreateViewStmt struct {
+	Name     TableRef
+	Query    Statement // *SelectStmt or *SetOpStmt
+	QuerySQL string    // source text of Query, as stored in the catalog
+}
+
+// DropViewStmt: DROP VIEW <name>
+type DropViewStmt struct {
+	Name TableRef
+}
+
 // ShowMemoryStmt: SHOW MEMORY
 type ShowMemoryStmt struct{}
 
+// SetStmt: SET [SESSION | LOCAL] <name> {= | TO} <value> [, <value> ...]
+type SetStmt struct {
+	Name  string // lowercased
+	Value string // quoted values verbatim, others lowercased; list items joined with ", "
+	Local bool   // SET LOCAL: only until the end of the transaction
+}
+
+// ShowStmt: SHOW <name> (any parameter other than MEMORY)
+type ShowStmt struct {
+	Name string // lowercased
+}
+
+// ExplainStmt: EXPLAIN [ANALYZE] <statement>
+type ExplainStmt struct {
+	Analyze bool      // run the statement and report its trace
+	Stmt    Statement // SELECT, UPDATE or DELETE
+}
+
+// CopyStmt: COPY <table> [(<columns>)] FROM STDIN | TO STDOUT, or
+// COPY ( <query> ) TO STDOUT, with [[WITH] ( FORMAT <name> ) | [WITH] CSV]
+type CopyStmt struct {
+	Table   TableRef  // the table copied, when Query is nil
+	Columns []string  // nil for all columns of Table
+	Query   Statement // *SelectStmt or *SetOpStmt; nil when copying a table
+	From    bool      // FROM STDIN: load rows; otherwise TO STDOUT
+	Format  string    // "text" or "csv"
+	Header  bool      // the first line names the columns
+	Null    *string   // the NULL marker; nil for \N in text, an unquoted empty field in CSV
+}
+
 func (*CreateTableStmt) statementNode()          {}
 func (*DropTableStmt) statementNode()             {}
 func (*InsertStmt) statementNode()                {}
 func (*SelectStmt) statementNode()                {}
+func (*SetOpStmt) statementNode()                 {}
 func (*UpdateStmt) statementNode()                {}
 func (*DeleteStmt) statementNode()                {}
+func (*TruncateStmt) statementNode()              {}
+func (*AnalyzeStmt) statementNode()               {}
+func (*VacuumStmt) statementNode()                {}
+func (*CheckpointStmt) statementNode()            {}
+func (*BackupStmt) statementNode()                {}
+func (*ListenStmt) statementNode()                {}
+func (*UnlistenStmt) statementNode()              {}
+func (*NotifyStmt) statementNode()                {}
+func (*ValuesStmt) statementNode()                {}
 func (*BeginStmt) statementNode()                 {}
 func (*CommitStmt) statementNode()                {}
 func (*RollbackStmt) statementNode()              {}
@@ -168,7 +341,13 @@ func (*AlterTableAddColumnStmt) statementNode()   {}
 func (*AlterTableDropColumnStmt) statementNode()  {}
 func (*CreateIndexStmt) statementNode()           {}
 func (*DropIndexStmt) statementNode()             {}
+func (*CreateViewStmt) statementNode()            {}
+func (*DropViewStmt) statementNode()              {}
 func (*ShowMemoryStmt) statementNode()            {}
+func (*SetStmt) statementNode()                   {}
+func (*ShowStmt) statementNode()                  {}
+func (*ExplainStmt) statementNode()               {}
+func (*CopyStmt) statementNode()                  {}
 
 // ---------------------------------------------------------------------------
 // Expressions
@@ -206,6 +385,31 @@ type BoolLit struct {
 // NullLit represents the NULL literal.
 type NullLit struct{}
 
+// TimestampLit is a fixed point in time. The parser never produces it: the
+// executor substitutes it for NOW() and CURRENT_TIMESTAMP before running a
+// statement, so that every reference sees the same instant.
+type TimestampLit struct {
+	Value time.Time
+}
+
+// RandomExpr yields a new random number in [0, 1) each time it is
+// evaluated. Like TimestampLit it is never parsed: the executor substitutes
+// it for RANDOM(), with Next drawing from the session's generator.
+type RandomExpr struct {
+	Next func() float64
+}
+
+// DefaultExpr is the DEFAULT keyword in an INSERT row or a SET assignment,
+// standing for the target column's default value.
+type DefaultExpr struct{}
+
+// ParamRef is a $n bind parameter placeholder of the extended query
+// protocol. Index is 1-based. The executor substitutes the bound value
+// before the statement is planned.
+type ParamRef struct {
+	Index int
+}
+
 // UnaryExpr is a unary operation (e.g. -expr).
 type UnaryExpr struct {
 	Op   string // "-"
@@ -243,20 +447,23 @@ type NotExpr struct {
 	Expr Expr
 }
 
-// LikeExpr represents [NOT] LIKE / [NOT] ILIKE pattern [ESCAPE char].
+// LikeExpr represents [NOT] LIKE / [NOT] ILIKE / [NOT] SIMILAR TO pattern
+// [ESCAPE char].
 type LikeExpr struct {
 	Expr            Expr // left-hand value
 	Pattern         Expr // right-hand pattern
 	Escape          Expr // optional ESCAPE character (nil if not specified)
-	Not             bool // true for NOT LIKE / NOT ILIKE
+	Not             bool // true for NOT LIKE / NOT ILIKE / NOT SIMILAR TO
 	CaseInsensitive bool // true for ILIKE
+	Similar         bool // true for SIMILAR TO
 }
 
-// InExpr represents [NOT] IN (expr, expr, ...).
+// InExpr represents [NOT] IN (expr, expr, ...) or [NOT] IN (SELECT ...).
 type InExpr struct {
-	Expr   Expr
-	Values []Expr
-	Not    bool // true for NOT IN
+	Expr     Expr
+	Values   []Expr
+	Subquery *SubqueryExpr // IN (SELECT ...); nil for a value list
+	Not      bool          // true for NOT IN
 }
 
 // BetweenExpr represents [NOT] BETWEEN low AND high.
@@ -269,8 +476,10 @@ type BetweenExpr struct {
 
 // CastExpr represents expr::type (PostgreSQL-style type cast).
 type CastExpr struct {
-	Expr     Expr
-	TypeName string // uppercased: "INTEGER", "TEXT", "BOOLEAN", "FLOAT", "TIMESTAMP"
+	Expr      Expr
+	TypeName  string // uppercased: "INTEGER", "TEXT", "BOOLEAN", "FLOAT", "TIMESTAMP"
+	Precision int    // NUMERIC(p,s) precision; 0 when not given
+	Scale     int    // NUMERIC(p,s) scale
 }
 
 // NestExpr represents NEST(SELECT ...) — a correlated subquery that collects rows.
@@ -279,13 +488,39 @@ type NestExpr struct {
 	Format string // "", "JSON", or "JSONA"
 }
 
+// SubqueryExpr is a parenthesized SELECT used as a value: (SELECT ...).
+// Before the statement is planned the executor replaces the columns of the
+// enclosing query that Query refers to with the OuterRefs listed in Outer,
+// and sets Run. Run runs Query with the current values of Outer and
+// returns the values of its single column, failing if there are more than
+// maxRows of them.
+type SubqueryExpr struct {
+	Query *SelectStmt
+	Outer []*OuterRef
+	Run   func(maxRows int) ([]any, error)
+}
+
+// OuterRef is a column of the enclosing query referenced in a subquery.
+// Like RandomExpr it is never parsed: the executor substitutes it for the
+// ColumnRef and sets Value to the column's value in the current outer row
+// before each run of the subquery.
+type OuterRef struct {
+	Table  string // "" when unqualified
+	Column string
+	Value  any
+}
+
 func (*ColumnRef) exprNode()         {}
 func (*StarExpr) exprNode()          {}
 func (*IntegerLit) exprNode()        {}
 func (*FloatLit) exprNode()          {}
 func (*StringLit) exprNode()         {}
 func (*BoolLit) exprNode()           {}
+func (*TimestampLit) exprNode()      {}
+func (*RandomExpr) exprNode()        {}
 func (*NullLit) exprNode()           {}
+func (*ParamRef) exprNode()          {}
+func (*DefaultExpr) exprNode()       {}
 func (*UnaryExpr) exprNode()         {}
 func (*BinaryExpr) exprNode()        {}
 func (*FunctionCallExpr) exprNode()  {}
@@ -297,3 +532,5 @@ func (*InExpr) exprNode()            {}
 func (*BetweenExpr) exprNode()       {}
 func (*CastExpr) exprNode()          {}
 func (*NestExpr) exprNode()          {}
+func (*SubqueryExpr) exprNode()      {}
+func (*OuterRef) exprNode()          {}
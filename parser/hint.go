@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Hints holds per-statement settings given in hint comments ahead of the
+// statement, such as
+//
+//	/*+ timeout(500ms) */ SELECT ...
+//
+// A hint comment is a list of name(value) items. Unknown names are
+// ignored, so hints meant for other systems do not break a statement.
+type Hints struct {
+	Timeout time.Duration // statement deadline; 0 for none
+}
+
+// parseHints reads the bodies of hint comments.
+func parseHints(bodies []string) (Hints, error) {
+	var h Hints
+	for _, body := range bodies {
+		rest := strings.TrimSpace(body)
+		for rest != "" {
+			name, value, tail, err := cutHint(rest)
+			if err != nil {
+				return Hints{}, err
+			}
+			if strings.EqualFold(name, "timeout") {
+				d, err := parseHintDuration(value)
+				if err != nil {
+					return Hints{}, fmt.Errorf("invalid timeout hint %q: %v", value, err)
+				}
+				h.Timeout = d
+			}
+			rest = strings.TrimLeft(tail, " \t\r\n,")
+		}
+	}
+	return h, nil
+}
+
+// cutHint splits the leading name(value) item off s.
+func cutHint(s string) (name, value, rest string, err error) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if i <= 0 {
+		return "", "", "", fmt.Errorf("invalid hint %q", s)
+	}
+	name = s[:i]
+	open := strings.TrimLeft(s[i:], " \t")
+	if !strings.HasPrefix(open, "(") {
+		return "", "", "", fmt.Errorf("expected ( after hint %s", name)
+	}
+	value, rest, ok := strings.Cut(open[1:], ")")
+	if !ok {
+		return "", "", "", fmt.Errorf("unterminated hint %s", name)
+	}
+	return name, strings.TrimSpace(value), rest, nil
+}
+
+// parseHintDuration accepts a Go duration such as 500ms or 2s, or a bare
+// number of milliseconds as in PostgreSQL's statement_timeout.
+func parseHintDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		ms, perr := strconv.ParseInt(s, 10, 64)
+		if perr != nil {
+			return 0, err
+		}
+		d = time.Duration(ms) * time.Millisecond
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return d, nil
+}
@@ -12,6 +12,9 @@ type Lexer struct {
 	pos   int  // current byte position
 	width int  // byte width of current rune
 	ch    rune // current character, 0 at EOF
+
+	emitted bool     // a token has been returned
+	hints   []string // bodies of /*+ ... */ comments before the first token
 }
 
 // NewLexer creates a lexer for the given input.
@@ -42,9 +45,17 @@ func (l *Lexer) peek() rune {
 	return r
 }
 
+// Hints returns the bodies of the hint comments (/*+ ... */, without the
+// plus sign) that precede the first token. Hint comments anywhere else are
+// ordinary comments.
+func (l *Lexer) Hints() []string {
+	return l.hints
+}
+
 // NextToken returns the next token from the input.
 func (l *Lexer) NextToken() Token {
 	l.skipWhitespace()
+	l.emitted = true
 	start := l.pos
 
 	switch {
@@ -99,6 +110,17 @@ func (l *Lexer) NextToken() Token {
 		}
 		l.advance()
 		return Token{Type: TokenIllegal, Literal: ":", Pos: start}
+	case l.ch == '$':
+		if !isDigit(l.peek()) {
+			l.advance()
+			return Token{Type: TokenIllegal, Literal: "$", Pos: start}
+		}
+		l.advance()
+		begin := l.pos
+		for isDigit(l.ch) {
+			l.advance()
+		}
+		return Token{Type: TokenParam, Literal: l.input[begin:l.pos], Pos: start}
 	case l.ch == '=':
 		l.advance()
 		return Token{Type: TokenEq, Literal: "=", Pos: start}
@@ -156,7 +178,10 @@ func (l *Lexer) skipWhitespace() {
 			continue
 		}
 		if l.ch == '/' && l.peek() == '*' {
-			l.skipBlockComment()
+			body := l.skipBlockComment()
+			if !l.emitted && strings.HasPrefix(body, "+") {
+				l.hints = append(l.hints, body[1:])
+			}
 			continue
 		}
 		break
@@ -171,9 +196,13 @@ func (l *Lexer) skipLineComment() {
 	}
 }
 
-func (l *Lexer) skipBlockComment() {
+// skipBlockComment skips a possibly nested block comment and returns the
+// text between its outer delimiters.
+func (l *Lexer) skipBlockComment() string {
 	l.advance() // skip /
 	l.advance() // skip *
+	start := l.pos
+	end := len(l.input)
 	depth := 1
 	for l.ch != 0 && depth > 0 {
 		if l.ch == '/' && l.peek() == '*' {
@@ -181,6 +210,7 @@ func (l *Lexer) skipBlockComment() {
 			l.advance()
 			depth++
 		} else if l.ch == '*' && l.peek() == '/' {
+			end = l.pos
 			l.advance()
 			l.advance()
 			depth--
@@ -188,19 +218,26 @@ func (l *Lexer) skipBlockComment() {
 			l.advance()
 		}
 	}
+	return l.input[start:end]
 }
 
 func (l *Lexer) readString(start int) Token {
 	l.advance() // skip opening quote
-	begin := l.pos
-	for l.ch != 0 && l.ch != '\'' {
+	var sb strings.Builder
+	for l.ch != 0 {
+		if l.ch == '\'' {
+			if l.peek() != '\'' {
+				break
+			}
+			l.advance() // '' is an escaped quote
+		}
+		sb.WriteRune(l.ch)
 		l.advance()
 	}
-	str := l.input[begin:l.pos]
 	if l.ch == '\'' {
 		l.advance() // skip closing quote
 	}
-	return Token{Type: TokenStrLit, Literal: str, Pos: start}
+	return Token{Type: TokenStrLit, Literal: sb.String(), Pos: start}
 }
 
 func (l *Lexer) readNumber(start int) Token {
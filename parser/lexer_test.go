@@ -206,6 +206,21 @@ func TestLexerCommentBlockInsideStringLiteral(t *testing.T) {
 	}
 }
 
+func TestLexerStringEscapedQuote(t *testing.T) {
+	l := NewLexer("'it''s' ''''")
+	tok := l.NextToken()
+	if tok.Type != TokenStrLit || tok.Literal != "it's" {
+		t.Fatalf("expected STRING %q, got %s %q", "it's", tok.Type, tok.Literal)
+	}
+	tok = l.NextToken()
+	if tok.Type != TokenStrLit || tok.Literal != "'" {
+		t.Fatalf("expected STRING %q, got %s %q", "'", tok.Type, tok.Literal)
+	}
+	if tok = l.NextToken(); tok.Type != TokenEOF {
+		t.Fatalf("expected EOF, got %s", tok.Type)
+	}
+}
+
 func TestLexerCommentMinusOperatorNotConfused(t *testing.T) {
 	l := NewLexer("5 - 3")
 	tok := l.NextToken()
@@ -361,3 +376,13 @@ func TestLexerFloatKeywords(t *testing.T) {
 		t.Errorf("DOUBLE: got %s, want DOUBLE keyword", tok.Type)
 	}
 }
+
+func TestLexerHintComment(t *testing.T) {
+	l := NewLexer("/* note */ /*+ timeout(1s) */ SELECT /*+ ignored */ 1")
+	for l.NextToken().Type != TokenEOF {
+	}
+	hints := l.Hints()
+	if len(hints) != 1 || hints[0] != " timeout(1s) " {
+		t.Fatalf("hints = %q, want [\" timeout(1s) \"]", hints)
+	}
+}
@@ -2,6 +2,8 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -15,12 +17,23 @@ type parser struct {
 
 // Parse parses a single SQL statement from input.
 func Parse(input string) (Statement, error) {
+	stmt, _, err := ParseWithHints(input)
+	return stmt, err
+}
+
+// ParseWithHints is Parse that also returns the settings given in hint
+// comments ahead of the statement.
+func ParseWithHints(input string) (Statement, Hints, error) {
 	p := &parser{lexer: NewLexer(input)}
 	p.next()
 
+	hints, err := parseHints(p.lexer.Hints())
+	if err != nil {
+		return nil, Hints{}, err
+	}
 	stmt, err := p.parseStatement()
 	if err != nil {
-		return nil, err
+		return nil, Hints{}, err
 	}
 
 	// Allow an optional trailing semicolon.
@@ -28,10 +41,26 @@ func Parse(input string) (Statement, error) {
 		p.next()
 	}
 	if p.cur.Type != TokenEOF {
-		return nil, fmt.Errorf("unexpected %q after statement at position %d",
+		return nil, Hints{}, fmt.Errorf("unexpected %q after statement at position %d",
 			p.cur.Literal, p.cur.Pos)
 	}
-	return stmt, nil
+	return stmt, hints, nil
+}
+
+// ParseExpr parses a single expression, such as a column default read back
+// from the catalog.
+func ParseExpr(input string) (Expr, error) {
+	p := &parser{lexer: NewLexer(input)}
+	p.next()
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.cur.Type != TokenEOF {
+		return nil, fmt.Errorf("unexpected %q after expression at position %d",
+			p.cur.Literal, p.cur.Pos)
+	}
+	return expr, nil
 }
 
 // -------------------------------------------------------------------------
@@ -52,6 +81,51 @@ func (p *parser) expect(t TokenType) (Token, error) {
 	return tok, nil
 }
 
+// expectWord consumes the non-reserved keyword word, which the lexer
+// returns as an identifier.
+func (p *parser) expectWord(word string) error {
+	if p.cur.Type != TokenIdent || !strings.EqualFold(p.cur.Literal, word) {
+		return fmt.Errorf("expected %s, got %q at position %d", word, p.cur.Literal, p.cur.Pos)
+	}
+	p.next()
+	return nil
+}
+
+// isWord reports whether the current token is the non-reserved keyword
+// word.
+func (p *parser) isWord(word string) bool {
+	return p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, word)
+}
+
+// isSimilar reports whether the current token is SIMILAR, which is not a
+// reserved word; it only starts a SIMILAR TO predicate.
+func (p *parser) isSimilar() bool {
+	return p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "SIMILAR")
+}
+
+// mark saves the parser's position; the returned function goes back to it.
+func (p *parser) mark() func() {
+	pos, ch, width, cur := p.lexer.pos, p.lexer.ch, p.lexer.width, p.cur
+	return func() {
+		p.lexer.pos, p.lexer.ch, p.lexer.width, p.cur = pos, ch, width, cur
+	}
+}
+
+// acceptWordIf consumes the non-reserved keyword word if the token after it
+// satisfies follows, and reports whether it did.
+func (p *parser) acceptWordIf(word string, follows func() bool) bool {
+	if !p.isWord(word) {
+		return false
+	}
+	restore := p.mark()
+	p.next()
+	if follows() {
+		return true
+	}
+	restore()
+	return false
+}
+
 func (p *parser) unexpected() error {
 	if p.cur.Type == TokenEOF {
 		return fmt.Errorf("unexpected end of input")
@@ -73,14 +147,22 @@ func (p *parser) parseStatement() (Statement, error) {
 		return p.parseAlterTable()
 	case TokenInsert:
 		return p.parseInsert()
-	case TokenSelect:
-		return p.parseSelect()
+	case TokenSelect, TokenLParen:
+		return p.parseQuery()
 	case TokenUpdate:
 		return p.parseUpdate()
 	case TokenDelete:
 		return p.parseDelete()
 	case TokenShow:
 		return p.parseShow()
+	case TokenSet:
+		return p.parseSet()
+	case TokenExplain:
+		return p.parseExplain()
+	case TokenTruncate:
+		return p.parseTruncate()
+	case TokenValues:
+		return p.parseValues()
 	case TokenBegin:
 		p.next()
 		return &BeginStmt{}, nil
@@ -90,6 +172,28 @@ func (p *parser) parseStatement() (Statement, error) {
 	case TokenRollback:
 		p.next()
 		return &RollbackStmt{}, nil
+	case TokenIdent:
+		// ANALYZE is not reserved, so it arrives as an identifier.
+		if strings.EqualFold(p.cur.Literal, "ANALYZE") {
+			return p.parseAnalyze()
+		}
+		if p.isWord("VACUUM") {
+			return p.parseVacuum()
+		}
+		if p.isWord("CHECKPOINT") {
+			p.next()
+			return &CheckpointStmt{}, nil
+		}
+		if p.isWord("COPY") {
+			return p.parseCopy()
+		}
+		if p.isWord("BACKUP") {
+			return p.parseBackup()
+		}
+		if p.isWord("LISTEN") || p.isWord("UNLISTEN") || p.isWord("NOTIFY") {
+			return p.parseChannelStmt()
+		}
+		return nil, p.unexpected()
 	default:
 		return nil, p.unexpected()
 	}
@@ -126,8 +230,32 @@ func (p *parser) parseCreate() (Statement, error) {
 		}
 		return p.parseCreateIndex(true)
 	default:
+		if p.isWord("VIEW") {
+			return p.parseCreateView()
+		}
+		return nil, p.unexpected()
+	}
+}
+
+// parseCreateView parses: CREATE VIEW name AS query
+func (p *parser) parseCreateView() (*CreateViewStmt, error) {
+	p.next() // skip VIEW
+	ref, err := p.parseTableRef()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenAs); err != nil {
+		return nil, err
+	}
+	if p.cur.Type != TokenSelect && p.cur.Type != TokenLParen {
 		return nil, p.unexpected()
 	}
+	start := p.cur.Pos
+	query, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	return &CreateViewStmt{Name: ref, Query: query, QuerySQL: strings.TrimSpace(p.lexer.input[start:p.cur.Pos])}, nil
 }
 
 func (p *parser) parseCreateTable() (*CreateTableStmt, error) {
@@ -141,12 +269,21 @@ func (p *parser) parseCreateTable() (*CreateTableStmt, error) {
 	}
 
 	var columns []ColumnDef
+	var foreignKeys []tableForeignKey
 	for {
-		col, err := p.parseColumnDef()
-		if err != nil {
-			return nil, err
+		if p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "FOREIGN") {
+			fk, err := p.parseTableForeignKey()
+			if err != nil {
+				return nil, err
+			}
+			foreignKeys = append(foreignKeys, fk)
+		} else {
+			col, err := p.parseColumnDef()
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, col)
 		}
-		columns = append(columns, col)
 		if p.cur.Type != TokenComma {
 			break
 		}
@@ -157,6 +294,18 @@ func (p *parser) parseCreateTable() (*CreateTableStmt, error) {
 		return nil, err
 	}
 
+	// Attach table-level foreign keys to their columns.
+	for _, tfk := range foreignKeys {
+		i := slices.IndexFunc(columns, func(c ColumnDef) bool { return strings.EqualFold(c.Name, tfk.column) })
+		if i < 0 {
+			return nil, fmt.Errorf("column %q named in FOREIGN KEY does not exist", tfk.column)
+		}
+		if columns[i].References != nil {
+			return nil, fmt.Errorf("multiple foreign keys on column %q are not supported", tfk.column)
+		}
+		columns[i].References = tfk.fk
+	}
+
 	// Validate at most one column is marked PRIMARY KEY.
 	pkCount := 0
 	for _, col := range columns {
@@ -171,13 +320,51 @@ func (p *parser) parseCreateTable() (*CreateTableStmt, error) {
 	return &CreateTableStmt{Name: ref, Columns: columns}, nil
 }
 
+// tableForeignKey is a FOREIGN KEY table constraint before it is attached
+// to the column it names.
+type tableForeignKey struct {
+	column string
+	fk     *ForeignKey
+}
+
+// parseTableForeignKey parses FOREIGN KEY (column) REFERENCES ... in a
+// CREATE TABLE column list. Only single-column keys are supported.
+func (p *parser) parseTableForeignKey() (tableForeignKey, error) {
+	p.next() // consume FOREIGN
+	if _, err := p.expect(TokenKey); err != nil {
+		return tableForeignKey{}, err
+	}
+	if _, err := p.expect(TokenLParen); err != nil {
+		return tableForeignKey{}, err
+	}
+	col, err := p.expect(TokenIdent)
+	if err != nil {
+		return tableForeignKey{}, err
+	}
+	if p.cur.Type == TokenComma {
+		return tableForeignKey{}, fmt.Errorf("multi-column foreign keys are not supported at position %d", p.cur.Pos)
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return tableForeignKey{}, err
+	}
+	if p.cur.Type != TokenIdent || !strings.EqualFold(p.cur.Literal, "REFERENCES") {
+		return tableForeignKey{}, fmt.Errorf("expected REFERENCES, got %q at position %d", p.cur.Literal, p.cur.Pos)
+	}
+	p.next()
+	fk, err := p.parseReferences()
+	if err != nil {
+		return tableForeignKey{}, err
+	}
+	return tableForeignKey{column: col.Literal, fk: fk}, nil
+}
+
 func (p *parser) parseColumnDef() (ColumnDef, error) {
 	name, err := p.expect(TokenIdent)
 	if err != nil {
 		return ColumnDef{}, err
 	}
 
-	var dataType string
+	var dataType, identity string
 	switch p.cur.Type {
 	case TokenIntegerKW:
 		dataType = "INTEGER"
@@ -189,6 +376,8 @@ func (p *parser) parseColumnDef() (ColumnDef, error) {
 		dataType = "TIMESTAMP"
 	case TokenFloatKW:
 		dataType = "FLOAT"
+	case TokenByteaKW:
+		dataType = "BYTEA"
 	case TokenDoubleKW:
 		dataType = "FLOAT"
 		p.next() // consume DOUBLE
@@ -196,12 +385,34 @@ func (p *parser) parseColumnDef() (ColumnDef, error) {
 			return ColumnDef{}, fmt.Errorf("expected PRECISION after DOUBLE at position %d", p.cur.Pos)
 		}
 		// PRECISION will be consumed by the p.next() after the switch
+	case TokenIdent:
+		switch {
+		case isNumericTypeName(p.cur.Literal):
+			dataType = "NUMERIC"
+		case strings.EqualFold(p.cur.Literal, "TIME"):
+			dataType = "TIME"
+		case isSerialTypeName(p.cur.Literal):
+			// SERIAL is shorthand for an INTEGER column whose values are
+			// generated when an INSERT does not give one.
+			dataType = "INTEGER"
+			identity = "BY DEFAULT"
+		default:
+			return ColumnDef{}, fmt.Errorf("expected data type, got %q at position %d",
+				p.cur.Literal, p.cur.Pos)
+		}
 	default:
 		return ColumnDef{}, fmt.Errorf("expected data type, got %q at position %d",
 			p.cur.Literal, p.cur.Pos)
 	}
 	p.next()
 
+	var precision, scale int
+	if dataType == "NUMERIC" && p.cur.Type == TokenLParen {
+		if precision, scale, err = p.parseNumericTypmod(); err != nil {
+			return ColumnDef{}, err
+		}
+	}
+
 	// For TIMESTAMP, consume optional "WITH TIME ZONE" (all map to the same type).
 	if dataType == "TIMESTAMP" && p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "WITH") {
 		p.next() // consume WITH
@@ -215,10 +426,65 @@ func (p *parser) parseColumnDef() (ColumnDef, error) {
 		p.next() // consume ZONE
 	}
 
-	// Optional column constraints: PRIMARY KEY, NOT NULL (in any order).
-	var pk, notNull bool
+	// For TIME, consume optional "WITHOUT TIME ZONE", which is what TIME is.
+	if dataType == "TIME" && p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "WITHOUT") {
+		p.next() // consume WITHOUT
+		if p.cur.Type != TokenIdent || !strings.EqualFold(p.cur.Literal, "TIME") {
+			return ColumnDef{}, fmt.Errorf("expected TIME after WITHOUT at position %d", p.cur.Pos)
+		}
+		p.next() // consume TIME
+		if p.cur.Type != TokenIdent || !strings.EqualFold(p.cur.Literal, "ZONE") {
+			return ColumnDef{}, fmt.Errorf("expected ZONE after TIME at position %d", p.cur.Pos)
+		}
+		p.next() // consume ZONE
+	}
+
+	// Optional column constraints: PRIMARY KEY, NOT NULL, UNIQUE, DEFAULT,
+	// REFERENCES, GENERATED ... AS IDENTITY, STORAGE DICTIONARY (in any
+	// order).
+	notNull := identity != ""
+	var pk, unique, dictionary bool
+	var def Expr
+	var defSQL string
+	var fk *ForeignKey
 	for {
-		if p.cur.Type == TokenPrimary {
+		if p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "DEFAULT") {
+			p.next()
+			// Like PostgreSQL, the default is an arithmetic expression, so
+			// that a following NOT NULL is not taken for NOT LIKE etc.;
+			// anything else needs parentheses.
+			start := p.cur.Pos
+			def, err = p.parseAdditive()
+			if err != nil {
+				return ColumnDef{}, err
+			}
+			defSQL = strings.TrimSpace(p.lexer.input[start:p.cur.Pos])
+		} else if p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "REFERENCES") {
+			p.next()
+			if fk, err = p.parseReferences(); err != nil {
+				return ColumnDef{}, err
+			}
+		} else if p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "GENERATED") {
+			pos := p.cur.Pos
+			p.next()
+			if identity != "" {
+				return ColumnDef{}, fmt.Errorf("multiple identity specifications for column %q at position %d", name.Literal, pos)
+			}
+			if identity, err = p.parseIdentity(); err != nil {
+				return ColumnDef{}, err
+			}
+			notNull = true
+		} else if p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "STORAGE") {
+			p.next()
+			if !p.isWord("DICTIONARY") {
+				return ColumnDef{}, fmt.Errorf("expected DICTIONARY after STORAGE at position %d", p.cur.Pos)
+			}
+			p.next()
+			dictionary = true
+		} else if p.cur.Type == TokenUnique {
+			p.next()
+			unique = true
+		} else if p.cur.Type == TokenPrimary {
 			p.next()
 			if _, err := p.expect(TokenKey); err != nil {
 				return ColumnDef{}, err
@@ -236,7 +502,170 @@ func (p *parser) parseColumnDef() (ColumnDef, error) {
 		}
 	}
 
-	return ColumnDef{Name: name.Literal, DataType: dataType, PrimaryKey: pk, NotNull: notNull}, nil
+	if identity != "" {
+		if dataType != "INTEGER" {
+			return ColumnDef{}, fmt.Errorf("identity column %q must be INTEGER", name.Literal)
+		}
+		if def != nil {
+			return ColumnDef{}, fmt.Errorf("both default and identity specified for column %q", name.Literal)
+		}
+	}
+	if dictionary && dataType != "TEXT" {
+		return ColumnDef{}, fmt.Errorf("dictionary-encoded column %q must be TEXT", name.Literal)
+	}
+
+	return ColumnDef{Name: name.Literal, DataType: dataType, Precision: precision, Scale: scale, PrimaryKey: pk, NotNull: notNull, Unique: unique, Default: def, DefaultSQL: defSQL, References: fk, Identity: identity, Dictionary: dictionary}, nil
+}
+
+// parseIdentity parses the rest of an identity column constraint, GENERATED
+// already consumed: { ALWAYS | BY DEFAULT } AS IDENTITY. Sequence options
+// are not supported.
+func (p *parser) parseIdentity() (string, error) {
+	var identity string
+	switch {
+	case p.isWord("ALWAYS"):
+		p.next()
+		identity = "ALWAYS"
+	case p.cur.Type == TokenBy:
+		p.next() // consume BY
+		if !p.isWord("DEFAULT") {
+			return "", fmt.Errorf("expected DEFAULT after BY at position %d", p.cur.Pos)
+		}
+		p.next()
+		identity = "BY DEFAULT"
+	default:
+		return "", fmt.Errorf("expected ALWAYS or BY DEFAULT after GENERATED at position %d", p.cur.Pos)
+	}
+	if _, err := p.expect(TokenAs); err != nil {
+		return "", err
+	}
+	if !p.isWord("IDENTITY") {
+		return "", fmt.Errorf("expected IDENTITY at position %d", p.cur.Pos)
+	}
+	p.next()
+	if p.cur.Type == TokenLParen {
+		return "", fmt.Errorf("identity sequence options are not supported at position %d", p.cur.Pos)
+	}
+	return identity, nil
+}
+
+// isSerialTypeName reports whether word names one of the serial types.
+// All of them make an INTEGER column, which is 64 bits wide.
+func isSerialTypeName(word string) bool {
+	switch strings.ToUpper(word) {
+	case "SERIAL", "BIGSERIAL", "SMALLSERIAL", "SERIAL2", "SERIAL4", "SERIAL8":
+		return true
+	}
+	return false
+}
+
+// parseReferences parses the rest of a REFERENCES constraint, the keyword
+// already consumed: table [(column)] followed by ON DELETE and ON UPDATE
+// clauses in either order. ON UPDATE only takes the actions that reject
+// the change, which is how updates of a referenced key always behave.
+func (p *parser) parseReferences() (*ForeignKey, error) {
+	ref, err := p.parseTableRef()
+	if err != nil {
+		return nil, err
+	}
+	fk := &ForeignKey{Table: ref.Name, OnDelete: "NO ACTION"}
+	if p.cur.Type == TokenLParen {
+		p.next() // consume (
+		col, err := p.expect(TokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		fk.Column = col.Literal
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, err
+		}
+	}
+	for p.cur.Type == TokenOn {
+		p.next() // consume ON
+		onUpdate := p.cur.Type == TokenUpdate
+		if !onUpdate && p.cur.Type != TokenDelete {
+			return nil, fmt.Errorf("expected DELETE or UPDATE after ON at position %d", p.cur.Pos)
+		}
+		p.next()
+		pos := p.cur.Pos
+		action, err := p.parseReferentialAction()
+		if err != nil {
+			return nil, err
+		}
+		if !onUpdate {
+			fk.OnDelete = action
+		} else if action != "NO ACTION" && action != "RESTRICT" {
+			return nil, fmt.Errorf("ON UPDATE %s is not supported at position %d", action, pos)
+		}
+	}
+	return fk, nil
+}
+
+// parseReferentialAction parses CASCADE, RESTRICT, NO ACTION or SET NULL.
+func (p *parser) parseReferentialAction() (string, error) {
+	pos := p.cur.Pos
+	switch {
+	case p.cur.Type == TokenSet:
+		p.next() // consume SET
+		if p.cur.Type != TokenNull {
+			return "", fmt.Errorf("expected NULL after SET at position %d", p.cur.Pos)
+		}
+		p.next()
+		return "SET NULL", nil
+	case p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "NO"):
+		p.next() // consume NO
+		if p.cur.Type != TokenIdent || !strings.EqualFold(p.cur.Literal, "ACTION") {
+			return "", fmt.Errorf("expected ACTION after NO at position %d", p.cur.Pos)
+		}
+		p.next()
+		return "NO ACTION", nil
+	case p.cur.Type == TokenIdent && (strings.EqualFold(p.cur.Literal, "CASCADE") || strings.EqualFold(p.cur.Literal, "RESTRICT")):
+		action := strings.ToUpper(p.cur.Literal)
+		p.next()
+		return action, nil
+	}
+	return "", fmt.Errorf("expected CASCADE, RESTRICT, NO ACTION or SET NULL, got %q at position %d", p.cur.Literal, pos)
+}
+
+// isNumericTypeName reports whether word names the NUMERIC type. DECIMAL
+// and DEC are its standard synonyms.
+func isNumericTypeName(word string) bool {
+	switch strings.ToUpper(word) {
+	case "NUMERIC", "DECIMAL", "DEC":
+		return true
+	}
+	return false
+}
+
+// parseNumericTypmod parses the (precision[, scale]) after NUMERIC. The
+// precision must be 1 to 1000 and the scale 0 to the precision, as in
+// PostgreSQL; an omitted scale is 0.
+func (p *parser) parseNumericTypmod() (int, int, error) {
+	p.next() // consume (
+	precTok, err := p.expect(TokenIntLit)
+	if err != nil {
+		return 0, 0, err
+	}
+	precision, _ := strconv.Atoi(precTok.Literal)
+	if precision < 1 || precision > 1000 {
+		return 0, 0, fmt.Errorf("NUMERIC precision %s must be between 1 and 1000", precTok.Literal)
+	}
+	scale := 0
+	if p.cur.Type == TokenComma {
+		p.next()
+		scaleTok, err := p.expect(TokenIntLit)
+		if err != nil {
+			return 0, 0, err
+		}
+		scale, _ = strconv.Atoi(scaleTok.Literal)
+		if scale > precision {
+			return 0, 0, fmt.Errorf("NUMERIC scale %d must be between 0 and precision %d", scale, precision)
+		}
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return 0, 0, err
+	}
+	return precision, scale, nil
 }
 
 func (p *parser) parseDrop() (Statement, error) {
@@ -247,6 +676,14 @@ func (p *parser) parseDrop() (Statement, error) {
 	case TokenIndex:
 		return p.parseDropIndex()
 	default:
+		if p.isWord("VIEW") {
+			p.next() // skip VIEW
+			ref, err := p.parseTableRef()
+			if err != nil {
+				return nil, err
+			}
+			return &DropViewStmt{Name: ref}, nil
+		}
 		return nil, p.unexpected()
 	}
 }
@@ -260,7 +697,83 @@ func (p *parser) parseDropTable() (*DropTableStmt, error) {
 	return &DropTableStmt{Name: ref}, nil
 }
 
-// parseCreateIndex parses: [name] ON table(column)
+// parseTruncate parses: TRUNCATE [TABLE] table [, ...] [RESTART IDENTITY | CONTINUE IDENTITY]
+func (p *parser) parseTruncate() (*TruncateStmt, error) {
+	p.next() // skip TRUNCATE
+	if p.cur.Type == TokenTable {
+		p.next()
+	}
+	stmt := &TruncateStmt{}
+	for {
+		ref, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Tables = append(stmt.Tables, ref)
+		if p.cur.Type != TokenComma {
+			break
+		}
+		p.next()
+	}
+	if p.isWord("RESTART") || p.isWord("CONTINUE") {
+		stmt.RestartIdentity = p.isWord("RESTART")
+		p.next()
+		if !p.isWord("IDENTITY") {
+			return nil, fmt.Errorf("expected IDENTITY at position %d", p.cur.Pos)
+		}
+		p.next()
+	}
+	return stmt, nil
+}
+
+// parseValues parses a standalone VALUES (exprs) [, (exprs) ...].
+func (p *parser) parseValues() (*ValuesStmt, error) {
+	p.next() // skip VALUES
+	var rows [][]Expr
+	for {
+		row, err := p.parseParenExprList()
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) > 0 && len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("VALUES lists must all be the same length")
+		}
+		rows = append(rows, row)
+		if p.cur.Type != TokenComma {
+			break
+		}
+		p.next()
+	}
+	return &ValuesStmt{Rows: rows}, nil
+}
+
+// parseAnalyze parses ANALYZE [table].
+func (p *parser) parseAnalyze() (*AnalyzeStmt, error) {
+	p.next() // skip ANALYZE
+	if p.cur.Type != TokenIdent {
+		return &AnalyzeStmt{}, nil
+	}
+	ref, err := p.parseTableRef()
+	if err != nil {
+		return nil, err
+	}
+	return &AnalyzeStmt{Table: ref}, nil
+}
+
+// parseVacuum parses VACUUM [table].
+func (p *parser) parseVacuum() (*VacuumStmt, error) {
+	p.next() // skip VACUUM
+	if p.cur.Type != TokenIdent {
+		return &VacuumStmt{}, nil
+	}
+	ref, err := p.parseTableRef()
+	if err != nil {
+		return nil, err
+	}
+	return &VacuumStmt{Table: ref}, nil
+}
+
+// parseCreateIndex parses: [name] ON table(column [, ...])
 // The INDEX keyword has already been consumed.
 func (p *parser) parseCreateIndex(unique bool) (*CreateIndexStmt, error) {
 	var name string
@@ -282,18 +795,26 @@ func (p *parser) parseCreateIndex(unique bool) (*CreateIndexStmt, error) {
 	if _, err := p.expect(TokenLParen); err != nil {
 		return nil, err
 	}
-	col, err := p.expect(TokenIdent)
-	if err != nil {
-		return nil, err
+	var cols []string
+	for {
+		col, err := p.expect(TokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, col.Literal)
+		if p.cur.Type != TokenComma {
+			break
+		}
+		p.next()
 	}
 	if _, err := p.expect(TokenRParen); err != nil {
 		return nil, err
 	}
 	return &CreateIndexStmt{
-		Name:   name,
-		Table:  ref,
-		Column: col.Literal,
-		Unique: unique,
+		Name:    name,
+		Table:   ref,
+		Columns: cols,
+		Unique:  unique,
 	}, nil
 }
 
@@ -359,16 +880,320 @@ func (p *parser) parseAlterTable() (Statement, error) {
 	}
 }
 
-func (p *parser) parseShow() (Statement, error) {
-	p.next() // skip SHOW
-	switch p.cur.Type {
-	case TokenMemory:
+func (p *parser) parseExplain() (Statement, error) {
+	p.next() // skip EXPLAIN
+	// ANALYZE is matched as an identifier so it stays usable as a name.
+	analyze := false
+	if p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "ANALYZE") {
+		analyze = true
+		p.next()
+	}
+	var inner Statement
+	var err error
+	switch p.cur.Type {
+	case TokenSelect:
+		inner, err = p.parseSelect()
+	case TokenUpdate:
+		inner, err = p.parseUpdate()
+	case TokenDelete:
+		inner, err = p.parseDelete()
+	default:
+		return nil, fmt.Errorf("expected SELECT, UPDATE or DELETE after EXPLAIN, got %q at position %d",
+			p.cur.Literal, p.cur.Pos)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ExplainStmt{Analyze: analyze, Stmt: inner}, nil
+}
+
+// parseBackup parses: BACKUP TO 'file' or BACKUP TO DIRECTORY 'dir'.
+func (p *parser) parseBackup() (*BackupStmt, error) {
+	p.next() // skip BACKUP
+	if err := p.expectWord("TO"); err != nil {
+		return nil, err
+	}
+	stmt := &BackupStmt{}
+	if p.isWord("DIRECTORY") {
+		p.next()
+		stmt.Directory = true
+	}
+	tok, err := p.expect(TokenStrLit)
+	if err != nil {
+		return nil, err
+	}
+	stmt.Path = tok.Literal
+	return stmt, nil
+}
+
+// parseChannelStmt parses: LISTEN channel, UNLISTEN channel | *, or
+// NOTIFY channel [, 'payload'].
+func (p *parser) parseChannelStmt() (Statement, error) {
+	word := strings.ToUpper(p.cur.Literal)
+	p.next()
+	if word == "UNLISTEN" && p.cur.Type == TokenStar {
+		p.next()
+		return &UnlistenStmt{}, nil
+	}
+	channel, err := p.expect(TokenIdent)
+	if err != nil {
+		return nil, err
+	}
+	switch word {
+	case "LISTEN":
+		return &ListenStmt{Channel: channel.Literal}, nil
+	case "UNLISTEN":
+		return &UnlistenStmt{Channel: channel.Literal}, nil
+	}
+	stmt := &NotifyStmt{Channel: channel.Literal}
+	if p.cur.Type == TokenComma {
+		p.next()
+		payload, err := p.expect(TokenStrLit)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Payload = payload.Literal
+	}
+	return stmt, nil
+}
+
+// parseCopy parses: COPY table [( columns )] FROM STDIN | TO STDOUT, or
+// COPY ( query ) TO STDOUT, followed by the options either as a list,
+// [WITH] ( FORMAT text | csv [, HEADER [boolean]] [, NULL 'marker'] ), or
+// as [WITH] [NULL [AS] 'marker'] [CSV [HEADER]].
+func (p *parser) parseCopy() (*CopyStmt, error) {
+	p.next() // skip COPY
+	stmt := &CopyStmt{Format: "text"}
+	if p.cur.Type == TokenLParen {
+		p.next() // skip (
+		query, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, err
+		}
+		stmt.Query = query
+	} else {
+		ref, err := p.parseTableRef()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Table = ref
+		if p.cur.Type == TokenLParen {
+			p.next()
+			for {
+				col, err := p.expect(TokenIdent)
+				if err != nil {
+					return nil, err
+				}
+				stmt.Columns = append(stmt.Columns, col.Literal)
+				if p.cur.Type != TokenComma {
+					break
+				}
+				p.next()
+			}
+			if _, err := p.expect(TokenRParen); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch {
+	case p.cur.Type == TokenFrom && stmt.Query == nil:
+		p.next()
+		if err := p.expectWord("STDIN"); err != nil {
+			return nil, err
+		}
+		stmt.From = true
+	case p.isWord("TO"):
+		p.next()
+		if err := p.expectWord("STDOUT"); err != nil {
+			return nil, err
+		}
+	case stmt.Query != nil:
+		return nil, fmt.Errorf("expected TO STDOUT after COPY query, got %q at position %d", p.cur.Literal, p.cur.Pos)
+	default:
+		return nil, fmt.Errorf("expected FROM STDIN or TO STDOUT, got %q at position %d", p.cur.Literal, p.cur.Pos)
+	}
+
+	if p.isWord("WITH") {
+		p.next()
+	}
+	if p.cur.Type == TokenNull {
+		// PostgreSQL's older syntax: NULL [AS] 'marker' [CSV [HEADER]].
+		p.next()
+		if p.cur.Type == TokenAs {
+			p.next()
+		}
+		tok, err := p.expect(TokenStrLit)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Null = &tok.Literal
+	}
+	switch {
+	case p.isWord("CSV"):
+		p.next()
+		stmt.Format = "csv"
+		if p.isWord("HEADER") {
+			p.next()
+			stmt.Header = true
+		}
+	case p.cur.Type == TokenLParen:
+		p.next()
+		seen := map[string]bool{}
+		for {
+			opt := strings.ToUpper(p.cur.Literal)
+			if seen[opt] {
+				return nil, fmt.Errorf("conflicting or redundant COPY option %s at position %d", opt, p.cur.Pos)
+			}
+			seen[opt] = true
+			switch {
+			case p.isWord("FORMAT"):
+				p.next()
+				switch {
+				case p.cur.Type == TokenTextKW:
+					stmt.Format = "text"
+				case p.isWord("CSV"):
+					stmt.Format = "csv"
+				default:
+					return nil, fmt.Errorf("COPY format %q not recognized at position %d", p.cur.Literal, p.cur.Pos)
+				}
+				p.next()
+			case p.isWord("HEADER"):
+				p.next()
+				stmt.Header = true
+				switch {
+				case p.cur.Type == TokenTrue || p.cur.Type == TokenOn:
+					p.next()
+				case p.cur.Type == TokenFalse || p.isWord("OFF"):
+					p.next()
+					stmt.Header = false
+				}
+			case p.cur.Type == TokenNull && stmt.Null == nil:
+				p.next()
+				tok, err := p.expect(TokenStrLit)
+				if err != nil {
+					return nil, err
+				}
+				stmt.Null = &tok.Literal
+			default:
+				return nil, fmt.Errorf("COPY option %q not recognized at position %d", p.cur.Literal, p.cur.Pos)
+			}
+			if p.cur.Type != TokenComma {
+				break
+			}
+			p.next()
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseShow() (Statement, error) {
+	p.next() // skip SHOW
+	switch p.cur.Type {
+	case TokenMemory:
 		p.next() // consume MEMORY
 		return &ShowMemoryStmt{}, nil
 	default:
-		return nil, fmt.Errorf("expected MEMORY after SHOW, got %q at position %d",
-			p.cur.Literal, p.cur.Pos)
+		name, err := p.parseParamName()
+		if err != nil {
+			return nil, err
+		}
+		return &ShowStmt{Name: name}, nil
+	}
+}
+
+// parseSet parses SET [SESSION | LOCAL] name {= | TO} value [, value ...].
+// SET TIME ZONE and SET TRANSACTION do not fit this form and are rejected.
+func (p *parser) parseSet() (*SetStmt, error) {
+	p.next() // skip SET
+	stmt := &SetStmt{}
+	switch {
+	case p.isWord("LOCAL"):
+		stmt.Local = true
+		p.next()
+	case p.isWord("SESSION"):
+		p.next()
+	}
+	name, err := p.parseParamName()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Name = name
+	if p.cur.Type != TokenEq && !p.isWord("TO") {
+		return nil, fmt.Errorf("expected = or TO after SET %s, got %q at position %d",
+			name, p.cur.Literal, p.cur.Pos)
+	}
+	p.next()
+
+	var values []string
+	for {
+		v, err := p.parseParamValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		if p.cur.Type != TokenComma {
+			break
+		}
+		p.next()
+	}
+	stmt.Value = strings.Join(values, ", ")
+	return stmt, nil
+}
+
+// parseParamName parses a run-time parameter name: an identifier,
+// optionally qualified as in "myapp.user_id". The name is lowercased.
+func (p *parser) parseParamName() (string, error) {
+	tok, err := p.expect(TokenIdent)
+	if err != nil {
+		return "", err
+	}
+	name := strings.ToLower(tok.Literal)
+	for p.cur.Type == TokenDot {
+		p.next()
+		tok, err := p.expect(TokenIdent)
+		if err != nil {
+			return "", err
+		}
+		name += "." + strings.ToLower(tok.Literal)
+	}
+	return name, nil
+}
+
+// parseParamValue parses one value of a SET list. String literals are
+// kept as written; words such as on, off or DEFAULT are lowercased.
+func (p *parser) parseParamValue() (string, error) {
+	tok := p.cur
+	switch tok.Type {
+	case TokenStrLit:
+		p.next()
+		return tok.Literal, nil
+	case TokenIntLit, TokenFloatLit:
+		p.next()
+		return tok.Literal, nil
+	case TokenMinus:
+		p.next()
+		if p.cur.Type != TokenIntLit && p.cur.Type != TokenFloatLit {
+			return "", p.unexpected()
+		}
+		lit := p.cur.Literal
+		p.next()
+		return "-" + lit, nil
+	case TokenIdent:
+		p.next()
+		return strings.ToLower(tok.Literal), nil
 	}
+	if _, ok := keywords[strings.ToUpper(tok.Literal)]; ok && tok.Literal != "" {
+		p.next()
+		return strings.ToLower(tok.Literal), nil
+	}
+	return "", p.unexpected()
 }
 
 func (p *parser) parseInsert() (*InsertStmt, error) {
@@ -401,24 +1226,158 @@ func (p *parser) parseInsert() (*InsertStmt, error) {
 		}
 	}
 
-	if _, err := p.expect(TokenValues); err != nil {
+	// DEFAULT VALUES inserts one row of defaults: an empty row, which the
+	// executor pads with every column's default.
+	var values [][]Expr
+	if p.atDefault() {
+		if columns != nil {
+			return nil, fmt.Errorf("unexpected DEFAULT VALUES after column list at position %d", p.cur.Pos)
+		}
+		p.next()
+		if _, err := p.expect(TokenValues); err != nil {
+			return nil, err
+		}
+		values = [][]Expr{{}}
+	} else {
+		if _, err := p.expect(TokenValues); err != nil {
+			return nil, err
+		}
+		for {
+			row, err := p.parseValuesRow()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, row)
+			if p.cur.Type != TokenComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	var onConflict *OnConflictClause
+	if p.cur.Type == TokenOn {
+		onConflict, err = p.parseOnConflict()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	returning, err := p.parseOptionalReturning()
+	if err != nil {
 		return nil, err
 	}
 
-	var values [][]Expr
+	return &InsertStmt{Table: ref, Columns: columns, Values: values, OnConflict: onConflict, Returning: returning}, nil
+}
+
+// parseOnConflict parses ON CONFLICT [(column)] DO NOTHING | DO UPDATE SET
+// assignments. CONFLICT, DO and NOTHING are not reserved words.
+func (p *parser) parseOnConflict() (*OnConflictClause, error) {
+	p.next() // consume ON
+	if err := p.expectWord("CONFLICT"); err != nil {
+		return nil, err
+	}
+	oc := &OnConflictClause{}
+	if p.cur.Type == TokenLParen {
+		p.next()
+		col, err := p.expect(TokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		oc.Column = col.Literal
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectWord("DO"); err != nil {
+		return nil, err
+	}
+	if p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "NOTHING") {
+		p.next()
+		return oc, nil
+	}
+	if _, err := p.expect(TokenUpdate); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenSet); err != nil {
+		return nil, err
+	}
+	if oc.Column == "" {
+		return nil, fmt.Errorf("ON CONFLICT DO UPDATE requires a conflict target column at position %d", p.cur.Pos)
+	}
+	sets, err := p.parseSetClauses()
+	if err != nil {
+		return nil, err
+	}
+	oc.Sets = sets
+	return oc, nil
+}
+
+// parseSetClauses parses a comma-separated list of column = expr
+// assignments, as in UPDATE ... SET.
+func (p *parser) parseSetClauses() ([]SetClause, error) {
+	var sets []SetClause
 	for {
-		row, err := p.parseParenExprList()
+		col, err := p.expect(TokenIdent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(TokenEq); err != nil {
+			return nil, err
+		}
+		val, err := p.parseValueOrDefault()
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, SetClause{Column: col.Literal, Value: val})
+		if p.cur.Type != TokenComma {
+			break
+		}
+		p.next()
+	}
+	return sets, nil
+}
+
+// parseValuesRow parses a parenthesized INSERT row, whose items may be
+// DEFAULT.
+func (p *parser) parseValuesRow() ([]Expr, error) {
+	if _, err := p.expect(TokenLParen); err != nil {
+		return nil, err
+	}
+	var exprs []Expr
+	for {
+		expr, err := p.parseValueOrDefault()
 		if err != nil {
 			return nil, err
 		}
-		values = append(values, row)
+		exprs = append(exprs, expr)
 		if p.cur.Type != TokenComma {
 			break
 		}
 		p.next()
 	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, err
+	}
+	return exprs, nil
+}
 
-	return &InsertStmt{Table: ref, Columns: columns, Values: values}, nil
+// parseValueOrDefault parses an expression or the DEFAULT keyword, as
+// allowed in INSERT rows and SET assignments.
+func (p *parser) parseValueOrDefault() (Expr, error) {
+	if p.atDefault() {
+		p.next()
+		return &DefaultExpr{}, nil
+	}
+	return p.parseExpr()
+}
+
+// atDefault reports whether the current token is an unquoted DEFAULT.
+// DEFAULT is not a reserved word here, so "default" still names a column.
+func (p *parser) atDefault() bool {
+	return p.cur.Type == TokenIdent && strings.EqualFold(p.cur.Literal, "DEFAULT") &&
+		p.lexer.input[p.cur.Pos] != '"'
 }
 
 func (p *parser) parseParenExprList() ([]Expr, error) {
@@ -448,39 +1407,124 @@ func (p *parser) parseSelect() (*SelectStmt, error) {
 	return p.parseSelectBody()
 }
 
-// parseSelectBody parses everything after the SELECT keyword: columns, FROM, WHERE, etc.
-func (p *parser) parseSelectBody() (*SelectStmt, error) {
-	var columns []Expr
+// parseQuery parses a SELECT or SELECTs combined with UNION, INTERSECT and
+// EXCEPT, each of which may be parenthesized. INTERSECT binds more tightly
+// than UNION and EXCEPT, and operators of the same precedence group from
+// the left. ORDER BY, LIMIT and OFFSET after the last SELECT apply to the
+// combined result.
+func (p *parser) parseQuery() (Statement, error) {
+	q, last, err := p.parseSetOps(false)
+	if err != nil {
+		return nil, err
+	}
+	top, ok := q.(*SetOpStmt)
+	if !ok {
+		return q, nil
+	}
+	tail := last
+	if tail == nil {
+		// The last query was parenthesized, so the clauses are still to come.
+		tail = &SelectStmt{}
+		if err := p.parseSelectTail(tail); err != nil {
+			return nil, err
+		}
+	}
+	top.OrderBy, top.Limit, top.Offset = tail.OrderBy, tail.Limit, tail.Offset
+	top.LimitExpr, top.OffsetExpr = tail.LimitExpr, tail.OffsetExpr
+	tail.OrderBy, tail.Limit, tail.Offset, tail.LimitExpr, tail.OffsetExpr = nil, nil, nil, nil, nil
+	return top, nil
+}
+
+// parseSetOps parses queries joined by INTERSECT or, unless intersect is
+// set, by any set operator. It also returns the last query if that is a
+// SELECT without parentheses, whose ORDER BY, LIMIT and OFFSET belong to
+// the whole query.
+func (p *parser) parseSetOps(intersect bool) (Statement, *SelectStmt, error) {
+	var left Statement
+	var last *SelectStmt
+	var err error
+	if intersect {
+		left, last, err = p.parseSetOperand()
+	} else {
+		left, last, err = p.parseSetOps(true)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 	for {
-		if p.cur.Type == TokenStar {
-			columns = append(columns, &StarExpr{})
+		var op string
+		switch {
+		case p.isWord("INTERSECT"):
+			op = "INTERSECT"
+		case !intersect && p.isWord("UNION"):
+			op = "UNION"
+		case !intersect && p.isWord("EXCEPT"):
+			op = "EXCEPT"
+		default:
+			return left, last, nil
+		}
+		if last != nil && (last.OrderBy != nil || last.Limit != nil || last.Offset != nil ||
+			last.LimitExpr != nil || last.OffsetExpr != nil) {
+			return nil, nil, fmt.Errorf("ORDER BY, LIMIT and OFFSET must follow the last query of %s at position %d", op, p.cur.Pos)
+		}
+		p.next() // consume the operator
+		all := p.isWord("ALL")
+		if all || p.isWord("DISTINCT") {
 			p.next()
+		}
+		var right Statement
+		if intersect {
+			right, last, err = p.parseSetOperand()
 		} else {
-			expr, err := p.parseExpr()
-			if err != nil {
-				return nil, err
-			}
-			if p.cur.Type == TokenAs {
-				p.next() // consume AS
-				alias, err := p.expect(TokenIdent)
-				if err != nil {
-					return nil, err
-				}
-				expr = &AliasExpr{Expr: expr, Alias: alias.Literal}
-			}
-			columns = append(columns, expr)
+			right, last, err = p.parseSetOps(true)
 		}
-		if p.cur.Type != TokenComma {
-			break
+		if err != nil {
+			return nil, nil, err
 		}
+		left = &SetOpStmt{Op: op, All: all, Left: left, Right: right}
+	}
+}
+
+// parseSetOperand parses a SELECT or a parenthesized query. The SELECT is
+// also returned as the last query for parseSetOps.
+func (p *parser) parseSetOperand() (Statement, *SelectStmt, error) {
+	switch p.cur.Type {
+	case TokenSelect:
+		s, err := p.parseSelect()
+		return s, s, err
+	case TokenLParen:
+		p.next() // consume (
+		q, err := p.parseQuery()
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, nil, err
+		}
+		return q, nil, nil
+	default:
+		return nil, nil, p.unexpected()
+	}
+}
+
+// parseSelectBody parses everything after the SELECT keyword: columns, FROM, WHERE, etc.
+func (p *parser) parseSelectBody() (*SelectStmt, error) {
+	distinct := p.isWord("DISTINCT")
+	if distinct || p.isWord("ALL") {
 		p.next()
+		if distinct && p.cur.Type == TokenOn {
+			return nil, fmt.Errorf("DISTINCT ON is not supported at position %d", p.cur.Pos)
+		}
+	}
+	columns, err := p.parseSelectList()
+	if err != nil {
+		return nil, err
 	}
 
 	var from TableRef
 	var fromAlias string
 	var indexedBy string
 	var joins []JoinClause
-	var err error
 	if p.cur.Type == TokenFrom {
 		p.next() // consume FROM
 		from, err = p.parseTableRef()
@@ -550,48 +1594,239 @@ func (p *parser) parseSelectBody() (*SelectStmt, error) {
 		}
 	}
 
-	// Parse optional GROUP BY col [, col, ...]
+	// Parse optional GROUP BY col [, col, ...], with ROLLUP, CUBE and
+	// GROUPING SETS.
 	var groupBy []Expr
+	var groupingSets [][]int
 	if p.cur.Type == TokenGroup {
 		p.next() // consume GROUP
 		if _, err := p.expect(TokenBy); err != nil {
 			return nil, err
 		}
+		if groupBy, groupingSets, err = p.parseGroupBy(); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &SelectStmt{
+		Distinct:  distinct,
+		Columns:   columns,
+		From:      from,
+		FromAlias: fromAlias,
+		IndexedBy: indexedBy,
+		Joins:     joins,
+		Where:     where,
+		GroupBy:   groupBy,
+
+		GroupingSets: groupingSets,
+	}
+	if err := p.parseSelectTail(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// maxGroupingSets caps the number of grouping sets a GROUP BY may expand
+// to, as in PostgreSQL.
+const maxGroupingSets = 4096
+
+// parseGroupBy parses the list after GROUP BY. For a plain list of
+// expressions exprs holds them in order and sets is nil. With ROLLUP,
+// CUBE, GROUPING SETS or (), each element of the list stands for a list of
+// grouping sets and the query groups by every combination of one set from
+// each element; exprs then holds each distinct expression once and sets
+// the grouping sets, as indexes into exprs.
+func (p *parser) parseGroupBy() (exprs []Expr, sets [][]int, err error) {
+	combined := [][]Expr{nil}
+	extended := false
+	for {
+		elem, ext, err := p.parseGroupingElement()
+		if err != nil {
+			return nil, nil, err
+		}
+		extended = extended || ext
+		var next [][]Expr
+		for _, set := range combined {
+			for _, more := range elem {
+				next = append(next, append(slices.Clip(set), more...))
+			}
+		}
+		if len(next) > maxGroupingSets {
+			return nil, nil, fmt.Errorf("too many grouping sets present (maximum %d)", maxGroupingSets)
+		}
+		combined = next
+		if p.cur.Type != TokenComma {
+			break
+		}
+		p.next() // consume comma
+	}
+	if !extended {
+		return combined[0], nil, nil
+	}
+	for _, set := range combined {
+		idxs := []int{}
+		for _, expr := range set {
+			i := slices.IndexFunc(exprs, func(x Expr) bool { return reflect.DeepEqual(x, expr) })
+			if i < 0 {
+				i = len(exprs)
+				exprs = append(exprs, expr)
+			}
+			if !slices.Contains(idxs, i) {
+				idxs = append(idxs, i)
+			}
+		}
+		sets = append(sets, idxs)
+	}
+	return exprs, sets, nil
+}
+
+// parseGroupingElement parses one element of a GROUP BY list into the
+// grouping sets it stands for: ROLLUP (a, b) for (a, b), (a) and (); CUBE
+// (a, b) for every subset of a and b, largest first; GROUPING SETS (...)
+// for the sets it lists; and a grouping set for itself. extended reports
+// whether the element goes beyond a plain list of expressions.
+func (p *parser) parseGroupingElement() (sets [][]Expr, extended bool, err error) {
+	lparen := func() bool { return p.cur.Type == TokenLParen }
+	switch {
+	case p.isWord("ROLLUP") || p.isWord("CUBE"):
+		cube := p.isWord("CUBE")
+		if !p.acceptWordIf(p.cur.Literal, lparen) {
+			break
+		}
+		p.next() // consume (
+		var items [][]Expr
+		for {
+			item, err := p.parseGroupingSet()
+			if err != nil {
+				return nil, false, err
+			}
+			items = append(items, item)
+			if p.cur.Type != TokenComma {
+				break
+			}
+			p.next() // consume comma
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, false, err
+		}
+		if !cube {
+			for n := len(items); n >= 0; n-- {
+				sets = append(sets, slices.Concat(items[:n]...))
+			}
+			return sets, true, nil
+		}
+		if len(items) > 12 {
+			return nil, false, fmt.Errorf("CUBE is limited to 12 elements")
+		}
+		for mask := 1<<len(items) - 1; mask >= 0; mask-- {
+			set := []Expr{}
+			for i, item := range items {
+				if mask&(1<<(len(items)-1-i)) != 0 {
+					set = append(set, item...)
+				}
+			}
+			sets = append(sets, set)
+		}
+		return sets, true, nil
+	case p.acceptWordIf("GROUPING", func() bool { return p.isWord("SETS") }):
+		p.next() // consume SETS
+		if _, err := p.expect(TokenLParen); err != nil {
+			return nil, false, err
+		}
+		for {
+			elem, _, err := p.parseGroupingElement()
+			if err != nil {
+				return nil, false, err
+			}
+			sets = append(sets, elem...)
+			if len(sets) > maxGroupingSets {
+				return nil, false, fmt.Errorf("too many grouping sets present (maximum %d)", maxGroupingSets)
+			}
+			if p.cur.Type != TokenComma {
+				break
+			}
+			p.next() // consume comma
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, false, err
+		}
+		return sets, true, nil
+	}
+	set, err := p.parseGroupingSet()
+	if err != nil {
+		return nil, false, err
+	}
+	return [][]Expr{set}, len(set) == 0, nil
+}
+
+// parseGroupingSet parses a single expression, or a parenthesized list of
+// expressions that may be empty. A parenthesized single expression is
+// parsed as an expression, so (a + b) * 2 groups by the product.
+func (p *parser) parseGroupingSet() ([]Expr, error) {
+	if p.cur.Type == TokenLParen {
+		restore := p.mark()
+		p.next() // consume (
+		if p.cur.Type == TokenRParen {
+			p.next()
+			return []Expr{}, nil
+		}
+		var set []Expr
 		for {
 			expr, err := p.parseExpr()
 			if err != nil {
 				return nil, err
 			}
-			groupBy = append(groupBy, expr)
+			set = append(set, expr)
 			if p.cur.Type != TokenComma {
 				break
 			}
 			p.next() // consume comma
 		}
+		if len(set) > 1 {
+			if _, err := p.expect(TokenRParen); err != nil {
+				return nil, err
+			}
+			return set, nil
+		}
+		restore()
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
 	}
+	return []Expr{expr}, nil
+}
 
-	// Parse optional ORDER BY col [ASC|DESC] [, col [ASC|DESC], ...]
-	var orderBy []OrderByClause
+// parseSelectTail parses the ORDER BY, LIMIT and OFFSET clauses that may
+// end a SELECT, or a set operation, into s.
+func (p *parser) parseSelectTail(s *SelectStmt) error {
+	// Parse optional ORDER BY key [ASC|DESC] [NULLS FIRST|LAST] [, ...],
+	// where a key is a column, possibly qualified, the position of a
+	// select-list column, or an expression.
 	if p.cur.Type == TokenOrder {
 		p.next() // consume ORDER
 		if _, err := p.expect(TokenBy); err != nil {
-			return nil, err
+			return err
 		}
 		for {
-			col, err := p.expect(TokenIdent)
+			start := p.cur.Pos
+			key, err := p.parseExpr()
 			if err != nil {
-				return nil, err
+				return err
 			}
-			clause := OrderByClause{Column: col.Literal}
-			// Check for qualified name: table.column
-			if p.cur.Type == TokenDot {
-				p.next() // consume dot
-				second, err := p.expect(TokenIdent)
-				if err != nil {
-					return nil, err
+			var clause OrderByClause
+			switch k := key.(type) {
+			case *ColumnRef:
+				clause.Table, clause.Column = k.Table, k.Name
+			case *IntegerLit:
+				if k.Value < 1 {
+					return fmt.Errorf("ORDER BY position %d is not in select list at position %d", k.Value, start)
 				}
-				clause.Table = clause.Column
-				clause.Column = second.Literal
+				clause.Position = int(k.Value)
+				clause.ExprSQL = strings.TrimSpace(p.lexer.input[start:p.cur.Pos])
+			default:
+				clause.Expr = key
+				clause.ExprSQL = strings.TrimSpace(p.lexer.input[start:p.cur.Pos])
 			}
 			if p.cur.Type == TokenDesc {
 				clause.Desc = true
@@ -599,7 +1834,16 @@ func (p *parser) parseSelectBody() (*SelectStmt, error) {
 			} else if p.cur.Type == TokenAsc {
 				p.next()
 			}
-			orderBy = append(orderBy, clause)
+			if p.isWord("NULLS") {
+				p.next()
+				first := p.isWord("FIRST")
+				if !first && !p.isWord("LAST") {
+					return fmt.Errorf("expected FIRST or LAST after NULLS, got %q at position %d", p.cur.Literal, p.cur.Pos)
+				}
+				p.next()
+				clause.NullsFirst = &first
+			}
+			s.OrderBy = append(s.OrderBy, clause)
 			if p.cur.Type != TokenComma {
 				break
 			}
@@ -607,48 +1851,82 @@ func (p *parser) parseSelectBody() (*SelectStmt, error) {
 		}
 	}
 
-	// Parse optional LIMIT and OFFSET (in either order).
-	var limit, offset *int64
+	// Parse optional LIMIT and OFFSET (in either order). A plain integer
+	// literal is stored directly; anything else is kept as an expression
+	// for the executor to evaluate.
 	for i := 0; i < 2; i++ {
-		if p.cur.Type == TokenLimit && limit == nil {
+		if p.cur.Type == TokenLimit && s.Limit == nil && s.LimitExpr == nil {
 			p.next()
-			tok, err := p.expect(TokenIntLit)
+			expr, err := p.parseExpr()
 			if err != nil {
-				return nil, err
+				return err
+			}
+			if lit, ok := expr.(*IntegerLit); ok {
+				v := lit.Value
+				s.Limit = &v
+			} else {
+				s.LimitExpr = expr
 			}
-			v, err := strconv.ParseInt(tok.Literal, 10, 64)
+		} else if p.cur.Type == TokenOffset && s.Offset == nil && s.OffsetExpr == nil {
+			p.next()
+			expr, err := p.parseExpr()
 			if err != nil {
-				return nil, fmt.Errorf("invalid LIMIT value %q: %w", tok.Literal, err)
+				return err
+			}
+			if lit, ok := expr.(*IntegerLit); ok {
+				v := lit.Value
+				s.Offset = &v
+			} else {
+				s.OffsetExpr = expr
 			}
-			limit = &v
-		} else if p.cur.Type == TokenOffset && offset == nil {
+		} else {
+			break
+		}
+	}
+
+	return nil
+}
+
+// parseSelectList parses a comma-separated list of output expressions, each
+// either * or an expression with an optional AS alias. It is shared by the
+// SELECT list and RETURNING.
+func (p *parser) parseSelectList() ([]Expr, error) {
+	var columns []Expr
+	for {
+		if p.cur.Type == TokenStar {
+			columns = append(columns, &StarExpr{})
 			p.next()
-			tok, err := p.expect(TokenIntLit)
+		} else {
+			expr, err := p.parseExpr()
 			if err != nil {
 				return nil, err
 			}
-			v, err := strconv.ParseInt(tok.Literal, 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid OFFSET value %q: %w", tok.Literal, err)
+			if p.cur.Type == TokenAs {
+				p.next() // consume AS
+				alias, err := p.expect(TokenIdent)
+				if err != nil {
+					return nil, err
+				}
+				expr = &AliasExpr{Expr: expr, Alias: alias.Literal}
 			}
-			offset = &v
-		} else {
+			columns = append(columns, expr)
+		}
+		if p.cur.Type != TokenComma {
 			break
 		}
+		p.next()
 	}
+	return columns, nil
+}
 
-	return &SelectStmt{
-		Columns:   columns,
-		From:      from,
-		FromAlias: fromAlias,
-		IndexedBy: indexedBy,
-		Joins:     joins,
-		Where:     where,
-		GroupBy:   groupBy,
-		OrderBy:   orderBy,
-		Limit:     limit,
-		Offset:    offset,
-	}, nil
+// parseOptionalReturning parses an optional RETURNING clause, returning nil
+// when there is none.
+func (p *parser) parseOptionalReturning() ([]Expr, error) {
+	if p.cur.Type != TokenReturning {
+		return nil, nil
+	}
+	p.next() // consume RETURNING
+	return p.parseSelectList()
 }
 
 // isSelectClauseKeyword returns true if the identifier (case-insensitive) is a
@@ -657,7 +1935,7 @@ func isSelectClauseKeyword(ident string) bool {
 	switch strings.ToUpper(ident) {
 	case "WHERE", "ORDER", "LIMIT", "OFFSET", "JOIN", "INNER", "ON",
 		"LEFT", "RIGHT", "OUTER", "CROSS", "FULL", "GROUP", "HAVING",
-		"INDEXED", "FORMAT":
+		"INDEXED", "FORMAT", "UNION", "INTERSECT", "EXCEPT":
 		return true
 	}
 	return false
@@ -694,24 +1972,18 @@ func (p *parser) parseUpdate() (*UpdateStmt, error) {
 		return nil, err
 	}
 
-	var sets []SetClause
-	for {
-		col, err := p.expect(TokenIdent)
-		if err != nil {
-			return nil, err
-		}
-		if _, err := p.expect(TokenEq); err != nil {
-			return nil, err
-		}
-		val, err := p.parseExpr()
+	sets, err := p.parseSetClauses()
+	if err != nil {
+		return nil, err
+	}
+
+	var from *ValuesTable
+	if p.cur.Type == TokenFrom {
+		p.next()
+		from, err = p.parseValuesTable()
 		if err != nil {
 			return nil, err
 		}
-		sets = append(sets, SetClause{Column: col.Literal, Value: val})
-		if p.cur.Type != TokenComma {
-			break
-		}
-		p.next()
 	}
 
 	var where Expr
@@ -723,7 +1995,59 @@ func (p *parser) parseUpdate() (*UpdateStmt, error) {
 		}
 	}
 
-	return &UpdateStmt{Table: ref, IndexedBy: indexedBy, Sets: sets, Where: where}, nil
+	returning, err := p.parseOptionalReturning()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateStmt{Table: ref, IndexedBy: indexedBy, Sets: sets, From: from, Where: where, Returning: returning}, nil
+}
+
+// parseValuesTable parses (VALUES (exprs) [, ...]) [AS] alias [(columns)].
+// As in PostgreSQL the alias is required.
+func (p *parser) parseValuesTable() (*ValuesTable, error) {
+	if _, err := p.expect(TokenLParen); err != nil {
+		return nil, err
+	}
+	if p.cur.Type != TokenValues {
+		return nil, fmt.Errorf("expected VALUES after FROM (, got %q at position %d", p.cur.Literal, p.cur.Pos)
+	}
+	values, err := p.parseValues()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, err
+	}
+	if p.cur.Type == TokenAs {
+		p.next()
+	}
+	if p.cur.Type != TokenIdent {
+		return nil, fmt.Errorf("VALUES in FROM must have an alias, got %q at position %d", p.cur.Literal, p.cur.Pos)
+	}
+	t := &ValuesTable{Rows: values.Rows, Alias: p.cur.Literal}
+	p.next()
+	if p.cur.Type == TokenLParen {
+		p.next()
+		for {
+			col, err := p.expect(TokenIdent)
+			if err != nil {
+				return nil, err
+			}
+			t.Columns = append(t.Columns, col.Literal)
+			if p.cur.Type != TokenComma {
+				break
+			}
+			p.next()
+		}
+		if _, err := p.expect(TokenRParen); err != nil {
+			return nil, err
+		}
+	}
+	if len(t.Columns) > len(t.Rows[0]) {
+		return nil, fmt.Errorf("table %q has %d columns available but %d columns specified", t.Alias, len(t.Rows[0]), len(t.Columns))
+	}
+	return t, nil
 }
 
 func (p *parser) parseDelete() (*DeleteStmt, error) {
@@ -749,7 +2073,12 @@ func (p *parser) parseDelete() (*DeleteStmt, error) {
 		}
 	}
 
-	return &DeleteStmt{Table: ref, IndexedBy: indexedBy, Where: where}, nil
+	returning, err := p.parseOptionalReturning()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeleteStmt{Table: ref, IndexedBy: indexedBy, Where: where, Returning: returning}, nil
 }
 
 // -------------------------------------------------------------------------
@@ -823,17 +2152,18 @@ func (p *parser) parseComparison() (Expr, error) {
 		return &IsNullExpr{Expr: left, Not: not}, nil
 	}
 
-	// [NOT] LIKE / [NOT] ILIKE pattern [ESCAPE char]
+	// [NOT] LIKE / [NOT] ILIKE / [NOT] SIMILAR TO pattern [ESCAPE char]
 	likeNot := false
 	if p.cur.Type == TokenNot {
-		// Peek: NOT followed by LIKE or ILIKE means NOT LIKE / NOT ILIKE.
+		// Peek: NOT followed by LIKE, ILIKE or SIMILAR means NOT LIKE /
+		// NOT ILIKE / NOT SIMILAR TO.
 		// Save lexer state to restore if NOT is not part of a LIKE predicate.
 		savedPos := p.lexer.pos
 		savedCh := p.lexer.ch
 		savedWidth := p.lexer.width
 		savedCur := p.cur
 		p.next()
-		if p.cur.Type == TokenLike || p.cur.Type == TokenIlike {
+		if p.cur.Type == TokenLike || p.cur.Type == TokenIlike || p.isSimilar() {
 			likeNot = true
 		} else {
 			// Restore: NOT is not part of a LIKE predicate.
@@ -843,9 +2173,15 @@ func (p *parser) parseComparison() (Expr, error) {
 			p.cur = savedCur
 		}
 	}
-	if p.cur.Type == TokenLike || p.cur.Type == TokenIlike {
+	if p.cur.Type == TokenLike || p.cur.Type == TokenIlike || p.isSimilar() {
 		caseInsensitive := p.cur.Type == TokenIlike
-		p.next() // consume LIKE/ILIKE
+		similar := p.isSimilar()
+		p.next() // consume LIKE/ILIKE/SIMILAR
+		if similar {
+			if err := p.expectWord("TO"); err != nil {
+				return nil, err
+			}
+		}
 		pattern, err := p.parseAdditive()
 		if err != nil {
 			return nil, err
@@ -864,10 +2200,11 @@ func (p *parser) parseComparison() (Expr, error) {
 			Escape:          escape,
 			Not:             likeNot,
 			CaseInsensitive: caseInsensitive,
+			Similar:         similar,
 		}, nil
 	}
 
-	// [NOT] IN (expr, expr, ...)
+	// [NOT] IN (expr, expr, ...) or [NOT] IN (SELECT ...)
 	inNot := false
 	if p.cur.Type == TokenNot {
 		savedPos, savedCh, savedWidth, savedCur := p.lexer.pos, p.lexer.ch, p.lexer.width, p.cur
@@ -880,6 +2217,19 @@ func (p *parser) parseComparison() (Expr, error) {
 	}
 	if p.cur.Type == TokenIn {
 		p.next()
+		if p.cur.Type == TokenLParen {
+			savedPos, savedCh, savedWidth, savedCur := p.lexer.pos, p.lexer.ch, p.lexer.width, p.cur
+			p.next()
+			isQuery := p.cur.Type == TokenSelect
+			p.lexer.pos, p.lexer.ch, p.lexer.width, p.cur = savedPos, savedCh, savedWidth, savedCur
+			if isQuery {
+				sq, err := p.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				return &InExpr{Expr: left, Subquery: sq.(*SubqueryExpr), Not: inNot}, nil
+			}
+		}
 		values, err := p.parseParenExprList()
 		if err != nil {
 			return nil, err
@@ -1011,48 +2361,106 @@ func (p *parser) parsePostfix() (Expr, error) {
 	}
 	for p.cur.Type == TokenCast {
 		p.next() // consume ::
-		typeName, err := p.parseCastType()
+		cast, err := p.parseCastType()
 		if err != nil {
 			return nil, err
 		}
-		expr = &CastExpr{Expr: expr, TypeName: typeName}
+		cast.Expr = expr
+		expr = cast
 	}
 	return expr, nil
 }
 
-// parseCastType parses the target type name after ::.
-func (p *parser) parseCastType() (string, error) {
+// parseCast parses the rest of CAST(expr AS type) after the opening
+// parenthesis.
+func (p *parser) parseCast() (Expr, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenAs); err != nil {
+		return nil, err
+	}
+	cast, err := p.parseCastType()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, err
+	}
+	cast.Expr = expr
+	return cast, nil
+}
+
+// parseCastType parses the target type of a cast and returns it as a
+// CastExpr without its operand. NUMERIC takes an optional (precision[,
+// scale]), as in a column definition.
+func (p *parser) parseCastType() (*CastExpr, error) {
 	switch p.cur.Type {
 	case TokenIntegerKW:
 		p.next()
-		return "INTEGER", nil
+		return &CastExpr{TypeName: "INTEGER"}, nil
 	case TokenTextKW:
 		p.next()
-		return "TEXT", nil
+		return &CastExpr{TypeName: "TEXT"}, nil
 	case TokenBooleanKW:
 		p.next()
-		return "BOOLEAN", nil
+		return &CastExpr{TypeName: "BOOLEAN"}, nil
 	case TokenFloatKW:
 		p.next()
-		return "FLOAT", nil
+		return &CastExpr{TypeName: "FLOAT"}, nil
 	case TokenTimestampKW:
 		p.next()
-		return "TIMESTAMP", nil
+		return &CastExpr{TypeName: "TIMESTAMP"}, nil
+	case TokenByteaKW:
+		p.next()
+		return &CastExpr{TypeName: "BYTEA"}, nil
 	case TokenDoubleKW:
 		p.next()
 		if p.cur.Type != TokenIdent || !strings.EqualFold(p.cur.Literal, "PRECISION") {
-			return "", fmt.Errorf("expected PRECISION after DOUBLE at position %d", p.cur.Pos)
+			return nil, fmt.Errorf("expected PRECISION after DOUBLE at position %d", p.cur.Pos)
 		}
 		p.next()
-		return "FLOAT", nil
+		return &CastExpr{TypeName: "FLOAT"}, nil
 	case TokenIdent:
 		// Accept common PostgreSQL type aliases that aren't keywords.
 		name := strings.ToUpper(p.cur.Literal)
 		p.next()
-		return name, nil
+		if !isNumericTypeName(name) {
+			return &CastExpr{TypeName: name}, nil
+		}
+		cast := &CastExpr{TypeName: "NUMERIC"}
+		if p.cur.Type == TokenLParen {
+			var err error
+			if cast.Precision, cast.Scale, err = p.parseNumericTypmod(); err != nil {
+				return nil, err
+			}
+		}
+		return cast, nil
 	default:
-		return "", fmt.Errorf("expected type name after :: at position %d", p.cur.Pos)
+		return nil, fmt.Errorf("expected type name in cast at position %d", p.cur.Pos)
+	}
+}
+
+// parseExtract parses the rest of EXTRACT(field FROM expr) after the opening
+// parenthesis. The field may be a bare word or a string literal.
+func (p *parser) parseExtract() (Expr, error) {
+	if p.cur.Type != TokenIdent && p.cur.Type != TokenStrLit {
+		return nil, fmt.Errorf("expected field name in EXTRACT at position %d", p.cur.Pos)
+	}
+	field := strings.ToLower(p.cur.Literal)
+	p.next()
+	if _, err := p.expect(TokenFrom); err != nil {
+		return nil, err
+	}
+	source, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenRParen); err != nil {
+		return nil, err
 	}
+	return &FunctionCallExpr{Name: "EXTRACT", Args: []Expr{&StringLit{Value: field}, source}}, nil
 }
 
 func (p *parser) parsePrimary() (Expr, error) {
@@ -1084,6 +2492,13 @@ func (p *parser) parsePrimary() (Expr, error) {
 	case TokenNull:
 		p.next()
 		return &NullLit{}, nil
+	case TokenParam:
+		n, err := strconv.Atoi(p.cur.Literal)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid parameter $%s at position %d", p.cur.Literal, p.cur.Pos)
+		}
+		p.next()
+		return &ParamRef{Index: n}, nil
 	case TokenIdent:
 		name := p.cur.Literal
 		p.next()
@@ -1100,6 +2515,17 @@ func (p *parser) parsePrimary() (Expr, error) {
 			return &ColumnRef{Table: name, Name: second.Literal}, nil
 		}
 		if p.cur.Type != TokenLParen {
+			// CURRENT_TIMESTAMP and CURRENT_DATE are written without
+			// parentheses.
+			if up := strings.ToUpper(name); up == "CURRENT_TIMESTAMP" || up == "CURRENT_DATE" {
+				return &FunctionCallExpr{Name: up}, nil
+			}
+			// TIME '12:30:00' is a typed literal, a cast of the string.
+			if strings.EqualFold(name, "TIME") && p.cur.Type == TokenStrLit {
+				lit := p.cur.Literal
+				p.next()
+				return &CastExpr{Expr: &StringLit{Value: lit}, TypeName: "TIME"}, nil
+			}
 			return &ColumnRef{Name: name}, nil
 		}
 		// function call: NAME(arg, arg, ...)
@@ -1129,6 +2555,14 @@ func (p *parser) parsePrimary() (Expr, error) {
 			}
 			return &NestExpr{Query: query, Format: format}, nil
 		}
+		// EXTRACT(field FROM expr) — the field becomes a string argument.
+		if strings.ToUpper(name) == "EXTRACT" {
+			return p.parseExtract()
+		}
+		// CAST(expr AS type) — the SQL-standard spelling of expr::type.
+		if strings.ToUpper(name) == "CAST" {
+			return p.parseCast()
+		}
 		var args []Expr
 		if p.cur.Type == TokenStar {
 			args = []Expr{&StarExpr{}}
@@ -1152,6 +2586,16 @@ func (p *parser) parsePrimary() (Expr, error) {
 		return &FunctionCallExpr{Name: strings.ToUpper(name), Args: args}, nil
 	case TokenLParen:
 		p.next()
+		if p.cur.Type == TokenSelect {
+			query, err := p.parseSelect()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(TokenRParen); err != nil {
+				return nil, err
+			}
+			return &SubqueryExpr{Query: query}, nil
+		}
 		expr, err := p.parseExpr()
 		if err != nil {
 			return nil, err
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 )
 
 // ---------------------------------------------------------------------------
@@ -91,9 +94,9 @@ func TestParse_CreateTable(t *testing.T) {
 		t.Fatalf("columns count = %d, want 3", len(ct.Columns))
 	}
 	wantCols := []ColumnDef{
-		{"id", "INTEGER", false, false},
-		{"name", "TEXT", false, false},
-		{"active", "BOOLEAN", false, false},
+		{Name: "id", DataType: "INTEGER"},
+		{Name: "name", DataType: "TEXT"},
+		{Name: "active", DataType: "BOOLEAN"},
 	}
 	for i, want := range wantCols {
 		got := ct.Columns[i]
@@ -141,6 +144,215 @@ func TestParse_CreateTableNotNull(t *testing.T) {
 	}
 }
 
+func TestParse_CreateTableUnique(t *testing.T) {
+	stmt, err := Parse("CREATE TABLE t (id INTEGER PRIMARY KEY, email TEXT UNIQUE NOT NULL, name TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := stmt.(*CreateTableStmt)
+	if !ct.Columns[1].Unique || !ct.Columns[1].NotNull {
+		t.Errorf("column[1] = %+v, want Unique and NotNull", ct.Columns[1])
+	}
+	if ct.Columns[0].Unique || ct.Columns[2].Unique {
+		t.Error("only column[1] should be Unique")
+	}
+}
+
+func TestParse_CreateTableDictionary(t *testing.T) {
+	stmt, err := Parse("CREATE TABLE t (id INTEGER PRIMARY KEY, status TEXT STORAGE DICTIONARY NOT NULL, name TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := stmt.(*CreateTableStmt)
+	if !ct.Columns[1].Dictionary || !ct.Columns[1].NotNull {
+		t.Errorf("column[1] = %+v, want Dictionary and NotNull", ct.Columns[1])
+	}
+	if ct.Columns[0].Dictionary || ct.Columns[2].Dictionary {
+		t.Error("only column[1] should be Dictionary")
+	}
+
+	stmt, err = Parse("ALTER TABLE t ADD COLUMN kind TEXT STORAGE DICTIONARY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if col := stmt.(*AlterTableAddColumnStmt).Column; !col.Dictionary {
+		t.Errorf("ADD COLUMN = %+v, want Dictionary", col)
+	}
+
+	for _, sql := range []string{
+		"CREATE TABLE t (n INTEGER STORAGE DICTIONARY)",
+		"CREATE TABLE t (s TEXT STORAGE)",
+		"CREATE TABLE t (s TEXT STORAGE PLAIN)",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("Parse(%q): expected error", sql)
+		}
+	}
+}
+
+func TestParse_CreateTableForeignKey(t *testing.T) {
+	stmt, err := Parse("CREATE TABLE c (id INTEGER PRIMARY KEY, pid INTEGER NOT NULL REFERENCES p ON DELETE CASCADE, " +
+		"oid INTEGER REFERENCES o (code) ON UPDATE RESTRICT ON DELETE SET NULL, n INTEGER, qid INTEGER, " +
+		"FOREIGN KEY (qid) REFERENCES q(id))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := stmt.(*CreateTableStmt)
+	want := []*ForeignKey{
+		nil,
+		{Table: "p", OnDelete: "CASCADE"},
+		{Table: "o", Column: "code", OnDelete: "SET NULL"},
+		nil,
+		{Table: "q", Column: "id", OnDelete: "NO ACTION"},
+	}
+	if len(ct.Columns) != len(want) {
+		t.Fatalf("columns = %d, want %d", len(ct.Columns), len(want))
+	}
+	for i, w := range want {
+		got := ct.Columns[i].References
+		if (got == nil) != (w == nil) || got != nil && *got != *w {
+			t.Errorf("column[%d].References = %+v, want %+v", i, got, w)
+		}
+	}
+	if !ct.Columns[1].NotNull {
+		t.Error("NOT NULL before REFERENCES was lost")
+	}
+
+	for _, sql := range []string{
+		"CREATE TABLE c (pid INTEGER REFERENCES p ON DELETE SET DEFAULT)",
+		"CREATE TABLE c (pid INTEGER REFERENCES p ON UPDATE CASCADE)",
+		"CREATE TABLE c (pid INTEGER REFERENCES p ON INSERT CASCADE)",
+		"CREATE TABLE c (a INTEGER, b INTEGER, FOREIGN KEY (a, b) REFERENCES p)",
+		"CREATE TABLE c (a INTEGER, FOREIGN KEY (b) REFERENCES p)",
+		"CREATE TABLE c (a INTEGER REFERENCES p, FOREIGN KEY (a) REFERENCES q)",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParse_CreateTableDefault(t *testing.T) {
+	stmt, err := Parse("CREATE TABLE t (id INTEGER DEFAULT -1 NOT NULL, created_at TIMESTAMP DEFAULT NOW(), status TEXT DEFAULT 'new' || '!', n INTEGER)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := stmt.(*CreateTableStmt)
+	wantSQL := []string{"-1", "NOW()", "'new' || '!'", ""}
+	for i, want := range wantSQL {
+		if got := ct.Columns[i].DefaultSQL; got != want {
+			t.Errorf("column[%d].DefaultSQL = %q, want %q", i, got, want)
+		}
+		if (ct.Columns[i].Default != nil) != (want != "") {
+			t.Errorf("column[%d].Default = %v", i, ct.Columns[i].Default)
+		}
+	}
+	if !ct.Columns[0].NotNull {
+		t.Error("NOT NULL after DEFAULT was lost")
+	}
+	if _, ok := ct.Columns[1].Default.(*FunctionCallExpr); !ok {
+		t.Errorf("column[1].Default = %T, want *FunctionCallExpr", ct.Columns[1].Default)
+	}
+
+	if _, err := Parse("CREATE TABLE t (id INTEGER DEFAULT)"); err == nil {
+		t.Error("expected error for DEFAULT without an expression")
+	}
+}
+
+func TestParse_CreateTableIdentity(t *testing.T) {
+	stmt, err := Parse("CREATE TABLE t (id SERIAL PRIMARY KEY, a INTEGER GENERATED ALWAYS AS IDENTITY, " +
+		"b BIGINT GENERATED BY DEFAULT AS IDENTITY, c BIGSERIAL, n INTEGER)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := stmt.(*CreateTableStmt)
+	want := []string{"BY DEFAULT", "ALWAYS", "BY DEFAULT", "BY DEFAULT", ""}
+	for i, w := range want {
+		col := ct.Columns[i]
+		if col.Identity != w {
+			t.Errorf("column[%d].Identity = %q, want %q", i, col.Identity, w)
+		}
+		if col.DataType != "INTEGER" {
+			t.Errorf("column[%d].DataType = %q, want INTEGER", i, col.DataType)
+		}
+		if col.NotNull != (w != "") {
+			t.Errorf("column[%d].NotNull = %v, want %v", i, col.NotNull, w != "")
+		}
+	}
+	if !ct.Columns[0].PrimaryKey {
+		t.Error("PRIMARY KEY after SERIAL was lost")
+	}
+
+	for _, sql := range []string{
+		"CREATE TABLE t (id TEXT GENERATED ALWAYS AS IDENTITY)",
+		"CREATE TABLE t (id SERIAL DEFAULT 1)",
+		"CREATE TABLE t (id SERIAL GENERATED ALWAYS AS IDENTITY)",
+		"CREATE TABLE t (id INTEGER GENERATED AS IDENTITY)",
+		"CREATE TABLE t (id INTEGER GENERATED ALWAYS AS IDENTITY (START WITH 10))",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParse_DefaultKeyword(t *testing.T) {
+	stmt, err := Parse("INSERT INTO t (a, b) VALUES (1, DEFAULT), (default, 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := stmt.(*InsertStmt)
+	if _, ok := ins.Values[0][1].(*DefaultExpr); !ok {
+		t.Errorf("values[0][1] = %T, want *DefaultExpr", ins.Values[0][1])
+	}
+	if _, ok := ins.Values[1][0].(*DefaultExpr); !ok {
+		t.Errorf("values[1][0] = %T, want *DefaultExpr", ins.Values[1][0])
+	}
+
+	stmt, err = Parse("INSERT INTO t DEFAULT VALUES RETURNING id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins = stmt.(*InsertStmt)
+	if len(ins.Values) != 1 || len(ins.Values[0]) != 0 || ins.Returning == nil {
+		t.Errorf("DEFAULT VALUES = %v returning %v, want one empty row", ins.Values, ins.Returning)
+	}
+
+	stmt, err = Parse(`UPDATE t SET a = DEFAULT, b = "default"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	up := stmt.(*UpdateStmt)
+	if _, ok := up.Sets[0].Value.(*DefaultExpr); !ok {
+		t.Errorf("SET a = %T, want *DefaultExpr", up.Sets[0].Value)
+	}
+	if ref, ok := up.Sets[1].Value.(*ColumnRef); !ok || ref.Name != "default" {
+		t.Errorf(`SET b = %#v, want column "default"`, up.Sets[1].Value)
+	}
+
+	for _, sql := range []string{
+		"INSERT INTO t (a) DEFAULT VALUES",
+		"INSERT INTO t DEFAULT",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("Parse(%q): expected error", sql)
+		}
+	}
+}
+
+func TestParse_ParseExpr(t *testing.T) {
+	expr, err := ParseExpr("1 + 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := expr.(*BinaryExpr); !ok {
+		t.Errorf("got %T, want *BinaryExpr", expr)
+	}
+	if _, err := ParseExpr("1 2"); err == nil {
+		t.Error("expected error for trailing input")
+	}
+}
+
 func TestParse_CreateTableNotNullPrimaryKey(t *testing.T) {
 	tests := []struct {
 		sql  string
@@ -184,6 +396,217 @@ func TestParse_DropTable(t *testing.T) {
 	}
 }
 
+func TestParse_CreateView(t *testing.T) {
+	stmt, err := Parse("CREATE VIEW adults AS SELECT id, name FROM users WHERE age >= 18 ;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cv, ok := stmt.(*CreateViewStmt)
+	if !ok {
+		t.Fatalf("got %T, want *CreateViewStmt", stmt)
+	}
+	if cv.Name.Name != "adults" {
+		t.Errorf("view name = %q, want adults", cv.Name.Name)
+	}
+	if q, ok := cv.Query.(*SelectStmt); !ok || q.From.Name != "users" || len(q.Columns) != 2 {
+		t.Errorf("query = %+v, want SELECT of two columns from users", cv.Query)
+	}
+	if want := "SELECT id, name FROM users WHERE age >= 18"; cv.QuerySQL != want {
+		t.Errorf("query SQL = %q, want %q", cv.QuerySQL, want)
+	}
+
+	for _, sql := range []string{"CREATE VIEW v AS INSERT INTO t VALUES (1)", "CREATE VIEW v SELECT 1", "CREATE VIEW AS SELECT 1"} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestParse_DropView(t *testing.T) {
+	stmt, err := Parse("drop view adults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dv, ok := stmt.(*DropViewStmt)
+	if !ok {
+		t.Fatalf("got %T, want *DropViewStmt", stmt)
+	}
+	if dv.Name.Name != "adults" {
+		t.Errorf("view name = %q, want adults", dv.Name.Name)
+	}
+}
+
+func TestParse_Truncate(t *testing.T) {
+	tests := []struct {
+		sql     string
+		restart bool
+	}{
+		{"TRUNCATE TABLE users", false},
+		{"TRUNCATE users;", false},
+		{"truncate table public.users", false},
+		{"TRUNCATE users RESTART IDENTITY", true},
+		{"TRUNCATE TABLE users CONTINUE IDENTITY", false},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		ts, ok := stmt.(*TruncateStmt)
+		if !ok {
+			t.Fatalf("%s: got %T, want *TruncateStmt", tt.sql, stmt)
+		}
+		if len(ts.Tables) != 1 || ts.Tables[0].Name != "users" {
+			t.Errorf("%s: tables = %v, want users", tt.sql, ts.Tables)
+		}
+		if ts.RestartIdentity != tt.restart {
+			t.Errorf("%s: RestartIdentity = %v, want %v", tt.sql, ts.RestartIdentity, tt.restart)
+		}
+	}
+	if _, err := Parse("TRUNCATE users RESTART"); err == nil {
+		t.Error("expected error for RESTART without IDENTITY")
+	}
+
+	stmt, err := Parse("TRUNCATE TABLE orders, public.items, users RESTART IDENTITY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := stmt.(*TruncateStmt)
+	if len(ts.Tables) != 3 || ts.Tables[0].Name != "orders" || ts.Tables[1].Name != "items" || ts.Tables[2].Name != "users" || !ts.RestartIdentity {
+		t.Errorf("tables = %v, restart = %v", ts.Tables, ts.RestartIdentity)
+	}
+	if _, err := Parse("TRUNCATE users,"); err == nil {
+		t.Error("expected error for a trailing comma")
+	}
+}
+
+func TestParse_Analyze(t *testing.T) {
+	stmt, err := Parse("ANALYZE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as, ok := stmt.(*AnalyzeStmt); !ok || !as.Table.IsEmpty() {
+		t.Fatalf("got %#v, want ANALYZE of all tables", stmt)
+	}
+	stmt, err = Parse("analyze public.users;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as, ok := stmt.(*AnalyzeStmt); !ok || as.Table.Name != "users" {
+		t.Errorf("got %#v, want ANALYZE users", stmt)
+	}
+}
+
+func TestParse_Vacuum(t *testing.T) {
+	stmt, err := Parse("VACUUM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vs, ok := stmt.(*VacuumStmt); !ok || !vs.Table.IsEmpty() {
+		t.Fatalf("got %#v, want VACUUM of all tables", stmt)
+	}
+	stmt, err = Parse("vacuum public.users;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vs, ok := stmt.(*VacuumStmt); !ok || vs.Table.Name != "users" {
+		t.Errorf("got %#v, want VACUUM users", stmt)
+	}
+}
+
+func TestParse_Checkpoint(t *testing.T) {
+	stmt, err := Parse("checkpoint;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stmt.(*CheckpointStmt); !ok {
+		t.Fatalf("got %#v, want CHECKPOINT", stmt)
+	}
+	if _, err := Parse("CHECKPOINT t"); err == nil {
+		t.Error("CHECKPOINT t: expected an error")
+	}
+}
+
+func TestParse_Backup(t *testing.T) {
+	stmt, err := Parse("backup to directory '/var/backups/mull';")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok := stmt.(*BackupStmt)
+	if !ok {
+		t.Fatalf("got %#v, want BACKUP", stmt)
+	}
+	if b.Path != "/var/backups/mull" || !b.Directory {
+		t.Errorf("got %+v, want directory /var/backups/mull", b)
+	}
+
+	stmt, err = Parse("BACKUP TO 'mull.mbak'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := stmt.(*BackupStmt); b.Path != "mull.mbak" || b.Directory {
+		t.Errorf("got %+v, want file mull.mbak", b)
+	}
+	for _, sql := range []string{"BACKUP", "BACKUP TO", "BACKUP TO DIRECTORY", "BACKUP TO DIRECTORY dir"} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestParse_ListenNotify(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want Statement
+	}{
+		{"LISTEN cache", &ListenStmt{Channel: "cache"}},
+		{`listen "Cache"`, &ListenStmt{Channel: "Cache"}},
+		{"UNLISTEN cache", &UnlistenStmt{Channel: "cache"}},
+		{"UNLISTEN *", &UnlistenStmt{}},
+		{"NOTIFY cache", &NotifyStmt{Channel: "cache"}},
+		{"NOTIFY cache, 'users:42';", &NotifyStmt{Channel: "cache", Payload: "users:42"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.sql)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.sql, got, tt.want)
+		}
+	}
+	for _, sql := range []string{"LISTEN", "LISTEN *", "NOTIFY cache,", "NOTIFY cache, 42", "NOTIFY cache 'x'"} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected an error", sql)
+		}
+	}
+}
+
+func TestParse_Values(t *testing.T) {
+	stmt, err := Parse("VALUES (1, 'a'), (2 + 3, NULL);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, ok := stmt.(*ValuesStmt)
+	if !ok {
+		t.Fatalf("got %T, want *ValuesStmt", stmt)
+	}
+	if len(vs.Rows) != 2 || len(vs.Rows[0]) != 2 || len(vs.Rows[1]) != 2 {
+		t.Fatalf("rows = %#v, want 2 rows of 2", vs.Rows)
+	}
+	if _, ok := vs.Rows[1][0].(*BinaryExpr); !ok {
+		t.Errorf("rows[1][0] = %T, want *BinaryExpr", vs.Rows[1][0])
+	}
+
+	if _, err := Parse("VALUES (1, 2), (3)"); err == nil {
+		t.Error("expected error for rows of different lengths")
+	}
+	if _, err := Parse("VALUES"); err == nil {
+		t.Error("expected error for VALUES without rows")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // INSERT
 // ---------------------------------------------------------------------------
@@ -447,6 +870,42 @@ func TestParse_UpdateNoWhere(t *testing.T) {
 	}
 }
 
+func TestParse_UpdateFromValues(t *testing.T) {
+	stmt, err := Parse("UPDATE t SET price = v.price FROM (VALUES (1, 100), (2, 200)) AS v(id, price) WHERE t.id = v.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upd := stmt.(*UpdateStmt)
+	if upd.From == nil || upd.From.Alias != "v" || !slices.Equal(upd.From.Columns, []string{"id", "price"}) || len(upd.From.Rows) != 2 {
+		t.Fatalf("from = %+v", upd.From)
+	}
+	assertIntLit(t, upd.From.Rows[1][1], 200)
+	if upd.Where == nil {
+		t.Error("where is nil")
+	}
+
+	// AS and the column list are optional.
+	stmt, err = Parse("UPDATE t SET a = 1 FROM (VALUES (1)) v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from := stmt.(*UpdateStmt).From; from.Alias != "v" || from.Columns != nil {
+		t.Errorf("from = %+v", from)
+	}
+
+	for _, sql := range []string{
+		"UPDATE t SET a = 1 FROM (VALUES (1))",
+		"UPDATE t SET a = 1 FROM (VALUES (1), (1, 2)) v",
+		"UPDATE t SET a = 1 FROM (VALUES (1)) v(x, y)",
+		"UPDATE t SET a = 1 FROM u",
+		"UPDATE t SET a = 1 FROM (SELECT 1) v",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // DELETE
 // ---------------------------------------------------------------------------
@@ -479,6 +938,77 @@ func TestParse_DeleteNoWhere(t *testing.T) {
 	}
 }
 
+func TestParse_Returning(t *testing.T) {
+	stmt, err := Parse("INSERT INTO users VALUES (1, 'a') RETURNING *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := stmt.(*InsertStmt)
+	if len(ins.Returning) != 1 {
+		t.Fatalf("insert returning = %v, want [*]", ins.Returning)
+	}
+	if _, ok := ins.Returning[0].(*StarExpr); !ok {
+		t.Errorf("insert returning[0] = %T, want *StarExpr", ins.Returning[0])
+	}
+
+	stmt, err = Parse("UPDATE users SET name = 'b' WHERE id = 1 RETURNING id, name AS n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upd := stmt.(*UpdateStmt)
+	if upd.Where == nil || len(upd.Returning) != 2 {
+		t.Fatalf("update where = %v, returning = %v", upd.Where, upd.Returning)
+	}
+	if a, ok := upd.Returning[1].(*AliasExpr); !ok || a.Alias != "n" {
+		t.Errorf("update returning[1] = %#v, want alias n", upd.Returning[1])
+	}
+
+	stmt, err = Parse("DELETE FROM users RETURNING id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if del := stmt.(*DeleteStmt); len(del.Returning) != 1 {
+		t.Errorf("delete returning = %v, want [id]", del.Returning)
+	}
+
+	if _, err := Parse("DELETE FROM users RETURNING"); err == nil {
+		t.Error("expected error for empty RETURNING list")
+	}
+}
+
+func TestParse_InsertOnConflict(t *testing.T) {
+	stmt, err := Parse("INSERT INTO users VALUES (1, 'a') ON CONFLICT DO NOTHING")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oc := stmt.(*InsertStmt).OnConflict
+	if oc == nil || oc.Column != "" || oc.Sets != nil {
+		t.Fatalf("on conflict = %#v, want DO NOTHING without target", oc)
+	}
+
+	stmt, err = Parse("INSERT INTO users VALUES (1, 'a') ON CONFLICT (id) DO UPDATE SET name = 'x', age = 3 RETURNING id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := stmt.(*InsertStmt)
+	if ins.OnConflict == nil || ins.OnConflict.Column != "id" || len(ins.OnConflict.Sets) != 2 {
+		t.Fatalf("on conflict = %#v, want target id with 2 assignments", ins.OnConflict)
+	}
+	if ins.OnConflict.Sets[1].Column != "age" || len(ins.Returning) != 1 {
+		t.Errorf("sets = %v, returning = %v", ins.OnConflict.Sets, ins.Returning)
+	}
+
+	for _, sql := range []string{
+		"INSERT INTO users VALUES (1) ON CONFLICT DO UPDATE SET name = 'x'",
+		"INSERT INTO users VALUES (1) ON CONFLICT (id) DO",
+		"INSERT INTO users VALUES (1) ON DUPLICATE DO NOTHING",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("Parse(%q): expected error", sql)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Error cases
 // ---------------------------------------------------------------------------
@@ -592,6 +1122,34 @@ func TestParse_SelectNoFrom_FunctionCall(t *testing.T) {
 	}
 }
 
+func TestParse_CurrentTimestamp(t *testing.T) {
+	stmt, err := Parse("SELECT current_timestamp, x FROM t WHERE ts < CURRENT_TIMESTAMP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := stmt.(*SelectStmt)
+	fn, ok := sel.Columns[0].(*FunctionCallExpr)
+	if !ok || fn.Name != "CURRENT_TIMESTAMP" || len(fn.Args) != 0 {
+		t.Fatalf("column 0 = %#v, want CURRENT_TIMESTAMP call", sel.Columns[0])
+	}
+	if _, ok := sel.Columns[1].(*ColumnRef); !ok {
+		t.Errorf("column 1 = %T, want *ColumnRef", sel.Columns[1])
+	}
+	cmp := sel.Where.(*BinaryExpr)
+	if fn, ok := cmp.Right.(*FunctionCallExpr); !ok || fn.Name != "CURRENT_TIMESTAMP" {
+		t.Errorf("where rhs = %#v, want CURRENT_TIMESTAMP call", cmp.Right)
+	}
+
+	stmt, err = Parse("SELECT x FROM t WHERE ts >= current_date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmp = stmt.(*SelectStmt).Where.(*BinaryExpr)
+	if fn, ok := cmp.Right.(*FunctionCallExpr); !ok || fn.Name != "CURRENT_DATE" || len(fn.Args) != 0 {
+		t.Errorf("where rhs = %#v, want CURRENT_DATE call", cmp.Right)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Aggregate functions
 // ---------------------------------------------------------------------------
@@ -720,6 +1278,34 @@ func TestParse_SelectColumnAlias(t *testing.T) {
 	}
 }
 
+func TestParse_SelectDistinct(t *testing.T) {
+	tests := []struct {
+		sql      string
+		distinct bool
+	}{
+		{"SELECT DISTINCT a, b FROM t", true},
+		{"SELECT ALL a, b FROM t", false},
+		{"SELECT a, b FROM t", false},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		sel := stmt.(*SelectStmt)
+		if sel.Distinct != tt.distinct {
+			t.Errorf("%s: Distinct = %v, want %v", tt.sql, sel.Distinct, tt.distinct)
+		}
+		if len(sel.Columns) != 2 {
+			t.Errorf("%s: columns = %d, want 2", tt.sql, len(sel.Columns))
+		}
+	}
+
+	if _, err := Parse("SELECT DISTINCT ON (a) a, b FROM t"); err == nil {
+		t.Error("DISTINCT ON: expected an error")
+	}
+}
+
 func TestParse_SelectAggregateAlias(t *testing.T) {
 	stmt, err := Parse("SELECT COUNT(*) AS total FROM t")
 	if err != nil {
@@ -813,6 +1399,23 @@ func TestParse_SelectLimitOffset(t *testing.T) {
 	}
 }
 
+func TestParse_SelectLimitExpr(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM t LIMIT 2 * 3 OFFSET -1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := stmt.(*SelectStmt)
+	if sel.Limit != nil || sel.Offset != nil {
+		t.Fatalf("limit/offset = %v/%v, want nil/nil", sel.Limit, sel.Offset)
+	}
+	if _, ok := sel.LimitExpr.(*BinaryExpr); !ok {
+		t.Errorf("limit expr = %T, want *BinaryExpr", sel.LimitExpr)
+	}
+	if _, ok := sel.OffsetExpr.(*UnaryExpr); !ok {
+		t.Errorf("offset expr = %T, want *UnaryExpr", sel.OffsetExpr)
+	}
+}
+
 func TestParse_SelectOffsetLimit(t *testing.T) {
 	stmt, err := Parse("SELECT * FROM t OFFSET 5 LIMIT 10")
 	if err != nil {
@@ -931,6 +1534,93 @@ func TestParse_CreateTableTypeAliases(t *testing.T) {
 	}
 }
 
+func TestParse_CreateTableNumeric(t *testing.T) {
+	stmt, err := Parse("CREATE TABLE t (a NUMERIC, b NUMERIC(10,2), c decimal(5), d DEC(3, 3) NOT NULL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := stmt.(*CreateTableStmt)
+	want := [][2]int{{0, 0}, {10, 2}, {5, 0}, {3, 3}}
+	for i, col := range ct.Columns {
+		if col.DataType != "NUMERIC" || col.Precision != want[i][0] || col.Scale != want[i][1] {
+			t.Errorf("column[%d] = %s(%d,%d), want NUMERIC(%d,%d)", i, col.DataType, col.Precision, col.Scale, want[i][0], want[i][1])
+		}
+	}
+	if !ct.Columns[3].NotNull {
+		t.Error("NOT NULL after the type modifier was lost")
+	}
+
+	for _, sql := range []string{
+		"CREATE TABLE t (a NUMERIC(0))",
+		"CREATE TABLE t (a NUMERIC(1001))",
+		"CREATE TABLE t (a NUMERIC(4,5))",
+		"CREATE TABLE t (a NUMERIC(4,))",
+		"CREATE TABLE t (a NUMBER)",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("Parse(%q): expected error", sql)
+		}
+	}
+}
+
+func TestParse_Time(t *testing.T) {
+	stmt, err := Parse("CREATE TABLE t (a TIME, b time without time zone NOT NULL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := stmt.(*CreateTableStmt)
+	for i, col := range ct.Columns {
+		if col.DataType != "TIME" {
+			t.Errorf("column[%d] DataType = %q, want TIME", i, col.DataType)
+		}
+	}
+	if !ct.Columns[1].NotNull {
+		t.Error("NOT NULL after WITHOUT TIME ZONE was lost")
+	}
+
+	stmt, err = Parse("SELECT TIME '12:30:00', time FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cols := stmt.(*SelectStmt).Columns
+	c, ok := cols[0].(*CastExpr)
+	if !ok {
+		t.Fatalf("column = %T, want *CastExpr", cols[0])
+	}
+	if lit, ok := c.Expr.(*StringLit); !ok || lit.Value != "12:30:00" || c.TypeName != "TIME" {
+		t.Errorf("got %#v AS %s, want '12:30:00' AS TIME", c.Expr, c.TypeName)
+	}
+	assertColumnRef(t, cols[1], "time")
+
+	if _, err := Parse("CREATE TABLE t (a TIME WITHOUT ZONE)"); err == nil {
+		t.Error("expected error for TIME WITHOUT ZONE")
+	}
+}
+
+func TestParse_ParamRef(t *testing.T) {
+	stmt, err := Parse("SELECT $1 FROM t WHERE id = $2 AND name LIKE $10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := stmt.(*SelectStmt)
+	if p, ok := sel.Columns[0].(*ParamRef); !ok || p.Index != 1 {
+		t.Errorf("column = %#v, want $1", sel.Columns[0])
+	}
+	and := sel.Where.(*BinaryExpr)
+	if p, ok := and.Left.(*BinaryExpr).Right.(*ParamRef); !ok || p.Index != 2 {
+		t.Errorf("id = %#v, want $2", and.Left.(*BinaryExpr).Right)
+	}
+	if p, ok := and.Right.(*LikeExpr).Pattern.(*ParamRef); !ok || p.Index != 10 {
+		t.Errorf("pattern = %#v, want $10", and.Right.(*LikeExpr).Pattern)
+	}
+
+	for _, sql := range []string{"SELECT $0", "SELECT $", "SELECT $a"} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("Parse(%q): expected error", sql)
+		}
+	}
+}
+
 func TestParse_CreateTableReservedWords(t *testing.T) {
 	stmt, err := Parse(`CREATE TABLE "table" ("select" INTEGER, "from" TEXT)`)
 	if err != nil {
@@ -1068,8 +1758,75 @@ func TestParse_SelectOrderByMultiColumn(t *testing.T) {
 	if sel.OrderBy[0].Column != "name" || sel.OrderBy[0].Desc {
 		t.Errorf("orderby[0] = %+v, want {name, ASC}", sel.OrderBy[0])
 	}
-	if sel.OrderBy[1].Column != "age" || !sel.OrderBy[1].Desc {
-		t.Errorf("orderby[1] = %+v, want {age, DESC}", sel.OrderBy[1])
+	if sel.OrderBy[1].Column != "age" || !sel.OrderBy[1].Desc {
+		t.Errorf("orderby[1] = %+v, want {age, DESC}", sel.OrderBy[1])
+	}
+}
+
+func TestParse_SelectOrderByExpr(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM t ORDER BY random() DESC, name LIMIT 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := stmt.(*SelectStmt)
+	if len(sel.OrderBy) != 2 {
+		t.Fatalf("orderby = %d, want 2", len(sel.OrderBy))
+	}
+	ob := sel.OrderBy[0]
+	if fn, ok := ob.Expr.(*FunctionCallExpr); !ok || fn.Name != "RANDOM" || ob.Column != "" || !ob.Desc {
+		t.Errorf("orderby[0] = %+v, want RANDOM() DESC", ob)
+	}
+	if ob.ExprSQL != "random()" {
+		t.Errorf("ExprSQL = %q, want random()", ob.ExprSQL)
+	}
+	if sel.OrderBy[1].Column != "name" || sel.OrderBy[1].Expr != nil {
+		t.Errorf("orderby[1] = %+v, want column name", sel.OrderBy[1])
+	}
+}
+
+func TestParse_SelectOrderByPosition(t *testing.T) {
+	stmt, err := Parse("SELECT name, price * qty FROM t ORDER BY 2 DESC, 1 + 1, name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob := stmt.(*SelectStmt).OrderBy
+	if ob[0].Position != 2 || ob[0].Expr != nil || ob[0].ExprSQL != "2" || !ob[0].Desc {
+		t.Errorf("orderby[0] = %+v, want position 2 DESC", ob[0])
+	}
+	// Only a bare integer is a position; anything else is an expression.
+	if ob[1].Position != 0 || ob[1].Expr == nil {
+		t.Errorf("orderby[1] = %+v, want an expression", ob[1])
+	}
+	if ob[2].Position != 0 || ob[2].Column != "name" {
+		t.Errorf("orderby[2] = %+v, want column name", ob[2])
+	}
+
+	if _, err := Parse("SELECT name FROM t ORDER BY 0"); err == nil {
+		t.Error("expected error for ORDER BY 0")
+	}
+}
+
+func TestParse_SelectOrderByNulls(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM t ORDER BY name ASC NULLS FIRST, age DESC nulls last, id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := stmt.(*SelectStmt)
+	if len(sel.OrderBy) != 3 {
+		t.Fatalf("orderby = %d, want 3", len(sel.OrderBy))
+	}
+	if nf := sel.OrderBy[0].NullsFirst; nf == nil || !*nf {
+		t.Errorf("orderby[0].NullsFirst = %v, want true", nf)
+	}
+	if nf := sel.OrderBy[1].NullsFirst; nf == nil || *nf || !sel.OrderBy[1].Desc {
+		t.Errorf("orderby[1] = %+v, want DESC NULLS LAST", sel.OrderBy[1])
+	}
+	if sel.OrderBy[2].NullsFirst != nil {
+		t.Errorf("orderby[2].NullsFirst = %v, want nil", *sel.OrderBy[2].NullsFirst)
+	}
+
+	if _, err := Parse("SELECT * FROM t ORDER BY name NULLS"); err == nil {
+		t.Error("expected error for NULLS without FIRST or LAST")
 	}
 }
 
@@ -1750,6 +2507,27 @@ func TestParse_MultiArgFunction(t *testing.T) {
 	}
 }
 
+func TestParse_Extract(t *testing.T) {
+	stmt, err := Parse("SELECT EXTRACT(YEAR FROM created_at + 1) FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := stmt.(*SelectStmt).Columns[0].(*FunctionCallExpr)
+	if fn.Name != "EXTRACT" || len(fn.Args) != 2 {
+		t.Fatalf("got %#v, want EXTRACT with 2 args", fn)
+	}
+	if field, ok := fn.Args[0].(*StringLit); !ok || field.Value != "year" {
+		t.Errorf("field = %#v, want StringLit year", fn.Args[0])
+	}
+	if _, ok := fn.Args[1].(*BinaryExpr); !ok {
+		t.Errorf("source = %T, want *BinaryExpr", fn.Args[1])
+	}
+
+	if _, err := Parse("SELECT EXTRACT(year, created_at) FROM t"); err == nil {
+		t.Error("expected error for EXTRACT without FROM")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // LIKE / ILIKE predicate
 // ---------------------------------------------------------------------------
@@ -1801,6 +2579,29 @@ func TestParse_NotLike(t *testing.T) {
 	}
 }
 
+func TestParse_SimilarTo(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM t WHERE name NOT SIMILAR TO '(a|b)%' ESCAPE '#'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := stmt.(*SelectStmt)
+	like, ok := sel.Where.(*LikeExpr)
+	if !ok {
+		t.Fatalf("WHERE = %T, want *LikeExpr", sel.Where)
+	}
+	if !like.Similar || !like.Not || like.Escape == nil {
+		t.Errorf("got Similar=%v Not=%v Escape=%v, want SIMILAR TO with NOT and ESCAPE", like.Similar, like.Not, like.Escape)
+	}
+
+	// SIMILAR is not reserved: it can still name a column.
+	if _, err := Parse("SELECT similar FROM t WHERE similar SIMILAR TO 'x'"); err != nil {
+		t.Errorf("column named similar: %v", err)
+	}
+	if _, err := Parse("SELECT * FROM t WHERE name SIMILAR 'x'"); err == nil {
+		t.Error("expected error for SIMILAR without TO")
+	}
+}
+
 func TestParse_Ilike(t *testing.T) {
 	stmt, err := Parse("SELECT * FROM t WHERE name ILIKE '%foo%'")
 	if err != nil {
@@ -2023,6 +2824,118 @@ func TestParse_NotIn(t *testing.T) {
 	}
 }
 
+func TestParse_InSubquery(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM orders WHERE user_id NOT IN (SELECT id FROM users WHERE active) AND total > 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	and, ok := stmt.(*SelectStmt).Where.(*BinaryExpr)
+	if !ok {
+		t.Fatalf("WHERE = %T, want *BinaryExpr", stmt.(*SelectStmt).Where)
+	}
+	in, ok := and.Left.(*InExpr)
+	if !ok {
+		t.Fatalf("left = %T, want *InExpr", and.Left)
+	}
+	assertColumnRef(t, in.Expr, "user_id")
+	if !in.Not || in.Values != nil || in.Subquery == nil {
+		t.Fatalf("InExpr = %+v, want NOT IN with a subquery", in)
+	}
+	if in.Subquery.Query.From.Name != "users" || in.Subquery.Query.Where == nil {
+		t.Errorf("subquery = %+v, want SELECT from users with WHERE", in.Subquery.Query)
+	}
+
+	// A subquery in parentheses inside a value list is a scalar value.
+	stmt, err = Parse("SELECT * FROM t WHERE x IN ((SELECT 1), 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in = stmt.(*SelectStmt).Where.(*InExpr)
+	if in.Subquery != nil || len(in.Values) != 2 {
+		t.Errorf("InExpr = %+v, want a list of two values", in)
+	}
+}
+
+func TestParse_SetOp(t *testing.T) {
+	stmt, err := Parse("SELECT id FROM a UNION ALL SELECT id FROM b ORDER BY id DESC LIMIT 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, ok := stmt.(*SetOpStmt)
+	if !ok {
+		t.Fatalf("got %T, want *SetOpStmt", stmt)
+	}
+	if u.Op != "UNION" || !u.All {
+		t.Errorf("op = %s all=%v, want UNION ALL", u.Op, u.All)
+	}
+	if u.Left.(*SelectStmt).From.Name != "a" {
+		t.Errorf("left = %+v, want SELECT from a", u.Left)
+	}
+	// ORDER BY and LIMIT after the last SELECT belong to the union.
+	right := u.Right.(*SelectStmt)
+	if right.From.Name != "b" || right.OrderBy != nil || right.Limit != nil {
+		t.Errorf("right = %+v, want SELECT from b without ORDER BY or LIMIT", right)
+	}
+	if len(u.OrderBy) != 1 || u.OrderBy[0].Column != "id" || !u.OrderBy[0].Desc || u.Limit == nil || *u.Limit != 3 {
+		t.Errorf("order by = %+v, limit = %v, want id DESC LIMIT 3", u.OrderBy, u.Limit)
+	}
+
+	// INTERSECT binds more tightly than UNION and EXCEPT, which group
+	// from the left.
+	stmt, err = Parse("SELECT 1 EXCEPT DISTINCT SELECT 2 UNION SELECT 3 INTERSECT SELECT 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u = stmt.(*SetOpStmt)
+	if u.Op != "UNION" || u.All {
+		t.Fatalf("top = %s all=%v, want UNION", u.Op, u.All)
+	}
+	if l, ok := u.Left.(*SetOpStmt); !ok || l.Op != "EXCEPT" || l.All {
+		t.Errorf("left = %+v, want EXCEPT", u.Left)
+	}
+	if r, ok := u.Right.(*SetOpStmt); !ok || r.Op != "INTERSECT" {
+		t.Errorf("right = %+v, want INTERSECT", u.Right)
+	}
+
+	// Parentheses group, and keep their own ORDER BY and LIMIT; the
+	// clauses after them apply to the whole query.
+	stmt, err = Parse("(SELECT x FROM a ORDER BY x LIMIT 1) UNION (SELECT x FROM b UNION SELECT x FROM c) ORDER BY 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u = stmt.(*SetOpStmt)
+	if l := u.Left.(*SelectStmt); l.Limit == nil || len(l.OrderBy) != 1 {
+		t.Errorf("left = %+v, want its own ORDER BY and LIMIT", l)
+	}
+	if r, ok := u.Right.(*SetOpStmt); !ok || r.OrderBy != nil {
+		t.Errorf("right = %+v, want a UNION without ORDER BY", u.Right)
+	}
+	if len(u.OrderBy) != 1 || u.OrderBy[0].Position != 1 {
+		t.Errorf("order by = %+v, want position 1", u.OrderBy)
+	}
+
+	// A table alias cannot be named after an operator.
+	stmt, err = Parse("SELECT id FROM a UNION SELECT id FROM b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := stmt.(*SetOpStmt).Left.(*SelectStmt); a.FromAlias != "" {
+		t.Errorf("alias = %q, want none", a.FromAlias)
+	}
+
+	for _, sql := range []string{
+		"SELECT 1 ORDER BY 1 UNION SELECT 2",
+		"SELECT 1 LIMIT 1 INTERSECT SELECT 2",
+		"SELECT 1 UNION",
+		"SELECT 1 UNION ALL ALL SELECT 2",
+		"SELECT 1 UNION (SELECT 2",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
 func TestParse_InSingleValue(t *testing.T) {
 	stmt, err := Parse("SELECT * FROM t WHERE x IN (42)")
 	if err != nil {
@@ -2195,10 +3108,74 @@ func TestParse_ShowMemory_CaseInsensitive(t *testing.T) {
 	}
 }
 
-func TestParse_ShowUnknown(t *testing.T) {
-	_, err := Parse("SHOW TABLES")
-	if err == nil {
-		t.Error("expected error for SHOW TABLES")
+func TestParse_Show(t *testing.T) {
+	tests := []struct {
+		sql  string
+		name string
+	}{
+		{"SHOW server_version", "server_version"},
+		{"show DateStyle;", "datestyle"},
+		{"SHOW myapp.user_id", "myapp.user_id"},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		show, ok := stmt.(*ShowStmt)
+		if !ok {
+			t.Fatalf("%s: expected *ShowStmt, got %T", tt.sql, stmt)
+		}
+		if show.Name != tt.name {
+			t.Errorf("%s: name = %q, want %q", tt.sql, show.Name, tt.name)
+		}
+	}
+	for _, sql := range []string{"SHOW", "SHOW 1", "SHOW a b"} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("expected error for %q", sql)
+		}
+	}
+}
+
+func TestParse_Set(t *testing.T) {
+	tests := []struct {
+		sql   string
+		name  string
+		value string
+		local bool
+	}{
+		{"SET client_encoding = 'UTF8'", "client_encoding", "UTF8", false},
+		{"SET trace TO ON", "trace", "on", false},
+		{"SET SESSION statement_timeout = 5000", "statement_timeout", "5000", false},
+		{"SET LOCAL extra_float_digits = -3", "extra_float_digits", "-3", true},
+		{"SET search_path TO public, pg_catalog", "search_path", "public, pg_catalog", false},
+		{"SET application_name = DEFAULT", "application_name", "default", false},
+		{"SET myapp.user_id = 'it''s';", "myapp.user_id", "it's", false},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		set, ok := stmt.(*SetStmt)
+		if !ok {
+			t.Fatalf("%s: expected *SetStmt, got %T", tt.sql, stmt)
+		}
+		if set.Name != tt.name || set.Value != tt.value || set.Local != tt.local {
+			t.Errorf("%s: got (%q, %q, %v), want (%q, %q, %v)",
+				tt.sql, set.Name, set.Value, set.Local, tt.name, tt.value, tt.local)
+		}
+	}
+	for _, sql := range []string{
+		"SET",
+		"SET trace",
+		"SET trace =",
+		"SET TIME ZONE 'UTC'",
+		"SET TRANSACTION ISOLATION LEVEL READ COMMITTED",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("expected error for %q", sql)
+		}
 	}
 }
 
@@ -2240,6 +3217,55 @@ func TestParse_GroupByMultiple(t *testing.T) {
 	}
 }
 
+func TestParse_GroupingSets(t *testing.T) {
+	tests := []struct {
+		sql   string
+		exprs int
+		sets  [][]int
+	}{
+		{"SELECT 1 FROM t GROUP BY ROLLUP(a, b)", 2, [][]int{{0, 1}, {0}, {}}},
+		{"SELECT 1 FROM t GROUP BY CUBE(a, b)", 2, [][]int{{0, 1}, {0}, {1}, {}}},
+		{"SELECT 1 FROM t GROUP BY GROUPING SETS ((a, b), a, ())", 2, [][]int{{0, 1}, {0}, {}}},
+		{"SELECT 1 FROM t GROUP BY a, ROLLUP(b)", 2, [][]int{{0, 1}, {0}}},
+		{"SELECT 1 FROM t GROUP BY ROLLUP(a, (b, c))", 3, [][]int{{0, 1, 2}, {0}, {}}},
+		{"SELECT 1 FROM t GROUP BY GROUPING SETS (a, ROLLUP(b)), a", 2, [][]int{{0}, {1, 0}, {0}}},
+		{"SELECT 1 FROM t GROUP BY ()", 0, [][]int{{}}},
+		// A parenthesized expression is not a grouping set.
+		{"SELECT 1 FROM t GROUP BY GROUPING SETS ((a + 1) * 2)", 1, [][]int{{0}}},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		sel := stmt.(*SelectStmt)
+		if len(sel.GroupBy) != tt.exprs || !slices.EqualFunc(sel.GroupingSets, tt.sets, slices.Equal) {
+			t.Errorf("%s: GroupBy = %d, GroupingSets = %v, want %d and %v", tt.sql, len(sel.GroupBy), sel.GroupingSets, tt.exprs, tt.sets)
+		}
+	}
+
+	// A plain list has no grouping sets, and ROLLUP without parentheses
+	// is a column.
+	stmt, err := Parse("SELECT 1 FROM t GROUP BY a, (b), rollup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel := stmt.(*SelectStmt); len(sel.GroupBy) != 3 || sel.GroupingSets != nil {
+		t.Errorf("GroupBy = %d, GroupingSets = %v, want 3 and nil", len(sel.GroupBy), sel.GroupingSets)
+	}
+
+	for _, sql := range []string{
+		"SELECT 1 FROM t GROUP BY ROLLUP(a",
+		"SELECT 1 FROM t GROUP BY GROUPING SETS a",
+		"SELECT 1 FROM t GROUP BY CUBE(a, b, c, d, e, f, g, h, i, j, k, l, m)",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // NEST(SELECT ...) tests
 // ---------------------------------------------------------------------------
@@ -2369,6 +3395,87 @@ func TestParse_NestFormatUnsupported(t *testing.T) {
 	}
 }
 
+func TestParse_ScalarSubquery(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM products WHERE price > (SELECT AVG(price) FROM products p2 WHERE p2.category_id = products.category_id)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel := stmt.(*SelectStmt)
+	be, ok := sel.Where.(*BinaryExpr)
+	if !ok {
+		t.Fatalf("WHERE = %T, want *BinaryExpr", sel.Where)
+	}
+	sq, ok := be.Right.(*SubqueryExpr)
+	if !ok {
+		t.Fatalf("right = %T, want *SubqueryExpr", be.Right)
+	}
+	if sq.Query.From.Name != "products" || sq.Query.FromAlias != "p2" {
+		t.Errorf("inner FROM = %q %q, want products p2", sq.Query.From.Name, sq.Query.FromAlias)
+	}
+	if sq.Query.Where == nil {
+		t.Fatal("inner WHERE is nil")
+	}
+
+	// A parenthesized expression is still just that.
+	stmt, err = Parse("SELECT (1 + 2) * 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stmt.(*SelectStmt).Columns[0].(*BinaryExpr); !ok {
+		t.Errorf("column = %T, want *BinaryExpr", stmt.(*SelectStmt).Columns[0])
+	}
+
+	if _, err := Parse("SELECT * FROM t WHERE x = (SELECT 1"); err == nil {
+		t.Error("expected error for unclosed subquery")
+	}
+}
+
+func TestParse_Cast(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT CAST(x AS INTEGER) FROM t",
+		"SELECT x::integer FROM t",
+		"SELECT cast(x as int) FROM t",
+	} {
+		stmt, err := Parse(sql)
+		if err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		c, ok := stmt.(*SelectStmt).Columns[0].(*CastExpr)
+		if !ok {
+			t.Fatalf("%s: column = %T, want *CastExpr", sql, stmt.(*SelectStmt).Columns[0])
+		}
+		assertColumnRef(t, c.Expr, "x")
+		if c.TypeName != "INTEGER" {
+			t.Errorf("%s: type = %q, want INTEGER", sql, c.TypeName)
+		}
+	}
+	// NUMERIC takes the same (precision, scale) as in a column definition.
+	for _, sql := range []string{
+		"SELECT CAST(x AS NUMERIC(10,2)) FROM t",
+		"SELECT x::decimal(10, 2) FROM t",
+	} {
+		stmt, err := Parse(sql)
+		if err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		c := stmt.(*SelectStmt).Columns[0].(*CastExpr)
+		if c.TypeName != "NUMERIC" || c.Precision != 10 || c.Scale != 2 {
+			t.Errorf("%s: cast = %s(%d,%d), want NUMERIC(10,2)", sql, c.TypeName, c.Precision, c.Scale)
+		}
+	}
+	for _, sql := range []string{
+		"SELECT CAST(x INTEGER) FROM t",
+		"SELECT CAST(x AS) FROM t",
+		"SELECT CAST(x AS INTEGER FROM t",
+		"SELECT CAST(x AS NUMERIC(2,3)) FROM t",
+		"SELECT CAST(x AS NUMERIC(10,2) FROM t",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // BETWEEN
 // ---------------------------------------------------------------------------
@@ -2455,3 +3562,224 @@ func TestParse_NotExprWithBetween(t *testing.T) {
 		t.Error("inner BetweenExpr.Not = true, want false")
 	}
 }
+
+func TestParse_Explain(t *testing.T) {
+	stmt, err := Parse("EXPLAIN SELECT * FROM users WHERE id = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex, ok := stmt.(*ExplainStmt)
+	if !ok {
+		t.Fatalf("expected *ExplainStmt, got %T", stmt)
+	}
+	sel, ok := ex.Stmt.(*SelectStmt)
+	if !ok {
+		t.Fatalf("expected inner *SelectStmt, got %T", ex.Stmt)
+	}
+	if sel.From.Name != "users" {
+		t.Errorf("From = %q, want users", sel.From.Name)
+	}
+}
+
+func TestParse_ExplainUpdateDelete(t *testing.T) {
+	stmt, err := Parse("explain UPDATE t SET a = 1 WHERE b = 2;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stmt.(*ExplainStmt).Stmt.(*UpdateStmt); !ok {
+		t.Errorf("expected inner *UpdateStmt, got %T", stmt.(*ExplainStmt).Stmt)
+	}
+
+	stmt, err = Parse("EXPLAIN DELETE FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stmt.(*ExplainStmt).Stmt.(*DeleteStmt); !ok {
+		t.Errorf("expected inner *DeleteStmt, got %T", stmt.(*ExplainStmt).Stmt)
+	}
+}
+
+func TestParse_ExplainUnsupported(t *testing.T) {
+	for _, sql := range []string{"EXPLAIN", "EXPLAIN CREATE TABLE t (a INTEGER)", "EXPLAIN EXPLAIN SELECT 1"} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("Parse(%q): expected error", sql)
+		}
+	}
+}
+
+func TestParse_ExplainAnalyze(t *testing.T) {
+	stmt, err := Parse("EXPLAIN ANALYZE SELECT * FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := stmt.(*ExplainStmt)
+	if !ex.Analyze {
+		t.Error("Analyze = false, want true")
+	}
+	if _, ok := ex.Stmt.(*SelectStmt); !ok {
+		t.Errorf("expected inner *SelectStmt, got %T", ex.Stmt)
+	}
+
+	stmt, err = Parse("EXPLAIN SELECT analyze FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt.(*ExplainStmt).Analyze {
+		t.Error("Analyze = true for plain EXPLAIN")
+	}
+}
+
+func TestParse_Hints(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want time.Duration
+	}{
+		{"/*+ timeout(500ms) */ SELECT 1", 500 * time.Millisecond},
+		{"/*+ TIMEOUT( 2s ) */ SELECT 1", 2 * time.Second},
+		{"/*+ timeout(250) */ SELECT 1", 250 * time.Millisecond},
+		{"/*+ SeqScan(t) timeout(1m) */ SELECT 1", time.Minute},
+		{"/*+ SeqScan(t) */ SELECT 1", 0},
+		{"/* timeout(1s) */ SELECT 1", 0},
+		{"SELECT /*+ timeout(1s) */ 1", 0},
+	}
+	for _, tt := range tests {
+		_, hints, err := ParseWithHints(tt.sql)
+		if err != nil {
+			t.Errorf("%s: %v", tt.sql, err)
+			continue
+		}
+		if hints.Timeout != tt.want {
+			t.Errorf("%s: timeout = %v, want %v", tt.sql, hints.Timeout, tt.want)
+		}
+	}
+
+	for _, sql := range []string{
+		"/*+ timeout(soon) */ SELECT 1",
+		"/*+ timeout(0) */ SELECT 1",
+		"/*+ timeout(-1s) */ SELECT 1",
+		"/*+ timeout */ SELECT 1",
+		"/*+ timeout(1s */ SELECT 1",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("Parse(%q): expected error", sql)
+		}
+	}
+}
+
+func TestParse_Copy(t *testing.T) {
+	tests := []struct {
+		sql    string
+		format string
+	}{
+		{"COPY (SELECT id FROM t ORDER BY id LIMIT 5) TO STDOUT", "text"},
+		{"copy (SELECT id FROM t) to stdout with (format csv)", "csv"},
+		{"COPY (SELECT id FROM t) TO STDOUT (FORMAT text)", "text"},
+		{"COPY (SELECT id FROM t) TO STDOUT WITH CSV", "csv"},
+		{"COPY (SELECT 1 UNION SELECT 2) TO STDOUT CSV", "csv"},
+	}
+	for _, tt := range tests {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		c, ok := stmt.(*CopyStmt)
+		if !ok {
+			t.Fatalf("%s: got %T, want *CopyStmt", tt.sql, stmt)
+		}
+		if c.Format != tt.format || c.Query == nil {
+			t.Errorf("%s: format = %q, query = %v, want %s", tt.sql, c.Format, c.Query, tt.format)
+		}
+	}
+	// The query keeps its ORDER BY, LIMIT and OFFSET.
+	stmt, err := Parse("COPY (SELECT id FROM t ORDER BY id DESC LIMIT 2 OFFSET 1) TO STDOUT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := stmt.(*CopyStmt).Query.(*SelectStmt); len(s.OrderBy) != 1 || *s.Limit != 2 || *s.Offset != 1 {
+		t.Errorf("query = %+v, want ORDER BY, LIMIT 2 and OFFSET 1", s)
+	}
+
+	// A table, with or without a column list, either way.
+	stmt, err = Parse("COPY public.orders (id, total) FROM STDIN WITH (FORMAT csv)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := stmt.(*CopyStmt)
+	if c.Table.Name != "orders" || !slices.Equal(c.Columns, []string{"id", "total"}) || !c.From || c.Query != nil || c.Format != "csv" {
+		t.Errorf("COPY FROM = %+v", c)
+	}
+	stmt, err = Parse("COPY orders TO STDOUT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := stmt.(*CopyStmt); c.Table.Name != "orders" || c.Columns != nil || c.From || c.Format != "text" {
+		t.Errorf("COPY TO = %+v", c)
+	}
+
+	// HEADER, after CSV or as an option with an optional boolean.
+	for _, tt := range []struct {
+		sql    string
+		header bool
+	}{
+		{"COPY orders TO STDOUT WITH CSV HEADER", true},
+		{"COPY orders TO STDOUT CSV", false},
+		{"COPY orders FROM STDIN (FORMAT csv, HEADER)", true},
+		{"COPY orders TO STDOUT (HEADER true, FORMAT csv)", true},
+		{"COPY orders TO STDOUT (HEADER on)", true},
+		{"COPY orders TO STDOUT (FORMAT text, HEADER off)", false},
+		{"COPY orders TO STDOUT (HEADER false)", false},
+	} {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if c := stmt.(*CopyStmt); c.Header != tt.header {
+			t.Errorf("%s: header = %v, want %v", tt.sql, c.Header, tt.header)
+		}
+	}
+
+	// NULL, as an option or in the older syntax.
+	for _, tt := range []struct {
+		sql  string
+		null string
+		set  bool
+	}{
+		{"COPY orders TO STDOUT", "", false},
+		{"COPY orders TO STDOUT (NULL 'NULL')", "NULL", true},
+		{"COPY orders FROM STDIN (FORMAT csv, NULL '', HEADER)", "", true},
+		{"COPY orders FROM STDIN WITH NULL AS '-' CSV HEADER", "-", true},
+		{"COPY orders TO STDOUT NULL 'n/a'", "n/a", true},
+	} {
+		stmt, err := Parse(tt.sql)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		c := stmt.(*CopyStmt)
+		if (c.Null != nil) != tt.set || c.Null != nil && *c.Null != tt.null {
+			t.Errorf("%s: null = %v, want %q (set %v)", tt.sql, c.Null, tt.null, tt.set)
+		}
+	}
+
+	for _, sql := range []string{
+		"COPY (SELECT 1) FROM STDIN",
+		"COPY orders FROM '/tmp/in'",
+		"COPY orders TO STDOUT (NULL 'a', NULL 'b')",
+		"COPY orders TO STDOUT (NULL)",
+		"COPY orders TO STDOUT NULL x",
+		"COPY orders TO STDOUT (FORMAT csv, FORMAT text)",
+		"COPY orders TO STDOUT (HEADER, HEADER)",
+		"COPY orders TO STDOUT (DELIMITER ';')",
+		"COPY orders TO STDOUT ()",
+		"COPY orders () FROM STDIN",
+		"COPY orders TO STDIN",
+		"COPY (SELECT 1) TO STDOUT WITH (FORMAT binary)",
+		"COPY (SELECT 1) TO STDOUT WITH (FORMAT)",
+		"COPY (SELECT 1) TO '/tmp/out'",
+		"COPY (SELECT 1 TO STDOUT",
+		"COPY (DELETE FROM t) TO STDOUT",
+	} {
+		if _, err := Parse(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
@@ -37,6 +37,7 @@ const (
 	TokenPercent   // %
 	TokenConcat    // ||
 	TokenCast      // ::
+	TokenParam     // $1, $2, ... (bind parameter placeholder)
 
 	// Keywords.
 	TokenSelect
@@ -93,6 +94,10 @@ const (
 	TokenShow        // SHOW
 	TokenMemory      // MEMORY
 	TokenGroup       // GROUP
+	TokenExplain     // EXPLAIN
+	TokenTruncate    // TRUNCATE
+	TokenReturning   // RETURNING
+	TokenByteaKW     // BYTEA (data type keyword)
 )
 
 var tokenNames = map[TokenType]string{
@@ -120,6 +125,7 @@ var tokenNames = map[TokenType]string{
 	TokenPercent:   "%",
 	TokenConcat:    "||",
 	TokenCast:      "::",
+	TokenParam:     "PARAM",
 	TokenSelect:    "SELECT",
 	TokenFrom:      "FROM",
 	TokenWhere:     "WHERE",
@@ -174,6 +180,10 @@ var tokenNames = map[TokenType]string{
 	TokenShow:        "SHOW",
 	TokenMemory:      "MEMORY",
 	TokenGroup:       "GROUP",
+	TokenExplain:     "EXPLAIN",
+	TokenTruncate:    "TRUNCATE",
+	TokenReturning:   "RETURNING",
+	TokenByteaKW:     "BYTEA",
 }
 
 func (t TokenType) String() string {
@@ -252,6 +262,10 @@ var keywords = map[string]TokenType{
 	"SHOW":        TokenShow,
 	"MEMORY":      TokenMemory,
 	"GROUP":       TokenGroup,
+	"EXPLAIN":     TokenExplain,
+	"TRUNCATE":    TokenTruncate,
+	"RETURNING":   TokenReturning,
+	"BYTEA":       TokenByteaKW,
 }
 
 // LookupKeyword returns the keyword token type for ident, or TokenIdent
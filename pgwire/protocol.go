@@ -6,11 +6,24 @@ const ProtocolVersion int32 = 196608 // 3 << 16
 // SSL request code sent by clients before the real startup message.
 const SSLRequestCode int32 = 80877103
 
+// CancelRequest code, sent on a new connection in place of a startup
+// message to cancel the query running in another session.
+const CancelRequestCode int32 = 80877102
+
 // Frontend (client → server) message types.
 const (
 	MsgPasswordMessage byte = 'p'
 	MsgQuery           byte = 'Q'
 	MsgTerminate       byte = 'X'
+
+	// Extended query protocol.
+	MsgParse    byte = 'P'
+	MsgBind     byte = 'B'
+	MsgDescribe byte = 'D'
+	MsgExecute  byte = 'E'
+	MsgClose    byte = 'C'
+	MsgSync     byte = 'S'
+	MsgFlush    byte = 'H'
 )
 
 // Backend (server → client) message types.
@@ -20,10 +33,39 @@ const (
 	MsgCommandComplete    byte = 'C'
 	MsgDataRow            byte = 'D'
 	MsgErrorResponse      byte = 'E'
+	MsgNoticeResponse     byte = 'N'
 	MsgEmptyQueryResponse byte = 'I'
 	MsgParameterStatus    byte = 'S'
 	MsgReadyForQuery      byte = 'Z'
 	MsgRowDescription     byte = 'T'
+
+	// Extended query protocol.
+	MsgParseComplete        byte = '1'
+	MsgBindComplete         byte = '2'
+	MsgCloseComplete        byte = '3'
+	MsgNoData               byte = 'n'
+	MsgParameterDescription byte = 't'
+	MsgPortalSuspended      byte = 's'
+
+	// COPY subprotocol.
+	MsgCopyInResponse  byte = 'G'
+	MsgCopyOutResponse byte = 'H'
+
+	// Asynchronous, sent between queries.
+	MsgNotificationResponse byte = 'A'
+)
+
+// COPY data message types, sent by either side, and the client's CopyFail.
+const (
+	MsgCopyData byte = 'd'
+	MsgCopyDone byte = 'c'
+	MsgCopyFail byte = 'f'
+)
+
+// Value format codes used in Bind messages and RowDescription.
+const (
+	FormatText   int16 = 0
+	FormatBinary int16 = 1
 )
 
 // Authentication sub-types (carried inside 'R' messages).
@@ -44,6 +86,14 @@ const (
 type StartupMessage struct {
 	ProtocolVersion int32
 	Parameters      map[string]string
+	Cancel          *CancelRequest // set, without parameters, for a CancelRequest
+}
+
+// CancelRequest carries the backend key data of the session whose running
+// query should be cancelled.
+type CancelRequest struct {
+	ProcessID int32
+	SecretKey int32
 }
 
 // ColumnInfo describes a single column in a RowDescription message.
@@ -56,3 +106,37 @@ type ColumnInfo struct {
 	TypeModifier int32
 	FormatCode   int16
 }
+
+// ParseMessage is a Parse message: prepare Query as statement Name. The
+// unnamed statement has an empty name. ParamOIDs declares parameter types;
+// 0 leaves a parameter's type to the server.
+type ParseMessage struct {
+	Name      string
+	Query     string
+	ParamOIDs []int32
+}
+
+// BindMessage is a Bind message: create portal Portal from statement
+// Statement with the given parameter values, nil meaning NULL.
+// ParamFormats holds no codes (all text), one code for every parameter,
+// or one per parameter, as does ResultFormats for the result columns.
+type BindMessage struct {
+	Portal        string
+	Statement     string
+	ParamFormats  []int16
+	Params        [][]byte
+	ResultFormats []int16
+}
+
+// DescribeMessage is a Describe or Close message. Kind is 'S' for a
+// prepared statement or 'P' for a portal.
+type DescribeMessage struct {
+	Kind byte
+	Name string
+}
+
+// ExecuteMessage is an Execute message. MaxRows 0 means no limit.
+type ExecuteMessage struct {
+	Portal  string
+	MaxRows int32
+}
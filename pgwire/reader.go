@@ -3,6 +3,7 @@ package pgwire
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -17,10 +18,16 @@ func NewReader(r io.Reader) *Reader {
 	return &Reader{r: bufio.NewReader(r)}
 }
 
+// Buffered returns the number of bytes read from the connection but not
+// yet consumed.
+func (r *Reader) Buffered() int {
+	return r.r.Buffered()
+}
+
 // ReadStartup reads the initial untyped message from the client.
 // It returns the parsed StartupMessage and whether the message was an SSL
-// request (in which case msg is nil and the caller should refuse SSL and
-// call ReadStartup again).
+// request (in which case msg is nil and the caller should answer it and
+// call ReadStartup again). For a CancelRequest, msg.Cancel is set.
 func (r *Reader) ReadStartup() (msg *StartupMessage, isSSL bool, err error) {
 	var length int32
 	if err := binary.Read(r.r, binary.BigEndian, &length); err != nil {
@@ -40,6 +47,18 @@ func (r *Reader) ReadStartup() (msg *StartupMessage, isSSL bool, err error) {
 	if version == SSLRequestCode {
 		return nil, true, nil
 	}
+	if version == CancelRequestCode {
+		if len(payload) != 12 {
+			return nil, false, fmt.Errorf("invalid cancel request length: %d bytes", length)
+		}
+		return &StartupMessage{
+			ProtocolVersion: version,
+			Cancel: &CancelRequest{
+				ProcessID: int32(binary.BigEndian.Uint32(payload[4:8])),
+				SecretKey: int32(binary.BigEndian.Uint32(payload[8:12])),
+			},
+		}, false, nil
+	}
 	if version != ProtocolVersion {
 		return nil, false, fmt.Errorf("unsupported protocol version: %d.%d",
 			version>>16, version&0xFFFF)
@@ -97,3 +116,133 @@ func readCString(b []byte) (string, []byte) {
 	}
 	return string(b), nil
 }
+
+// payloadReader decodes the fields of a message payload. The first
+// malformed or missing field sets err; later reads return zero values.
+type payloadReader struct {
+	b   []byte
+	err error
+}
+
+func (p *payloadReader) fail() {
+	if p.err == nil {
+		p.err = errors.New("malformed message payload")
+	}
+	p.b = nil
+}
+
+func (p *payloadReader) cstring() string {
+	for i, c := range p.b {
+		if c == 0 {
+			s := string(p.b[:i])
+			p.b = p.b[i+1:]
+			return s
+		}
+	}
+	p.fail()
+	return ""
+}
+
+func (p *payloadReader) int16() int16 {
+	if len(p.b) < 2 {
+		p.fail()
+		return 0
+	}
+	v := int16(binary.BigEndian.Uint16(p.b))
+	p.b = p.b[2:]
+	return v
+}
+
+func (p *payloadReader) int32() int32 {
+	if len(p.b) < 4 {
+		p.fail()
+		return 0
+	}
+	v := int32(binary.BigEndian.Uint32(p.b))
+	p.b = p.b[4:]
+	return v
+}
+
+func (p *payloadReader) byte() byte {
+	if len(p.b) < 1 {
+		p.fail()
+		return 0
+	}
+	v := p.b[0]
+	p.b = p.b[1:]
+	return v
+}
+
+// bytes reads an int32 length followed by that many bytes; length -1 is
+// NULL and returns nil.
+func (p *payloadReader) bytes() []byte {
+	n := p.int32()
+	if n == -1 || p.err != nil {
+		return nil
+	}
+	if n < 0 || int(n) > len(p.b) {
+		p.fail()
+		return nil
+	}
+	v := p.b[:n:n]
+	p.b = p.b[n:]
+	return v
+}
+
+// count reads an int16 element count, which must be non-negative.
+func (p *payloadReader) count() int {
+	n := p.int16()
+	if n < 0 {
+		p.fail()
+		return 0
+	}
+	return int(n)
+}
+
+// ParseParse decodes the payload of a Parse message.
+func ParseParse(payload []byte) (*ParseMessage, error) {
+	p := &payloadReader{b: payload}
+	m := &ParseMessage{Name: p.cstring(), Query: p.cstring()}
+	n := p.count()
+	for i := 0; i < n && p.err == nil; i++ {
+		m.ParamOIDs = append(m.ParamOIDs, p.int32())
+	}
+	return m, p.err
+}
+
+// ParseBind decodes the payload of a Bind message.
+func ParseBind(payload []byte) (*BindMessage, error) {
+	p := &payloadReader{b: payload}
+	m := &BindMessage{Portal: p.cstring(), Statement: p.cstring()}
+	n := p.count()
+	for i := 0; i < n && p.err == nil; i++ {
+		m.ParamFormats = append(m.ParamFormats, p.int16())
+	}
+	n = p.count()
+	m.Params = make([][]byte, 0, n)
+	for i := 0; i < n && p.err == nil; i++ {
+		m.Params = append(m.Params, p.bytes())
+	}
+	n = p.count()
+	for i := 0; i < n && p.err == nil; i++ {
+		m.ResultFormats = append(m.ResultFormats, p.int16())
+	}
+	return m, p.err
+}
+
+// ParseDescribe decodes the payload of a Describe or Close message.
+func ParseDescribe(payload []byte) (*DescribeMessage, error) {
+	p := &payloadReader{b: payload}
+	m := &DescribeMessage{Kind: p.byte(), Name: p.cstring()}
+	if p.err == nil && m.Kind != 'S' && m.Kind != 'P' {
+		return nil, fmt.Errorf("invalid describe kind '%c'", m.Kind)
+	}
+	return m, p.err
+}
+
+// ParseExecute decodes the payload of an Execute message.
+func ParseExecute(payload []byte) (*ExecuteMessage, error) {
+	p := &payloadReader{b: payload}
+	m := &ExecuteMessage{Portal: p.cstring(), MaxRows: p.int32()}
+	return m, p.err
+}
@@ -25,6 +25,13 @@ func (w *Writer) Flush() error {
 	return w.w.Flush()
 }
 
+// WriteSSLAccept writes a single 'S' byte to accept an SSL connection. The
+// TLS handshake follows directly on the same socket.
+func (w *Writer) WriteSSLAccept() error {
+	_, err := w.w.Write([]byte{'S'})
+	return err
+}
+
 // WriteSSLRefuse writes a single 'N' byte to refuse an SSL connection.
 func (w *Writer) WriteSSLRefuse() error {
 	_, err := w.w.Write([]byte{'N'})
@@ -125,6 +132,108 @@ func (w *Writer) WriteErrorResponse(severity, code, message string) error {
 	return w.finishMessage()
 }
 
+// WriteNoticeResponse sends a non-fatal notice (e.g. a WARNING) to the
+// client. Unlike ErrorResponse it does not end the current command.
+func (w *Writer) WriteNoticeResponse(severity, code, message string) error {
+	w.beginMessage(MsgNoticeResponse)
+	w.buf = append(w.buf, 'S')
+	w.writeCString(severity)
+	w.buf = append(w.buf, 'C')
+	w.writeCString(code)
+	w.buf = append(w.buf, 'M')
+	w.writeCString(message)
+	w.buf = append(w.buf, 0) // field terminator
+	return w.finishMessage()
+}
+
+// WriteParseComplete acknowledges a Parse message.
+func (w *Writer) WriteParseComplete() error {
+	w.beginMessage(MsgParseComplete)
+	return w.finishMessage()
+}
+
+// WriteBindComplete acknowledges a Bind message.
+func (w *Writer) WriteBindComplete() error {
+	w.beginMessage(MsgBindComplete)
+	return w.finishMessage()
+}
+
+// WriteCloseComplete acknowledges a Close message.
+func (w *Writer) WriteCloseComplete() error {
+	w.beginMessage(MsgCloseComplete)
+	return w.finishMessage()
+}
+
+// WriteNoData answers a Describe for a statement or portal that returns
+// no rows.
+func (w *Writer) WriteNoData() error {
+	w.beginMessage(MsgNoData)
+	return w.finishMessage()
+}
+
+// WriteParameterDescription sends the type OIDs of a prepared statement's
+// parameters.
+func (w *Writer) WriteParameterDescription(oids []int32) error {
+	w.beginMessage(MsgParameterDescription)
+	w.writeInt16(int16(len(oids)))
+	for _, oid := range oids {
+		w.writeInt32(oid)
+	}
+	return w.finishMessage()
+}
+
+// WritePortalSuspended signals that an Execute stopped at its row limit
+// before the portal was exhausted.
+func (w *Writer) WritePortalSuspended() error {
+	w.beginMessage(MsgPortalSuspended)
+	return w.finishMessage()
+}
+
+// WriteCopyInResponse asks the client for the data of a COPY from it,
+// rows with ncols columns in text format.
+func (w *Writer) WriteCopyInResponse(ncols int) error {
+	return w.writeCopyResponse(MsgCopyInResponse, ncols)
+}
+
+// WriteCopyOutResponse starts a COPY to the client of rows with ncols
+// columns, all in text format; the CSV format is text too on the wire.
+func (w *Writer) WriteCopyOutResponse(ncols int) error {
+	return w.writeCopyResponse(MsgCopyOutResponse, ncols)
+}
+
+func (w *Writer) writeCopyResponse(msgType byte, ncols int) error {
+	w.beginMessage(msgType)
+	w.buf = append(w.buf, 0) // overall format: text
+	w.writeInt16(int16(ncols))
+	for range ncols {
+		w.writeInt16(0)
+	}
+	return w.finishMessage()
+}
+
+// WriteCopyData sends a chunk of COPY data, here one line.
+func (w *Writer) WriteCopyData(data []byte) error {
+	w.beginMessage(MsgCopyData)
+	w.buf = append(w.buf, data...)
+	return w.finishMessage()
+}
+
+// WriteCopyDone ends a COPY to the client.
+func (w *Writer) WriteCopyDone() error {
+	w.beginMessage(MsgCopyDone)
+	return w.finishMessage()
+}
+
+// WriteNotificationResponse delivers a NOTIFY sent on channel by the
+// session with process ID pid.
+func (w *Writer) WriteNotificationResponse(pid int32, channel, payload string) error {
+	w.beginMessage(MsgNotificationResponse)
+	w.writeInt32(pid)
+	w.writeCString(channel)
+	w.writeCString(payload)
+	return w.finishMessage()
+}
+
 // beginMessage starts building a new message with the given type byte.
 func (w *Writer) beginMessage(msgType byte) {
 	w.buf = w.buf[:0]
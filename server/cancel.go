@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"sync"
+)
+
+// cancelRegistry maps the backend keys handed out in BackendKeyData to
+// their sessions, so that a CancelRequest arriving on a fresh connection
+// can reach the statement running in another one.
+type cancelRegistry struct {
+	mu       sync.Mutex
+	lastPID  int32
+	sessions map[int32]*cancelTarget
+}
+
+// cancelTarget is the cancellable state of one session.
+type cancelTarget struct {
+	secret int32
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // of the running statement; nil between statements
+}
+
+func newCancelRegistry() *cancelRegistry {
+	return &cancelRegistry{sessions: make(map[int32]*cancelTarget)}
+}
+
+// register adds a session and returns its process ID and cancellation
+// state; the process ID and the state's secret are the session's key.
+func (r *cancelRegistry) register() (int32, *cancelTarget) {
+	var b [4]byte
+	rand.Read(b[:])
+	t := &cancelTarget{secret: int32(binary.BigEndian.Uint32(b[:]))}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		r.lastPID++
+		if r.lastPID <= 0 {
+			r.lastPID = 1
+		}
+		if _, taken := r.sessions[r.lastPID]; !taken {
+			break
+		}
+	}
+	r.sessions[r.lastPID] = t
+	return r.lastPID, t
+}
+
+// unregister removes the session with the given process ID.
+func (r *cancelRegistry) unregister(pid int32) {
+	r.mu.Lock()
+	delete(r.sessions, pid)
+	r.mu.Unlock()
+}
+
+// cancel cancels the running statement of the session identified by pid
+// and secret. Unknown keys are ignored, as PostgreSQL does, so that a
+// client cannot probe for sessions.
+func (r *cancelRegistry) cancel(pid, secret int32) {
+	r.mu.Lock()
+	t := r.sessions[pid]
+	r.mu.Unlock()
+	if t == nil {
+		return
+	}
+	var want, got [4]byte
+	binary.BigEndian.PutUint32(want[:], uint32(t.secret))
+	binary.BigEndian.PutUint32(got[:], uint32(secret))
+	if subtle.ConstantTimeCompare(want[:], got[:]) != 1 {
+		return
+	}
+	t.mu.Lock()
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.mu.Unlock()
+}
+
+// statement returns the context of a statement about to run. The
+// returned function must be called when it finishes.
+func (t *cancelTarget) statement() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.mu.Lock()
+	t.cancel = cancel
+	t.mu.Unlock()
+	return ctx, func() {
+		t.mu.Lock()
+		t.cancel = nil
+		t.mu.Unlock()
+		cancel()
+	}
+}
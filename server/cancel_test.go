@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
+
+	"mulldb/config"
+)
+
+func TestCancelRequest(t *testing.T) {
+	ctx := context.Background()
+	connStr := startServer(t)
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	other, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close(ctx)
+	if conn.PgConn().PID() == other.PgConn().PID() {
+		t.Errorf("sessions share process ID %d", conn.PgConn().PID())
+	}
+
+	vals := make([]string, 300)
+	for i := range vals {
+		vals[i] = fmt.Sprintf("(%d)", i)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := conn.Exec(ctx, "CREATE TABLE "+name+" (n INTEGER)"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(ctx, "INSERT INTO "+name+" VALUES "+strings.Join(vals, ", ")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A 27 million row join runs until it is cancelled. The request is
+	// repeated in case it arrives before the query starts.
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Exec(ctx, "SELECT a.n FROM a JOIN b ON a.n = b.n JOIN c ON b.n + c.n < 0")
+		done <- err
+	}()
+	var queryErr error
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+wait:
+	for {
+		select {
+		case queryErr = <-done:
+			break wait
+		case <-tick.C:
+			if err := conn.PgConn().CancelRequest(ctx); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("query was not cancelled")
+		}
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(queryErr, &pgErr) || pgErr.Code != "57014" {
+		t.Fatalf("got %v, want SQLSTATE 57014", queryErr)
+	}
+
+	// The session stays usable, and other sessions were not affected.
+	var n int64
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*) FROM a").Scan(&n); err != nil || n != 300 {
+		t.Fatalf("after cancel: count = %d, %v", n, err)
+	}
+	if err := other.QueryRow(ctx, "SELECT COUNT(*) FROM b").Scan(&n); err != nil || n != 300 {
+		t.Fatalf("other session: count = %d, %v", n, err)
+	}
+}
+
+// TestCancelRequest_Context cancels a query the way an application does,
+// through its context. pgx then sends a CancelRequest with the session's
+// backend key and waits for the server to end the query.
+func TestCancelRequest_Context(t *testing.T) {
+	ctx := context.Background()
+	cfg, err := pgx.ParseConfig(startServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.BuildContextWatcherHandler = func(pgConn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{Conn: pgConn, DeadlineDelay: 10 * time.Second}
+	}
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	vals := make([]string, 300)
+	for i := range vals {
+		vals[i] = fmt.Sprintf("(%d)", i)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := conn.Exec(ctx, "CREATE TABLE "+name+" (n INTEGER)"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(ctx, "INSERT INTO "+name+" VALUES "+strings.Join(vals, ", ")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	qctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = conn.Exec(qctx, "SELECT a.n FROM a JOIN b ON a.n = b.n JOIN c ON b.n + c.n < 0")
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "57014" {
+		t.Fatalf("got %v, want SQLSTATE 57014", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("query ended after %v, want soon after the cancel", elapsed)
+	}
+
+	// The server answered the cancel, so the connection is still open.
+	var n int64
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*) FROM a").Scan(&n); err != nil || n != 300 {
+		t.Fatalf("after cancel: count = %d, %v", n, err)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	ctx := context.Background()
+	connStr := startServer(t, func(c *config.Config) { c.StatementTimeout = 50 * time.Millisecond })
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	vals := make([]string, 300)
+	for i := range vals {
+		vals[i] = fmt.Sprintf("(%d)", i)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := conn.Exec(ctx, "CREATE TABLE "+name+" (n INTEGER)"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(ctx, "INSERT INTO "+name+" VALUES "+strings.Join(vals, ", ")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = conn.Exec(ctx, "SELECT a.n FROM a JOIN b ON a.n = b.n JOIN c ON b.n + c.n < 0")
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "57014" || !strings.Contains(pgErr.Message, "statement timeout") {
+		t.Fatalf("got %v, want SQLSTATE 57014 for a statement timeout", err)
+	}
+
+	var n int64
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*) FROM a").Scan(&n); err != nil || n != 300 {
+		t.Fatalf("after timeout: count = %d, %v", n, err)
+	}
+}
+
+func TestCancelRegistry(t *testing.T) {
+	r := newCancelRegistry()
+	pid, target := r.register()
+	ctx, done := target.statement()
+	defer done()
+
+	// A wrong secret or an unknown process ID is ignored.
+	r.cancel(pid, target.secret+1)
+	r.cancel(pid+1, target.secret)
+	if ctx.Err() != nil {
+		t.Fatal("statement cancelled with a wrong key")
+	}
+
+	r.cancel(pid, target.secret)
+	if ctx.Err() == nil {
+		t.Fatal("statement not cancelled")
+	}
+
+	// Once unregistered, the key no longer reaches the session.
+	r.unregister(pid)
+	ctx, done2 := target.statement()
+	defer done2()
+	r.cancel(pid, target.secret)
+	if ctx.Err() != nil {
+		t.Fatal("unregistered session cancelled")
+	}
+}
@@ -1,13 +1,14 @@
 package server
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
-	"os"
 	"strings"
+	"time"
 
 	"mulldb/config"
 	"mulldb/executor"
@@ -27,35 +28,67 @@ const (
 // Connection handles the lifecycle of a single client connection:
 // startup handshake → authentication → query loop.
 type Connection struct {
-	conn         net.Conn
-	reader       *pgwire.Reader
-	writer       *pgwire.Writer
-	cfg          *config.Config
-	exec         *executor.Executor // current executor (base or tx-scoped)
-	baseExec     *executor.Executor // original executor backed by real engine
-	traceEnabled bool
-	lastTrace    *executor.Trace
-	txState      txStatus
-	txEngine     *storage.TxEngine
+	conn      net.Conn
+	reader    *pgwire.Reader
+	writer    *pgwire.Writer
+	cfg       *config.Config
+	tls       *tls.Config        // offered on SSLRequest; nil refuses it
+	cancels   *cancelRegistry    // backend keys of all sessions
+	pid       int32              // process ID in this session's backend key
+	cancel    *cancelTarget      // cancels the running statement; nil before startup
+	notifies  *notifyHub         // LISTEN registry of all sessions
+	listener  *listener          // receives notifications; nil until the first LISTEN
+	exec      *executor.Executor // current executor (base or tx-scoped)
+	baseExec  *executor.Executor // original executor backed by real engine
+	params    *sessionParams
+	lastTrace *executor.Trace
+	txState   txStatus
+	txEngine  *storage.TxEngine
+	cursors   map[string]*executor.Cursor // open cursors by name; closed when the transaction ends
+	txChannel []*executor.ChannelCommand  // LISTEN, UNLISTEN and NOTIFY waiting for COMMIT
+
+	// Extended query protocol state. After an error, messages up to the
+	// next Sync are skipped.
+	statements     map[string]*preparedStatement
+	portals        map[string]*portal
+	ignoreTillSync bool
 }
 
-func newConnection(conn net.Conn, cfg *config.Config, exec *executor.Executor) *Connection {
+func newConnection(conn net.Conn, cfg *config.Config, exec *executor.Executor, tlsCfg *tls.Config, cancels *cancelRegistry, notifies *notifyHub) *Connection {
+	exec = exec.NewSession()
+	exec.SetStatementTimeout(cfg.StatementTimeout)
+	exec.SetScanBatchSize(cfg.ScanBatchSize)
+	exec.SetSeqScanNotice(cfg.SeqScanNotice)
+	exec.SetCopyProgress(cfg.CopyProgress)
 	return &Connection{
 		conn:     conn,
 		reader:   pgwire.NewReader(conn),
 		writer:   pgwire.NewWriter(conn),
 		cfg:      cfg,
+		tls:      tlsCfg,
+		cancels:  cancels,
+		notifies: notifies,
 		exec:     exec,
 		baseExec: exec,
+		params:   newSessionParams(),
 	}
 }
 
 // Handle runs the full connection lifecycle and closes the connection on return.
 func (c *Connection) Handle() {
-	defer c.conn.Close()
+	defer func() { c.conn.Close() }() // c.conn may be replaced by a TLS conn
+	defer c.closeCursors()
+	defer c.stopListening()
+	defer func() {
+		if c.cancel != nil {
+			c.cancels.unregister(c.pid)
+		}
+	}()
 
 	if err := c.startup(); err != nil {
-		log.Printf("connection %s: startup: %v", c.conn.RemoteAddr(), err)
+		if !errors.Is(err, errCancelRequest) {
+			log.Printf("connection %s: startup: %v", c.conn.RemoteAddr(), err)
+		}
 		return
 	}
 
@@ -64,8 +97,46 @@ func (c *Connection) Handle() {
 	log.Printf("connection %s: disconnected", c.conn.RemoteAddr())
 }
 
+// errCancelRequest ends a connection that was opened to send a
+// CancelRequest; such connections get no response.
+var errCancelRequest = errors.New("cancel request")
+
+// negotiateSSL answers an SSLRequest. Without a TLS configuration, or on
+// a connection that is already encrypted, it refuses and the client goes
+// on in plaintext; otherwise it accepts and runs the TLS handshake.
+func (c *Connection) negotiateSSL() error {
+	_, secure := c.conn.(*tls.Conn)
+	if c.tls == nil || secure {
+		if err := c.writer.WriteSSLRefuse(); err != nil {
+			return fmt.Errorf("refuse SSL: %w", err)
+		}
+		return c.writer.Flush()
+	}
+
+	// Bytes sent after the SSLRequest but before the handshake were not
+	// protected by TLS; accepting them would allow injection.
+	if c.reader.Buffered() > 0 {
+		return errors.New("received unencrypted data after SSL request")
+	}
+	if err := c.writer.WriteSSLAccept(); err != nil {
+		return fmt.Errorf("accept SSL: %w", err)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	tlsConn := tls.Server(c.conn, c.tls)
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake: %w", err)
+	}
+	c.conn = tlsConn
+	c.reader = pgwire.NewReader(tlsConn)
+	c.writer = pgwire.NewWriter(tlsConn)
+	return nil
+}
+
 // startup performs the PostgreSQL startup handshake and cleartext password
-// authentication. It handles optional SSL negotiation.
+// authentication. It handles optional SSL negotiation, upgrading the
+// connection to TLS when a certificate is configured.
 func (c *Connection) startup() error {
 	for {
 		msg, isSSL, err := c.reader.ReadStartup()
@@ -73,14 +144,15 @@ func (c *Connection) startup() error {
 			return fmt.Errorf("read startup: %w", err)
 		}
 		if isSSL {
-			if err := c.writer.WriteSSLRefuse(); err != nil {
-				return fmt.Errorf("refuse SSL: %w", err)
-			}
-			if err := c.writer.Flush(); err != nil {
+			if err := c.negotiateSSL(); err != nil {
 				return err
 			}
 			continue
 		}
+		if msg.Cancel != nil {
+			c.cancels.cancel(msg.Cancel.ProcessID, msg.Cancel.SecretKey)
+			return errCancelRequest
+		}
 
 		user := msg.Parameters["user"]
 		if user != c.cfg.User {
@@ -115,19 +187,14 @@ func (c *Connection) startup() error {
 		if err := c.writer.WriteAuthOk(); err != nil {
 			return err
 		}
-		serverParams := [][2]string{
-			{"server_version", "mulldb-0.1"},
-			{"server_encoding", "UTF8"},
-			{"client_encoding", "UTF8"},
-			{"DateStyle", "ISO, MDY"},
-			{"standard_conforming_strings", "on"},
+		if name, ok := msg.Parameters["application_name"]; ok {
+			c.params.Set("application_name", name, false)
 		}
-		for _, p := range serverParams {
-			if err := c.writer.WriteParameterStatus(p[0], p[1]); err != nil {
-				return err
-			}
+		if err := c.reportParams(); err != nil {
+			return err
 		}
-		if err := c.writer.WriteBackendKeyData(int32(os.Getpid()), 0); err != nil {
+		c.pid, c.cancel = c.cancels.register()
+		if err := c.writer.WriteBackendKeyData(c.pid, c.cancel.secret); err != nil {
 			return err
 		}
 		if err := c.writer.WriteReadyForQuery(pgwire.TxIdle); err != nil {
@@ -142,6 +209,9 @@ func (c *Connection) startup() error {
 func (c *Connection) queryLoop() {
 	for {
 		msgType, payload, err := c.reader.ReadMessage()
+		if c.listener != nil {
+			c.listener.setIdle(false)
+		}
 		if err != nil {
 			if err != io.EOF {
 				log.Printf("connection %s: read: %v", c.conn.RemoteAddr(), err)
@@ -152,19 +222,40 @@ func (c *Connection) queryLoop() {
 		switch msgType {
 		case pgwire.MsgQuery:
 			query := stripNull(payload)
-			if err := c.handleQuery(query); err != nil {
-				log.Printf("connection %s: write: %v", c.conn.RemoteAddr(), err)
-				return
+			err = c.handleQuery(query)
+			if err == nil {
+				err = c.sendReady()
+			}
+			c.ignoreTillSync = false
+		case pgwire.MsgParse, pgwire.MsgBind, pgwire.MsgDescribe, pgwire.MsgExecute, pgwire.MsgClose:
+			if !c.ignoreTillSync {
+				err = c.handleExtended(msgType, payload)
 			}
+		case pgwire.MsgSync:
+			c.ignoreTillSync = false
+			if c.txState == txStatusIdle {
+				clear(c.portals)
+			}
+			err = c.sendReady()
+		case pgwire.MsgFlush:
+			err = c.writer.Flush()
+		case pgwire.MsgCopyData, pgwire.MsgCopyDone, pgwire.MsgCopyFail:
+			// The rest of a COPY that failed before or while its data
+			// came in; dropped, as PostgreSQL does.
 		case pgwire.MsgTerminate:
 			return
 		default:
 			log.Printf("connection %s: unsupported message type '%c'", c.conn.RemoteAddr(), msgType)
 		}
+		if err != nil {
+			log.Printf("connection %s: write: %v", c.conn.RemoteAddr(), err)
+			return
+		}
 	}
 }
 
-// handleQuery processes a single SQL query string and writes the response.
+// handleQuery processes a single SQL query string and writes the response,
+// up to but not including ReadyForQuery.
 func (c *Connection) handleQuery(query string) error {
 	query = strings.TrimSpace(query)
 	query = strings.TrimRight(query, ";")
@@ -174,15 +265,16 @@ func (c *Connection) handleQuery(query string) error {
 		if err := c.writer.WriteEmptyQueryResponse(); err != nil {
 			return err
 		}
-		return c.sendReady()
+		return nil
 	}
 
 	upper := strings.ToUpper(query)
 
 	// Handle transaction control statements before anything else.
+	if readOnly, _, ok := parseBegin(query); ok {
+		return c.handleBegin(query, readOnly)
+	}
 	switch {
-	case upper == "BEGIN" || upper == "BEGIN TRANSACTION" || upper == "START TRANSACTION":
-		return c.handleBegin(query)
 	case upper == "COMMIT" || upper == "END" || upper == "END TRANSACTION":
 		return c.handleCommit(query)
 	case strings.HasPrefix(upper, "ROLLBACK TO SAVEPOINT ") || strings.HasPrefix(upper, "ROLLBACK TO "):
@@ -197,119 +289,69 @@ func (c *Connection) handleQuery(query string) error {
 
 	// In failed-transaction state, reject everything except ROLLBACK.
 	if c.txState == txStatusFailed {
-		if werr := c.writer.WriteErrorResponse("ERROR", "25P02",
-			"current transaction is aborted, commands ignored until end of transaction block"); werr != nil {
-			return werr
-		}
-		if c.cfg.LogLevel >= 1 {
-			log.Printf("[SQL] ERROR  %s — transaction aborted", query)
-		}
-		return c.sendReady()
+		return c.sendQueryError(query, "25P02",
+			"current transaction is aborted, commands ignored until end of transaction block")
 	}
 
-	// Handle SET commands that psql sends during startup — our parser
-	// doesn't cover SET, so we return a stub response.
-	if strings.HasPrefix(upper, "SET") {
-		c.handleSetTrace(upper)
-		c.handleSetFsync(upper)
-		if err := c.writer.WriteCommandComplete("SET"); err != nil {
-			return err
-		}
-		if c.cfg.LogLevel >= 1 {
-			log.Printf("[SQL] OK     %s — SET", query)
-		}
-		return c.sendReady()
+	// Cursors hold per-connection state, so they are handled here rather
+	// than in the executor.
+	switch {
+	case strings.HasPrefix(upper, "DECLARE "):
+		return c.handleDeclare(query)
+	case strings.HasPrefix(upper, "FETCH "):
+		return c.handleFetch(query)
+	case strings.HasPrefix(upper, "CLOSE "):
+		return c.handleClose(query)
 	}
 
-	// Handle SHOW TRACE — return the stored trace from the last traced statement.
-	if upper == "SHOW TRACE" {
-		result := executor.TraceToResult(c.lastTrace)
-		return c.sendResult(result, query)
+	// SET and SHOW work on parameters tracked per connection, so they are
+	// handled here rather than in the executor.
+	if readOnly, explicit, ok := parseSetTransaction(query); ok {
+		return c.handleSetTransaction(query, readOnly, explicit)
+	}
+	if strings.HasPrefix(upper, "SET") {
+		return c.handleSet(query)
 	}
 
-	// Handle SHOW FSYNC — return the current fsync setting.
-	if upper == "SHOW FSYNC" {
-		val := "on"
-		if !c.exec.GetFsync() {
-			val = "off"
-		}
-		result := &executor.Result{
-			Columns: []executor.Column{{Name: "fsync", TypeOID: executor.OIDText, TypeSize: -1}},
-			Rows:    [][][]byte{{[]byte(val)}},
-			Tag:     "SHOW",
+	if name, ok := parseShow(query); ok {
+		result, err := c.showResult(name)
+		if err != nil {
+			return c.sendQueryError(query, queryErrorCode(err), err.Error())
 		}
 		return c.sendResult(result, query)
 	}
 
 	// Execute via the real parser + executor + storage path.
+	ctx, done := c.cancel.statement()
+	defer done()
+	exec := c.exec.WithContext(ctx)
 	var result *executor.Result
 	var err error
-	if c.traceEnabled {
+	if c.tracing() {
 		var tr *executor.Trace
-		result, tr, err = c.exec.ExecuteTraced(query)
+		result, tr, err = exec.ExecuteTraced(query)
 		c.lastTrace = tr
 	} else {
-		result, err = c.exec.Execute(query)
+		result, err = exec.Execute(query)
 		c.lastTrace = nil
 	}
 	if err != nil {
-		code := "42000" // fallback
-		var qe *executor.QueryError
-		if errors.As(err, &qe) {
-			code = qe.Code
-		}
-
-		// Check for DDL-in-transaction error.
-		var activeTxErr *storage.ActiveTxError
-		if errors.As(err, &activeTxErr) {
-			code = "25001"
-		}
-
-		if werr := c.writer.WriteErrorResponse("ERROR", code, err.Error()); werr != nil {
-			return werr
-		}
-		if c.cfg.LogLevel >= 1 {
-			log.Printf("[SQL] ERROR  %s — %s", query, err.Error())
-		}
-		// If in a transaction, transition to failed state on any error.
-		if c.txState == txStatusActive {
-			c.txState = txStatusFailed
-		}
-		return c.sendReady()
+		return c.sendQueryError(query, queryErrorCode(err), err.Error())
 	}
-
-	// SELECT: send RowDescription + DataRows + CommandComplete.
-	if result.Columns != nil {
-		cols := make([]pgwire.ColumnInfo, len(result.Columns))
-		for i, rc := range result.Columns {
-			cols[i] = pgwire.ColumnInfo{
-				Name:         rc.Name,
-				DataTypeOID:  rc.TypeOID,
-				DataTypeSize: rc.TypeSize,
-				TypeModifier: -1,
-			}
-		}
-		if err := c.writer.WriteRowDescription(cols); err != nil {
-			return err
-		}
-		for _, row := range result.Rows {
-			if err := c.writer.WriteDataRow(row); err != nil {
-				return err
-			}
-		}
-	}
-
-	if err := c.writer.WriteCommandComplete(result.Tag); err != nil {
+	if err := c.sendNotices(result, query); err != nil {
 		return err
 	}
-	if c.cfg.LogLevel >= 1 {
-		log.Printf("[SQL] OK     %s — %s", query, result.Tag)
+	if result.Channel != nil {
+		c.runChannelCommand(result.Channel)
 	}
-	return c.sendReady()
+	if result.CopyIn != nil {
+		return c.receiveCopyIn(exec, query, result.CopyIn)
+	}
+	return c.sendResult(result, query)
 }
 
-// handleBegin starts a new transaction.
-func (c *Connection) handleBegin(query string) error {
+// handleBegin starts a new transaction, read-only if requested.
+func (c *Connection) handleBegin(query string, readOnly bool) error {
 	if c.txState == txStatusActive || c.txState == txStatusFailed {
 		// PostgreSQL sends a WARNING but stays in the same transaction.
 		// We'll just send the CommandComplete and stay in the current tx.
@@ -320,8 +362,10 @@ func (c *Connection) handleBegin(query string) error {
 	} else {
 		// Start a new transaction.
 		c.txEngine = storage.NewTxEngine(c.baseExec.Engine())
+		c.txEngine.SetReadOnly(readOnly)
 		c.exec = c.baseExec.WithEngine(c.txEngine)
 		c.txState = txStatusActive
+		c.params.Begin()
 	}
 
 	if err := c.writer.WriteCommandComplete("BEGIN"); err != nil {
@@ -330,7 +374,7 @@ func (c *Connection) handleBegin(query string) error {
 	if c.cfg.LogLevel >= 1 {
 		log.Printf("[SQL] OK     %s — BEGIN", query)
 	}
-	return c.sendReady()
+	return nil
 }
 
 // handleCommit commits the current transaction.
@@ -344,7 +388,7 @@ func (c *Connection) handleCommit(query string) error {
 		if c.cfg.LogLevel >= 1 {
 			log.Printf("[SQL] OK     %s — ROLLBACK (aborted tx)", query)
 		}
-		return c.sendReady()
+		return nil
 	}
 
 	if c.txState == txStatusActive {
@@ -360,15 +404,12 @@ func (c *Connection) handleCommit(query string) error {
 				code = errCode
 			}
 			c.rollbackTx()
-			if werr := c.writer.WriteErrorResponse("ERROR", code, err.Error()); werr != nil {
-				return werr
-			}
-			if c.cfg.LogLevel >= 1 {
-				log.Printf("[SQL] ERROR  %s — %s", query, err.Error())
-			}
-			return c.sendReady()
+			return c.sendQueryError(query, code, err.Error())
 		}
+		c.params.Commit()
+		channelCmds := c.txChannel
 		c.rollbackTx() // Clean up tx state (exec is reset, but changes are committed)
+		c.commitChannelCommands(channelCmds)
 	}
 
 	if err := c.writer.WriteCommandComplete("COMMIT"); err != nil {
@@ -377,7 +418,7 @@ func (c *Connection) handleCommit(query string) error {
 	if c.cfg.LogLevel >= 1 {
 		log.Printf("[SQL] OK     %s — COMMIT", query)
 	}
-	return c.sendReady()
+	return nil
 }
 
 // handleRollback rolls back the current transaction.
@@ -389,7 +430,7 @@ func (c *Connection) handleRollback(query string) error {
 	if c.cfg.LogLevel >= 1 {
 		log.Printf("[SQL] OK     %s — ROLLBACK", query)
 	}
-	return c.sendReady()
+	return nil
 }
 
 // handleSavepoint accepts SAVEPOINT commands (e.g. from psql's
@@ -402,7 +443,7 @@ func (c *Connection) handleSavepoint(query string) error {
 	if c.cfg.LogLevel >= 1 {
 		log.Printf("[SQL] OK     %s — SAVEPOINT (no-op)", query)
 	}
-	return c.sendReady()
+	return nil
 }
 
 // handleReleaseSavepoint accepts RELEASE SAVEPOINT commands and
@@ -414,18 +455,28 @@ func (c *Connection) handleReleaseSavepoint(query string) error {
 	if c.cfg.LogLevel >= 1 {
 		log.Printf("[SQL] OK     %s — RELEASE SAVEPOINT (no-op)", query)
 	}
-	return c.sendReady()
+	return nil
 }
 
 // rollbackTx discards the transaction overlay and restores the base executor.
+// Parameters set inside the transaction are discarded too, unless Commit
+// already folded them into the session, and open cursors and portals are
+// closed.
 func (c *Connection) rollbackTx() {
+	c.closeCursors()
+	clear(c.portals)
 	c.txState = txStatusIdle
 	c.txEngine = nil
+	c.txChannel = nil
 	c.exec = c.baseExec
+	c.params.Rollback()
+	c.exec.SetStatementTimeout(c.statementTimeout())
 }
 
 // sendReady sends ReadyForQuery with the appropriate transaction status
-// indicator and flushes the write buffer.
+// indicator and flushes the write buffer. Outside a transaction block,
+// notifications for the session go out first, and until the next message
+// arrives they are sent as they come.
 func (c *Connection) sendReady() error {
 	var status byte
 	switch c.txState {
@@ -436,10 +487,39 @@ func (c *Connection) sendReady() error {
 	case txStatusFailed:
 		status = pgwire.TxFailed
 	}
+	if err := c.reportParams(); err != nil {
+		return err
+	}
+	notify := c.listener != nil && c.txState == txStatusIdle
+	if notify {
+		if err := c.listener.flush(); err != nil {
+			return err
+		}
+	}
 	if err := c.writer.WriteReadyForQuery(status); err != nil {
 		return err
 	}
-	return c.writer.Flush()
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	if notify {
+		return c.listener.setIdle(true)
+	}
+	return nil
+}
+
+// reportParams sends a ParameterStatus message for each reported
+// parameter whose value the client has not been told yet. Drivers such
+// as pgx and JDBC track these values, so like PostgreSQL we report a
+// change made by SET, or undone by ROLLBACK, before the next
+// ReadyForQuery.
+func (c *Connection) reportParams() error {
+	for _, p := range c.params.changedReported() {
+		if err := c.writer.WriteParameterStatus(p[0], p[1]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // sendFatalError writes a FATAL error response and flushes. Errors are
@@ -449,54 +529,146 @@ func (c *Connection) sendFatalError(code, message string) {
 	c.writer.Flush()
 }
 
-// handleSetTrace checks if the SET command is "SET trace = on/off" and
-// updates the connection's tracing state accordingly.
-func (c *Connection) handleSetTrace(upper string) {
-	// Normalize: remove spaces around '='.
-	normalized := strings.Join(strings.Fields(upper), " ")
-	switch {
-	case strings.HasPrefix(normalized, "SET TRACE = ON"),
-		strings.HasPrefix(normalized, "SET TRACE=ON"),
-		strings.HasPrefix(normalized, "SET TRACE TO ON"):
-		c.traceEnabled = true
-	case strings.HasPrefix(normalized, "SET TRACE = OFF"),
-		strings.HasPrefix(normalized, "SET TRACE=OFF"),
-		strings.HasPrefix(normalized, "SET TRACE TO OFF"):
-		c.traceEnabled = false
-		c.lastTrace = nil
+// sendQueryError reports a failed statement. Inside a transaction the
+// transaction enters the failed state, as for any other error. In the
+// extended protocol the rest of the message sequence is skipped.
+func (c *Connection) sendQueryError(query, code, message string) error {
+	if werr := c.writer.WriteErrorResponse("ERROR", code, message); werr != nil {
+		return werr
 	}
+	if c.cfg.LogLevel >= 1 {
+		log.Printf("[SQL] ERROR  %s — %s", query, message)
+	}
+	if c.txState == txStatusActive {
+		c.txState = txStatusFailed
+	}
+	c.ignoreTillSync = true
+	return nil
 }
 
-// handleSetFsync checks if the SET command is "SET FSYNC = ON/OFF" and
-// updates the engine's fsync setting accordingly.
-func (c *Connection) handleSetFsync(upper string) {
-	normalized := strings.Join(strings.Fields(upper), " ")
-	switch {
-	case strings.HasPrefix(normalized, "SET FSYNC = ON"),
-		strings.HasPrefix(normalized, "SET FSYNC=ON"),
-		strings.HasPrefix(normalized, "SET FSYNC TO ON"):
-		c.exec.SetFsync(true)
-	case strings.HasPrefix(normalized, "SET FSYNC = OFF"),
-		strings.HasPrefix(normalized, "SET FSYNC=OFF"),
-		strings.HasPrefix(normalized, "SET FSYNC TO OFF"):
-		c.exec.SetFsync(false)
+// queryErrorCode returns the SQLSTATE for an error from the executor.
+func queryErrorCode(err error) string {
+	// TRUNCATE or view DDL inside a transaction.
+	var activeTxErr *storage.ActiveTxError
+	if errors.As(err, &activeTxErr) {
+		return "25001"
+	}
+	var qe *executor.QueryError
+	if errors.As(err, &qe) {
+		return qe.Code
 	}
+	return "42000" // fallback
 }
 
-// sendResult writes a query result (RowDescription + DataRows + CommandComplete)
-// and flushes. Used for internal results like SHOW TRACE.
-func (c *Connection) sendResult(result *executor.Result, query string) error {
-	if result.Columns != nil {
-		cols := make([]pgwire.ColumnInfo, len(result.Columns))
-		for i, rc := range result.Columns {
-			cols[i] = pgwire.ColumnInfo{
-				Name:         rc.Name,
-				DataTypeOID:  rc.TypeOID,
-				DataTypeSize: rc.TypeSize,
-				TypeModifier: -1,
+// handleSet applies a SET command. trace is a per-connection parameter
+// that honours SET LOCAL; fsync is server-wide and can only be set for the
+// session. statement_timeout limits the statements of the connection.
+// Other parameters are recorded for SHOW but have no effect, and SET forms
+// that do not name a single parameter are accepted as no-ops, so that
+// driver handshakes do not fail.
+func (c *Connection) handleSet(query string) error {
+	name, value, local, ok := parseSet(query)
+	if ok {
+		if readOnlyParams[name] {
+			return c.sendQueryError(query, "55P02", fmt.Sprintf("parameter %q cannot be changed", name))
+		}
+		if name == "statement_timeout" && value != "default" {
+			if _, err := parseTimeout(value); err != nil {
+				return c.sendQueryError(query, "22023",
+					fmt.Sprintf(`invalid value for parameter "statement_timeout": %q`, value))
 			}
 		}
-		if err := c.writer.WriteRowDescription(cols); err != nil {
+		switch {
+		case name == "fsync" && local:
+			return c.sendQueryError(query, "55P02", `parameter "fsync" cannot be set locally`)
+		case name == "fsync":
+			switch value {
+			case "on":
+				c.exec.SetFsync(true)
+			case "off":
+				c.exec.SetFsync(false)
+			}
+		case !c.params.Set(name, value, local):
+			// PostgreSQL only warns about SET LOCAL outside a transaction.
+			if werr := c.writer.WriteNoticeResponse("WARNING", "25P01",
+				"SET LOCAL can only be used in transaction blocks"); werr != nil {
+				return werr
+			}
+		}
+		if name == "trace" && !c.tracing() {
+			c.lastTrace = nil
+		}
+		if name == "statement_timeout" {
+			c.exec.SetStatementTimeout(c.statementTimeout())
+		}
+	}
+	if err := c.writer.WriteCommandComplete("SET"); err != nil {
+		return err
+	}
+	if c.cfg.LogLevel >= 1 {
+		log.Printf("[SQL] OK     %s — SET", query)
+	}
+	return nil
+}
+
+// showResult answers SHOW name: a single row with the value of the
+// parameter as set on this connection, or else its default. SHOW TRACE
+// returns the trace of the last traced statement instead.
+func (c *Connection) showResult(name string) (*executor.Result, error) {
+	var val string
+	switch name {
+	case "trace":
+		return executor.TraceToResult(c.lastTrace), nil
+	case "fsync":
+		val = "on"
+		if !c.exec.GetFsync() {
+			val = "off"
+		}
+	case "statement_timeout":
+		val = formatTimeout(c.statementTimeout())
+	default:
+		v, set := c.params.Get(name)
+		if !set || v == "default" {
+			d, known := paramDefault(name)
+			if !set && !known {
+				return nil, &executor.QueryError{
+					Code:    "42704",
+					Message: fmt.Sprintf("unrecognized configuration parameter %q", name),
+				}
+			}
+			v = d
+		}
+		val = v
+	}
+	return &executor.Result{
+		Columns: []executor.Column{{Name: name, TypeOID: executor.OIDText, TypeSize: -1}},
+		Rows:    [][][]byte{{[]byte(val)}},
+		Tag:     "SHOW",
+	}, nil
+}
+
+// statementTimeout returns the statement_timeout in effect: the value SET
+// on the connection, or else the configured default.
+func (c *Connection) statementTimeout() time.Duration {
+	if v, ok := c.params.Get("statement_timeout"); ok && v != "default" {
+		if d, err := parseTimeout(v); err == nil {
+			return d
+		}
+	}
+	return c.cfg.StatementTimeout
+}
+
+// tracing reports whether statement tracing is enabled for this connection.
+func (c *Connection) tracing() bool {
+	v, _ := c.params.Get("trace")
+	return v == "on"
+}
+
+// sendResult writes a query result (RowDescription + DataRows, or the
+// data of a COPY, + CommandComplete).
+func (c *Connection) sendResult(result *executor.Result, query string) error {
+	if result.Columns != nil {
+		if err := c.writeRowDescription(result.Columns); err != nil {
 			return err
 		}
 		for _, row := range result.Rows {
@@ -505,13 +677,53 @@ func (c *Connection) sendResult(result *executor.Result, query string) error {
 			}
 		}
 	}
+	if result.CopyOut != nil {
+		if err := c.sendCopyOut(result.CopyOut); err != nil {
+			return err
+		}
+	}
 	if err := c.writer.WriteCommandComplete(result.Tag); err != nil {
 		return err
 	}
 	if c.cfg.LogLevel >= 1 {
 		log.Printf("[SQL] OK     %s — %s", query, result.Tag)
 	}
-	return c.sendReady()
+	return nil
+}
+
+// sendNotices sends the notices of a statement's result to the client and
+// logs them.
+func (c *Connection) sendNotices(result *executor.Result, query string) error {
+	for _, msg := range result.Notices {
+		log.Printf("[NOTICE] %s — %s", query, msg)
+		if err := c.writer.WriteNoticeResponse("NOTICE", "00000", msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRowDescription describes result columns, all in text format.
+func (c *Connection) writeRowDescription(columns []executor.Column) error {
+	return c.writeRowDescriptionFormats(columns, nil)
+}
+
+// writeRowDescriptionFormats describes result columns sent in the given
+// formats; nil means all text.
+func (c *Connection) writeRowDescriptionFormats(columns []executor.Column, formats []int16) error {
+	cols := make([]pgwire.ColumnInfo, len(columns))
+	for i, rc := range columns {
+		cols[i] = pgwire.ColumnInfo{
+			Name:         rc.Name,
+			DataTypeOID:  rc.TypeOID,
+			DataTypeSize: rc.TypeSize,
+			TypeModifier: -1,
+		}
+		if formats != nil {
+			cols[i].FormatCode = formats[i]
+		}
+	}
+	return c.writer.WriteRowDescription(cols)
 }
 
 // sqlstateForStorageError maps storage-layer errors to SQLSTATE codes.
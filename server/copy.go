@@ -0,0 +1,72 @@
+package server
+
+import (
+	"fmt"
+
+	"mulldb/executor"
+	"mulldb/pgwire"
+)
+
+// receiveCopyIn runs the data phase of a COPY ... FROM STDIN: it sends
+// CopyInResponse, collects the CopyData messages up to CopyDone and has
+// exec load them. A CopyFail from the client ends the COPY with an error
+// and nothing loaded.
+func (c *Connection) receiveCopyIn(exec *executor.Executor, query string, in *executor.CopyIn) error {
+	if err := c.writer.WriteCopyInResponse(in.Columns); err != nil {
+		return err
+	}
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	var data []byte
+	for {
+		msgType, payload, err := c.reader.ReadMessage()
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case pgwire.MsgCopyData:
+			data = append(data, payload...)
+		case pgwire.MsgCopyDone:
+			// Progress notices go out as the load runs; a client that is
+			// gone is noticed once it is over.
+			var werr error
+			progress := func(rows int64) {
+				if werr == nil {
+					werr = c.writer.WriteNoticeResponse("NOTICE", "00000", fmt.Sprintf("COPY: %d rows loaded", rows))
+				}
+				if werr == nil {
+					werr = c.writer.Flush()
+				}
+			}
+			result, err := exec.CopyFrom(query, data, progress)
+			if werr != nil {
+				return werr
+			}
+			if err != nil {
+				return c.sendQueryError(query, queryErrorCode(err), err.Error())
+			}
+			return c.sendResult(result, query)
+		case pgwire.MsgCopyFail:
+			return c.sendQueryError(query, "57014", "COPY from stdin failed: "+stripNull(payload))
+		case pgwire.MsgFlush, pgwire.MsgSync:
+			// Ignored while copying, as PostgreSQL does.
+		default:
+			return c.sendQueryError(query, "08P01", fmt.Sprintf("unexpected message type '%c' during COPY from stdin", msgType))
+		}
+	}
+}
+
+// sendCopyOut sends the data of a COPY ... TO STDOUT: a CopyOutResponse,
+// a CopyData message per line and CopyDone. The CommandComplete follows.
+func (c *Connection) sendCopyOut(out *executor.CopyOut) error {
+	if err := c.writer.WriteCopyOutResponse(out.Columns); err != nil {
+		return err
+	}
+	for _, line := range out.Lines {
+		if err := c.writer.WriteCopyData(line); err != nil {
+			return err
+		}
+	}
+	return c.writer.WriteCopyDone()
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"mulldb/config"
+)
+
+func TestCopyQueryToStdout(t *testing.T) {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, startServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	for _, sql := range []string{
+		"CREATE TABLE scores (id INTEGER, name TEXT, score INTEGER)",
+		"INSERT INTO scores VALUES (1, 'ann', 70), (2, 'bob, jr.', 95), (3, 'cy', 80), (4, 'dee', 95), (5, 'eve', 60)",
+	} {
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The top three rows, in the query's order.
+	var buf bytes.Buffer
+	tag, err := conn.PgConn().CopyTo(ctx, &buf,
+		"COPY (SELECT id, name, score FROM scores ORDER BY score DESC, id LIMIT 3) TO STDOUT WITH (FORMAT csv)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2,\"bob, jr.\",95\n4,dee,95\n3,cy,80\n"; buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+	if tag.String() != "COPY 3" || tag.RowsAffected() != 3 {
+		t.Errorf("tag = %q, want COPY 3", tag)
+	}
+
+	// The connection is ready for the next statement.
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*) FROM scores").Scan(&n); err != nil || n != 5 {
+		t.Errorf("COUNT(*) = %d, %v, want 5", n, err)
+	}
+
+	// A failing query is an ordinary error.
+	buf.Reset()
+	if _, err := conn.PgConn().CopyTo(ctx, &buf, "COPY (SELECT id FROM missing) TO STDOUT"); err == nil {
+		t.Error("COPY from a missing table succeeded")
+	}
+}
+
+func TestCopyFromStdin(t *testing.T) {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, startServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	if _, err := conn.Exec(ctx, "CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total NUMERIC(10,2))"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough rows for several CopyData messages and insert batches.
+	var data strings.Builder
+	for i := 1; i <= 2500; i++ {
+		fmt.Fprintf(&data, "%d,\"c%d, inc.\",%d.5\n", i, i, i)
+	}
+	tag, err := conn.PgConn().CopyFrom(ctx, strings.NewReader(data.String()), "COPY orders FROM STDIN WITH (FORMAT csv)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.String() != "COPY 2500" {
+		t.Errorf("tag = %q, want COPY 2500", tag)
+	}
+	var n int
+	var customer string
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*), MAX(customer) FROM orders WHERE total > 100").Scan(&n, &customer); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2401 || customer != "c999, inc." {
+		t.Errorf("COUNT(*) = %d, MAX(customer) = %q, want 2401 and c999, inc.", n, customer)
+	}
+
+	// A column list in text format, and the table back out.
+	if _, err := conn.PgConn().CopyFrom(ctx, strings.NewReader("9001\t\\N\n"), "COPY orders (id, customer) FROM STDIN"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := conn.PgConn().CopyTo(ctx, &buf, "COPY orders (id, customer, total) TO STDOUT"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), "\n9001\t\\N\t\\N\n") || strings.Count(buf.String(), "\n") != 2501 {
+		t.Errorf("COPY TO ends %q, want 2501 lines ending in 9001", buf.String()[max(0, buf.Len()-40):])
+	}
+
+	// Bad data fails the whole COPY, and the session goes on.
+	_, err = conn.PgConn().CopyFrom(ctx, strings.NewReader("1\tdup\t1\n"), "COPY orders FROM STDIN")
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		t.Errorf("duplicate key: err = %v, want 23505", err)
+	}
+	_, err = conn.PgConn().CopyFrom(ctx, strings.NewReader(""), "COPY missing FROM STDIN")
+	if !errors.As(err, &pgErr) || pgErr.Code != "42P01" {
+		t.Errorf("missing table: err = %v, want 42P01", err)
+	}
+	// A client that gives up mid-stream sends CopyFail.
+	r := io.MultiReader(strings.NewReader("5000\tlate\t1\n"), iotest.ErrReader(errors.New("client gave up")))
+	if _, err := conn.PgConn().CopyFrom(ctx, r, "COPY orders FROM STDIN"); err == nil {
+		t.Error("COPY with a failing reader succeeded")
+	}
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*) FROM orders").Scan(&n); err != nil || n != 2501 {
+		t.Errorf("COUNT(*) = %d, %v, want 2501", n, err)
+	}
+}
+
+func TestCopyFromStdinProgress(t *testing.T) {
+	ctx := context.Background()
+	cfg, err := pgx.ParseConfig(startServer(t, func(cfg *config.Config) { cfg.CopyProgress = 400 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notices []string
+	cfg.OnNotice = func(_ *pgconn.PgConn, n *pgconn.Notice) {
+		notices = append(notices, n.Severity+" "+n.Message)
+	}
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	if _, err := conn.Exec(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// One notice per 400 rows, ahead of the command tag; none for the
+	// 100 rows past the last mark.
+	var data strings.Builder
+	for i := 1; i <= 2100; i++ {
+		fmt.Fprintf(&data, "%d\titem %d\n", i, i)
+	}
+	tag, err := conn.PgConn().CopyFrom(ctx, strings.NewReader(data.String()), "COPY items FROM STDIN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.String() != "COPY 2100" {
+		t.Errorf("tag = %q, want COPY 2100", tag)
+	}
+	var want []string
+	for n := 400; n <= 2100; n += 400 {
+		want = append(want, fmt.Sprintf("NOTICE COPY: %d rows loaded", n))
+	}
+	if !slices.Equal(notices, want) {
+		t.Errorf("notices = %q, want %q", notices, want)
+	}
+
+	// A load that fails part way has reported what it got through, and
+	// stored nothing.
+	notices = nil
+	data.Reset()
+	for i := 3001; i <= 3600; i++ {
+		fmt.Fprintf(&data, "%d\titem %d\n", i, i)
+	}
+	data.WriteString("1\tduplicate\n")
+	_, err = conn.PgConn().CopyFrom(ctx, strings.NewReader(data.String()), "COPY items FROM STDIN")
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		t.Fatalf("duplicate key: got %v, want 23505", err)
+	}
+	if want := []string{"NOTICE COPY: 400 rows loaded"}; !slices.Equal(notices, want) {
+		t.Errorf("notices = %q, want %q", notices, want)
+	}
+	var n int
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*) FROM items").Scan(&n); err != nil || n != 2100 {
+		t.Errorf("COUNT(*) = %d, %v, want 2100", n, err)
+	}
+}
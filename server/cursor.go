@@ -0,0 +1,197 @@
+package server
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"mulldb/executor"
+)
+
+// handleDeclare opens a cursor: DECLARE name [NO SCROLL] CURSOR
+// [WITHOUT HOLD] FOR select. Cursors live until CLOSE or the end of the
+// transaction, so DECLARE is only allowed inside one.
+func (c *Connection) handleDeclare(query string) error {
+	name, sel, hold, ok := parseDeclare(query)
+	switch {
+	case !ok:
+		return c.sendQueryError(query, "42601", "syntax error in DECLARE CURSOR")
+	case hold:
+		return c.sendQueryError(query, "0A000", "DECLARE CURSOR WITH HOLD is not supported")
+	case c.txState != txStatusActive:
+		return c.sendQueryError(query, "25P01", "DECLARE CURSOR can only be used in transaction blocks")
+	}
+	if _, exists := c.cursors[name]; exists {
+		return c.sendQueryError(query, "42P03", `cursor "`+name+`" already exists`)
+	}
+
+	cur, err := c.exec.OpenCursor(sel)
+	if err != nil {
+		return c.sendQueryError(query, queryErrorCode(err), err.Error())
+	}
+	if c.cursors == nil {
+		c.cursors = make(map[string]*executor.Cursor)
+	}
+	c.cursors[name] = cur
+
+	if err := c.writer.WriteCommandComplete("DECLARE CURSOR"); err != nil {
+		return err
+	}
+	if c.cfg.LogLevel >= 1 {
+		log.Printf("[SQL] OK     %s — DECLARE CURSOR", query)
+	}
+	return nil
+}
+
+// handleFetch returns the next batch of rows from a cursor.
+func (c *Connection) handleFetch(query string) error {
+	name, count, ok := parseFetch(query)
+	if !ok {
+		return c.sendQueryError(query, "42601", "syntax error in FETCH")
+	}
+	cur, exists := c.cursors[name]
+	if !exists {
+		return c.sendQueryError(query, "34000", `cursor "`+name+`" does not exist`)
+	}
+	rows := cur.Fetch(count)
+	if err := cur.Err(); err != nil {
+		return c.sendQueryError(query, queryErrorCode(err), err.Error())
+	}
+	return c.sendResult(&executor.Result{
+		Columns: cur.Columns,
+		Rows:    rows,
+		Tag:     "FETCH " + strconv.Itoa(len(rows)),
+	}, query)
+}
+
+// handleClose closes one cursor, or every cursor with CLOSE ALL.
+func (c *Connection) handleClose(query string) error {
+	fields := strings.Fields(query)
+	if len(fields) != 2 {
+		return c.sendQueryError(query, "42601", "syntax error in CLOSE")
+	}
+	name := cursorName(fields[1])
+	if strings.EqualFold(fields[1], "ALL") {
+		c.closeCursors()
+	} else if cur, exists := c.cursors[name]; exists {
+		cur.Close()
+		delete(c.cursors, name)
+	} else {
+		return c.sendQueryError(query, "34000", `cursor "`+name+`" does not exist`)
+	}
+
+	if err := c.writer.WriteCommandComplete("CLOSE CURSOR"); err != nil {
+		return err
+	}
+	if c.cfg.LogLevel >= 1 {
+		log.Printf("[SQL] OK     %s — CLOSE CURSOR", query)
+	}
+	return nil
+}
+
+// closeCursors closes every open cursor of the connection.
+func (c *Connection) closeCursors() {
+	for name, cur := range c.cursors {
+		cur.Close()
+		delete(c.cursors, name)
+	}
+}
+
+// parseDeclare splits "DECLARE name [options] CURSOR [WITH[OUT] HOLD] FOR
+// select" into the cursor name and its query, which is returned verbatim.
+// BINARY, INSENSITIVE, SCROLL and NO SCROLL are accepted and ignored:
+// results are always text, and FETCH only moves forward.
+func parseDeclare(query string) (name, sel string, hold, ok bool) {
+	word, rest := nextWord(query)
+	if !strings.EqualFold(word, "DECLARE") {
+		return "", "", false, false
+	}
+	word, rest = nextWord(rest)
+	if word == "" {
+		return "", "", false, false
+	}
+	name = cursorName(word)
+	for {
+		word, rest = nextWord(rest)
+		switch strings.ToUpper(word) {
+		case "BINARY", "INSENSITIVE", "ASENSITIVE", "SCROLL", "NO":
+			continue
+		case "CURSOR":
+		default:
+			return "", "", false, false
+		}
+		break
+	}
+	word, rest = nextWord(rest)
+	switch strings.ToUpper(word) {
+	case "WITH", "WITHOUT":
+		hold = strings.EqualFold(word, "WITH")
+		if word, rest = nextWord(rest); !strings.EqualFold(word, "HOLD") {
+			return "", "", false, false
+		}
+		word, rest = nextWord(rest)
+	}
+	if !strings.EqualFold(word, "FOR") || rest == "" {
+		return "", "", false, false
+	}
+	return name, rest, hold, true
+}
+
+// nextWord splits s into its first whitespace-delimited word and the
+// remainder, both trimmed.
+func nextWord(s string) (word, rest string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " \t\r\n"); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+// parseFetch parses "FETCH [NEXT | FORWARD [n | ALL] | n | ALL] [FROM | IN]
+// name". count is -1 for ALL and 1 when omitted.
+func parseFetch(query string) (name string, count int64, ok bool) {
+	fields := strings.Fields(query)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "FETCH") {
+		return "", 0, false
+	}
+	fields = fields[1:]
+	count = 1
+	withCount := true
+	if len(fields) > 1 {
+		switch strings.ToUpper(fields[0]) {
+		case "NEXT":
+			fields = fields[1:]
+			withCount = false
+		case "FORWARD":
+			fields = fields[1:]
+		}
+	}
+	if withCount && len(fields) > 1 {
+		if strings.EqualFold(fields[0], "ALL") {
+			count = -1
+			fields = fields[1:]
+		} else if n, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			if n < 0 {
+				return "", 0, false
+			}
+			count = n
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 1 && (strings.EqualFold(fields[0], "FROM") || strings.EqualFold(fields[0], "IN")) {
+		fields = fields[1:]
+	}
+	if len(fields) != 1 || strings.EqualFold(fields[0], "FROM") || strings.EqualFold(fields[0], "IN") {
+		return "", 0, false
+	}
+	return cursorName(fields[0]), count, true
+}
+
+// cursorName normalises a cursor name the way identifiers are: lowercase
+// unless double-quoted.
+func cursorName(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return strings.ToLower(s)
+}
@@ -0,0 +1,55 @@
+package server
+
+import "testing"
+
+func TestParseDeclare(t *testing.T) {
+	tests := []struct {
+		query string
+		name  string
+		sel   string
+		hold  bool
+		ok    bool
+	}{
+		{"DECLARE c CURSOR FOR SELECT * FROM t", "c", "SELECT * FROM t", false, true},
+		{"declare Cur no scroll cursor without hold for select 'a  b' from t", "cur", "select 'a  b' from t", false, true},
+		{`DECLARE "MyCur" BINARY CURSOR WITH HOLD FOR SELECT 1`, "MyCur", "SELECT 1", true, true},
+		{"DECLARE c CURSOR SELECT 1", "", "", false, false},
+		{"DECLARE c CURSOR FOR", "", "", false, false},
+		{"DECLARE c FOR SELECT 1", "", "", false, false},
+		{"DECLARE c CURSOR WITH FOR SELECT 1", "", "", false, false},
+	}
+	for _, tt := range tests {
+		name, sel, hold, ok := parseDeclare(tt.query)
+		if ok != tt.ok || name != tt.name || sel != tt.sel || hold != tt.hold {
+			t.Errorf("parseDeclare(%q) = %q, %q, %v, %v; want %q, %q, %v, %v",
+				tt.query, name, sel, hold, ok, tt.name, tt.sel, tt.hold, tt.ok)
+		}
+	}
+}
+
+func TestParseFetch(t *testing.T) {
+	tests := []struct {
+		query string
+		name  string
+		count int64
+		ok    bool
+	}{
+		{"FETCH c", "c", 1, true},
+		{"FETCH NEXT FROM c", "c", 1, true},
+		{"FETCH 10 FROM c", "c", 10, true},
+		{"fetch forward 5 in C", "c", 5, true},
+		{"FETCH FORWARD FROM c", "c", 1, true},
+		{"FETCH ALL c", "c", -1, true},
+		{"FETCH FORWARD ALL FROM c", "c", -1, true},
+		{"FETCH -1 FROM c", "", 0, false},
+		{"FETCH 10 FROM", "", 0, false},
+		{"FETCH NEXT 5 FROM c", "", 0, false},
+	}
+	for _, tt := range tests {
+		name, count, ok := parseFetch(tt.query)
+		if ok != tt.ok || name != tt.name || count != tt.count {
+			t.Errorf("parseFetch(%q) = %q, %d, %v; want %q, %d, %v",
+				tt.query, name, count, ok, tt.name, tt.count, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,553 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"mulldb/executor"
+	"mulldb/pgwire"
+	"mulldb/storage"
+)
+
+// preparedStatement is a statement prepared by a Parse message.
+type preparedStatement struct {
+	query     string
+	paramOIDs []int32
+	// command marks statements the connection handles itself, such as
+	// BEGIN or SET, rather than passing them to the executor.
+	command bool
+	// show is the parameter of a SHOW statement, answered by the
+	// connection from its session parameters; "" for other statements.
+	show string
+}
+
+// portal is a prepared statement bound to parameter values. It runs when
+// first executed; Execute then hands out its rows, possibly over several
+// calls.
+type portal struct {
+	stmt    *preparedStatement
+	params  []any
+	formats []int16 // result column formats as given in Bind
+	result  *executor.Result
+	sent    int // rows of result already returned
+}
+
+// columnFormats returns the format of each of the n result columns: text
+// for all if Bind gave no formats, the one given for all, or one each.
+func (p *portal) columnFormats(n int) ([]int16, error) {
+	switch len(p.formats) {
+	case 0:
+		return make([]int16, n), nil
+	case 1:
+		formats := make([]int16, n)
+		for i := range formats {
+			formats[i] = p.formats[0]
+		}
+		return formats, nil
+	case n:
+		return p.formats, nil
+	}
+	return nil, &executor.QueryError{Code: "08P01",
+		Message: fmt.Sprintf("bind message has %d result formats but query has %d columns", len(p.formats), n)}
+}
+
+// handleExtended processes one Parse, Bind, Describe, Execute or Close
+// message. Responses are buffered until Sync or Flush; ReadyForQuery is
+// only sent for Sync.
+func (c *Connection) handleExtended(msgType byte, payload []byte) error {
+	switch msgType {
+	case pgwire.MsgParse:
+		m, err := pgwire.ParseParse(payload)
+		if err != nil {
+			return c.sendQueryError("", "08P01", err.Error())
+		}
+		return c.handleParse(m)
+	case pgwire.MsgBind:
+		m, err := pgwire.ParseBind(payload)
+		if err != nil {
+			return c.sendQueryError("", "08P01", err.Error())
+		}
+		return c.handleBind(m)
+	case pgwire.MsgDescribe:
+		m, err := pgwire.ParseDescribe(payload)
+		if err != nil {
+			return c.sendQueryError("", "08P01", err.Error())
+		}
+		return c.handleDescribe(m)
+	case pgwire.MsgExecute:
+		m, err := pgwire.ParseExecute(payload)
+		if err != nil {
+			return c.sendQueryError("", "08P01", err.Error())
+		}
+		return c.handleExecute(m)
+	case pgwire.MsgClose:
+		m, err := pgwire.ParseDescribe(payload)
+		if err != nil {
+			return c.sendQueryError("", "08P01", err.Error())
+		}
+		if m.Kind == 'S' {
+			delete(c.statements, m.Name)
+		} else {
+			delete(c.portals, m.Name)
+		}
+		return c.writer.WriteCloseComplete()
+	}
+	return nil
+}
+
+// handleParse prepares a statement. The unnamed statement is replaced by
+// each Parse; a named one must be closed before its name is reused.
+func (c *Connection) handleParse(m *pgwire.ParseMessage) error {
+	query := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(m.Query), ";"))
+	if _, exists := c.statements[m.Name]; exists && m.Name != "" {
+		return c.sendQueryError(query, "42P05", fmt.Sprintf("prepared statement %q already exists", m.Name))
+	}
+
+	stmt := &preparedStatement{query: query, paramOIDs: m.ParamOIDs}
+	if name, ok := parseShow(query); ok {
+		stmt.show = name
+	} else if isConnectionCommand(query) {
+		stmt.command = true
+	} else {
+		oids, err := c.exec.Prepare(query, m.ParamOIDs)
+		if err != nil {
+			return c.sendQueryError(query, queryErrorCode(err), err.Error())
+		}
+		stmt.paramOIDs = oids
+	}
+	if c.statements == nil {
+		c.statements = make(map[string]*preparedStatement)
+	}
+	c.statements[m.Name] = stmt
+	return c.writer.WriteParseComplete()
+}
+
+// handleBind creates a portal from a prepared statement, decoding its
+// parameter values by the statement's parameter types. The result formats
+// are checked against the result columns once the portal has run.
+func (c *Connection) handleBind(m *pgwire.BindMessage) error {
+	stmt, ok := c.statements[m.Statement]
+	if !ok {
+		return c.sendQueryError("", "26000", fmt.Sprintf("prepared statement %q does not exist", m.Statement))
+	}
+	if len(m.Params) != len(stmt.paramOIDs) {
+		return c.sendQueryError(stmt.query, "08P01", fmt.Sprintf(
+			"bind message supplies %d parameters, but prepared statement %q requires %d",
+			len(m.Params), m.Statement, len(stmt.paramOIDs)))
+	}
+	if n := len(m.ParamFormats); n > 1 && n != len(m.Params) {
+		return c.sendQueryError(stmt.query, "08P01", fmt.Sprintf(
+			"bind message has %d parameter formats but %d parameters", n, len(m.Params)))
+	}
+	if _, exists := c.portals[m.Portal]; exists && m.Portal != "" {
+		return c.sendQueryError(stmt.query, "42P03", fmt.Sprintf("portal %q already exists", m.Portal))
+	}
+	for _, f := range m.ResultFormats {
+		if f != pgwire.FormatText && f != pgwire.FormatBinary {
+			return c.sendQueryError(stmt.query, "08P01", fmt.Sprintf("unsupported format code %d", f))
+		}
+	}
+
+	params := make([]any, len(m.Params))
+	for i, data := range m.Params {
+		format := pgwire.FormatText
+		switch len(m.ParamFormats) {
+		case 0:
+		case 1:
+			format = m.ParamFormats[0]
+		default:
+			format = m.ParamFormats[i]
+		}
+		v, code, err := decodeParam(stmt.paramOIDs[i], format, data)
+		if err != nil {
+			return c.sendQueryError(stmt.query, code, fmt.Sprintf("parameter $%d: %v", i+1, err))
+		}
+		params[i] = v
+	}
+
+	if c.portals == nil {
+		c.portals = make(map[string]*portal)
+	}
+	c.portals[m.Portal] = &portal{stmt: stmt, params: params, formats: m.ResultFormats}
+	return c.writer.WriteBindComplete()
+}
+
+// handleDescribe describes a prepared statement's parameters and result
+// columns, or a portal's result columns. Neither runs the statement, so
+// that a write takes effect on Execute only; a portal that has run is
+// described by its result.
+func (c *Connection) handleDescribe(m *pgwire.DescribeMessage) error {
+	if m.Kind == 'S' {
+		stmt, ok := c.statements[m.Name]
+		if !ok {
+			return c.sendQueryError("", "26000", fmt.Sprintf("prepared statement %q does not exist", m.Name))
+		}
+		if err := c.writer.WriteParameterDescription(stmt.paramOIDs); err != nil {
+			return err
+		}
+		cols, err := c.describeColumns(stmt)
+		if err != nil {
+			return c.sendQueryError(stmt.query, queryErrorCode(err), err.Error())
+		}
+		if cols == nil {
+			return c.writer.WriteNoData()
+		}
+		return c.writeRowDescription(cols)
+	}
+
+	p, ok := c.portals[m.Name]
+	if !ok {
+		return c.sendQueryError("", "34000", fmt.Sprintf("portal %q does not exist", m.Name))
+	}
+	var cols []executor.Column
+	if p.result != nil {
+		cols = p.result.Columns
+	} else {
+		var err error
+		if cols, err = c.describeColumns(p.stmt); err != nil {
+			return c.sendQueryError(p.stmt.query, queryErrorCode(err), err.Error())
+		}
+	}
+	if cols == nil {
+		return c.writer.WriteNoData()
+	}
+	formats, err := p.columnFormats(len(cols))
+	if err != nil {
+		return c.sendQueryError(p.stmt.query, queryErrorCode(err), err.Error())
+	}
+	return c.writeRowDescriptionFormats(cols, formats)
+}
+
+// describeColumns returns the columns stmt returns, or nil if it returns
+// no rows, without running it.
+func (c *Connection) describeColumns(stmt *preparedStatement) ([]executor.Column, error) {
+	if stmt.command {
+		return nil, nil
+	}
+	if stmt.show != "" {
+		result, err := c.showResult(stmt.show)
+		if err != nil {
+			return nil, err
+		}
+		return result.Columns, nil
+	}
+	return c.exec.DescribeColumns(stmt.query, stmt.paramOIDs)
+}
+
+// handleExecute runs a portal, or continues one suspended at its row
+// limit, and sends its rows without a RowDescription. Statements the
+// connection handles itself take the simple-query path.
+func (c *Connection) handleExecute(m *pgwire.ExecuteMessage) error {
+	p, ok := c.portals[m.Portal]
+	if !ok {
+		return c.sendQueryError("", "34000", fmt.Sprintf("portal %q does not exist", m.Portal))
+	}
+	if p.stmt.command {
+		return c.handleQuery(p.stmt.query)
+	}
+	if p.result == nil {
+		if err := c.runPortal(p); err != nil {
+			return c.sendQueryError(p.stmt.query, queryErrorCode(err), err.Error())
+		}
+	}
+	// A portal's notices go out with its first batch of rows.
+	if err := c.sendNotices(p.result, p.stmt.query); err != nil {
+		return err
+	}
+	p.result.Notices = nil
+	if p.result.CopyOut != nil {
+		if err := c.sendCopyOut(p.result.CopyOut); err != nil {
+			return err
+		}
+		p.result.CopyOut = nil
+	}
+
+	formats, err := p.columnFormats(len(p.result.Columns))
+	if err != nil {
+		return c.sendQueryError(p.stmt.query, queryErrorCode(err), err.Error())
+	}
+	rows := p.result.Rows[p.sent:]
+	if m.MaxRows > 0 && int(m.MaxRows) < len(rows) {
+		rows = rows[:m.MaxRows]
+	}
+	if slices.Contains(formats, pgwire.FormatBinary) {
+		if rows, err = encodeBinaryRows(rows, p.result.Columns, formats); err != nil {
+			return c.sendQueryError(p.stmt.query, queryErrorCode(err), err.Error())
+		}
+	}
+	for _, row := range rows {
+		if err := c.writer.WriteDataRow(row); err != nil {
+			return err
+		}
+	}
+	p.sent += len(rows)
+	if p.sent < len(p.result.Rows) {
+		return c.writer.WritePortalSuspended()
+	}
+	if err := c.writer.WriteCommandComplete(p.result.Tag); err != nil {
+		return err
+	}
+	if c.cfg.LogLevel >= 1 {
+		log.Printf("[SQL] OK     %s — %s", p.stmt.query, p.result.Tag)
+	}
+	return nil
+}
+
+// encodeBinaryRows returns rows with the values of the columns whose
+// format is binary converted from text.
+func encodeBinaryRows(rows [][][]byte, columns []executor.Column, formats []int16) ([][][]byte, error) {
+	out := make([][][]byte, len(rows))
+	for i, row := range rows {
+		enc := make([][]byte, len(row))
+		for j, v := range row {
+			if formats[j] != pgwire.FormatBinary {
+				enc[j] = v
+				continue
+			}
+			b, err := executor.EncodeBinary(v, columns[j].TypeOID)
+			if err != nil {
+				return nil, err
+			}
+			enc[j] = b
+		}
+		out[i] = enc
+	}
+	return out, nil
+}
+
+// runPortal executes a portal's statement with its parameters.
+func (c *Connection) runPortal(p *portal) error {
+	if c.txState == txStatusFailed {
+		return &executor.QueryError{Code: "25P02",
+			Message: "current transaction is aborted, commands ignored until end of transaction block"}
+	}
+	if p.stmt.show != "" {
+		result, err := c.showResult(p.stmt.show)
+		if err != nil {
+			return err
+		}
+		p.result = result
+		return nil
+	}
+	ctx, done := c.cancel.statement()
+	defer done()
+	exec := c.exec.WithContext(ctx)
+	var result *executor.Result
+	var err error
+	if c.tracing() {
+		var tr *executor.Trace
+		result, tr, err = exec.ExecuteParamsTraced(p.stmt.query, p.params)
+		c.lastTrace = tr
+	} else {
+		result, err = exec.ExecuteParams(p.stmt.query, p.params)
+		c.lastTrace = nil
+	}
+	if err != nil {
+		return err
+	}
+	if result.CopyIn != nil {
+		return &executor.QueryError{Code: "0A000", Message: "COPY FROM STDIN is not supported in the extended query protocol"}
+	}
+	if result.Channel != nil {
+		c.runChannelCommand(result.Channel)
+	}
+	p.result = result
+	return nil
+}
+
+// isConnectionCommand reports whether handleQuery answers query itself
+// instead of passing it to the executor and without a result: transaction
+// control, cursors and SET. Such statements take no parameters. SHOW is
+// answered by the connection too, but through a portal like a query.
+func isConnectionCommand(query string) bool {
+	if query == "" {
+		return true
+	}
+	if _, _, ok := parseBegin(query); ok {
+		return true
+	}
+	upper := strings.ToUpper(query)
+	switch upper {
+	case "COMMIT", "END", "END TRANSACTION", "ROLLBACK", "ABORT":
+		return true
+	}
+	for _, prefix := range []string{"ROLLBACK TO ", "SAVEPOINT ", "RELEASE ", "DECLARE ", "FETCH ", "CLOSE ", "SET"} {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// pgEpoch is the zero point of binary timestamps.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// decodeParam converts a bind parameter from the wire to the Go value the
+// executor expects for its type: int64, float64, bool, string, time.Time,
+// storage.TimeOfDay, []byte or storage.Numeric. nil data is NULL. On failure it also returns
+// the SQLSTATE to report.
+func decodeParam(oid int32, format int16, data []byte) (any, string, error) {
+	if data == nil {
+		return nil, "", nil
+	}
+	switch format {
+	case pgwire.FormatText:
+		v, err := decodeTextParam(oid, string(data))
+		if err != nil {
+			return nil, "22P02", err
+		}
+		return v, "", nil
+	case pgwire.FormatBinary:
+		v, err := decodeBinaryParam(oid, data)
+		if err != nil {
+			return nil, "22P03", err
+		}
+		return v, "", nil
+	}
+	return nil, "08P01", fmt.Errorf("unsupported format code %d", format)
+}
+
+func decodeTextParam(oid int32, s string) (any, error) {
+	switch oid {
+	case executor.OIDInt2, executor.OIDInt4, executor.OIDInt8:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input syntax for type integer: %q", s)
+		}
+		return n, nil
+	case executor.OIDFloat4, executor.OIDFloat8:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input syntax for type double precision: %q", s)
+		}
+		return f, nil
+	case executor.OIDBool:
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "t", "true", "y", "yes", "on", "1":
+			return true, nil
+		case "f", "false", "n", "no", "off", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid input syntax for type boolean: %q", s)
+	case executor.OIDNumeric:
+		return storage.ParseNumeric(s)
+	case executor.OIDTimestamp, executor.OIDTimestampTZ:
+		return storage.ParseTimestamp(s)
+	case executor.OIDTime:
+		return storage.ParseTime(s)
+	case executor.OIDBytea:
+		return storage.ParseBytea(s)
+	}
+	return s, nil
+}
+
+func decodeBinaryParam(oid int32, b []byte) (any, error) {
+	size := func(n int) error {
+		if len(b) != n {
+			return fmt.Errorf("invalid binary value of %d bytes for type OID %d", len(b), oid)
+		}
+		return nil
+	}
+	switch oid {
+	case executor.OIDInt2:
+		if err := size(2); err != nil {
+			return nil, err
+		}
+		return int64(int16(binary.BigEndian.Uint16(b))), nil
+	case executor.OIDInt4:
+		if err := size(4); err != nil {
+			return nil, err
+		}
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	case executor.OIDInt8:
+		if err := size(8); err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case executor.OIDFloat4:
+		if err := size(4); err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case executor.OIDFloat8:
+		if err := size(8); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case executor.OIDBool:
+		if err := size(1); err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case executor.OIDTimestamp, executor.OIDTimestampTZ:
+		if err := size(8); err != nil {
+			return nil, err
+		}
+		us := int64(binary.BigEndian.Uint64(b))
+		if us == math.MaxInt64 || us == math.MinInt64 {
+			return nil, fmt.Errorf("infinite timestamps are not supported")
+		}
+		return pgEpoch.Add(time.Duration(us) * time.Microsecond), nil
+	case executor.OIDTime:
+		if err := size(8); err != nil {
+			return nil, err
+		}
+		t := storage.TimeOfDay(binary.BigEndian.Uint64(b))
+		if t < 0 || t > storage.MaxTimeOfDay {
+			return nil, fmt.Errorf("time out of range")
+		}
+		return t, nil
+	case executor.OIDBytea:
+		return append([]byte(nil), b...), nil
+	case executor.OIDNumeric:
+		return decodeBinaryNumeric(b)
+	case executor.OIDText, executor.OIDVarchar, executor.OIDUnknown:
+		return string(b), nil
+	}
+	return nil, fmt.Errorf("binary format is not supported for type OID %d", oid)
+}
+
+// decodeBinaryNumeric decodes PostgreSQL's binary NUMERIC: a digit count,
+// the weight of the first digit, a sign word and the display scale,
+// followed by base-10000 digits.
+func decodeBinaryNumeric(b []byte) (storage.Numeric, error) {
+	if len(b) < 8 {
+		return storage.Numeric{}, fmt.Errorf("invalid binary numeric")
+	}
+	ndigits := int(binary.BigEndian.Uint16(b))
+	weight := int(int16(binary.BigEndian.Uint16(b[2:])))
+	sign := binary.BigEndian.Uint16(b[4:])
+	dscale := int(binary.BigEndian.Uint16(b[6:]))
+	if len(b) != 8+2*ndigits {
+		return storage.Numeric{}, fmt.Errorf("invalid binary numeric")
+	}
+	if sign != 0x0000 && sign != 0x4000 {
+		return storage.Numeric{}, fmt.Errorf("NaN and infinite numerics are not supported")
+	}
+
+	var digits strings.Builder
+	digits.WriteString("0")
+	for i := range ndigits {
+		d := binary.BigEndian.Uint16(b[8+2*i:])
+		if d > 9999 {
+			return storage.Numeric{}, fmt.Errorf("invalid binary numeric")
+		}
+		fmt.Fprintf(&digits, "%04d", d)
+	}
+	// The last digit read has weight weight-ndigits+1.
+	n, err := storage.ParseNumeric(fmt.Sprintf("%se%d", digits.String(), 4*(weight-ndigits+1)))
+	if err != nil {
+		return storage.Numeric{}, err
+	}
+	n = n.Round(dscale)
+	if sign == 0x4000 {
+		n = n.Neg()
+	}
+	return n, nil
+}
@@ -0,0 +1,347 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"mulldb/config"
+	"mulldb/executor"
+	"mulldb/storage"
+)
+
+// startServer runs a server on an OS-assigned port over a fresh data
+// directory and returns a connection string for it.
+func startServer(t *testing.T, opts ...func(*config.Config)) string {
+	t.Helper()
+	dir := t.TempDir()
+	eng, err := storage.Open(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{DataDir: dir, User: "admin", Password: "test"}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	srv := New(cfg, executor.New(eng))
+	go srv.ListenAndServe()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+		eng.Close()
+	})
+
+	for range 100 {
+		if addr := srv.Addr(); addr != nil {
+			port := addr.(*net.TCPAddr).Port
+			return fmt.Sprintf("host=127.0.0.1 port=%d user=admin password=test sslmode=disable", port)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("server did not start within 1s")
+	return ""
+}
+
+func TestExtendedProtocol(t *testing.T) {
+	ctx := context.Background()
+	connStr := startServer(t)
+
+	// Both modes use Parse/Bind/Describe/Execute. The default mode
+	// describes each statement first and sends binary parameters where
+	// the described type allows it; exec mode sends text parameters
+	// without describing the statement.
+	for i, mode := range []pgx.QueryExecMode{pgx.QueryExecModeCacheStatement, pgx.QueryExecModeExec} {
+		t.Run(mode.String(), func(t *testing.T) {
+			cfg, err := pgx.ParseConfig(connStr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.DefaultQueryExecMode = mode
+			conn, err := pgx.ConnectConfig(ctx, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close(ctx)
+
+			table := fmt.Sprintf("t%d", i)
+			if _, err := conn.Exec(ctx, "CREATE TABLE "+table+" (id INTEGER PRIMARY KEY, name TEXT, score FLOAT, ok BOOLEAN)"); err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i <= 3; i++ {
+				tag, err := conn.Exec(ctx, "INSERT INTO "+table+" VALUES ($1, $2, $3, $4)", i, fmt.Sprintf("n'%d", i), float64(i)/2, i%2 == 1)
+				if err != nil {
+					t.Fatalf("insert %d: %v", i, err)
+				}
+				if tag.String() != "INSERT 0 1" {
+					t.Errorf("tag = %q, want INSERT 0 1", tag)
+				}
+			}
+
+			var name string
+			var score float64
+			var ok bool
+			err = conn.QueryRow(ctx, "SELECT name, score, ok FROM "+table+" WHERE id = $1", 3).Scan(&name, &score, &ok)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != "n'3" || score != 1.5 || !ok {
+				t.Errorf("row 3 = %q, %v, %v; want n'3, 1.5, true", name, score, ok)
+			}
+
+			rows, err := conn.Query(ctx, "SELECT id FROM "+table+" WHERE score > $1 ORDER BY id LIMIT $2", 0.6, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(ids) != "[2 3]" {
+				t.Errorf("ids = %v, want [2 3]", ids)
+			}
+
+			// Each query of a set operation types its own parameters.
+			rows, err = conn.Query(ctx, "SELECT id FROM "+table+" WHERE id = $1 UNION SELECT id FROM "+table+" WHERE name = $2 ORDER BY 1", 1, "n'3")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids, err = pgx.CollectRows(rows, pgx.RowTo[int64])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(ids) != "[1 3]" {
+				t.Errorf("UNION ids = %v, want [1 3]", ids)
+			}
+
+			var id int64
+			err = conn.QueryRow(ctx, "UPDATE "+table+" SET name = $1 WHERE id = $2 RETURNING id", nil, 2).Scan(&id)
+			if err != nil || id != 2 {
+				t.Errorf("UPDATE RETURNING = %d, %v; want 2", id, err)
+			}
+
+			// An error skips the rest of the batch; the connection stays usable.
+			_, err = conn.Exec(ctx, "INSERT INTO "+table+" VALUES ($1, $2, $3, $4)", 1, "dup", 0.0, false)
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+				t.Fatalf("duplicate insert: got %v, want SQLSTATE 23505", err)
+			}
+			var n int64
+			if err := conn.QueryRow(ctx, "SELECT COUNT(*) FROM "+table+" WHERE name IS NULL").Scan(&n); err != nil || n != 1 {
+				t.Errorf("COUNT(*) = %d, %v; want 1", n, err)
+			}
+		})
+	}
+}
+
+func TestExtendedProtocol_Transaction(t *testing.T) {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, startServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "CREATE TABLE t (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO t VALUES ($1)", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*) FROM t WHERE id >= $1", 0).Scan(&n); err != nil || n != 0 {
+		t.Errorf("COUNT(*) after ROLLBACK = %d, %v; want 0", n, err)
+	}
+}
+
+func TestExtendedProtocol_DescribePortalDoesNotRun(t *testing.T) {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, startServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(ctx, "INSERT INTO t VALUES (1, 'a')"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Describe a portal of each write, then Sync without Execute.
+	for _, sql := range []string{
+		"INSERT INTO t VALUES (2, 'b') RETURNING id, name",
+		"UPDATE t SET name = 'x' RETURNING id",
+		"DELETE FROM t RETURNING id",
+	} {
+		fe := conn.PgConn().Frontend()
+		fe.Send(&pgproto3.Parse{Query: sql})
+		fe.Send(&pgproto3.Bind{})
+		fe.Send(&pgproto3.Describe{ObjectType: 'P'})
+		fe.Send(&pgproto3.Sync{})
+		if err := fe.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		var described *pgproto3.RowDescription
+	read:
+		for {
+			msg, err := conn.PgConn().ReceiveMessage(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch msg := msg.(type) {
+			case *pgproto3.RowDescription:
+				described = msg
+			case *pgproto3.DataRow, *pgproto3.CommandComplete:
+				t.Errorf("%s: Describe sent %T", sql, msg)
+			case *pgproto3.ErrorResponse:
+				t.Fatalf("%s: %s", sql, msg.Message)
+			case *pgproto3.ReadyForQuery:
+				break read
+			}
+		}
+		if described == nil || len(described.Fields) == 0 || string(described.Fields[0].Name) != "id" {
+			t.Errorf("%s: RowDescription = %+v, want the RETURNING columns", sql, described)
+		}
+	}
+
+	var n int64
+	var name string
+	if err := conn.QueryRow(ctx, "SELECT COUNT(*), MIN(name) FROM t").Scan(&n, &name); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || name != "a" {
+		t.Errorf("table = %d rows, name %q; want it unchanged: 1 row, name a", n, name)
+	}
+}
+
+func TestDecodeParam(t *testing.T) {
+	tests := []struct {
+		oid    int32
+		format int16
+		data   []byte
+		want   string
+	}{
+		{executor.OIDInt4, 0, []byte(" 42"), "42"},
+		{executor.OIDInt4, 1, []byte{0xff, 0xff, 0xff, 0xfe}, "-2"},
+		{executor.OIDBool, 0, []byte("on"), "true"},
+		{executor.OIDFloat4, 1, []byte{0x3f, 0xc0, 0, 0}, "1.5"},
+		{executor.OIDText, 1, []byte("a'b"), "a'b"},
+		{executor.OIDBytea, 0, []byte(`\x0aff`), "[10 255]"},
+		{executor.OIDNumeric, 0, []byte("12.50"), "12.50"},
+		// 12.50: two base-10000 digits 12 and 5000, weight 0, scale 2.
+		{executor.OIDNumeric, 1, []byte{0, 2, 0, 0, 0, 0, 0, 2, 0, 12, 0x13, 0x88}, "12.50"},
+		// -0.001: one digit 10 at weight -1, negative, scale 3.
+		{executor.OIDNumeric, 1, []byte{0, 1, 0xff, 0xff, 0x40, 0, 0, 3, 0, 10}, "-0.001"},
+		{executor.OIDNumeric, 1, []byte{0, 0, 0, 0, 0, 0, 0, 1}, "0.0"},
+		{executor.OIDTimestampTZ, 1, []byte{0, 0, 0, 0, 0, 0, 0, 1}, "2000-01-01 00:00:00.000001 +0000 UTC"},
+	}
+	for _, tt := range tests {
+		v, _, err := decodeParam(tt.oid, tt.format, tt.data)
+		if err != nil {
+			t.Errorf("decodeParam(%d, %d, %q): %v", tt.oid, tt.format, tt.data, err)
+			continue
+		}
+		if got := fmt.Sprint(v); got != tt.want {
+			t.Errorf("decodeParam(%d, %d, %q) = %s, want %s", tt.oid, tt.format, tt.data, got, tt.want)
+		}
+	}
+
+	if v, _, err := decodeParam(executor.OIDInt8, 1, nil); v != nil || err != nil {
+		t.Errorf("NULL = %v, %v; want nil", v, err)
+	}
+	for _, tt := range []struct {
+		oid    int32
+		format int16
+		data   string
+		code   string
+	}{
+		{executor.OIDInt8, 0, "x", "22P02"},
+		{executor.OIDInt8, 1, "abc", "22P03"},
+		{executor.OIDText, 2, "a", "08P01"},
+	} {
+		if _, code, err := decodeParam(tt.oid, tt.format, []byte(tt.data)); err == nil || code != tt.code {
+			t.Errorf("decodeParam(%d, %d, %q) = %s, %v; want %s", tt.oid, tt.format, tt.data, code, err, tt.code)
+		}
+	}
+}
+
+func TestExtendedProtocol_BinaryResults(t *testing.T) {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, startServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "CREATE TABLE t (id INTEGER PRIMARY KEY, f FLOAT, b BOOLEAN, ts TIMESTAMP, n NUMERIC(10,3), raw BYTEA, s TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(ctx, `INSERT INTO t VALUES (-7, 2.5, TRUE, '1999-12-31 23:59:58', -1234.5, '\xdead', 'hi'), (8, NULL, NULL, NULL, NULL, NULL, NULL)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every column in binary: pgx decodes each by its binary format.
+	var (
+		id  int64
+		f   float64
+		b   bool
+		ts  time.Time
+		n   pgtype.Numeric
+		raw []byte
+		s   string
+	)
+	err = conn.QueryRow(ctx, "SELECT id, f, b, ts, n, raw, s FROM t WHERE id = $1", pgx.QueryResultFormats{pgx.BinaryFormatCode}, -7).
+		Scan(&id, &f, &b, &ts, &n, &raw, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTS := time.Date(1999, 12, 31, 23, 59, 58, 0, time.UTC)
+	if id != -7 || f != 2.5 || !b || !ts.Equal(wantTS) || string(raw) != "\xde\xad" || s != "hi" {
+		t.Errorf("row = %d, %v, %v, %v, %x, %q", id, f, b, ts, raw, s)
+	}
+	if v, err := n.Value(); err != nil || v != "-1234.500" {
+		t.Errorf("numeric = %v, %v; want -1234.500", v, err)
+	}
+
+	// Formats per column: the first binary, the second text.
+	rr := conn.PgConn().ExecParams(ctx, "SELECT id, s FROM t ORDER BY id", nil, nil, nil, []int16{1, 0})
+	var rows [][]string
+	for rr.NextRow() {
+		vals := rr.Values()
+		rows = append(rows, []string{fmt.Sprintf("%x", vals[0]), fmt.Sprint(vals[1])})
+	}
+	if _, err := rr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fds := rr.FieldDescriptions(); fds[0].Format != 1 || fds[1].Format != 0 {
+		t.Errorf("formats = %d, %d; want 1, 0", fds[0].Format, fds[1].Format)
+	}
+	if fmt.Sprint(rows) != "[[fffffffffffffff9 [104 105]] [0000000000000008 []]]" {
+		t.Errorf("rows = %v", rows)
+	}
+
+	// A format count that fits neither all columns nor each is an error.
+	rr = conn.PgConn().ExecParams(ctx, "SELECT id, f, b FROM t", nil, nil, nil, []int16{1, 0})
+	_, err = rr.Close()
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "08P01" {
+		t.Errorf("mismatched formats: got %v, want SQLSTATE 08P01", err)
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"sync"
+
+	"mulldb/executor"
+	"mulldb/pgwire"
+)
+
+// notifyHub maps channel names to the sessions listening on them, for
+// LISTEN and NOTIFY. Nothing is persisted: a notification reaches the
+// sessions listening when it is sent, and is lost when they disconnect.
+type notifyHub struct {
+	mu        sync.Mutex
+	listeners map[string]map[*listener]bool
+}
+
+func newNotifyHub() *notifyHub {
+	return &notifyHub{listeners: make(map[string]map[*listener]bool)}
+}
+
+// listen adds l to the listeners of channel.
+func (h *notifyHub) listen(l *listener, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.listeners[channel] == nil {
+		h.listeners[channel] = make(map[*listener]bool)
+	}
+	h.listeners[channel][l] = true
+}
+
+// unlisten removes l from the listeners of channel, or of every channel
+// if channel is "".
+func (h *notifyHub) unlisten(l *listener, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, ls := range h.listeners {
+		if channel != "" && name != channel {
+			continue
+		}
+		delete(ls, l)
+		if len(ls) == 0 {
+			delete(h.listeners, name)
+		}
+	}
+}
+
+// notify queues a notification for every session listening on channel,
+// including the sending one. pid identifies the sender.
+func (h *notifyHub) notify(pid int32, channel, payload string) {
+	n := notification{pid: pid, channel: channel, payload: payload}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for l := range h.listeners[channel] {
+		l.push(n)
+	}
+}
+
+// notification is a NOTIFY on its way to a listening session.
+type notification struct {
+	pid     int32
+	channel string
+	payload string
+}
+
+// listener is the receiving end of a session that ran LISTEN. As in
+// PostgreSQL, notifications reach the client only while the session is
+// idle: before its ReadyForQuery outside a transaction block, or while it
+// waits for the next query. In the second case a goroutine of the
+// listener writes them, so that a sender never waits for the client.
+type listener struct {
+	w *pgwire.Writer // the session's writer
+
+	mu      sync.Mutex
+	pending []notification
+
+	writeMu sync.Mutex // held by whoever writes to w while the session is idle
+	idle    bool       // the session waits for a query; guarded by writeMu
+
+	wake chan struct{}
+	quit chan struct{}
+}
+
+// newListener returns a listener writing to w and starts its goroutine.
+func newListener(w *pgwire.Writer) *listener {
+	l := &listener{
+		w:    w,
+		wake: make(chan struct{}, 1),
+		quit: make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// run delivers notifications that arrive while the session is idle.
+func (l *listener) run() {
+	for {
+		select {
+		case <-l.wake:
+			l.writeMu.Lock()
+			if l.idle {
+				// A write error ends the session's next read as well.
+				if l.flush() == nil {
+					l.w.Flush()
+				}
+			}
+			l.writeMu.Unlock()
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+// push queues n and wakes the listener's goroutine.
+func (l *listener) push(n notification) {
+	l.mu.Lock()
+	l.pending = append(l.pending, n)
+	l.mu.Unlock()
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// flush writes the queued notifications without flushing w. The caller
+// must own the writer: be the session, while it is not idle, or hold
+// writeMu.
+func (l *listener) flush() error {
+	l.mu.Lock()
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+	for _, n := range pending {
+		if err := l.w.WriteNotificationResponse(n.pid, n.channel, n.payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setIdle records whether the session waits for a query. Becoming idle
+// sends what was queued meanwhile; once setIdle(false) returns, the
+// session owns its writer again.
+func (l *listener) setIdle(idle bool) error {
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+	l.idle = idle
+	if !idle {
+		return nil
+	}
+	if err := l.flush(); err != nil {
+		return err
+	}
+	return l.w.Flush()
+}
+
+// stop ends the listener's goroutine; nothing is written afterwards.
+func (l *listener) stop() {
+	l.setIdle(false)
+	close(l.quit)
+}
+
+// runChannelCommand carries out a LISTEN, UNLISTEN or NOTIFY. Inside a
+// transaction block it waits for COMMIT, as in PostgreSQL, and ROLLBACK
+// drops it.
+func (c *Connection) runChannelCommand(cmd *executor.ChannelCommand) {
+	if c.txState != txStatusIdle {
+		c.txChannel = append(c.txChannel, cmd)
+		return
+	}
+	switch cmd.Op {
+	case "LISTEN":
+		if c.listener == nil {
+			c.listener = newListener(c.writer)
+		}
+		c.notifies.listen(c.listener, cmd.Channel)
+	case "UNLISTEN":
+		if c.listener != nil {
+			c.notifies.unlisten(c.listener, cmd.Channel)
+		}
+	case "NOTIFY":
+		c.notifies.notify(c.pid, cmd.Channel, cmd.Payload)
+	}
+}
+
+// commitChannelCommands runs the channel commands of a transaction that
+// committed. A NOTIFY repeated with the same payload is sent once.
+func (c *Connection) commitChannelCommands(cmds []*executor.ChannelCommand) {
+	seen := make(map[executor.ChannelCommand]bool)
+	for _, cmd := range cmds {
+		if cmd.Op == "NOTIFY" {
+			if seen[*cmd] {
+				continue
+			}
+			seen[*cmd] = true
+		}
+		c.runChannelCommand(cmd)
+	}
+}
+
+// stopListening removes the session from every channel.
+func (c *Connection) stopListening() {
+	if c.listener != nil {
+		c.notifies.unlisten(c.listener, "")
+		c.listener.stop()
+	}
+}
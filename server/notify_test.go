@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestListenNotify(t *testing.T) {
+	ctx := context.Background()
+	connStr := startServer(t)
+	listen, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listen.Close(ctx)
+	send, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer send.Close(ctx)
+
+	mustExec := func(conn *pgx.Conn, sql string) {
+		t.Helper()
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	// wait returns the next notification of listen, or nil if none comes
+	// within d.
+	wait := func(d time.Duration) *pgconn.Notification {
+		t.Helper()
+		wctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		n, err := listen.WaitForNotification(wctx)
+		if err != nil {
+			if wctx.Err() == nil {
+				t.Fatal(err)
+			}
+			return nil
+		}
+		return n
+	}
+
+	mustExec(listen, "LISTEN cache")
+	mustExec(listen, "LISTEN other")
+
+	// Delivered while the listening session is idle.
+	mustExec(send, "NOTIFY cache, 'users:42'")
+	n := wait(5 * time.Second)
+	if n == nil {
+		t.Fatal("no notification")
+	}
+	if n.Channel != "cache" || n.Payload != "users:42" || n.PID != send.PgConn().PID() {
+		t.Errorf("got %+v, want users:42 on cache from %d", n, send.PgConn().PID())
+	}
+
+	// Inside a transaction block NOTIFY waits for COMMIT, which sends a
+	// repeated notification once; ROLLBACK drops it.
+	mustExec(send, "BEGIN")
+	mustExec(send, "NOTIFY cache, 'a'")
+	mustExec(send, "NOTIFY cache, 'a'")
+	mustExec(send, "NOTIFY other")
+	if n := wait(100 * time.Millisecond); n != nil {
+		t.Errorf("got %+v before COMMIT", n)
+	}
+	mustExec(send, "COMMIT")
+	var got []string
+	for range 2 {
+		if n := wait(5 * time.Second); n != nil {
+			got = append(got, n.Channel+":"+n.Payload)
+		}
+	}
+	if len(got) != 2 || got[0] != "cache:a" || got[1] != "other:" {
+		t.Errorf("after COMMIT got %v, want [cache:a other:]", got)
+	}
+	if n := wait(100 * time.Millisecond); n != nil {
+		t.Errorf("got %+v, a repeated notification", n)
+	}
+	mustExec(send, "BEGIN")
+	mustExec(send, "NOTIFY cache, 'b'")
+	mustExec(send, "ROLLBACK")
+
+	// A session gets its own notifications, before ReadyForQuery.
+	mustExec(listen, "NOTIFY other, 'self'")
+	if n := wait(time.Second); n == nil || n.Payload != "self" || n.PID != listen.PgConn().PID() {
+		t.Errorf("got %+v, want its own notification", n)
+	}
+
+	mustExec(listen, "UNLISTEN cache")
+	mustExec(send, "NOTIFY cache, 'c'")
+	if n := wait(100 * time.Millisecond); n != nil {
+		t.Errorf("got %+v after UNLISTEN", n)
+	}
+	mustExec(listen, "UNLISTEN *")
+	mustExec(send, "NOTIFY other, 'd'")
+	if n := wait(100 * time.Millisecond); n != nil {
+		t.Errorf("got %+v after UNLISTEN *", n)
+	}
+
+	// The extended protocol reaches the same statements.
+	if _, err := listen.Exec(ctx, "LISTEN cache", pgx.QueryExecModeDescribeExec); err != nil {
+		t.Fatal(err)
+	}
+	mustExec(send, "NOTIFY cache, 'e'")
+	if n := wait(5 * time.Second); n == nil || n.Payload != "e" {
+		t.Errorf("got %+v, want e", n)
+	}
+}
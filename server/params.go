@@ -0,0 +1,218 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"mulldb/parser"
+)
+
+// sessionParams holds the run-time parameters set with SET for one
+// connection. Values live in three layers, looked up innermost first:
+//
+//   - local: SET LOCAL values, discarded when the transaction ends
+//   - pending: plain SET values issued inside a transaction, kept on
+//     COMMIT and discarded on ROLLBACK
+//   - session: committed values that last for the whole connection
+//
+// Outside a transaction only the session layer is used.
+type sessionParams struct {
+	session  map[string]string
+	pending  map[string]string // nil when no transaction is open
+	local    map[string]string // nil when no transaction is open
+	reported map[string]string // values of reportedParams the client was last sent
+}
+
+func newSessionParams() *sessionParams {
+	return &sessionParams{session: make(map[string]string), reported: make(map[string]string)}
+}
+
+// Get returns the effective value of name.
+func (p *sessionParams) Get(name string) (string, bool) {
+	name = strings.ToLower(name)
+	if v, ok := p.local[name]; ok {
+		return v, true
+	}
+	if v, ok := p.pending[name]; ok {
+		return v, true
+	}
+	v, ok := p.session[name]
+	return v, ok
+}
+
+// Set assigns name. A plain SET inside a transaction overrides any earlier
+// SET LOCAL of the same name, as in PostgreSQL. It reports false if local
+// is requested outside a transaction, in which case nothing changes.
+func (p *sessionParams) Set(name, value string, local bool) bool {
+	name = strings.ToLower(name)
+	if !p.inTx() {
+		if local {
+			return false
+		}
+		p.session[name] = value
+		return true
+	}
+	if local {
+		p.local[name] = value
+		return true
+	}
+	delete(p.local, name)
+	p.pending[name] = value
+	return true
+}
+
+// Begin opens the transaction layers.
+func (p *sessionParams) Begin() {
+	p.pending = make(map[string]string)
+	p.local = make(map[string]string)
+}
+
+// Commit keeps plain SETs made in the transaction and drops SET LOCALs.
+func (p *sessionParams) Commit() {
+	for k, v := range p.pending {
+		p.session[k] = v
+	}
+	p.pending = nil
+	p.local = nil
+}
+
+// Rollback drops every change made in the transaction.
+func (p *sessionParams) Rollback() {
+	p.pending = nil
+	p.local = nil
+}
+
+func (p *sessionParams) inTx() bool {
+	return p.pending != nil
+}
+
+// changedReported returns the reportedParams whose effective value differs
+// from the one the client was last sent, or was never sent, with their new
+// values, and records those as sent. A value SET to DEFAULT is reported
+// as the default.
+func (p *sessionParams) changedReported() [][2]string {
+	var changed [][2]string
+	for _, r := range reportedParams {
+		v, set := p.Get(r[0])
+		if !set || v == "default" {
+			v = r[1]
+		}
+		if old, ok := p.reported[r[0]]; ok && old == v {
+			continue
+		}
+		p.reported[r[0]] = v
+		changed = append(changed, [2]string{r[0], v})
+	}
+	return changed
+}
+
+// reportedParams are sent as ParameterStatus messages after
+// authentication, and again whenever SET, or the end of a transaction,
+// changes one. SHOW reports the same values while they are not SET.
+var reportedParams = [][2]string{
+	{"server_version", "mulldb-0.1"},
+	{"server_encoding", "UTF8"},
+	{"client_encoding", "UTF8"},
+	{"application_name", ""},
+	{"DateStyle", "ISO, MDY"},
+	{"TimeZone", "UTC"},
+	{"integer_datetimes", "on"},
+	{"is_superuser", "on"},
+	{"standard_conforming_strings", "on"},
+}
+
+// readOnlyParams are reported to the client but describe the server, so
+// SET cannot change them (SQLSTATE 55P02), as in PostgreSQL.
+var readOnlyParams = map[string]bool{
+	"server_version":    true,
+	"server_encoding":   true,
+	"integer_datetimes": true,
+	"is_superuser":      true,
+}
+
+// showDefaults are the values SHOW reports for other parameters that
+// drivers and tools commonly ask for.
+var showDefaults = map[string]string{
+	"default_transaction_isolation": "read committed",
+	"max_identifier_length":         "63",
+	"search_path":                   `"$user", public`,
+	"transaction_isolation":         "read committed",
+}
+
+// paramDefault returns the value SHOW reports for name while it is not SET.
+func paramDefault(name string) (string, bool) {
+	for _, p := range reportedParams {
+		if strings.EqualFold(p[0], name) {
+			return p[1], true
+		}
+	}
+	v, ok := showDefaults[name]
+	return v, ok
+}
+
+// parseSet extracts the parameter from "SET [SESSION | LOCAL] name {= | TO}
+// value". Quotes around the value are removed and on/off style keywords are
+// lowercased. ok is false for forms that do not assign a single named
+// parameter (SET TIME ZONE, SET TRANSACTION, ...).
+func parseSet(query string) (name, value string, local, ok bool) {
+	stmt, err := parser.Parse(query)
+	if err != nil {
+		return "", "", false, false
+	}
+	set, ok := stmt.(*parser.SetStmt)
+	if !ok {
+		return "", "", false, false
+	}
+	return set.Name, set.Value, set.Local, true
+}
+
+// parseShow extracts the parameter name from "SHOW name". ok is false for
+// SHOW MEMORY, which the executor answers, and for anything that is not a
+// SHOW statement.
+func parseShow(query string) (name string, ok bool) {
+	stmt, err := parser.Parse(query)
+	if err != nil {
+		return "", false
+	}
+	show, ok := stmt.(*parser.ShowStmt)
+	if !ok {
+		return "", false
+	}
+	return show.Name, true
+}
+
+// parseTimeout parses a statement_timeout value: a number of milliseconds
+// or a duration such as 500ms, 5s or 1min. Zero disables the timeout.
+func parseTimeout(s string) (time.Duration, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if ms < 0 {
+			return 0, fmt.Errorf("must not be negative")
+		}
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	if n, ok := strings.CutSuffix(s, "min"); ok {
+		s = n + "m"
+	}
+	d, err := time.ParseDuration(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return d, nil
+}
+
+// formatTimeout renders a statement_timeout the way SHOW reports it.
+func formatTimeout(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "0"
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	default:
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestSessionParams_SetLocalRevertsOnCommit(t *testing.T) {
+	p := newSessionParams()
+
+	p.Begin()
+	p.Set("trace", "on", true)
+	p.Set("application_name", "psql", false)
+	if v, _ := p.Get("trace"); v != "on" {
+		t.Fatalf("inside tx: trace = %q, want on", v)
+	}
+	p.Commit()
+
+	if v, ok := p.Get("trace"); ok {
+		t.Errorf("after COMMIT: SET LOCAL trace still visible (%q)", v)
+	}
+	if v, _ := p.Get("application_name"); v != "psql" {
+		t.Errorf("after COMMIT: application_name = %q, want psql", v)
+	}
+}
+
+func TestSessionParams_RollbackDiscardsAll(t *testing.T) {
+	p := newSessionParams()
+	p.Set("trace", "off", false)
+
+	p.Begin()
+	p.Set("trace", "on", false)
+	p.Set("search_path", "public", true)
+	p.Rollback()
+
+	if v, _ := p.Get("trace"); v != "off" {
+		t.Errorf("after ROLLBACK: trace = %q, want off", v)
+	}
+	if _, ok := p.Get("search_path"); ok {
+		t.Error("after ROLLBACK: search_path still set")
+	}
+}
+
+func TestSessionParams_LocalShadowsSession(t *testing.T) {
+	p := newSessionParams()
+	p.Set("trace", "off", false)
+
+	p.Begin()
+	p.Set("trace", "on", true)
+	if v, _ := p.Get("trace"); v != "on" {
+		t.Errorf("SET LOCAL: trace = %q, want on", v)
+	}
+	// A plain SET after SET LOCAL wins and survives COMMIT.
+	p.Set("TRACE", "on", false)
+	p.Set("trace", "off", true)
+	p.Set("trace", "on", false)
+	p.Commit()
+	if v, _ := p.Get("trace"); v != "on" {
+		t.Errorf("after COMMIT: trace = %q, want on", v)
+	}
+}
+
+func TestSessionParams_LocalOutsideTx(t *testing.T) {
+	p := newSessionParams()
+	if p.Set("trace", "on", true) {
+		t.Error("SET LOCAL outside a transaction should be rejected")
+	}
+	if _, ok := p.Get("trace"); ok {
+		t.Error("SET LOCAL outside a transaction should have no effect")
+	}
+}
+
+func TestSessionParams_ChangedReported(t *testing.T) {
+	p := newSessionParams()
+	if got := p.changedReported(); len(got) != len(reportedParams) {
+		t.Fatalf("first report = %q, want all %d parameters", got, len(reportedParams))
+	}
+	if got := p.changedReported(); got != nil {
+		t.Errorf("unchanged parameters reported again: %q", got)
+	}
+
+	p.Set("TimeZone", "Europe/Berlin", false)
+	p.Set("search_path", "public", false) // not a reported parameter
+	if got := p.changedReported(); len(got) != 1 || got[0] != [2]string{"TimeZone", "Europe/Berlin"} {
+		t.Errorf("after SET TimeZone: reported %q", got)
+	}
+	p.Begin()
+	p.Set("timezone", "default", true)
+	if got := p.changedReported(); len(got) != 1 || got[0] != [2]string{"TimeZone", "UTC"} {
+		t.Errorf("after SET LOCAL TimeZone TO DEFAULT: reported %q", got)
+	}
+	p.Rollback()
+	if got := p.changedReported(); len(got) != 1 || got[0] != [2]string{"TimeZone", "Europe/Berlin"} {
+		t.Errorf("after ROLLBACK: reported %q", got)
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	tests := []struct {
+		query string
+		name  string
+		value string
+		local bool
+		ok    bool
+	}{
+		{"SET trace = on", "trace", "on", false, true},
+		{"set TRACE=OFF", "trace", "off", false, true},
+		{"SET LOCAL trace TO on", "trace", "on", true, true},
+		{"SET SESSION client_encoding = 'UTF8'", "client_encoding", "UTF8", false, true},
+		{"SET application_name = 'it''s'", "application_name", "it's", false, true},
+		{"SET TIME ZONE 'UTC'", "", "", false, false},
+		{"SET TRANSACTION ISOLATION LEVEL READ COMMITTED", "", "", false, false},
+	}
+	for _, tt := range tests {
+		name, value, local, ok := parseSet(tt.query)
+		if ok != tt.ok || name != tt.name || value != tt.value || local != tt.local {
+			t.Errorf("parseSet(%q) = (%q, %q, %v, %v), want (%q, %q, %v, %v)",
+				tt.query, name, value, local, ok, tt.name, tt.value, tt.local, tt.ok)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"0", 0},
+		{"250", 250 * time.Millisecond},
+		{"500ms", 500 * time.Millisecond},
+		{"5s", 5 * time.Second},
+		{"2min", 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeout(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseTimeout(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+		if tt.want != 0 && formatTimeout(got) == "" {
+			t.Errorf("formatTimeout(%v) is empty", got)
+		}
+	}
+	for _, in := range []string{"-1", "soon", "5 parsecs"} {
+		if _, err := parseTimeout(in); err == nil {
+			t.Errorf("parseTimeout(%q): expected error", in)
+		}
+	}
+	if got := formatTimeout(1500 * time.Millisecond); got != "1500ms" {
+		t.Errorf("formatTimeout(1.5s) = %q, want 1500ms", got)
+	}
+}
+
+func TestSetShow(t *testing.T) {
+	ctx := context.Background()
+	connStr := startServer(t)
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+
+	show := func(name string) string {
+		t.Helper()
+		var v string
+		if err := conn.QueryRow(ctx, "SHOW "+name).Scan(&v); err != nil {
+			t.Fatalf("SHOW %s: %v", name, err)
+		}
+		var simple string
+		if err := conn.QueryRow(ctx, "SHOW "+name, pgx.QueryExecModeSimpleProtocol).Scan(&simple); err != nil {
+			t.Fatalf("SHOW %s (simple protocol): %v", name, err)
+		}
+		if simple != v {
+			t.Errorf("SHOW %s = %q, but %q over the simple protocol", name, v, simple)
+		}
+		return v
+	}
+	mustExec := func(sql string) {
+		t.Helper()
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	if v := show("server_version"); v != "mulldb-0.1" {
+		t.Errorf("server_version = %q", v)
+	}
+	if v := show("DateStyle"); v != "ISO, MDY" {
+		t.Errorf("DateStyle = %q", v)
+	}
+
+	mustExec("SET application_name = 'reports'")
+	mustExec("SET search_path TO public, pg_catalog")
+	mustExec("SET myapp.tenant = 42")
+	if v := show("application_name"); v != "reports" {
+		t.Errorf("application_name = %q, want reports", v)
+	}
+	if v := show("search_path"); v != "public, pg_catalog" {
+		t.Errorf("search_path = %q", v)
+	}
+	if v := show("myapp.tenant"); v != "42" {
+		t.Errorf("myapp.tenant = %q, want 42", v)
+	}
+	mustExec("SET application_name TO DEFAULT")
+	if v := show("application_name"); v != "" {
+		t.Errorf("application_name after DEFAULT = %q, want empty", v)
+	}
+
+	// Unknown parameters and SET forms without a single parameter are
+	// accepted, but SHOW of a parameter never set is an error.
+	mustExec("SET extra_float_digits = 3")
+	mustExec("SET TIME ZONE 'UTC'")
+	_, err = conn.Exec(ctx, "SHOW no_such_parameter")
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "42704" {
+		t.Errorf("SHOW no_such_parameter: got %v, want SQLSTATE 42704", err)
+	}
+
+	// statement_timeout applies to the session and honours SET LOCAL.
+	if v := show("statement_timeout"); v != "0" {
+		t.Errorf("statement_timeout = %q, want 0", v)
+	}
+	_, err = conn.Exec(ctx, "SET statement_timeout = 'never'")
+	if !errors.As(err, &pgErr) || pgErr.Code != "22023" {
+		t.Errorf("invalid statement_timeout: got %v, want SQLSTATE 22023", err)
+	}
+	mustExec("SET statement_timeout = 50")
+	if v := show("statement_timeout"); v != "50ms" {
+		t.Errorf("statement_timeout = %q, want 50ms", v)
+	}
+	vals := make([]string, 300)
+	for i := range vals {
+		vals[i] = fmt.Sprintf("(%d)", i)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		mustExec("CREATE TABLE " + name + " (n INTEGER)")
+		mustExec("INSERT INTO " + name + " VALUES " + strings.Join(vals, ", "))
+	}
+	_, err = conn.Exec(ctx, "SELECT a.n FROM a JOIN b ON a.n = b.n JOIN c ON b.n + c.n < 0")
+	if !errors.As(err, &pgErr) || pgErr.Code != "57014" {
+		t.Fatalf("got %v, want SQLSTATE 57014", err)
+	}
+	mustExec("BEGIN")
+	mustExec("SET LOCAL statement_timeout = '10s'")
+	if v := show("statement_timeout"); v != "10s" {
+		t.Errorf("statement_timeout in transaction = %q, want 10s", v)
+	}
+	mustExec("COMMIT")
+	if v := show("statement_timeout"); v != "50ms" {
+		t.Errorf("statement_timeout after COMMIT = %q, want 50ms", v)
+	}
+}
+
+func TestParameterStatus(t *testing.T) {
+	ctx := context.Background()
+	cfg, err := pgx.ParseConfig(startServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.RuntimeParams["application_name"] = "loader"
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	pg := conn.PgConn()
+
+	// The startup packet's application_name is the session's value.
+	if v := pg.ParameterStatus("application_name"); v != "loader" {
+		t.Errorf("application_name at startup = %q, want loader", v)
+	}
+	if v := pg.ParameterStatus("TimeZone"); v != "UTC" {
+		t.Errorf("TimeZone at startup = %q, want UTC", v)
+	}
+
+	mustExec := func(sql string) {
+		t.Helper()
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	mustExec("SET application_name = 'reports'")
+	if v := pg.ParameterStatus("application_name"); v != "reports" {
+		t.Errorf("application_name after SET = %q, want reports", v)
+	}
+
+	// A SET undone by ROLLBACK is reported again.
+	mustExec("BEGIN")
+	mustExec("SET timezone = 'Europe/Berlin'")
+	if v := pg.ParameterStatus("TimeZone"); v != "Europe/Berlin" {
+		t.Errorf("TimeZone after SET = %q, want Europe/Berlin", v)
+	}
+	mustExec("ROLLBACK")
+	if v := pg.ParameterStatus("TimeZone"); v != "UTC" {
+		t.Errorf("TimeZone after ROLLBACK = %q, want UTC", v)
+	}
+	// Parameters that describe the server cannot be SET, and the client
+	// keeps the values it was sent.
+	for _, sql := range []string{
+		"SET server_version = '1.0'",
+		"SET server_encoding TO 'LATIN1'",
+		"SET integer_datetimes = off",
+		"SET is_superuser = off",
+		"SET LOCAL is_superuser = off",
+	} {
+		_, err := conn.Exec(ctx, sql)
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != "55P02" {
+			t.Errorf("%s: got %v, want SQLSTATE 55P02", sql, err)
+		}
+	}
+	if v := pg.ParameterStatus("server_version"); v != "mulldb-0.1" {
+		t.Errorf("server_version = %q, want mulldb-0.1", v)
+	}
+	if v := pg.ParameterStatus("is_superuser"); v != "on" {
+		t.Errorf("is_superuser = %q, want on", v)
+	}
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -15,6 +17,9 @@ import (
 type Server struct {
 	cfg      *config.Config
 	exec     *executor.Executor
+	tls      *tls.Config // nil when TLS is not configured
+	cancels  *cancelRegistry
+	notifies *notifyHub
 	mu       sync.Mutex // protects listener
 	listener net.Listener
 	wg       sync.WaitGroup
@@ -24,15 +29,23 @@ type Server struct {
 // New creates a server with the given configuration and executor.
 func New(cfg *config.Config, exec *executor.Executor) *Server {
 	return &Server{
-		cfg:  cfg,
-		exec: exec,
-		quit: make(chan struct{}),
+		cfg:      cfg,
+		exec:     exec,
+		cancels:  newCancelRegistry(),
+		notifies: newNotifyHub(),
+		quit:     make(chan struct{}),
 	}
 }
 
 // ListenAndServe starts accepting connections. It blocks until Shutdown
 // is called or an unrecoverable error occurs.
 func (s *Server) ListenAndServe() error {
+	tlsCfg, err := loadTLSConfig(s.cfg)
+	if err != nil {
+		return err
+	}
+	s.tls = tlsCfg
+
 	addr := fmt.Sprintf(":%d", s.cfg.Port)
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -58,12 +71,31 @@ func (s *Server) ListenAndServe() error {
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
-			c := newConnection(conn, s.cfg, s.exec)
+			c := newConnection(conn, s.cfg, s.exec, s.tls, s.cancels, s.notifies)
 			c.Handle()
 		}()
 	}
 }
 
+// loadTLSConfig builds the TLS configuration from the certificate and key
+// files in cfg. It returns nil when neither is set.
+func loadTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.TLSCert == "" && cfg.TLSKey == "" {
+		return nil, nil
+	}
+	if cfg.TLSCert == "" || cfg.TLSKey == "" {
+		return nil, errors.New("tls: both a certificate and a key file are required")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
 // Addr returns the listener's network address, or nil if not yet listening.
 func (s *Server) Addr() net.Addr {
 	s.mu.Lock()
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"mulldb/config"
+)
+
+// writeTestCert writes a self-signed certificate and its key to dir.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLS(t *testing.T) {
+	ctx := context.Background()
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+	connStr := startServer(t, func(cfg *config.Config) {
+		cfg.TLSCert = certFile
+		cfg.TLSKey = keyFile
+	})
+	base := strings.TrimSuffix(connStr, "sslmode=disable")
+
+	for _, mode := range []string{"require", "prefer"} {
+		t.Run(mode, func(t *testing.T) {
+			conn, err := pgx.Connect(ctx, base+"sslmode="+mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close(ctx)
+			if _, ok := conn.PgConn().Conn().(*tls.Conn); !ok {
+				t.Fatalf("connection is not encrypted: %T", conn.PgConn().Conn())
+			}
+			var n int64
+			if err := conn.QueryRow(ctx, "SELECT 1 + 1").Scan(&n); err != nil {
+				t.Fatal(err)
+			}
+			if n != 2 {
+				t.Fatalf("got %d, want 2", n)
+			}
+		})
+	}
+
+	t.Run("disable", func(t *testing.T) {
+		conn, err := pgx.Connect(ctx, connStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close(ctx)
+		if _, ok := conn.PgConn().Conn().(*tls.Conn); ok {
+			t.Fatal("expected a plaintext connection")
+		}
+	})
+}
+
+func TestTLS_NotConfigured(t *testing.T) {
+	ctx := context.Background()
+	base := strings.TrimSuffix(startServer(t), "sslmode=disable")
+
+	// prefer falls back to plaintext when the server refuses SSL.
+	conn, err := pgx.Connect(ctx, base+"sslmode=prefer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	if _, ok := conn.PgConn().Conn().(*tls.Conn); ok {
+		t.Fatal("expected a plaintext connection")
+	}
+
+	if conn, err := pgx.Connect(ctx, base+"sslmode=require"); err == nil {
+		conn.Close(ctx)
+		t.Fatal("sslmode=require: expected error")
+	}
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+
+	if cfg, err := loadTLSConfig(&config.Config{}); err != nil || cfg != nil {
+		t.Fatalf("no files: got %v, %v; want nil, nil", cfg, err)
+	}
+	if _, err := loadTLSConfig(&config.Config{TLSCert: certFile}); err == nil {
+		t.Fatal("certificate without key: expected error")
+	}
+	if _, err := loadTLSConfig(&config.Config{TLSCert: certFile, TLSKey: certFile}); err == nil {
+		t.Fatal("mismatched key: expected error")
+	}
+	cfg, err := loadTLSConfig(&config.Config{TLSCert: certFile, TLSKey: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Certificates) != 1 {
+		t.Fatalf("got %d certificates, want 1", len(cfg.Certificates))
+	}
+}
+
+func TestTLS_SSLRequestBytes(t *testing.T) {
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+	sslRequest := []byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f} // length 8, code 80877103
+
+	dial := func(t *testing.T, connStr string) net.Conn {
+		t.Helper()
+		var port string
+		for _, f := range strings.Fields(connStr) {
+			if p, ok := strings.CutPrefix(f, "port="); ok {
+				port = p
+			}
+		}
+		conn, err := net.DialTimeout("tcp", "127.0.0.1:"+port, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	answer := func(t *testing.T, conn net.Conn) byte {
+		t.Helper()
+		buf := make([]byte, 1)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf[0]
+	}
+
+	// Without a certificate the server answers 'N' and the client goes on
+	// in plaintext on the same connection.
+	conn := dial(t, startServer(t))
+	conn.Write(sslRequest)
+	if b := answer(t, conn); b != 'N' {
+		t.Fatalf("unconfigured: answer %q, want 'N'", b)
+	}
+
+	// With one it answers 'S' and the TLS handshake follows.
+	connStr := startServer(t, func(cfg *config.Config) {
+		cfg.TLSCert = certFile
+		cfg.TLSKey = keyFile
+	})
+	conn = dial(t, connStr)
+	conn.Write(sslRequest)
+	if b := answer(t, conn); b != 'S' {
+		t.Fatalf("configured: answer %q, want 'S'", b)
+	}
+	tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+	if err := tlsConn.Handshake(); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+
+	// Plaintext bytes sent along with the request are refused: the server
+	// closes the connection rather than read them as if encrypted.
+	conn = dial(t, connStr)
+	conn.Write(append(sslRequest, 'Q', 0, 0, 0, 4))
+	if rest, err := io.ReadAll(conn); err != nil || len(rest) != 0 {
+		t.Fatalf("after injected bytes: read %q, %v; want the connection closed", rest, err)
+	}
+}
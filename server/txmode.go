@@ -0,0 +1,111 @@
+package server
+
+import (
+	"log"
+	"strings"
+)
+
+// handleSetTransaction applies SET TRANSACTION modes to the current
+// transaction. Only the access mode has an effect; isolation levels and
+// DEFERRABLE are accepted and ignored.
+func (c *Connection) handleSetTransaction(query string, readOnly, explicit bool) error {
+	if c.txState != txStatusActive {
+		// PostgreSQL only warns about SET TRANSACTION outside a transaction.
+		if werr := c.writer.WriteNoticeResponse("WARNING", "25P01",
+			"SET TRANSACTION can only be used in transaction blocks"); werr != nil {
+			return werr
+		}
+	} else if explicit {
+		c.txEngine.SetReadOnly(readOnly)
+	}
+	if err := c.writer.WriteCommandComplete("SET"); err != nil {
+		return err
+	}
+	if c.cfg.LogLevel >= 1 {
+		log.Printf("[SQL] OK     %s — SET", query)
+	}
+	return nil
+}
+
+// parseBegin recognises "BEGIN [WORK | TRANSACTION] [modes]" and
+// "START TRANSACTION [modes]". explicit reports whether the modes set an
+// access mode; without one a transaction is read-write.
+func parseBegin(query string) (readOnly, explicit, ok bool) {
+	fields := txModeFields(query)
+	switch {
+	case len(fields) >= 1 && strings.EqualFold(fields[0], "BEGIN"):
+		fields = fields[1:]
+		if len(fields) > 0 && (strings.EqualFold(fields[0], "WORK") || strings.EqualFold(fields[0], "TRANSACTION")) {
+			fields = fields[1:]
+		}
+	case hasWords(fields, "START", "TRANSACTION"):
+		fields = fields[2:]
+	default:
+		return false, false, false
+	}
+	return parseTxModes(fields)
+}
+
+// parseSetTransaction recognises "SET TRANSACTION modes".
+func parseSetTransaction(query string) (readOnly, explicit, ok bool) {
+	fields := txModeFields(query)
+	if !hasWords(fields, "SET", "TRANSACTION") || len(fields) == 2 {
+		return false, false, false
+	}
+	return parseTxModes(fields[2:])
+}
+
+// parseTxModes parses a transaction mode list, whose entries may be
+// separated by commas:
+//
+//	READ ONLY | READ WRITE
+//	ISOLATION LEVEL { SERIALIZABLE | REPEATABLE READ | READ COMMITTED | READ UNCOMMITTED }
+//	[NOT] DEFERRABLE
+//
+// The last access mode given wins.
+func parseTxModes(fields []string) (readOnly, explicit, ok bool) {
+	for len(fields) > 0 {
+		n := 0
+		switch {
+		case fields[0] == ",":
+			n = 1
+		case hasWords(fields, "READ", "ONLY"):
+			readOnly, explicit, n = true, true, 2
+		case hasWords(fields, "READ", "WRITE"):
+			readOnly, explicit, n = false, true, 2
+		case hasWords(fields, "ISOLATION", "LEVEL", "SERIALIZABLE"):
+			n = 3
+		case hasWords(fields, "ISOLATION", "LEVEL", "REPEATABLE", "READ"),
+			hasWords(fields, "ISOLATION", "LEVEL", "READ", "COMMITTED"),
+			hasWords(fields, "ISOLATION", "LEVEL", "READ", "UNCOMMITTED"):
+			n = 4
+		case hasWords(fields, "NOT", "DEFERRABLE"):
+			n = 2
+		case hasWords(fields, "DEFERRABLE"):
+			n = 1
+		default:
+			return false, false, false
+		}
+		fields = fields[n:]
+	}
+	return readOnly, explicit, true
+}
+
+// txModeFields splits a transaction statement into words, with commas as
+// words of their own.
+func txModeFields(query string) []string {
+	return strings.Fields(strings.ReplaceAll(query, ",", " , "))
+}
+
+// hasWords reports whether fields starts with words, ignoring case.
+func hasWords(fields []string, words ...string) bool {
+	if len(fields) < len(words) {
+		return false
+	}
+	for i, w := range words {
+		if !strings.EqualFold(fields[i], w) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package server
+
+import "testing"
+
+func TestParseBegin(t *testing.T) {
+	tests := []struct {
+		query    string
+		readOnly bool
+		explicit bool
+		ok       bool
+	}{
+		{"BEGIN", false, false, true},
+		{"begin work", false, false, true},
+		{"START TRANSACTION", false, false, true},
+		{"BEGIN READ ONLY", true, true, true},
+		{"BEGIN TRANSACTION READ WRITE", false, true, true},
+		{"start transaction isolation level repeatable read, read only", true, true, true},
+		{"BEGIN READ ONLY, READ WRITE", false, true, true},
+		{"BEGIN ISOLATION LEVEL SERIALIZABLE NOT DEFERRABLE", false, false, true},
+		{"BEGIN READ", false, false, false},
+		{"BEGIN ISOLATION LEVEL CHAOS", false, false, false},
+		{"BEGINNING", false, false, false},
+		{"START", false, false, false},
+	}
+	for _, tt := range tests {
+		readOnly, explicit, ok := parseBegin(tt.query)
+		if readOnly != tt.readOnly || explicit != tt.explicit || ok != tt.ok {
+			t.Errorf("parseBegin(%q) = %v, %v, %v; want %v, %v, %v",
+				tt.query, readOnly, explicit, ok, tt.readOnly, tt.explicit, tt.ok)
+		}
+	}
+}
+
+func TestParseSetTransaction(t *testing.T) {
+	tests := []struct {
+		query    string
+		readOnly bool
+		explicit bool
+		ok       bool
+	}{
+		{"SET TRANSACTION READ ONLY", true, true, true},
+		{"set transaction read write", false, true, true},
+		{"SET TRANSACTION ISOLATION LEVEL READ COMMITTED", false, false, true},
+		{"SET TRANSACTION", false, false, false},
+		{"SET trace = on", false, false, false},
+	}
+	for _, tt := range tests {
+		readOnly, explicit, ok := parseSetTransaction(tt.query)
+		if readOnly != tt.readOnly || explicit != tt.explicit || ok != tt.ok {
+			t.Errorf("parseSetTransaction(%q) = %v, %v, %v; want %v, %v, %v",
+				tt.query, readOnly, explicit, ok, tt.readOnly, tt.explicit, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// A backup file holds the files of a data directory, as Snapshot writes
+// them, one after the other:
+//
+//	[magic:4 "MBAK"][version:u16]
+//	([kind:u8][name:str][size:u64][data:size])*   kind 1: catalog.wal, 2: a table WAL
+//	[kind:u8 0][crc32:u32]                        of everything before it
+//
+// The files keep the WAL format and version they were written with, so a
+// backup of an older version restores like an old data directory does.
+const (
+	backupMagic          = "MBAK"
+	backupCurrentVersion = 1
+
+	backupEnd     = 0
+	backupCatalog = 1
+	backupTable   = 2
+)
+
+// BackupVersionError reports a backup file written by a newer version of
+// the engine.
+type BackupVersionError struct {
+	Version uint16
+}
+
+func (e *BackupVersionError) Error() string {
+	return fmt.Sprintf("backup file version %d is newer than the supported version %d", e.Version, backupCurrentVersion)
+}
+
+// Backup writes a consistent copy of the database to w as one file, which
+// Restore turns back into a data directory. The tables are captured
+// together as by Snapshot; the locks are released before anything is
+// written.
+func (e *engine) Backup(w io.Writer) error {
+	catalog, tables, err := e.captureSnapshot()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(bw, crc)
+	var hdr [6]byte
+	copy(hdr[:4], backupMagic)
+	binary.BigEndian.PutUint16(hdr[4:], backupCurrentVersion)
+	if _, err := out.Write(hdr[:]); err != nil {
+		return err
+	}
+	if err := writeBackupFile(out, backupCatalog, "", catalog); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, t := range tables {
+		buf.Reset()
+		if err := writeTableWAL(&buf, t.name, &t.rows, t.sequences); err != nil {
+			return err
+		}
+		if err := writeBackupFile(out, backupTable, t.name, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if _, err := out.Write([]byte{backupEnd}); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, crc.Sum32()); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeBackupFile writes one file of a backup.
+func writeBackupFile(w io.Writer, kind byte, name string, data []byte) error {
+	hdr := encodeString([]byte{kind}, name)
+	hdr = binary.BigEndian.AppendUint64(hdr, uint64(len(data)))
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// Backup writes a backup of the committed database; the transaction's own
+// changes are not part of it.
+func (tx *TxEngine) Backup(w io.Writer) error {
+	return tx.real.Backup(w)
+}
+
+// BackupToFile writes a backup of eng to a new file at path. Nothing is
+// left behind if it fails.
+func BackupToFile(eng Engine, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if err := eng.Backup(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("write backup: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("write backup: %w", err)
+	}
+	return f.Close()
+}
+
+// Restore rebuilds a data directory at dataDir from the backup file at
+// path. dataDir must not exist or be empty. The checksum of the file is
+// verified before Restore returns; if it fails, the files written so far
+// are removed again. Indexes are not part of a backup and are rebuilt
+// when the directory is opened.
+func Restore(path, dataDir string) error {
+	if err := checkEmptyDir(dataDir); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := restoreFiles(bufio.NewReader(f), dataDir); err != nil {
+		os.Remove(filepath.Join(dataDir, catalogWALName))
+		os.RemoveAll(filepath.Join(dataDir, tablesDirName))
+		return fmt.Errorf("restore %s: %w", path, err)
+	}
+	return nil
+}
+
+// restoreFiles reads the files of a backup from r and writes them to dir.
+func restoreFiles(r *bufio.Reader, dir string) error {
+	crc := crc32.NewIEEE()
+	in := io.TeeReader(r, crc)
+	var hdr [6]byte
+	if _, err := io.ReadFull(in, hdr[:]); err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	if string(hdr[:4]) != backupMagic {
+		return fmt.Errorf("not a backup file")
+	}
+	if v := binary.BigEndian.Uint16(hdr[4:]); v > backupCurrentVersion {
+		return &BackupVersionError{Version: v}
+	}
+
+	tablesDir := filepath.Join(dir, tablesDirName)
+	if err := os.MkdirAll(tablesDir, 0755); err != nil {
+		return err
+	}
+	hasCatalog := false
+	for {
+		var kind [1]byte
+		if _, err := io.ReadFull(in, kind[:]); err != nil {
+			return fmt.Errorf("read backup: %w", err)
+		}
+		if kind[0] == backupEnd {
+			break
+		}
+		name, size, err := readBackupFileHeader(in)
+		if err != nil {
+			return err
+		}
+		var dest string
+		switch {
+		case kind[0] == backupCatalog && !hasCatalog:
+			dest = filepath.Join(dir, catalogWALName)
+			hasCatalog = true
+		case kind[0] == backupTable && name != "":
+			dest = filepath.Join(tablesDir, tableFileName(name))
+		default:
+			return fmt.Errorf("unexpected file of kind %d in backup", kind[0])
+		}
+		if err := restoreFile(dest, in, size); err != nil {
+			return err
+		}
+	}
+	if !hasCatalog {
+		return fmt.Errorf("backup holds no catalog")
+	}
+	if err := checkBackupCRC(r, crc); err != nil {
+		return err
+	}
+	if err := syncDir(tablesDir); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// readBackupFileHeader reads the name and size of a file in a backup.
+func readBackupFileHeader(r io.Reader) (string, int64, error) {
+	var n [2]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return "", 0, fmt.Errorf("read backup: %w", err)
+	}
+	name := make([]byte, binary.BigEndian.Uint16(n[:]))
+	var size [8]byte
+	if _, err := io.ReadFull(r, name); err != nil {
+		return "", 0, fmt.Errorf("read backup: %w", err)
+	}
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return "", 0, fmt.Errorf("read backup: %w", err)
+	}
+	return string(name), int64(binary.BigEndian.Uint64(size[:])), nil
+}
+
+// restoreFile copies size bytes of r to a new file at path and fsyncs it.
+func restoreFile(path string, r io.Reader, size int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.CopyN(f, r, size); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("read backup: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// checkBackupCRC compares the checksum that ends a backup with crc, the
+// checksum of what was read before it.
+func checkBackupCRC(r io.Reader, crc hash.Hash32) error {
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		return fmt.Errorf("read backup checksum: %w", err)
+	}
+	if binary.BigEndian.Uint32(sum[:]) != crc.Sum32() {
+		return fmt.Errorf("backup checksum mismatch")
+	}
+	return nil
+}
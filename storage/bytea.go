@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ParseBytea parses the text form of a BYTEA value. Input starting with
+// \x is the hex format: pairs of hex digits, optionally separated by
+// whitespace. Anything else is the escape format, where \\ is a backslash,
+// \ooo is a byte in octal, and every other character stands for itself.
+func ParseBytea(s string) ([]byte, error) {
+	if strings.HasPrefix(s, `\x`) || strings.HasPrefix(s, `\X`) {
+		digits := strings.Map(func(r rune) rune {
+			switch r {
+			case ' ', '\t', '\n', '\r':
+				return -1
+			}
+			return r
+		}, s[2:])
+		b, err := hex.DecodeString(digits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hexadecimal data in bytea %q", s)
+		}
+		return b, nil
+	}
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		switch {
+		case i+1 < len(s) && s[i+1] == '\\':
+			b = append(b, '\\')
+			i++
+		case i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) && s[i+1] <= '3':
+			b = append(b, (s[i+1]-'0')<<6|(s[i+2]-'0')<<3|(s[i+3]-'0'))
+			i += 3
+		default:
+			return nil, fmt.Errorf("invalid escape sequence in bytea %q", s)
+		}
+	}
+	return b, nil
+}
+
+// FormatBytea returns b in the PostgreSQL hex output format, e.g. \x0aff.
+func FormatBytea(b []byte) string {
+	return `\x` + hex.EncodeToString(b)
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+// byteaKey is the map-key form of a BYTEA value, which as a []byte cannot
+// be hashed.
+type byteaKey string
+
+// tupleKey is the map-key form of a composite index key: the type and
+// length-prefixed text of each element's map key.
+type tupleKey string
+
+// mapKey returns v in a form usable as a map key, for the duplicate checks
+// that key maps by column value.
+func mapKey(v any) any {
+	switch x := v.(type) {
+	case []byte:
+		return byteaKey(x)
+	case Numeric:
+		return x.key()
+	case []any:
+		var b strings.Builder
+		for _, el := range x {
+			k := mapKey(el)
+			s := fmt.Sprint(k)
+			fmt.Fprintf(&b, "%T:%d:%s", k, len(s), s)
+		}
+		return tupleKey(b.String())
+	}
+	return v
+}
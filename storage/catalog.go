@@ -6,14 +6,25 @@ import "fmt"
 // on startup — there is no separate catalog file.
 type catalog struct {
 	tables map[string]*TableDef
+	views  map[string]*ViewDef
 }
 
 func newCatalog() *catalog {
-	return &catalog{tables: make(map[string]*TableDef)}
+	return &catalog{
+		tables: make(map[string]*TableDef),
+		views:  make(map[string]*ViewDef),
+	}
+}
+
+// exists reports whether a table or a view is named name.
+func (c *catalog) exists(name string) bool {
+	_, isTable := c.tables[name]
+	_, isView := c.views[name]
+	return isTable || isView
 }
 
 func (c *catalog) createTable(name string, columns []ColumnDef) error {
-	if _, exists := c.tables[name]; exists {
+	if c.exists(name) {
 		return &TableExistsError{Name: name}
 	}
 	// Derive NextOrdinal from the column ordinals.
@@ -109,3 +120,24 @@ func (c *catalog) getTable(name string) (*TableDef, bool) {
 	def, ok := c.tables[name]
 	return def, ok
 }
+
+func (c *catalog) createView(name, query string) error {
+	if c.exists(name) {
+		return &TableExistsError{Name: name}
+	}
+	c.views[name] = &ViewDef{Name: name, Query: query}
+	return nil
+}
+
+func (c *catalog) dropView(name string) error {
+	if _, exists := c.views[name]; !exists {
+		return &ViewNotFoundError{Name: name}
+	}
+	delete(c.views, name)
+	return nil
+}
+
+func (c *catalog) getView(name string) (*ViewDef, bool) {
+	def, ok := c.views[name]
+	return def, ok
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// checkpointBatchRows is how many rows go into one insert entry of a
+// checkpoint; the entry's row count is a uint16.
+const checkpointBatchRows = 1000
+
+// checkpointSuffix names the file a checkpoint is written to before it
+// replaces the table's WAL.
+const checkpointSuffix = ".ckpt"
+
+// Checkpoint rewrites the WAL of table as a snapshot of its live rows, so
+// that the file no longer grows with every UPDATE and DELETE ever run and
+// replay only has to insert each row once. The rows keep their IDs, and an
+// identity entry carries the positions of the identity sequences, which
+// the rows alone may not show. The snapshot is fsynced and renamed over
+// the WAL under the table's write lock, so a crash leaves either the old
+// file or the new one.
+func (e *engine) Checkpoint(table string) error {
+	ts, err := e.acquireTableWrite(table)
+	if err != nil {
+		return err
+	}
+	defer ts.mu.Unlock()
+
+	// Writers that released the lock may still wait for entries of the
+	// old file to become durable; once they are, closing it is safe.
+	old := ts.wal
+	if err := old.Sync(); err != nil {
+		return fmt.Errorf("WAL sync: %w", err)
+	}
+	path := old.file.Name()
+	tmpPath := path + checkpointSuffix
+	if err := writeCheckpoint(tmpPath, table, &ts.heap.rows, ts.heap.sequencePositions()); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("install checkpoint: %w", err)
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return e.failWAL(old, "fsync", err)
+	}
+
+	w, err := OpenWAL(path, false)
+	if err != nil {
+		return e.failWAL(old, "open", err)
+	}
+	w.fsync = &e.fsync
+	w.syncFile = old.syncFile
+	ts.wal = w
+	old.Close()
+	return nil
+}
+
+// failWAL stops a WAL whose file was replaced by a checkpoint that could
+// not be completed, since entries appended to it would be lost. The table
+// is refused until restart, when the checkpoint is replayed.
+func (e *engine) failWAL(w *WAL, op string, err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = &WALIOError{Op: op, Path: w.file.Name(), Err: err}
+	}
+	return w.err
+}
+
+// writeCheckpoint writes a WAL file at path holding the live rows of a
+// table, in ID order, and the positions of its identity sequences.
+func writeCheckpoint(path, table string, rows *rowStore, sequences map[int]int64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	if err := writeTableWAL(bw, table, rows, sequences); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeTableWAL writes the contents of a checkpoint to w: the WAL header,
+// the rows as insert batches and an identity entry.
+func writeTableWAL(w io.Writer, table string, rows *rowStore, sequences map[int]int64) error {
+	if err := writeWALHeader(w); err != nil {
+		return err
+	}
+
+	var batch []rowInsert
+	flush := func() error {
+		buf := encodeString(nil, table)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(batch)))
+		for _, ins := range batch {
+			buf = binary.BigEndian.AppendUint64(buf, uint64(ins.RowID))
+			buf = encodeValues(buf, ins.Values)
+		}
+		batch = batch[:0]
+		return writeRawEntry(w, opInsertBatch, buf)
+	}
+	for id, values := range rows.all() {
+		batch = append(batch, rowInsert{RowID: id, Values: values})
+		if len(batch) == checkpointBatchRows {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(sequences) > 0 {
+		buf := encodeString(nil, table)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(sequences)))
+		for _, ord := range slices.Sorted(maps.Keys(sequences)) {
+			buf = binary.BigEndian.AppendUint16(buf, uint16(ord))
+			buf = binary.BigEndian.AppendUint64(buf, uint64(sequences[ord]))
+		}
+		if err := writeRawEntry(w, opIdentity, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteTableWAL replaces the table WAL at path with a checkpoint of heap,
+// keeping the original as a backup, and opens it. Open uses it once
+// --migrate has converted rows as they were replayed.
+func rewriteTableWAL(path, table string, heap *tableHeap) (*WAL, error) {
+	tmpPath := path + checkpointSuffix
+	if err := writeCheckpoint(tmpPath, table, &heap.rows, heap.sequencePositions()); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	backupPath := chooseBackupPath(path)
+	if err := os.Rename(path, backupPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("backup original WAL: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Rename(backupPath, path)
+		return nil, fmt.Errorf("install rewritten WAL: %w", err)
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	log.Printf("table WAL rewritten. Original backed up to %s", backupPath)
+	return OpenWAL(path, false)
+}
+
+// syncDir fsyncs a directory, making a rename within it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Checkpoint checkpoints the committed table. A table the transaction
+// created or altered has no WAL of its own yet, so there is nothing to do.
+func (tx *TxEngine) Checkpoint(table string) error {
+	if _, ok := tx.privateTable(table); ok {
+		return nil
+	}
+	return tx.real.Checkpoint(table)
+}
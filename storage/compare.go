@@ -1,12 +1,15 @@
 package storage
 
 import (
+	"bytes"
 	"strings"
 	"time"
 )
 
 // CompareValues returns -1, 0, or 1 for ordering, or -2 if the values
-// are not comparable (e.g. NULL or type mismatch).
+// are not comparable (e.g. NULL or type mismatch). Composite index keys
+// ([]any) compare element by element. A NUMERIC compared with an INTEGER or
+// FLOAT is compared exactly, with the other value promoted to NUMERIC.
 func CompareValues(a, b any) int {
 	if a == nil || b == nil {
 		return -2
@@ -25,6 +28,8 @@ func CompareValues(a, b any) int {
 			}
 		case float64:
 			return compareFloat64(float64(av), bv)
+		case Numeric:
+			return NumericFromInt(av).Cmp(bv)
 		default:
 			return -2
 		}
@@ -38,6 +43,12 @@ func CompareValues(a, b any) int {
 				return -2
 			}
 			return CompareValues(t, bv)
+		case TimeOfDay:
+			t, err := ParseTime(av)
+			if err != nil {
+				return -2
+			}
+			return compareInt64(int64(t), int64(bv))
 		default:
 			return -2
 		}
@@ -47,9 +58,24 @@ func CompareValues(a, b any) int {
 			return compareFloat64(av, bv)
 		case int64:
 			return compareFloat64(av, float64(bv))
+		case Numeric:
+			return -compareNumericFloat(bv, av)
 		default:
 			return -2
 		}
+	case Numeric:
+		switch bv := b.(type) {
+		case Numeric:
+			return av.Cmp(bv)
+		case int64:
+			return av.Cmp(NumericFromInt(bv))
+		case float64:
+			return compareNumericFloat(av, bv)
+		default:
+			return -2
+		}
+	case []any:
+		return compareTuples(av, b)
 	case bool:
 		bv, ok := b.(bool)
 		if !ok {
@@ -82,11 +108,55 @@ func CompareValues(a, b any) int {
 		default:
 			return -2
 		}
+	case TimeOfDay:
+		switch bv := b.(type) {
+		case TimeOfDay:
+			return compareInt64(int64(av), int64(bv))
+		case string:
+			t, err := ParseTime(bv)
+			if err != nil {
+				return -2
+			}
+			return compareInt64(int64(av), int64(t))
+		default:
+			return -2
+		}
+	case []byte:
+		bv, ok := b.([]byte)
+		if !ok {
+			return -2
+		}
+		return bytes.Compare(av, bv)
 	default:
 		return -2
 	}
 }
 
+// compareNumericFloat compares a NUMERIC with a float64 as NUMERIC, the
+// more precise type, so that none of n's digits are lost. The float is
+// taken as the shortest decimal that converts back to it, which is the
+// value a literal was written as: 0.3 is 0.3, not the binary fraction
+// nearest to it. NaN and infinities, which NUMERIC lacks, compare as
+// floats.
+func compareNumericFloat(n Numeric, f float64) int {
+	m, err := NumericFromFloat(f)
+	if err != nil {
+		return compareFloat64(n.Float64(), f)
+	}
+	return n.Cmp(m)
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
 func compareFloat64(a, b float64) int {
 	switch {
 	case a < b:
@@ -97,3 +167,27 @@ func compareFloat64(a, b float64) int {
 		return 0
 	}
 }
+
+// compareTuples orders composite keys element by element. They are
+// incomparable if any element pair is. Only the elements both keys have
+// are compared, so a shorter key equals every key it is a prefix of: a
+// bound on the leading columns of a composite index covers all keys that
+// start with those values.
+func compareTuples(a []any, b any) int {
+	bv, ok := b.([]any)
+	if !ok || len(a) == 0 || len(bv) == 0 {
+		return -2
+	}
+	n := min(len(a), len(bv))
+	result := 0
+	for i := range n {
+		c := CompareValues(a[i], bv[i])
+		if c == -2 {
+			return -2
+		}
+		if result == 0 {
+			result = c
+		}
+	}
+	return result
+}
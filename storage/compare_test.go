@@ -0,0 +1,66 @@
+package storage
+
+import "testing"
+
+func TestCompareValues_NumericPromotion(t *testing.T) {
+	num := func(s string) Numeric {
+		t.Helper()
+		n, err := ParseNumeric(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	tests := []struct {
+		name string
+		a, b any
+		want int
+	}{
+		// As float64 both sides would be 2^53.
+		{"numeric above int", num("9007199254740993"), int64(9007199254740992), 1},
+		{"int below numeric", int64(9007199254740992), num("9007199254740993"), -1},
+		{"numeric equals int", num("42.000"), int64(42), 0},
+		// As float64 the NUMERIC would round to 0.3.
+		{"numeric above float", num("0.30000000000000001"), 0.3, 1},
+		{"float below numeric", 0.3, num("0.30000000000000001"), -1},
+		{"numeric equals float", num("0.30"), 0.3, 0},
+		{"float equals numeric", 0.1, num("0.1"), 0},
+		{"large float", num("1e20"), 1e20, 0},
+		{"negative", num("-2.5"), -2.4, -1},
+	}
+	for _, tt := range tests {
+		if got := CompareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: CompareValues(%v, %v) = %d, want %d", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCompareValues_Time(t *testing.T) {
+	tm := func(s string) TimeOfDay {
+		t.Helper()
+		v, err := ParseTime(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		a, b any
+		want int
+	}{
+		{tm("09:00"), tm("09:00:00.000001"), -1},
+		{tm("24:00:00"), tm("23:59:59.999999"), 1},
+		{tm("12:30"), "12:30:00", 0},
+		{"8:15", tm("08:15:01"), -1},
+		{tm("12:30"), "noon", -2},
+		{tm("12:30"), int64(1), -2},
+	}
+	for _, tt := range tests {
+		if got := CompareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareValues(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if got := tm("2024-01-15 08:45:10.25").String(); got != "08:45:10.25" {
+		t.Errorf("time of a timestamp = %s, want 08:45:10.25", got)
+	}
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// csvBatchRows is how many rows of a LoadCSV go into one WAL entry.
+const csvBatchRows = 1000
+
+// CSVOptions controls how LoadCSV reads its input.
+type CSVOptions struct {
+	// Delimiter separates the fields of a record; 0 means a comma.
+	Delimiter rune
+	// Header says the first record names the columns the others fill, in
+	// order. Without it every record holds all columns in table order.
+	Header bool
+	// Null is the field text read as NULL. encoding/csv does not report
+	// quoting, so a quoted field equal to it is NULL as well. The default
+	// "" makes empty fields NULL, as in PostgreSQL's CSV format.
+	Null string
+}
+
+// csvData is the input of a LoadCSV, read but not yet coerced.
+type csvData struct {
+	columns []string // from the header; nil for all columns
+	rows    [][]any  // string fields, nil for NULL
+	lines   []int    // the line each row starts on, for errors
+}
+
+// readCSV reads the records of r. The header, if any, must name distinct
+// columns of def; each record must have as many fields as the header, or
+// as def has columns if there is none.
+func readCSV(def *TableDef, r io.Reader, opts CSVOptions) (*csvData, error) {
+	cr := csv.NewReader(r)
+	if opts.Delimiter != 0 {
+		cr.Comma = opts.Delimiter
+	}
+	cr.FieldsPerRecord = -1 // counted here, to report a ValueCountError
+	cr.ReuseRecord = true
+
+	d := &csvData{}
+	width := len(def.Columns)
+	if opts.Header {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return d, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.columns = append([]string(nil), rec...)
+		width = len(d.columns)
+		for i, name := range d.columns {
+			if !slices.ContainsFunc(def.Columns, func(c ColumnDef) bool { return c.Name == name }) {
+				return nil, &ColumnNotFoundError{Column: name, Table: def.Name}
+			}
+			if slices.Contains(d.columns[:i], name) {
+				return nil, fmt.Errorf("column %q specified more than once", name)
+			}
+		}
+	}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return d, nil
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				return nil, err // already names the line
+			}
+			return nil, fmt.Errorf("read CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(rec) != width {
+			return nil, fmt.Errorf("line %d: %w", line, &ValueCountError{Expected: width, Got: len(rec)})
+		}
+		row := make([]any, len(rec))
+		for i, f := range rec {
+			if f != opts.Null {
+				row[i] = f
+			}
+		}
+		d.rows = append(d.rows, row)
+		d.lines = append(d.lines, line)
+	}
+}
+
+// LoadCSV inserts the rows of a CSV file into table and returns how many
+// it stored. Fields are coerced to their column's type as string literals
+// are by INSERT; columns a header leaves out are NULL or take their next
+// identity value, since DEFAULT expressions belong to the executor. The
+// rows are checked like an INSERT's and written as one transaction group,
+// in batches of csvBatchRows rows with a single fsync at the end, so all
+// or none of them are stored.
+func (e *engine) LoadCSV(table string, r io.Reader, opts CSVOptions) (int64, error) {
+	def, ok := e.GetTable(table)
+	if !ok {
+		return 0, &TableNotFoundError{Name: table}
+	}
+	d, err := readCSV(def, r, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	ts, err := e.acquireTableWrite(table)
+	if err != nil {
+		return 0, err
+	}
+	defer ts.mu.Unlock()
+	heap := ts.heap
+
+	resolvedRows := make([][]any, len(d.rows))
+	for i, vals := range d.rows {
+		if resolvedRows[i], err = resolveInsertRow(heap, d.columns, vals); err != nil {
+			return 0, fmt.Errorf("line %d: %w", d.lines[i], err)
+		}
+	}
+	if err := validateInsertRows(heap, table, resolvedRows); err != nil {
+		return 0, err
+	}
+	if len(resolvedRows) == 0 {
+		return 0, nil
+	}
+
+	inserts := make([]rowInsert, len(resolvedRows))
+	for i, fullRow := range resolvedRows {
+		inserts[i] = rowInsert{RowID: heap.allocateID(), Values: fullRow}
+	}
+	if err := ts.wal.WriteBeginTx(); err != nil {
+		return 0, fmt.Errorf("WAL begin: %w", err)
+	}
+	for start := 0; start < len(inserts); start += csvBatchRows {
+		batch := inserts[start:min(start+csvBatchRows, len(inserts))]
+		if err := ts.wal.WriteInsertBatchNoSync(table, batch); err != nil {
+			return 0, fmt.Errorf("WAL: %w", err)
+		}
+	}
+	if err := ts.wal.WriteCommitTx(); err != nil {
+		return 0, fmt.Errorf("WAL commit: %w", err)
+	}
+	for _, ins := range inserts {
+		heap.insertWithID(ins.RowID, ins.Values)
+	}
+	return int64(len(inserts)), nil
+}
+
+// LoadCSV reads the CSV file like the engine's LoadCSV and buffers its
+// rows in the overlay as an INSERT would.
+func (tx *TxEngine) LoadCSV(table string, r io.Reader, opts CSVOptions) (int64, error) {
+	def, ok := tx.GetTable(table)
+	if !ok {
+		return 0, &TableNotFoundError{Name: table}
+	}
+	d, err := readCSV(def, r, opts)
+	if err != nil {
+		return 0, err
+	}
+	if len(d.rows) == 0 {
+		return 0, nil
+	}
+	return tx.Insert(table, d.columns, d.rows)
+}
@@ -1,13 +1,19 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
+
+	"mulldb/storage/index"
 )
 
 // tableState holds the per-table mutex, heap, WAL, and a flag indicating
@@ -18,6 +24,8 @@ type tableState struct {
 	heap    *tableHeap
 	wal     *WAL
 	dropped bool
+	stats   atomic.Pointer[TableStats] // nil until the first ANALYZE
+	vacuums uint64                     // VACUUMs so far, which renumber rows
 }
 
 // engine is the concrete storage engine implementation. It uses per-table
@@ -37,6 +45,8 @@ type tableState struct {
 //   - Insert/Update/Delete: catalogMu read lock (brief) → table write lock
 //   - Scan/LookupByPK: catalogMu read lock (brief) → table read lock
 //   - GetTable/ListTables: catalogMu read lock only
+//   - Multi-table Truncate, like TxEngine.CommitOverlay: table write locks
+//     in name order → catalogMu write lock (brief, for the commit record)
 type engine struct {
 	dataDir     string
 	catalogMu   sync.RWMutex
@@ -159,11 +169,24 @@ func (e *engine) openTableState(def TableDef, tablesDir string, migrate bool, tx
 	}
 
 	heap := newTableHeap(def)
-	handler := &dmlReplayHandler{tableName: def.Name, heap: heap}
+	handler := &dmlReplayHandler{tableName: def.Name, heap: heap, migrate: migrate}
 	if err := w.ReplayWithTxRecovery(handler, txCommitted); err != nil {
 		w.Close()
+		if errors.As(err, new(*DatatypeMismatchError)) {
+			return nil, fmt.Errorf("replay: %w; restart with --migrate flag to convert the stored values", err)
+		}
 		return nil, fmt.Errorf("replay: %w", err)
 	}
+	if handler.migrated > 0 {
+		log.Printf("converted %d rows of table %q to the column types; rewriting its WAL...", handler.migrated, def.Name)
+		nw, err := rewriteTableWAL(w.file.Name(), def.Name, heap)
+		w.Close()
+		if err != nil {
+			return nil, fmt.Errorf("rewrite WAL: %w", err)
+		}
+		w = nw
+	}
+	heap.encodeDictionaries()
 
 	// Initialize and populate secondary indexes from the catalog metadata.
 	for _, idx := range def.Indexes {
@@ -180,7 +203,8 @@ func (e *engine) openTableState(def TableDef, tablesDir string, migrate bool, tx
 // cleanOrphanWALs scans the tables directory and removes WAL files for
 // tables that don't exist in the catalog. This handles the case where a
 // crash occurred between writing the DROP TABLE entry to the catalog WAL
-// and deleting the table's WAL file.
+// and deleting the table's WAL file. Leftover checkpoint files are removed
+// too.
 func (e *engine) cleanOrphanWALs(tablesDir string) error {
 	entries, err := os.ReadDir(tablesDir)
 	if err != nil {
@@ -190,6 +214,13 @@ func (e *engine) cleanOrphanWALs(tablesDir string) error {
 		if entry.IsDir() {
 			continue
 		}
+		if strings.HasSuffix(entry.Name(), checkpointSuffix) {
+			// A checkpoint that crashed before replacing the WAL.
+			if err := os.Remove(filepath.Join(tablesDir, entry.Name())); err != nil {
+				return fmt.Errorf("remove unfinished checkpoint %q: %w", entry.Name(), err)
+			}
+			continue
+		}
 		name, err := tableNameFromFile(entry.Name())
 		if err != nil {
 			continue // skip non-table files
@@ -281,6 +312,14 @@ func (h *catalogReplayHandler) OnDropIndex(table string, indexName string) error
 	return h.catalog.dropIndex(table, indexName)
 }
 
+func (h *catalogReplayHandler) OnCreateView(name, query string) error {
+	return h.catalog.createView(name, query)
+}
+
+func (h *catalogReplayHandler) OnDropView(name string) error {
+	return h.catalog.dropView(name)
+}
+
 func (h *catalogReplayHandler) OnInsert(string, int64, []any) error {
 	return fmt.Errorf("unexpected INSERT in catalog WAL")
 }
@@ -293,6 +332,18 @@ func (h *catalogReplayHandler) OnUpdate(string, []rowUpdate) error {
 	return fmt.Errorf("unexpected UPDATE in catalog WAL")
 }
 
+func (h *catalogReplayHandler) OnTruncate(string, bool) error {
+	return fmt.Errorf("unexpected TRUNCATE in catalog WAL")
+}
+
+func (h *catalogReplayHandler) OnVacuum(string) error {
+	return fmt.Errorf("unexpected VACUUM in catalog WAL")
+}
+
+func (h *catalogReplayHandler) OnIdentity(string, map[int]int64) error {
+	return fmt.Errorf("unexpected identity entry in catalog WAL")
+}
+
 func (h *catalogReplayHandler) OnTxCommit(tables []string) error {
 	for _, t := range tables {
 		h.txCommittedTables[t] = true
@@ -302,9 +353,25 @@ func (h *catalogReplayHandler) OnTxCommit(tables []string) error {
 
 // dmlReplayHandler accepts only DML entries (Insert/Delete/Update) and
 // validates that the table name in each entry matches the expected table.
+// With migrate set, it converts the values of rows written by engines that
+// stored INTEGER columns boxed, counting the rows in migrated.
 type dmlReplayHandler struct {
 	tableName string
 	heap      *tableHeap
+	migrate   bool
+	migrated  int
+}
+
+// migrateValues converts values for the heap if the handler migrates.
+func (h *dmlReplayHandler) migrateValues(values []any) []any {
+	if !h.migrate {
+		return values
+	}
+	values, changed := h.heap.migrateValues(values)
+	if changed {
+		h.migrated++
+	}
+	return values
 }
 
 func (h *dmlReplayHandler) OnCreateTable(string, []ColumnDef) error {
@@ -331,11 +398,19 @@ func (h *dmlReplayHandler) OnDropIndex(string, string) error {
 	return fmt.Errorf("unexpected DROP INDEX in table WAL for %q", h.tableName)
 }
 
+func (h *dmlReplayHandler) OnCreateView(string, string) error {
+	return fmt.Errorf("unexpected CREATE VIEW in table WAL for %q", h.tableName)
+}
+
+func (h *dmlReplayHandler) OnDropView(string) error {
+	return fmt.Errorf("unexpected DROP VIEW in table WAL for %q", h.tableName)
+}
+
 func (h *dmlReplayHandler) OnInsert(table string, rowID int64, values []any) error {
 	if table != h.tableName {
 		return fmt.Errorf("table name mismatch in WAL: got %q, want %q", table, h.tableName)
 	}
-	return h.heap.insertWithID(rowID, values)
+	return h.heap.insertWithID(rowID, h.migrateValues(values))
 }
 
 func (h *dmlReplayHandler) OnDelete(table string, rowIDs []int64) error {
@@ -350,9 +425,35 @@ func (h *dmlReplayHandler) OnUpdate(table string, updates []rowUpdate) error {
 	if table != h.tableName {
 		return fmt.Errorf("table name mismatch in WAL: got %q, want %q", table, h.tableName)
 	}
-	for _, u := range updates {
-		if err := h.heap.updateRow(u.RowID, u.Values); err != nil {
-			return err
+	for i := range updates {
+		updates[i].Values = h.migrateValues(updates[i].Values)
+	}
+	return h.heap.updateRows(updates)
+}
+
+func (h *dmlReplayHandler) OnTruncate(table string, restartIdentity bool) error {
+	if table != h.tableName {
+		return fmt.Errorf("table name mismatch in WAL: got %q, want %q", table, h.tableName)
+	}
+	h.heap.truncate(restartIdentity)
+	return nil
+}
+
+func (h *dmlReplayHandler) OnVacuum(table string) error {
+	if table != h.tableName {
+		return fmt.Errorf("table name mismatch in WAL: got %q, want %q", table, h.tableName)
+	}
+	h.heap.vacuum()
+	return nil
+}
+
+func (h *dmlReplayHandler) OnIdentity(table string, last map[int]int64) error {
+	if table != h.tableName {
+		return fmt.Errorf("table name mismatch in WAL: got %q, want %q", table, h.tableName)
+	}
+	for ord, n := range last {
+		if seq := h.heap.sequences[ord]; seq != nil {
+			seq.observe(n)
 		}
 	}
 	return nil
@@ -370,7 +471,7 @@ func (e *engine) CreateTable(name string, columns []ColumnDef) error {
 	e.catalogMu.Lock()
 	defer e.catalogMu.Unlock()
 
-	if _, exists := e.catalog.getTable(name); exists {
+	if e.catalog.exists(name) {
 		return &TableExistsError{Name: name}
 	}
 
@@ -452,16 +553,11 @@ func (e *engine) AddColumn(table string, col ColumnDef) error {
 		return &TableNotFoundError{Name: table}
 	}
 
-	// Validate column name is not a duplicate.
-	for _, existing := range ts.heap.def.Columns {
-		if existing.Name == col.Name {
-			return &ColumnExistsError{Column: col.Name, Table: table}
-		}
+	col, err = prepareColumn(ts.heap, int64(ts.heap.count), col)
+	if err != nil {
+		return err
 	}
 
-	// Assign ordinal.
-	col.Ordinal = ts.heap.def.NextOrdinal
-
 	// Write to catalog WAL.
 	if err := e.catalogWAL.WriteAddColumn(table, col); err != nil {
 		return fmt.Errorf("catalog WAL: %w", err)
@@ -470,9 +566,40 @@ func (e *engine) AddColumn(table string, col ColumnDef) error {
 	// Update catalog + heap def.
 	e.catalog.addColumn(table, col)
 	ts.heap.def = *e.catalog.tables[table]
+	ts.heap.addColumn(col)
 	return nil
 }
 
+// prepareColumn checks that col can be added to the table of heap, which
+// holds rows rows, and returns it with its ordinal assigned and its Fill
+// value coerced to the column type.
+func prepareColumn(heap *tableHeap, rows int64, col ColumnDef) (ColumnDef, error) {
+	table := heap.def.Name
+
+	// Validate column name is not a duplicate.
+	for _, existing := range heap.def.Columns {
+		if existing.Name == col.Name {
+			return col, &ColumnExistsError{Column: col.Name, Table: table}
+		}
+	}
+
+	// Assign ordinal.
+	col.Ordinal = heap.def.NextOrdinal
+
+	// Existing rows take the fill value, which must satisfy NOT NULL.
+	if col.Fill != nil {
+		vals := make([]any, col.Ordinal+1)
+		vals[col.Ordinal] = col.Fill
+		if _, err := coerceRowValues(&TableDef{Columns: []ColumnDef{col}}, vals); err != nil {
+			return col, err
+		}
+		col.Fill = vals[col.Ordinal]
+	} else if col.NotNull && rows > 0 {
+		return col, &NotNullViolationError{Table: table, Column: col.Name}
+	}
+	return col, nil
+}
+
 func (e *engine) DropColumn(table string, colName string) error {
 	e.catalogMu.Lock()
 	defer e.catalogMu.Unlock()
@@ -518,6 +645,7 @@ func (e *engine) DropColumn(table string, colName string) error {
 
 	// Update heap def.
 	ts.heap.def = *e.catalog.tables[table]
+	ts.stats.Store(nil)
 	return nil
 }
 
@@ -537,16 +665,14 @@ func (e *engine) CreateIndex(table string, idx IndexDef) error {
 		return &TableNotFoundError{Name: table}
 	}
 
-	// Validate column exists.
-	colExists := false
-	for _, col := range ts.heap.def.Columns {
-		if col.Name == idx.Column {
-			colExists = true
-			break
-		}
+	// Validate the columns exist.
+	if len(idx.Columns) == 0 {
+		return fmt.Errorf("index %q has no columns", idx.Name)
 	}
-	if !colExists {
-		return &ColumnNotFoundError{Column: idx.Column, Table: table}
+	for _, name := range idx.Columns {
+		if ts.heap.columnIndex(name) < 0 {
+			return &ColumnNotFoundError{Column: name, Table: table}
+		}
 	}
 
 	// Validate index name is unique within the table.
@@ -614,6 +740,35 @@ func (e *engine) DropIndex(table string, indexName string) error {
 	return nil
 }
 
+func (e *engine) CreateView(name, query string) error {
+	e.catalogMu.Lock()
+	defer e.catalogMu.Unlock()
+
+	if e.catalog.exists(name) {
+		return &TableExistsError{Name: name}
+	}
+	if len(query) > math.MaxUint16 {
+		return fmt.Errorf("view definition of %q exceeds %d bytes", name, math.MaxUint16)
+	}
+	if err := e.catalogWAL.WriteCreateView(name, query); err != nil {
+		return fmt.Errorf("catalog WAL: %w", err)
+	}
+	return e.catalog.createView(name, query)
+}
+
+func (e *engine) DropView(name string) error {
+	e.catalogMu.Lock()
+	defer e.catalogMu.Unlock()
+
+	if _, exists := e.catalog.getView(name); !exists {
+		return &ViewNotFoundError{Name: name}
+	}
+	if err := e.catalogWAL.WriteDropView(name); err != nil {
+		return fmt.Errorf("catalog WAL: %w", err)
+	}
+	return e.catalog.dropView(name)
+}
+
 func (e *engine) LookupByIndex(table string, indexName string, value any) ([]Row, error) {
 	ts, err := e.acquireTableRead(table)
 	if err != nil {
@@ -632,6 +787,22 @@ func (e *engine) LookupByIndex(table string, indexName string, value any) ([]Row
 	return result, nil
 }
 
+func (e *engine) LookupByIndexRange(table string, indexName string, lo, hi *KeyBound) ([]Row, error) {
+	ts, err := e.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.mu.RUnlock()
+
+	rows := ts.heap.lookupByIndexRange(indexName, lo, hi)
+	for i, row := range rows {
+		vals := make([]any, len(row.Values))
+		copy(vals, row.Values)
+		rows[i] = Row{ID: row.ID, Values: vals}
+	}
+	return rows, nil
+}
+
 // -------------------------------------------------------------------------
 // Engine interface — read-only metadata
 // -------------------------------------------------------------------------
@@ -643,6 +814,24 @@ func (e *engine) GetTable(name string) (*TableDef, bool) {
 	return e.catalog.getTable(name)
 }
 
+func (e *engine) GetView(name string) (*ViewDef, bool) {
+	e.catalogMu.RLock()
+	defer e.catalogMu.RUnlock()
+
+	return e.catalog.getView(name)
+}
+
+func (e *engine) ListViews() []*ViewDef {
+	e.catalogMu.RLock()
+	defer e.catalogMu.RUnlock()
+
+	defs := make([]*ViewDef, 0, len(e.catalog.views))
+	for _, def := range e.catalog.views {
+		defs = append(defs, def)
+	}
+	return defs
+}
+
 func (e *engine) RowCount(table string) (int64, error) {
 	ts, err := e.getTableState(table)
 	if err != nil {
@@ -653,6 +842,45 @@ func (e *engine) RowCount(table string) (int64, error) {
 	return int64(ts.heap.count), nil
 }
 
+// Analyze rebuilds the planner statistics of table from its current rows.
+func (e *engine) Analyze(table string) error {
+	ts, err := e.acquireTableRead(table)
+	if err != nil {
+		return err
+	}
+	stats := analyzeHeap(ts.heap)
+	ts.mu.RUnlock()
+	ts.stats.Store(stats)
+	return nil
+}
+
+// Vacuum compacts the rows array of table under its write lock, so
+// readers wait only for the rebuild of the array and its indexes. The
+// VACUUM is logged first, since it changes the row IDs later entries refer
+// to.
+func (e *engine) Vacuum(table string) (int64, error) {
+	ts, err := e.acquireTableWrite(table)
+	if err != nil {
+		return 0, err
+	}
+	defer ts.mu.Unlock()
+	if err := ts.wal.WriteVacuum(table); err != nil {
+		return 0, fmt.Errorf("WAL: %w", err)
+	}
+	ts.vacuums++
+	return int64(ts.heap.vacuum()), nil
+}
+
+func (e *engine) Stats(table string) *TableStats {
+	e.catalogMu.RLock()
+	ts, err := e.getTableState(table)
+	e.catalogMu.RUnlock()
+	if err != nil {
+		return nil
+	}
+	return ts.stats.Load()
+}
+
 func (e *engine) ListTables() []*TableDef {
 	e.catalogMu.RLock()
 	defer e.catalogMu.RUnlock()
@@ -686,11 +914,16 @@ func (e *engine) MemoryUsage() []TableMemoryInfo {
 // -------------------------------------------------------------------------
 
 func (e *engine) Insert(table string, columns []string, values [][]any) (int64, error) {
+	rows, err := e.InsertReturning(table, columns, values)
+	return int64(len(rows)), err
+}
+
+func (e *engine) InsertReturning(table string, columns []string, values [][]any) (rows []Row, err error) {
 	ts, err := e.acquireTableWrite(table)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer ts.mu.Unlock()
+	defer ts.finishWrite(&rows, &err)
 
 	heap := ts.heap
 
@@ -699,11 +932,375 @@ func (e *engine) Insert(table string, columns []string, values [][]any) (int64,
 	for _, vals := range values {
 		fullRow, err := resolveInsertRow(heap, columns, vals)
 		if err != nil {
-			return 0, err
+			return nil, err
+		}
+		resolvedRows = append(resolvedRows, fullRow)
+	}
+
+	if err := validateInsertRows(heap, table, resolvedRows); err != nil {
+		return nil, err
+	}
+
+	// Allocate all row IDs, write a single batched WAL entry, then apply
+	// to the heap. If the WAL write fails, zero rows are applied.
+	inserts := make([]rowInsert, len(resolvedRows))
+	for i, fullRow := range resolvedRows {
+		inserts[i] = rowInsert{RowID: heap.allocateID(), Values: fullRow}
+	}
+	if err := ts.wal.WriteInsertBatchNoSync(table, inserts); err != nil {
+		return nil, fmt.Errorf("WAL: %w", err)
+	}
+	rows = make([]Row, len(inserts))
+	for i, ins := range inserts {
+		heap.insertWithID(ins.RowID, ins.Values)
+		rows[i] = Row{ID: ins.RowID, Values: ins.Values}
+	}
+	return rows, nil
+}
+
+func (e *engine) Scan(table string) (RowIterator, error) {
+	ts, err := e.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.mu.RUnlock()
+
+	return ts.heap.scan(), nil
+}
+
+func (e *engine) ScanPKRange(table string, lo, hi *KeyBound) (RowIterator, error) {
+	ts, err := e.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.mu.RUnlock()
+
+	if ts.heap.pkIdx == nil {
+		return nil, fmt.Errorf("table %q has no primary key", table)
+	}
+	return &sliceIterator{rows: ts.heap.scanPKRange(lo, hi)}, nil
+}
+
+func (e *engine) ScanEqual(table, column string, value any) (RowIterator, error) {
+	ts, err := e.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.mu.RUnlock()
+
+	ord := ts.heap.columnIndex(column)
+	if ord < 0 {
+		return nil, &ColumnNotFoundError{Column: column, Table: table}
+	}
+	return ts.heap.scanEqual(ord, value), nil
+}
+
+func (e *engine) Update(table string, sets map[string]any, filter func(Row) bool) (int64, error) {
+	rows, err := e.UpdateReturning(table, sets, filter)
+	return int64(len(rows)), err
+}
+
+func (e *engine) UpdateReturning(table string, sets map[string]any, filter func(Row) bool) (rows []Row, err error) {
+	ts, err := e.acquireTableWrite(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.finishWrite(&rows, &err)
+
+	heap := ts.heap
+
+	var updates []rowUpdate
+	for id, values := range heap.rows.all() {
+		row := Row{ID: id, Values: values}
+		if filter != nil && !filter(row) {
+			continue
+		}
+		newValues, err := applySets(heap, values, sets)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, rowUpdate{RowID: id, Values: newValues})
+	}
+
+	if len(updates) == 0 {
+		return nil, nil
+	}
+
+	if err := validateUpdateRows(heap, table, sets, updates); err != nil {
+		return nil, err
+	}
+
+	if err := ts.wal.WriteUpdateNoSync(table, updates); err != nil {
+		return nil, fmt.Errorf("WAL: %w", err)
+	}
+	if err := heap.updateRows(updates); err != nil {
+		return nil, err
+	}
+	rows = make([]Row, len(updates))
+	for i, u := range updates {
+		rows[i] = Row{ID: u.RowID, Values: u.Values}
+	}
+	return rows, nil
+}
+
+// Upsert inserts rows like InsertReturning, except that a row whose
+// conflict target key is already taken is skipped (DO NOTHING) or turned
+// into an update of the existing row (DO UPDATE). Inserts and updates of
+// one statement are logged as a single BeginTx/CommitTx group, so a crash
+// cannot leave half of it applied.
+func (e *engine) Upsert(table string, columns []string, values [][]any, oc OnConflict) (rows []Row, err error) {
+	ts, err := e.acquireTableWrite(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.finishWrite(&rows, &err)
+
+	heap := ts.heap
+
+	keys, err := conflictKeys(heap, oc)
+	if err != nil {
+		return nil, err
+	}
+	resolvedRows := make([][]any, 0, len(values))
+	for _, vals := range values {
+		fullRow, err := resolveInsertRow(heap, columns, vals)
+		if err != nil {
+			return nil, err
 		}
 		resolvedRows = append(resolvedRows, fullRow)
 	}
 
+	lookup := func(k uniqueKey, key any) (int64, bool) {
+		if k.index == nil {
+			return heap.pkIdx.Get(key)
+		}
+		return k.index.Get(key)
+	}
+	newRows, updateIDs, err := planUpsert(table, resolvedRows, keys, oc.Sets != nil, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make([]rowUpdate, 0, len(updateIDs))
+	for _, id := range updateIDs {
+		newValues, err := applySets(heap, heap.rows.get(id), oc.Sets)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, rowUpdate{RowID: id, Values: newValues})
+	}
+
+	if err := validateInsertRows(heap, table, newRows); err != nil {
+		return nil, err
+	}
+	if err := validateUpdateRows(heap, table, oc.Sets, updates); err != nil {
+		return nil, err
+	}
+	if err := checkUpsertKeys(heap, table, newRows, updates); err != nil {
+		return nil, err
+	}
+
+	inserts := make([]rowInsert, len(newRows))
+	for i, fullRow := range newRows {
+		inserts[i] = rowInsert{RowID: heap.allocateID(), Values: fullRow}
+	}
+	switch {
+	case len(inserts) > 0 && len(updates) > 0:
+		if err := ts.wal.WriteBeginTx(); err != nil {
+			return nil, fmt.Errorf("WAL: %w", err)
+		}
+		if err := ts.wal.WriteInsertBatchNoSync(table, inserts); err != nil {
+			return nil, fmt.Errorf("WAL: %w", err)
+		}
+		if err := ts.wal.WriteUpdateNoSync(table, updates); err != nil {
+			return nil, fmt.Errorf("WAL: %w", err)
+		}
+		if err := ts.wal.WriteCommitTxNoSync(); err != nil {
+			return nil, fmt.Errorf("WAL: %w", err)
+		}
+	case len(inserts) > 0:
+		if err := ts.wal.WriteInsertBatchNoSync(table, inserts); err != nil {
+			return nil, fmt.Errorf("WAL: %w", err)
+		}
+	case len(updates) > 0:
+		if err := ts.wal.WriteUpdateNoSync(table, updates); err != nil {
+			return nil, fmt.Errorf("WAL: %w", err)
+		}
+	}
+
+	rows = make([]Row, 0, len(inserts)+len(updates))
+	for _, ins := range inserts {
+		heap.insertWithID(ins.RowID, ins.Values)
+		rows = append(rows, Row{ID: ins.RowID, Values: ins.Values})
+	}
+	if err := heap.updateRows(updates); err != nil {
+		return nil, err
+	}
+	for _, u := range updates {
+		rows = append(rows, Row{ID: u.RowID, Values: u.Values})
+	}
+	return rows, nil
+}
+
+func (e *engine) Delete(table string, filter func(Row) bool) (int64, error) {
+	rows, err := e.DeleteReturning(table, filter)
+	return int64(len(rows)), err
+}
+
+func (e *engine) DeleteReturning(table string, filter func(Row) bool) (rows []Row, err error) {
+	ts, err := e.acquireTableWrite(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.finishWrite(&rows, &err)
+
+	heap := ts.heap
+
+	var ids []int64
+	for id, values := range heap.rows.all() {
+		row := Row{ID: id, Values: values}
+		if filter != nil && !filter(row) {
+			continue
+		}
+		ids = append(ids, id)
+		rows = append(rows, row)
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if err := ts.wal.WriteDeleteNoSync(table, ids); err != nil {
+		return nil, fmt.Errorf("WAL: %w", err)
+	}
+	heap.deleteRows(ids)
+	return rows, nil
+}
+
+func (e *engine) DeleteRows(table string, rowIDs []int64, filter func(Row) bool) (rows []Row, err error) {
+	ts, err := e.acquireTableWrite(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.finishWrite(&rows, &err)
+
+	heap := ts.heap
+
+	var ids []int64
+	seen := make(map[int64]struct{}, len(rowIDs))
+	for _, id := range rowIDs {
+		values := heap.rows.get(id)
+		if values == nil {
+			continue
+		}
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		row := Row{ID: id, Values: values}
+		if filter != nil && !filter(row) {
+			continue
+		}
+		ids = append(ids, id)
+		rows = append(rows, row)
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if err := ts.wal.WriteDeleteNoSync(table, ids); err != nil {
+		return nil, fmt.Errorf("WAL: %w", err)
+	}
+	heap.deleteRows(ids)
+	return rows, nil
+}
+
+// Truncate removes every row of the tables with a single WAL entry each,
+// instead of the per-row tombstones a full DELETE writes. Several tables
+// are emptied together or not at all: their write locks are taken in name
+// order, as CommitOverlay takes them, and their entries are committed as
+// one transaction, so that recovery replays all of them or none.
+func (e *engine) Truncate(tables []string, restartIdentity bool) error {
+	tables = slices.Clone(tables)
+	slices.Sort(tables)
+	tables = slices.Compact(tables)
+
+	locked := make([]*tableState, 0, len(tables))
+	defer func() {
+		for _, ts := range locked {
+			ts.mu.Unlock()
+		}
+	}()
+	for _, t := range tables {
+		ts, err := e.acquireTableWrite(t)
+		if err != nil {
+			return err
+		}
+		locked = append(locked, ts)
+	}
+
+	if len(tables) == 1 {
+		if err := locked[0].wal.WriteTruncate(tables[0], restartIdentity); err != nil {
+			return fmt.Errorf("WAL: %w", err)
+		}
+	} else {
+		for i, ts := range locked {
+			if err := ts.wal.WriteBeginTx(); err != nil {
+				return fmt.Errorf("WAL begin: %w", err)
+			}
+			if err := ts.wal.WriteTruncateNoSync(tables[i], restartIdentity); err != nil {
+				return fmt.Errorf("WAL: %w", err)
+			}
+		}
+		for _, ts := range locked {
+			if err := ts.wal.Sync(); err != nil {
+				return fmt.Errorf("WAL sync: %w", err)
+			}
+		}
+		e.catalogMu.Lock()
+		err := e.catalogWAL.WriteTxCommit(tables)
+		e.catalogMu.Unlock()
+		if err != nil {
+			return fmt.Errorf("catalog WAL tx commit: %w", err)
+		}
+		for _, ts := range locked {
+			if err := ts.wal.WriteCommitTx(); err != nil {
+				return fmt.Errorf("WAL commit: %w", err)
+			}
+		}
+	}
+	for _, ts := range locked {
+		ts.heap.truncate(restartIdentity)
+	}
+	return nil
+}
+
+func (e *engine) LookupByPK(table string, value any) (*Row, error) {
+	ts, err := e.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.mu.RUnlock()
+
+	row, ok := ts.heap.lookupByPK(value)
+	if !ok {
+		return nil, nil
+	}
+	// Return a copy to avoid data races.
+	vals := make([]any, len(row.Values))
+	copy(vals, row.Values)
+	return &Row{ID: row.ID, Values: vals}, nil
+}
+
+// -------------------------------------------------------------------------
+// Helpers
+// -------------------------------------------------------------------------
+
+// validateInsertRows checks NOT NULL, primary key, and unique index
+// constraints for rows about to be inserted, against the heap and against
+// each other. It runs before anything is written to the WAL.
+func validateInsertRows(heap *tableHeap, table string, resolvedRows [][]any) error {
 	// Pre-validate NOT NULL constraints for all rows.
 	for _, col := range heap.def.Columns {
 		if !col.NotNull {
@@ -711,7 +1308,7 @@ func (e *engine) Insert(table string, columns []string, values [][]any) (int64,
 		}
 		for _, fullRow := range resolvedRows {
 			if RowValue(fullRow, col.Ordinal) == nil {
-				return 0, &NotNullViolationError{
+				return &NotNullViolationError{
 					Table:  table,
 					Column: col.Name,
 				}
@@ -726,21 +1323,21 @@ func (e *engine) Insert(table string, columns []string, values [][]any) (int64,
 		for _, fullRow := range resolvedRows {
 			key := RowValue(fullRow, heap.pkCol)
 			if key == nil {
-				return 0, &UniqueViolationError{
+				return &UniqueViolationError{
 					Table:  table,
 					Column: pkColName,
 				}
 			}
-			if seen[key] {
-				return 0, &UniqueViolationError{
+			if seen[mapKey(key)] {
+				return &UniqueViolationError{
 					Table:  table,
 					Column: pkColName,
 					Value:  key,
 				}
 			}
-			seen[key] = true
+			seen[mapKey(key)] = true
 			if _, exists := heap.pkIdx.Get(key); exists {
-				return 0, &UniqueViolationError{
+				return &UniqueViolationError{
 					Table:  table,
 					Column: pkColName,
 					Value:  key,
@@ -757,94 +1354,36 @@ func (e *engine) Insert(table string, columns []string, values [][]any) (int64,
 		}
 		seen := make(map[any]bool, len(resolvedRows))
 		for _, fullRow := range resolvedRows {
-			key := RowValue(fullRow, si.colOrd)
+			key := si.key(fullRow)
 			if key == nil {
 				continue // NULLs don't violate unique constraints
 			}
-			if seen[key] {
-				return 0, &UniqueViolationError{
+			if seen[mapKey(key)] {
+				return &UniqueViolationError{
 					Table:  table,
-					Column: si.def.Column,
+					Column: si.def.ColumnList(),
 					Value:  key,
 					Index:  si.def.Name,
 				}
 			}
-			seen[key] = true
+			seen[mapKey(key)] = true
 			if _, exists := si.unique.Get(key); exists {
-				return 0, &UniqueViolationError{
+				return &UniqueViolationError{
 					Table:  table,
-					Column: si.def.Column,
+					Column: si.def.ColumnList(),
 					Value:  key,
 					Index:  si.def.Name,
 				}
 			}
 		}
 	}
-
-	// Allocate all row IDs, write a single batched WAL entry (one fsync),
-	// then apply to the heap. If the WAL write fails, zero rows are applied.
-	inserts := make([]rowInsert, len(resolvedRows))
-	for i, fullRow := range resolvedRows {
-		inserts[i] = rowInsert{RowID: heap.allocateID(), Values: fullRow}
-	}
-	if err := ts.wal.WriteInsertBatch(table, inserts); err != nil {
-		return 0, fmt.Errorf("WAL: %w", err)
-	}
-	for _, ins := range inserts {
-		heap.insertWithID(ins.RowID, ins.Values)
-	}
-	return int64(len(inserts)), nil
-}
-
-func (e *engine) Scan(table string) (RowIterator, error) {
-	ts, err := e.acquireTableRead(table)
-	if err != nil {
-		return nil, err
-	}
-	defer ts.mu.RUnlock()
-
-	return ts.heap.scan(), nil
+	return nil
 }
 
-func (e *engine) Update(table string, sets map[string]any, filter func(Row) bool) (int64, error) {
-	ts, err := e.acquireTableWrite(table)
-	if err != nil {
-		return 0, err
-	}
-	defer ts.mu.Unlock()
-
-	heap := ts.heap
-
-	var updates []rowUpdate
-	for id, values := range heap.rows {
-		if values == nil {
-			continue
-		}
-		row := Row{ID: int64(id), Values: values}
-		if filter != nil && !filter(row) {
-			continue
-		}
-		// Extend short rows to full ordinal width.
-		newValues := make([]any, heap.def.NextOrdinal)
-		copy(newValues, values)
-		for colName, newVal := range sets {
-			idx := heap.columnIndex(colName)
-			if idx < 0 {
-				return 0, &ColumnNotFoundError{Column: colName, Table: heap.def.Name}
-			}
-			newValues[idx] = newVal
-		}
-		coerced, err := coerceRowValues(&heap.def, newValues)
-		if err != nil {
-			return 0, err
-		}
-		updates = append(updates, rowUpdate{RowID: int64(id), Values: coerced})
-	}
-
-	if len(updates) == 0 {
-		return 0, nil
-	}
-
+// validateUpdateRows checks NOT NULL, primary key, and unique index
+// constraints for the new values of updated rows. Only constraints on
+// columns named in sets are checked, since other values are unchanged.
+func validateUpdateRows(heap *tableHeap, table string, sets map[string]any, updates []rowUpdate) error {
 	// Pre-validate NOT NULL constraints for columns being SET.
 	for _, col := range heap.def.Columns {
 		if !col.NotNull {
@@ -855,7 +1394,7 @@ func (e *engine) Update(table string, sets map[string]any, filter func(Row) bool
 		}
 		for _, u := range updates {
 			if RowValue(u.Values, col.Ordinal) == nil {
-				return 0, &NotNullViolationError{
+				return &NotNullViolationError{
 					Table:  table,
 					Column: col.Name,
 				}
@@ -876,14 +1415,14 @@ func (e *engine) Update(table string, sets map[string]any, filter func(Row) bool
 			for _, u := range updates {
 				newKey := RowValue(u.Values, heap.pkCol)
 				if newKey == nil {
-					return 0, &UniqueViolationError{Table: table, Column: pkColName}
+					return &UniqueViolationError{Table: table, Column: pkColName}
 				}
-				if seen[newKey] {
-					return 0, &UniqueViolationError{Table: table, Column: pkColName, Value: newKey}
+				if seen[mapKey(newKey)] {
+					return &UniqueViolationError{Table: table, Column: pkColName, Value: newKey}
 				}
-				seen[newKey] = true
+				seen[mapKey(newKey)] = true
 				if existingID, found := heap.pkIdx.Get(newKey); found && !updatingIDs[existingID] {
-					return 0, &UniqueViolationError{Table: table, Column: pkColName, Value: newKey}
+					return &UniqueViolationError{Table: table, Column: pkColName, Value: newKey}
 				}
 			}
 		}
@@ -899,90 +1438,207 @@ func (e *engine) Update(table string, sets map[string]any, filter func(Row) bool
 		if si.unique == nil {
 			continue
 		}
-		if _, changing := sets[si.def.Column]; !changing {
+		changing := false
+		for _, name := range si.def.Columns {
+			if _, ok := sets[name]; ok {
+				changing = true
+			}
+		}
+		if !changing {
 			continue
 		}
 		seen := make(map[any]bool, len(updates))
 		for _, u := range updates {
-			newKey := RowValue(u.Values, si.colOrd)
+			newKey := si.key(u.Values)
 			if newKey == nil {
 				continue // NULLs don't violate unique constraints
 			}
-			if seen[newKey] {
-				return 0, &UniqueViolationError{Table: table, Column: si.def.Column, Value: newKey, Index: si.def.Name}
+			if seen[mapKey(newKey)] {
+				return &UniqueViolationError{Table: table, Column: si.def.ColumnList(), Value: newKey, Index: si.def.Name}
 			}
-			seen[newKey] = true
+			seen[mapKey(newKey)] = true
 			if existingID, found := si.unique.Get(newKey); found && !updatingIDs[existingID] {
-				return 0, &UniqueViolationError{Table: table, Column: si.def.Column, Value: newKey, Index: si.def.Name}
+				return &UniqueViolationError{Table: table, Column: si.def.ColumnList(), Value: newKey, Index: si.def.Name}
 			}
 		}
 	}
-
-	if err := ts.wal.WriteUpdate(table, updates); err != nil {
-		return 0, fmt.Errorf("WAL: %w", err)
-	}
-	for _, u := range updates {
-		heap.updateRow(u.RowID, u.Values)
-	}
-	return int64(len(updates)), nil
+	return nil
 }
 
-func (e *engine) Delete(table string, filter func(Row) bool) (int64, error) {
-	ts, err := e.acquireTableWrite(table)
-	if err != nil {
-		return 0, err
+// applySets returns a copy of values, extended to the table's full ordinal
+// width, with the SET assignments applied and coerced to the column types.
+func applySets(heap *tableHeap, values []any, sets map[string]any) ([]any, error) {
+	newValues := make([]any, heap.def.NextOrdinal)
+	copy(newValues, values)
+	for colName, newVal := range sets {
+		idx := heap.columnIndex(colName)
+		if idx < 0 {
+			return nil, &ColumnNotFoundError{Column: colName, Table: heap.def.Name}
+		}
+		newValues[idx] = newVal
 	}
-	defer ts.mu.Unlock()
+	return coerceRowValues(&heap.def, newValues)
+}
 
-	heap := ts.heap
+// uniqueKey is a uniqueness constraint an ON CONFLICT clause can match:
+// the primary key (index nil) or a unique secondary index.
+type uniqueKey struct {
+	key   func(values []any) any // the constraint's key of a row
+	name  string                 // index name; "" for the primary key
+	index index.Index
+}
 
-	var ids []int64
-	for id, values := range heap.rows {
-		if values == nil {
+// conflictKeys returns the constraints matched by oc's conflict target, or
+// every uniqueness constraint of the table when no target is given.
+func conflictKeys(heap *tableHeap, oc OnConflict) ([]uniqueKey, error) {
+	if oc.Column == "" && oc.Sets != nil {
+		return nil, &ConflictTargetError{Table: heap.def.Name}
+	}
+	if oc.Column != "" && heap.columnIndex(oc.Column) < 0 {
+		return nil, &ColumnNotFoundError{Column: oc.Column, Table: heap.def.Name}
+	}
+	var keys []uniqueKey
+	if heap.pkCol >= 0 && (oc.Column == "" || oc.Column == heap.pkColumnName()) {
+		keys = append(keys, uniqueKey{key: heap.pkKey})
+	}
+	for i := range heap.secondaries {
+		si := &heap.secondaries[i]
+		if si.unique == nil {
 			continue
 		}
-		row := Row{ID: int64(id), Values: values}
-		if filter != nil && !filter(row) {
+		// A column target matches single-column indexes only.
+		if oc.Column != "" && (len(si.def.Columns) != 1 || oc.Column != si.def.Columns[0]) {
 			continue
 		}
-		ids = append(ids, int64(id))
+		keys = append(keys, uniqueKey{key: si.key, name: si.def.Name, index: si.unique})
 	}
-
-	if len(ids) == 0 {
-		return 0, nil
+	if oc.Column != "" && len(keys) == 0 {
+		return nil, &ConflictTargetError{Table: heap.def.Name, Column: oc.Column}
 	}
+	return keys, nil
+}
 
-	if err := ts.wal.WriteDelete(table, ids); err != nil {
-		return 0, fmt.Errorf("WAL: %w", err)
+// planUpsert splits resolved VALUES rows into the rows to insert and the
+// IDs of existing rows to update. lookup finds the row currently holding a
+// key; keys claimed by earlier VALUES rows of the same statement count as
+// conflicts too. With DO NOTHING conflicting rows are dropped; with DO
+// UPDATE, hitting the same row twice is an error.
+func planUpsert(table string, rows [][]any, keys []uniqueKey, doUpdate bool,
+	lookup func(k uniqueKey, key any) (int64, bool)) (inserts [][]any, updateIDs []int64, err error) {
+	claimed := make([]map[any]bool, len(keys))
+	for i := range claimed {
+		claimed[i] = make(map[any]bool)
+	}
+	updating := make(map[int64]bool)
+	for _, row := range rows {
+		var conflictID int64
+		conflict, twice := false, false
+		for i, k := range keys {
+			key := k.key(row)
+			if key == nil {
+				continue
+			}
+			if claimed[i][mapKey(key)] {
+				conflict, twice = true, true
+				break
+			}
+			if id, ok := lookup(k, key); ok {
+				conflictID, conflict, twice = id, true, updating[id]
+				break
+			}
+		}
+		switch {
+		case !conflict:
+			inserts = append(inserts, row)
+			for i, k := range keys {
+				if key := k.key(row); key != nil {
+					claimed[i][mapKey(key)] = true
+				}
+			}
+		case !doUpdate:
+			// DO NOTHING
+		case twice:
+			return nil, nil, &RowAffectedTwiceError{Table: table}
+		default:
+			updating[conflictID] = true
+			updateIDs = append(updateIDs, conflictID)
+		}
 	}
-	heap.deleteRows(ids)
-	return int64(len(ids)), nil
+	return inserts, updateIDs, nil
 }
 
-func (e *engine) LookupByPK(table string, value any) (*Row, error) {
-	ts, err := e.acquireTableRead(table)
-	if err != nil {
-		return nil, err
+// checkUpsertKeys rejects an upsert whose DO UPDATE gives an existing row
+// a key that one of the statement's inserted rows also takes. The insert
+// and update validations each only see the heap as it was.
+func checkUpsertKeys(heap *tableHeap, table string, inserts [][]any, updates []rowUpdate) error {
+	if len(inserts) == 0 || len(updates) == 0 {
+		return nil
+	}
+	check := func(keyOf func([]any) any, column, indexName string) error {
+		taken := make(map[any]bool, len(inserts))
+		for _, row := range inserts {
+			if key := keyOf(row); key != nil {
+				taken[mapKey(key)] = true
+			}
+		}
+		for _, u := range updates {
+			if key := keyOf(u.Values); key != nil && taken[mapKey(key)] {
+				return &UniqueViolationError{Table: table, Column: column, Value: key, Index: indexName}
+			}
+		}
+		return nil
 	}
-	defer ts.mu.RUnlock()
+	if heap.pkCol >= 0 {
+		if err := check(heap.pkKey, heap.pkColumnName(), ""); err != nil {
+			return err
+		}
+	}
+	for i := range heap.secondaries {
+		si := &heap.secondaries[i]
+		if si.unique == nil {
+			continue
+		}
+		if err := check(si.key, si.def.ColumnList(), si.def.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	row, ok := ts.heap.lookupByPK(value)
-	if !ok {
-		return nil, nil
+// finishWrite ends a write to the table: it releases the write lock and
+// then waits until the table's WAL entries are durable. Waiting without
+// the lock lets other writers of the table append their entries
+// meanwhile, so one fsync covers all of them. Readers do not see the rows
+// before that fsync ends (see awaitDurable). The wait applies even when
+// the statement wrote nothing or failed, since its outcome may rest on
+// rows of an earlier statement whose fsync is still pending. A failed
+// fsync replaces the result with its error; the WAL then refuses all
+// further use of the table.
+func (ts *tableState) finishWrite(rows *[]Row, err *error) {
+	w := ts.wal // a checkpoint may replace it once the lock is released
+	pos := w.Pos()
+	ts.mu.Unlock()
+	if serr := w.SyncTo(pos); serr != nil {
+		*rows, *err = nil, fmt.Errorf("WAL: %w", serr)
 	}
-	// Return a copy to avoid data races.
-	vals := make([]any, len(row.Values))
-	copy(vals, row.Values)
-	return &Row{ID: row.ID, Values: vals}, nil
 }
 
-// -------------------------------------------------------------------------
-// Helpers
-// -------------------------------------------------------------------------
+// awaitDurable waits, under the table's read lock, until every entry
+// written to the table's WAL is durable, so that a reader never sees rows
+// of a statement whose fsync is still pending. The read lock keeps further
+// writes out meanwhile. Once the WAL has failed, the heap may hold writes
+// that never became durable, and awaitDurable returns the failure.
+func (ts *tableState) awaitDurable() error {
+	if err := ts.wal.SyncTo(ts.wal.Pos()); err != nil {
+		return err
+	}
+	return ts.wal.failure()
+}
 
 // acquireTableWrite looks up the tableState under a brief catalogMu read
 // lock, then acquires the table's write lock. Returns an error if the
-// table doesn't exist or was dropped concurrently.
+// table doesn't exist or was dropped concurrently, or the WALIOError of
+// its WAL if that has failed.
 func (e *engine) acquireTableWrite(name string) (*tableState, error) {
 	e.catalogMu.RLock()
 	ts, err := e.getTableState(name)
@@ -996,11 +1652,17 @@ func (e *engine) acquireTableWrite(name string) (*tableState, error) {
 		ts.mu.Unlock()
 		return nil, &TableNotFoundError{Name: name}
 	}
+	if err := ts.wal.failure(); err != nil {
+		ts.mu.Unlock()
+		return nil, err
+	}
 	return ts, nil
 }
 
 // acquireTableRead looks up the tableState under a brief catalogMu read
-// lock, then acquires the table's read lock.
+// lock, then acquires the table's read lock and waits for pending writes
+// to become durable. Once the table's WAL has failed, the table cannot be
+// read either until the engine is reopened.
 func (e *engine) acquireTableRead(name string) (*tableState, error) {
 	e.catalogMu.RLock()
 	ts, err := e.getTableState(name)
@@ -1014,12 +1676,17 @@ func (e *engine) acquireTableRead(name string) (*tableState, error) {
 		ts.mu.RUnlock()
 		return nil, &TableNotFoundError{Name: name}
 	}
+	if err := ts.awaitDurable(); err != nil {
+		ts.mu.RUnlock()
+		return nil, err
+	}
 	return ts, nil
 }
 
 // resolveInsertRow maps named columns + values to a full row in ordinal
 // order, filling unspecified positions with nil (NULL). When columns is nil
-// the values are mapped positionally via def.Columns[i].Ordinal.
+// the values are mapped positionally via def.Columns[i].Ordinal. Identity
+// columns left NULL take the next value of their sequence.
 func resolveInsertRow(heap *tableHeap, columns []string, values []any) ([]any, error) {
 	def := &heap.def
 
@@ -1032,6 +1699,9 @@ func resolveInsertRow(heap *tableHeap, columns []string, values []any) ([]any, e
 		for i, col := range def.Columns {
 			row[col.Ordinal] = values[i]
 		}
+		if err := fillIdentity(heap, row); err != nil {
+			return nil, err
+		}
 		return coerceRowValues(def, row)
 	}
 
@@ -1046,6 +1716,9 @@ func resolveInsertRow(heap *tableHeap, columns []string, values []any) ([]any, e
 		}
 		row[idx] = values[i]
 	}
+	if err := fillIdentity(heap, row); err != nil {
+		return nil, err
+	}
 	return coerceRowValues(def, row)
 }
 
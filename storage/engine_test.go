@@ -1,13 +1,19 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func tempDir(t *testing.T) string {
@@ -173,1223 +179,3105 @@ func TestEngine_Delete(t *testing.T) {
 	}
 }
 
-func TestEngine_DropTable(t *testing.T) {
+func TestEngine_DeleteRows(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
-	defer eng.Close()
 
-	eng.CreateTable("users", testColumns)
-	if err := eng.DropTable("users"); err != nil {
+	cols := []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+		{Name: "team", DataType: TypeText},
+	}
+	eng.CreateTable("users", cols)
+	eng.CreateIndex("users", IndexDef{Name: "idx_name", Columns: []string{"name"}, Unique: true})
+	eng.CreateIndex("users", IndexDef{Name: "idx_team", Columns: []string{"team"}})
+	eng.Insert("users", nil, [][]any{
+		{int64(1), "alice", "red"},
+		{int64(2), "bob", "red"},
+		{int64(3), "carol", "blue"},
+		{int64(4), "dave", "red"},
+	})
+	byName := map[string]int64{}
+	for _, r := range collectRows(t, must(eng.Scan("users"))) {
+		byName[r.Values[1].(string)] = r.ID
+	}
+
+	// Unknown and repeated IDs are skipped; the filter keeps dave.
+	ids := []int64{byName["alice"], byName["carol"], byName["alice"], byName["dave"], 999}
+	deleted, err := eng.DeleteRows("users", ids, func(r Row) bool { return r.Values[1] != "dave" })
+	if err != nil {
 		t.Fatal(err)
 	}
-	if _, ok := eng.GetTable("users"); ok {
-		t.Error("table still exists after drop")
+	if len(deleted) != 2 || deleted[0].Values[1] != "alice" || deleted[1].Values[1] != "carol" {
+		t.Fatalf("deleted = %v, want alice and carol", deleted)
 	}
-	if _, err := eng.Scan("users"); err == nil {
-		t.Error("scan should fail after drop")
+
+	check := func(phase string) {
+		t.Helper()
+		if n, _ := eng.RowCount("users"); n != 2 {
+			t.Errorf("%s: row count = %d, want 2", phase, n)
+		}
+		for _, pk := range []int64{1, 3} {
+			if row, _ := eng.LookupByPK("users", pk); row != nil {
+				t.Errorf("%s: PK %d still found: %v", phase, pk, row)
+			}
+		}
+		for _, name := range []string{"alice", "carol"} {
+			if rows, _ := eng.LookupByIndex("users", "idx_name", name); len(rows) != 0 {
+				t.Errorf("%s: idx_name still finds %s", phase, name)
+			}
+		}
+		if rows, _ := eng.LookupByIndex("users", "idx_team", "blue"); len(rows) != 0 {
+			t.Errorf("%s: idx_team still finds blue: %v", phase, rows)
+		}
+		if rows, _ := eng.LookupByIndex("users", "idx_team", "red"); len(rows) != 2 {
+			t.Errorf("%s: idx_team red = %d rows, want 2", phase, len(rows))
+		}
 	}
-}
+	check("before reopen")
+	eng.Close()
 
-// -------------------------------------------------------------------------
-// WAL replay — data survives restart
-// -------------------------------------------------------------------------
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	check("after reopen")
+	// The removed keys can be reused.
+	if _, err := eng.Insert("users", nil, [][]any{{int64(1), "alice", "blue"}}); err != nil {
+		t.Fatalf("insert after delete: %v", err)
+	}
+}
 
-func TestEngine_Restart(t *testing.T) {
+func TestEngine_Truncate(t *testing.T) {
 	dir := tempDir(t)
-
-	// First session: create table, insert, update, delete.
 	eng := openEngine(t, dir)
-	eng.CreateTable("users", testColumns)
-	eng.Insert("users", nil, [][]any{
-		{int64(1), "alice", true},
-		{int64(2), "bob", false},
-		{int64(3), "carol", true},
-	})
-	eng.Update("users",
-		map[string]any{"name": "robert"},
-		func(r Row) bool { return r.Values[0] == int64(2) },
-	)
-	eng.Delete("users",
-		func(r Row) bool { return r.Values[0] == int64(3) },
-	)
-	eng.Close()
-
-	// Second session: reopen and verify state.
-	eng2 := openEngine(t, dir)
-	defer eng2.Close()
 
-	def, ok := eng2.GetTable("users")
-	if !ok {
-		t.Fatal("table users not found after restart")
-	}
-	if len(def.Columns) != 3 {
-		t.Fatalf("columns = %d, want 3", len(def.Columns))
+	cols := []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
 	}
+	eng.CreateTable("users", cols)
+	eng.CreateIndex("users", IndexDef{Name: "idx_name", Columns: []string{"name"}, Unique: true})
+	eng.Insert("users", nil, [][]any{{int64(1), "alice"}, {int64(2), "bob"}})
 
-	rows := collectRows(t, must(eng2.Scan("users")))
-	if len(rows) != 2 {
-		t.Fatalf("rows after restart = %d, want 2", len(rows))
+	if err := eng.Truncate([]string{"users"}, false); err != nil {
+		t.Fatal(err)
 	}
-
-	byID := make(map[int64]Row)
-	for _, r := range rows {
-		byID[r.ID] = r
+	if n, _ := eng.RowCount("users"); n != 0 {
+		t.Fatalf("row count = %d, want 0", n)
 	}
-
-	alice := byID[1]
-	if alice.Values[1] != "alice" {
-		t.Errorf("alice name = %v", alice.Values[1])
+	// The PK and unique index are empty, so the old keys can be reused.
+	if _, err := eng.Insert("users", nil, [][]any{{int64(1), "alice"}}); err != nil {
+		t.Fatalf("insert after truncate: %v", err)
 	}
-
-	robert := byID[2]
-	if robert.Values[1] != "robert" {
-		t.Errorf("bob should be renamed to robert, got %v", robert.Values[1])
+	if err := eng.Truncate([]string{"missing"}, false); err == nil {
+		t.Error("expected error truncating a missing table")
 	}
+	eng.Close()
 
-	if _, ok := byID[3]; ok {
-		t.Error("carol should have been deleted")
+	// Replay applies the truncate entry, then the insert that followed it.
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	rows := collectRows(t, must(eng.Scan("users")))
+	if len(rows) != 1 || rows[0].Values[1] != "alice" {
+		t.Fatalf("after restart got %v, want only alice", rows)
+	}
+	if rows, _ := eng.LookupByIndex("users", "idx_name", "bob"); len(rows) != 0 {
+		t.Errorf("index still finds bob after truncate: %v", rows)
 	}
 }
 
-func TestEngine_RestartDroppedTable(t *testing.T) {
+func TestEngine_Vacuum(t *testing.T) {
 	dir := tempDir(t)
-
 	eng := openEngine(t, dir)
-	eng.CreateTable("temp", testColumns)
-	eng.Insert("temp", nil, [][]any{{int64(1), "x", false}})
-	eng.DropTable("temp")
-	eng.Close()
 
-	eng2 := openEngine(t, dir)
-	defer eng2.Close()
+	eng.CreateTable("users", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	})
+	eng.CreateIndex("users", IndexDef{Name: "idx_name", Columns: []string{"name"}, Unique: true})
+	var rows [][]any
+	for i := int64(1); i <= 10; i++ {
+		rows = append(rows, []any{i, fmt.Sprintf("u%d", i)})
+	}
+	must(eng.Insert("users", nil, rows))
+	must(eng.Delete("users", func(r Row) bool { return r.Values[0].(int64)%3 != 0 }))
 
-	if _, ok := eng2.GetTable("temp"); ok {
-		t.Error("dropped table should not exist after restart")
+	n, err := eng.Vacuum("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 7 {
+		t.Errorf("reclaimed %d slots, want 7", n)
+	}
+	if n := must(eng.Vacuum("users")); n != 0 {
+		t.Errorf("second vacuum reclaimed %d slots, want 0", n)
+	}
+	if _, err := eng.Vacuum("missing"); err == nil {
+		t.Error("expected error vacuuming a missing table")
 	}
-}
 
-// -------------------------------------------------------------------------
-// Error cases
-// -------------------------------------------------------------------------
+	// The live rows kept their order and moved to IDs 1..3; both indexes
+	// point at the new IDs.
+	check := func(eng Engine) {
+		t.Helper()
+		rows := collectRows(t, must(eng.Scan("users")))
+		var got []string
+		for _, r := range rows {
+			got = append(got, fmt.Sprintf("%d:%v", r.ID, r.Values[1]))
+		}
+		if fmt.Sprint(got) != "[2:u6 3:u9 4:u12]" {
+			t.Errorf("rows = %v", got)
+		}
+		if r, _ := eng.LookupByPK("users", int64(9)); r == nil || r.ID != 3 {
+			t.Errorf("PK lookup of 9 = %v, want row 3", r)
+		}
+		if rs, _ := eng.LookupByIndex("users", "idx_name", "u6"); len(rs) != 1 || rs[0].ID != 2 {
+			t.Errorf("index lookup of u6 = %v, want row 2", rs)
+		}
+	}
+	// New rows continue after the compacted ones, and writes refer to
+	// rows by their new IDs.
+	must(eng.Insert("users", nil, [][]any{{int64(12), "u12"}}))
+	must(eng.Delete("users", func(r Row) bool { return r.Values[0] == int64(3) }))
+	check(eng)
+	eng.Close()
 
-func TestEngine_Errors(t *testing.T) {
+	// Replay renumbers the rows at the same point, so the insert logged
+	// after the vacuum lands where it did.
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	check(eng)
+}
+
+func TestEngine_IntegerColumns(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
-	defer eng.Close()
 
-	// Table doesn't exist.
-	if _, err := eng.Scan("nope"); err == nil {
-		t.Error("scan non-existent table should fail")
+	// INTEGER columns are kept unboxed with a NULL bitmap; NULL and 0 must
+	// stay apart, and so must every int64.
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "n", DataType: TypeInteger},
+		{Name: "s", DataType: TypeText},
+		{Name: "old", DataType: TypeInteger},
+	})
+	must(eng.Insert("t", nil, [][]any{
+		{int64(1), int64(0), "a", int64(7)},
+		{int64(2), nil, nil, int64(7)},
+		{int64(3), int64(-1 << 63), "", nil},
+		{int64(4), int64(1<<63 - 1), "d", int64(7)},
+	}))
+	must(eng.Delete("t", func(r Row) bool { return r.Values[0] == int64(3) }))
+	must(eng.Update("t", map[string]any{"n": nil}, func(r Row) bool { return r.Values[0] == int64(1) }))
+	must(eng.Insert("t", nil, [][]any{{int64(5), int64(-1 << 63), nil, nil}})) // reuses row 3's slot
+	if err := eng.DropColumn("t", "old"); err != nil {
+		t.Fatal(err)
 	}
-	if _, err := eng.Insert("nope", nil, [][]any{{int64(1)}}); err == nil {
-		t.Error("insert non-existent table should fail")
+	if err := eng.AddColumn("t", ColumnDef{Name: "k", DataType: TypeInteger, Fill: int64(300)}); err != nil {
+		t.Fatal(err)
 	}
-	if err := eng.DropTable("nope"); err == nil {
-		t.Error("drop non-existent table should fail")
+	if err := eng.AddColumn("t", ColumnDef{Name: "u", DataType: TypeText, Fill: "x"}); err != nil {
+		t.Fatal(err)
 	}
+	must(eng.Insert("t", nil, [][]any{{int64(6), int64(6), "f", nil, nil}}))
 
-	// Duplicate table.
-	eng.CreateTable("t", testColumns)
-	if err := eng.CreateTable("t", testColumns); err == nil {
-		t.Error("duplicate create should fail")
+	check := func(eng Engine) {
+		t.Helper()
+		def, _ := eng.GetTable("t")
+		var got []string
+		for _, r := range collectRows(t, must(eng.Scan("t"))) {
+			var vals []string
+			for _, col := range def.Columns {
+				vals = append(vals, fmt.Sprintf("%#v", RowValue(r.Values, col.Ordinal)))
+			}
+			got = append(got, strings.Join(vals, " "))
+		}
+		want := []string{
+			`1 <nil> "a" 300 "x"`,
+			`2 <nil> <nil> 300 "x"`,
+			`5 -9223372036854775808 <nil> 300 "x"`,
+			`4 9223372036854775807 "d" 300 "x"`,
+			`6 6 "f" <nil> <nil>`,
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("rows =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+		if r, _ := eng.LookupByPK("t", int64(2)); r == nil || RowValue(r.Values, 1) != nil {
+			t.Errorf("PK lookup of 2 = %v", r)
+		}
 	}
+	check(eng)
+	eng.Close()
 
-	// Wrong value count.
-	if _, err := eng.Insert("t", nil, [][]any{{int64(1)}}); err == nil {
-		t.Error("insert with wrong column count should fail")
+	// Replay rebuilds the same rows, and so does a checkpoint of them.
+	eng = openEngine(t, dir)
+	check(eng)
+	if err := eng.Checkpoint("t"); err != nil {
+		t.Fatal(err)
 	}
+	eng.Close()
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	check(eng)
 }
 
-// -------------------------------------------------------------------------
-// Value encoding round-trip
-// -------------------------------------------------------------------------
+func TestEngine_DictionaryColumns(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
 
-func TestValueEncoding(t *testing.T) {
-	values := []any{int64(42), "hello world", true, nil, false, int64(-1), ""}
-	buf := encodeValues(nil, values)
-	decoded, rest, err := decodeValues(buf)
-	if err != nil {
+	// A STORAGE DICTIONARY column reads like any TEXT column, through NULL,
+	// updates, deletes, slot reuse and ADD COLUMN.
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "status", DataType: TypeText, Dictionary: true},
+		{Name: "note", DataType: TypeText},
+	})
+	must(eng.Insert("t", nil, [][]any{
+		{int64(1), "open", "a"},
+		{int64(2), nil, "b"},
+		{int64(3), "closed", nil},
+		{int64(4), "open", ""},
+	}))
+	must(eng.Delete("t", func(r Row) bool { return r.Values[0] == int64(3) }))
+	must(eng.Update("t", map[string]any{"status": "closed"}, func(r Row) bool { return r.Values[0] == int64(4) }))
+	must(eng.Insert("t", nil, [][]any{{int64(5), "", "e"}})) // reuses row 3's slot
+	if err := eng.AddColumn("t", ColumnDef{Name: "kind", DataType: TypeText, Dictionary: true, Fill: "x"}); err != nil {
 		t.Fatal(err)
 	}
-	if len(rest) != 0 {
-		t.Fatalf("leftover bytes: %d", len(rest))
+	must(eng.Insert("t", nil, [][]any{{int64(6), "open", nil, "y"}}))
+
+	check := func(eng Engine) {
+		t.Helper()
+		var got []string
+		for _, r := range collectRows(t, must(eng.Scan("t"))) {
+			got = append(got, fmt.Sprintf("%#v", r.Values))
+		}
+		want := []string{
+			`[]interface {}{1, "open", "a", "x"}`,
+			`[]interface {}{2, interface {}(nil), "b", "x"}`,
+			`[]interface {}{5, "", "e", "x"}`,
+			`[]interface {}{4, "closed", "", "x"}`,
+			`[]interface {}{6, "open", interface {}(nil), "y"}`,
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("rows =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+		var ids []any
+		for _, r := range collectRows(t, must(eng.ScanEqual("t", "status", "open"))) {
+			ids = append(ids, r.Values[0])
+		}
+		if !slices.Equal(ids, []any{int64(1), int64(6)}) {
+			t.Errorf("ScanEqual(status, open) = %v, want [1 6]", ids)
+		}
+		if rows := collectRows(t, must(eng.ScanEqual("t", "status", "pending"))); len(rows) != 0 {
+			t.Errorf("ScanEqual(status, pending) = %v, want none", rows)
+		}
+		if def, _ := eng.GetTable("t"); !def.Columns[1].Dictionary || def.Columns[2].Dictionary || !def.Columns[3].Dictionary {
+			t.Errorf("Dictionary flags = %v", def.Columns)
+		}
 	}
-	if len(decoded) != len(values) {
-		t.Fatalf("decoded %d values, want %d", len(decoded), len(values))
+	check(eng)
+	eng.Close()
+
+	// Replay rebuilds the same rows, and so does a checkpoint of them.
+	eng = openEngine(t, dir)
+	check(eng)
+	if err := eng.Checkpoint("t"); err != nil {
+		t.Fatal(err)
 	}
-	for i, want := range values {
-		got := decoded[i]
-		if got != want {
-			t.Errorf("value[%d] = %v (%T), want %v (%T)", i, got, got, want, want)
+	eng.Close()
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	check(eng)
+}
+
+func TestEngine_ScanDictionarySnapshot(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
+	defer eng.Close()
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "status", DataType: TypeText, Dictionary: true},
+	})
+	must(eng.Insert("t", nil, [][]any{{int64(1), "open"}, {int64(2), "closed"}, {int64(3), "open"}}))
+
+	// Scans opened before a write read the rows as they were, while the
+	// write adds values to the dictionaries they share.
+	scan := must(eng.Scan("t"))
+	equal := must(eng.ScanEqual("t", "status", "open"))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 100 {
+			must(eng.Insert("t", nil, [][]any{{int64(10 + i), fmt.Sprintf("s%d", i)}}))
 		}
+		must(eng.Update("t", map[string]any{"status": "gone"}, func(r Row) bool { return r.Values[0] == int64(1) }))
+	}()
+	var got []string
+	for _, r := range collectRows(t, scan) {
+		got = append(got, fmt.Sprint(r.Values))
+	}
+	for _, r := range collectRows(t, equal) {
+		got = append(got, fmt.Sprint(r.Values))
+	}
+	<-done
+	want := []string{"[1 open]", "[2 closed]", "[3 open]", "[1 open]", "[3 open]"}
+	if !slices.Equal(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
 	}
 }
 
-// -------------------------------------------------------------------------
-// Typed errors
-// -------------------------------------------------------------------------
-
-func TestEngine_TypedErrors(t *testing.T) {
+func TestEngine_VacuumDictionary(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
-	defer eng.Close()
 
-	// TableNotFoundError from Scan.
-	_, err := eng.Scan("nope")
-	if err == nil {
-		t.Fatal("expected error")
+	// VACUUM dictionary-encodes a TEXT column with few distinct values,
+	// which shrinks the rows, but not one whose values are all different.
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "country", DataType: TypeText},
+		{Name: "email", DataType: TypeText},
+	})
+	countries := []string{"de", "fr", "it", "nl"}
+	var rows [][]any
+	for i := range 1000 {
+		// Build each value anew, as rows read from the wire would be.
+		country := strings.Clone(countries[i%len(countries)])
+		rows = append(rows, []any{int64(i), country, fmt.Sprintf("user%d@example.com", i)})
+	}
+	must(eng.Insert("t", nil, rows))
+	before := eng.MemoryUsage()[0].RowBytes
+	if _, err := eng.Vacuum("t"); err != nil {
+		t.Fatal(err)
 	}
-	var tnf *TableNotFoundError
-	if !errors.As(err, &tnf) {
-		t.Errorf("Scan: expected TableNotFoundError, got %T: %v", err, err)
+	after := eng.MemoryUsage()[0].RowBytes
+	if after >= before {
+		t.Errorf("RowBytes = %d after VACUUM, want less than %d", after, before)
 	}
 
-	// TableExistsError from duplicate CreateTable.
-	eng.CreateTable("t", testColumns)
-	err = eng.CreateTable("t", testColumns)
-	if err == nil {
-		t.Fatal("expected error")
+	check := func(eng Engine) {
+		t.Helper()
+		rows := collectRows(t, must(eng.ScanEqual("t", "country", "it")))
+		if len(rows) != 250 {
+			t.Fatalf("ScanEqual(country, it) = %d rows, want 250", len(rows))
+		}
+		for _, r := range rows {
+			if r.Values[1] != "it" || r.Values[0].(int64)%4 != 2 {
+				t.Fatalf("ScanEqual(country, it) returned %v", r.Values)
+			}
+		}
+		r := collectRows(t, must(eng.ScanEqual("t", "email", "user7@example.com")))
+		if len(r) != 1 || r[0].Values[0] != int64(7) {
+			t.Errorf("ScanEqual(email) = %v", r)
+		}
+		if _, err := eng.ScanEqual("t", "nope", "x"); err == nil {
+			t.Error("ScanEqual of a missing column succeeded")
+		}
 	}
-	var te *TableExistsError
-	if !errors.As(err, &te) {
-		t.Errorf("CreateTable: expected TableExistsError, got %T: %v", err, err)
+	check(eng)
+	must(eng.Update("t", map[string]any{"country": "es"}, func(r Row) bool { return r.Values[0] == int64(2) }))
+	if n := len(collectRows(t, must(eng.ScanEqual("t", "country", "it")))); n != 249 {
+		t.Errorf("ScanEqual(country, it) after UPDATE = %d rows, want 249", n)
+	}
+	if n := len(collectRows(t, must(eng.ScanEqual("t", "country", "es")))); n != 1 {
+		t.Errorf("ScanEqual(country, es) = %d rows, want 1", n)
 	}
+	must(eng.Update("t", map[string]any{"country": "it"}, func(r Row) bool { return r.Values[0] == int64(2) }))
 
-	// ValueCountError from wrong column count.
-	_, err = eng.Insert("t", nil, [][]any{{int64(1)}})
-	if err == nil {
-		t.Fatal("expected error")
+	// A checkpoint drops the VACUUM entry; loading the table encodes the
+	// column again.
+	if err := eng.Checkpoint("t"); err != nil {
+		t.Fatal(err)
 	}
-	var vc *ValueCountError
-	if !errors.As(err, &vc) {
-		t.Errorf("Insert: expected ValueCountError, got %T: %v", err, err)
+	eng.Close()
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	check(eng)
+	if got := eng.MemoryUsage()[0].RowBytes; got >= before {
+		t.Errorf("RowBytes = %d after reopening, want less than %d", got, before)
 	}
 }
 
-// -------------------------------------------------------------------------
-// Concurrency
-// -------------------------------------------------------------------------
-
-func TestEngine_ConcurrentReadsAndWrites(t *testing.T) {
+func TestEngine_TruncateMany(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
-	defer eng.Close()
-
-	eng.CreateTable("t", []ColumnDef{
-		{Name: "id", DataType: TypeInteger},
-		{Name: "val", DataType: TypeText},
-	})
 
-	const numWriters = 4
-	const numReaders = 8
-	const opsPerWorker = 50
-
-	// Insert some seed data so readers always have something to scan.
-	eng.Insert("t", nil, [][]any{
-		{int64(0), "seed"},
-	})
-
-	errs := make(chan error, numWriters+numReaders)
-	var wg sync.WaitGroup
-
-	// Writers: insert rows concurrently.
-	for w := 0; w < numWriters; w++ {
-		wg.Add(1)
-		go func(writerID int) {
-			defer wg.Done()
-			for i := 0; i < opsPerWorker; i++ {
-				_, err := eng.Insert("t", nil, [][]any{
-					{int64(writerID*1000 + i), fmt.Sprintf("w%d-%d", writerID, i)},
-				})
-				if err != nil {
-					errs <- fmt.Errorf("writer %d, op %d: %w", writerID, i, err)
-					return
-				}
-			}
-		}(w)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := eng.CreateTable(name, []ColumnDef{{Name: "id", DataType: TypeInteger, PrimaryKey: true}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := eng.Insert(name, nil, [][]any{{int64(1)}, {int64(2)}}); err != nil {
+			t.Fatal(err)
+		}
 	}
-
-	// Readers: scan the table concurrently.
-	for r := 0; r < numReaders; r++ {
-		wg.Add(1)
-		go func(readerID int) {
-			defer wg.Done()
-			for i := 0; i < opsPerWorker; i++ {
-				it, err := eng.Scan("t")
-				if err != nil {
-					errs <- fmt.Errorf("reader %d, op %d: scan: %w", readerID, i, err)
-					return
-				}
-				count := 0
-				for {
-					_, ok := it.Next()
-					if !ok {
-						break
-					}
-					count++
-				}
-				it.Close()
-				if count == 0 {
-					errs <- fmt.Errorf("reader %d, op %d: got 0 rows", readerID, i)
-					return
-				}
+	counts := func(when string, want ...int64) {
+		t.Helper()
+		for i, name := range []string{"a", "b", "c"} {
+			if n, _ := eng.RowCount(name); n != want[i] {
+				t.Errorf("%s: %s has %d rows, want %d", when, name, n, want[i])
 			}
-		}(r)
+		}
 	}
 
-	wg.Wait()
-	close(errs)
-
-	for err := range errs {
-		t.Error(err)
+	// A missing table, or a failing WAL, leaves every table as it was.
+	if err := eng.Truncate([]string{"a", "missing"}, false); err == nil {
+		t.Error("expected error truncating a missing table")
+	}
+	counts("after missing table", 2, 2, 2)
+	eng.(*engine).tableStates["c"].wal.syncFile = failingSync
+	var ioErr *WALIOError
+	if err := eng.Truncate([]string{"c", "a"}, false); !errors.As(err, &ioErr) {
+		t.Fatalf("failing WAL: expected WALIOError, got %v", err)
 	}
+	counts("after failing WAL", 2, 2, 2)
 
-	// Verify final row count: 1 seed + numWriters * opsPerWorker
-	expectedRows := 1 + numWriters*opsPerWorker
-	it, err := eng.Scan("t")
-	if err != nil {
+	// Names may repeat and come in any order.
+	if err := eng.Truncate([]string{"b", "a", "b"}, false); err != nil {
 		t.Fatal(err)
 	}
-	rows := collectRows(t, it)
-	if len(rows) != expectedRows {
-		t.Errorf("final rows = %d, want %d", len(rows), expectedRows)
-	}
-}
-
-// -------------------------------------------------------------------------
-// Primary Key
-// -------------------------------------------------------------------------
+	counts("after truncate", 0, 0, 2)
+	eng.(*engine).tableStates["c"].wal.syncFile = nil
+	eng.Close()
 
-var pkColumns = []ColumnDef{
-	{Name: "id", DataType: TypeInteger, PrimaryKey: true},
-	{Name: "name", DataType: TypeText},
+	// Replay applies the committed group; c's failed one is discarded.
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	counts("after restart", 0, 0, 2)
 }
 
-func TestEngine_PrimaryKey_Insert(t *testing.T) {
+func TestEngine_LoadCSV(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
-	defer eng.Close()
 
-	eng.CreateTable("users", pkColumns)
-	n, err := eng.Insert("users", nil, [][]any{
-		{int64(1), "alice"},
-		{int64(2), "bob"},
-	})
+	if err := eng.CreateTable("items", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText, NotNull: true},
+		{Name: "price", DataType: TypeFloat},
+		{Name: "at", DataType: TypeTimestamp},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fields are coerced to their column's type; an empty field is NULL.
+	n, err := eng.LoadCSV("items", strings.NewReader("1,apple,1.5,2024-01-02 03:04:05\n2,\"pear, ripe\",,\n"), CSVOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
 	if n != 2 {
-		t.Fatalf("inserted %d, want 2", n)
+		t.Errorf("loaded %d rows, want 2", n)
+	}
+	row := must(eng.LookupByPK("items", int64(1)))
+	if row.Values[2] != 1.5 || row.Values[3] != time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) {
+		t.Errorf("row 1 = %v", row.Values)
+	}
+	row = must(eng.LookupByPK("items", int64(2)))
+	if row.Values[1] != "pear, ripe" || row.Values[2] != nil || row.Values[3] != nil {
+		t.Errorf("row 2 = %v", row.Values)
+	}
+
+	// A header names the columns; the delimiter and NULL marker are set.
+	n, err = eng.LoadCSV("items", strings.NewReader("name;id\nplum;3\n;4\n"), CSVOptions{Delimiter: ';', Header: true, Null: "NA"})
+	if err != nil || n != 2 {
+		t.Fatalf("header: got %d, %v", n, err)
+	}
+	if row := must(eng.LookupByPK("items", int64(4))); row.Values[1] != "" || row.Values[2] != nil {
+		t.Errorf("row 4 = %v", row.Values)
+	}
+
+	// A bad row stores none of the rows.
+	for _, tc := range []struct {
+		name, data string
+		check      func(error) bool
+	}{
+		{"too few fields", "10,a,1,\n11,b\n", func(err error) bool {
+			var e *ValueCountError
+			return errors.As(err, &e) && strings.HasPrefix(err.Error(), "line 2:")
+		}},
+		{"bad value", "10,a,1,\n11,b,cheap,\n", func(err error) bool {
+			var e *InvalidValueError
+			return errors.As(err, &e) && strings.HasPrefix(err.Error(), "line 2:")
+		}},
+		{"duplicate key", "10,a,1,\n1,b,2,\n", isUniqueViolation},
+		{"null in NOT NULL", "10,,1,\n", func(err error) bool {
+			var e *NotNullViolationError
+			return errors.As(err, &e)
+		}},
+		{"unterminated quote", "10,\"a,1,\n", func(err error) bool { return err != nil }},
+	} {
+		if _, err := eng.LoadCSV("items", strings.NewReader(tc.data), CSVOptions{}); !tc.check(err) {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+	}
+	for _, header := range []string{"id,nope\n", "id,name,id\n"} {
+		if _, err := eng.LoadCSV("items", strings.NewReader(header), CSVOptions{Header: true}); err == nil {
+			t.Errorf("header %q: expected error", header)
+		}
+	}
+	if _, err := eng.LoadCSV("missing", strings.NewReader(""), CSVOptions{}); err == nil {
+		t.Error("expected error for a missing table")
+	}
+	if n, _ := eng.RowCount("items"); n != 4 {
+		t.Errorf("after failed loads: %d rows, want 4", n)
 	}
-}
-
-func TestEngine_PrimaryKey_DuplicateInsert(t *testing.T) {
-	dir := tempDir(t)
-	eng := openEngine(t, dir)
-	defer eng.Close()
-
-	eng.CreateTable("users", pkColumns)
-	eng.Insert("users", nil, [][]any{{int64(1), "alice"}})
 
-	_, err := eng.Insert("users", nil, [][]any{{int64(1), "bob"}})
-	if err == nil {
-		t.Fatal("expected error on duplicate PK")
+	// Many rows go into several WAL entries with one fsync.
+	var data strings.Builder
+	for i := 100; i < 100+2*csvBatchRows+1; i++ {
+		fmt.Fprintf(&data, "%d,item%d,%d,\n", i, i, i)
 	}
-	var uv *UniqueViolationError
-	if !errors.As(err, &uv) {
-		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
+	syncs := 0
+	eng.(*engine).tableStates["items"].wal.syncFile = func(f *os.File) error {
+		syncs++
+		return f.Sync()
+	}
+	if n, err := eng.LoadCSV("items", strings.NewReader(data.String()), CSVOptions{}); err != nil || n != 2*csvBatchRows+1 {
+		t.Fatalf("bulk load: got %d, %v", n, err)
+	}
+	if syncs != 1 {
+		t.Errorf("bulk load: %d fsyncs, want 1", syncs)
+	}
+	eng.(*engine).tableStates["items"].wal.syncFile = nil
+	eng.Close()
+
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	if n, _ := eng.RowCount("items"); n != 4+2*csvBatchRows+1 {
+		t.Errorf("after restart: %d rows, want %d", n, 4+2*csvBatchRows+1)
 	}
 }
 
-func TestEngine_PrimaryKey_DuplicateInBatch(t *testing.T) {
+func TestEngine_Returning(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
 	defer eng.Close()
 
-	eng.CreateTable("users", pkColumns)
-	_, err := eng.Insert("users", nil, [][]any{
-		{int64(1), "alice"},
-		{int64(1), "bob"}, // duplicate within same batch
+	eng.CreateTable("events", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "at", DataType: TypeTimestamp},
 	})
-	if err == nil {
-		t.Fatal("expected error on duplicate PK within batch")
+
+	// Inserted rows come back as stored, after type coercion.
+	rows, err := eng.InsertReturning("events", nil, [][]any{{int64(1), "2024-01-02 03:04:05"}, {int64(2), nil}})
+	if err != nil {
+		t.Fatal(err)
 	}
-	var uv *UniqueViolationError
-	if !errors.As(err, &uv) {
-		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
+	if len(rows) != 2 {
+		t.Fatalf("inserted %d rows, want 2", len(rows))
+	}
+	if _, ok := rows[0].Values[1].(time.Time); !ok {
+		t.Errorf("inserted at = %T, want time.Time", rows[0].Values[1])
 	}
-}
 
-func TestEngine_PrimaryKey_NullPK(t *testing.T) {
-	dir := tempDir(t)
-	eng := openEngine(t, dir)
-	defer eng.Close()
+	// Updated rows carry the new values.
+	rows, err = eng.UpdateReturning("events", map[string]any{"at": "2025-06-07 00:00:00"}, func(r Row) bool {
+		return r.Values[0] == int64(2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Values[1] == nil {
+		t.Fatalf("updated rows = %v, want row 2 with a timestamp", rows)
+	}
 
-	eng.CreateTable("users", pkColumns)
-	_, err := eng.Insert("users", nil, [][]any{{nil, "alice"}})
-	if err == nil {
-		t.Fatal("expected error on NULL PK")
+	// Deleted rows carry the values they had before removal.
+	rows, err = eng.DeleteReturning("events", nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-	var uv *UniqueViolationError
-	if !errors.As(err, &uv) {
-		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
+	if len(rows) != 2 {
+		t.Fatalf("deleted %d rows, want 2", len(rows))
+	}
+	if n, _ := eng.RowCount("events"); n != 0 {
+		t.Errorf("row count = %d, want 0", n)
+	}
+
+	// No match returns no rows and no error.
+	rows, err = eng.DeleteReturning("events", nil)
+	if err != nil || len(rows) != 0 {
+		t.Errorf("delete on empty table = %v, %v", rows, err)
 	}
 }
 
-func TestEngine_PrimaryKey_DeleteAndReinsert(t *testing.T) {
+func TestEngine_Upsert(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
-	defer eng.Close()
 
-	eng.CreateTable("users", pkColumns)
+	eng.CreateTable("users", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	})
 	eng.Insert("users", nil, [][]any{{int64(1), "alice"}})
 
-	eng.Delete("users", func(r Row) bool { return r.Values[0] == int64(1) })
-
-	// Should be able to reinsert same PK.
-	_, err := eng.Insert("users", nil, [][]any{{int64(1), "bob"}})
+	// DO NOTHING skips the conflicting row and inserts the rest.
+	rows, err := eng.Upsert("users", nil, [][]any{{int64(1), "x"}, {int64(2), "bob"}}, OnConflict{})
 	if err != nil {
-		t.Fatalf("reinsert after delete failed: %v", err)
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Values[1] != "bob" {
+		t.Fatalf("do nothing = %v, want only bob", rows)
 	}
-}
-
-func TestEngine_PrimaryKey_UpdateNonPK(t *testing.T) {
-	dir := tempDir(t)
-	eng := openEngine(t, dir)
-	defer eng.Close()
-
-	eng.CreateTable("users", pkColumns)
-	eng.Insert("users", nil, [][]any{{int64(1), "alice"}})
 
-	// Update non-PK column should succeed.
-	n, err := eng.Update("users",
-		map[string]any{"name": "alicia"},
-		func(r Row) bool { return r.Values[0] == int64(1) },
-	)
+	// DO UPDATE inserts new keys and updates existing ones, which come last.
+	oc := OnConflict{Column: "id", Sets: map[string]any{"name": "dup"}}
+	rows, err = eng.Upsert("users", nil, [][]any{{int64(1), "a"}, {int64(3), "carol"}}, oc)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if n != 1 {
-		t.Fatalf("updated %d, want 1", n)
+	if len(rows) != 2 || rows[0].Values[1] != "carol" || rows[1].Values[1] != "dup" {
+		t.Fatalf("do update = %v, want carol then dup", rows)
 	}
-}
 
-func TestEngine_PrimaryKey_UpdatePKToDuplicate(t *testing.T) {
-	dir := tempDir(t)
-	eng := openEngine(t, dir)
-	defer eng.Close()
+	// Two VALUES rows hitting the same row are rejected.
+	_, err = eng.Upsert("users", nil, [][]any{{int64(2), "a"}, {int64(2), "b"}}, oc)
+	var twice *RowAffectedTwiceError
+	if !errors.As(err, &twice) {
+		t.Errorf("same key twice: expected RowAffectedTwiceError, got %v", err)
+	}
 
-	eng.CreateTable("users", pkColumns)
-	eng.Insert("users", nil, [][]any{
-		{int64(1), "alice"},
-		{int64(2), "bob"},
-	})
+	// The conflict target must be the primary key or a unique index.
+	_, err = eng.Upsert("users", nil, [][]any{{int64(4), "d"}}, OnConflict{Column: "name", Sets: oc.Sets})
+	var target *ConflictTargetError
+	if !errors.As(err, &target) {
+		t.Errorf("non-unique target: expected ConflictTargetError, got %v", err)
+	}
 
-	_, err := eng.Update("users",
-		map[string]any{"id": int64(1)},
-		func(r Row) bool { return r.Values[0] == int64(2) },
-	)
-	if err == nil {
-		t.Fatal("expected error on PK update to duplicate value")
+	// The insert-and-update group survives a restart.
+	eng.Close()
+	eng = openEngine(t, dir)
+	defer eng.Close()
+
+	row, err := eng.LookupByPK("users", int64(1))
+	if err != nil || row == nil || row.Values[1] != "dup" {
+		t.Fatalf("after restart id 1 = %v, %v, want dup", row, err)
 	}
-	var uv *UniqueViolationError
-	if !errors.As(err, &uv) {
-		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
+	if n, _ := eng.RowCount("users"); n != 3 {
+		t.Errorf("after restart row count = %d, want 3", n)
 	}
 }
 
-func TestEngine_PrimaryKey_LookupByPK(t *testing.T) {
+func TestEngine_Analyze(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
 	defer eng.Close()
 
-	eng.CreateTable("users", pkColumns)
-	eng.Insert("users", nil, [][]any{
-		{int64(1), "alice"},
-		{int64(2), "bob"},
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "kind", DataType: TypeText},
+		{Name: "note", DataType: TypeText},
 	})
+	eng.CreateIndex("t", IndexDef{Name: "idx_kind", Columns: []string{"kind"}})
+	var rows [][]any
+	for i := int64(1); i <= 300; i++ {
+		kind := any("hot")
+		switch {
+		case i%3 == 0:
+			kind = fmt.Sprintf("k%03d", i)
+		case i%10 == 1:
+			kind = nil
+		}
+		rows = append(rows, []any{i, kind, "x"})
+	}
+	must(eng.Insert("t", nil, rows))
 
-	row, err := eng.LookupByPK("users", int64(2))
-	if err != nil {
+	if eng.Stats("t") != nil {
+		t.Fatal("stats before ANALYZE, want nil")
+	}
+	if err := eng.Analyze("t"); err != nil {
 		t.Fatal(err)
 	}
-	if row == nil {
-		t.Fatal("expected row, got nil")
+	stats := eng.Stats("t")
+	if stats == nil || stats.RowCount != 300 {
+		t.Fatalf("stats = %+v, want 300 rows", stats)
 	}
-	if row.Values[1] != "bob" {
-		t.Errorf("name = %v, want bob", row.Values[1])
+	if stats.Columns["note"] != nil {
+		t.Error("unindexed column note has statistics")
+	}
+	if cs := stats.Columns["id"]; cs == nil || cs.Distinct != 300 || len(cs.MostCommon) != 0 {
+		t.Errorf("id stats = %+v, want 300 distinct values and no common ones", cs)
 	}
 
-	// Non-existent key.
-	row, err = eng.LookupByPK("users", int64(99))
-	if err != nil {
-		t.Fatal(err)
+	kind := stats.Columns["kind"]
+	if kind == nil || kind.NullCount != 20 || len(kind.MostCommon) != 1 || kind.MostCommon[0].Value != "hot" {
+		t.Fatalf("kind stats = %+v, want 20 NULLs and hot as the only common value", kind)
 	}
-	if row != nil {
-		t.Errorf("expected nil for missing key, got %v", row)
+	if f := kind.EqualFraction("hot"); f < 0.59 || f > 0.61 {
+		t.Errorf("EqualFraction(hot) = %v, want 180/300", f)
+	}
+	if f := kind.EqualFraction("k003"); f < 0.003 || f > 0.004 {
+		t.Errorf("EqualFraction(k003) = %v, want 1/300", f)
+	}
+	if f := kind.EqualFraction("zzz"); f != 0 {
+		t.Errorf("EqualFraction beyond the histogram = %v, want 0", f)
+	}
+	if f := stats.Columns["id"].RangeFraction(int64(1), int64(150)); f < 0.45 || f > 0.55 {
+		t.Errorf("RangeFraction(1, 150) = %v, want about 0.5", f)
 	}
 }
 
-func TestEngine_PrimaryKey_LookupByPK_NoPK(t *testing.T) {
+func TestEngine_DropTable(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
 	defer eng.Close()
 
-	eng.CreateTable("users", testColumns) // no PK
-	eng.Insert("users", nil, [][]any{{int64(1), "alice", true}})
-
-	row, err := eng.LookupByPK("users", int64(1))
-	if err != nil {
+	eng.CreateTable("users", testColumns)
+	if err := eng.DropTable("users"); err != nil {
 		t.Fatal(err)
 	}
-	if row != nil {
-		t.Error("expected nil for table without PK")
+	if _, ok := eng.GetTable("users"); ok {
+		t.Error("table still exists after drop")
+	}
+	if _, err := eng.Scan("users"); err == nil {
+		t.Error("scan should fail after drop")
 	}
 }
 
-func TestEngine_PrimaryKey_Restart(t *testing.T) {
+// -------------------------------------------------------------------------
+// WAL replay — data survives restart
+// -------------------------------------------------------------------------
+
+func TestEngine_Restart(t *testing.T) {
 	dir := tempDir(t)
 
-	// First session.
+	// First session: create table, insert, update, delete.
 	eng := openEngine(t, dir)
-	eng.CreateTable("users", pkColumns)
+	eng.CreateTable("users", testColumns)
 	eng.Insert("users", nil, [][]any{
-		{int64(1), "alice"},
-		{int64(2), "bob"},
+		{int64(1), "alice", true},
+		{int64(2), "bob", false},
+		{int64(3), "carol", true},
 	})
+	eng.Update("users",
+		map[string]any{"name": "robert"},
+		func(r Row) bool { return r.Values[0] == int64(2) },
+	)
+	eng.Delete("users",
+		func(r Row) bool { return r.Values[0] == int64(3) },
+	)
 	eng.Close()
 
-	// Second session — PK enforcement should still work.
+	// Second session: reopen and verify state.
 	eng2 := openEngine(t, dir)
 	defer eng2.Close()
 
-	_, err := eng2.Insert("users", nil, [][]any{{int64(1), "dup"}})
-	if err == nil {
-		t.Fatal("expected duplicate PK error after restart")
+	def, ok := eng2.GetTable("users")
+	if !ok {
+		t.Fatal("table users not found after restart")
 	}
-	var uv *UniqueViolationError
-	if !errors.As(err, &uv) {
-		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
+	if len(def.Columns) != 3 {
+		t.Fatalf("columns = %d, want 3", len(def.Columns))
 	}
 
-	// Verify LookupByPK works after restart.
-	row, err := eng2.LookupByPK("users", int64(2))
-	if err != nil {
-		t.Fatal(err)
-	}
-	if row == nil || row.Values[1] != "bob" {
-		t.Errorf("lookup after restart: got %v, want bob", row)
+	rows := collectRows(t, must(eng2.Scan("users")))
+	if len(rows) != 2 {
+		t.Fatalf("rows after restart = %d, want 2", len(rows))
 	}
-}
 
-func TestEngine_PrimaryKey_TextKey(t *testing.T) {
-	dir := tempDir(t)
-	eng := openEngine(t, dir)
-	defer eng.Close()
+	byID := make(map[int64]Row)
+	for _, r := range rows {
+		byID[r.ID] = r
+	}
 
-	cols := []ColumnDef{
-		{Name: "code", DataType: TypeText, PrimaryKey: true},
-		{Name: "desc", DataType: TypeText},
+	alice := byID[1]
+	if alice.Values[1] != "alice" {
+		t.Errorf("alice name = %v", alice.Values[1])
 	}
-	eng.CreateTable("codes", cols)
-	eng.Insert("codes", nil, [][]any{{"US", "United States"}, {"UK", "United Kingdom"}})
 
-	_, err := eng.Insert("codes", nil, [][]any{{"US", "duplicate"}})
-	if err == nil {
-		t.Fatal("expected error on duplicate text PK")
+	robert := byID[2]
+	if robert.Values[1] != "robert" {
+		t.Errorf("bob should be renamed to robert, got %v", robert.Values[1])
 	}
 
-	row, _ := eng.LookupByPK("codes", "UK")
-	if row == nil || row.Values[1] != "United Kingdom" {
-		t.Errorf("text key lookup: got %v", row)
+	if _, ok := byID[3]; ok {
+		t.Error("carol should have been deleted")
 	}
 }
 
-// -------------------------------------------------------------------------
-// Split WAL — file layout and migration
-// -------------------------------------------------------------------------
-
-func TestEngine_SplitWAL_FileLayout(t *testing.T) {
+func TestEngine_RestartDroppedTable(t *testing.T) {
 	dir := tempDir(t)
-	eng := openEngine(t, dir)
 
-	eng.CreateTable("users", testColumns)
-	eng.CreateTable("orders", []ColumnDef{
-		{Name: "id", DataType: TypeInteger},
-		{Name: "total", DataType: TypeInteger},
-	})
-	eng.Insert("users", nil, [][]any{{int64(1), "alice", true}})
-	eng.Insert("orders", nil, [][]any{{int64(1), int64(100)}})
+	eng := openEngine(t, dir)
+	eng.CreateTable("temp", testColumns)
+	eng.Insert("temp", nil, [][]any{{int64(1), "x", false}})
+	eng.DropTable("temp")
 	eng.Close()
 
-	// Verify file layout.
-	if !fileExists(filepath.Join(dir, "catalog.wal")) {
-		t.Error("catalog.wal not found")
-	}
-	if !fileExists(filepath.Join(dir, "tables", "users.wal")) {
-		t.Error("tables/users.wal not found")
-	}
-	if !fileExists(filepath.Join(dir, "tables", "orders.wal")) {
-		t.Error("tables/orders.wal not found")
-	}
-	// Legacy wal.dat should NOT exist for a fresh database.
-	if fileExists(filepath.Join(dir, "wal.dat")) {
-		t.Error("wal.dat should not exist for a fresh database")
+	eng2 := openEngine(t, dir)
+	defer eng2.Close()
+
+	if _, ok := eng2.GetTable("temp"); ok {
+		t.Error("dropped table should not exist after restart")
 	}
 }
 
-func TestEngine_SplitWAL_DropRemovesFile(t *testing.T) {
+// -------------------------------------------------------------------------
+// Error cases
+// -------------------------------------------------------------------------
+
+func TestEngine_Errors(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
+	defer eng.Close()
 
-	eng.CreateTable("temp", testColumns)
-	eng.Insert("temp", nil, [][]any{{int64(1), "x", false}})
-
-	walPath := filepath.Join(dir, "tables", "temp.wal")
-	if !fileExists(walPath) {
-		t.Fatal("table WAL file should exist before drop")
+	// Table doesn't exist.
+	if _, err := eng.Scan("nope"); err == nil {
+		t.Error("scan non-existent table should fail")
+	}
+	if _, err := eng.Insert("nope", nil, [][]any{{int64(1)}}); err == nil {
+		t.Error("insert non-existent table should fail")
+	}
+	if err := eng.DropTable("nope"); err == nil {
+		t.Error("drop non-existent table should fail")
 	}
 
-	eng.DropTable("temp")
-
-	if fileExists(walPath) {
-		t.Error("table WAL file should be deleted after drop")
+	// Duplicate table.
+	eng.CreateTable("t", testColumns)
+	if err := eng.CreateTable("t", testColumns); err == nil {
+		t.Error("duplicate create should fail")
 	}
 
-	eng.Close()
+	// Wrong value count.
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1)}}); err == nil {
+		t.Error("insert with wrong column count should fail")
+	}
 }
 
-func TestEngine_SplitWAL_Migration(t *testing.T) {
-	dir := tempDir(t)
-	os.MkdirAll(dir, 0755)
+// -------------------------------------------------------------------------
+// Value encoding round-trip
+// -------------------------------------------------------------------------
 
-	// Create a v2 single-WAL file manually (simulates legacy data).
-	walPath := filepath.Join(dir, "wal.dat")
-	w, err := OpenWAL(walPath, false)
+func TestValueEncoding(t *testing.T) {
+	values := []any{int64(42), "hello world", true, nil, false, int64(-1), ""}
+	buf := encodeValues(nil, values)
+	decoded, rest, err := decodeValues(buf)
 	if err != nil {
-		t.Fatalf("create legacy WAL: %v", err)
+		t.Fatal(err)
 	}
-	cols := []ColumnDef{
-		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
-		{Name: "name", DataType: TypeText},
+	if len(rest) != 0 {
+		t.Fatalf("leftover bytes: %d", len(rest))
 	}
-	w.WriteCreateTable("users", cols)
-	w.WriteInsertBatch("users", []rowInsert{
-		{RowID: 1, Values: []any{int64(1), "alice"}},
-		{RowID: 2, Values: []any{int64(2), "bob"}},
-	})
-
-	// Also create+drop a table to test that dropped tables are pruned.
-	w.WriteCreateTable("temp", []ColumnDef{{Name: "x", DataType: TypeInteger}})
-	w.WriteInsertBatch("temp", []rowInsert{{RowID: 1, Values: []any{int64(42)}}})
-	w.WriteDropTable("temp")
-	w.Close()
-
-	// Open with migrate=true.
-	eng, err := Open(dir, true)
-	if err != nil {
-		t.Fatalf("Open with migration: %v", err)
+	if len(decoded) != len(values) {
+		t.Fatalf("decoded %d values, want %d", len(decoded), len(values))
+	}
+	for i, want := range values {
+		got := decoded[i]
+		if got != want {
+			t.Errorf("value[%d] = %v (%T), want %v (%T)", i, got, got, want, want)
+		}
 	}
+}
 
-	// Verify users survived.
-	def, ok := eng.GetTable("users")
-	if !ok {
-		t.Fatal("users table not found after migration")
+func TestByteaValues(t *testing.T) {
+	// Hex and escape input formats.
+	for in, want := range map[string][]byte{
+		`\x00ff10`: {0x00, 0xff, 0x10},
+		`\XDE AD`:  {0xde, 0xad},
+		`ab\\c`:    {'a', 'b', '\\', 'c'},
+		`\001\377`: {0x01, 0xff},
+		``:         {},
+	} {
+		got, err := ParseBytea(in)
+		if err != nil {
+			t.Errorf("ParseBytea(%q): %v", in, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("ParseBytea(%q) = %v, want %v", in, got, want)
+		}
 	}
-	if len(def.Columns) != 2 {
-		t.Fatalf("columns = %d, want 2", len(def.Columns))
+	for _, in := range []string{`\x0`, `\xzz`, `a\b`, `\400`} {
+		if _, err := ParseBytea(in); err == nil {
+			t.Errorf("ParseBytea(%q): expected error", in)
+		}
 	}
-	if !def.Columns[0].PrimaryKey {
-		t.Error("PK flag lost during migration")
+	if got := FormatBytea([]byte{0x0a, 0xff}); got != `\x0aff` {
+		t.Errorf("FormatBytea = %q, want \\x0aff", got)
 	}
 
-	rows := collectRows(t, must(eng.Scan("users")))
-	if len(rows) != 2 {
-		t.Fatalf("rows = %d, want 2", len(rows))
+	// WAL codec round trip.
+	blob := bytes.Repeat([]byte{0xab}, 70000) // longer than a TEXT length prefix allows
+	decoded, rest, err := decodeValues(encodeValues(nil, []any{[]byte{1, 2}, blob}))
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decode: %v, %d leftover bytes", err, len(rest))
+	}
+	if !bytes.Equal(decoded[0].([]byte), []byte{1, 2}) || !bytes.Equal(decoded[1].([]byte), blob) {
+		t.Errorf("decoded bytea values differ from input")
 	}
 
-	// Verify temp table was dropped.
-	if _, ok := eng.GetTable("temp"); ok {
-		t.Error("dropped table 'temp' should not exist after migration")
+	// Byte-wise ordering.
+	if c := CompareValues([]byte{0x01, 0xff}, []byte{0x02}); c != -1 {
+		t.Errorf("CompareValues = %d, want -1", c)
 	}
+	if c := CompareValues([]byte{0x01}, "x"); c != -2 {
+		t.Errorf("CompareValues(bytea, text) = %d, want -2", c)
+	}
+}
 
-	// Verify no WAL file exists for the dropped table.
-	if fileExists(filepath.Join(dir, "tables", "temp.wal")) {
-		t.Error("temp.wal should not exist after migration (table was dropped)")
+func TestNumericValues(t *testing.T) {
+	num := func(s string) Numeric {
+		t.Helper()
+		n, err := ParseNumeric(s)
+		if err != nil {
+			t.Fatalf("ParseNumeric(%q): %v", s, err)
+		}
+		return n
+	}
+
+	for in, want := range map[string]string{
+		"12":      "12",
+		"-0.50":   "-0.50",
+		".25":     "0.25",
+		"+3.":     "3",
+		"1.5e3":   "1500",
+		"25e-3":   "0.025",
+		"0.000":   "0.000",
+		"-0.0001": "-0.0001",
+	} {
+		if got := num(in).String(); got != want {
+			t.Errorf("ParseNumeric(%q) = %s, want %s", in, got, want)
+		}
+	}
+	for _, in := range []string{"", ".", "1.2.3", "abc", "1e", "NaN", "- 1"} {
+		if _, err := ParseNumeric(in); err == nil {
+			t.Errorf("ParseNumeric(%q): expected error", in)
+		}
 	}
 
-	// Verify backup exists.
-	if !fileExists(filepath.Join(dir, "wal.dat.bak")) {
-		t.Error("wal.dat.bak should exist after migration")
+	// Exact arithmetic keeps the operands' scale.
+	a, b := num("0.10"), num("0.2")
+	if got := a.Add(b).String(); got != "0.30" {
+		t.Errorf("0.10 + 0.2 = %s, want 0.30", got)
+	}
+	if got := a.Sub(b).String(); got != "-0.10" {
+		t.Errorf("0.10 - 0.2 = %s, want -0.10", got)
+	}
+	if got := a.Mul(b).String(); got != "0.020" {
+		t.Errorf("0.10 * 0.2 = %s, want 0.020", got)
+	}
+	if got := num("1").Div(num("3")).String(); got != "0.33333333333333333333" {
+		t.Errorf("1 / 3 = %s", got)
+	}
+	if got := num("10").Div(num("4")).String(); got != "2.5000000000000000" {
+		t.Errorf("10 / 4 = %s", got)
 	}
 
-	eng.Close()
+	// Rounding is half away from zero.
+	for in, want := range map[string]string{"2.345": "2.35", "-2.345": "-2.35", "2.344": "2.34", "7": "7.00"} {
+		if got := num(in).Round(2).String(); got != want {
+			t.Errorf("Round(%s, 2) = %s, want %s", in, got, want)
+		}
+	}
 
-	// Reopen without --migrate to verify the split format works.
-	eng2, err := Open(dir, false)
-	if err != nil {
-		t.Fatalf("reopen after migration: %v", err)
+	// Precision checks.
+	if n, err := CoerceNumeric(float64(0.1), 5, 2); err != nil || n.String() != "0.10" {
+		t.Errorf("CoerceNumeric(0.1, 5, 2) = %v, %v", n, err)
+	}
+	if n, err := CoerceNumeric("999.994", 5, 2); err != nil || n.String() != "999.99" {
+		t.Errorf("CoerceNumeric(999.994, 5, 2) = %v, %v", n, err)
+	}
+	var overflow *NumericOverflowError
+	if _, err := CoerceNumeric("999.995", 5, 2); !errors.As(err, &overflow) {
+		t.Errorf("CoerceNumeric(999.995, 5, 2): got %v, want NumericOverflowError", err)
 	}
-	defer eng2.Close()
 
-	rows2 := collectRows(t, must(eng2.Scan("users")))
-	if len(rows2) != 2 {
-		t.Fatalf("rows after reopen = %d, want 2", len(rows2))
+	// Comparison is by value, and equal values share a map key.
+	if c := CompareValues(num("1.50"), num("1.5")); c != 0 {
+		t.Errorf("CompareValues(1.50, 1.5) = %d, want 0", c)
+	}
+	if c := CompareValues(num("2.5"), int64(3)); c != -1 {
+		t.Errorf("CompareValues(2.5, 3) = %d, want -1", c)
+	}
+	if mapKey(num("1.50")) != mapKey(num("1.5")) || mapKey(num("0.00")) != mapKey(num("0")) {
+		t.Errorf("equal numerics have different map keys")
+	}
+
+	// WAL codec round trip.
+	in := []any{num("-12345678901234567890.123"), num("0"), num("0.001")}
+	decoded, rest, err := decodeValues(encodeValues(nil, in))
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decode: %v, %d leftover bytes", err, len(rest))
+	}
+	for i, v := range decoded {
+		if v.(Numeric).String() != in[i].(Numeric).String() {
+			t.Errorf("decoded[%d] = %v, want %v", i, v, in[i])
+		}
 	}
 }
 
-func TestEngine_SplitWAL_RestartWithMultipleTables(t *testing.T) {
+func TestEngine_NumericColumn(t *testing.T) {
 	dir := tempDir(t)
-
 	eng := openEngine(t, dir)
-	eng.CreateTable("t1", []ColumnDef{{Name: "v", DataType: TypeInteger}})
-	eng.CreateTable("t2", []ColumnDef{{Name: "v", DataType: TypeText}})
-	eng.Insert("t1", nil, [][]any{{int64(1)}, {int64(2)}})
-	eng.Insert("t2", nil, [][]any{{"a"}, {"b"}, {"c"}})
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "price", DataType: TypeNumeric, Precision: 6, Scale: 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.AddColumn("t", ColumnDef{Name: "qty", DataType: TypeNumeric, Ordinal: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1), "19.999", int64(3)}, {int64(2), float64(0.5), "1.25"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := eng.Insert("t", nil, [][]any{{int64(3), int64(10000), nil}})
+	var overflow *NumericOverflowError
+	if !errors.As(err, &overflow) {
+		t.Errorf("insert 10000 into NUMERIC(6,2): got %v, want NumericOverflowError", err)
+	}
 	eng.Close()
 
-	eng2 := openEngine(t, dir)
-	defer eng2.Close()
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	def, _ := eng.GetTable("t")
+	if c := def.Columns[1]; c.Precision != 6 || c.Scale != 2 {
+		t.Errorf("price = NUMERIC(%d,%d), want NUMERIC(6,2)", c.Precision, c.Scale)
+	}
+	if c := def.Columns[2]; c.DataType != TypeNumeric || c.Precision != 0 {
+		t.Errorf("qty = %v(%d), want unconstrained NUMERIC", c.DataType, c.Precision)
+	}
+	it, err := eng.Scan("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range collectRows(t, it) {
+		got = append(got, fmt.Sprint(r.Values[1], " ", r.Values[2]))
+	}
+	if want := []string{"20.00 3", "0.50 1.25"}; !slices.Equal(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
 
-	rows1 := collectRows(t, must(eng2.Scan("t1")))
-	if len(rows1) != 2 {
-		t.Errorf("t1 rows = %d, want 2", len(rows1))
+// -------------------------------------------------------------------------
+// Typed errors
+// -------------------------------------------------------------------------
+
+func TestEngine_TypedErrors(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	// TableNotFoundError from Scan.
+	_, err := eng.Scan("nope")
+	if err == nil {
+		t.Fatal("expected error")
 	}
-	rows2 := collectRows(t, must(eng2.Scan("t2")))
-	if len(rows2) != 3 {
-		t.Errorf("t2 rows = %d, want 3", len(rows2))
+	var tnf *TableNotFoundError
+	if !errors.As(err, &tnf) {
+		t.Errorf("Scan: expected TableNotFoundError, got %T: %v", err, err)
+	}
+
+	// TableExistsError from duplicate CreateTable.
+	eng.CreateTable("t", testColumns)
+	err = eng.CreateTable("t", testColumns)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	var te *TableExistsError
+	if !errors.As(err, &te) {
+		t.Errorf("CreateTable: expected TableExistsError, got %T: %v", err, err)
+	}
+
+	// ValueCountError from wrong column count.
+	_, err = eng.Insert("t", nil, [][]any{{int64(1)}})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	var vc *ValueCountError
+	if !errors.As(err, &vc) {
+		t.Errorf("Insert: expected ValueCountError, got %T: %v", err, err)
 	}
 }
 
-func TestEngine_SplitWAL_SpecialCharTableNames(t *testing.T) {
+// -------------------------------------------------------------------------
+// Concurrency
+// -------------------------------------------------------------------------
+
+func TestEngine_ConcurrentReadsAndWrites(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
+	defer eng.Close()
 
-	// Table names with special characters.
-	eng.CreateTable("my table", []ColumnDef{{Name: "v", DataType: TypeInteger}})
-	eng.Insert("my table", nil, [][]any{{int64(1)}})
-	eng.Close()
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "val", DataType: TypeText},
+	})
 
-	// Verify the WAL file has percent-encoded name.
-	if !fileExists(filepath.Join(dir, "tables", "my%20table.wal")) {
-		t.Error("expected percent-encoded WAL filename")
+	const numWriters = 4
+	const numReaders = 8
+	const opsPerWorker = 50
+
+	// Insert some seed data so readers always have something to scan.
+	eng.Insert("t", nil, [][]any{
+		{int64(0), "seed"},
+	})
+
+	errs := make(chan error, numWriters+numReaders)
+	var wg sync.WaitGroup
+
+	// Writers: insert rows concurrently.
+	for w := 0; w < numWriters; w++ {
+		wg.Add(1)
+		go func(writerID int) {
+			defer wg.Done()
+			for i := 0; i < opsPerWorker; i++ {
+				_, err := eng.Insert("t", nil, [][]any{
+					{int64(writerID*1000 + i), fmt.Sprintf("w%d-%d", writerID, i)},
+				})
+				if err != nil {
+					errs <- fmt.Errorf("writer %d, op %d: %w", writerID, i, err)
+					return
+				}
+			}
+		}(w)
 	}
 
-	// Reopen and verify data.
-	eng2 := openEngine(t, dir)
-	defer eng2.Close()
+	// Readers: scan the table concurrently.
+	for r := 0; r < numReaders; r++ {
+		wg.Add(1)
+		go func(readerID int) {
+			defer wg.Done()
+			for i := 0; i < opsPerWorker; i++ {
+				it, err := eng.Scan("t")
+				if err != nil {
+					errs <- fmt.Errorf("reader %d, op %d: scan: %w", readerID, i, err)
+					return
+				}
+				count := 0
+				for {
+					_, ok := it.Next()
+					if !ok {
+						break
+					}
+					count++
+				}
+				it.Close()
+				if count == 0 {
+					errs <- fmt.Errorf("reader %d, op %d: got 0 rows", readerID, i)
+					return
+				}
+			}
+		}(r)
+	}
 
-	rows := collectRows(t, must(eng2.Scan("my table")))
-	if len(rows) != 1 {
-		t.Fatalf("rows = %d, want 1", len(rows))
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
 	}
-	if rows[0].Values[0] != int64(1) {
-		t.Errorf("value = %v, want 1", rows[0].Values[0])
+
+	// Verify final row count: 1 seed + numWriters * opsPerWorker
+	expectedRows := 1 + numWriters*opsPerWorker
+	it, err := eng.Scan("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := collectRows(t, it)
+	if len(rows) != expectedRows {
+		t.Errorf("final rows = %d, want %d", len(rows), expectedRows)
 	}
 }
 
-func TestEngine_SplitWAL_OrphanCleanup(t *testing.T) {
-	dir := tempDir(t)
+// -------------------------------------------------------------------------
+// Primary Key
+// -------------------------------------------------------------------------
 
-	// Create a normal database.
+var pkColumns = []ColumnDef{
+	{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+	{Name: "name", DataType: TypeText},
+}
+
+func TestEngine_PrimaryKey_Insert(t *testing.T) {
+	dir := tempDir(t)
 	eng := openEngine(t, dir)
-	eng.CreateTable("keep", []ColumnDef{{Name: "v", DataType: TypeInteger}})
-	eng.Close()
+	defer eng.Close()
 
-	// Simulate an orphan WAL file (table was dropped but file lingers).
-	tablesDir := filepath.Join(dir, "tables")
-	orphanPath := filepath.Join(tablesDir, "orphan.wal")
-	orphanFile, err := os.Create(orphanPath)
+	eng.CreateTable("users", pkColumns)
+	n, err := eng.Insert("users", nil, [][]any{
+		{int64(1), "alice"},
+		{int64(2), "bob"},
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	writeWALHeader(orphanFile)
-	orphanFile.Close()
+	if n != 2 {
+		t.Fatalf("inserted %d, want 2", n)
+	}
+}
 
-	if !fileExists(orphanPath) {
-		t.Fatal("orphan file should exist before reopen")
+func TestEngine_PrimaryKey_DuplicateInsert(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("users", pkColumns)
+	eng.Insert("users", nil, [][]any{{int64(1), "alice"}})
+
+	_, err := eng.Insert("users", nil, [][]any{{int64(1), "bob"}})
+	if err == nil {
+		t.Fatal("expected error on duplicate PK")
+	}
+	var uv *UniqueViolationError
+	if !errors.As(err, &uv) {
+		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
 	}
+}
 
-	// Reopen — orphan should be cleaned up.
-	eng2 := openEngine(t, dir)
-	defer eng2.Close()
+func TestEngine_PrimaryKey_DuplicateInBatch(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
 
-	if fileExists(orphanPath) {
-		t.Error("orphan WAL file should be deleted on startup")
+	eng.CreateTable("users", pkColumns)
+	_, err := eng.Insert("users", nil, [][]any{
+		{int64(1), "alice"},
+		{int64(1), "bob"}, // duplicate within same batch
+	})
+	if err == nil {
+		t.Fatal("expected error on duplicate PK within batch")
+	}
+	var uv *UniqueViolationError
+	if !errors.As(err, &uv) {
+		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
 	}
+}
 
-	// Keep table should still work.
-	if _, ok := eng2.GetTable("keep"); !ok {
-		t.Error("'keep' table should still exist")
+func TestEngine_PrimaryKey_NullPK(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("users", pkColumns)
+	_, err := eng.Insert("users", nil, [][]any{{nil, "alice"}})
+	if err == nil {
+		t.Fatal("expected error on NULL PK")
+	}
+	var uv *UniqueViolationError
+	if !errors.As(err, &uv) {
+		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
 	}
 }
 
-func TestEngine_SplitWAL_ConcurrentDifferentTables(t *testing.T) {
+func TestEngine_PrimaryKey_DeleteAndReinsert(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
 	defer eng.Close()
 
-	eng.CreateTable("t1", []ColumnDef{
-		{Name: "id", DataType: TypeInteger},
-		{Name: "val", DataType: TypeText},
-	})
-	eng.CreateTable("t2", []ColumnDef{
-		{Name: "id", DataType: TypeInteger},
-		{Name: "val", DataType: TypeText},
-	})
+	eng.CreateTable("users", pkColumns)
+	eng.Insert("users", nil, [][]any{{int64(1), "alice"}})
 
-	const ops = 100
-	errs := make(chan error, 4)
-	var wg sync.WaitGroup
+	eng.Delete("users", func(r Row) bool { return r.Values[0] == int64(1) })
 
-	// Writer to t1.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	// Should be able to reinsert same PK.
+	_, err := eng.Insert("users", nil, [][]any{{int64(1), "bob"}})
+	if err != nil {
+		t.Fatalf("reinsert after delete failed: %v", err)
+	}
+}
+
+func TestEngine_PrimaryKey_UpdateNonPK(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("users", pkColumns)
+	eng.Insert("users", nil, [][]any{{int64(1), "alice"}})
+
+	// Update non-PK column should succeed.
+	n, err := eng.Update("users",
+		map[string]any{"name": "alicia"},
+		func(r Row) bool { return r.Values[0] == int64(1) },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("updated %d, want 1", n)
+	}
+}
+
+func TestEngine_PrimaryKey_UpdatePKToDuplicate(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("users", pkColumns)
+	eng.Insert("users", nil, [][]any{
+		{int64(1), "alice"},
+		{int64(2), "bob"},
+	})
+
+	_, err := eng.Update("users",
+		map[string]any{"id": int64(1)},
+		func(r Row) bool { return r.Values[0] == int64(2) },
+	)
+	if err == nil {
+		t.Fatal("expected error on PK update to duplicate value")
+	}
+	var uv *UniqueViolationError
+	if !errors.As(err, &uv) {
+		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
+	}
+}
+
+func TestEngine_PrimaryKey_LookupByPK(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("users", pkColumns)
+	eng.Insert("users", nil, [][]any{
+		{int64(1), "alice"},
+		{int64(2), "bob"},
+	})
+
+	row, err := eng.LookupByPK("users", int64(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row == nil {
+		t.Fatal("expected row, got nil")
+	}
+	if row.Values[1] != "bob" {
+		t.Errorf("name = %v, want bob", row.Values[1])
+	}
+
+	// Non-existent key.
+	row, err = eng.LookupByPK("users", int64(99))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row != nil {
+		t.Errorf("expected nil for missing key, got %v", row)
+	}
+}
+
+func TestEngine_PrimaryKey_LookupByPK_NoPK(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("users", testColumns) // no PK
+	eng.Insert("users", nil, [][]any{{int64(1), "alice", true}})
+
+	row, err := eng.LookupByPK("users", int64(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row != nil {
+		t.Error("expected nil for table without PK")
+	}
+}
+
+func TestEngine_PrimaryKey_Restart(t *testing.T) {
+	dir := tempDir(t)
+
+	// First session.
+	eng := openEngine(t, dir)
+	eng.CreateTable("users", pkColumns)
+	eng.Insert("users", nil, [][]any{
+		{int64(1), "alice"},
+		{int64(2), "bob"},
+	})
+	eng.Close()
+
+	// Second session — PK enforcement should still work.
+	eng2 := openEngine(t, dir)
+	defer eng2.Close()
+
+	_, err := eng2.Insert("users", nil, [][]any{{int64(1), "dup"}})
+	if err == nil {
+		t.Fatal("expected duplicate PK error after restart")
+	}
+	var uv *UniqueViolationError
+	if !errors.As(err, &uv) {
+		t.Fatalf("expected UniqueViolationError, got %T: %v", err, err)
+	}
+
+	// Verify LookupByPK works after restart.
+	row, err := eng2.LookupByPK("users", int64(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row == nil || row.Values[1] != "bob" {
+		t.Errorf("lookup after restart: got %v, want bob", row)
+	}
+}
+
+func TestEngine_PrimaryKey_TextKey(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	cols := []ColumnDef{
+		{Name: "code", DataType: TypeText, PrimaryKey: true},
+		{Name: "desc", DataType: TypeText},
+	}
+	eng.CreateTable("codes", cols)
+	eng.Insert("codes", nil, [][]any{{"US", "United States"}, {"UK", "United Kingdom"}})
+
+	_, err := eng.Insert("codes", nil, [][]any{{"US", "duplicate"}})
+	if err == nil {
+		t.Fatal("expected error on duplicate text PK")
+	}
+
+	row, _ := eng.LookupByPK("codes", "UK")
+	if row == nil || row.Values[1] != "United Kingdom" {
+		t.Errorf("text key lookup: got %v", row)
+	}
+}
+
+// -------------------------------------------------------------------------
+// Split WAL — file layout and migration
+// -------------------------------------------------------------------------
+
+func TestEngine_SplitWAL_FileLayout(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+
+	eng.CreateTable("users", testColumns)
+	eng.CreateTable("orders", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "total", DataType: TypeInteger},
+	})
+	eng.Insert("users", nil, [][]any{{int64(1), "alice", true}})
+	eng.Insert("orders", nil, [][]any{{int64(1), int64(100)}})
+	eng.Close()
+
+	// Verify file layout.
+	if !fileExists(filepath.Join(dir, "catalog.wal")) {
+		t.Error("catalog.wal not found")
+	}
+	if !fileExists(filepath.Join(dir, "tables", "users.wal")) {
+		t.Error("tables/users.wal not found")
+	}
+	if !fileExists(filepath.Join(dir, "tables", "orders.wal")) {
+		t.Error("tables/orders.wal not found")
+	}
+	// Legacy wal.dat should NOT exist for a fresh database.
+	if fileExists(filepath.Join(dir, "wal.dat")) {
+		t.Error("wal.dat should not exist for a fresh database")
+	}
+}
+
+func TestEngine_SplitWAL_DropRemovesFile(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+
+	eng.CreateTable("temp", testColumns)
+	eng.Insert("temp", nil, [][]any{{int64(1), "x", false}})
+
+	walPath := filepath.Join(dir, "tables", "temp.wal")
+	if !fileExists(walPath) {
+		t.Fatal("table WAL file should exist before drop")
+	}
+
+	eng.DropTable("temp")
+
+	if fileExists(walPath) {
+		t.Error("table WAL file should be deleted after drop")
+	}
+
+	eng.Close()
+}
+
+func TestEngine_SplitWAL_Migration(t *testing.T) {
+	dir := tempDir(t)
+	os.MkdirAll(dir, 0755)
+
+	// Create a v2 single-WAL file manually (simulates legacy data).
+	walPath := filepath.Join(dir, "wal.dat")
+	w, err := OpenWAL(walPath, false)
+	if err != nil {
+		t.Fatalf("create legacy WAL: %v", err)
+	}
+	cols := []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	}
+	w.WriteCreateTable("users", cols)
+	w.WriteInsertBatch("users", []rowInsert{
+		{RowID: 1, Values: []any{int64(1), "alice"}},
+		{RowID: 2, Values: []any{int64(2), "bob"}},
+	})
+
+	// Also create+drop a table to test that dropped tables are pruned.
+	w.WriteCreateTable("temp", []ColumnDef{{Name: "x", DataType: TypeInteger}})
+	w.WriteInsertBatch("temp", []rowInsert{{RowID: 1, Values: []any{int64(42)}}})
+	w.WriteDropTable("temp")
+	w.Close()
+
+	// Open with migrate=true.
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open with migration: %v", err)
+	}
+
+	// Verify users survived.
+	def, ok := eng.GetTable("users")
+	if !ok {
+		t.Fatal("users table not found after migration")
+	}
+	if len(def.Columns) != 2 {
+		t.Fatalf("columns = %d, want 2", len(def.Columns))
+	}
+	if !def.Columns[0].PrimaryKey {
+		t.Error("PK flag lost during migration")
+	}
+
+	rows := collectRows(t, must(eng.Scan("users")))
+	if len(rows) != 2 {
+		t.Fatalf("rows = %d, want 2", len(rows))
+	}
+
+	// Verify temp table was dropped.
+	if _, ok := eng.GetTable("temp"); ok {
+		t.Error("dropped table 'temp' should not exist after migration")
+	}
+
+	// Verify no WAL file exists for the dropped table.
+	if fileExists(filepath.Join(dir, "tables", "temp.wal")) {
+		t.Error("temp.wal should not exist after migration (table was dropped)")
+	}
+
+	// Verify backup exists.
+	if !fileExists(filepath.Join(dir, "wal.dat.bak")) {
+		t.Error("wal.dat.bak should exist after migration")
+	}
+
+	eng.Close()
+
+	// Reopen without --migrate to verify the split format works.
+	eng2, err := Open(dir, false)
+	if err != nil {
+		t.Fatalf("reopen after migration: %v", err)
+	}
+	defer eng2.Close()
+
+	rows2 := collectRows(t, must(eng2.Scan("users")))
+	if len(rows2) != 2 {
+		t.Fatalf("rows after reopen = %d, want 2", len(rows2))
+	}
+}
+
+func TestEngine_SplitWAL_RestartWithMultipleTables(t *testing.T) {
+	dir := tempDir(t)
+
+	eng := openEngine(t, dir)
+	eng.CreateTable("t1", []ColumnDef{{Name: "v", DataType: TypeInteger}})
+	eng.CreateTable("t2", []ColumnDef{{Name: "v", DataType: TypeText}})
+	eng.Insert("t1", nil, [][]any{{int64(1)}, {int64(2)}})
+	eng.Insert("t2", nil, [][]any{{"a"}, {"b"}, {"c"}})
+	eng.Close()
+
+	eng2 := openEngine(t, dir)
+	defer eng2.Close()
+
+	rows1 := collectRows(t, must(eng2.Scan("t1")))
+	if len(rows1) != 2 {
+		t.Errorf("t1 rows = %d, want 2", len(rows1))
+	}
+	rows2 := collectRows(t, must(eng2.Scan("t2")))
+	if len(rows2) != 3 {
+		t.Errorf("t2 rows = %d, want 3", len(rows2))
+	}
+}
+
+func TestEngine_SplitWAL_SpecialCharTableNames(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+
+	// Table names with special characters.
+	eng.CreateTable("my table", []ColumnDef{{Name: "v", DataType: TypeInteger}})
+	eng.Insert("my table", nil, [][]any{{int64(1)}})
+	eng.Close()
+
+	// Verify the WAL file has percent-encoded name.
+	if !fileExists(filepath.Join(dir, "tables", "my%20table.wal")) {
+		t.Error("expected percent-encoded WAL filename")
+	}
+
+	// Reopen and verify data.
+	eng2 := openEngine(t, dir)
+	defer eng2.Close()
+
+	rows := collectRows(t, must(eng2.Scan("my table")))
+	if len(rows) != 1 {
+		t.Fatalf("rows = %d, want 1", len(rows))
+	}
+	if rows[0].Values[0] != int64(1) {
+		t.Errorf("value = %v, want 1", rows[0].Values[0])
+	}
+}
+
+func TestEngine_SplitWAL_OrphanCleanup(t *testing.T) {
+	dir := tempDir(t)
+
+	// Create a normal database.
+	eng := openEngine(t, dir)
+	eng.CreateTable("keep", []ColumnDef{{Name: "v", DataType: TypeInteger}})
+	eng.Close()
+
+	// Simulate an orphan WAL file (table was dropped but file lingers).
+	tablesDir := filepath.Join(dir, "tables")
+	orphanPath := filepath.Join(tablesDir, "orphan.wal")
+	orphanFile, err := os.Create(orphanPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeWALHeader(orphanFile)
+	orphanFile.Close()
+
+	if !fileExists(orphanPath) {
+		t.Fatal("orphan file should exist before reopen")
+	}
+
+	// Reopen — orphan should be cleaned up.
+	eng2 := openEngine(t, dir)
+	defer eng2.Close()
+
+	if fileExists(orphanPath) {
+		t.Error("orphan WAL file should be deleted on startup")
+	}
+
+	// Keep table should still work.
+	if _, ok := eng2.GetTable("keep"); !ok {
+		t.Error("'keep' table should still exist")
+	}
+}
+
+func TestEngine_SplitWAL_ConcurrentDifferentTables(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("t1", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "val", DataType: TypeText},
+	})
+	eng.CreateTable("t2", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "val", DataType: TypeText},
+	})
+
+	const ops = 100
+	errs := make(chan error, 4)
+	var wg sync.WaitGroup
+
+	// Writer to t1.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
 		for i := 0; i < ops; i++ {
 			_, err := eng.Insert("t1", nil, [][]any{
 				{int64(i), fmt.Sprintf("t1-%d", i)},
 			})
-			if err != nil {
-				errs <- fmt.Errorf("t1 insert %d: %w", i, err)
-				return
+			if err != nil {
+				errs <- fmt.Errorf("t1 insert %d: %w", i, err)
+				return
+			}
+		}
+	}()
+
+	// Writer to t2.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < ops; i++ {
+			_, err := eng.Insert("t2", nil, [][]any{
+				{int64(i), fmt.Sprintf("t2-%d", i)},
+			})
+			if err != nil {
+				errs <- fmt.Errorf("t2 insert %d: %w", i, err)
+				return
+			}
+		}
+	}()
+
+	// Reader of t1.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < ops; i++ {
+			it, err := eng.Scan("t1")
+			if err != nil {
+				errs <- fmt.Errorf("t1 scan %d: %w", i, err)
+				return
+			}
+			for {
+				if _, ok := it.Next(); !ok {
+					break
+				}
+			}
+			it.Close()
+		}
+	}()
+
+	// Reader of t2.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < ops; i++ {
+			it, err := eng.Scan("t2")
+			if err != nil {
+				errs <- fmt.Errorf("t2 scan %d: %w", i, err)
+				return
+			}
+			for {
+				if _, ok := it.Next(); !ok {
+					break
+				}
+			}
+			it.Close()
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Verify final counts.
+	rows1 := collectRows(t, must(eng.Scan("t1")))
+	if len(rows1) != ops {
+		t.Errorf("t1 rows = %d, want %d", len(rows1), ops)
+	}
+	rows2 := collectRows(t, must(eng.Scan("t2")))
+	if len(rows2) != ops {
+		t.Errorf("t2 rows = %d, want %d", len(rows2), ops)
+	}
+}
+
+func TestEngine_SplitWAL_DropWhileOtherTableActive(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("keep", []ColumnDef{{Name: "v", DataType: TypeInteger}})
+	eng.CreateTable("drop_me", []ColumnDef{{Name: "v", DataType: TypeInteger}})
+	eng.Insert("keep", nil, [][]any{{int64(1)}})
+	eng.Insert("drop_me", nil, [][]any{{int64(2)}})
+
+	// Drop one table.
+	if err := eng.DropTable("drop_me"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other table should still work fine.
+	rows := collectRows(t, must(eng.Scan("keep")))
+	if len(rows) != 1 {
+		t.Errorf("keep rows = %d, want 1", len(rows))
+	}
+
+	// DML on dropped table should fail.
+	_, err := eng.Scan("drop_me")
+	if err == nil {
+		t.Error("scan on dropped table should fail")
+	}
+}
+
+// -------------------------------------------------------------------------
+// Helpers
+// -------------------------------------------------------------------------
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// rowOnlyIterator hides the NextBatch of the iterator it wraps.
+type rowOnlyIterator struct{ it RowIterator }
+
+func (r rowOnlyIterator) Next() (Row, bool) { return r.it.Next() }
+func (r rowOnlyIterator) Close() error      { return r.it.Close() }
+
+func TestNextBatch(t *testing.T) {
+	h := benchHeap(10)
+	for _, wrap := range []bool{false, true} {
+		var it RowIterator = h.scan()
+		if wrap {
+			it = rowOnlyIterator{it}
+		}
+		// Batches and single rows can be mixed; rows come in order.
+		var ids []int64
+		for _, n := range []int{3, 0, 4, 100, 5} {
+			for _, r := range NextBatch(it, n) {
+				ids = append(ids, r.ID)
+			}
+			if n == 0 {
+				if r, ok := it.Next(); ok {
+					ids = append(ids, r.ID)
+				}
+			}
+		}
+		want := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+		if !slices.Equal(ids, want) {
+			t.Errorf("wrapped=%v: ids = %v, want %v", wrap, ids, want)
+		}
+	}
+}
+
+// -------------------------------------------------------------------------
+// Benchmarks
+// -------------------------------------------------------------------------
+
+func BenchmarkSumScan(b *testing.B) {
+	h := benchHeap(10_000_000)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		it := h.scan()
+		var sum int64
+		for {
+			row, ok := it.Next()
+			if !ok {
+				break
+			}
+			sum += row.Values[0].(int64)
+		}
+		it.Close()
+		_ = sum
+	}
+}
+
+// BenchmarkRowMemory reports the heap bytes per row of a table of five
+// INTEGER columns, like an order_items table, as the heap stores it and as
+// one []any per row, the layout the heap used before, would.
+func BenchmarkRowMemory(b *testing.B) {
+	const rowCount = 100_000
+	def := TableDef{Name: "order_items", NextOrdinal: 5}
+	for i, name := range []string{"id", "order_id", "product_id", "quantity", "unit_price"} {
+		def.Columns = append(def.Columns, ColumnDef{Name: name, DataType: TypeInteger, Ordinal: i})
+	}
+	row := func(i int) []any {
+		return []any{int64(i), int64(i/4 + 1000), int64(i%5000 + 1000), int64(i%10 + 1), int64(i%900 + 100)}
+	}
+	b.Run("heap", func(b *testing.B) {
+		reportRowBytes(b, rowCount, func() any {
+			h := newTableHeap(def)
+			for i := 1; i <= rowCount; i++ {
+				h.insertWithID(int64(i), row(i))
+			}
+			return h
+		})
+	})
+	b.Run("boxed", func(b *testing.B) {
+		reportRowBytes(b, rowCount, func() any {
+			rows := make([][]any, 1, rowCount+1)
+			for i := 1; i <= rowCount; i++ {
+				rows = append(rows, row(i))
+			}
+			return rows
+		})
+	})
+}
+
+// BenchmarkDictionaryMemory reports the heap bytes per row of an orders
+// table whose status column is kept boxed and dictionary-encoded.
+func BenchmarkDictionaryMemory(b *testing.B) {
+	const rowCount = 100_000
+	statuses := []string{"pending", "paid", "shipped", "delivered", "cancelled"}
+	for _, dict := range []bool{false, true} {
+		def := TableDef{Name: "orders", NextOrdinal: 2, Columns: []ColumnDef{
+			{Name: "id", DataType: TypeInteger, Ordinal: 0},
+			{Name: "status", DataType: TypeText, Ordinal: 1, Dictionary: dict},
+		}}
+		name := "boxed"
+		if dict {
+			name = "dictionary"
+		}
+		b.Run(name, func(b *testing.B) {
+			reportRowBytes(b, rowCount, func() any {
+				h := newTableHeap(def)
+				for i := 1; i <= rowCount; i++ {
+					// A string of its own per row, as rows read from the
+					// wire have.
+					h.insertWithID(int64(i), []any{int64(i), strings.Clone(statuses[i%len(statuses)])})
+				}
+				return h
+			})
+		})
+	}
+}
+
+// reportRowBytes reports the heap bytes that what build returns keeps
+// alive, per row of rowCount.
+func reportRowBytes(b *testing.B, rowCount int, build func() any) {
+	for n := 0; n < b.N; n++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		kept := build()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(kept)
+		b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(rowCount), "B/row")
+	}
+}
+
+// benchHeap returns a heap of rowCount rows with a small random integer.
+func benchHeap(rowCount int) *tableHeap {
+	def := TableDef{Name: "bench", Columns: []ColumnDef{{Name: "val", DataType: TypeInteger}}}
+	h := newTableHeap(def)
+	rng := rand.New(rand.NewSource(42))
+	for i := 0; i < rowCount; i++ {
+		h.insertWithID(int64(i+1), []any{int64(rng.Intn(6))})
+	}
+	return h
+}
+
+// -------------------------------------------------------------------------
+// ALTER TABLE (ADD COLUMN / DROP COLUMN)
+// -------------------------------------------------------------------------
+
+func TestEngine_AddColumn(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+	})
+	eng.Insert("t", nil, [][]any{{int64(1)}})
+
+	// Add a column.
+	if err := eng.AddColumn("t", ColumnDef{Name: "name", DataType: TypeText}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Old rows should return NULL for the new column.
+	rows := collectRows(t, must(eng.Scan("t")))
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	// New column ordinal = 1, row length = 1 (short row), so RowValue should return nil.
+	if RowValue(rows[0].Values, 1) != nil {
+		t.Errorf("new column = %v, want nil", RowValue(rows[0].Values, 1))
+	}
+
+	// Insert with the new column.
+	eng.Insert("t", []string{"id", "name"}, [][]any{{int64(2), "alice"}})
+	rows = collectRows(t, must(eng.Scan("t")))
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+}
+
+func TestEngine_AddColumnFill(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+	})
+	eng.Insert("t", nil, [][]any{{int64(1)}, {int64(2)}})
+
+	// NOT NULL without a fill value fails while rows exist.
+	err := eng.AddColumn("t", ColumnDef{Name: "status", DataType: TypeText, NotNull: true})
+	var nnErr *NotNullViolationError
+	if !errors.As(err, &nnErr) {
+		t.Fatalf("AddColumn NOT NULL = %v, want NotNullViolationError", err)
+	}
+
+	if err := eng.AddColumn("t", ColumnDef{Name: "status", DataType: TypeText, NotNull: true, Default: "'active'", Fill: "active"}); err != nil {
+		t.Fatal(err)
+	}
+	// The fill value is coerced to the column type.
+	if err := eng.AddColumn("t", ColumnDef{Name: "score", DataType: TypeFloat, Fill: int64(3)}); err != nil {
+		t.Fatal(err)
+	}
+	eng.Insert("t", nil, [][]any{{int64(3), "closed", nil}})
+
+	check := func(phase string) {
+		t.Helper()
+		rows := collectRows(t, must(eng.Scan("t")))
+		want := [][]any{{int64(1), "active", 3.0}, {int64(2), "active", 3.0}, {int64(3), "closed", nil}}
+		if len(rows) != len(want) {
+			t.Fatalf("%s: got %d rows, want %d", phase, len(rows), len(want))
+		}
+		for i, w := range want {
+			for ord, v := range w {
+				if got := RowValue(rows[i].Values, ord); got != v {
+					t.Errorf("%s: row %d ordinal %d = %v, want %v", phase, i, ord, got, v)
+				}
 			}
 		}
-	}()
+	}
+	check("before reopen")
+	eng.Close()
+
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	check("after reopen")
+	def, _ := eng.GetTable("t")
+	if def.Columns[1].Fill != "active" || def.Columns[2].Fill != 3.0 {
+		t.Errorf("fill values = %v, %v, want active, 3", def.Columns[1].Fill, def.Columns[2].Fill)
+	}
+}
+
+func TestEngine_DropColumn(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "name", DataType: TypeText},
+	})
+	eng.Insert("t", nil, [][]any{{int64(1), "alice"}})
+
+	if err := eng.DropColumn("t", "name"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Schema should only have one column.
+	def, ok := eng.GetTable("t")
+	if !ok {
+		t.Fatal("table not found")
+	}
+	if len(def.Columns) != 1 {
+		t.Fatalf("got %d columns, want 1", len(def.Columns))
+	}
+	if def.Columns[0].Name != "id" {
+		t.Errorf("remaining column = %q, want id", def.Columns[0].Name)
+	}
+}
+
+func TestEngine_DropColumn_PK_Error(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	})
+
+	err := eng.DropColumn("t", "id")
+	if err == nil {
+		t.Fatal("expected error for dropping PK column")
+	}
+}
+
+func TestEngine_DropColumn_LastColumn_Error(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+	})
+
+	err := eng.DropColumn("t", "id")
+	if err == nil {
+		t.Fatal("expected error for dropping last column")
+	}
+}
+
+func TestEngine_AddColumn_Duplicate_Error(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+	})
+
+	err := eng.AddColumn("t", ColumnDef{Name: "id", DataType: TypeText})
+	if err == nil {
+		t.Fatal("expected error for duplicate column")
+	}
+	var colExists *ColumnExistsError
+	if !errors.As(err, &colExists) {
+		t.Errorf("got error %T, want *ColumnExistsError", err)
+	}
+}
+
+func TestEngine_DropColumn_NotFound_Error(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+	})
+
+	err := eng.DropColumn("t", "nonexistent")
+	if err == nil {
+		t.Fatal("expected error for dropping non-existent column")
+	}
+}
+
+func TestEngine_AddDropColumn_WAL_Replay(t *testing.T) {
+	dir := tempDir(t)
+
+	// Create, insert, add column, insert again, drop column.
+	eng := openEngine(t, dir)
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "a", DataType: TypeInteger},
+		{Name: "b", DataType: TypeText},
+	})
+	eng.Insert("t", nil, [][]any{{int64(1), "x"}})
+	eng.AddColumn("t", ColumnDef{Name: "c", DataType: TypeInteger})
+	eng.Insert("t", []string{"a", "b", "c"}, [][]any{{int64(2), "y", int64(42)}})
+	eng.DropColumn("t", "b")
+	eng.Close()
+
+	// Reopen and verify.
+	eng = openEngine(t, dir)
+	defer eng.Close()
+
+	def, ok := eng.GetTable("t")
+	if !ok {
+		t.Fatal("table not found after replay")
+	}
+	if len(def.Columns) != 2 {
+		t.Fatalf("got %d columns, want 2 (a, c)", len(def.Columns))
+	}
+	if def.Columns[0].Name != "a" || def.Columns[1].Name != "c" {
+		t.Errorf("columns = [%s, %s], want [a, c]", def.Columns[0].Name, def.Columns[1].Name)
+	}
+
+	rows := collectRows(t, must(eng.Scan("t")))
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+}
+
+func TestEngine_MigrateV2ToV3(t *testing.T) {
+	dir := tempDir(t)
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
+
+	// Write a v2 catalog WAL manually.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write v2 header.
+	var hdr [6]byte
+	copy(hdr[:4], "MWAL")
+	hdr[4] = 0
+	hdr[5] = 2
+	f.Write(hdr[:])
 
-	// Writer to t2.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for i := 0; i < ops; i++ {
-			_, err := eng.Insert("t2", nil, [][]any{
-				{int64(i), fmt.Sprintf("t2-%d", i)},
-			})
-			if err != nil {
-				errs <- fmt.Errorf("t2 insert %d: %w", i, err)
-				return
-			}
+	// Write a v2 CREATE TABLE entry (no ordinals).
+	cols := []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	}
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, uint16(len(cols)))
+	for _, col := range cols {
+		buf = encodeString(buf, col.Name)
+		buf = append(buf, byte(col.DataType))
+		var pk byte
+		if col.PrimaryKey {
+			pk = 1
 		}
-	}()
+		buf = append(buf, pk)
+		// v2 does NOT have ordinal — that's what migration adds
+	}
+	writeRawEntry(f, 1, buf) // opCreateTable = 1
+	f.Close()
 
-	// Reader of t1.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for i := 0; i < ops; i++ {
-			it, err := eng.Scan("t1")
-			if err != nil {
-				errs <- fmt.Errorf("t1 scan %d: %w", i, err)
-				return
-			}
-			for {
-				if _, ok := it.Next(); !ok {
-					break
-				}
-			}
-			it.Close()
+	// Create empty table WAL.
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, _ := os.Create(tablePath)
+	writeWALHeader(tf)
+	tf.Close()
+
+	// Open with migrate=true — should migrate v2→v3.
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer eng.Close()
+
+	def, ok := eng.GetTable("users")
+	if !ok {
+		t.Fatal("table not found after migration")
+	}
+	if len(def.Columns) != 2 {
+		t.Fatalf("got %d columns, want 2", len(def.Columns))
+	}
+	// Ordinals should be assigned sequentially by migration.
+	if def.Columns[0].Ordinal != 0 {
+		t.Errorf("col[0].Ordinal = %d, want 0", def.Columns[0].Ordinal)
+	}
+	if def.Columns[1].Ordinal != 1 {
+		t.Errorf("col[1].Ordinal = %d, want 1", def.Columns[1].Ordinal)
+	}
+	if def.NextOrdinal != 2 {
+		t.Errorf("NextOrdinal = %d, want 2", def.NextOrdinal)
+	}
+}
+
+func TestEngine_MigrateV3ToV4(t *testing.T) {
+	dir := tempDir(t)
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
+
+	// Write a v3 catalog WAL manually.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write v3 header.
+	var hdr [6]byte
+	copy(hdr[:4], "MWAL")
+	hdr[4] = 0
+	hdr[5] = 3
+	f.Write(hdr[:])
+
+	// Write a v3 CREATE TABLE entry (with ordinals, no notNull).
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, 2) // 2 columns
+	// col 0: id INTEGER PRIMARY KEY
+	buf = encodeString(buf, "id")
+	buf = append(buf, byte(TypeInteger))
+	buf = append(buf, 1) // pk = true
+	buf = appendUint16(buf, 0)
+	// col 1: name TEXT
+	buf = encodeString(buf, "name")
+	buf = append(buf, byte(TypeText))
+	buf = append(buf, 0) // pk = false
+	buf = appendUint16(buf, 1)
+	writeRawEntry(f, 1, buf) // opCreateTable = 1
+	f.Close()
+
+	// Create empty table WAL.
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, _ := os.Create(tablePath)
+	writeWALHeader(tf)
+	tf.Close()
+
+	// Open with migrate=true — should migrate v3→v4.
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer eng.Close()
+
+	def, ok := eng.GetTable("users")
+	if !ok {
+		t.Fatal("table not found after migration")
+	}
+	if len(def.Columns) != 2 {
+		t.Fatalf("got %d columns, want 2", len(def.Columns))
+	}
+	// PK column should have NotNull=true after migration.
+	if !def.Columns[0].NotNull {
+		t.Error("col[0].NotNull = false, want true (PK column)")
+	}
+	// Non-PK column should have NotNull=false after migration.
+	if def.Columns[1].NotNull {
+		t.Error("col[1].NotNull = true, want false (non-PK column)")
+	}
+}
+
+func TestEngine_MigrateV4ToV5(t *testing.T) {
+	dir := tempDir(t)
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
+
+	// Write a v4 catalog WAL manually: CREATE TABLE then ADD COLUMN.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{'M', 'W', 'A', 'L', 0, 4})
+
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, 2)
+	buf = encodeString(buf, "id")
+	buf = append(buf, byte(TypeInteger), 1, 1) // pk, notNull
+	buf = appendUint16(buf, 0)
+	buf = encodeString(buf, "name")
+	buf = append(buf, byte(TypeText), 0, 0)
+	buf = appendUint16(buf, 1)
+	writeRawEntry(f, opCreateTable, buf)
+
+	buf = encodeString(nil, "users")
+	buf = encodeString(buf, "age")
+	buf = append(buf, byte(TypeInteger), 0, 0)
+	buf = appendUint16(buf, 2)
+	writeRawEntry(f, opAddColumn, buf)
+	f.Close()
+
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, _ := os.Create(tablePath)
+	tf.Write([]byte{'M', 'W', 'A', 'L', 0, 4})
+	tf.Close()
+
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer eng.Close()
+
+	def, ok := eng.GetTable("users")
+	if !ok {
+		t.Fatal("table not found after migration")
+	}
+	if len(def.Columns) != 3 || def.Columns[2].Name != "age" || def.Columns[2].Ordinal != 2 {
+		t.Fatalf("columns = %+v, want id, name, age", def.Columns)
+	}
+	for _, c := range def.Columns {
+		if c.Default != "" {
+			t.Errorf("column %s default = %q, want none", c.Name, c.Default)
 		}
-	}()
+	}
+	if !def.Columns[0].PrimaryKey || !def.Columns[0].NotNull {
+		t.Error("id lost its PK/NOT NULL flags in migration")
+	}
+}
 
-	// Reader of t2.
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for i := 0; i < ops; i++ {
-			it, err := eng.Scan("t2")
-			if err != nil {
-				errs <- fmt.Errorf("t2 scan %d: %w", i, err)
-				return
-			}
-			for {
-				if _, ok := it.Next(); !ok {
-					break
-				}
-			}
-			it.Close()
+func TestEngine_MigrateV5ToV6(t *testing.T) {
+	dir := tempDir(t)
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
+
+	// Write a v5 catalog WAL manually: CREATE TABLE then ADD COLUMN.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{'M', 'W', 'A', 'L', 0, 5})
+
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, 2)
+	buf = encodeString(buf, "id")
+	buf = append(buf, byte(TypeInteger), 1, 1) // pk, notNull
+	buf = appendUint16(buf, 0)
+	buf = encodeString(buf, "")
+	buf = encodeString(buf, "status")
+	buf = append(buf, byte(TypeText), 0, 0)
+	buf = appendUint16(buf, 1)
+	buf = encodeString(buf, "'new'")
+	writeRawEntry(f, opCreateTable, buf)
+
+	buf = encodeString(nil, "users")
+	buf = encodeString(buf, "age")
+	buf = append(buf, byte(TypeInteger), 0, 0)
+	buf = appendUint16(buf, 2)
+	buf = encodeString(buf, "0")
+	writeRawEntry(f, opAddColumn, buf)
+	f.Close()
+
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, _ := os.Create(tablePath)
+	tf.Write([]byte{'M', 'W', 'A', 'L', 0, 5})
+	tf.Close()
+
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer eng.Close()
+
+	def, ok := eng.GetTable("users")
+	if !ok {
+		t.Fatal("table not found after migration")
+	}
+	if len(def.Columns) != 3 || def.Columns[1].Default != "'new'" || def.Columns[2].Default != "0" {
+		t.Fatalf("columns = %+v, want id, status DEFAULT 'new', age DEFAULT 0", def.Columns)
+	}
+	for _, c := range def.Columns {
+		if c.Precision != 0 || c.Scale != 0 {
+			t.Errorf("column %s = (%d,%d), want no precision/scale", c.Name, c.Precision, c.Scale)
 		}
-	}()
+	}
+}
+
+func TestEngine_MigrateV6ToV7(t *testing.T) {
+	dir := tempDir(t)
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
+
+	// Write a v6 catalog WAL manually: CREATE TABLE then ADD COLUMN.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{'M', 'W', 'A', 'L', 0, 6})
+
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, 1)
+	buf = encodeString(buf, "id")
+	buf = append(buf, byte(TypeInteger), 1, 1) // pk, notNull
+	buf = appendUint16(buf, 0)
+	buf = encodeString(buf, "")
+	buf = append(buf, 0, 0, 0, 0)
+	writeRawEntry(f, opCreateTable, buf)
+
+	buf = encodeString(nil, "users")
+	buf = encodeString(buf, "age")
+	buf = append(buf, byte(TypeInteger), 0, 0)
+	buf = appendUint16(buf, 1)
+	buf = encodeString(buf, "0")
+	buf = append(buf, 0, 0, 0, 0)
+	writeRawEntry(f, opAddColumn, buf)
+	f.Close()
 
-	wg.Wait()
-	close(errs)
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, _ := os.Create(tablePath)
+	tf.Write([]byte{'M', 'W', 'A', 'L', 0, 6})
+	tf.Close()
 
-	for err := range errs {
-		t.Error(err)
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
 	}
+	defer eng.Close()
 
-	// Verify final counts.
-	rows1 := collectRows(t, must(eng.Scan("t1")))
-	if len(rows1) != ops {
-		t.Errorf("t1 rows = %d, want %d", len(rows1), ops)
+	def, ok := eng.GetTable("users")
+	if !ok {
+		t.Fatal("table not found after migration")
 	}
-	rows2 := collectRows(t, must(eng.Scan("t2")))
-	if len(rows2) != ops {
-		t.Errorf("t2 rows = %d, want %d", len(rows2), ops)
+	if len(def.Columns) != 2 || def.Columns[1].Default != "0" {
+		t.Fatalf("columns = %+v, want id, age DEFAULT 0", def.Columns)
+	}
+	// Rows that predate a v6 ADD COLUMN read as NULL, not as the default.
+	if def.Columns[1].Fill != nil {
+		t.Errorf("age fill = %v, want nil", def.Columns[1].Fill)
 	}
 }
 
-func TestEngine_SplitWAL_DropWhileOtherTableActive(t *testing.T) {
+func TestEngine_MigrateV7ToV8(t *testing.T) {
 	dir := tempDir(t)
-	eng := openEngine(t, dir)
-	defer eng.Close()
-
-	eng.CreateTable("keep", []ColumnDef{{Name: "v", DataType: TypeInteger}})
-	eng.CreateTable("drop_me", []ColumnDef{{Name: "v", DataType: TypeInteger}})
-	eng.Insert("keep", nil, [][]any{{int64(1)}})
-	eng.Insert("drop_me", nil, [][]any{{int64(2)}})
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
 
-	// Drop one table.
-	if err := eng.DropTable("drop_me"); err != nil {
+	// Write a v7 catalog WAL manually: CREATE TABLE then a single-column
+	// CREATE INDEX, whose payload names one column without a count.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
+	if err != nil {
 		t.Fatal(err)
 	}
+	f.Write([]byte{'M', 'W', 'A', 'L', 0, 7})
 
-	// The other table should still work fine.
-	rows := collectRows(t, must(eng.Scan("keep")))
-	if len(rows) != 1 {
-		t.Errorf("keep rows = %d, want 1", len(rows))
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, 2)
+	buf = encodeString(buf, "id")
+	buf = append(buf, byte(TypeInteger), 1, 1)
+	buf = appendUint16(buf, 0)
+	buf = encodeString(buf, "")
+	buf = append(buf, 0, 0, 0, 0)
+	buf = encodeString(buf, "email")
+	buf = append(buf, byte(TypeText), 0, 0)
+	buf = appendUint16(buf, 0)
+	buf = encodeString(buf, "")
+	buf = append(buf, 0, 0, 0, 0)
+	writeRawEntry(f, opCreateTable, buf)
+
+	buf = encodeString(nil, "users")
+	buf = encodeString(buf, "idx_email")
+	buf = encodeString(buf, "email")
+	buf = append(buf, 1)
+	writeRawEntry(f, opCreateIndex, buf)
+	f.Close()
+
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, _ := os.Create(tablePath)
+	tf.Write([]byte{'M', 'W', 'A', 'L', 0, 7})
+	tf.Close()
+
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
 	}
+	defer eng.Close()
 
-	// DML on dropped table should fail.
-	_, err := eng.Scan("drop_me")
-	if err == nil {
-		t.Error("scan on dropped table should fail")
+	def, _ := eng.GetTable("users")
+	idx := def.Indexes
+	if len(idx) != 1 || idx[0].Name != "idx_email" || !idx[0].Unique ||
+		len(idx[0].Columns) != 1 || idx[0].Columns[0] != "email" {
+		t.Fatalf("indexes = %+v, want unique idx_email on (email)", idx)
 	}
 }
 
-// -------------------------------------------------------------------------
-// Helpers
-// -------------------------------------------------------------------------
+func TestEngine_MigrateV8ToV9(t *testing.T) {
+	dir := tempDir(t)
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
 
-func must[T any](v T, err error) T {
+	// Write a v8 catalog WAL manually: its CREATE TABLE payload ends after
+	// the columns, without a foreign key list.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
 	}
-	return v
-}
+	f.Write([]byte{'M', 'W', 'A', 'L', 0, 8})
 
-// -------------------------------------------------------------------------
-// Benchmarks
-// -------------------------------------------------------------------------
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, 1)
+	buf = encodeString(buf, "id")
+	buf = append(buf, byte(TypeInteger), 1, 1)
+	buf = appendUint16(buf, 0)
+	buf = encodeString(buf, "")
+	buf = append(buf, 0, 0, 0, 0)
+	writeRawEntry(f, opCreateTable, buf)
+	f.Close()
 
-func BenchmarkSumScan(b *testing.B) {
-	const rowCount = 10_000_000
-	def := TableDef{Name: "bench", Columns: []ColumnDef{{Name: "val", DataType: TypeInteger}}}
-	h := newTableHeap(def)
-	rng := rand.New(rand.NewSource(42))
-	for i := 0; i < rowCount; i++ {
-		h.insertWithID(int64(i+1), []any{int64(rng.Intn(6))})
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, _ := os.Create(tablePath)
+	tf.Write([]byte{'M', 'W', 'A', 'L', 0, 8})
+	tf.Close()
+
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
 	}
-	b.ResetTimer()
-	for n := 0; n < b.N; n++ {
-		it := h.scan()
-		var sum int64
-		for {
-			row, ok := it.Next()
-			if !ok {
-				break
-			}
-			sum += row.Values[0].(int64)
-		}
-		it.Close()
-		_ = sum
+	defer eng.Close()
+
+	def, ok := eng.GetTable("users")
+	if !ok {
+		t.Fatal("table not found after migration")
+	}
+	if len(def.Columns) != 1 || def.Columns[0].References != nil {
+		t.Fatalf("columns = %+v, want id without a foreign key", def.Columns)
 	}
 }
 
-// -------------------------------------------------------------------------
-// ALTER TABLE (ADD COLUMN / DROP COLUMN)
-// -------------------------------------------------------------------------
-
-func TestEngine_AddColumn(t *testing.T) {
+func TestEngine_ForeignKeyReplay(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
+	eng.CreateTable("parent", []ColumnDef{{Name: "id", DataType: TypeInteger, PrimaryKey: true}})
+	eng.CreateTable("child", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "note", DataType: TypeText},
+		{Name: "pid", DataType: TypeInteger, References: &ForeignKey{Table: "parent", Column: "id", OnDelete: FKSetNull}},
+	})
+	eng.Close()
+
+	eng = openEngine(t, dir)
 	defer eng.Close()
+	def, _ := eng.GetTable("child")
+	if def.Columns[0].References != nil || def.Columns[1].References != nil {
+		t.Errorf("unexpected foreign key on %+v", def.Columns[:2])
+	}
+	fk := def.Columns[2].References
+	if fk == nil || *fk != (ForeignKey{Table: "parent", Column: "id", OnDelete: FKSetNull}) {
+		t.Errorf("pid references %+v, want parent(id) ON DELETE SET NULL", fk)
+	}
+}
 
-	eng.CreateTable("t", []ColumnDef{
-		{Name: "id", DataType: TypeInteger},
-	})
-	eng.Insert("t", nil, [][]any{{int64(1)}})
+func TestEngine_MigrateV9ToV10(t *testing.T) {
+	dir := tempDir(t)
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
 
-	// Add a column.
-	if err := eng.AddColumn("t", ColumnDef{Name: "name", DataType: TypeText}); err != nil {
+	// Write a v9 catalog WAL manually: its CREATE TABLE payload ends after
+	// the foreign key list, without an identity column list.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
+	if err != nil {
 		t.Fatal(err)
 	}
+	f.Write([]byte{'M', 'W', 'A', 'L', 0, 9})
 
-	// Old rows should return NULL for the new column.
-	rows := collectRows(t, must(eng.Scan("t")))
-	if len(rows) != 1 {
-		t.Fatalf("got %d rows, want 1", len(rows))
-	}
-	// New column ordinal = 1, row length = 1 (short row), so RowValue should return nil.
-	if RowValue(rows[0].Values, 1) != nil {
-		t.Errorf("new column = %v, want nil", RowValue(rows[0].Values, 1))
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, 1)
+	buf = encodeString(buf, "id")
+	buf = append(buf, byte(TypeInteger), 1, 1)
+	buf = appendUint16(buf, 0)
+	buf = encodeString(buf, "")
+	buf = append(buf, 0, 0, 0, 0)
+	buf = appendUint16(buf, 0) // no foreign keys
+	writeRawEntry(f, opCreateTable, buf)
+	f.Close()
+
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, _ := os.Create(tablePath)
+	tf.Write([]byte{'M', 'W', 'A', 'L', 0, 9})
+	tf.Close()
+
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
 	}
+	defer eng.Close()
 
-	// Insert with the new column.
-	eng.Insert("t", []string{"id", "name"}, [][]any{{int64(2), "alice"}})
-	rows = collectRows(t, must(eng.Scan("t")))
-	if len(rows) != 2 {
-		t.Fatalf("got %d rows, want 2", len(rows))
+	def, ok := eng.GetTable("users")
+	if !ok {
+		t.Fatal("table not found after migration")
+	}
+	if len(def.Columns) != 1 || def.Columns[0].Identity != IdentityNone {
+		t.Fatalf("columns = %+v, want id without identity", def.Columns)
 	}
 }
 
-func TestEngine_DropColumn(t *testing.T) {
+func TestEngine_MigrateV10ToV11(t *testing.T) {
 	dir := tempDir(t)
-	eng := openEngine(t, dir)
-	defer eng.Close()
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
 
-	eng.CreateTable("t", []ColumnDef{
-		{Name: "id", DataType: TypeInteger},
-		{Name: "name", DataType: TypeText},
-	})
-	eng.Insert("t", nil, [][]any{{int64(1), "alice"}})
+	// Write a v10 catalog and table WAL manually: the TRUNCATE payload
+	// ends after the table name, without the RESTART IDENTITY flag.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{'M', 'W', 'A', 'L', 0, 10})
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, 1)
+	buf = encodeString(buf, "id")
+	buf = append(buf, byte(TypeInteger), 1, 1)
+	buf = appendUint16(buf, 0)
+	buf = encodeString(buf, "")
+	buf = append(buf, 0, 0, 0, 0)
+	buf = appendUint16(buf, 0) // no foreign keys
+	buf = appendUint16(buf, 1) // one identity column
+	buf = appendUint16(buf, 0)
+	buf = append(buf, byte(IdentityByDefault))
+	writeRawEntry(f, opCreateTable, buf)
+	f.Close()
 
-	if err := eng.DropColumn("t", "name"); err != nil {
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, err := os.Create(tablePath)
+	if err != nil {
 		t.Fatal(err)
 	}
+	tf.Write([]byte{'M', 'W', 'A', 'L', 0, 10})
+	ins := encodeString(nil, "users")
+	ins = binary.BigEndian.AppendUint64(ins, 1)
+	ins = encodeValues(ins, []any{int64(7)})
+	writeRawEntry(tf, opInsert, ins)
+	writeRawEntry(tf, opTruncate, encodeString(nil, "users"))
+	tf.Close()
 
-	// Schema should only have one column.
-	def, ok := eng.GetTable("t")
-	if !ok {
-		t.Fatal("table not found")
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
 	}
-	if len(def.Columns) != 1 {
-		t.Fatalf("got %d columns, want 1", len(def.Columns))
+	defer eng.Close()
+
+	if n, _ := eng.RowCount("users"); n != 0 {
+		t.Fatalf("row count = %d, want 0", n)
 	}
-	if def.Columns[0].Name != "id" {
-		t.Errorf("remaining column = %q, want id", def.Columns[0].Name)
+	// The truncate continued the identity sequence.
+	rows, err := eng.InsertReturning("users", nil, [][]any{{nil}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Values[0] != int64(8) {
+		t.Fatalf("id after migration = %v, want 8", rows[0].Values[0])
 	}
 }
 
-func TestEngine_DropColumn_PK_Error(t *testing.T) {
+func TestEngine_MigrateV11ToV12(t *testing.T) {
 	dir := tempDir(t)
-	eng := openEngine(t, dir)
+	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
+
+	// Write a v11 catalog WAL manually: the CREATE TABLE payload ends after
+	// the identity columns and the ADD COLUMN payload after the fill value,
+	// both without dictionary columns.
+	catPath := filepath.Join(dir, "catalog.wal")
+	f, err := os.Create(catPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{'M', 'W', 'A', 'L', 0, 11})
+	buf := encodeString(nil, "users")
+	buf = appendUint16(buf, 1)
+	buf = encodeString(buf, "id")
+	buf = append(buf, byte(TypeInteger), 1, 1)
+	buf = appendUint16(buf, 0)
+	buf = encodeString(buf, "")
+	buf = append(buf, 0, 0, 0, 0)
+	buf = appendUint16(buf, 0) // no foreign keys
+	buf = appendUint16(buf, 0) // no identity columns
+	writeRawEntry(f, opCreateTable, buf)
+
+	buf = encodeString(nil, "users")
+	buf = encodeString(buf, "role")
+	buf = append(buf, byte(TypeText), 0, 0)
+	buf = appendUint16(buf, 1)
+	buf = encodeString(buf, "")
+	buf = append(buf, 0, 0, 0, 0)
+	buf = encodeValue(buf, "guest")
+	writeRawEntry(f, opAddColumn, buf)
+	f.Close()
+
+	tablePath := filepath.Join(dir, "tables", "users.wal")
+	tf, _ := os.Create(tablePath)
+	tf.Write([]byte{'M', 'W', 'A', 'L', 0, 11})
+	tf.Close()
+
+	eng, err := Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
 	defer eng.Close()
 
+	def, _ := eng.GetTable("users")
+	if len(def.Columns) != 2 || def.Columns[0].Dictionary || def.Columns[1].Dictionary || def.Columns[1].Fill != "guest" {
+		t.Fatalf("columns = %+v, want id and role DEFAULT guest, neither dictionary-encoded", def.Columns)
+	}
+}
+
+func TestEngine_IdentityGeneratesValues(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
+	defer eng.Close()
 	eng.CreateTable("t", []ColumnDef{
-		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true, NotNull: true, Identity: IdentityByDefault},
 		{Name: "name", DataType: TypeText},
 	})
 
-	err := eng.DropColumn("t", "id")
-	if err == nil {
-		t.Fatal("expected error for dropping PK column")
+	rows, err := eng.InsertReturning("t", []string{"name"}, [][]any{{"a"}, {"b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Values[0] != int64(1) || rows[1].Values[0] != int64(2) {
+		t.Fatalf("generated ids = %v, %v, want 1, 2", rows[0].Values[0], rows[1].Values[0])
+	}
+
+	// A given value is kept, and generation continues above it.
+	if _, err := eng.Insert("t", []string{"id", "name"}, [][]any{{int64(10), "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err = eng.InsertReturning("t", []string{"name"}, [][]any{{"d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Values[0] != int64(11) {
+		t.Fatalf("id after explicit 10 = %v, want 11", rows[0].Values[0])
 	}
 }
 
-func TestEngine_DropColumn_LastColumn_Error(t *testing.T) {
-	dir := tempDir(t)
-	eng := openEngine(t, dir)
+func TestEngine_IdentityAlwaysRejectsValue(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
 	defer eng.Close()
-
 	eng.CreateTable("t", []ColumnDef{
-		{Name: "id", DataType: TypeInteger},
+		{Name: "id", DataType: TypeInteger, NotNull: true, Identity: IdentityAlways},
+		{Name: "name", DataType: TypeText},
 	})
 
-	err := eng.DropColumn("t", "id")
-	if err == nil {
-		t.Fatal("expected error for dropping last column")
+	_, err := eng.Insert("t", []string{"id", "name"}, [][]any{{int64(5), "a"}})
+	var ga *GeneratedAlwaysError
+	if !errors.As(err, &ga) {
+		t.Fatalf("err = %v, want GeneratedAlwaysError", err)
 	}
 }
 
-func TestEngine_AddColumn_Duplicate_Error(t *testing.T) {
+func TestEngine_IdentityReplay(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
-	defer eng.Close()
-
 	eng.CreateTable("t", []ColumnDef{
-		{Name: "id", DataType: TypeInteger},
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true, NotNull: true, Identity: IdentityByDefault},
+		{Name: "name", DataType: TypeText},
 	})
+	eng.Insert("t", []string{"name"}, [][]any{{"a"}, {"b"}, {"c"}})
+	// Deleting the newest row must not make its id available again.
+	eng.Delete("t", func(r Row) bool { return r.Values[0] == int64(3) })
+	eng.Close()
 
-	err := eng.AddColumn("t", ColumnDef{Name: "id", DataType: TypeText})
-	if err == nil {
-		t.Fatal("expected error for duplicate column")
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	def, _ := eng.GetTable("t")
+	if def.Columns[0].Identity != IdentityByDefault || def.Columns[1].Identity != IdentityNone {
+		t.Fatalf("identity = %v, %v, want BY DEFAULT, none", def.Columns[0].Identity, def.Columns[1].Identity)
 	}
-	var colExists *ColumnExistsError
-	if !errors.As(err, &colExists) {
-		t.Errorf("got error %T, want *ColumnExistsError", err)
+	rows, err := eng.InsertReturning("t", []string{"name"}, [][]any{{"d"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Values[0] != int64(4) {
+		t.Fatalf("id after restart = %v, want 4", rows[0].Values[0])
 	}
 }
 
-func TestEngine_DropColumn_NotFound_Error(t *testing.T) {
+func TestEngine_TruncateIdentity(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
-	defer eng.Close()
-
 	eng.CreateTable("t", []ColumnDef{
-		{Name: "id", DataType: TypeInteger},
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true, NotNull: true, Identity: IdentityByDefault},
+		{Name: "name", DataType: TypeText},
 	})
+	nextID := func(name string) any {
+		t.Helper()
+		rows, err := eng.InsertReturning("t", []string{"name"}, [][]any{{name}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows[0].Values[0]
+	}
+	nextID("a")
+	nextID("b")
 
-	err := eng.DropColumn("t", "nonexistent")
-	if err == nil {
-		t.Fatal("expected error for dropping non-existent column")
+	// CONTINUE IDENTITY keeps the sequence where it was.
+	if err := eng.Truncate([]string{"t"}, false); err != nil {
+		t.Fatal(err)
 	}
-}
+	if id := nextID("c"); id != int64(3) {
+		t.Fatalf("id after truncate = %v, want 3", id)
+	}
+	// RESTART IDENTITY starts it over.
+	if err := eng.Truncate([]string{"t"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if id := nextID("d"); id != int64(1) {
+		t.Fatalf("id after truncate restart identity = %v, want 1", id)
+	}
+	eng.Close()
 
-func TestEngine_AddDropColumn_WAL_Replay(t *testing.T) {
-	dir := tempDir(t)
+	// Replay restarts the sequence at the same point.
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	if id := nextID("e"); id != int64(2) {
+		t.Fatalf("id after restart = %v, want 2", id)
+	}
+}
 
-	// Create, insert, add column, insert again, drop column.
-	eng := openEngine(t, dir)
+func TestEngine_ScanPKRange(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
+	defer eng.Close()
 	eng.CreateTable("t", []ColumnDef{
-		{Name: "a", DataType: TypeInteger},
-		{Name: "b", DataType: TypeText},
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "v", DataType: TypeText},
 	})
-	eng.Insert("t", nil, [][]any{{int64(1), "x"}})
-	eng.AddColumn("t", ColumnDef{Name: "c", DataType: TypeInteger})
-	eng.Insert("t", []string{"a", "b", "c"}, [][]any{{int64(2), "y", int64(42)}})
-	eng.DropColumn("t", "b")
-	eng.Close()
+	eng.CreateTable("nopk", []ColumnDef{{Name: "v", DataType: TypeText}})
+	for _, id := range []int64{40, 10, 30, 20, 50} {
+		eng.Insert("t", nil, [][]any{{id, "x"}})
+	}
 
-	// Reopen and verify.
-	eng = openEngine(t, dir)
+	ids := func(lo, hi *KeyBound) []any {
+		t.Helper()
+		var out []any
+		for _, r := range collectRows(t, must(eng.ScanPKRange("t", lo, hi))) {
+			out = append(out, r.Values[0])
+		}
+		return out
+	}
+	if got := ids(&KeyBound{Value: int64(20)}, &KeyBound{Value: int64(50)}); !slices.Equal(got, []any{int64(30), int64(40)}) {
+		t.Errorf("(20, 50) = %v, want [30 40]", got)
+	}
+	if got := ids(nil, &KeyBound{Value: int64(30), Inclusive: true}); !slices.Equal(got, []any{int64(10), int64(20), int64(30)}) {
+		t.Errorf("[.., 30] = %v, want [10 20 30]", got)
+	}
+	if got := ids(nil, nil); len(got) != 5 {
+		t.Errorf("unbounded = %v, want all 5 keys", got)
+	}
+	if _, err := eng.ScanPKRange("nopk", nil, nil); err == nil {
+		t.Error("expected error for table without primary key")
+	}
+}
+
+func TestEngine_LookupByIndexRange(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
 	defer eng.Close()
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "score", DataType: TypeInteger},
+		{Name: "code", DataType: TypeText},
+	})
+	eng.CreateIndex("t", IndexDef{Name: "idx_score", Columns: []string{"score"}})
+	eng.CreateIndex("t", IndexDef{Name: "idx_code", Columns: []string{"code"}, Unique: true})
+	eng.Insert("t", nil, [][]any{
+		{int64(1), int64(30), "c"},
+		{int64(2), int64(10), "a"},
+		{int64(3), int64(30), "e"},
+		{int64(4), int64(20), "b"},
+		{int64(5), nil, "d"},
+	})
 
-	def, ok := eng.GetTable("t")
-	if !ok {
-		t.Fatal("table not found after replay")
+	ids := func(e Engine, index string, lo, hi *KeyBound) []int64 {
+		t.Helper()
+		rows, err := e.LookupByIndexRange("t", index, lo, hi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []int64
+		for _, r := range rows {
+			out = append(out, r.ID)
+		}
+		return out
 	}
-	if len(def.Columns) != 2 {
-		t.Fatalf("got %d columns, want 2 (a, c)", len(def.Columns))
+	// Equal keys come in row ID order; the NULL score is not indexed.
+	if got := ids(eng, "idx_score", nil, nil); !slices.Equal(got, []int64{2, 4, 1, 3}) {
+		t.Errorf("idx_score unbounded = %v, want [2 4 1 3]", got)
 	}
-	if def.Columns[0].Name != "a" || def.Columns[1].Name != "c" {
-		t.Errorf("columns = [%s, %s], want [a, c]", def.Columns[0].Name, def.Columns[1].Name)
+	if got := ids(eng, "idx_score", &KeyBound{Value: int64(10)}, &KeyBound{Value: int64(30), Inclusive: true}); !slices.Equal(got, []int64{4, 1, 3}) {
+		t.Errorf("idx_score (10, 30] = %v, want [4 1 3]", got)
+	}
+	if got := ids(eng, "idx_code", &KeyBound{Value: "b", Inclusive: true}, &KeyBound{Value: "d"}); !slices.Equal(got, []int64{4, 1}) {
+		t.Errorf("idx_code [b, d) = %v, want [4 1]", got)
 	}
 
-	rows := collectRows(t, must(eng.Scan("t")))
-	if len(rows) != 2 {
-		t.Fatalf("got %d rows, want 2", len(rows))
+	// A transaction sees its own changes, still in key order.
+	tx := NewTxEngine(eng)
+	tx.Insert("t", nil, [][]any{{int64(6), int64(15), "f"}})
+	tx.Update("t", map[string]any{"score": int64(25)}, func(r Row) bool { return r.Values[0] == int64(1) })
+	tx.Delete("t", func(r Row) bool { return r.Values[0] == int64(4) })
+	if got := ids(tx, "idx_score", &KeyBound{Value: int64(10), Inclusive: true}, nil); !slices.Equal(got, []int64{2, 6, 1, 3}) {
+		t.Errorf("idx_score in transaction = %v, want [2 6 1 3]", got)
+	}
+	if got := ids(eng, "idx_score", &KeyBound{Value: int64(10), Inclusive: true}, nil); !slices.Equal(got, []int64{2, 4, 1, 3}) {
+		t.Errorf("idx_score outside transaction = %v, want [2 4 1 3]", got)
 	}
 }
 
-func TestEngine_MigrateV2ToV3(t *testing.T) {
-	dir := tempDir(t)
-	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
+func TestTxEngine_LookupByIndex_UpdatedKey(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
+	defer eng.Close()
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "score", DataType: TypeInteger},
+	})
+	eng.CreateIndex("t", IndexDef{Name: "idx_score", Columns: []string{"score"}})
+	eng.Insert("t", nil, [][]any{
+		{int64(1), int64(10)},
+		{int64(2), int64(20)},
+		{int64(3), int64(10)},
+	})
 
-	// Write a v2 catalog WAL manually.
-	catPath := filepath.Join(dir, "catalog.wal")
-	f, err := os.Create(catPath)
+	tx := NewTxEngine(eng)
+	tx.Update("t", map[string]any{"score": int64(10)}, func(r Row) bool { return r.Values[0] == int64(2) })
+	tx.Update("t", map[string]any{"score": int64(30)}, func(r Row) bool { return r.Values[0] == int64(3) })
+	rows, err := tx.LookupByIndex("t", "idx_score", int64(10))
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Write v2 header.
-	var hdr [6]byte
-	copy(hdr[:4], "MWAL")
-	hdr[4] = 0
-	hdr[5] = 2
-	f.Write(hdr[:])
+	var got []int64
+	for _, r := range rows {
+		got = append(got, r.ID)
+	}
+	// Row 2 moved into the key and row 3 out of it.
+	if !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("score = 10 in transaction = %v, want [1 2]", got)
+	}
+}
 
-	// Write a v2 CREATE TABLE entry (no ordinals).
-	cols := []ColumnDef{
+func TestEngine_CompositeIndex(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	eng.CreateTable("orders", []ColumnDef{
 		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
-		{Name: "name", DataType: TypeText},
+		{Name: "user_id", DataType: TypeInteger},
+		{Name: "status", DataType: TypeText},
+	})
+	if err := eng.CreateIndex("orders", IndexDef{Name: "idx_user_status", Columns: []string{"user_id", "status"}}); err != nil {
+		t.Fatal(err)
 	}
-	buf := encodeString(nil, "users")
-	buf = appendUint16(buf, uint16(len(cols)))
-	for _, col := range cols {
-		buf = encodeString(buf, col.Name)
-		buf = append(buf, byte(col.DataType))
-		var pk byte
-		if col.PrimaryKey {
-			pk = 1
+	if err := eng.CreateIndex("orders", IndexDef{Name: "idx_bad", Columns: []string{"user_id", "nope"}}); err == nil {
+		t.Fatal("expected error for unknown column in composite index")
+	}
+	eng.Insert("orders", nil, [][]any{
+		{int64(1), int64(7), "open"},
+		{int64(2), int64(7), "shipped"},
+		{int64(3), int64(7), "open"},
+		{int64(4), int64(8), "open"},
+		{int64(5), int64(7), nil},
+	})
+
+	check := func(phase string) {
+		t.Helper()
+		rows, err := eng.LookupByIndex("orders", "idx_user_status", []any{int64(7), "open"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 2 {
+			t.Errorf("%s: (7, open) = %d rows, want 2", phase, len(rows))
+		}
+		if rows, _ := eng.LookupByIndex("orders", "idx_user_status", []any{int64(8), "shipped"}); len(rows) != 0 {
+			t.Errorf("%s: (8, shipped) = %v, want none", phase, rows)
+		}
+		// A key with a NULL component is not indexed.
+		if rows, _ := eng.LookupByIndex("orders", "idx_user_status", []any{int64(7), nil}); len(rows) != 0 {
+			t.Errorf("%s: (7, NULL) = %v, want none", phase, rows)
+		}
+		// Bounds on a prefix of the columns cover every key starting with it.
+		prefix := &KeyBound{Value: []any{int64(7)}, Inclusive: true}
+		rows, err = eng.LookupByIndexRange("orders", "idx_user_status", prefix, prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rowIDs(rows); !slices.Equal(got, []int64{1, 3, 2}) {
+			t.Errorf("%s: prefix (7) = row IDs %v, want [1 3 2]", phase, got)
+		}
+		rows, _ = eng.LookupByIndexRange("orders", "idx_user_status", &KeyBound{Value: []any{int64(7)}}, nil)
+		if got := rowIDs(rows); !slices.Equal(got, []int64{4}) {
+			t.Errorf("%s: prefix > (7) = row IDs %v, want [4]", phase, got)
 		}
-		buf = append(buf, pk)
-		// v2 does NOT have ordinal — that's what migration adds
 	}
-	writeRawEntry(f, 1, buf) // opCreateTable = 1
-	f.Close()
+	check("before reopen")
+	eng.Close()
 
-	// Create empty table WAL.
-	tablePath := filepath.Join(dir, "tables", "users.wal")
-	tf, _ := os.Create(tablePath)
-	writeWALHeader(tf)
-	tf.Close()
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	check("after reopen")
 
-	// Open with migrate=true — should migrate v2→v3.
-	eng, err := Open(dir, true)
-	if err != nil {
-		t.Fatalf("Open: %v", err)
+	tx := NewTxEngine(eng)
+	if _, err := tx.Update("orders", map[string]any{"status": "shipped"}, func(r Row) bool { return r.Values[0] == int64(1) }); err != nil {
+		t.Fatal(err)
 	}
-	defer eng.Close()
+	if rows, _ := tx.LookupByIndex("orders", "idx_user_status", []any{int64(7), "open"}); len(rows) != 1 {
+		t.Errorf("tx: (7, open) = %d rows, want 1", len(rows))
+	}
+	prefix := &KeyBound{Value: []any{int64(7)}, Inclusive: true}
+	if rows, _ := tx.LookupByIndexRange("orders", "idx_user_status", prefix, prefix); !slices.Equal(rowIDs(rows), []int64{3, 1, 2}) {
+		t.Errorf("tx: prefix (7) = row IDs %v, want [3 1 2]", rowIDs(rows))
+	}
+}
 
-	def, ok := eng.GetTable("users")
-	if !ok {
-		t.Fatal("table not found after migration")
+// rowIDs returns the IDs of rows, in order.
+func rowIDs(rows []Row) []int64 {
+	ids := make([]int64, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
 	}
-	if len(def.Columns) != 2 {
-		t.Fatalf("got %d columns, want 2", len(def.Columns))
+	return ids
+}
+
+func TestEngine_CompositeUniqueIndex(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
+	defer eng.Close()
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "a", DataType: TypeInteger},
+		{Name: "b", DataType: TypeText},
+	})
+	eng.CreateIndex("t", IndexDef{Name: "idx_ab", Columns: []string{"a", "b"}, Unique: true})
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1), int64(1), "x"}, {int64(2), int64(1), "y"}}); err != nil {
+		t.Fatalf("pairs differing in one column: %v", err)
 	}
-	// Ordinals should be assigned sequentially by migration.
-	if def.Columns[0].Ordinal != 0 {
-		t.Errorf("col[0].Ordinal = %d, want 0", def.Columns[0].Ordinal)
+	// NULL components never conflict, as in PostgreSQL.
+	if _, err := eng.Insert("t", nil, [][]any{{int64(3), int64(1), nil}, {int64(4), int64(1), nil}}); err != nil {
+		t.Fatalf("NULL component: %v", err)
 	}
-	if def.Columns[1].Ordinal != 1 {
-		t.Errorf("col[1].Ordinal = %d, want 1", def.Columns[1].Ordinal)
+	_, err := eng.Insert("t", nil, [][]any{{int64(5), int64(1), "x"}})
+	var uv *UniqueViolationError
+	if !errors.As(err, &uv) {
+		t.Fatalf("err = %v, want UniqueViolationError", err)
 	}
-	if def.NextOrdinal != 2 {
-		t.Errorf("NextOrdinal = %d, want 2", def.NextOrdinal)
+	if !strings.Contains(uv.Error(), "(a, b)") {
+		t.Errorf("message = %q, want it to name (a, b)", uv.Error())
 	}
 }
 
-func TestEngine_MigrateV3ToV4(t *testing.T) {
+func TestEngine_ColumnDefaultPersisted(t *testing.T) {
 	dir := tempDir(t)
-	os.MkdirAll(filepath.Join(dir, "tables"), 0755)
-
-	// Write a v3 catalog WAL manually.
-	catPath := filepath.Join(dir, "catalog.wal")
-	f, err := os.Create(catPath)
-	if err != nil {
+	eng := openEngine(t, dir)
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "status", DataType: TypeText, Default: "'new'"},
+	}); err != nil {
 		t.Fatal(err)
 	}
-	// Write v3 header.
-	var hdr [6]byte
-	copy(hdr[:4], "MWAL")
-	hdr[4] = 0
-	hdr[5] = 3
-	f.Write(hdr[:])
-
-	// Write a v3 CREATE TABLE entry (with ordinals, no notNull).
-	buf := encodeString(nil, "users")
-	buf = appendUint16(buf, 2) // 2 columns
-	// col 0: id INTEGER PRIMARY KEY
-	buf = encodeString(buf, "id")
-	buf = append(buf, byte(TypeInteger))
-	buf = append(buf, 1) // pk = true
-	buf = appendUint16(buf, 0)
-	// col 1: name TEXT
-	buf = encodeString(buf, "name")
-	buf = append(buf, byte(TypeText))
-	buf = append(buf, 0) // pk = false
-	buf = appendUint16(buf, 1)
-	writeRawEntry(f, 1, buf) // opCreateTable = 1
-	f.Close()
-
-	// Create empty table WAL.
-	tablePath := filepath.Join(dir, "tables", "users.wal")
-	tf, _ := os.Create(tablePath)
-	writeWALHeader(tf)
-	tf.Close()
-
-	// Open with migrate=true — should migrate v3→v4.
-	eng, err := Open(dir, true)
-	if err != nil {
-		t.Fatalf("Open: %v", err)
+	if err := eng.AddColumn("t", ColumnDef{Name: "created", DataType: TypeTimestamp, Default: "NOW()"}); err != nil {
+		t.Fatal(err)
 	}
-	defer eng.Close()
+	eng.Close()
 
-	def, ok := eng.GetTable("users")
-	if !ok {
-		t.Fatal("table not found after migration")
-	}
-	if len(def.Columns) != 2 {
-		t.Fatalf("got %d columns, want 2", len(def.Columns))
-	}
-	// PK column should have NotNull=true after migration.
-	if !def.Columns[0].NotNull {
-		t.Error("col[0].NotNull = false, want true (PK column)")
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	def, _ := eng.GetTable("t")
+	if got := def.Columns[1].Default; got != "'new'" {
+		t.Errorf("status default = %q, want 'new'", got)
 	}
-	// Non-PK column should have NotNull=false after migration.
-	if def.Columns[1].NotNull {
-		t.Error("col[1].NotNull = true, want false (non-PK column)")
+	if got := def.Columns[2].Default; got != "NOW()" {
+		t.Errorf("created default = %q, want NOW()", got)
 	}
 }
 
@@ -1425,7 +3313,7 @@ func TestEngine_MemoryUsage(t *testing.T) {
 	eng.Insert("logs", nil, [][]any{{"hello"}})
 
 	// Create a secondary index on users.
-	eng.CreateIndex("users", IndexDef{Name: "idx_name", Column: "name", Unique: false})
+	eng.CreateIndex("users", IndexDef{Name: "idx_name", Columns: []string{"name"}, Unique: false})
 
 	infos = eng.MemoryUsage()
 	if len(infos) != 2 {
@@ -1476,3 +3364,57 @@ func TestEngine_MemoryUsage(t *testing.T) {
 func appendUint16(buf []byte, v uint16) []byte {
 	return append(buf, byte(v>>8), byte(v))
 }
+
+func TestEngine_Views(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+
+	eng.CreateTable("users", []ColumnDef{{Name: "id", DataType: TypeInteger, PrimaryKey: true}})
+	if err := eng.CreateView("v1", "SELECT id FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.CreateView("v2", "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	// Tables and views share one namespace.
+	var exists *TableExistsError
+	if err := eng.CreateView("users", "SELECT 1"); !errors.As(err, &exists) {
+		t.Errorf("CreateView over a table: err = %v, want TableExistsError", err)
+	}
+	if err := eng.CreateTable("v1", []ColumnDef{{Name: "id", DataType: TypeInteger}}); !errors.As(err, &exists) {
+		t.Errorf("CreateTable over a view: err = %v, want TableExistsError", err)
+	}
+	if err := eng.DropView("v2"); err != nil {
+		t.Fatal(err)
+	}
+	var notFound *ViewNotFoundError
+	if err := eng.DropView("v2"); !errors.As(err, &notFound) {
+		t.Errorf("DropView twice: err = %v, want ViewNotFoundError", err)
+	}
+	if err := eng.CreateView("long", "SELECT '"+strings.Repeat("x", 70000)+"'"); err == nil {
+		t.Error("expected error for a definition over 65535 bytes")
+	}
+	eng.Close()
+
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	v, ok := eng.GetView("v1")
+	if !ok || v.Query != "SELECT id FROM users" {
+		t.Fatalf("v1 after reopen = %+v, %v", v, ok)
+	}
+	if views := eng.ListViews(); len(views) != 1 {
+		t.Errorf("views after reopen = %d, want 1", len(views))
+	}
+	if _, ok := eng.GetTable("v1"); ok {
+		t.Error("a view must not be listed as a table")
+	}
+
+	tx := NewTxEngine(eng)
+	var active *ActiveTxError
+	if err := tx.CreateView("v3", "SELECT 1"); !errors.As(err, &active) {
+		t.Errorf("CreateView in a transaction: err = %v, want ActiveTxError", err)
+	}
+	if _, ok := tx.GetView("v1"); !ok {
+		t.Error("transaction does not see v1")
+	}
+}
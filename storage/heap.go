@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"maps"
+	"slices"
+
 	"mulldb/deepsize"
 	"mulldb/storage/index"
 )
@@ -8,39 +11,66 @@ import (
 // tableHeap holds the in-memory row data for a single table.
 // It is populated during WAL replay and modified by engine operations.
 //
-// Rows are stored in a dense array indexed by row ID. Deleted slots are
-// set to nil and pushed onto a free list for reuse by future inserts.
-// This eliminates the ~72 bytes per row of map bucket overhead that a
-// map[int64][]any would incur, since row IDs are sequential integers.
+// Rows are stored in a dense array indexed by row ID (see rowStore).
+// Deleted slots are freed and pushed onto a free list for reuse by future
+// inserts. This eliminates the ~72 bytes per row of map bucket overhead
+// that a map[int64][]any would incur, since row IDs are sequential
+// integers.
 type tableHeap struct {
 	def         TableDef
-	rows        [][]any   // indexed by rowID; nil = free slot
-	freeList    []int64   // stack of reusable row IDs from deletes
-	count       int       // number of live (non-nil) rows
-	nextID      int64     // next fresh ID (used when freeList empty)
+	rows        rowStore // indexed by rowID
+	freeList    []int64  // stack of reusable row IDs from deletes
+	count       int      // number of live rows
+	nextID      int64    // next fresh ID (used when freeList empty)
 	pkIdx       index.Index
 	pkCol       int
 	secondaries []secondaryIdx
+	sequences   map[int]*sequence // identity columns by ordinal
 }
 
 // secondaryIdx tracks a single secondary index on the table.
 type secondaryIdx struct {
-	def    IndexDef
-	colOrd int               // ordinal of the indexed column
-	unique index.Index       // non-nil for UNIQUE indexes
-	multi  index.MultiIndex  // non-nil for non-unique indexes
+	def     IndexDef
+	colOrds []int            // ordinals of the indexed columns
+	unique  index.Index      // non-nil for UNIQUE indexes
+	multi   index.MultiIndex // non-nil for non-unique indexes
+}
+
+// key returns the index key of a row: the column value for a single-column
+// index, the tuple of values ([]any) for a composite one. Rows with a NULL
+// in any indexed column are not indexed; key returns nil for them.
+func (si *secondaryIdx) key(values []any) any {
+	if len(si.colOrds) == 1 {
+		return RowValue(values, si.colOrds[0])
+	}
+	key := make([]any, len(si.colOrds))
+	for i, ord := range si.colOrds {
+		key[i] = RowValue(values, ord)
+		if key[i] == nil {
+			return nil
+		}
+	}
+	return key
 }
 
 func newTableHeap(def TableDef) *tableHeap {
 	h := &tableHeap{
 		def:    def,
-		rows:   [][]any{},
+		rows:   newRowStore(def),
 		nextID: 1,
 		pkCol:  def.PrimaryKeyColumn(),
 	}
 	if h.pkCol >= 0 {
 		h.pkIdx = index.NewBTree(CompareValues)
 	}
+	for _, col := range def.Columns {
+		if col.Identity != IdentityNone {
+			if h.sequences == nil {
+				h.sequences = make(map[int]*sequence)
+			}
+			h.sequences[col.Ordinal] = &sequence{}
+		}
+	}
 	return h
 }
 
@@ -66,29 +96,17 @@ func (h *tableHeap) pkColumnName() string {
 	return ""
 }
 
-// growRows extends the rows slice so that index id is valid.
-func (h *tableHeap) growRows(id int64) {
-	need := int(id) + 1
-	if need <= len(h.rows) {
-		return
-	}
-	if need <= cap(h.rows) {
-		h.rows = h.rows[:need]
-		return
-	}
-	// Grow with amortized doubling.
-	newCap := cap(h.rows) * 2
-	if newCap < need {
-		newCap = need
-	}
-	grown := make([][]any, need, newCap)
-	copy(grown, h.rows)
-	h.rows = grown
+// pkKey returns the primary key value of a row.
+func (h *tableHeap) pkKey(values []any) any {
+	return RowValue(values, h.pkCol)
 }
 
 // insertWithID stores a row with a specific ID (used by both live inserts
 // and WAL replay). Returns an error if the row violates a PK constraint.
 func (h *tableHeap) insertWithID(id int64, values []any) error {
+	if err := h.checkValues(values); err != nil {
+		return err
+	}
 	if h.pkIdx != nil {
 		key := RowValue(values, h.pkCol)
 		if key == nil {
@@ -108,7 +126,7 @@ func (h *tableHeap) insertWithID(id int64, values []any) error {
 	// Maintain secondary indexes.
 	for i := range h.secondaries {
 		si := &h.secondaries[i]
-		key := RowValue(values, si.colOrd)
+		key := si.key(values)
 		if key == nil {
 			continue // NULLs are not indexed
 		}
@@ -120,7 +138,7 @@ func (h *tableHeap) insertWithID(id int64, values []any) error {
 				}
 				for j := 0; j < i; j++ {
 					sj := &h.secondaries[j]
-					k := RowValue(values, sj.colOrd)
+					k := sj.key(values)
 					if k == nil {
 						continue
 					}
@@ -132,7 +150,7 @@ func (h *tableHeap) insertWithID(id int64, values []any) error {
 				}
 				return &UniqueViolationError{
 					Table:  h.def.Name,
-					Column: si.def.Column,
+					Column: si.def.ColumnList(),
 					Value:  key,
 					Index:  si.def.Name,
 				}
@@ -141,30 +159,28 @@ func (h *tableHeap) insertWithID(id int64, values []any) error {
 			si.multi.Put(key, id)
 		}
 	}
-	row := make([]any, len(values))
-	copy(row, values)
-	h.growRows(id)
-	h.rows[id] = row
+	h.rows.set(id, values)
 	h.count++
 	if id >= h.nextID {
 		h.nextID = id + 1
 	}
+	h.observeSequences(values)
 	return nil
 }
 
 // deleteRows removes the rows with the given IDs.
 func (h *tableHeap) deleteRows(ids []int64) {
 	for _, id := range ids {
-		if int(id) >= len(h.rows) || h.rows[id] == nil {
+		vals := h.rows.get(id)
+		if vals == nil {
 			continue
 		}
-		vals := h.rows[id]
 		if h.pkIdx != nil {
 			h.pkIdx.Delete(RowValue(vals, h.pkCol))
 		}
 		for i := range h.secondaries {
 			si := &h.secondaries[i]
-			key := RowValue(vals, si.colOrd)
+			key := si.key(vals)
 			if key == nil {
 				continue
 			}
@@ -174,117 +190,245 @@ func (h *tableHeap) deleteRows(ids []int64) {
 				si.multi.Delete(key, id)
 			}
 		}
-		h.rows[id] = nil
+		h.rows.free(id)
 		h.freeList = append(h.freeList, id)
 		h.count--
 	}
 }
 
-// updateRow replaces the values for a given row ID. Returns an error if
-// the update would violate a PK or unique index constraint.
-func (h *tableHeap) updateRow(id int64, values []any) error {
-	oldVals := h.rows[id]
+// truncate removes every row and empties the primary key and secondary
+// indexes. Row IDs start again from 1, and with restartIdentity so do the
+// identity sequences.
+func (h *tableHeap) truncate(restartIdentity bool) {
+	h.rows = h.rows.empty()
+	h.freeList = nil
+	h.count = 0
+	h.nextID = 1
+	h.resetIndexes()
+	if restartIdentity {
+		for _, seq := range h.sequences {
+			seq.restart()
+		}
+	}
+}
 
+// resetIndexes replaces the primary key and secondary indexes with empty
+// ones.
+func (h *tableHeap) resetIndexes() {
 	if h.pkIdx != nil {
-		oldKey := RowValue(oldVals, h.pkCol)
-		newKey := RowValue(values, h.pkCol)
-		if CompareValues(oldKey, newKey) != 0 {
-			if newKey == nil {
-				return &UniqueViolationError{
-					Table:  h.def.Name,
-					Column: h.pkColumnName(),
-				}
+		h.pkIdx = index.NewBTree(CompareValues)
+	}
+	for i := range h.secondaries {
+		si := &h.secondaries[i]
+		if si.unique != nil {
+			si.unique = index.NewBTree(CompareValues)
+		} else {
+			si.multi = index.NewMultiBTree(CompareValues)
+		}
+	}
+}
+
+// vacuum compacts the rows array. The live rows keep their order but move
+// to IDs 1..count, the free list is emptied and the primary key and
+// secondary indexes are rebuilt for the new IDs. It returns the number of
+// slots reclaimed: those of deleted rows and of IDs never stored. The
+// result depends only on the rows, so replaying a vacuum renumbers them
+// exactly as the live one did.
+func (h *tableHeap) vacuum() int {
+	reclaimed := max(h.rows.len()-1, 0) - h.count
+	rows, _ := h.dictionaryLayout()
+	h.resetIndexes()
+	id := int64(1) // slot 0 is never used
+	for _, values := range h.rows.all() {
+		rows.set(id, values)
+		if h.pkIdx != nil {
+			h.pkIdx.Put(h.pkKey(values), id)
+		}
+		for i := range h.secondaries {
+			si := &h.secondaries[i]
+			key := si.key(values)
+			if key == nil {
+				continue
 			}
-			// PK value is changing: remove old, try inserting new.
-			h.pkIdx.Delete(oldKey)
-			if !h.pkIdx.Put(newKey, id) {
-				// Restore old entry on failure.
-				h.pkIdx.Put(oldKey, id)
-				return &UniqueViolationError{
-					Table:  h.def.Name,
-					Column: h.pkColumnName(),
-					Value:  newKey,
-				}
+			if si.unique != nil {
+				si.unique.Put(key, id)
+			} else {
+				si.multi.Put(key, id)
 			}
 		}
+		id++
 	}
+	h.rows = rows
+	h.freeList = nil
+	h.nextID = id
+	return reclaimed
+}
 
-	// Update secondary indexes.
-	for i := range h.secondaries {
-		si := &h.secondaries[i]
-		oldKey := RowValue(oldVals, si.colOrd)
-		newKey := RowValue(values, si.colOrd)
-		if CompareValues(oldKey, newKey) == 0 {
-			continue // value unchanged
+// dictionaryLayout returns an empty store for the rows in which the TEXT
+// columns whose values repeat enough are dictionary-encoded, and whether
+// that adds a dictionary to the current layout.
+func (h *tableHeap) dictionaryLayout() (rowStore, bool) {
+	rows := h.rows.empty()
+	added := false
+	for _, col := range h.def.Columns {
+		if col.DataType == TypeText && h.rows.dictionaryCandidate(col.Ordinal, h.count) {
+			rows.useDictionary(col.Ordinal)
+			added = true
+		}
+	}
+	return rows, added
+}
+
+// encodeDictionaries dictionary-encodes the columns VACUUM would, keeping
+// the row IDs. It runs when a table is loaded, as the choice VACUUM made is
+// not logged and a checkpoint drops the VACUUM entry that would make it
+// again.
+func (h *tableHeap) encodeDictionaries() {
+	rows, added := h.dictionaryLayout()
+	if !added {
+		return
+	}
+	rows.grow(h.rows.len())
+	for id, values := range h.rows.all() {
+		rows.set(id, values)
+	}
+	h.rows = rows
+}
+
+// addColumn makes room for col, which h.def already holds, in the rows.
+// Every stored row takes its Fill value.
+func (h *tableHeap) addColumn(col ColumnDef) {
+	h.rows.addColumn(col)
+}
+
+// updateRows replaces the values of the rows of updates, which may trade
+// keys among themselves, as when UPDATE swaps two primary keys: the old
+// keys of all of them leave the indexes before any new key goes in.
+// Callers check the keys beforehand; if one is taken all the same, a
+// UniqueViolationError is returned and the heap is left as it was.
+func (h *tableHeap) updateRows(updates []rowUpdate) error {
+	for _, u := range updates {
+		if err := h.checkValues(u.Values); err != nil {
+			return err
+		}
+	}
+	olds := make([][]any, len(updates))
+	for i, u := range updates {
+		olds[i] = h.rows.get(u.RowID)
+		if h.pkIdx != nil {
+			if oldKey := RowValue(olds[i], h.pkCol); CompareValues(oldKey, RowValue(u.Values, h.pkCol)) != 0 {
+				h.pkIdx.Delete(oldKey)
+			}
 		}
-		// Remove old entry.
-		if oldKey != nil {
+		for j := range h.secondaries {
+			si := &h.secondaries[j]
+			oldKey := si.key(olds[i])
+			if oldKey == nil || CompareValues(oldKey, si.key(u.Values)) == 0 {
+				continue
+			}
 			if si.unique != nil {
 				si.unique.Delete(oldKey)
 			} else {
-				si.multi.Delete(oldKey, id)
+				si.multi.Delete(oldKey, u.RowID)
 			}
 		}
-		// Insert new entry.
-		if newKey != nil {
-			if si.unique != nil {
-				if !si.unique.Put(newKey, id) {
-					// Restore old entry on failure.
-					if oldKey != nil {
-						si.unique.Put(oldKey, id)
-					}
-					// Roll back earlier secondary index changes.
-					for j := 0; j < i; j++ {
-						sj := &h.secondaries[j]
-						ok := RowValue(oldVals, sj.colOrd)
-						nk := RowValue(values, sj.colOrd)
-						if CompareValues(ok, nk) == 0 {
-							continue
-						}
-						// Reverse: remove new, restore old.
-						if nk != nil {
-							if sj.unique != nil {
-								sj.unique.Delete(nk)
-							} else {
-								sj.multi.Delete(nk, id)
-							}
-						}
-						if ok != nil {
-							if sj.unique != nil {
-								sj.unique.Put(ok, id)
-							} else {
-								sj.multi.Put(ok, id)
-							}
-						}
-					}
-					// Roll back PK change if it was modified.
-					if h.pkIdx != nil {
-						pkOld := RowValue(oldVals, h.pkCol)
-						pkNew := RowValue(values, h.pkCol)
-						if CompareValues(pkOld, pkNew) != 0 {
-							h.pkIdx.Delete(pkNew)
-							h.pkIdx.Put(pkOld, id)
-						}
-					}
+	}
+
+	for i, u := range updates {
+		if h.pkIdx != nil {
+			newKey := RowValue(u.Values, h.pkCol)
+			if CompareValues(RowValue(olds[i], h.pkCol), newKey) != 0 {
+				if newKey == nil || !h.pkIdx.Put(newKey, u.RowID) {
+					h.reindex()
 					return &UniqueViolationError{
 						Table:  h.def.Name,
-						Column: si.def.Column,
+						Column: h.pkColumnName(),
 						Value:  newKey,
-						Index:  si.def.Name,
 					}
 				}
-			} else {
-				si.multi.Put(newKey, id)
+			}
+		}
+		for j := range h.secondaries {
+			si := &h.secondaries[j]
+			newKey := si.key(u.Values)
+			if newKey == nil || CompareValues(si.key(olds[i]), newKey) == 0 {
+				continue
+			}
+			if si.unique == nil {
+				si.multi.Put(newKey, u.RowID)
+			} else if !si.unique.Put(newKey, u.RowID) {
+				h.reindex()
+				return &UniqueViolationError{
+					Table:  h.def.Name,
+					Column: si.def.ColumnList(),
+					Value:  newKey,
+					Index:  si.def.Name,
+				}
 			}
 		}
 	}
 
-	row := make([]any, len(values))
-	copy(row, values)
-	h.rows[id] = row
+	for _, u := range updates {
+		h.rows.set(u.RowID, u.Values)
+		h.observeSequences(u.Values)
+	}
 	return nil
 }
 
+// checkValues returns a DatatypeMismatchError for the first of values the
+// rows cannot hold in its column, as a string in an INTEGER column. Only a
+// WAL written by an engine that stored INTEGER columns boxed holds such
+// values; writes are coerced to the column types before they get here.
+func (h *tableHeap) checkValues(values []any) error {
+	ord := h.rows.misfit(values)
+	if ord < 0 {
+		return nil
+	}
+	col := h.column(ord)
+	return &DatatypeMismatchError{Column: col.Name, Type: pgTypeName(col.DataType), Got: valueTypeName(values[ord])}
+}
+
+// migrateValues converts the values checkValues would reject the way an
+// INSERT would; one that does not convert becomes NULL. It returns values,
+// or a converted copy and true.
+func (h *tableHeap) migrateValues(values []any) ([]any, bool) {
+	ord := h.rows.misfit(values)
+	if ord < 0 {
+		return values, false
+	}
+	values = slices.Clone(values)
+	for ; ord >= 0; ord = h.rows.misfit(values) {
+		if _, err := coerceRowValues(&TableDef{Columns: []ColumnDef{h.column(ord)}}, values); err != nil {
+			values[ord] = nil
+		}
+	}
+	return values, true
+}
+
+// reindex rebuilds the primary key and secondary indexes from the rows. It
+// undoes the index changes of an update batch that could not be applied,
+// as no row has changed by then.
+func (h *tableHeap) reindex() {
+	h.resetIndexes()
+	for id, values := range h.rows.all() {
+		if h.pkIdx != nil {
+			h.pkIdx.Put(h.pkKey(values), id)
+		}
+		for i := range h.secondaries {
+			si := &h.secondaries[i]
+			key := si.key(values)
+			if key == nil {
+				continue
+			}
+			if si.unique != nil {
+				si.unique.Put(key, id)
+			} else {
+				si.multi.Put(key, id)
+			}
+		}
+	}
+}
+
 // lookupByPK returns the row matching the given PK value, or false if not found.
 func (h *tableHeap) lookupByPK(value any) (*Row, bool) {
 	if h.pkIdx == nil {
@@ -294,10 +438,52 @@ func (h *tableHeap) lookupByPK(value any) (*Row, bool) {
 	if !ok {
 		return nil, false
 	}
-	if int(rowID) >= len(h.rows) || h.rows[rowID] == nil {
+	values := h.rows.get(rowID)
+	if values == nil {
 		return nil, false
 	}
-	return &Row{ID: rowID, Values: h.rows[rowID]}, true
+	return &Row{ID: rowID, Values: values}, true
+}
+
+// scanPKRange returns the rows whose primary key lies between lo and hi,
+// in ascending key order.
+func (h *tableHeap) scanPKRange(lo, hi *KeyBound) []Row {
+	var rows []Row
+	if h.pkIdx == nil {
+		return rows
+	}
+	h.pkIdx.Ascend(lo.indexBound(), hi.indexBound(), func(_ any, id int64) bool {
+		if values := h.rows.get(id); values != nil {
+			rows = append(rows, Row{ID: id, Values: values})
+		}
+		return true
+	})
+	return rows
+}
+
+// lookupByIndexRange returns the rows whose key in the named index lies
+// between lo and hi, in key order.
+func (h *tableHeap) lookupByIndexRange(name string, lo, hi *KeyBound) []Row {
+	var rows []Row
+	for i := range h.secondaries {
+		si := &h.secondaries[i]
+		if si.def.Name != name {
+			continue
+		}
+		visit := func(_ any, id int64) bool {
+			if values := h.rows.get(id); values != nil {
+				rows = append(rows, Row{ID: id, Values: values})
+			}
+			return true
+		}
+		if si.unique != nil {
+			si.unique.Ascend(lo.indexBound(), hi.indexBound(), visit)
+		} else {
+			si.multi.Ascend(lo.indexBound(), hi.indexBound(), visit)
+		}
+		break
+	}
+	return rows
 }
 
 // buildSecondaryIndexes populates all secondary indexes from the current rows.
@@ -306,25 +492,22 @@ func (h *tableHeap) lookupByPK(value any) (*Row, bool) {
 func (h *tableHeap) buildSecondaryIndexes() error {
 	for i := range h.secondaries {
 		si := &h.secondaries[i]
-		for id, vals := range h.rows {
-			if vals == nil {
-				continue
-			}
-			key := RowValue(vals, si.colOrd)
+		for id, vals := range h.rows.all() {
+			key := si.key(vals)
 			if key == nil {
 				continue
 			}
 			if si.unique != nil {
-				if !si.unique.Put(key, int64(id)) {
+				if !si.unique.Put(key, id) {
 					return &UniqueViolationError{
 						Table:  h.def.Name,
-						Column: si.def.Column,
+						Column: si.def.ColumnList(),
 						Value:  key,
 						Index:  si.def.Name,
 					}
 				}
 			} else {
-				si.multi.Put(key, int64(id))
+				si.multi.Put(key, id)
 			}
 		}
 	}
@@ -334,42 +517,64 @@ func (h *tableHeap) buildSecondaryIndexes() error {
 // addSecondaryIndex builds a new secondary index from the existing rows and
 // adds it to the heap. Returns an error if a UNIQUE index has duplicates.
 func (h *tableHeap) addSecondaryIndex(def IndexDef) error {
-	colOrd := h.columnIndex(def.Column)
-	if colOrd < 0 {
-		return &ColumnNotFoundError{Column: def.Column, Table: h.def.Name}
+	si := secondaryIdx{def: def, colOrds: make([]int, len(def.Columns))}
+	for i, name := range def.Columns {
+		si.colOrds[i] = h.columnIndex(name)
+		if si.colOrds[i] < 0 {
+			return &ColumnNotFoundError{Column: name, Table: h.def.Name}
+		}
 	}
-	si := secondaryIdx{def: def, colOrd: colOrd}
 	if def.Unique {
 		si.unique = index.NewBTree(CompareValues)
 	} else {
 		si.multi = index.NewMultiBTree(CompareValues)
 	}
 	// Populate from existing rows.
-	for id, vals := range h.rows {
-		if vals == nil {
-			continue
-		}
-		key := RowValue(vals, colOrd)
+	for id, vals := range h.rows.all() {
+		key := si.key(vals)
 		if key == nil {
 			continue
 		}
 		if si.unique != nil {
-			if !si.unique.Put(key, int64(id)) {
+			if !si.unique.Put(key, id) {
 				return &UniqueViolationError{
 					Table:  h.def.Name,
-					Column: def.Column,
+					Column: def.ColumnList(),
 					Value:  key,
 					Index:  def.Name,
 				}
 			}
 		} else {
-			si.multi.Put(key, int64(id))
+			si.multi.Put(key, id)
 		}
 	}
 	h.secondaries = append(h.secondaries, si)
 	return nil
 }
 
+// clone returns a copy of the heap with definition def, which must lay out
+// rows as h's does. The copy shares the identity sequences, so that values
+// generated through either are unique; its indexes are rebuilt.
+func (h *tableHeap) clone(def TableDef) (*tableHeap, error) {
+	c := newTableHeap(def)
+	c.rows = h.rows.clone()
+	c.freeList = slices.Clone(h.freeList)
+	c.count = h.count
+	c.nextID = h.nextID
+	c.sequences = maps.Clone(h.sequences)
+	if c.pkIdx != nil {
+		for id, vals := range c.rows.all() {
+			c.pkIdx.Put(RowValue(vals, c.pkCol), id)
+		}
+	}
+	for _, idx := range def.Indexes {
+		if err := c.addSecondaryIndex(idx); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
 // removeSecondaryIndex removes a secondary index by name.
 func (h *tableHeap) removeSecondaryIndex(name string) {
 	for i, si := range h.secondaries {
@@ -398,8 +603,8 @@ func (h *tableHeap) lookupByIndex(name string, value any) []Row {
 		}
 		rows := make([]Row, 0, len(ids))
 		for _, id := range ids {
-			if int(id) < len(h.rows) && h.rows[id] != nil {
-				rows = append(rows, Row{ID: id, Values: h.rows[id]})
+			if values := h.rows.get(id); values != nil {
+				rows = append(rows, Row{ID: id, Values: values})
 			}
 		}
 		return rows
@@ -411,14 +616,24 @@ func (h *tableHeap) lookupByIndex(name string, value any) []Row {
 // Rows are returned in insertion order (ascending row ID) naturally,
 // since the array index is the row ID.
 func (h *tableHeap) scan() RowIterator {
-	rows := make([]Row, 0, h.count)
-	for id, values := range h.rows {
-		if values == nil {
-			continue
+	return &storeIterator{rows: h.rows.snapshot()}
+}
+
+// scanEqual returns a RowIterator over the rows whose column at ord equals
+// value, in row ID order. Nothing equals NULL. The rows are picked and
+// their values read at once, so that only they are copied.
+func (h *tableHeap) scanEqual(ord int, value any) RowIterator {
+	if value == nil {
+		return &sliceIterator{}
+	}
+	match := h.rows.equalTo(ord, value)
+	var rows []Row
+	for id := range int64(h.rows.len()) {
+		if h.rows.has(id) && match(id) {
+			rows = append(rows, Row{ID: id, Values: h.rows.get(id)})
 		}
-		rows = append(rows, Row{ID: int64(id), Values: values})
 	}
-	return &sliceIterator{rows: rows}
+	return &pickedIterator{sliceIterator: sliceIterator{rows: rows}, scanned: int64(h.count)}
 }
 
 // columnIndex returns the ordinal of the named column, or -1.
@@ -431,6 +646,16 @@ func (h *tableHeap) columnIndex(name string) int {
 	return -1
 }
 
+// column returns the definition of the column at ord.
+func (h *tableHeap) column(ord int) ColumnDef {
+	for _, col := range h.def.Columns {
+		if col.Ordinal == ord {
+			return col
+		}
+	}
+	return ColumnDef{Ordinal: ord}
+}
+
 // memoryInfo returns memory usage information for this table.
 func (h *tableHeap) memoryInfo() TableMemoryInfo {
 	info := TableMemoryInfo{
@@ -482,4 +707,60 @@ func (it *sliceIterator) Next() (Row, bool) {
 	return row, true
 }
 
+// NextBatch returns the next n rows as a subslice, without copying.
+func (it *sliceIterator) NextBatch(n int) []Row {
+	end := min(it.pos+n, len(it.rows))
+	rows := it.rows[it.pos:end]
+	it.pos = end
+	return rows
+}
+
 func (it *sliceIterator) Close() error { return nil }
+
+// pickedIterator is a sliceIterator over rows picked out of more, as
+// ScanEqual does.
+type pickedIterator struct {
+	sliceIterator
+	scanned int64 // rows examined to pick them
+}
+
+// Scanned returns the rows examined to pick the rows, all of them before
+// the first is read.
+func (it *pickedIterator) Scanned() int64 { return it.scanned }
+
+// storeIterator is a RowIterator over a snapshot of a table's rowStore.
+// It builds each row's values as the row is read, so a scan does not hold
+// all of them at once.
+type storeIterator struct {
+	rows  rowStore
+	id    int64
+	batch []Row
+}
+
+func (it *storeIterator) Next() (Row, bool) {
+	for it.id < int64(it.rows.len()) {
+		id := it.id
+		it.id++
+		if !it.rows.has(id) {
+			continue
+		}
+		return Row{ID: id, Values: it.rows.get(id)}, true
+	}
+	return Row{}, false
+}
+
+// NextBatch returns the next n rows in a slice that is reused by the next
+// call.
+func (it *storeIterator) NextBatch(n int) []Row {
+	it.batch = it.batch[:0]
+	for len(it.batch) < n {
+		row, ok := it.Next()
+		if !ok {
+			break
+		}
+		it.batch = append(it.batch, row)
+	}
+	return it.batch
+}
+
+func (it *storeIterator) Close() error { return nil }
@@ -1,6 +1,10 @@
 package index
 
-import "mulldb/deepsize"
+import (
+	"math"
+
+	"mulldb/deepsize"
+)
 
 const btreeOrder = 64 // max children per node
 
@@ -96,6 +100,45 @@ func (b *BTree) get(n *btreeNode, key any) (int64, bool) {
 	return b.get(n.children[idx], key)
 }
 
+// Ascend calls fn for every key between lo and hi in ascending order,
+// stopping early if fn returns false. A nil bound leaves that end open.
+func (b *BTree) Ascend(lo, hi *Bound, fn func(key any, rowID int64) bool) {
+	if b.root != nil {
+		b.ascend(b.root, lo, hi, fn)
+	}
+}
+
+// ascend visits the subtree rooted at n in order. Children left of the
+// lower bound are skipped, and the walk ends at the first key past the
+// upper bound. It returns false once the walk is over.
+func (b *BTree) ascend(n *btreeNode, lo, hi *Bound, fn func(key any, rowID int64) bool) bool {
+	i := 0
+	if lo != nil {
+		i, _ = b.search(n, lo.Key)
+	}
+	for ; i < len(n.entries); i++ {
+		if !n.isLeaf() && !b.ascend(n.children[i], lo, hi, fn) {
+			return false
+		}
+		e := n.entries[i]
+		if lo != nil && !lo.Inclusive && b.cmp(e.key, lo.Key) == 0 {
+			continue
+		}
+		if hi != nil {
+			if c := b.cmp(e.key, hi.Key); c > 0 || c == 0 && !hi.Inclusive {
+				return false
+			}
+		}
+		if !fn(e.key, e.rowID) {
+			return false
+		}
+	}
+	if !n.isLeaf() {
+		return b.ascend(n.children[len(n.entries)], lo, hi, fn)
+	}
+	return true
+}
+
 // Delete removes a key. Returns false if the key was not found.
 func (b *BTree) Delete(key any) bool {
 	if b.root == nil {
@@ -310,6 +353,31 @@ func (m *MultiBTree) Delete(key any, rowID int64) bool {
 	return m.bt.Delete(multiKey{key: key, rowID: rowID})
 }
 
+// Ascend calls fn for every (key, rowID) pair with a key between lo and hi,
+// in (key, rowID) order. Each bound is turned into a composite key that
+// sorts before or after every row ID of its user key, so the walk over the
+// underlying B-tree includes or excludes all of them at once.
+func (m *MultiBTree) Ascend(lo, hi *Bound, fn func(key any, rowID int64) bool) {
+	var clo, chi *Bound
+	if lo != nil {
+		if lo.Inclusive {
+			clo = &Bound{Key: multiKey{key: lo.Key, rowID: math.MinInt64}, Inclusive: true}
+		} else {
+			clo = &Bound{Key: multiKey{key: lo.Key, rowID: math.MaxInt64}}
+		}
+	}
+	if hi != nil {
+		if hi.Inclusive {
+			chi = &Bound{Key: multiKey{key: hi.Key, rowID: math.MaxInt64}, Inclusive: true}
+		} else {
+			chi = &Bound{Key: multiKey{key: hi.Key, rowID: math.MinInt64}}
+		}
+	}
+	m.bt.Ascend(clo, chi, func(key any, rowID int64) bool {
+		return fn(key.(multiKey).key, rowID)
+	})
+}
+
 // Size returns the estimated in-memory size of the multi-value B-tree in bytes.
 func (m *MultiBTree) Size() int64 {
 	return m.bt.Size()
@@ -223,6 +223,78 @@ func TestBTree_LargeDelete(t *testing.T) {
 	}
 }
 
+func TestBTree_Ascend(t *testing.T) {
+	bt := NewBTree(cmp)
+	const n = 1000
+	// Insert in a scrambled order, then delete every third key.
+	for i := int64(0); i < n; i++ {
+		k := i * 7919 % n
+		bt.Put(k, k+100)
+	}
+	for i := int64(0); i < n; i += 3 {
+		bt.Delete(i)
+	}
+
+	collect := func(lo, hi *Bound) []int64 {
+		var keys []int64
+		bt.Ascend(lo, hi, func(key any, rowID int64) bool {
+			if rowID != key.(int64)+100 {
+				t.Fatalf("key %v has row %d", key, rowID)
+			}
+			keys = append(keys, key.(int64))
+			return true
+		})
+		return keys
+	}
+	want := func(from, to int64) []int64 {
+		var keys []int64
+		for k := from; k <= to; k++ {
+			if k%3 != 0 {
+				keys = append(keys, k)
+			}
+		}
+		return keys
+	}
+	tests := []struct {
+		name   string
+		lo, hi *Bound
+		want   []int64
+	}{
+		{"all", nil, nil, want(0, n-1)},
+		{"from inclusive", &Bound{Key: int64(500), Inclusive: true}, nil, want(500, n-1)},
+		{"from exclusive", &Bound{Key: int64(500)}, nil, want(501, n-1)},
+		{"to inclusive", nil, &Bound{Key: int64(200), Inclusive: true}, want(0, 200)},
+		{"to exclusive", nil, &Bound{Key: int64(200)}, want(0, 199)},
+		{"between", &Bound{Key: int64(10), Inclusive: true}, &Bound{Key: int64(20), Inclusive: true}, want(10, 20)},
+		{"deleted bound", &Bound{Key: int64(300), Inclusive: true}, &Bound{Key: int64(303), Inclusive: true}, want(300, 303)},
+		{"empty", &Bound{Key: int64(20)}, &Bound{Key: int64(10)}, nil},
+		{"past end", &Bound{Key: int64(n)}, nil, nil},
+	}
+	for _, tt := range tests {
+		got := collect(tt.lo, tt.hi)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d keys, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: key %d = %d, want %d", tt.name, i, got[i], tt.want[i])
+				break
+			}
+		}
+	}
+
+	// Returning false stops the walk.
+	var seen int
+	bt.Ascend(nil, nil, func(any, int64) bool {
+		seen++
+		return seen < 5
+	})
+	if seen != 5 {
+		t.Errorf("visited %d keys after stopping, want 5", seen)
+	}
+}
+
 func TestBTree_LargeDeleteAll(t *testing.T) {
 	bt := NewBTree(cmp)
 	const n = 100_000
@@ -337,6 +409,53 @@ func TestMultiBTree_PutAndGetAll(t *testing.T) {
 	}
 }
 
+func TestMultiBTree_Ascend(t *testing.T) {
+	mt := NewMultiBTree(cmp)
+	// Ten row IDs for each key 0..99, inserted out of order.
+	for i := int64(0); i < 1000; i++ {
+		id := i * 7919 % 1000
+		mt.Put(id/10, id)
+	}
+
+	collect := func(lo, hi *Bound) []int64 {
+		var ids []int64
+		mt.Ascend(lo, hi, func(key any, rowID int64) bool {
+			if key.(int64) != rowID/10 {
+				t.Fatalf("row %d has key %v", rowID, key)
+			}
+			ids = append(ids, rowID)
+			return true
+		})
+		return ids
+	}
+	tests := []struct {
+		name     string
+		lo, hi   *Bound
+		from, to int64 // expected row IDs, inclusive; from > to for none
+	}{
+		{"all", nil, nil, 0, 999},
+		{"from inclusive", &Bound{Key: int64(50), Inclusive: true}, nil, 500, 999},
+		{"from exclusive", &Bound{Key: int64(50)}, nil, 510, 999},
+		{"to inclusive", nil, &Bound{Key: int64(9), Inclusive: true}, 0, 99},
+		{"to exclusive", nil, &Bound{Key: int64(9)}, 0, 89},
+		{"one key", &Bound{Key: int64(42), Inclusive: true}, &Bound{Key: int64(42), Inclusive: true}, 420, 429},
+		{"empty", &Bound{Key: int64(42)}, &Bound{Key: int64(42), Inclusive: true}, 1, 0},
+	}
+	for _, tt := range tests {
+		got := collect(tt.lo, tt.hi)
+		if want := tt.to - tt.from + 1; int64(len(got)) != want {
+			t.Errorf("%s: got %d rows, want %d", tt.name, len(got), want)
+			continue
+		}
+		for i, id := range got {
+			if id != tt.from+int64(i) {
+				t.Errorf("%s: row %d = %d, want %d", tt.name, i, id, tt.from+int64(i))
+				break
+			}
+		}
+	}
+}
+
 func TestMultiBTree_Delete(t *testing.T) {
 	mt := NewMultiBTree(cmp)
 	mt.Put(int64(10), 1)
@@ -11,10 +11,19 @@ type Index interface {
 	Get(key any) (int64, bool)
 	// Delete removes a key. Returns false if the key was not found.
 	Delete(key any) bool
+	// Ascend calls fn for every key between lo and hi in ascending order,
+	// stopping early if fn returns false. A nil bound leaves that end open.
+	Ascend(lo, hi *Bound, fn func(key any, rowID int64) bool)
 	// Size returns the estimated in-memory size in bytes.
 	Size() int64
 }
 
+// Bound is one end of a key range.
+type Bound struct {
+	Key       any
+	Inclusive bool
+}
+
 // MultiIndex maps a key to zero or more row IDs. Used for non-unique
 // secondary indexes where duplicate key values are allowed.
 type MultiIndex interface {
@@ -24,6 +33,10 @@ type MultiIndex interface {
 	GetAll(key any) []int64
 	// Delete removes a specific key+rowID pair. Returns false if not found.
 	Delete(key any, rowID int64) bool
+	// Ascend calls fn for every key between lo and hi in ascending order,
+	// row IDs of equal keys in ascending order, stopping early if fn
+	// returns false. A nil bound leaves that end open.
+	Ascend(lo, hi *Bound, fn func(key any, rowID int64) bool)
 	// Size returns the estimated in-memory size in bytes.
 	Size() int64
 }
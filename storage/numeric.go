@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Numeric is an exact decimal value: coef × 10^-scale. The scale is the
+// number of digits after the decimal point and is kept through arithmetic,
+// so 1.50 + 1 is 2.50 as in PostgreSQL. The zero value is 0.
+type Numeric struct {
+	coef  *big.Int
+	scale int
+}
+
+// numericMinDivScale is the minimum number of significant digits a
+// quotient is computed to, following PostgreSQL's NUMERIC_MIN_SIG_DIGITS.
+const numericMinDivScale = 16
+
+var bigTen = big.NewInt(10)
+
+// ParseNumeric parses a decimal literal such as "12", "-0.50", "1.5e3" or
+// ".25". NaN and infinities are not supported.
+func ParseNumeric(s string) (Numeric, error) {
+	t := strings.TrimSpace(s)
+	mant, exp := t, 0
+	if i := strings.IndexAny(t, "eE"); i >= 0 {
+		e, err := strconv.Atoi(t[i+1:])
+		if err != nil {
+			return Numeric{}, fmt.Errorf("invalid input syntax for type numeric: %q", s)
+		}
+		mant, exp = t[:i], e
+	}
+	sign := ""
+	if mant != "" && (mant[0] == '-' || mant[0] == '+') {
+		sign, mant = mant[:1], mant[1:]
+	}
+	intPart, frac, _ := strings.Cut(mant, ".")
+	if intPart == "" && frac == "" || !isDigits(intPart) || !isDigits(frac) {
+		return Numeric{}, fmt.Errorf("invalid input syntax for type numeric: %q", s)
+	}
+	coef, _ := new(big.Int).SetString(sign+intPart+frac, 10)
+	n := Numeric{coef: coef, scale: len(frac) - exp}
+	if n.scale < 0 {
+		n = n.Round(0)
+	}
+	return n, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// NumericFromInt returns v as a NUMERIC with scale 0.
+func NumericFromInt(v int64) Numeric {
+	return Numeric{coef: big.NewInt(v)}
+}
+
+// NumericFromFloat returns the shortest decimal that round-trips to f, so
+// 0.1 becomes exactly 0.1 rather than the binary value nearest to it.
+func NumericFromFloat(f float64) (Numeric, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Numeric{}, fmt.Errorf("cannot convert %v to numeric", f)
+	}
+	return ParseNumeric(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func (n Numeric) bigCoef() *big.Int {
+	if n.coef == nil {
+		return new(big.Int)
+	}
+	return n.coef
+}
+
+// Scale returns the number of digits after the decimal point.
+func (n Numeric) Scale() int { return n.scale }
+
+// Sign returns -1, 0 or 1.
+func (n Numeric) Sign() int { return n.bigCoef().Sign() }
+
+// String formats n with exactly Scale() fractional digits.
+func (n Numeric) String() string {
+	digits := new(big.Int).Abs(n.bigCoef()).String()
+	if n.scale > 0 {
+		if len(digits) <= n.scale {
+			digits = strings.Repeat("0", n.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-n.scale] + "." + digits[len(digits)-n.scale:]
+	}
+	if n.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Float64 returns the nearest float64 to n.
+func (n Numeric) Float64() float64 {
+	f, _ := strconv.ParseFloat(n.String(), 64)
+	return f
+}
+
+// Int64 returns n rounded to an integer, and false if that does not fit
+// in an int64.
+func (n Numeric) Int64() (int64, bool) {
+	c := n.Round(0).bigCoef()
+	return c.Int64(), c.IsInt64()
+}
+
+// rescale returns n's coefficient at a larger scale.
+func (n Numeric) rescale(scale int) *big.Int {
+	c := n.bigCoef()
+	if scale == n.scale {
+		return c
+	}
+	return new(big.Int).Mul(c, pow10(scale-n.scale))
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+// Round returns n rounded to scale fractional digits, halves away from
+// zero. A larger scale pads with zeros.
+func (n Numeric) Round(scale int) Numeric {
+	if scale >= n.scale {
+		return Numeric{coef: n.rescale(scale), scale: scale}
+	}
+	return Numeric{coef: quoRound(n.bigCoef(), pow10(n.scale-scale)), scale: scale}
+}
+
+// quoRound returns a/b rounded half away from zero.
+func quoRound(a, b *big.Int) *big.Int {
+	q, r := new(big.Int).QuoRem(a, b, new(big.Int))
+	if r.Sign() != 0 {
+		twice := new(big.Int).Abs(r)
+		twice.Lsh(twice, 1)
+		if twice.Cmp(new(big.Int).Abs(b)) >= 0 {
+			if a.Sign()*b.Sign() < 0 {
+				q.Sub(q, big.NewInt(1))
+			} else {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+	return q
+}
+
+// Add returns n + m at the larger of the two scales.
+func (n Numeric) Add(m Numeric) Numeric {
+	s := max(n.scale, m.scale)
+	return Numeric{coef: new(big.Int).Add(n.rescale(s), m.rescale(s)), scale: s}
+}
+
+// Sub returns n - m at the larger of the two scales.
+func (n Numeric) Sub(m Numeric) Numeric {
+	s := max(n.scale, m.scale)
+	return Numeric{coef: new(big.Int).Sub(n.rescale(s), m.rescale(s)), scale: s}
+}
+
+// Mul returns n × m at the sum of the two scales.
+func (n Numeric) Mul(m Numeric) Numeric {
+	return Numeric{coef: new(big.Int).Mul(n.bigCoef(), m.bigCoef()), scale: n.scale + m.scale}
+}
+
+// Mod returns the remainder of n / m truncated toward zero, which has the
+// sign of n. m must not be zero.
+func (n Numeric) Mod(m Numeric) Numeric {
+	s := max(n.scale, m.scale)
+	return Numeric{coef: new(big.Int).Rem(n.rescale(s), m.rescale(s)), scale: s}
+}
+
+// Neg returns -n.
+func (n Numeric) Neg() Numeric {
+	return Numeric{coef: new(big.Int).Neg(n.bigCoef()), scale: n.scale}
+}
+
+// Div returns n / m. The quotient's scale follows PostgreSQL: at least
+// 16 significant digits, and no less than the scale of either operand. m
+// must not be zero.
+func (n Numeric) Div(m Numeric) Numeric {
+	nw, nfirst := n.weight()
+	mw, mfirst := m.weight()
+	qweight := nw - mw
+	if nfirst <= mfirst {
+		qweight--
+	}
+	scale := max(numericMinDivScale-qweight*4, n.scale, m.scale, 0)
+	// n/m at scale s is n.coef × 10^(s - n.scale + m.scale) / m.coef.
+	shift := scale - n.scale + m.scale
+	num, den := new(big.Int).Set(n.bigCoef()), new(big.Int).Set(m.bigCoef())
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	return Numeric{coef: quoRound(num, den), scale: scale}
+}
+
+// weight returns the position of n's leading base-10000 digit and that
+// digit's value, the terms PostgreSQL sizes a quotient by.
+func (n Numeric) weight() (int, int) {
+	if n.Sign() == 0 {
+		return 0, 0
+	}
+	digits := new(big.Int).Abs(n.bigCoef()).String()
+	// The leading digit is at 10^(d-1); its group w covers 10^(4w)..10^(4w+3).
+	d := n.intDigits()
+	w := (d - 1) / 4
+	if d < 1 && (d-1)%4 != 0 {
+		w-- // round toward negative infinity
+	}
+	first, _ := strconv.Atoi((digits + "000")[:d-4*w])
+	return w, first
+}
+
+// intDigits returns the number of digits before the decimal point, which
+// is zero or negative for values below 1.
+func (n Numeric) intDigits() int {
+	if n.Sign() == 0 {
+		return 0
+	}
+	return len(new(big.Int).Abs(n.bigCoef()).String()) - n.scale
+}
+
+// Cmp returns -1, 0 or 1 as n is less than, equal to or greater than m.
+func (n Numeric) Cmp(m Numeric) int {
+	s := max(n.scale, m.scale)
+	return n.rescale(s).Cmp(m.rescale(s))
+}
+
+// key returns a form of n that is equal for equal values, whatever the
+// scale.
+func (n Numeric) key() numericKey {
+	c, s := new(big.Int).Set(n.bigCoef()), n.scale
+	r := new(big.Int)
+	for s > 0 && c.Sign() != 0 {
+		q, _ := new(big.Int).QuoRem(c, bigTen, r)
+		if r.Sign() != 0 {
+			break
+		}
+		c, s = q, s-1
+	}
+	if c.Sign() == 0 {
+		s = 0
+	}
+	return numericKey(Numeric{coef: c, scale: s}.String())
+}
+
+// numericKey is the map-key form of a NUMERIC value.
+type numericKey string
+
+// NumericOverflowError is returned when a value does not fit a column's
+// declared NUMERIC precision.
+type NumericOverflowError struct {
+	Precision int
+	Scale     int
+}
+
+func (e *NumericOverflowError) Error() string {
+	return fmt.Sprintf("numeric field overflow: a field with precision %d, scale %d must round to an absolute value less than 10^%d",
+		e.Precision, e.Scale, e.Precision-e.Scale)
+}
+
+// CoerceNumeric converts an int64, float64, string or Numeric value to a
+// NUMERIC fitting precision and scale, rounding to scale digits. A zero
+// precision means unconstrained: the value is kept as given.
+func CoerceNumeric(v any, precision, scale int) (Numeric, error) {
+	var n Numeric
+	switch x := v.(type) {
+	case Numeric:
+		n = x
+	case int64:
+		n = NumericFromInt(x)
+	case float64:
+		var err error
+		if n, err = NumericFromFloat(x); err != nil {
+			return Numeric{}, err
+		}
+	case string:
+		var err error
+		if n, err = ParseNumeric(x); err != nil {
+			return Numeric{}, err
+		}
+	default:
+		return Numeric{}, fmt.Errorf("cannot convert %T to numeric", v)
+	}
+	if precision == 0 {
+		return n, nil
+	}
+	n = n.Round(scale)
+	if n.Sign() != 0 && n.intDigits() > precision-scale {
+		return Numeric{}, &NumericOverflowError{Precision: precision, Scale: scale}
+	}
+	return n, nil
+}
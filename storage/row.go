@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"math/big"
 	"time"
 )
 
@@ -13,6 +14,9 @@ import (
 //	tagInteger (1): 8 bytes int64 big-endian
 //	tagText    (2): uint16 length + bytes
 //	tagBoolean (3): 1 byte (0=false, 1=true)
+//	tagBytea   (6): uint32 length + bytes
+//	tagNumeric (7): uint16 scale + sign byte (1=negative) + uint16 length + magnitude bytes big-endian
+//	tagTime    (8): 8 bytes int64 big-endian, microseconds since midnight
 const (
 	tagNull      byte = 0
 	tagInteger   byte = 1
@@ -20,6 +24,9 @@ const (
 	tagBoolean   byte = 3
 	tagTimestamp byte = 4
 	tagFloat     byte = 5
+	tagBytea     byte = 6
+	tagNumeric   byte = 7
+	tagTime      byte = 8
 )
 
 // encodeValue appends the binary encoding of v to buf.
@@ -47,6 +54,24 @@ func encodeValue(buf []byte, v any) []byte {
 		buf = append(buf, tagTimestamp)
 		usec := val.UnixMicro()
 		return binary.BigEndian.AppendUint64(buf, uint64(usec))
+	case TimeOfDay:
+		buf = append(buf, tagTime)
+		return binary.BigEndian.AppendUint64(buf, uint64(val))
+	case []byte:
+		buf = append(buf, tagBytea)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(val)))
+		return append(buf, val...)
+	case Numeric:
+		buf = append(buf, tagNumeric)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(val.scale))
+		var neg byte
+		if val.Sign() < 0 {
+			neg = 1
+		}
+		buf = append(buf, neg)
+		mag := val.bigCoef().Bytes()
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(mag)))
+		return append(buf, mag...)
 	default:
 		// Treat unknown types as NULL.
 		return append(buf, tagNull)
@@ -98,6 +123,37 @@ func decodeValue(data []byte) (any, []byte, error) {
 		}
 		usec := int64(binary.BigEndian.Uint64(data[:8]))
 		return time.UnixMicro(usec).UTC(), data[8:], nil
+	case tagTime:
+		if len(data) < 8 {
+			return nil, nil, fmt.Errorf("truncated time value")
+		}
+		return TimeOfDay(binary.BigEndian.Uint64(data[:8])), data[8:], nil
+	case tagBytea:
+		if len(data) < 4 {
+			return nil, nil, fmt.Errorf("truncated bytea length")
+		}
+		n := binary.BigEndian.Uint32(data[:4])
+		data = data[4:]
+		if uint32(len(data)) < n {
+			return nil, nil, fmt.Errorf("truncated bytea value")
+		}
+		return append([]byte(nil), data[:n]...), data[n:], nil
+	case tagNumeric:
+		if len(data) < 5 {
+			return nil, nil, fmt.Errorf("truncated numeric header")
+		}
+		scale := int(binary.BigEndian.Uint16(data[:2]))
+		neg := data[2] != 0
+		n := int(binary.BigEndian.Uint16(data[3:5]))
+		data = data[5:]
+		if len(data) < n {
+			return nil, nil, fmt.Errorf("truncated numeric value")
+		}
+		coef := new(big.Int).SetBytes(data[:n])
+		if neg {
+			coef.Neg(coef)
+		}
+		return Numeric{coef: coef, scale: scale}, data[n:], nil
 	default:
 		return nil, nil, fmt.Errorf("unknown value tag %d", tag)
 	}
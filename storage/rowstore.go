@@ -0,0 +1,466 @@
+package storage
+
+import (
+	"iter"
+	"maps"
+	"slices"
+)
+
+// rowStore holds the rows of a table by row ID. An interface value costs
+// 16 bytes, and an int64 in one points to another 8 unless it is below
+// 256; so INTEGER columns are kept unboxed, in one []int64 per column with
+// a bitmap of the rows where the column is NULL, and only the values of
+// the other columns go into a []any per row. Reading a row builds its
+// []any, in ordinal order, so callers see rows as before.
+//
+// A TEXT column may be dictionary-encoded instead: each distinct value is
+// kept once, and the rows hold a uint32 index into the dictionary. That
+// saves the string header and bytes each row would otherwise point to,
+// and lets equality tests compare indexes.
+//
+// Rows are stored in a dense array indexed by row ID; the live bitmap
+// marks the IDs that hold a row. The per-row value slices are never
+// changed in place, while the int64 and index arrays are.
+type rowStore struct {
+	layout []columnSlot // by ordinal: where the column's values are kept
+	ints   []intColumn  // the INTEGER columns, in layout order
+	dicts  []dictColumn // the dictionary-encoded columns, in layout order
+	boxed  [][]any      // by row ID: the other columns' values, in layout order; may be shorter than n
+	live   bitmap       // row IDs that hold a row
+	n      int          // number of row ID slots
+}
+
+// columnSlot says where one column's values are kept.
+type columnSlot struct {
+	kind  slotKind
+	index int // into ints, dicts or a row's boxed values, by kind
+	fill  any // value of rows stored without the column, which predate it
+}
+
+type slotKind uint8
+
+const (
+	slotDropped slotKind = iota // a column dropped before the table was loaded: not kept
+	slotInt
+	slotDict
+	slotBoxed
+)
+
+// intColumn holds the values of an INTEGER column by row ID.
+type intColumn struct {
+	vals  []int64
+	nulls bitmap // set where the value is NULL
+}
+
+// dictColumn holds the values of a dictionary-encoded TEXT column by row
+// ID. Index 0 is NULL and index i the value values[i-1]. The values are
+// kept boxed, so that reading one shares the interface value rather than
+// allocating a new one. Values are only added; VACUUM and TRUNCATE start a
+// new dictionary.
+type dictColumn struct {
+	ids    []uint32
+	values []any             // the distinct values, as strings
+	lookup map[string]uint32 // value to index
+}
+
+func newDictColumn(n int) dictColumn {
+	return dictColumn{ids: make([]uint32, n), lookup: make(map[string]uint32)}
+}
+
+// intern returns the index of s, adding it to the dictionary if needed.
+func (c *dictColumn) intern(s string) uint32 {
+	if id, ok := c.lookup[s]; ok {
+		return id
+	}
+	c.values = append(c.values, s)
+	id := uint32(len(c.values))
+	c.lookup[s] = id
+	return id
+}
+
+// value returns the value of row id.
+func (c *dictColumn) value(id int64) any {
+	if i := c.ids[id]; i != 0 {
+		return c.values[i-1]
+	}
+	return nil
+}
+
+// Dictionary encoding is chosen by VACUUM for a TEXT column whose values
+// repeat this often on average, in a table of at least dictMinRows rows.
+const (
+	dictMinRows    = 256
+	dictMinRepeats = 16
+)
+
+// bitmap is a set of row IDs.
+type bitmap []uint64
+
+func (b bitmap) has(id int) bool {
+	return id>>6 < len(b) && b[id>>6]&(1<<(id&63)) != 0
+}
+
+func (b bitmap) set(id int, on bool) {
+	if on {
+		b[id>>6] |= 1 << (id & 63)
+	} else {
+		b[id>>6] &^= 1 << (id & 63)
+	}
+}
+
+// grow extends b to hold n bits.
+func (b bitmap) grow(n int) bitmap {
+	if words := (n + 63) >> 6; words > len(b) {
+		b = append(b, make(bitmap, words-len(b))...)
+	}
+	return b
+}
+
+// newRowStore returns an empty store for the rows of def. Ordinals below
+// def.NextOrdinal that def does not name belong to dropped columns, whose
+// values are not kept.
+func newRowStore(def TableDef) rowStore {
+	s := rowStore{layout: make([]columnSlot, def.NextOrdinal)}
+	for _, col := range def.Columns {
+		s.addColumn(col)
+	}
+	return s
+}
+
+// addColumn adds a slot for col. The rows stored so far predate it and
+// take its Fill value.
+func (s *rowStore) addColumn(col ColumnDef) {
+	for len(s.layout) <= col.Ordinal {
+		s.layout = append(s.layout, columnSlot{})
+	}
+	slot := columnSlot{kind: slotBoxed, fill: col.Fill}
+	switch {
+	case col.DataType == TypeInteger:
+		slot.kind, slot.index = slotInt, len(s.ints)
+		c := intColumn{vals: make([]int64, s.n), nulls: make(bitmap, (s.n+63)>>6)}
+		for id := range s.n {
+			if fill, ok := col.Fill.(int64); ok && s.live.has(id) {
+				c.vals[id] = fill
+			} else {
+				c.nulls.set(id, true)
+			}
+		}
+		s.ints = append(s.ints, c)
+	case col.Dictionary:
+		slot.kind, slot.index = slotDict, len(s.dicts)
+		c := newDictColumn(s.n)
+		if fill, ok := col.Fill.(string); ok {
+			id := c.intern(fill)
+			for i := range s.n {
+				if s.live.has(i) {
+					c.ids[i] = id
+				}
+			}
+		}
+		s.dicts = append(s.dicts, c)
+	default:
+		slot.index = s.boxedWidth()
+		if col.Fill != nil {
+			for id := range s.n {
+				if !s.live.has(id) {
+					continue
+				}
+				for len(s.boxed) <= id {
+					s.boxed = append(s.boxed, nil)
+				}
+				filled := make([]any, slot.index+1)
+				copy(filled, s.boxed[id])
+				filled[slot.index] = col.Fill
+				s.boxed[id] = filled
+			}
+		}
+	}
+	s.layout[col.Ordinal] = slot
+}
+
+// boxedWidth returns the number of values in a row's boxed slice.
+func (s *rowStore) boxedWidth() int {
+	n := 0
+	for _, slot := range s.layout {
+		if slot.kind == slotBoxed {
+			n++
+		}
+	}
+	return n
+}
+
+// len returns the number of row ID slots, free or not.
+func (s *rowStore) len() int {
+	return s.n
+}
+
+// has reports whether id holds a row.
+func (s *rowStore) has(id int64) bool {
+	return id >= 0 && s.live.has(int(id))
+}
+
+// get returns the values of row id, or nil if it holds none. The slice is
+// the caller's.
+func (s *rowStore) get(id int64) []any {
+	if !s.has(id) {
+		return nil
+	}
+	var boxed []any
+	if int(id) < len(s.boxed) {
+		boxed = s.boxed[id]
+	}
+	values := make([]any, len(s.layout))
+	for ord, slot := range s.layout {
+		switch slot.kind {
+		case slotInt:
+			if c := &s.ints[slot.index]; !c.nulls.has(int(id)) {
+				values[ord] = c.vals[id]
+			}
+		case slotDict:
+			values[ord] = s.dicts[slot.index].value(id)
+		case slotBoxed:
+			if slot.index < len(boxed) {
+				values[ord] = boxed[slot.index]
+			}
+		}
+	}
+	return values
+}
+
+// value returns the value of the column at ord in the live row id.
+func (s *rowStore) value(id int64, ord int) any {
+	if ord >= len(s.layout) {
+		return nil
+	}
+	switch slot := s.layout[ord]; slot.kind {
+	case slotInt:
+		if c := &s.ints[slot.index]; !c.nulls.has(int(id)) {
+			return c.vals[id]
+		}
+	case slotDict:
+		return s.dicts[slot.index].value(id)
+	case slotBoxed:
+		if int(id) < len(s.boxed) && slot.index < len(s.boxed[id]) {
+			return s.boxed[id][slot.index]
+		}
+	}
+	return nil
+}
+
+// equalTo returns a test of whether the column at ord of a live row
+// equals v, which is not NULL. On a dictionary-encoded column it compares
+// dictionary indexes, and on an INTEGER column the unboxed values.
+func (s *rowStore) equalTo(ord int, v any) func(id int64) bool {
+	if ord < len(s.layout) {
+		switch slot := s.layout[ord]; slot.kind {
+		case slotDict:
+			if str, ok := v.(string); ok {
+				c := &s.dicts[slot.index]
+				want, ok := c.lookup[str]
+				if !ok {
+					return func(int64) bool { return false }
+				}
+				return func(id int64) bool { return c.ids[id] == want }
+			}
+		case slotInt:
+			if n, ok := v.(int64); ok {
+				c := &s.ints[slot.index]
+				return func(id int64) bool { return c.vals[id] == n && !c.nulls.has(int(id)) }
+			}
+		}
+	}
+	return func(id int64) bool { return CompareValues(s.value(id, ord), v) == 0 }
+}
+
+// dictionaryCandidate reports whether the boxed TEXT column at ord has few
+// enough distinct values among the live rows for dictionary encoding.
+func (s *rowStore) dictionaryCandidate(ord int, count int) bool {
+	if count < dictMinRows || ord >= len(s.layout) || s.layout[ord].kind != slotBoxed {
+		return false
+	}
+	limit := count / dictMinRepeats
+	seen := make(map[string]bool)
+	for id := range int64(s.n) {
+		if !s.has(id) {
+			continue
+		}
+		switch v := s.value(id, ord).(type) {
+		case nil:
+		case string:
+			if !seen[v] {
+				if len(seen) == limit {
+					return false
+				}
+				seen[v] = true
+			}
+		default:
+			return false // a legacy value the dictionary cannot hold
+		}
+	}
+	return true
+}
+
+// useDictionary switches the boxed column at ord of an empty store to
+// dictionary encoding.
+func (s *rowStore) useDictionary(ord int) {
+	slot := &s.layout[ord]
+	for i := range s.layout {
+		if other := &s.layout[i]; other.kind == slotBoxed && other.index > slot.index {
+			other.index--
+		}
+	}
+	slot.kind, slot.index = slotDict, len(s.dicts)
+	s.dicts = append(s.dicts, newDictColumn(s.n))
+}
+
+// all returns the live rows in ID order.
+func (s *rowStore) all() iter.Seq2[int64, []any] {
+	return func(yield func(int64, []any) bool) {
+		for id := range int64(s.n) {
+			if s.has(id) && !yield(id, s.get(id)) {
+				return
+			}
+		}
+	}
+}
+
+// misfit returns the ordinal of the first of values that its column's slot
+// cannot hold, as a string in an INTEGER column, or -1 if all fit.
+func (s *rowStore) misfit(values []any) int {
+	for ord, v := range values {
+		if v == nil || ord >= len(s.layout) {
+			continue
+		}
+		switch s.layout[ord].kind {
+		case slotInt:
+			if _, ok := v.(int64); !ok {
+				return ord
+			}
+		case slotDict:
+			if _, ok := v.(string); !ok {
+				return ord
+			}
+		}
+	}
+	return -1
+}
+
+// set stores values as row id, growing the store as needed. Columns past
+// the end of values take their fill value. Callers check values with
+// misfit first; a value its slot cannot hold is stored as NULL.
+func (s *rowStore) set(id int64, values []any) {
+	s.grow(int(id) + 1)
+	var boxed []any
+	if w := s.boxedWidth(); w > 0 {
+		boxed = make([]any, w)
+	}
+	for ord, slot := range s.layout {
+		v := slot.fill
+		if ord < len(values) {
+			v = values[ord]
+		}
+		switch slot.kind {
+		case slotInt:
+			c := &s.ints[slot.index]
+			n, ok := v.(int64)
+			c.vals[id] = n
+			c.nulls.set(int(id), !ok)
+		case slotDict:
+			c := &s.dicts[slot.index]
+			if str, ok := v.(string); ok {
+				c.ids[id] = c.intern(str)
+			} else {
+				c.ids[id] = 0
+			}
+		case slotBoxed:
+			boxed[slot.index] = v
+		}
+	}
+	if boxed != nil || int(id) < len(s.boxed) {
+		for len(s.boxed) <= int(id) {
+			s.boxed = append(s.boxed, nil)
+		}
+		s.boxed[id] = boxed
+	}
+	s.live.set(int(id), true)
+}
+
+// free removes row id.
+func (s *rowStore) free(id int64) {
+	if int(id) < len(s.boxed) {
+		s.boxed[id] = nil
+	}
+	for i := range s.dicts {
+		s.dicts[i].ids[id] = 0
+	}
+	s.live.set(int(id), false)
+}
+
+// grow makes room for n row ID slots.
+func (s *rowStore) grow(n int) {
+	if n <= s.n {
+		return
+	}
+	for i := range s.ints {
+		c := &s.ints[i]
+		c.vals = append(c.vals, make([]int64, n-len(c.vals))...)
+		c.nulls = c.nulls.grow(n)
+	}
+	for i := range s.dicts {
+		c := &s.dicts[i]
+		c.ids = append(c.ids, make([]uint32, n-len(c.ids))...)
+	}
+	s.live = s.live.grow(n)
+	s.n = n
+}
+
+// empty returns a store with the same columns and no rows. Dictionaries
+// start over.
+func (s *rowStore) empty() rowStore {
+	e := rowStore{
+		layout: slices.Clone(s.layout),
+		ints:   make([]intColumn, len(s.ints)),
+		dicts:  make([]dictColumn, len(s.dicts)),
+	}
+	for i := range e.dicts {
+		e.dicts[i] = newDictColumn(0)
+	}
+	return e
+}
+
+// clone returns a copy of s that later changes to either do not affect
+// the other. The per-row value slices are shared, since they are never
+// changed in place, and so are the dictionaries' values, which are only
+// appended to.
+func (s *rowStore) clone() rowStore {
+	c := s.snapshot()
+	for i := range c.dicts {
+		c.dicts[i].lookup = maps.Clone(c.dicts[i].lookup)
+	}
+	return c
+}
+
+// snapshot returns a copy of s for reading only, which later changes to s
+// do not affect. Unlike clone it shares the dictionaries' lookup maps as
+// well: s goes on adding to them, so the copy must not read them, and
+// only get, value and all are safe on it.
+func (s *rowStore) snapshot() rowStore {
+	c := rowStore{
+		layout: slices.Clone(s.layout),
+		ints:   make([]intColumn, len(s.ints)),
+		boxed:  slices.Clone(s.boxed),
+		live:   slices.Clone(s.live),
+		n:      s.n,
+	}
+	for i, col := range s.ints {
+		c.ints[i] = intColumn{vals: slices.Clone(col.vals), nulls: slices.Clone(col.nulls)}
+	}
+	for _, col := range s.dicts {
+		c.dicts = append(c.dicts, dictColumn{
+			ids:    slices.Clone(col.ids),
+			values: slices.Clip(col.values),
+			lookup: col.lookup,
+		})
+	}
+	return c
+}
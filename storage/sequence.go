@@ -0,0 +1,85 @@
+package storage
+
+import "sync"
+
+// sequence generates the values of an identity column. last is the
+// highest value the column has held, given or generated, so a generated
+// value never repeats one that was stored before, even if that row has
+// since been deleted. The sequence is not logged: replaying the table WAL
+// observes every value ever inserted and so restores it. Values handed
+// out to a transaction that rolled back are the only ones a restart can
+// hand out again.
+//
+// A transaction resolves its rows under the table read lock, so the
+// sequence has a lock of its own.
+type sequence struct {
+	mu   sync.Mutex
+	last int64
+}
+
+// next returns the next value of the sequence.
+func (s *sequence) next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last++
+	return s.last
+}
+
+// restart makes the sequence start over from 1.
+func (s *sequence) restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = 0
+}
+
+// observe records that the column holds v.
+func (s *sequence) observe(v any) {
+	n, ok := v.(int64)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > s.last {
+		s.last = n
+	}
+}
+
+// observeSequences records the identity column values of a stored row.
+func (h *tableHeap) observeSequences(values []any) {
+	for ord, seq := range h.sequences {
+		seq.observe(RowValue(values, ord))
+	}
+}
+
+// sequencePositions returns the last value of each identity sequence of
+// the table, by column ordinal.
+func (h *tableHeap) sequencePositions() map[int]int64 {
+	pos := make(map[int]int64, len(h.sequences))
+	for ord, seq := range h.sequences {
+		seq.mu.Lock()
+		pos[ord] = seq.last
+		seq.mu.Unlock()
+	}
+	return pos
+}
+
+// fillIdentity gives every identity column of row that has no value the
+// next value of its sequence. A value given for a GENERATED ALWAYS column
+// is an error.
+func fillIdentity(heap *tableHeap, row []any) error {
+	for _, col := range heap.def.Columns {
+		seq := heap.sequences[col.Ordinal]
+		if seq == nil {
+			continue
+		}
+		if row[col.Ordinal] != nil {
+			if col.Identity == IdentityAlways {
+				return &GeneratedAlwaysError{Column: col.Name}
+			}
+			continue
+		}
+		row[col.Ordinal] = seq.next()
+	}
+	return nil
+}
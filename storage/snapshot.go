@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// tableSnapshot is the state of one table at the moment of a snapshot.
+type tableSnapshot struct {
+	name      string
+	rows      rowStore
+	sequences map[int]int64
+}
+
+// Snapshot writes a consistent copy of the database to destDir, which
+// must not exist or be empty: the catalog WAL as it is, and each table's
+// WAL as a checkpoint of its rows. Opening destDir restores the database
+// as it was when the snapshot was taken. All tables are read-locked
+// together only while their row arrays are copied, so writers wait for
+// no longer than that; the files are written after the locks are gone.
+func (e *engine) Snapshot(destDir string) error {
+	if err := checkEmptyDir(destDir); err != nil {
+		return err
+	}
+
+	catalog, tables, err := e.captureSnapshot()
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshot(destDir, catalog, tables); err != nil {
+		os.Remove(filepath.Join(destDir, catalogWALName))
+		os.RemoveAll(filepath.Join(destDir, tablesDirName))
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	return nil
+}
+
+// captureSnapshot read-locks every table and then the catalog, and copies
+// the catalog WAL and the rows and identity positions of each table. The table locks come first, in name order, as multi-table
+// commits take them; the catalog lock is only tried, since DropTable
+// holds it while it waits for a table lock. If it is taken, or the set of
+// tables changes meanwhile, the locks are released and taken again.
+func (e *engine) captureSnapshot() ([]byte, []tableSnapshot, error) {
+	for {
+		e.catalogMu.RLock()
+		names := slices.Sorted(maps.Keys(e.tableStates))
+		e.catalogMu.RUnlock()
+
+		var locked []*tableState
+		unlock := func() {
+			for _, ts := range locked {
+				ts.mu.RUnlock()
+			}
+		}
+		var err error
+		for _, name := range names {
+			var ts *tableState
+			if ts, err = e.acquireTableRead(name); err != nil {
+				break
+			}
+			locked = append(locked, ts)
+		}
+		var notFound *TableNotFoundError
+		if errors.As(err, &notFound) {
+			unlock()
+			continue
+		}
+		if err != nil {
+			unlock()
+			return nil, nil, err
+		}
+
+		if !e.catalogMu.TryRLock() {
+			unlock()
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		same := len(e.tableStates) == len(locked)
+		for i, name := range names {
+			same = same && e.tableStates[name] == locked[i]
+		}
+		if !same {
+			e.catalogMu.RUnlock()
+			unlock()
+			continue
+		}
+
+		catalog, err := os.ReadFile(filepath.Join(e.dataDir, catalogWALName))
+		tables := make([]tableSnapshot, len(names))
+		for i, ts := range locked {
+			tables[i] = tableSnapshot{
+				name:      names[i],
+				rows:      ts.heap.rows.snapshot(),
+				sequences: ts.heap.sequencePositions(),
+			}
+		}
+		e.catalogMu.RUnlock()
+		unlock()
+		if err != nil {
+			return nil, nil, fmt.Errorf("read catalog WAL: %w", err)
+		}
+		return catalog, tables, nil
+	}
+}
+
+// checkEmptyDir reports an error unless dir is empty or does not exist.
+func checkEmptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	switch {
+	case err == nil && len(entries) > 0:
+		return fmt.Errorf("directory %q is not empty", dir)
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	return nil
+}
+
+// writeSnapshot writes the catalog WAL and a checkpoint of each table to
+// dir and makes them durable.
+func writeSnapshot(dir string, catalog []byte, tables []tableSnapshot) error {
+	tablesDir := filepath.Join(dir, tablesDirName)
+	if err := os.MkdirAll(tablesDir, 0755); err != nil {
+		return err
+	}
+	if err := writeFileSync(filepath.Join(dir, catalogWALName), catalog); err != nil {
+		return err
+	}
+	for _, t := range tables {
+		path := filepath.Join(tablesDir, tableFileName(t.name))
+		if err := writeCheckpoint(path, t.name, &t.rows, t.sequences); err != nil {
+			return err
+		}
+	}
+	if err := syncDir(tablesDir); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// writeFileSync writes data to a new file at path and fsyncs it.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Snapshot writes a snapshot of the committed database; the transaction's
+// own changes are not part of it.
+func (tx *TxEngine) Snapshot(destDir string) error {
+	return tx.real.Snapshot(destDir)
+}
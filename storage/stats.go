@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// Planner statistics gathered by ANALYZE. They are a snapshot: rows written
+// afterwards are not reflected until the next ANALYZE, and statistics are
+// kept in memory only, so a restart starts without them.
+
+const (
+	statsBuckets    = 100 // histogram buckets per column
+	statsMostCommon = 10  // most-common values tracked per column
+)
+
+// TableStats holds the statistics of one table as of its last ANALYZE.
+type TableStats struct {
+	RowCount int64                   // rows when analyzed
+	Columns  map[string]*ColumnStats // by column name; indexed columns only
+}
+
+// ColumnStats describes the value distribution of one column in the
+// PostgreSQL manner: values much more frequent than average are counted
+// exactly in MostCommon, and an equi-depth histogram covers the rest.
+type ColumnStats struct {
+	NullCount  int64
+	MostCommon []ValueCount // most frequent first
+	// Bounds splits the remaining values into buckets of equal row count:
+	// bucket i holds the values between Bounds[i] and Bounds[i+1].
+	Bounds   []any
+	HistRows int64 // rows described by Bounds
+	Distinct int64 // distinct values described by Bounds
+}
+
+// ValueCount is one entry of a most-common-values list.
+type ValueCount struct {
+	Value any
+	Count int64
+}
+
+// buildColumnStats summarises values, which are modified (sorted).
+func buildColumnStats(values []any) *ColumnStats {
+	cs := &ColumnStats{}
+	nonNull := values[:0]
+	for _, v := range values {
+		if v == nil {
+			cs.NullCount++
+			continue
+		}
+		nonNull = append(nonNull, v)
+	}
+	if len(nonNull) == 0 {
+		return cs
+	}
+	sort.Slice(nonNull, func(i, j int) bool { return CompareValues(nonNull[i], nonNull[j]) < 0 })
+
+	// Collapse runs of equal values.
+	var groups []ValueCount
+	for _, v := range nonNull {
+		if n := len(groups); n > 0 && CompareValues(groups[n-1].Value, v) == 0 {
+			groups[n-1].Count++
+			continue
+		}
+		groups = append(groups, ValueCount{Value: v, Count: 1})
+	}
+
+	// A value is "common" if it occurs at least twice as often as the
+	// average value.
+	avg := float64(len(nonNull)) / float64(len(groups))
+	byCount := make([]ValueCount, len(groups))
+	copy(byCount, groups)
+	sort.SliceStable(byCount, func(i, j int) bool { return byCount[i].Count > byCount[j].Count })
+	common := make(map[int]bool)
+	for _, g := range byCount {
+		if len(cs.MostCommon) == statsMostCommon || g.Count < 2 || float64(g.Count) < 2*avg {
+			break
+		}
+		cs.MostCommon = append(cs.MostCommon, g)
+	}
+	for i, g := range groups {
+		for _, mc := range cs.MostCommon {
+			if CompareValues(g.Value, mc.Value) == 0 {
+				common[i] = true
+			}
+		}
+	}
+
+	// Equi-depth histogram over the values not in MostCommon.
+	var rest []any
+	for i, g := range groups {
+		if common[i] {
+			continue
+		}
+		cs.Distinct++
+		for n := int64(0); n < g.Count; n++ {
+			rest = append(rest, g.Value)
+		}
+	}
+	cs.HistRows = int64(len(rest))
+	if len(rest) == 0 {
+		return cs
+	}
+	buckets := statsBuckets
+	if len(rest)-1 < buckets {
+		buckets = len(rest) - 1
+	}
+	if buckets == 0 {
+		cs.Bounds = []any{rest[0], rest[0]}
+		return cs
+	}
+	cs.Bounds = make([]any, buckets+1)
+	for i := range cs.Bounds {
+		cs.Bounds[i] = rest[i*(len(rest)-1)/buckets]
+	}
+	return cs
+}
+
+// rows is the number of rows the statistics were built from.
+func (cs *ColumnStats) rows() int64 {
+	n := cs.NullCount + cs.HistRows
+	for _, mc := range cs.MostCommon {
+		n += mc.Count
+	}
+	return n
+}
+
+// EqualFraction estimates the fraction of rows whose value equals v.
+func (cs *ColumnStats) EqualFraction(v any) float64 {
+	total := cs.rows()
+	if total == 0 || v == nil {
+		return 0
+	}
+	for _, mc := range cs.MostCommon {
+		if CompareValues(mc.Value, v) == 0 {
+			return float64(mc.Count) / float64(total)
+		}
+	}
+	if cs.HistRows == 0 || CompareValues(v, cs.Bounds[0]) < 0 || CompareValues(v, cs.Bounds[len(cs.Bounds)-1]) > 0 {
+		return 0
+	}
+	return float64(cs.HistRows) / float64(cs.Distinct) / float64(total)
+}
+
+// RangeFraction estimates the fraction of rows with lo <= value <= hi. A
+// nil bound is open.
+func (cs *ColumnStats) RangeFraction(lo, hi any) float64 {
+	total := cs.rows()
+	if total == 0 {
+		return 0
+	}
+	inRange := func(v any) bool {
+		return (lo == nil || CompareValues(v, lo) >= 0) && (hi == nil || CompareValues(v, hi) <= 0)
+	}
+	var rows float64
+	for _, mc := range cs.MostCommon {
+		if inRange(mc.Value) {
+			rows += float64(mc.Count)
+		}
+	}
+	if cs.HistRows > 0 {
+		buckets := len(cs.Bounds) - 1
+		if buckets == 0 {
+			if inRange(cs.Bounds[0]) {
+				rows += float64(cs.HistRows)
+			}
+		} else {
+			perBucket := float64(cs.HistRows) / float64(buckets)
+			for i := 0; i < buckets; i++ {
+				rows += perBucket * bucketOverlap(cs.Bounds[i], cs.Bounds[i+1], lo, hi)
+			}
+		}
+	}
+	return rows / float64(total)
+}
+
+// bucketOverlap returns the share of the bucket [b0, b1] that lies within
+// [lo, hi], interpolating linearly for numbers and timestamps and assuming
+// half for partially covered buckets of other types.
+func bucketOverlap(b0, b1, lo, hi any) float64 {
+	if (lo != nil && CompareValues(b1, lo) < 0) || (hi != nil && CompareValues(b0, hi) > 0) {
+		return 0
+	}
+	if (lo == nil || CompareValues(b0, lo) >= 0) && (hi == nil || CompareValues(b1, hi) <= 0) {
+		return 1
+	}
+	f0, ok0 := statsPosition(b0)
+	f1, ok1 := statsPosition(b1)
+	if !ok0 || !ok1 || f1 <= f0 {
+		return 0.5
+	}
+	from, to := f0, f1
+	if lo != nil {
+		if f, ok := statsPosition(lo); ok && f > from {
+			from = f
+		}
+	}
+	if hi != nil {
+		if f, ok := statsPosition(hi); ok && f < to {
+			to = f
+		}
+	}
+	if to < from {
+		return 0
+	}
+	return (to - from) / (f1 - f0)
+}
+
+// statsPosition maps an orderable value onto a number line for histogram
+// interpolation.
+func statsPosition(v any) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case time.Time:
+		return float64(x.UnixMicro()), true
+	case TimeOfDay:
+		return float64(x), true
+	default:
+		return 0, false
+	}
+}
+
+// analyzeHeap builds statistics for the primary key and every indexed
+// column of heap.
+func analyzeHeap(heap *tableHeap) *TableStats {
+	stats := &TableStats{Columns: make(map[string]*ColumnStats)}
+	ords := make(map[string]int)
+	if heap.pkCol >= 0 {
+		ords[heap.pkColumnName()] = heap.pkCol
+	}
+	for i := range heap.secondaries {
+		si := &heap.secondaries[i]
+		for j, name := range si.def.Columns {
+			ords[name] = si.colOrds[j]
+		}
+	}
+
+	columns := make(map[string][]any, len(ords))
+	for _, values := range heap.rows.all() {
+		stats.RowCount++
+		for name, ord := range ords {
+			columns[name] = append(columns[name], RowValue(values, ord))
+		}
+	}
+	for name := range ords {
+		stats.Columns[name] = buildColumnStats(columns[name])
+	}
+	return stats
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeOfDay is a TIME value: a time of day without a date or time zone, in
+// microseconds since midnight. 24:00:00 is allowed, as in PostgreSQL.
+type TimeOfDay int64
+
+// MaxTimeOfDay is 24:00:00, the largest TIME value.
+const MaxTimeOfDay = TimeOfDay(24 * time.Hour / time.Microsecond)
+
+// TimeOfDayOf returns the time of day of t.
+func TimeOfDayOf(t time.Time) TimeOfDay {
+	h, m, s := t.Clock()
+	return TimeOfDay(((int64(h)*60+int64(m))*60+int64(s))*1e6 + int64(t.Nanosecond()/1e3))
+}
+
+// ParseTime parses the text form of a TIME value: HH:MM, HH:MM:SS or
+// HH:MM:SS.ffffff, where fractional seconds past microseconds are rounded.
+// The time part of a timestamp, such as '2024-01-15 12:30:00', is taken
+// as well.
+func ParseTime(s string) (TimeOfDay, error) {
+	in := strings.TrimSpace(s)
+	if t, err := ParseTimestamp(in); err == nil && strings.ContainsAny(in, " T") {
+		return TimeOfDayOf(t), nil
+	}
+	parts := strings.Split(in, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || len(parts[0]) > 2 || len(parts[1]) != 2 ||
+		h < 0 || h > 24 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var usec int64
+	if len(parts) == 3 {
+		sec, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || len(parts[2]) < 2 || parts[2][0] < '0' || parts[2][0] > '9' || sec < 0 || sec >= 60 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		usec = int64(sec*1e6 + 0.5)
+	}
+	t := TimeOfDay((int64(h)*60+int64(m))*60*1e6 + usec)
+	if t > MaxTimeOfDay {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t, nil
+}
+
+// String returns t as PostgreSQL prints a TIME, e.g. 15:04:05 or
+// 15:04:05.25; fractional seconds are shown only when present.
+func (t TimeOfDay) String() string {
+	usec := int64(t)
+	sec := usec / 1e6
+	s := fmt.Sprintf("%02d:%02d:%02d", sec/3600, sec/60%60, sec%60)
+	if frac := usec % 1e6; frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", frac), "0")
+	}
+	return s
+}
@@ -1,9 +1,11 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,7 @@ var timestampFormats = []string{
 	"2006-01-02 15:04:05Z07:00",        // full with timezone
 	"2006-01-02T15:04:05.999999Z07:00", // ISO 8601 with fractional seconds
 	"2006-01-02T15:04:05Z07:00",        // ISO 8601
+	"2006-01-02 15:04:05.999999-07",    // PostgreSQL output form, offset in hours
 	"2006-01-02 15:04:05.999999",       // no timezone, fractional seconds (assumed UTC)
 	"2006-01-02 15:04:05",              // no timezone (assumed UTC)
 	"2006-01-02T15:04:05",              // ISO 8601 no timezone (assumed UTC)
@@ -33,8 +36,15 @@ func ParseTimestamp(s string) (time.Time, error) {
 }
 
 // coerceRowValues validates and coerces values to match the column types
-// in def. TIMESTAMP columns coerce strings to time.Time, and FLOAT columns
-// coerce strings and integers to float64.
+// in def, as PostgreSQL's assignment casts do. INTEGER columns parse
+// strings and round floats and NUMERICs, TEXT columns take the text form
+// of numbers, booleans and timestamps, BOOLEAN columns parse strings such
+// as 't' or 'off', TIMESTAMP columns coerce strings to time.Time, TIME
+// columns parse strings with ParseTime and take the time of day of
+// timestamps, FLOAT columns coerce strings and integers to float64, BYTEA
+// columns parse strings with ParseBytea, and NUMERIC columns round to the
+// column's scale and check its precision. A string that does not parse is an
+// InvalidValueError, a value of an unrelated type a DatatypeMismatchError.
 // Uses col.Ordinal to index into the values slice (ordinal-based storage).
 func coerceRowValues(def *TableDef, values []any) ([]any, error) {
 	for _, col := range def.Columns {
@@ -42,39 +52,200 @@ func coerceRowValues(def *TableDef, values []any) ([]any, error) {
 		if ord >= len(values) || values[ord] == nil {
 			continue
 		}
+		mismatch := &DatatypeMismatchError{Column: col.Name, Type: pgTypeName(col.DataType), Got: valueTypeName(values[ord])}
 		switch col.DataType {
+		case TypeInteger:
+			switch v := values[ord].(type) {
+			case int64:
+				continue
+			case string:
+				n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+				if err != nil {
+					return nil, &InvalidValueError{Type: "integer", Value: v}
+				}
+				values[ord] = n
+			case float64:
+				r := math.Round(v)
+				if math.IsNaN(r) || r < math.MinInt64 || r >= math.MaxInt64 {
+					return nil, &NumericOverflowError{}
+				}
+				values[ord] = int64(r)
+			case Numeric:
+				n, ok := v.Int64()
+				if !ok {
+					return nil, &NumericOverflowError{}
+				}
+				values[ord] = n
+			default:
+				return nil, mismatch
+			}
+		case TypeText:
+			switch v := values[ord].(type) {
+			case string:
+				continue
+			case int64:
+				values[ord] = strconv.FormatInt(v, 10)
+			case float64:
+				values[ord] = strconv.FormatFloat(v, 'g', -1, 64)
+			case Numeric:
+				values[ord] = v.String()
+			case bool:
+				values[ord] = strconv.FormatBool(v)
+			case time.Time:
+				values[ord] = v.Format("2006-01-02 15:04:05.999999+00")
+			case TimeOfDay:
+				values[ord] = v.String()
+			default:
+				return nil, mismatch
+			}
+		case TypeBoolean:
+			switch v := values[ord].(type) {
+			case bool:
+				continue
+			case string:
+				b, ok := parseBool(v)
+				if !ok {
+					return nil, &InvalidValueError{Type: "boolean", Value: v}
+				}
+				values[ord] = b
+			default:
+				return nil, mismatch
+			}
 		case TypeTimestamp:
 			if _, ok := values[ord].(time.Time); ok {
 				continue // already a time.Time
 			}
 			s, ok := values[ord].(string)
 			if !ok {
-				return nil, fmt.Errorf("column %q expects TIMESTAMP, got %T", col.Name, values[ord])
+				return nil, mismatch
 			}
 			t, err := ParseTimestamp(s)
 			if err != nil {
 				return nil, fmt.Errorf("column %q: %w", col.Name, err)
 			}
 			values[ord] = t
+		case TypeTime:
+			switch v := values[ord].(type) {
+			case TimeOfDay:
+				continue // already a TimeOfDay
+			case time.Time:
+				values[ord] = TimeOfDayOf(v)
+			case string:
+				t, err := ParseTime(v)
+				if err != nil {
+					return nil, &InvalidValueError{Type: "time without time zone", Value: v}
+				}
+				values[ord] = t
+			default:
+				return nil, mismatch
+			}
 		case TypeFloat:
 			switch v := values[ord].(type) {
 			case float64:
 				continue // already float64
 			case int64:
 				values[ord] = float64(v)
+			case Numeric:
+				values[ord] = v.Float64()
 			case string:
-				f, err := strconv.ParseFloat(v, 64)
+				f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
 				if err != nil {
-					return nil, fmt.Errorf("column %q: invalid float %q", col.Name, v)
+					return nil, &InvalidValueError{Type: "double precision", Value: v}
 				}
 				if math.IsNaN(f) || math.IsInf(f, 0) {
 					return nil, fmt.Errorf("column %q: NaN and Infinity are not supported", col.Name)
 				}
 				values[ord] = f
 			default:
-				return nil, fmt.Errorf("column %q expects FLOAT, got %T", col.Name, values[ord])
+				return nil, mismatch
+			}
+		case TypeBytea:
+			switch v := values[ord].(type) {
+			case []byte:
+				continue // already []byte
+			case string:
+				b, err := ParseBytea(v)
+				if err != nil {
+					return nil, fmt.Errorf("column %q: %w", col.Name, err)
+				}
+				values[ord] = b
+			default:
+				return nil, mismatch
 			}
+		case TypeNumeric:
+			switch values[ord].(type) {
+			case bool, time.Time, TimeOfDay, []byte:
+				return nil, mismatch
+			}
+			n, err := CoerceNumeric(values[ord], col.Precision, col.Scale)
+			if err != nil {
+				if s, ok := values[ord].(string); ok && !errors.As(err, new(*NumericOverflowError)) {
+					return nil, &InvalidValueError{Type: "numeric", Value: s}
+				}
+				return nil, fmt.Errorf("column %q: %w", col.Name, err)
+			}
+			values[ord] = n
 		}
 	}
 	return values, nil
 }
+
+// parseBool reads the spellings of a boolean PostgreSQL accepts, in any
+// case and around spaces.
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "yes", "y", "on", "1":
+		return true, true
+	case "false", "f", "no", "n", "off", "0":
+		return false, true
+	}
+	return false, false
+}
+
+// pgTypeName returns the name PostgreSQL gives to values of type dt.
+func pgTypeName(dt DataType) string {
+	switch dt {
+	case TypeInteger:
+		return "bigint"
+	case TypeText:
+		return "text"
+	case TypeBoolean:
+		return "boolean"
+	case TypeTimestamp:
+		return "timestamp with time zone"
+	case TypeFloat:
+		return "double precision"
+	case TypeBytea:
+		return "bytea"
+	case TypeNumeric:
+		return "numeric"
+	case TypeTime:
+		return "time without time zone"
+	default:
+		return "unknown"
+	}
+}
+
+// valueTypeName returns the PostgreSQL name of the type of a stored value.
+func valueTypeName(v any) string {
+	switch v.(type) {
+	case int64:
+		return "bigint"
+	case string:
+		return "text"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "timestamp with time zone"
+	case float64:
+		return "double precision"
+	case []byte:
+		return "bytea"
+	case Numeric:
+		return "numeric"
+	case TimeOfDay:
+		return "time without time zone"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
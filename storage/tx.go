@@ -61,6 +61,36 @@ func (o *TxOverlay) GetUpdate(table string, rowID int64) ([]any, bool) {
 	return nil, false
 }
 
+// Discard drops the pending changes to table.
+func (o *TxOverlay) Discard(table string) {
+	delete(o.Inserts, table)
+	delete(o.Deletes, table)
+	delete(o.Updates, table)
+}
+
+// FillColumn gives col its Fill value in the pending rows of table that
+// predate it, as tableHeap.fillColumn does for stored rows.
+func (o *TxOverlay) FillColumn(table string, col ColumnDef) {
+	if col.Fill == nil {
+		return
+	}
+	fill := func(values []any) []any {
+		if len(values) > col.Ordinal {
+			return values
+		}
+		filled := make([]any, col.Ordinal+1)
+		copy(filled, values)
+		filled[col.Ordinal] = col.Fill
+		return filled
+	}
+	for i := range o.Inserts[table] {
+		o.Inserts[table][i].Values = fill(o.Inserts[table][i].Values)
+	}
+	for id, values := range o.Updates[table] {
+		o.Updates[table][id] = fill(values)
+	}
+}
+
 // TouchedTables returns a sorted list of table names that have any changes.
 func (o *TxOverlay) TouchedTables() []string {
 	seen := make(map[string]bool)
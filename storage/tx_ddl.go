@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// txSchema holds the DDL of a transaction until COMMIT. A table the
+// transaction creates, or a committed table it alters, lives in a private
+// copy: a catalog entry and a heap without a WAL, which the transaction's
+// reads and writes use in place of the real table, with the overlay on top
+// as for any other table. Tables it drops are hidden. Nothing reaches the
+// catalog WAL or the tables directory before COMMIT, so a transaction that
+// rolls back leaves no trace.
+type txSchema struct {
+	catalog *catalog             // definitions of the private tables
+	tables  map[string]*txTable  // the private tables
+	dropped map[string]bool      // committed tables the transaction dropped
+	seen    map[string]*TableDef // committed definition of each table the DDL touched, nil if there was none
+	ops     []txOp               // to replay on the real engine at COMMIT
+}
+
+// txTable is a table private to a transaction.
+type txTable struct {
+	state   *tableState
+	created bool // created by the transaction, not copied from a committed table
+}
+
+// txOp is a DDL command to replay on the real engine at COMMIT.
+type txOp struct {
+	table  string
+	create bool // CREATE TABLE
+	apply  func(*engine) error
+}
+
+// ddl returns the transaction's schema changes, recording the committed
+// definition of table the first time a DDL command names it.
+func (tx *TxEngine) ddl(table string) *txSchema {
+	s := tx.schema
+	if s == nil {
+		s = &txSchema{
+			catalog: newCatalog(),
+			tables:  make(map[string]*txTable),
+			dropped: make(map[string]bool),
+			seen:    make(map[string]*TableDef),
+		}
+		tx.schema = s
+	}
+	if _, ok := s.seen[table]; !ok {
+		tx.real.catalogMu.RLock()
+		var def *TableDef
+		if committed, ok := tx.real.catalog.getTable(table); ok {
+			def = cloneTableDef(committed)
+		}
+		tx.real.catalogMu.RUnlock()
+		s.seen[table] = def
+	}
+	return s
+}
+
+// privateTable returns the transaction's own copy of table, if it has one.
+func (tx *TxEngine) privateTable(table string) (*txTable, bool) {
+	if tx.schema == nil {
+		return nil, false
+	}
+	t, ok := tx.schema.tables[table]
+	return t, ok
+}
+
+// alterTable returns the transaction's own copy of table for a DDL command
+// to change, copying the committed table the first time.
+func (tx *TxEngine) alterTable(op, table string) (*txSchema, *tableState, error) {
+	if tx.readOnly {
+		return nil, nil, &ReadOnlyTxError{Op: op}
+	}
+	if t, ok := tx.privateTable(table); ok {
+		return tx.schema, t.state, nil
+	}
+	if tx.schema != nil && tx.schema.dropped[table] {
+		return nil, nil, &TableNotFoundError{Name: table}
+	}
+	ts, err := tx.real.acquireTableRead(table)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer ts.mu.RUnlock()
+
+	s := tx.ddl(table)
+	def := cloneTableDef(&ts.heap.def)
+	heap, err := ts.heap.clone(*def)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.catalog.tables[table] = def
+	state := &tableState{heap: heap}
+	s.tables[table] = &txTable{state: state}
+	return s, state, nil
+}
+
+// acquireTableRead read-locks the table as the transaction sees it: its
+// private copy if it has one, and otherwise the committed table unless the
+// transaction dropped it.
+func (tx *TxEngine) acquireTableRead(name string) (*tableState, error) {
+	if s := tx.schema; s != nil {
+		if t, ok := s.tables[name]; ok {
+			t.state.mu.RLock()
+			return t.state, nil
+		}
+		if s.dropped[name] {
+			return nil, &TableNotFoundError{Name: name}
+		}
+	}
+	ts, err := tx.real.acquireTableRead(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tx.vacuums[name]; !ok {
+		if tx.vacuums == nil {
+			tx.vacuums = make(map[string]uint64)
+		}
+		tx.vacuums[name] = ts.vacuums
+	}
+	return ts, nil
+}
+
+func (tx *TxEngine) CreateTable(name string, columns []ColumnDef) error {
+	if tx.readOnly {
+		return &ReadOnlyTxError{Op: "CREATE TABLE"}
+	}
+	if _, ok := tx.GetTable(name); ok {
+		return &TableExistsError{Name: name}
+	}
+	if _, ok := tx.real.GetView(name); ok {
+		return &TableExistsError{Name: name}
+	}
+
+	// Assign sequential ordinals 0..N-1, as the engine will at COMMIT.
+	for i := range columns {
+		columns[i].Ordinal = i
+	}
+
+	s := tx.ddl(name)
+	if err := s.catalog.createTable(name, slices.Clone(columns)); err != nil {
+		return err
+	}
+	s.tables[name] = &txTable{
+		state:   &tableState{heap: newTableHeap(*s.catalog.tables[name])},
+		created: true,
+	}
+	cols := slices.Clone(columns)
+	s.ops = append(s.ops, txOp{table: name, create: true, apply: func(e *engine) error {
+		return e.CreateTable(name, cols)
+	}})
+	return nil
+}
+
+func (tx *TxEngine) DropTable(name string) error {
+	if tx.readOnly {
+		return &ReadOnlyTxError{Op: "DROP TABLE"}
+	}
+	if _, ok := tx.GetTable(name); !ok {
+		return &TableNotFoundError{Name: name}
+	}
+	s := tx.ddl(name)
+	tx.overlay.Discard(name)
+
+	// The commands run on the table since the transaction created or
+	// copied it have no effect once it is gone. A table it created never
+	// reaches the real engine at all.
+	t, private := s.tables[name]
+	from := 0
+	if private && t.created {
+		from = slices.IndexFunc(s.ops, func(op txOp) bool { return op.table == name && op.create })
+		for i := from + 1; i < len(s.ops); i++ {
+			if s.ops[i].table == name && s.ops[i].create {
+				from = i
+			}
+		}
+	}
+	s.ops = append(s.ops[:from], slices.DeleteFunc(s.ops[from:], func(op txOp) bool { return op.table == name })...)
+	delete(s.tables, name)
+	s.catalog.dropTable(name)
+	if private && t.created {
+		return nil
+	}
+
+	s.dropped[name] = true
+	s.ops = append(s.ops, txOp{table: name, apply: func(e *engine) error {
+		return e.DropTable(name)
+	}})
+	return nil
+}
+
+func (tx *TxEngine) AddColumn(table string, col ColumnDef) error {
+	rows, err := tx.RowCount(table)
+	if err != nil {
+		return err
+	}
+	s, ts, err := tx.alterTable("ALTER TABLE", table)
+	if err != nil {
+		return err
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	col, err = prepareColumn(ts.heap, rows, col)
+	if err != nil {
+		return err
+	}
+	if err := s.catalog.addColumn(table, col); err != nil {
+		return err
+	}
+	ts.heap.def = *s.catalog.tables[table]
+	ts.heap.addColumn(col)
+	tx.overlay.FillColumn(table, col)
+	s.ops = append(s.ops, txOp{table: table, apply: func(e *engine) error {
+		return e.AddColumn(table, col)
+	}})
+	return nil
+}
+
+func (tx *TxEngine) DropColumn(table string, colName string) error {
+	s, ts, err := tx.alterTable("ALTER TABLE", table)
+	if err != nil {
+		return err
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if err := s.catalog.dropColumn(table, colName); err != nil {
+		return err
+	}
+	ts.heap.def = *s.catalog.tables[table]
+	s.ops = append(s.ops, txOp{table: table, apply: func(e *engine) error {
+		return e.DropColumn(table, colName)
+	}})
+	return nil
+}
+
+func (tx *TxEngine) CreateIndex(table string, idx IndexDef) error {
+	s, ts, err := tx.alterTable("CREATE INDEX", table)
+	if err != nil {
+		return err
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if len(idx.Columns) == 0 {
+		return fmt.Errorf("index %q has no columns", idx.Name)
+	}
+	for _, name := range idx.Columns {
+		if ts.heap.columnIndex(name) < 0 {
+			return &ColumnNotFoundError{Column: name, Table: table}
+		}
+	}
+	for _, existing := range ts.heap.def.Indexes {
+		if existing.Name == idx.Name {
+			return &IndexExistsError{Name: idx.Name, Table: table}
+		}
+	}
+
+	// The heap holds the rows the copy started with; the rows the
+	// transaction wrote are in the overlay and are checked against each
+	// other and the heap's as well.
+	if err := ts.heap.addSecondaryIndex(idx); err != nil {
+		return err
+	}
+	if idx.Unique {
+		if err := tx.checkUnique(table, ts.heap, &ts.heap.secondaries[len(ts.heap.secondaries)-1]); err != nil {
+			ts.heap.removeSecondaryIndex(idx.Name)
+			return err
+		}
+	}
+	if err := s.catalog.createIndex(table, idx); err != nil {
+		ts.heap.removeSecondaryIndex(idx.Name)
+		return err
+	}
+	ts.heap.def = *s.catalog.tables[table]
+	s.ops = append(s.ops, txOp{table: table, apply: func(e *engine) error {
+		return e.CreateIndex(table, idx)
+	}})
+	return nil
+}
+
+func (tx *TxEngine) DropIndex(table string, indexName string) error {
+	s, ts, err := tx.alterTable("DROP INDEX", table)
+	if err != nil {
+		return err
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if err := s.catalog.dropIndex(table, indexName); err != nil {
+		return err
+	}
+	ts.heap.removeSecondaryIndex(indexName)
+	ts.heap.def = *s.catalog.tables[table]
+	s.ops = append(s.ops, txOp{table: table, apply: func(e *engine) error {
+		return e.DropIndex(table, indexName)
+	}})
+	return nil
+}
+
+// checkUnique reports a UniqueViolationError if two rows of table, as the
+// transaction sees it, share a key of the unique index si of heap. The
+// caller holds the heap's lock.
+func (tx *TxEngine) checkUnique(table string, heap *tableHeap, si *secondaryIdx) error {
+	seen := make(map[any]bool)
+	check := func(values []any) error {
+		key := si.key(values)
+		if key == nil {
+			return nil
+		}
+		if seen[mapKey(key)] {
+			return &UniqueViolationError{Table: table, Column: si.def.ColumnList(), Value: key, Index: si.def.Name}
+		}
+		seen[mapKey(key)] = true
+		return nil
+	}
+	for id, values := range heap.rows.all() {
+		if tx.overlay.IsDeleted(table, id) {
+			continue
+		}
+		if upd, ok := tx.overlay.GetUpdate(table, id); ok {
+			values = upd
+		}
+		if err := check(values); err != nil {
+			return err
+		}
+	}
+	for _, ins := range tx.overlay.Inserts[table] {
+		if err := check(ins.Values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitSchema replays the transaction's DDL on the real engine. It first
+// checks that every table the DDL touched is still as the transaction
+// found it, so that the commands do not fail half way through on a
+// change another session committed in the meantime.
+func (tx *TxEngine) commitSchema() error {
+	s := tx.schema
+	if s == nil {
+		return nil
+	}
+	tx.real.catalogMu.RLock()
+	for name, def := range s.seen {
+		current, exists := tx.real.catalog.getTable(name)
+		if _, isView := tx.real.catalog.getView(name); isView ||
+			exists != (def != nil) || exists && !reflect.DeepEqual(current, def) {
+			tx.real.catalogMu.RUnlock()
+			return &SchemaChangedError{Table: name}
+		}
+	}
+	tx.real.catalogMu.RUnlock()
+
+	for _, op := range s.ops {
+		if err := op.apply(tx.real); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneTableDef returns a copy of def that shares no slices with it, as the
+// catalog changes definitions in place.
+func cloneTableDef(def *TableDef) *TableDef {
+	c := *def
+	c.Columns = slices.Clone(def.Columns)
+	c.Indexes = slices.Clone(def.Indexes)
+	for i := range c.Indexes {
+		c.Indexes[i].Columns = slices.Clone(c.Indexes[i].Columns)
+	}
+	return &c
+}
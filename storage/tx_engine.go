@@ -1,14 +1,26 @@
 package storage
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+
+	"mulldb/storage/index"
+)
 
 // TxEngine wraps a real Engine and intercepts reads/writes to use a
 // transaction overlay. Writes go to the overlay; reads merge the overlay
 // with the real heap. On COMMIT, the overlay is applied atomically to the
 // real engine.
 type TxEngine struct {
-	real    *engine
-	overlay *TxOverlay
+	real     *engine
+	overlay  *TxOverlay
+	readOnly bool      // BEGIN READ ONLY / SET TRANSACTION READ ONLY
+	schema   *txSchema // DDL of the transaction; nil until the first
+
+	// vacuums records, for each committed table the transaction has read,
+	// the table's VACUUM count at the time. The row IDs in the overlay are
+	// only valid if it is unchanged at COMMIT.
+	vacuums map[string]uint64
 }
 
 // NewTxEngine creates a transaction engine wrapping the given engine.
@@ -24,51 +36,103 @@ func (tx *TxEngine) Overlay() *TxOverlay {
 	return tx.overlay
 }
 
+// SetReadOnly sets the transaction's access mode. A read-only transaction
+// rejects every write and every DDL command with a ReadOnlyTxError.
+func (tx *TxEngine) SetReadOnly(readOnly bool) {
+	tx.readOnly = readOnly
+}
+
+// ReadOnly reports whether the transaction is read-only.
+func (tx *TxEngine) ReadOnly() bool {
+	return tx.readOnly
+}
+
 // -------------------------------------------------------------------------
-// DDL — rejected inside transactions
+// DDL — tables and indexes are buffered (see tx_ddl.go); views and
+// TRUNCATE are rejected inside transactions
 // -------------------------------------------------------------------------
 
-func (tx *TxEngine) CreateTable(string, []ColumnDef) error {
-	return &ActiveTxError{}
+func (tx *TxEngine) CreateView(string, string) error {
+	return &ActiveTxError{Op: "CREATE VIEW"}
 }
 
-func (tx *TxEngine) DropTable(string) error {
-	return &ActiveTxError{}
+func (tx *TxEngine) DropView(string) error {
+	return &ActiveTxError{Op: "DROP VIEW"}
 }
 
-func (tx *TxEngine) AddColumn(string, ColumnDef) error {
-	return &ActiveTxError{}
+// Truncate is rejected: the overlay has no way to express "every row of
+// the heap is gone".
+func (tx *TxEngine) Truncate([]string, bool) error {
+	return &ActiveTxError{Op: "TRUNCATE"}
 }
 
-func (tx *TxEngine) DropColumn(string, string) error {
-	return &ActiveTxError{}
+// Vacuum is rejected: renumbering the rows would invalidate the row IDs
+// the overlay refers to.
+func (tx *TxEngine) Vacuum(string) (int64, error) {
+	return 0, &ActiveTxError{Op: "VACUUM"}
 }
 
-func (tx *TxEngine) CreateIndex(string, IndexDef) error {
-	return &ActiveTxError{}
+// ActiveTxError is returned when a statement that cannot be part of a
+// transaction is run inside one.
+type ActiveTxError struct {
+	Op string // CREATE VIEW, DROP VIEW, TRUNCATE or VACUUM
 }
 
-func (tx *TxEngine) DropIndex(string, string) error {
-	return &ActiveTxError{}
+func (e *ActiveTxError) Error() string {
+	return fmt.Sprintf("%s cannot run inside a transaction block", e.Op)
 }
 
-// ActiveTxError is returned when DDL is attempted inside a transaction.
-type ActiveTxError struct{}
+// ReadOnlyTxError is returned when a read-only transaction attempts a write.
+type ReadOnlyTxError struct {
+	Op string // INSERT, UPDATE, DELETE, ANALYZE or a DDL command
+}
 
-func (e *ActiveTxError) Error() string {
-	return "DDL commands are not allowed inside a transaction"
+func (e *ReadOnlyTxError) Error() string {
+	return fmt.Sprintf("cannot execute %s in a read-only transaction", e.Op)
 }
 
 // -------------------------------------------------------------------------
 // Read-only metadata — delegate to real engine
 // -------------------------------------------------------------------------
 
+// GetTable and ListTables show the transaction's own tables in place of
+// the committed ones and leave out the tables it dropped.
 func (tx *TxEngine) GetTable(name string) (*TableDef, bool) {
+	if s := tx.schema; s != nil {
+		if def, ok := s.catalog.getTable(name); ok {
+			return def, true
+		}
+		if s.dropped[name] {
+			return nil, false
+		}
+	}
 	return tx.real.GetTable(name)
 }
 
 func (tx *TxEngine) ListTables() []*TableDef {
-	return tx.real.ListTables()
+	defs := tx.real.ListTables()
+	s := tx.schema
+	if s == nil {
+		return defs
+	}
+	out := make([]*TableDef, 0, len(defs)+len(s.catalog.tables))
+	for _, def := range defs {
+		if _, private := s.catalog.tables[def.Name]; !private && !s.dropped[def.Name] {
+			out = append(out, def)
+		}
+	}
+	for _, def := range s.catalog.tables {
+		out = append(out, def)
+	}
+	return out
+}
+
+func (tx *TxEngine) GetView(name string) (*ViewDef, bool) {
+	return tx.real.GetView(name)
+}
+
+func (tx *TxEngine) ListViews() []*ViewDef {
+	return tx.real.ListViews()
 }
 
 func (tx *TxEngine) MemoryUsage() []TableMemoryInfo {
@@ -92,11 +156,19 @@ func (tx *TxEngine) Close() error {
 // -------------------------------------------------------------------------
 
 func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int64, error) {
+	rows, err := tx.InsertReturning(table, columns, values)
+	return int64(len(rows)), err
+}
+
+func (tx *TxEngine) InsertReturning(table string, columns []string, values [][]any) ([]Row, error) {
+	if tx.readOnly {
+		return nil, &ReadOnlyTxError{Op: "INSERT"}
+	}
 	// We need to acquire a brief read lock on the table to get the heap
 	// for constraint validation, then release it and buffer in overlay.
-	ts, err := tx.real.acquireTableRead(table)
+	ts, err := tx.acquireTableRead(table)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	heap := ts.heap
 
@@ -106,7 +178,7 @@ func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int6
 		fullRow, err := resolveInsertRow(heap, columns, vals)
 		if err != nil {
 			ts.mu.RUnlock()
-			return 0, err
+			return nil, err
 		}
 		resolvedRows = append(resolvedRows, fullRow)
 	}
@@ -119,7 +191,7 @@ func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int6
 		for _, fullRow := range resolvedRows {
 			if RowValue(fullRow, col.Ordinal) == nil {
 				ts.mu.RUnlock()
-				return 0, &NotNullViolationError{
+				return nil, &NotNullViolationError{
 					Table:  table,
 					Column: col.Name,
 				}
@@ -135,20 +207,20 @@ func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int6
 			key := RowValue(fullRow, heap.pkCol)
 			if key == nil {
 				ts.mu.RUnlock()
-				return 0, &UniqueViolationError{
+				return nil, &UniqueViolationError{
 					Table:  table,
 					Column: pkColName,
 				}
 			}
-			if seen[key] {
+			if seen[mapKey(key)] {
 				ts.mu.RUnlock()
-				return 0, &UniqueViolationError{
+				return nil, &UniqueViolationError{
 					Table:  table,
 					Column: pkColName,
 					Value:  key,
 				}
 			}
-			seen[key] = true
+			seen[mapKey(key)] = true
 			// Check real heap (only if not deleted in overlay).
 			if existingID, exists := heap.pkIdx.Get(key); exists {
 				if !tx.overlay.IsDeleted(table, existingID) {
@@ -157,7 +229,7 @@ func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int6
 						updKey := RowValue(updVals, heap.pkCol)
 						if CompareValues(updKey, key) == 0 {
 							ts.mu.RUnlock()
-							return 0, &UniqueViolationError{
+							return nil, &UniqueViolationError{
 								Table:  table,
 								Column: pkColName,
 								Value:  key,
@@ -166,7 +238,7 @@ func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int6
 						// PK was changed by update, original key is available
 					} else {
 						ts.mu.RUnlock()
-						return 0, &UniqueViolationError{
+						return nil, &UniqueViolationError{
 							Table:  table,
 							Column: pkColName,
 							Value:  key,
@@ -179,7 +251,7 @@ func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int6
 				insKey := RowValue(ins.Values, heap.pkCol)
 				if CompareValues(insKey, key) == 0 {
 					ts.mu.RUnlock()
-					return 0, &UniqueViolationError{
+					return nil, &UniqueViolationError{
 						Table:  table,
 						Column: pkColName,
 						Value:  key,
@@ -197,38 +269,38 @@ func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int6
 		}
 		seen := make(map[any]bool, len(resolvedRows))
 		for _, fullRow := range resolvedRows {
-			key := RowValue(fullRow, si.colOrd)
+			key := si.key(fullRow)
 			if key == nil {
 				continue
 			}
-			if seen[key] {
+			if seen[mapKey(key)] {
 				ts.mu.RUnlock()
-				return 0, &UniqueViolationError{
+				return nil, &UniqueViolationError{
 					Table:  table,
-					Column: si.def.Column,
+					Column: si.def.ColumnList(),
 					Value:  key,
 					Index:  si.def.Name,
 				}
 			}
-			seen[key] = true
+			seen[mapKey(key)] = true
 			if existingID, exists := si.unique.Get(key); exists {
 				if !tx.overlay.IsDeleted(table, existingID) {
 					if updVals, updated := tx.overlay.GetUpdate(table, existingID); updated {
-						updKey := RowValue(updVals, si.colOrd)
+						updKey := si.key(updVals)
 						if CompareValues(updKey, key) == 0 {
 							ts.mu.RUnlock()
-							return 0, &UniqueViolationError{
+							return nil, &UniqueViolationError{
 								Table:  table,
-								Column: si.def.Column,
+								Column: si.def.ColumnList(),
 								Value:  key,
 								Index:  si.def.Name,
 							}
 						}
 					} else {
 						ts.mu.RUnlock()
-						return 0, &UniqueViolationError{
+						return nil, &UniqueViolationError{
 							Table:  table,
-							Column: si.def.Column,
+							Column: si.def.ColumnList(),
 							Value:  key,
 							Index:  si.def.Name,
 						}
@@ -237,12 +309,12 @@ func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int6
 			}
 			// Check overlay inserts.
 			for _, ins := range tx.overlay.Inserts[table] {
-				insKey := RowValue(ins.Values, si.colOrd)
+				insKey := si.key(ins.Values)
 				if CompareValues(insKey, key) == 0 {
 					ts.mu.RUnlock()
-					return 0, &UniqueViolationError{
+					return nil, &UniqueViolationError{
 						Table:  table,
-						Column: si.def.Column,
+						Column: si.def.ColumnList(),
 						Value:  key,
 						Index:  si.def.Name,
 					}
@@ -252,17 +324,19 @@ func (tx *TxEngine) Insert(table string, columns []string, values [][]any) (int6
 	}
 
 	// Allocate row IDs and buffer in overlay.
-	for _, fullRow := range resolvedRows {
+	rows := make([]Row, len(resolvedRows))
+	for i, fullRow := range resolvedRows {
 		id := heap.allocateID()
 		tx.overlay.AddInsert(table, id, fullRow)
+		rows[i] = Row{ID: id, Values: fullRow}
 	}
 
 	ts.mu.RUnlock()
-	return int64(len(resolvedRows)), nil
+	return rows, nil
 }
 
 func (tx *TxEngine) Scan(table string) (RowIterator, error) {
-	ts, err := tx.real.acquireTableRead(table)
+	ts, err := tx.acquireTableRead(table)
 	if err != nil {
 		return nil, err
 	}
@@ -273,11 +347,7 @@ func (tx *TxEngine) Scan(table string) (RowIterator, error) {
 	// Build rows: scan heap, apply overlay (skip deletes, apply updates),
 	// then append overlay inserts.
 	rows := make([]Row, 0, heap.count)
-	for id, values := range heap.rows {
-		if values == nil {
-			continue
-		}
-		rowID := int64(id)
+	for rowID, values := range heap.rows.all() {
 		if tx.overlay.IsDeleted(table, rowID) {
 			continue
 		}
@@ -294,10 +364,110 @@ func (tx *TxEngine) Scan(table string) (RowIterator, error) {
 	return &sliceIterator{rows: rows}, nil
 }
 
+// ScanEqual matches the committed rows as the engine does, and the rows
+// this transaction changed or added by their values.
+func (tx *TxEngine) ScanEqual(table, column string, value any) (RowIterator, error) {
+	ts, err := tx.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.mu.RUnlock()
+
+	heap := ts.heap
+	ord := heap.columnIndex(column)
+	if ord < 0 {
+		return nil, &ColumnNotFoundError{Column: column, Table: table}
+	}
+	if value == nil {
+		return &sliceIterator{}, nil
+	}
+	equal := func(vals []any) bool { return CompareValues(RowValue(vals, ord), value) == 0 }
+	match := heap.rows.equalTo(ord, value)
+	var rows []Row
+	var scanned int64
+	for id := range int64(heap.rows.len()) {
+		if !heap.rows.has(id) || tx.overlay.IsDeleted(table, id) {
+			continue
+		}
+		scanned++
+		if updVals, ok := tx.overlay.GetUpdate(table, id); ok {
+			if equal(updVals) {
+				rows = append(rows, Row{ID: id, Values: updVals})
+			}
+		} else if match(id) {
+			rows = append(rows, Row{ID: id, Values: heap.rows.get(id)})
+		}
+	}
+	for _, ins := range tx.overlay.Inserts[table] {
+		if equal(ins.Values) {
+			rows = append(rows, Row{ID: ins.RowID, Values: ins.Values})
+		}
+	}
+	scanned += int64(len(tx.overlay.Inserts[table]))
+	return &pickedIterator{sliceIterator: sliceIterator{rows: rows}, scanned: scanned}, nil
+}
+
+// ScanPKRange walks the committed rows in the range through the primary
+// key index, then merges in the rows this transaction changed or added
+// whose key falls in the range.
+func (tx *TxEngine) ScanPKRange(table string, lo, hi *KeyBound) (RowIterator, error) {
+	ts, err := tx.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.mu.RUnlock()
+
+	heap := ts.heap
+	if heap.pkIdx == nil {
+		return nil, fmt.Errorf("table %q has no primary key", table)
+	}
+
+	var rows []Row
+	for _, row := range heap.scanPKRange(lo, hi) {
+		if tx.overlay.IsDeleted(table, row.ID) {
+			continue
+		}
+		if _, ok := tx.overlay.GetUpdate(table, row.ID); ok {
+			continue // merged below, as its key may have moved
+		}
+		rows = append(rows, row)
+	}
+	merged := false
+	for id, vals := range tx.overlay.Updates[table] {
+		if !heap.rows.has(id) || tx.overlay.IsDeleted(table, id) {
+			continue
+		}
+		if inKeyRange(heap.pkKey(vals), lo, hi) {
+			rows = append(rows, Row{ID: id, Values: vals})
+			merged = true
+		}
+	}
+	for _, ins := range tx.overlay.Inserts[table] {
+		if inKeyRange(heap.pkKey(ins.Values), lo, hi) {
+			rows = append(rows, Row{ID: ins.RowID, Values: ins.Values})
+			merged = true
+		}
+	}
+	if merged {
+		sort.Slice(rows, func(i, j int) bool {
+			return CompareValues(heap.pkKey(rows[i].Values), heap.pkKey(rows[j].Values)) < 0
+		})
+	}
+	return &sliceIterator{rows: rows}, nil
+}
+
 func (tx *TxEngine) Update(table string, sets map[string]any, filter func(Row) bool) (int64, error) {
-	ts, err := tx.real.acquireTableRead(table)
+	rows, err := tx.UpdateReturning(table, sets, filter)
+	return int64(len(rows)), err
+}
+
+func (tx *TxEngine) UpdateReturning(table string, sets map[string]any, filter func(Row) bool) ([]Row, error) {
+	if tx.readOnly {
+		return nil, &ReadOnlyTxError{Op: "UPDATE"}
+	}
+	ts, err := tx.acquireTableRead(table)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	heap := ts.heap
 
@@ -310,11 +480,7 @@ func (tx *TxEngine) Update(table string, sets map[string]any, filter func(Row) b
 	var updates []pendingUpdate
 
 	// Scan heap rows.
-	for id, values := range heap.rows {
-		if values == nil {
-			continue
-		}
-		rowID := int64(id)
+	for rowID, values := range heap.rows.all() {
 		if tx.overlay.IsDeleted(table, rowID) {
 			continue
 		}
@@ -333,14 +499,14 @@ func (tx *TxEngine) Update(table string, sets map[string]any, filter func(Row) b
 			idx := heap.columnIndex(colName)
 			if idx < 0 {
 				ts.mu.RUnlock()
-				return 0, &ColumnNotFoundError{Column: colName, Table: heap.def.Name}
+				return nil, &ColumnNotFoundError{Column: colName, Table: heap.def.Name}
 			}
 			newValues[idx] = newVal
 		}
 		coerced, err := coerceRowValues(&heap.def, newValues)
 		if err != nil {
 			ts.mu.RUnlock()
-			return 0, err
+			return nil, err
 		}
 		updates = append(updates, pendingUpdate{rowID: rowID, newValues: coerced})
 	}
@@ -357,23 +523,32 @@ func (tx *TxEngine) Update(table string, sets map[string]any, filter func(Row) b
 			idx := heap.columnIndex(colName)
 			if idx < 0 {
 				ts.mu.RUnlock()
-				return 0, &ColumnNotFoundError{Column: colName, Table: heap.def.Name}
+				return nil, &ColumnNotFoundError{Column: colName, Table: heap.def.Name}
 			}
 			newValues[idx] = newVal
 		}
 		coerced, err := coerceRowValues(&heap.def, newValues)
 		if err != nil {
 			ts.mu.RUnlock()
-			return 0, err
+			return nil, err
 		}
 		_ = i
 		updates = append(updates, pendingUpdate{rowID: ins.RowID, newValues: coerced, isOverlay: true})
 	}
 
+	changed := make([]rowUpdate, len(updates))
+	for i, u := range updates {
+		changed[i] = rowUpdate{RowID: u.rowID, Values: u.newValues}
+	}
+	if err := tx.checkUpdateKeys(heap, table, changed); err != nil {
+		ts.mu.RUnlock()
+		return nil, err
+	}
+
 	ts.mu.RUnlock()
 
 	if len(updates) == 0 {
-		return 0, nil
+		return nil, nil
 	}
 
 	// Validate NOT NULL constraints for columns being SET.
@@ -386,7 +561,7 @@ func (tx *TxEngine) Update(table string, sets map[string]any, filter func(Row) b
 		}
 		for _, u := range updates {
 			if RowValue(u.newValues, col.Ordinal) == nil {
-				return 0, &NotNullViolationError{
+				return nil, &NotNullViolationError{
 					Table:  table,
 					Column: col.Name,
 				}
@@ -395,7 +570,9 @@ func (tx *TxEngine) Update(table string, sets map[string]any, filter func(Row) b
 	}
 
 	// Apply updates to overlay.
-	for _, u := range updates {
+	rows := make([]Row, len(updates))
+	for i, u := range updates {
+		rows[i] = Row{ID: u.rowID, Values: u.newValues}
 		if u.isOverlay {
 			// Update the overlay insert in place.
 			for i := range tx.overlay.Inserts[table] {
@@ -408,24 +585,182 @@ func (tx *TxEngine) Update(table string, sets map[string]any, filter func(Row) b
 			tx.overlay.AddUpdate(table, u.rowID, u.newValues)
 		}
 	}
-	return int64(len(updates)), nil
+	return rows, nil
+}
+
+// checkUpdateKeys returns a UniqueViolationError if the updates would
+// leave two rows of the transaction's view of table, heap plus overlay,
+// with the same primary key or the same key of a unique index. Rows not
+// among the updates keep the keys the overlay gives them, or else their
+// keys in the heap. The caller holds the table's read lock.
+func (tx *TxEngine) checkUpdateKeys(heap *tableHeap, table string, updates []rowUpdate) error {
+	updating := make(map[int64]bool, len(updates))
+	for _, u := range updates {
+		updating[u.RowID] = true
+	}
+	check := func(key func([]any) any, idx index.Index, nullOK bool, violation func(key any) error) error {
+		// Keys of the other rows the overlay holds: inserted or updated.
+		overlay := make(map[any]bool)
+		for _, ins := range tx.overlay.Inserts[table] {
+			if k := key(ins.Values); k != nil && !updating[ins.RowID] {
+				overlay[mapKey(k)] = true
+			}
+		}
+		for id, vals := range tx.overlay.Updates[table] {
+			if k := key(vals); k != nil && !updating[id] {
+				overlay[mapKey(k)] = true
+			}
+		}
+		seen := make(map[any]bool, len(updates))
+		for _, u := range updates {
+			k := key(u.Values)
+			if k == nil {
+				if nullOK {
+					continue
+				}
+				return violation(nil)
+			}
+			if seen[mapKey(k)] || overlay[mapKey(k)] {
+				return violation(k)
+			}
+			seen[mapKey(k)] = true
+			if id, ok := idx.Get(k); ok && !updating[id] && !tx.overlay.IsDeleted(table, id) {
+				if _, moved := tx.overlay.GetUpdate(table, id); !moved {
+					return violation(k)
+				}
+			}
+		}
+		return nil
+	}
+
+	if heap.pkIdx != nil {
+		pkColName := heap.pkColumnName()
+		err := check(heap.pkKey, heap.pkIdx, false, func(key any) error {
+			return &UniqueViolationError{Table: table, Column: pkColName, Value: key}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for i := range heap.secondaries {
+		si := &heap.secondaries[i]
+		if si.unique == nil {
+			continue
+		}
+		err := check(si.key, si.unique, true, func(key any) error {
+			return &UniqueViolationError{Table: table, Column: si.def.ColumnList(), Value: key, Index: si.def.Name}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Upsert resolves conflicts against the transaction's view of the table,
+// heap plus overlay, then buffers the inserts and updates through
+// InsertReturning and UpdateReturning. Constraints are re-checked by
+// CommitOverlay as for any other write.
+func (tx *TxEngine) Upsert(table string, columns []string, values [][]any, oc OnConflict) ([]Row, error) {
+	if tx.readOnly {
+		return nil, &ReadOnlyTxError{Op: "INSERT"}
+	}
+	ts, err := tx.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	heap := ts.heap
+
+	keys, err := conflictKeys(heap, oc)
+	if err != nil {
+		ts.mu.RUnlock()
+		return nil, err
+	}
+	resolvedRows := make([][]any, 0, len(values))
+	for _, vals := range values {
+		fullRow, err := resolveInsertRow(heap, columns, vals)
+		if err != nil {
+			ts.mu.RUnlock()
+			return nil, err
+		}
+		resolvedRows = append(resolvedRows, fullRow)
+	}
+	// Resolved rows are laid out by ordinal, which can have gaps after
+	// DROP COLUMN, so inserts are re-entered with explicit column names.
+	names := make([]string, len(heap.def.Columns))
+	ords := make([]int, len(heap.def.Columns))
+	for i, col := range heap.def.Columns {
+		names[i], ords[i] = col.Name, col.Ordinal
+	}
+	ts.mu.RUnlock()
+
+	lookup := func(k uniqueKey, key any) (int64, bool) {
+		if k.index == nil {
+			row, err := tx.LookupByPK(table, key)
+			if err != nil || row == nil {
+				return 0, false
+			}
+			return row.ID, true
+		}
+		rows, err := tx.LookupByIndex(table, k.name, key)
+		if err != nil || len(rows) == 0 {
+			return 0, false
+		}
+		return rows[0].ID, true
+	}
+	newRows, updateIDs, err := planUpsert(table, resolvedRows, keys, oc.Sets != nil, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	if len(newRows) > 0 {
+		vals := make([][]any, len(newRows))
+		for i, fullRow := range newRows {
+			vals[i] = make([]any, len(ords))
+			for j, ord := range ords {
+				vals[i][j] = RowValue(fullRow, ord)
+			}
+		}
+		inserted, err := tx.InsertReturning(table, names, vals)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, inserted...)
+	}
+	if len(updateIDs) > 0 {
+		ids := make(map[int64]bool, len(updateIDs))
+		for _, id := range updateIDs {
+			ids[id] = true
+		}
+		updated, err := tx.UpdateReturning(table, oc.Sets, func(r Row) bool { return ids[r.ID] })
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, updated...)
+	}
+	return rows, nil
 }
 
 func (tx *TxEngine) Delete(table string, filter func(Row) bool) (int64, error) {
-	ts, err := tx.real.acquireTableRead(table)
+	rows, err := tx.DeleteReturning(table, filter)
+	return int64(len(rows)), err
+}
+
+func (tx *TxEngine) DeleteReturning(table string, filter func(Row) bool) ([]Row, error) {
+	if tx.readOnly {
+		return nil, &ReadOnlyTxError{Op: "DELETE"}
+	}
+	ts, err := tx.acquireTableRead(table)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	heap := ts.heap
 
-	var count int64
+	var rows []Row
 
 	// Scan heap rows.
-	for id, values := range heap.rows {
-		if values == nil {
-			continue
-		}
-		rowID := int64(id)
+	for rowID, values := range heap.rows.all() {
 		if tx.overlay.IsDeleted(table, rowID) {
 			continue
 		}
@@ -442,7 +777,7 @@ func (tx *TxEngine) Delete(table string, filter func(Row) bool) (int64, error) {
 		if tx.overlay.Updates[table] != nil {
 			delete(tx.overlay.Updates[table], rowID)
 		}
-		count++
+		rows = append(rows, row)
 	}
 
 	// Scan overlay inserts — remove matching ones.
@@ -453,18 +788,79 @@ func (tx *TxEngine) Delete(table string, filter func(Row) bool) (int64, error) {
 			if filter != nil && !filter(row) {
 				remaining = append(remaining, ins)
 			} else {
-				count++
+				rows = append(rows, row)
 			}
 		}
 		tx.overlay.Inserts[table] = remaining
 	}
 
 	ts.mu.RUnlock()
-	return count, nil
+	return rows, nil
+}
+
+func (tx *TxEngine) DeleteRows(table string, rowIDs []int64, filter func(Row) bool) ([]Row, error) {
+	if tx.readOnly {
+		return nil, &ReadOnlyTxError{Op: "DELETE"}
+	}
+	ts, err := tx.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.mu.RUnlock()
+	heap := ts.heap
+
+	want := make(map[int64]struct{}, len(rowIDs))
+	for _, id := range rowIDs {
+		want[id] = struct{}{}
+	}
+
+	var rows []Row
+
+	// Rows inserted by this transaction are dropped from the overlay.
+	if inserts, ok := tx.overlay.Inserts[table]; ok {
+		remaining := inserts[:0]
+		for _, ins := range inserts {
+			row := Row{ID: ins.RowID, Values: ins.Values}
+			if _, ok := want[ins.RowID]; ok && (filter == nil || filter(row)) {
+				delete(want, ins.RowID)
+				rows = append(rows, row)
+				continue
+			}
+			remaining = append(remaining, ins)
+		}
+		tx.overlay.Inserts[table] = remaining
+	}
+
+	for _, rowID := range rowIDs {
+		if _, ok := want[rowID]; !ok {
+			continue
+		}
+		delete(want, rowID)
+		if !heap.rows.has(rowID) {
+			continue
+		}
+		if tx.overlay.IsDeleted(table, rowID) {
+			continue
+		}
+		currentVals := heap.rows.get(rowID)
+		if updVals, ok := tx.overlay.GetUpdate(table, rowID); ok {
+			currentVals = updVals
+		}
+		row := Row{ID: rowID, Values: currentVals}
+		if filter != nil && !filter(row) {
+			continue
+		}
+		tx.overlay.AddDelete(table, rowID)
+		if tx.overlay.Updates[table] != nil {
+			delete(tx.overlay.Updates[table], rowID)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
 }
 
 func (tx *TxEngine) LookupByPK(table string, value any) (*Row, error) {
-	ts, err := tx.real.acquireTableRead(table)
+	ts, err := tx.acquireTableRead(table)
 	if err != nil {
 		return nil, err
 	}
@@ -513,7 +909,7 @@ func (tx *TxEngine) LookupByPK(table string, value any) (*Row, error) {
 }
 
 func (tx *TxEngine) LookupByIndex(table string, indexName string, value any) ([]Row, error) {
-	ts, err := tx.real.acquireTableRead(table)
+	ts, err := tx.acquireTableRead(table)
 	if err != nil {
 		return nil, err
 	}
@@ -521,6 +917,14 @@ func (tx *TxEngine) LookupByIndex(table string, indexName string, value any) ([]
 
 	heap := ts.heap
 
+	var si *secondaryIdx
+	for i := range heap.secondaries {
+		if heap.secondaries[i].def.Name == indexName {
+			si = &heap.secondaries[i]
+			break
+		}
+	}
+
 	// Look up in real heap index.
 	heapRows := heap.lookupByIndex(indexName, value)
 	var result []Row
@@ -528,43 +932,36 @@ func (tx *TxEngine) LookupByIndex(table string, indexName string, value any) ([]
 		if tx.overlay.IsDeleted(table, row.ID) {
 			continue
 		}
-		if updVals, ok := tx.overlay.GetUpdate(table, row.ID); ok {
-			// Find the index column ordinal.
-			var colOrd int
-			for i := range heap.secondaries {
-				if heap.secondaries[i].def.Name == indexName {
-					colOrd = heap.secondaries[i].colOrd
-					break
-				}
+		if _, ok := tx.overlay.GetUpdate(table, row.ID); ok {
+			continue // merged below, as its key may have changed
+		}
+		vals := make([]any, len(row.Values))
+		copy(vals, row.Values)
+		result = append(result, Row{ID: row.ID, Values: vals})
+	}
+
+	// Rows updated in the transaction match by their new key, whether or
+	// not the old one matched.
+	if si != nil {
+		merged := false
+		for id, vals := range tx.overlay.Updates[table] {
+			if !heap.rows.has(id) || tx.overlay.IsDeleted(table, id) {
+				continue
 			}
-			updKey := RowValue(updVals, colOrd)
-			if CompareValues(updKey, value) == 0 {
-				vals := make([]any, len(updVals))
-				copy(vals, updVals)
-				result = append(result, Row{ID: row.ID, Values: vals})
+			if CompareValues(si.key(vals), value) == 0 {
+				result = append(result, Row{ID: id, Values: append([]any(nil), vals...)})
+				merged = true
 			}
-			// else: key was changed, doesn't match anymore
-		} else {
-			vals := make([]any, len(row.Values))
-			copy(vals, row.Values)
-			result = append(result, Row{ID: row.ID, Values: vals})
+		}
+		if merged {
+			sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
 		}
 	}
 
 	// Also scan overlay inserts for matching values.
-	var colOrd int
-	found := false
-	for i := range heap.secondaries {
-		if heap.secondaries[i].def.Name == indexName {
-			colOrd = heap.secondaries[i].colOrd
-			found = true
-			break
-		}
-	}
-	if found {
+	if si != nil {
 		for _, ins := range tx.overlay.Inserts[table] {
-			key := RowValue(ins.Values, colOrd)
-			if CompareValues(key, value) == 0 {
+			if CompareValues(si.key(ins.Values), value) == 0 {
 				vals := make([]any, len(ins.Values))
 				copy(vals, ins.Values)
 				result = append(result, Row{ID: ins.RowID, Values: vals})
@@ -575,8 +972,92 @@ func (tx *TxEngine) LookupByIndex(table string, indexName string, value any) ([]
 	return result, nil
 }
 
+// LookupByIndexRange walks the committed rows in the range through the
+// index and merges in the rows this transaction changed or added, keeping
+// key order, as ScanPKRange does for the primary key.
+func (tx *TxEngine) LookupByIndexRange(table string, indexName string, lo, hi *KeyBound) ([]Row, error) {
+	ts, err := tx.acquireTableRead(table)
+	if err != nil {
+		return nil, err
+	}
+	defer ts.mu.RUnlock()
+
+	heap := ts.heap
+	var si *secondaryIdx
+	for i := range heap.secondaries {
+		if heap.secondaries[i].def.Name == indexName {
+			si = &heap.secondaries[i]
+			break
+		}
+	}
+	if si == nil {
+		return nil, nil
+	}
+
+	var result []Row
+	for _, row := range heap.lookupByIndexRange(indexName, lo, hi) {
+		if tx.overlay.IsDeleted(table, row.ID) {
+			continue
+		}
+		if _, ok := tx.overlay.GetUpdate(table, row.ID); ok {
+			continue // merged below, as its key may have moved
+		}
+		vals := make([]any, len(row.Values))
+		copy(vals, row.Values)
+		result = append(result, Row{ID: row.ID, Values: vals})
+	}
+	merged := false
+	inRange := func(vals []any) bool {
+		key := si.key(vals)
+		return key != nil && inKeyRange(key, lo, hi)
+	}
+	for id, vals := range tx.overlay.Updates[table] {
+		if !heap.rows.has(id) || tx.overlay.IsDeleted(table, id) {
+			continue
+		}
+		if inRange(vals) {
+			result = append(result, Row{ID: id, Values: append([]any(nil), vals...)})
+			merged = true
+		}
+	}
+	for _, ins := range tx.overlay.Inserts[table] {
+		if inRange(ins.Values) {
+			result = append(result, Row{ID: ins.RowID, Values: append([]any(nil), ins.Values...)})
+			merged = true
+		}
+	}
+	if merged {
+		sort.SliceStable(result, func(i, j int) bool {
+			if c := CompareValues(si.key(result[i].Values), si.key(result[j].Values)); c != 0 {
+				return c < 0
+			}
+			return result[i].ID < result[j].ID
+		})
+	}
+	return result, nil
+}
+
+// Analyze and Stats work on committed data only, like ANALYZE run outside
+// the transaction. A table the transaction created has none.
+func (tx *TxEngine) Analyze(table string) error {
+	if tx.readOnly {
+		return &ReadOnlyTxError{Op: "ANALYZE"}
+	}
+	if t, ok := tx.privateTable(table); ok && t.created {
+		return nil
+	}
+	return tx.real.Analyze(table)
+}
+
+func (tx *TxEngine) Stats(table string) *TableStats {
+	if t, ok := tx.privateTable(table); ok && t.created {
+		return nil
+	}
+	return tx.real.Stats(table)
+}
+
 func (tx *TxEngine) RowCount(table string) (int64, error) {
-	ts, err := tx.real.acquireTableRead(table)
+	ts, err := tx.acquireTableRead(table)
 	if err != nil {
 		return 0, err
 	}
@@ -595,10 +1076,14 @@ func (tx *TxEngine) RowCount(table string) (int64, error) {
 // -------------------------------------------------------------------------
 
 // CommitOverlay atomically applies the transaction overlay to the real
-// engine. It acquires table locks in deterministic order, re-validates
-// constraints, writes all DML entries to WAL with begin/commit markers,
-// applies changes to the heap, and releases all locks.
+// engine. It first replays the transaction's DDL, if any, then acquires
+// table locks in deterministic order, re-validates constraints, writes all
+// DML entries to WAL with begin/commit markers, applies changes to the
+// heap, and releases all locks.
 func (tx *TxEngine) CommitOverlay() error {
+	if err := tx.commitSchema(); err != nil {
+		return err
+	}
 	tables := tx.overlay.TouchedTables()
 	if len(tables) == 0 {
 		return nil // nothing to commit
@@ -625,6 +1110,26 @@ func (tx *TxEngine) CommitOverlay() error {
 		}
 	}()
 
+	// A VACUUM since the transaction first read a table has renumbered the
+	// rows its overlay refers to by ID.
+	for i, t := range tables {
+		if n, ok := tx.vacuums[t]; ok && lockedStates[i].vacuums != n {
+			return &SchemaChangedError{Table: t}
+		}
+	}
+
+	// Rows added to a table the transaction created or altered took their
+	// IDs from its private copy; they get fresh ones from the real heap.
+	for i, t := range tables {
+		if _, ok := tx.privateTable(t); !ok {
+			continue
+		}
+		heap := lockedStates[i].heap
+		for j := range tx.overlay.Inserts[t] {
+			tx.overlay.Inserts[t][j].RowID = heap.allocateID()
+		}
+	}
+
 	// Re-validate constraints against current heap state.
 	for i, t := range tables {
 		ts := lockedStates[i]
@@ -653,7 +1158,7 @@ func (tx *TxEngine) CommitOverlay() error {
 				continue
 			}
 			for _, ins := range tx.overlay.Inserts[t] {
-				key := RowValue(ins.Values, sec.colOrd)
+				key := sec.key(ins.Values)
 				if key == nil {
 					continue
 				}
@@ -661,7 +1166,7 @@ func (tx *TxEngine) CommitOverlay() error {
 					if _, deleted := tx.overlay.Deletes[t][existingID]; !deleted {
 						return &UniqueViolationError{
 							Table:  t,
-							Column: sec.def.Column,
+							Column: sec.def.ColumnList(),
 							Value:  key,
 							Index:  sec.def.Name,
 						}
@@ -791,8 +1296,14 @@ func (tx *TxEngine) CommitOverlay() error {
 		}
 
 		// Apply updates.
-		for rowID, vals := range tx.overlay.Updates[t] {
-			heap.updateRow(rowID, vals)
+		if upds := tx.overlay.Updates[t]; len(upds) > 0 {
+			updates := make([]rowUpdate, 0, len(upds))
+			for rowID, vals := range upds {
+				updates = append(updates, rowUpdate{RowID: rowID, Values: vals})
+			}
+			if err := heap.updateRows(updates); err != nil {
+				return err
+			}
 		}
 
 		// Apply inserts.
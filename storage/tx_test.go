@@ -3,6 +3,9 @@ package storage
 import (
 	"errors"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -103,6 +106,36 @@ func TestTxEngine_InsertRollback(t *testing.T) {
 	}
 }
 
+func TestTxEngine_LoadCSV(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	if err := eng.CreateTable("users", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := NewTxEngine(eng)
+	if n, err := tx.LoadCSV("users", strings.NewReader("1,alice\n2,\n"), CSVOptions{}); err != nil || n != 2 {
+		t.Fatalf("LoadCSV: got %d, %v", n, err)
+	}
+	if _, err := tx.LoadCSV("users", strings.NewReader("2,bob\n"), CSVOptions{}); !isUniqueViolation(err) {
+		t.Errorf("key taken in the overlay: expected unique violation, got %v", err)
+	}
+	if n, _ := eng.RowCount("users"); n != 0 {
+		t.Errorf("before commit: real engine has %d rows, want 0", n)
+	}
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := eng.RowCount("users"); n != 2 {
+		t.Errorf("after commit: real engine has %d rows, want 2", n)
+	}
+}
+
 func TestTxEngine_DeleteCommit(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
@@ -175,6 +208,103 @@ func TestTxEngine_DeleteCommit(t *testing.T) {
 	}
 }
 
+func TestTxEngine_DeleteRows(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	if err := eng.CreateTable("users", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "name", DataType: TypeText},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := eng.InsertReturning("users", nil, [][]any{
+		{int64(1), "alice"},
+		{int64(2), "bob"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := NewTxEngine(eng)
+	inserted, err := tx.InsertReturning("users", nil, [][]any{{int64(3), "carol"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One committed row and one row of the transaction's own.
+	deleted, err := tx.DeleteRows("users", []int64{rows[0].ID, inserted[0].ID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 {
+		t.Fatalf("deleted %d rows, want 2", len(deleted))
+	}
+	if got := collectRows(t, must(tx.Scan("users"))); len(got) != 1 || got[0].Values[1] != "bob" {
+		t.Fatalf("tx scan = %v, want only bob", got)
+	}
+	if n, _ := eng.RowCount("users"); n != 2 {
+		t.Fatalf("real engine rows = %d before commit, want 2", n)
+	}
+
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+	if got := collectRows(t, must(eng.Scan("users"))); len(got) != 1 || got[0].Values[1] != "bob" {
+		t.Fatalf("post-commit scan = %v, want only bob", got)
+	}
+}
+
+func TestTxEngine_ScanEqual(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	if err := eng.CreateTable("users", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "role", DataType: TypeText, Dictionary: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Insert("users", nil, [][]any{
+		{int64(1), "admin"},
+		{int64(2), "guest"},
+		{int64(3), "admin"},
+		{int64(4), "guest"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The transaction deletes one admin, demotes another, promotes a guest
+	// and adds an admin; it sees all of that, the engine none of it.
+	tx := NewTxEngine(eng)
+	must(tx.Delete("users", func(r Row) bool { return r.Values[0] == int64(1) }))
+	must(tx.Update("users", map[string]any{"role": "guest"}, func(r Row) bool { return r.Values[0] == int64(3) }))
+	must(tx.Update("users", map[string]any{"role": "admin"}, func(r Row) bool { return r.Values[0] == int64(4) }))
+	must(tx.Insert("users", nil, [][]any{{int64(5), "admin"}}))
+
+	ids := func(e Engine) []any {
+		var ids []any
+		for _, r := range collectRows(t, must(e.ScanEqual("users", "role", "admin"))) {
+			ids = append(ids, r.Values[0])
+		}
+		return ids
+	}
+	if got := ids(tx); !slices.Equal(got, []any{int64(4), int64(5)}) {
+		t.Errorf("tx ScanEqual = %v, want [4 5]", got)
+	}
+	if got := ids(eng); !slices.Equal(got, []any{int64(1), int64(3)}) {
+		t.Errorf("engine ScanEqual = %v, want [1 3]", got)
+	}
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(eng); !slices.Equal(got, []any{int64(4), int64(5)}) {
+		t.Errorf("post-commit ScanEqual = %v, want [4 5]", got)
+	}
+}
+
 func TestTxEngine_UpdateCommit(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
@@ -240,30 +370,272 @@ func TestTxEngine_UpdateCommit(t *testing.T) {
 	}
 }
 
-func TestTxEngine_DDLRejected(t *testing.T) {
+func TestTxEngine_CreateTableRollback(t *testing.T) {
 	dir := tempDir(t)
 	eng := openEngine(t, dir)
+
+	tx := NewTxEngine(eng)
+	if err := tx.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CreateIndex("t", IndexDef{Name: "idx_name", Columns: []string{"name"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Insert("t", nil, [][]any{{int64(1), "alice"}, {int64(2), "bob"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The transaction sees its table; the engine and the disk do not.
+	if _, ok := tx.GetTable("t"); !ok {
+		t.Fatal("transaction does not see its table")
+	}
+	it, err := tx.Scan("t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := collectRows(t, it); len(rows) != 2 {
+		t.Fatalf("tx scan got %d rows, want 2", len(rows))
+	}
+	if rows, err := tx.LookupByIndex("t", "idx_name", "bob"); err != nil || len(rows) != 1 {
+		t.Fatalf("tx index lookup = %v, %v, want one row", rows, err)
+	}
+	if _, ok := eng.GetTable("t"); ok {
+		t.Fatal("engine sees an uncommitted table")
+	}
+	walPath := filepath.Join(dir, tablesDirName, tableFileName("t"))
+	if _, err := os.Stat(walPath); !os.IsNotExist(err) {
+		t.Fatalf("table WAL exists before commit: %v", err)
+	}
+
+	// Rollback: discard tx. Nothing is left, also after a restart.
+	eng.Close()
+	eng = openEngine(t, dir)
 	defer eng.Close()
+	if _, ok := eng.GetTable("t"); ok {
+		t.Error("rolled back table exists after reopen")
+	}
+	if _, err := os.Stat(walPath); !os.IsNotExist(err) {
+		t.Errorf("table WAL exists after rollback: %v", err)
+	}
+}
+
+func TestTxEngine_CreateTableCommit(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+
+	// A committed table of the same name is dropped and replaced.
+	if err := eng.CreateTable("t", []ColumnDef{{Name: "x", DataType: TypeText}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Insert("t", nil, [][]any{{"old"}}); err != nil {
+		t.Fatal(err)
+	}
 
 	tx := NewTxEngine(eng)
+	if err := tx.DropTable("t"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tx.GetTable("t"); ok {
+		t.Fatal("transaction sees the table it dropped")
+	}
+	if err := tx.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Insert("t", nil, [][]any{{int64(1), "alice"}, {int64(2), "bob"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CreateIndex("t", IndexDef{Name: "t_name_key", Columns: []string{"name"}, Unique: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Insert("t", nil, [][]any{{int64(3), "bob"}}); !errors.As(err, new(*UniqueViolationError)) {
+		t.Fatalf("duplicate insert: err = %v, want UniqueViolationError", err)
+	}
+	// Tables created and dropped within the transaction leave nothing.
+	if err := tx.CreateTable("scratch", []ColumnDef{{Name: "id", DataType: TypeInteger}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.DropTable("scratch"); err != nil {
+		t.Fatal(err)
+	}
+
+	if def, _ := eng.GetTable("t"); len(def.Columns) != 1 {
+		t.Fatalf("engine sees uncommitted columns %v", def.Columns)
+	}
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(eng Engine) {
+		t.Helper()
+		def, ok := eng.GetTable("t")
+		if !ok || len(def.Columns) != 2 || len(def.Indexes) != 1 {
+			t.Fatalf("table = %+v, want two columns and one index", def)
+		}
+		it, err := eng.Scan("t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows := collectRows(t, it); len(rows) != 2 {
+			t.Fatalf("scan got %d rows, want 2", len(rows))
+		}
+		if rows, err := eng.LookupByIndex("t", "t_name_key", "alice"); err != nil || len(rows) != 1 {
+			t.Fatalf("index lookup = %v, %v, want one row", rows, err)
+		}
+		if _, ok := eng.GetTable("scratch"); ok {
+			t.Fatal("table dropped in the transaction exists")
+		}
+	}
+	check(eng)
+	eng.Close()
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	check(eng)
+}
+
+func TestTxEngine_AlterTable(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
 
-	if err := tx.CreateTable("t", nil); err == nil {
-		t.Fatal("expected error for CREATE TABLE inside tx")
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	}); err != nil {
+		t.Fatal(err)
 	}
-	if err := tx.DropTable("t"); err == nil {
-		t.Fatal("expected error for DROP TABLE inside tx")
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1), "alice"}, {int64(2), "bob"}}); err != nil {
+		t.Fatal(err)
 	}
-	if err := tx.AddColumn("t", ColumnDef{}); err == nil {
-		t.Fatal("expected error for ADD COLUMN inside tx")
+
+	tx := NewTxEngine(eng)
+	if _, err := tx.Insert("t", nil, [][]any{{int64(3), "carol"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.AddColumn("t", ColumnDef{Name: "score", DataType: TypeInteger, Fill: int64(0)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Insert("t", []string{"id", "name", "score"}, [][]any{{int64(4), "dave", int64(9)}}); err != nil {
+		t.Fatal(err)
 	}
-	if err := tx.DropColumn("t", "c"); err == nil {
-		t.Fatal("expected error for DROP COLUMN inside tx")
+	if err := tx.DropColumn("t", "name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CreateIndex("t", IndexDef{Name: "idx_score", Columns: []string{"score"}}); err != nil {
+		t.Fatal(err)
 	}
-	if err := tx.CreateIndex("t", IndexDef{}); err == nil {
-		t.Fatal("expected error for CREATE INDEX inside tx")
+	if err := tx.CreateIndex("t", IndexDef{Name: "idx_u", Columns: []string{"score"}, Unique: true}); !errors.As(err, new(*UniqueViolationError)) {
+		t.Fatalf("unique index on duplicates: err = %v, want UniqueViolationError", err)
 	}
-	if err := tx.DropIndex("t", "i"); err == nil {
-		t.Fatal("expected error for DROP INDEX inside tx")
+	if def, _ := eng.GetTable("t"); len(def.Columns) != 2 || len(def.Indexes) != 0 {
+		t.Fatalf("engine sees uncommitted changes: %+v", def)
+	}
+
+	// Another session's rows take IDs the transaction's copy also hands out.
+	if _, err := eng.Insert("t", []string{"id", "name"}, [][]any{{int64(5), "eve"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+
+	def, _ := eng.GetTable("t")
+	if len(def.Columns) != 2 || def.Columns[1].Name != "score" || len(def.Indexes) != 1 {
+		t.Fatalf("table = %+v, want id, score and idx_score", def)
+	}
+	rows, err := eng.LookupByIndex("t", "idx_score", int64(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rowIDs(rows); len(got) != 4 {
+		t.Errorf("rows with score 0 = %v, want ids of alice, bob, carol and eve", got)
+	}
+	if rows, _ := eng.LookupByIndex("t", "idx_score", int64(9)); len(rows) != 1 || RowValue(rows[0].Values, 0) != int64(4) {
+		t.Errorf("rows with score 9 = %v, want dave", rows)
+	}
+	if n, _ := eng.RowCount("t"); n != 5 {
+		t.Errorf("row count = %d, want 5", n)
+	}
+}
+
+func TestTxEngine_SchemaChanged(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	if err := eng.CreateTable("t", []ColumnDef{{Name: "id", DataType: TypeInteger}}); err != nil {
+		t.Fatal(err)
+	}
+	tx := NewTxEngine(eng)
+	if err := tx.CreateTable("u", []ColumnDef{{Name: "id", DataType: TypeInteger}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.AddColumn("t", ColumnDef{Name: "a", DataType: TypeText}); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.AddColumn("t", ColumnDef{Name: "b", DataType: TypeText}); err != nil {
+		t.Fatal(err)
+	}
+	var changed *SchemaChangedError
+	if err := tx.CommitOverlay(); !errors.As(err, &changed) || changed.Table != "t" {
+		t.Fatalf("commit: err = %v, want SchemaChangedError for t", err)
+	}
+	if _, ok := eng.GetTable("u"); ok {
+		t.Error("table u was created by a failed commit")
+	}
+
+	ro := NewTxEngine(eng)
+	ro.SetReadOnly(true)
+	if err := ro.CreateTable("v", nil); !errors.As(err, new(*ReadOnlyTxError)) {
+		t.Errorf("CREATE TABLE in a read-only transaction: err = %v, want ReadOnlyTxError", err)
+	}
+	if err := NewTxEngine(eng).Truncate([]string{"t"}, false); !errors.As(err, new(*ActiveTxError)) {
+		t.Errorf("TRUNCATE in a transaction: err = %v, want ActiveTxError", err)
+	}
+}
+
+func TestTxEngine_VacuumConflict(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "v", DataType: TypeText},
+	})
+	must(eng.Insert("t", nil, [][]any{{int64(1), "a"}, {int64(2), "b"}, {int64(3), "c"}}))
+	must(eng.Delete("t", func(r Row) bool { return r.Values[0] == int64(1) }))
+
+	// The transaction's update names row 3, which the vacuum moves to 2.
+	tx := NewTxEngine(eng)
+	must(tx.Update("t", map[string]any{"v": "x"}, func(r Row) bool { return r.Values[0] == int64(3) }))
+	must(eng.Vacuum("t"))
+	var changed *SchemaChangedError
+	if err := tx.CommitOverlay(); !errors.As(err, &changed) || changed.Table != "t" {
+		t.Fatalf("commit: err = %v, want SchemaChangedError for t", err)
+	}
+	rows := collectRows(t, must(eng.Scan("t")))
+	if len(rows) != 2 || rows[0].Values[1] != "b" || rows[1].Values[1] != "c" {
+		t.Errorf("rows after failed commit = %v, want b and c unchanged", rows)
+	}
+
+	// A transaction that starts after the vacuum commits normally.
+	tx = NewTxEngine(eng)
+	must(tx.Update("t", map[string]any{"v": "x"}, func(r Row) bool { return r.Values[0] == int64(3) }))
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := eng.LookupByPK("t", int64(3)); r == nil || r.Values[1] != "x" {
+		t.Errorf("row 3 after commit = %v, want v = x", r)
+	}
+
+	if _, err := NewTxEngine(eng).Vacuum("t"); !errors.As(err, new(*ActiveTxError)) {
+		t.Errorf("VACUUM in a transaction: err = %v, want ActiveTxError", err)
 	}
 }
 
@@ -885,6 +1257,159 @@ func TestTxEngine_UpdateNotNull(t *testing.T) {
 	}
 }
 
+func TestTxEngine_UpdateUniqueKeys(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "code", DataType: TypeText},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.CreateIndex("t", IndexDef{Name: "t_code_key", Columns: []string{"code"}, Unique: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1), "a"}, {int64(2), "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	byID := func(id int64) func(Row) bool {
+		return func(r Row) bool { return r.Values[0] == id }
+	}
+
+	tx := NewTxEngine(eng)
+	// A key the transaction moved away from is free; one it moved to,
+	// or a heap row still has, is taken.
+	if _, err := tx.Update("t", map[string]any{"id": int64(5)}, byID(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Update("t", map[string]any{"id": int64(1)}, byID(2)); err != nil {
+		t.Fatalf("reusing a key moved away: %v", err)
+	}
+	if _, err := tx.Insert("t", nil, [][]any{{int64(7), "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		sets   map[string]any
+		filter func(Row) bool
+	}{
+		{map[string]any{"id": int64(5)}, byID(1)}, // moved there by the transaction
+		{map[string]any{"id": int64(7)}, byID(5)}, // inserted by it
+		{map[string]any{"code": "b"}, byID(5)},    // unique index, in the heap
+		{map[string]any{"code": "same"}, nil},     // two rows of one update
+		{map[string]any{"id": nil}, byID(7)},      // NULL primary key
+	} {
+		_, err := tx.Update("t", tt.sets, tt.filter)
+		if !isUniqueViolation(err) {
+			t.Errorf("Update(%v): got %v, want UniqueViolationError", tt.sets, err)
+		}
+	}
+	if _, err := tx.Update("t", map[string]any{"code": "z"}, byID(7)); err != nil {
+		t.Errorf("unused unique key: %v", err)
+	}
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTxEngine_SwapKeys(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "code", DataType: TypeText},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.CreateIndex("t", IndexDef{Name: "t_code_key", Columns: []string{"code"}, Unique: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1), "a"}, {int64(2), "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	byID := func(id int64) func(Row) bool {
+		return func(r Row) bool { return r.Values[0] == id }
+	}
+
+	// Each transaction swaps both keys of the two rows, by way of a third
+	// pair. Committing must not depend on the order the rows are applied
+	// in.
+	for i := range 8 {
+		tx := NewTxEngine(eng)
+		for _, step := range []struct {
+			from, id int64
+			code     string
+		}{{1, 3, "c"}, {2, 1, "a"}, {3, 2, "b"}} {
+			if _, err := tx.Update("t", map[string]any{"id": step.id, "code": step.code}, byID(step.from)); err != nil {
+				t.Fatalf("swap %d: %v", i, err)
+			}
+		}
+		if err := tx.CommitOverlay(); err != nil {
+			t.Fatalf("swap %d: commit: %v", i, err)
+		}
+	}
+
+	check := func(eng Engine) {
+		t.Helper()
+		for _, want := range []struct {
+			id   int64
+			code string
+		}{{1, "a"}, {2, "b"}} {
+			row, err := eng.LookupByPK("t", want.id)
+			if err != nil || row == nil || row.Values[1] != want.code {
+				t.Errorf("LookupByPK(%d) = %v, %v; want code %s", want.id, row, err, want.code)
+			}
+			rows, err := eng.LookupByIndex("t", "t_code_key", want.code)
+			if err != nil || len(rows) != 1 || rows[0].Values[0] != want.id {
+				t.Errorf("LookupByIndex(%s) = %v, %v; want id %d", want.code, rows, err, want.id)
+			}
+		}
+	}
+	check(eng)
+
+	// The swaps replay from the WAL.
+	eng.Close()
+	eng2 := openEngine(t, dir)
+	defer eng2.Close()
+	check(eng2)
+}
+
+// TestHeap_UpdateRowsConflict checks that a batch whose new key is taken
+// leaves the rows and indexes as they were, even after earlier rows of the
+// batch took their keys.
+func TestHeap_UpdateRowsConflict(t *testing.T) {
+	h := newTableHeap(TableDef{Name: "t", Columns: []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true, Ordinal: 0},
+		{Name: "code", DataType: TypeText, Ordinal: 1},
+	}})
+	for i, code := range []string{"a", "b", "c"} {
+		if err := h.insertWithID(int64(i+1), []any{int64(i + 1), code}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := h.updateRows([]rowUpdate{
+		{RowID: 3, Values: []any{int64(4), "d"}},
+		{RowID: 1, Values: []any{int64(2), "e"}},
+	})
+	var uv *UniqueViolationError
+	if !errors.As(err, &uv) {
+		t.Fatalf("got %v, want a unique violation", err)
+	}
+	for id, want := range map[int64]string{1: "a", 2: "b", 3: "c"} {
+		row, ok := h.lookupByPK(id)
+		if !ok || row.ID != id || row.Values[1] != want {
+			t.Errorf("lookupByPK(%d) = %v, %v; want row %d with %q", id, row, ok, id, want)
+		}
+	}
+	if _, ok := h.lookupByPK(int64(4)); ok {
+		t.Error("key 4 of the failed batch is still indexed")
+	}
+}
+
 // -------------------------------------------------------------------------
 // Multi-table crash recovery — verifies atomicity across tables.
 //
@@ -1077,3 +1602,92 @@ func TestWAL_MultiTableCrashNoCommit(t *testing.T) {
 		}
 	}
 }
+
+func TestTxEngine_Returning(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("users", []ColumnDef{
+		{Name: "id", DataType: TypeInteger},
+		{Name: "name", DataType: TypeText},
+	})
+	eng.Insert("users", nil, [][]any{{int64(1), "alice"}})
+
+	tx := NewTxEngine(eng)
+	rows, err := tx.InsertReturning("users", nil, [][]any{{int64(2), "bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Values[1] != "bob" {
+		t.Fatalf("inserted = %v, want bob", rows)
+	}
+
+	// The update covers a committed row and an overlay insert.
+	rows, err = tx.UpdateReturning("users", map[string]any{"name": "x"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("updated %d rows, want 2", len(rows))
+	}
+	for _, r := range rows {
+		if r.Values[1] != "x" {
+			t.Errorf("updated row %d name = %v, want x", r.ID, r.Values[1])
+		}
+	}
+
+	rows, err = tx.DeleteReturning("users", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("deleted %d rows, want 2", len(rows))
+	}
+	for _, r := range rows {
+		if r.Values[1] != "x" {
+			t.Errorf("deleted row %d name = %v, want the updated value x", r.ID, r.Values[1])
+		}
+	}
+}
+
+func TestTxEngine_Upsert(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("users", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "name", DataType: TypeText},
+	})
+	eng.Insert("users", nil, [][]any{{int64(1), "alice"}})
+
+	tx := NewTxEngine(eng)
+	tx.Insert("users", nil, [][]any{{int64(2), "bob"}})
+
+	// Conflicts are found in both the heap and the overlay.
+	oc := OnConflict{Column: "id", Sets: map[string]any{"name": "dup"}}
+	rows, err := tx.Upsert("users", nil, [][]any{{int64(1), "a"}, {int64(2), "b"}, {int64(3), "c"}}, oc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0].Values[1] != "c" {
+		t.Fatalf("upsert = %v, want c then two updates", rows)
+	}
+
+	// Nothing reaches the engine before commit.
+	if row, _ := eng.LookupByPK("users", int64(1)); row.Values[1] != "alice" {
+		t.Errorf("before commit id 1 = %v, want alice", row.Values[1])
+	}
+	if err := tx.CommitOverlay(); err != nil {
+		t.Fatal(err)
+	}
+	for id := int64(1); id <= 2; id++ {
+		if row, _ := eng.LookupByPK("users", id); row == nil || row.Values[1] != "dup" {
+			t.Errorf("after commit id %d = %v, want dup", id, row)
+		}
+	}
+	if n, _ := eng.RowCount("users"); n != 3 {
+		t.Errorf("row count = %d, want 3", n)
+	}
+}
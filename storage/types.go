@@ -1,6 +1,12 @@
 package storage
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"mulldb/storage/index"
+)
 
 // DataType identifies a column's data type.
 type DataType uint8
@@ -11,6 +17,9 @@ const (
 	TypeBoolean
 	TypeTimestamp
 	TypeFloat
+	TypeBytea
+	TypeNumeric
+	TypeTime
 )
 
 func (d DataType) String() string {
@@ -25,6 +34,12 @@ func (d DataType) String() string {
 		return "TIMESTAMP"
 	case TypeFloat:
 		return "FLOAT"
+	case TypeBytea:
+		return "BYTEA"
+	case TypeNumeric:
+		return "NUMERIC"
+	case TypeTime:
+		return "TIME"
 	default:
 		return "UNKNOWN"
 	}
@@ -36,14 +51,87 @@ type ColumnDef struct {
 	DataType   DataType
 	PrimaryKey bool
 	NotNull    bool
-	Ordinal    int // permanent position index; never reused after DROP COLUMN
+	Ordinal    int    // permanent position index; never reused after DROP COLUMN
+	Default    string // DEFAULT expression as SQL text, "" for none; evaluated by the executor
+	Precision  int    // NUMERIC total digits; 0 for an unconstrained NUMERIC and other types
+	Scale      int    // NUMERIC digits after the decimal point
+	Fill       any    // value of rows that predate an added column; nil for NULL
+	Dictionary bool   // TEXT values are dictionary-encoded in memory (STORAGE DICTIONARY)
+
+	References *ForeignKey // REFERENCES constraint; nil for none
+	Identity   Identity    // whether the column's values are generated
+}
+
+// Identity says whether an INTEGER column takes its values from a
+// per-column sequence, as SERIAL and GENERATED ... AS IDENTITY columns do.
+type Identity uint8
+
+const (
+	IdentityNone      Identity = iota
+	IdentityByDefault          // SERIAL, GENERATED BY DEFAULT: generated when no value is given
+	IdentityAlways             // GENERATED ALWAYS: a given value is rejected
+)
+
+func (i Identity) String() string {
+	switch i {
+	case IdentityByDefault:
+		return "BY DEFAULT"
+	case IdentityAlways:
+		return "ALWAYS"
+	default:
+		return ""
+	}
+}
+
+// ForeignKey is a single-column REFERENCES constraint. The executor
+// enforces it; the engine only records it in the catalog.
+type ForeignKey struct {
+	Table    string   // referenced (parent) table
+	Column   string   // referenced column: the parent's primary key or a UNIQUE column
+	OnDelete FKAction // what deleting a referenced parent row does
+}
+
+// FKAction is the referential action of a foreign key.
+type FKAction uint8
+
+const (
+	FKNoAction FKAction = iota
+	FKRestrict
+	FKCascade
+	FKSetNull
+)
+
+func (a FKAction) String() string {
+	switch a {
+	case FKRestrict:
+		return "RESTRICT"
+	case FKCascade:
+		return "CASCADE"
+	case FKSetNull:
+		return "SET NULL"
+	default:
+		return "NO ACTION"
+	}
 }
 
 // IndexDef describes a secondary index on a table.
 type IndexDef struct {
-	Name   string // index name (unique within the table)
-	Column string // indexed column name
-	Unique bool   // true for UNIQUE indexes
+	Name    string   // index name (unique within the table)
+	Columns []string // indexed column names, in key order
+	Unique  bool     // true for UNIQUE indexes
+}
+
+// ColumnList returns the indexed column names separated by commas, as
+// they appear in CREATE INDEX.
+func (d IndexDef) ColumnList() string {
+	return strings.Join(d.Columns, ", ")
+}
+
+// ViewDef describes a view: a named SELECT that is expanded by the
+// executor each time the view is queried.
+type ViewDef struct {
+	Name  string
+	Query string // SELECT text as written in CREATE VIEW
 }
 
 // TableDef describes the schema of a table.
@@ -83,6 +171,8 @@ func RowValue(values []any, ordinal int) any {
 //	string     (TEXT)
 //	bool       (BOOLEAN)
 //	time.Time  (TIMESTAMP)
+//	TimeOfDay  (TIME)
+//	[]byte     (BYTEA)
 //	nil        (NULL)
 type Row struct {
 	ID     int64
@@ -95,6 +185,85 @@ type RowIterator interface {
 	Close() error
 }
 
+// BatchIterator is a RowIterator that can hand out several rows per call,
+// saving the per-row call overhead of long scans. The scans of the engine
+// implement it; use NextBatch to read any RowIterator in batches.
+type BatchIterator interface {
+	RowIterator
+	// NextBatch returns up to n rows, and none once the rows are used up.
+	// The slice is only valid until the next call.
+	NextBatch(n int) []Row
+}
+
+// NextBatch reads up to n rows from it, through its own NextBatch if it
+// is a BatchIterator and row by row otherwise; n below 1 counts as 1. An
+// empty result means the iterator is exhausted.
+func NextBatch(it RowIterator, n int) []Row {
+	if n < 1 {
+		n = 1
+	}
+	if b, ok := it.(BatchIterator); ok {
+		return b.NextBatch(n)
+	}
+	var rows []Row
+	for len(rows) < n {
+		row, ok := it.Next()
+		if !ok {
+			break
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// ScanCounter is a RowIterator that examines rows it does not hand out,
+// as those of ScanEqual do. Scanned returns the number of rows it has
+// examined so far.
+type ScanCounter interface {
+	RowIterator
+	Scanned() int64
+}
+
+// RowsScanned returns the number of rows it has examined, given that read
+// rows were read from it: its own count if it is a ScanCounter, and read
+// otherwise.
+func RowsScanned(it RowIterator, read int64) int64 {
+	if c, ok := it.(ScanCounter); ok {
+		return c.Scanned()
+	}
+	return read
+}
+
+// KeyBound is one end of a key range for ScanPKRange.
+type KeyBound struct {
+	Value     any
+	Inclusive bool
+}
+
+// indexBound converts b for the index package; a nil bound stays nil.
+func (b *KeyBound) indexBound() *index.Bound {
+	if b == nil {
+		return nil
+	}
+	return &index.Bound{Key: b.Value, Inclusive: b.Inclusive}
+}
+
+// inKeyRange reports whether key lies between lo and hi; a nil bound
+// leaves that end open.
+func inKeyRange(key any, lo, hi *KeyBound) bool {
+	if lo != nil {
+		if c := CompareValues(key, lo.Value); c == -2 || c < 0 || c == 0 && !lo.Inclusive {
+			return false
+		}
+	}
+	if hi != nil {
+		if c := CompareValues(key, hi.Value); c == -2 || c > 0 || c == 0 && !hi.Inclusive {
+			return false
+		}
+	}
+	return true
+}
+
 // -------------------------------------------------------------------------
 // Typed errors — used by the executor to map to SQLSTATE codes
 // -------------------------------------------------------------------------
@@ -106,6 +275,13 @@ func (e *TableExistsError) Error() string {
 	return fmt.Sprintf("table %q already exists", e.Name)
 }
 
+// ViewNotFoundError is returned when dropping a view that does not exist.
+type ViewNotFoundError struct{ Name string }
+
+func (e *ViewNotFoundError) Error() string {
+	return fmt.Sprintf("view %q does not exist", e.Name)
+}
+
 // TableNotFoundError is returned when referencing a table that does not exist.
 type TableNotFoundError struct{ Name string }
 
@@ -131,12 +307,15 @@ func (e *ValueCountError) Error() string {
 // violate a uniqueness constraint (primary key or unique index).
 type UniqueViolationError struct {
 	Table  string
-	Column string
+	Column string // the key's columns, comma-separated for a composite index
 	Value  any
 	Index  string // index name, if violation came from a secondary index
 }
 
 func (e *UniqueViolationError) Error() string {
+	if strings.Contains(e.Column, ", ") {
+		return fmt.Sprintf("duplicate key value violates unique constraint on columns (%s) of table %q", e.Column, e.Table)
+	}
 	return fmt.Sprintf("duplicate key value violates unique constraint on column %q of table %q", e.Column, e.Table)
 }
 
@@ -151,6 +330,16 @@ func (e *NotNullViolationError) Error() string {
 	return fmt.Sprintf("null value in column %q of relation %q violates not-null constraint", e.Column, e.Table)
 }
 
+// GeneratedAlwaysError is returned when an INSERT gives a value for a
+// GENERATED ALWAYS AS IDENTITY column.
+type GeneratedAlwaysError struct {
+	Column string
+}
+
+func (e *GeneratedAlwaysError) Error() string {
+	return fmt.Sprintf("cannot insert a non-DEFAULT value into column %q", e.Column)
+}
+
 // ColumnExistsError is returned when adding a column that already exists.
 type ColumnExistsError struct {
 	Column string
@@ -181,6 +370,75 @@ func (e *IndexNotFoundError) Error() string {
 	return fmt.Sprintf("index %q does not exist on table %q", e.Name, e.Table)
 }
 
+// ConflictTargetError is returned when an ON CONFLICT target column has
+// neither a primary key nor a unique index.
+type ConflictTargetError struct {
+	Table  string
+	Column string
+}
+
+func (e *ConflictTargetError) Error() string {
+	if e.Column == "" {
+		return "ON CONFLICT DO UPDATE requires a conflict target column"
+	}
+	return fmt.Sprintf("there is no unique constraint matching the ON CONFLICT specification (column %q of table %q)", e.Column, e.Table)
+}
+
+// SchemaChangedError is returned by COMMIT when another session changed or
+// dropped a table, or created one of the same name, after the transaction
+// ran DDL on it, or vacuumed a table after the transaction read it.
+type SchemaChangedError struct {
+	Table string
+}
+
+func (e *SchemaChangedError) Error() string {
+	return fmt.Sprintf("table %q was changed by another transaction", e.Table)
+}
+
+// InvalidValueError is returned when a string written to a column cannot
+// be read as a value of the column's type.
+type InvalidValueError struct {
+	Type  string // type name as PostgreSQL spells it, e.g. "integer"
+	Value string
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("invalid input syntax for type %s: %q", e.Type, e.Value)
+}
+
+// DatatypeMismatchError is returned when a value written to a column has a
+// type that does not convert to the column's type.
+type DatatypeMismatchError struct {
+	Column string
+	Type   string // the column's type
+	Got    string // the value's type
+}
+
+func (e *DatatypeMismatchError) Error() string {
+	return fmt.Sprintf("column %q is of type %s but expression is of type %s", e.Column, e.Type, e.Got)
+}
+
+// RowAffectedTwiceError is returned when one ON CONFLICT DO UPDATE
+// statement would update the same row twice, e.g. because two of its
+// VALUES rows carry the same key.
+type RowAffectedTwiceError struct{ Table string }
+
+func (e *RowAffectedTwiceError) Error() string {
+	return "ON CONFLICT DO UPDATE command cannot affect row a second time"
+}
+
+// OnConflict tells Upsert what to do with a row whose key collides with an
+// existing row.
+type OnConflict struct {
+	// Column is the conflict target: the primary key column or a column
+	// with a unique index. "" matches any uniqueness constraint and is
+	// only valid for DO NOTHING.
+	Column string
+	// Sets holds the DO UPDATE assignments applied to the existing row.
+	// nil means DO NOTHING: the row is skipped.
+	Sets map[string]any
+}
+
 // TableMemoryInfo holds memory usage information for a single table.
 type TableMemoryInfo struct {
 	TableName string
@@ -209,11 +467,70 @@ type Engine interface {
 	Scan(table string) (RowIterator, error)
 	Update(table string, sets map[string]any, filter func(Row) bool) (int64, error)
 	Delete(table string, filter func(Row) bool) (int64, error)
+	// The Returning variants report the affected rows instead of a count:
+	// the stored rows for INSERT and UPDATE, the removed rows for DELETE.
+	// Values are shared with the heap and must not be modified.
+	InsertReturning(table string, columns []string, values [][]any) ([]Row, error)
+	UpdateReturning(table string, sets map[string]any, filter func(Row) bool) ([]Row, error)
+	DeleteReturning(table string, filter func(Row) bool) ([]Row, error)
+	// DeleteRows removes the rows with the given IDs, as found by an index
+	// lookup, and returns them. IDs of rows that no longer exist are
+	// skipped. A non-nil filter is checked again under the table lock, so
+	// a row changed since its ID was looked up is kept if it no longer
+	// matches.
+	DeleteRows(table string, rowIDs []int64, filter func(Row) bool) ([]Row, error)
+	// Upsert inserts rows like InsertReturning, resolving key collisions
+	// as described by oc. It returns the inserted rows followed by the
+	// updated ones; skipped rows are left out.
+	Upsert(table string, columns []string, values [][]any, oc OnConflict) ([]Row, error)
+	// Truncate removes every row of the tables, all of them or, on
+	// failure, none. restartIdentity also resets the sequences of their
+	// identity columns; otherwise they continue where they were.
+	Truncate(tables []string, restartIdentity bool) error
+	// LoadCSV inserts the rows of a CSV file into table, all or none of
+	// them, and returns how many it stored.
+	LoadCSV(table string, r io.Reader, opts CSVOptions) (int64, error)
 	LookupByPK(table string, value any) (*Row, error)
+	// ScanPKRange returns the rows whose primary key lies between lo and
+	// hi, in ascending key order, using the primary key index. A nil
+	// bound leaves that end open.
+	ScanPKRange(table string, lo, hi *KeyBound) (RowIterator, error)
+	// ScanEqual returns the rows whose column equals value, which is of
+	// the column's type, in the order Scan returns them. On a
+	// dictionary-encoded column the rows are matched by dictionary index,
+	// without building the others.
+	ScanEqual(table, column string, value any) (RowIterator, error)
 	CreateIndex(table string, idx IndexDef) error
 	DropIndex(table string, indexName string) error
 	LookupByIndex(table string, indexName string, value any) ([]Row, error)
+	// LookupByIndexRange returns the rows whose key in the named index
+	// lies between lo and hi, in ascending key order. A nil bound leaves
+	// that end open.
+	LookupByIndexRange(table string, indexName string, lo, hi *KeyBound) ([]Row, error)
+	// Views share the table namespace: CreateView fails with
+	// TableExistsError if a table or view of that name exists, and
+	// CreateTable fails likewise for a view name.
+	CreateView(name, query string) error
+	DropView(name string) error
+	GetView(name string) (*ViewDef, bool)
+	ListViews() []*ViewDef
 	RowCount(table string) (int64, error)
+	// Analyze refreshes the planner statistics of table; Stats returns
+	// them, or nil if the table has not been analyzed.
+	Analyze(table string) error
+	Stats(table string) *TableStats
+	// Vacuum compacts the rows array of table, giving its live rows new,
+	// consecutive IDs, and returns how many slots it reclaimed.
+	Vacuum(table string) (int64, error)
+	// Checkpoint replaces the WAL of table with a snapshot of its live
+	// rows.
+	Checkpoint(table string) error
+	// Snapshot writes a consistent copy of the whole database to destDir,
+	// which Open restores.
+	Snapshot(destDir string) error
+	// Backup writes a consistent copy of the whole database to w as one
+	// file, which Restore turns back into a data directory.
+	Backup(w io.Writer) error
 	MemoryUsage() []TableMemoryInfo
 	SetFsync(enabled bool)
 	GetFsync() bool
@@ -7,14 +7,15 @@ import (
 	"io"
 	"log"
 	"os"
+	"sync"
 	"sync/atomic"
 )
 
 // WAL file header: [4-byte magic "MWAL"][uint16 version]
 const (
 	walMagic          = "MWAL"
-	walHeaderSize     = 6 // 4 (magic) + 2 (version)
-	walCurrentVersion = 4 // v1 = legacy (no PK flag), v2 = PK flag, v3 = ordinals + ALTER TABLE, v4 = NOT NULL flag
+	walHeaderSize     = 6  // 4 (magic) + 2 (version)
+	walCurrentVersion = 12 // v1 = legacy (no PK flag), v2 = PK flag, v3 = ordinals + ALTER TABLE, v4 = NOT NULL flag, v5 = column defaults, v6 = NUMERIC precision/scale, v7 = ADD COLUMN fill value, v8 = multi-column indexes, v9 = foreign keys, v10 = identity columns, v11 = TRUNCATE RESTART IDENTITY flag, v12 = dictionary-encoded columns
 )
 
 // WAL operation types.
@@ -32,6 +33,11 @@ const (
 	opBeginTx     byte = 11
 	opCommitTx    byte = 12
 	opTxCommit    byte = 13 // catalog-level: atomic commit record for multi-table transactions
+	opTruncate    byte = 14 // table-level: remove every row in one entry
+	opCreateView  byte = 15 // catalog-level
+	opDropView    byte = 16 // catalog-level
+	opVacuum      byte = 17 // table-level: compact the rows array, renumbering rows
+	opIdentity    byte = 18 // table-level: identity sequence positions, from a checkpoint
 )
 
 // WALMigrationNeededError is returned when a WAL file requires migration
@@ -63,11 +69,53 @@ type rowUpdate struct {
 // WAL manages an append-only write-ahead log file.
 // Entry format: [uint32 totalLen][byte op][payload…][uint32 crc32]
 // CRC covers the op byte + payload.
+//
+// Entries are appended by the caller; fsyncs are done by a flusher
+// goroutine. A writer that needs its entries durable asks for a flush and
+// waits for it, and requests that arrive while an fsync is running are
+// served together by the next one, so concurrent writers share fsyncs
+// (group commit).
 type WAL struct {
 	file  *os.File
 	fsync *atomic.Bool
+
+	// syncFile fsyncs file; nil means file.Sync. Tests replace it to
+	// simulate a failing disk.
+	syncFile func(*os.File) error
+
+	// mu guards the fields below, which writers share with the flusher.
+	mu sync.Mutex
+	// flushed is broadcast each time a flush ends.
+	flushed *sync.Cond
+	// written counts the entries appended so far; the first durable of
+	// them are known to be on disk.
+	written, durable uint64
+
+	// err is the first write or fsync failure. Once a fsync has failed
+	// the kernel may have dropped the unwritten pages, so a later fsync
+	// succeeding proves nothing; every write after a failure therefore
+	// returns err until the WAL is reopened.
+	err error
+
+	wake    chan struct{} // flush requests; holds one, so they coalesce
+	quit    chan struct{} // closed by Close to stop the flusher
+	stopped chan struct{} // closed by the flusher when it returns
+}
+
+// WALIOError is returned when writing or fsyncing a WAL file fails. The
+// WAL refuses further writes after the first one.
+type WALIOError struct {
+	Op   string // "write" or "fsync"
+	Path string
+	Err  error
 }
 
+func (e *WALIOError) Error() string {
+	return fmt.Sprintf("could not %s WAL file %q: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *WALIOError) Unwrap() error { return e.Err }
+
 // OpenWAL opens (or creates) the WAL file at path. If the file uses an
 // older format version and migrate is true, it is migrated in place
 // (with the original preserved as a .bak file). If migrate is false and
@@ -125,7 +173,53 @@ func OpenWAL(path string, migrate bool) (*WAL, error) {
 		f.Close()
 		return nil, err
 	}
-	return &WAL{file: f}, nil
+	return newWAL(f), nil
+}
+
+// newWAL returns a WAL appending to f and starts its flusher.
+func newWAL(f *os.File) *WAL {
+	w := &WAL{
+		file:    f,
+		wake:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	w.flushed = sync.NewCond(&w.mu)
+	go w.flusher()
+	return w
+}
+
+// flusher fsyncs the file whenever a writer asks, covering every entry
+// written by the time the fsync starts, until Close stops it.
+func (w *WAL) flusher() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.wake:
+		case <-w.quit:
+			return
+		}
+		w.mu.Lock()
+		upTo, failed := w.written, w.err != nil
+		w.mu.Unlock()
+		if failed {
+			continue
+		}
+		syncFile := w.syncFile
+		if syncFile == nil {
+			syncFile = (*os.File).Sync
+		}
+		err := syncFile(w.file)
+
+		w.mu.Lock()
+		if err != nil {
+			w.err = &WALIOError{Op: "fsync", Path: w.file.Name(), Err: err}
+		} else {
+			w.durable = upTo
+		}
+		w.flushed.Broadcast()
+		w.mu.Unlock()
+	}
 }
 
 // readWALVersion detects the WAL format version from the file header.
@@ -162,17 +256,93 @@ func readWALVersion(f *os.File) (uint16, error) {
 }
 
 // writeWALHeader writes the magic + version header at the current position.
-func writeWALHeader(f *os.File) error {
+func writeWALHeader(w io.Writer) error {
 	var hdr [walHeaderSize]byte
 	copy(hdr[:4], walMagic)
 	binary.BigEndian.PutUint16(hdr[4:], walCurrentVersion)
-	_, err := f.Write(hdr[:])
+	_, err := w.Write(hdr[:])
 	return err
 }
 
-// Close closes the WAL file.
+// Close fsyncs any entries written since the last fsync, stops the
+// flusher and closes the WAL file. It reports an earlier write or fsync
+// failure if there was one.
 func (w *WAL) Close() error {
-	return w.file.Close()
+	err := w.sync()
+	close(w.quit)
+	<-w.stopped
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// write appends entry to the file, recording a failure in w.err.
+func (w *WAL) write(entry []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	if _, err := w.file.Write(entry); err != nil {
+		w.err = &WALIOError{Op: "write", Path: w.file.Name(), Err: err}
+		return w.err
+	}
+	w.written++
+	return nil
+}
+
+// failure returns the write or fsync failure that stopped the WAL, or nil.
+func (w *WAL) failure() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Pos returns the position after the last entry written, for SyncTo.
+func (w *WAL) Pos() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+// SyncTo waits until the entries before pos are durable, if fsync is on.
+// Writers call it after releasing the table lock, so that others can
+// append their entries meanwhile and share the fsync.
+func (w *WAL) SyncTo(pos uint64) error {
+	if w.fsync != nil && !w.fsync.Load() {
+		return nil
+	}
+	return w.syncTo(pos)
+}
+
+// syncTo has the flusher fsync the file until the entries before pos are
+// durable. It returns the recorded failure if they cannot be.
+func (w *WAL) syncTo(pos uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.durable < pos && w.err == nil {
+		select {
+		case w.wake <- struct{}{}:
+		default: // a request is pending already
+		}
+		w.flushed.Wait()
+	}
+	if w.durable < pos {
+		return w.err
+	}
+	return nil
+}
+
+// sync fsyncs every entry written so far, recording a failure in w.err.
+func (w *WAL) sync() error {
+	w.mu.Lock()
+	pos, err := w.written, w.err
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return w.syncTo(pos)
 }
 
 // writeEntry appends a single WAL entry and fsyncs.
@@ -185,17 +355,18 @@ func (w *WAL) writeEntry(op byte, payload []byte) error {
 	entry = append(entry, payload...)
 	entry = binary.BigEndian.AppendUint32(entry, crc32.ChecksumIEEE(entry[4:])) // crc of op+payload
 
-	if _, err := w.file.Write(entry); err != nil {
+	if err := w.write(entry); err != nil {
 		return err
 	}
-	if w.fsync == nil || w.fsync.Load() {
-		return w.file.Sync()
-	}
-	return nil
+	return w.SyncTo(w.Pos())
 }
 
 // WriteCreateTable logs a CREATE TABLE operation.
-// v4 format: [table:str][colCount:u16] per col: [name:str][datatype:u8][pk:u8][notNull:u8][ordinal:u16]
+// v12 format: [table:str][colCount:u16] per col: [name:str][datatype:u8][pk:u8][notNull:u8][ordinal:u16][default:str][precision:u16][scale:u16]
+// followed by [fkCount:u16] per foreign key: [col:u16][refTable:str][refColumn:str][onDelete:u8]
+// where col is the referencing column's position in the column list,
+// followed by [identityCount:u16] per identity column: [col:u16][identity:u8],
+// followed by [dictCount:u16] per dictionary-encoded column: [col:u16].
 func (w *WAL) WriteCreateTable(name string, columns []ColumnDef) error {
 	buf := encodeString(nil, name)
 	buf = binary.BigEndian.AppendUint16(buf, uint16(len(columns)))
@@ -213,6 +384,44 @@ func (w *WAL) WriteCreateTable(name string, columns []ColumnDef) error {
 		}
 		buf = append(buf, nnFlag)
 		buf = binary.BigEndian.AppendUint16(buf, uint16(col.Ordinal))
+		buf = encodeString(buf, col.Default)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(col.Precision))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(col.Scale))
+	}
+	var fks []int
+	for i, col := range columns {
+		if col.References != nil {
+			fks = append(fks, i)
+		}
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(fks)))
+	for _, i := range fks {
+		fk := columns[i].References
+		buf = binary.BigEndian.AppendUint16(buf, uint16(i))
+		buf = encodeString(buf, fk.Table)
+		buf = encodeString(buf, fk.Column)
+		buf = append(buf, byte(fk.OnDelete))
+	}
+	var idents []int
+	for i, col := range columns {
+		if col.Identity != IdentityNone {
+			idents = append(idents, i)
+		}
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(idents)))
+	for _, i := range idents {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(i))
+		buf = append(buf, byte(columns[i].Identity))
+	}
+	var dicts []int
+	for i, col := range columns {
+		if col.Dictionary {
+			dicts = append(dicts, i)
+		}
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(dicts)))
+	for _, i := range dicts {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(i))
 	}
 	return w.writeEntry(opCreateTable, buf)
 }
@@ -223,7 +432,7 @@ func (w *WAL) WriteDropTable(name string) error {
 }
 
 // WriteAddColumn logs an ALTER TABLE ADD COLUMN operation.
-// v4 format: [table:str][name:str][datatype:u8][pk:u8][notNull:u8][ordinal:u16]
+// v12 format: [table:str][name:str][datatype:u8][pk:u8][notNull:u8][ordinal:u16][default:str][precision:u16][scale:u16][fill:value][dictionary:u8]
 func (w *WAL) WriteAddColumn(table string, col ColumnDef) error {
 	buf := encodeString(nil, table)
 	buf = encodeString(buf, col.Name)
@@ -239,6 +448,15 @@ func (w *WAL) WriteAddColumn(table string, col ColumnDef) error {
 	}
 	buf = append(buf, nnFlag)
 	buf = binary.BigEndian.AppendUint16(buf, uint16(col.Ordinal))
+	buf = encodeString(buf, col.Default)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(col.Precision))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(col.Scale))
+	buf = encodeValue(buf, col.Fill)
+	var dictFlag byte
+	if col.Dictionary {
+		dictFlag = 1
+	}
+	buf = append(buf, dictFlag)
 	return w.writeEntry(opAddColumn, buf)
 }
 
@@ -251,11 +469,14 @@ func (w *WAL) WriteDropColumn(table string, colName string) error {
 }
 
 // WriteCreateIndex logs a CREATE INDEX operation.
-// Format: [table:str][indexName:str][columnName:str][unique:u8]
+// v8 format: [table:str][indexName:str][colCount:u16][columnName:str]...[unique:u8]
 func (w *WAL) WriteCreateIndex(table string, idx IndexDef) error {
 	buf := encodeString(nil, table)
 	buf = encodeString(buf, idx.Name)
-	buf = encodeString(buf, idx.Column)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(idx.Columns)))
+	for _, col := range idx.Columns {
+		buf = encodeString(buf, col)
+	}
 	var uniqueFlag byte
 	if idx.Unique {
 		uniqueFlag = 1
@@ -264,6 +485,20 @@ func (w *WAL) WriteCreateIndex(table string, idx IndexDef) error {
 	return w.writeEntry(opCreateIndex, buf)
 }
 
+// WriteCreateView logs a CREATE VIEW operation.
+// Format: [name:str][query:str]
+func (w *WAL) WriteCreateView(name, query string) error {
+	buf := encodeString(nil, name)
+	buf = encodeString(buf, query)
+	return w.writeEntry(opCreateView, buf)
+}
+
+// WriteDropView logs a DROP VIEW operation.
+// Format: [name:str]
+func (w *WAL) WriteDropView(name string) error {
+	return w.writeEntry(opDropView, encodeString(nil, name))
+}
+
 // WriteDropIndex logs a DROP INDEX operation.
 // Format: [table:str][indexName:str]
 func (w *WAL) WriteDropIndex(table string, indexName string) error {
@@ -295,6 +530,38 @@ func (w *WAL) WriteDelete(table string, rowIDs []int64) error {
 	return w.writeEntry(opDelete, buf)
 }
 
+// WriteTruncate logs a TRUNCATE: every row of the table is removed, and
+// with restartIdentity its identity sequences start over.
+// Format: [table:str][restartIdentity:u8]
+func (w *WAL) WriteTruncate(table string, restartIdentity bool) error {
+	buf := encodeString(nil, table)
+	if restartIdentity {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	return w.writeEntry(opTruncate, buf)
+}
+
+// WriteTruncateNoSync logs a TRUNCATE without fsyncing (used inside
+// transactions).
+func (w *WAL) WriteTruncateNoSync(table string, restartIdentity bool) error {
+	buf := encodeString(nil, table)
+	if restartIdentity {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	return w.writeEntryNoSync(opTruncate, buf)
+}
+
+// WriteVacuum logs a VACUUM, after which replay renumbers the table's
+// rows as the live heap did.
+// Format: [table:str]
+func (w *WAL) WriteVacuum(table string) error {
+	return w.writeEntry(opVacuum, encodeString(nil, table))
+}
+
 // WriteBeginTx logs a transaction begin marker. No fsync — the commit
 // marker will fsync the whole group.
 func (w *WAL) WriteBeginTx() error {
@@ -306,6 +573,12 @@ func (w *WAL) WriteCommitTx() error {
 	return w.writeEntry(opCommitTx, nil)
 }
 
+// WriteCommitTxNoSync logs a transaction commit marker without fsyncing;
+// the writer waits for it with SyncTo.
+func (w *WAL) WriteCommitTxNoSync() error {
+	return w.writeEntryNoSync(opCommitTx, nil)
+}
+
 // WriteTxCommit writes a multi-table transaction commit record to the
 // catalog WAL. This is the single atomic commit point for multi-table
 // transactions. The record lists all table names whose per-table WAL
@@ -330,8 +603,7 @@ func (w *WAL) writeEntryNoSync(op byte, payload []byte) error {
 	entry = append(entry, payload...)
 	entry = binary.BigEndian.AppendUint32(entry, crc32.ChecksumIEEE(entry[4:]))
 
-	_, err := w.file.Write(entry)
-	return err
+	return w.write(entry)
 }
 
 // WriteInsertBatchNoSync logs a batch INSERT without fsyncing (used inside transactions).
@@ -368,7 +640,7 @@ func (w *WAL) WriteUpdateNoSync(table string, updates []rowUpdate) error {
 
 // Sync fsyncs the WAL file (used after writing all transaction entries).
 func (w *WAL) Sync() error {
-	return w.file.Sync()
+	return w.sync()
 }
 
 // WriteUpdate logs an UPDATE operation.
@@ -394,9 +666,14 @@ type ReplayHandler interface {
 	OnDropColumn(table string, colName string) error
 	OnCreateIndex(table string, idx IndexDef) error
 	OnDropIndex(table string, indexName string) error
+	OnCreateView(name, query string) error
+	OnDropView(name string) error
 	OnInsert(table string, rowID int64, values []any) error
 	OnDelete(table string, rowIDs []int64) error
 	OnUpdate(table string, updates []rowUpdate) error
+	OnTruncate(table string, restartIdentity bool) error
+	OnVacuum(table string) error
+	OnIdentity(table string, last map[int]int64) error
 	OnTxCommit(tables []string) error
 }
 
@@ -551,6 +828,16 @@ func replayEntry(op byte, payload []byte, h ReplayHandler) error {
 		return replayCreateIndex(payload, h)
 	case opDropIndex:
 		return replayDropIndex(payload, h)
+	case opTruncate:
+		return replayTruncate(payload, h)
+	case opVacuum:
+		return replayVacuum(payload, h)
+	case opIdentity:
+		return replayIdentity(payload, h)
+	case opCreateView:
+		return replayCreateView(payload, h)
+	case opDropView:
+		return replayDropView(payload, h)
 	case opTxCommit:
 		return replayTxCommit(payload, h)
 	default:
@@ -599,7 +886,75 @@ func replayCreateTable(payload []byte, h ReplayHandler) error {
 		cols[i].PrimaryKey = rest[1] != 0
 		cols[i].NotNull = rest[2] != 0
 		cols[i].Ordinal = int(binary.BigEndian.Uint16(rest[3:5]))
-		rest = rest[5:]
+		cols[i].Default, rest, err = decodeString(rest[5:])
+		if err != nil {
+			return err
+		}
+		if len(rest) < 4 { // precision(2) + scale(2)
+			return fmt.Errorf("truncated column precision/scale")
+		}
+		cols[i].Precision = int(binary.BigEndian.Uint16(rest[:2]))
+		cols[i].Scale = int(binary.BigEndian.Uint16(rest[2:4]))
+		rest = rest[4:]
+	}
+	if len(rest) < 2 {
+		return fmt.Errorf("truncated foreign key count")
+	}
+	fkCount := binary.BigEndian.Uint16(rest[:2])
+	rest = rest[2:]
+	for range fkCount {
+		if len(rest) < 2 {
+			return fmt.Errorf("truncated foreign key column")
+		}
+		i := int(binary.BigEndian.Uint16(rest[:2]))
+		if i >= len(cols) {
+			return fmt.Errorf("foreign key column %d out of range", i)
+		}
+		fk := &ForeignKey{}
+		if fk.Table, rest, err = decodeString(rest[2:]); err != nil {
+			return err
+		}
+		if fk.Column, rest, err = decodeString(rest); err != nil {
+			return err
+		}
+		if len(rest) < 1 {
+			return fmt.Errorf("truncated foreign key action")
+		}
+		fk.OnDelete = FKAction(rest[0])
+		rest = rest[1:]
+		cols[i].References = fk
+	}
+	if len(rest) < 2 {
+		return fmt.Errorf("truncated identity column count")
+	}
+	identCount := binary.BigEndian.Uint16(rest[:2])
+	rest = rest[2:]
+	for range identCount {
+		if len(rest) < 3 { // col(2) + identity(1)
+			return fmt.Errorf("truncated identity column")
+		}
+		i := int(binary.BigEndian.Uint16(rest[:2]))
+		if i >= len(cols) {
+			return fmt.Errorf("identity column %d out of range", i)
+		}
+		cols[i].Identity = Identity(rest[2])
+		rest = rest[3:]
+	}
+	if len(rest) < 2 {
+		return fmt.Errorf("truncated dictionary column count")
+	}
+	dictCount := binary.BigEndian.Uint16(rest[:2])
+	rest = rest[2:]
+	for range dictCount {
+		if len(rest) < 2 {
+			return fmt.Errorf("truncated dictionary column")
+		}
+		i := int(binary.BigEndian.Uint16(rest[:2]))
+		if i >= len(cols) {
+			return fmt.Errorf("dictionary column %d out of range", i)
+		}
+		cols[i].Dictionary = true
+		rest = rest[2:]
 	}
 	return h.OnCreateTable(name, cols)
 }
@@ -629,6 +984,23 @@ func replayAddColumn(payload []byte, h ReplayHandler) error {
 	col.PrimaryKey = rest[1] != 0
 	col.NotNull = rest[2] != 0
 	col.Ordinal = int(binary.BigEndian.Uint16(rest[3:5]))
+	col.Default, rest, err = decodeString(rest[5:])
+	if err != nil {
+		return err
+	}
+	if len(rest) < 4 { // precision(2) + scale(2)
+		return fmt.Errorf("truncated add column precision/scale")
+	}
+	col.Precision = int(binary.BigEndian.Uint16(rest[:2]))
+	col.Scale = int(binary.BigEndian.Uint16(rest[2:4]))
+	col.Fill, rest, err = decodeValue(rest[4:])
+	if err != nil {
+		return fmt.Errorf("add column fill value: %w", err)
+	}
+	if len(rest) < 1 {
+		return fmt.Errorf("truncated add column dictionary flag")
+	}
+	col.Dictionary = rest[0] != 0
 	return h.OnAddColumn(table, col)
 }
 
@@ -735,6 +1107,47 @@ func replayUpdate(payload []byte, h ReplayHandler) error {
 	return h.OnUpdate(table, updates)
 }
 
+func replayTruncate(payload []byte, h ReplayHandler) error {
+	table, rest, err := decodeString(payload)
+	if err != nil {
+		return err
+	}
+	if len(rest) < 1 {
+		return fmt.Errorf("truncated truncate flags")
+	}
+	return h.OnTruncate(table, rest[0] == 1)
+}
+
+func replayVacuum(payload []byte, h ReplayHandler) error {
+	table, _, err := decodeString(payload)
+	if err != nil {
+		return err
+	}
+	return h.OnVacuum(table)
+}
+
+func replayIdentity(payload []byte, h ReplayHandler) error {
+	table, rest, err := decodeString(payload)
+	if err != nil {
+		return err
+	}
+	if len(rest) < 2 {
+		return fmt.Errorf("truncated identity count")
+	}
+	count := int(binary.BigEndian.Uint16(rest[:2]))
+	rest = rest[2:]
+	if len(rest) < count*10 {
+		return fmt.Errorf("truncated identity entry")
+	}
+	last := make(map[int]int64, count)
+	for range count {
+		ord := int(binary.BigEndian.Uint16(rest[:2]))
+		last[ord] = int64(binary.BigEndian.Uint64(rest[2:10]))
+		rest = rest[10:]
+	}
+	return h.OnIdentity(table, last)
+}
+
 func replayCreateIndex(payload []byte, h ReplayHandler) error {
 	table, rest, err := decodeString(payload)
 	if err != nil {
@@ -745,9 +1158,17 @@ func replayCreateIndex(payload []byte, h ReplayHandler) error {
 	if err != nil {
 		return err
 	}
-	idx.Column, rest, err = decodeString(rest)
-	if err != nil {
-		return err
+	if len(rest) < 2 {
+		return fmt.Errorf("truncated create index column count")
+	}
+	count := int(binary.BigEndian.Uint16(rest[:2]))
+	rest = rest[2:]
+	idx.Columns = make([]string, count)
+	for i := range idx.Columns {
+		idx.Columns[i], rest, err = decodeString(rest)
+		if err != nil {
+			return err
+		}
 	}
 	if len(rest) < 1 {
 		return fmt.Errorf("truncated create index unique flag")
@@ -756,6 +1177,26 @@ func replayCreateIndex(payload []byte, h ReplayHandler) error {
 	return h.OnCreateIndex(table, idx)
 }
 
+func replayCreateView(payload []byte, h ReplayHandler) error {
+	name, rest, err := decodeString(payload)
+	if err != nil {
+		return err
+	}
+	query, _, err := decodeString(rest)
+	if err != nil {
+		return err
+	}
+	return h.OnCreateView(name, query)
+}
+
+func replayDropView(payload []byte, h ReplayHandler) error {
+	name, _, err := decodeString(payload)
+	if err != nil {
+		return err
+	}
+	return h.OnDropView(name)
+}
+
 func replayDropIndex(payload []byte, h ReplayHandler) error {
 	table, rest, err := decodeString(payload)
 	if err != nil {
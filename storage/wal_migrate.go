@@ -19,9 +19,17 @@ type entryMigrateFunc func(op byte, payload []byte) (byte, []byte, error)
 // entries to the next version. To migrate across multiple versions the
 // functions are applied sequentially (v1→v2, v2→v3, …).
 var walMigrations = map[uint16]entryMigrateFunc{
-	1: migrateV1ToV2,
-	2: migrateV2ToV3,
-	3: migrateV3ToV4,
+	1:  migrateV1ToV2,
+	2:  migrateV2ToV3,
+	3:  migrateV3ToV4,
+	4:  migrateV4ToV5,
+	5:  migrateV5ToV6,
+	6:  migrateV6ToV7,
+	7:  migrateV7ToV8,
+	8:  migrateV8ToV9,
+	9:  migrateV9ToV10,
+	10: migrateV10ToV11,
+	11: migrateV11ToV12,
 }
 
 // rawEntry is an undecoded WAL entry (op + payload, CRC already verified).
@@ -413,6 +421,202 @@ func migrateV3ToV4AddColumn(payload []byte) (byte, []byte, error) {
 	return opAddColumn, buf, nil
 }
 
+// migrateV4ToV5 appends an empty default expression to each column in
+// CREATE TABLE and ADD COLUMN entries: no column had a default before v5.
+// All other entry types pass through unchanged.
+//
+// v4 column format: [string name][byte dataType][byte pkFlag][byte notNullFlag][uint16 ordinal]
+// v5 column format: [string name][byte dataType][byte pkFlag][byte notNullFlag][uint16 ordinal][string default]
+func migrateV4ToV5(op byte, payload []byte) (byte, []byte, error) {
+	switch op {
+	case opCreateTable:
+		name, rest, err := decodeString(payload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("decode table name: %w", err)
+		}
+		if len(rest) < 2 {
+			return 0, nil, fmt.Errorf("truncated column count")
+		}
+		count := binary.BigEndian.Uint16(rest[:2])
+		rest = rest[2:]
+
+		buf := encodeString(nil, name)
+		buf = binary.BigEndian.AppendUint16(buf, count)
+		for i := 0; i < int(count); i++ {
+			var colName string
+			colName, rest, err = decodeString(rest)
+			if err != nil {
+				return 0, nil, fmt.Errorf("column %d name: %w", i, err)
+			}
+			if len(rest) < 5 { // datatype(1) + pk(1) + notNull(1) + ordinal(2)
+				return 0, nil, fmt.Errorf("column %d: truncated data", i)
+			}
+			buf = encodeString(buf, colName)
+			buf = append(buf, rest[:5]...)
+			buf = encodeString(buf, "")
+			rest = rest[5:]
+		}
+		return opCreateTable, buf, nil
+	case opAddColumn:
+		// The column definition ends the payload, so the default is
+		// simply appended.
+		buf := append([]byte(nil), payload...)
+		return opAddColumn, encodeString(buf, ""), nil
+	default:
+		return op, payload, nil
+	}
+}
+
+// migrateV5ToV6 appends a zero precision and scale to each column in
+// CREATE TABLE and ADD COLUMN entries: NUMERIC did not exist before v6, and
+// other types leave both at zero. All other entry types pass through
+// unchanged.
+//
+// v5 column format: [string name][byte dataType][byte pkFlag][byte notNullFlag][uint16 ordinal][string default]
+// v6 column format: [string name][byte dataType][byte pkFlag][byte notNullFlag][uint16 ordinal][string default][uint16 precision][uint16 scale]
+func migrateV5ToV6(op byte, payload []byte) (byte, []byte, error) {
+	switch op {
+	case opCreateTable:
+		name, rest, err := decodeString(payload)
+		if err != nil {
+			return 0, nil, fmt.Errorf("decode table name: %w", err)
+		}
+		if len(rest) < 2 {
+			return 0, nil, fmt.Errorf("truncated column count")
+		}
+		count := binary.BigEndian.Uint16(rest[:2])
+		rest = rest[2:]
+
+		buf := encodeString(nil, name)
+		buf = binary.BigEndian.AppendUint16(buf, count)
+		for i := 0; i < int(count); i++ {
+			var colName, def string
+			colName, rest, err = decodeString(rest)
+			if err != nil {
+				return 0, nil, fmt.Errorf("column %d name: %w", i, err)
+			}
+			if len(rest) < 5 { // datatype(1) + pk(1) + notNull(1) + ordinal(2)
+				return 0, nil, fmt.Errorf("column %d: truncated data", i)
+			}
+			flags := rest[:5]
+			def, rest, err = decodeString(rest[5:])
+			if err != nil {
+				return 0, nil, fmt.Errorf("column %d default: %w", i, err)
+			}
+			buf = encodeString(buf, colName)
+			buf = append(buf, flags...)
+			buf = encodeString(buf, def)
+			buf = append(buf, 0, 0, 0, 0)
+		}
+		return opCreateTable, buf, nil
+	case opAddColumn:
+		buf := append([]byte(nil), payload...)
+		return opAddColumn, append(buf, 0, 0, 0, 0), nil
+	default:
+		return op, payload, nil
+	}
+}
+
+// migrateV6ToV7 appends a NULL fill value to ADD COLUMN entries: before
+// v7 an added column read as NULL in the rows that predate it. All other
+// entry types pass through unchanged.
+//
+// v6 ADD COLUMN format: [string table][column]
+// v7 ADD COLUMN format: [string table][column][value fill]
+func migrateV6ToV7(op byte, payload []byte) (byte, []byte, error) {
+	if op != opAddColumn {
+		return op, payload, nil
+	}
+	buf := append([]byte(nil), payload...)
+	return opAddColumn, encodeValue(buf, nil), nil
+}
+
+// migrateV7ToV8 converts CREATE INDEX entries from v7 to v8 format: v8
+// indexes list their columns, so the single v7 column becomes a list of
+// one. All other entry types pass through unchanged.
+//
+// v7 CREATE INDEX format: [string table][string name][string column][u8 unique]
+// v8 CREATE INDEX format: [string table][string name][u16 count][string column]...[u8 unique]
+func migrateV7ToV8(op byte, payload []byte) (byte, []byte, error) {
+	if op != opCreateIndex {
+		return op, payload, nil
+	}
+	table, rest, err := decodeString(payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	name, rest, err := decodeString(rest)
+	if err != nil {
+		return 0, nil, err
+	}
+	column, rest, err := decodeString(rest)
+	if err != nil {
+		return 0, nil, err
+	}
+	buf := encodeString(nil, table)
+	buf = encodeString(buf, name)
+	buf = binary.BigEndian.AppendUint16(buf, 1)
+	buf = encodeString(buf, column)
+	return opCreateIndex, append(buf, rest...), nil
+}
+
+// migrateV8ToV9 appends an empty foreign key list to CREATE TABLE
+// entries: no table had foreign keys before v9. All other entry types pass
+// through unchanged.
+//
+// v9 CREATE TABLE format: the v8 payload followed by [u16 fkCount]
+func migrateV8ToV9(op byte, payload []byte) (byte, []byte, error) {
+	if op != opCreateTable {
+		return op, payload, nil
+	}
+	buf := append([]byte(nil), payload...)
+	return opCreateTable, binary.BigEndian.AppendUint16(buf, 0), nil
+}
+
+// migrateV10ToV11 appends a cleared RESTART IDENTITY flag to TRUNCATE
+// entries: before v11 a TRUNCATE always continued the identity sequences.
+// All other entry types pass through unchanged.
+//
+// v11 TRUNCATE format: [table:str][restartIdentity:u8]
+func migrateV10ToV11(op byte, payload []byte) (byte, []byte, error) {
+	if op != opTruncate {
+		return op, payload, nil
+	}
+	buf := append([]byte(nil), payload...)
+	return opTruncate, append(buf, 0), nil
+}
+
+// migrateV11ToV12 appends an empty dictionary column list to CREATE TABLE
+// entries and a cleared dictionary flag to ADD COLUMN entries: no column
+// was dictionary-encoded before v12. All other entry types pass through
+// unchanged.
+//
+// v12 CREATE TABLE format: the v11 payload followed by [u16 dictCount]
+// v12 ADD COLUMN format: the v11 payload followed by [u8 dictionary]
+func migrateV11ToV12(op byte, payload []byte) (byte, []byte, error) {
+	buf := append([]byte(nil), payload...)
+	switch op {
+	case opCreateTable:
+		return op, binary.BigEndian.AppendUint16(buf, 0), nil
+	case opAddColumn:
+		return op, append(buf, 0), nil
+	}
+	return op, payload, nil
+}
+
+// migrateV9ToV10 appends an empty identity column list to CREATE TABLE
+// entries: no table had identity columns before v10. All other entry types
+// pass through unchanged.
+//
+// v10 CREATE TABLE format: the v9 payload followed by [u16 identityCount]
+func migrateV9ToV10(op byte, payload []byte) (byte, []byte, error) {
+	if op != opCreateTable {
+		return op, payload, nil
+	}
+	buf := append([]byte(nil), payload...)
+	return opCreateTable, binary.BigEndian.AppendUint16(buf, 0), nil
+}
+
 // -------------------------------------------------------------------------
 // Single-WAL → Split-WAL migration
 // -------------------------------------------------------------------------
@@ -1,13 +1,19 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // writeV1Entry writes a single WAL entry in the legacy (headerless) format.
@@ -464,10 +470,12 @@ type deleteRecord struct {
 }
 
 type testReplayHandler struct {
-	creates []createRecord
-	inserts []insertRecord
-	updates []updateRecord
-	deletes []deleteRecord
+	creates   []createRecord
+	inserts   []insertRecord
+	updates   []updateRecord
+	deletes   []deleteRecord
+	truncates []string
+	restarts  []bool
 }
 
 func (h *testReplayHandler) OnCreateTable(name string, columns []ColumnDef) error {
@@ -504,7 +512,50 @@ func (h *testReplayHandler) OnUpdate(table string, updates []rowUpdate) error {
 
 func (h *testReplayHandler) OnCreateIndex(string, IndexDef) error { return nil }
 func (h *testReplayHandler) OnDropIndex(string, string) error     { return nil }
-func (h *testReplayHandler) OnTxCommit([]string) error            { return nil }
+func (h *testReplayHandler) OnCreateView(string, string) error    { return nil }
+func (h *testReplayHandler) OnDropView(string) error              { return nil }
+func (h *testReplayHandler) OnTruncate(table string, restartIdentity bool) error {
+	h.truncates = append(h.truncates, table)
+	h.restarts = append(h.restarts, restartIdentity)
+	return nil
+}
+func (h *testReplayHandler) OnVacuum(string) error                  { return nil }
+func (h *testReplayHandler) OnIdentity(string, map[int]int64) error { return nil }
+func (h *testReplayHandler) OnTxCommit([]string) error              { return nil }
+
+func TestWAL_TruncateRoundTrip(t *testing.T) {
+	dir := tempDir(t)
+	walPath := filepath.Join(dir, "wal.dat")
+	os.MkdirAll(dir, 0755)
+
+	w, err := OpenWAL(walPath, false)
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	if err := w.WriteTruncate("users", false); err != nil {
+		t.Fatalf("WriteTruncate: %v", err)
+	}
+	if err := w.WriteTruncate("users", true); err != nil {
+		t.Fatalf("WriteTruncate: %v", err)
+	}
+	w.Close()
+
+	w, err = OpenWAL(walPath, false)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer w.Close()
+	h := &testReplayHandler{}
+	if err := w.Replay(h); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if len(h.truncates) != 2 || h.truncates[0] != "users" || h.truncates[1] != "users" {
+		t.Errorf("truncates = %v, want [users users]", h.truncates)
+	}
+	if len(h.restarts) != 2 || h.restarts[0] || !h.restarts[1] {
+		t.Errorf("restart identity flags = %v, want [false true]", h.restarts)
+	}
+}
 
 func TestWAL_InsertBatchRoundTrip(t *testing.T) {
 	dir := tempDir(t)
@@ -597,7 +648,13 @@ func TestWAL_LegacyInsertReplay(t *testing.T) {
 		buf = append(buf, 0) // pk
 		buf = append(buf, 0) // notNull
 		buf = binary.BigEndian.AppendUint16(buf, uint16(col.Ordinal))
+		buf = encodeString(buf, col.Default)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(col.Precision))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(col.Scale))
 	}
+	buf = binary.BigEndian.AppendUint16(buf, 0) // no foreign keys
+	buf = binary.BigEndian.AppendUint16(buf, 0) // no identity columns
+	buf = binary.BigEndian.AppendUint16(buf, 0) // no dictionary columns
 	writeRawEntry(f, opCreateTable, buf)
 
 	// Write a legacy opInsert=3 entry (single row format).
@@ -639,3 +696,544 @@ func TestWAL_LegacyInsertReplay(t *testing.T) {
 		t.Errorf("batch insert: rowID=%d vals=%v", h.inserts[1].rowID, h.inserts[1].vals)
 	}
 }
+
+// failingSync simulates a disk whose fsync fails.
+func failingSync(*os.File) error { return errors.New("injected fsync failure") }
+
+func TestEngine_WALFsyncFailure(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := eng.(*engine).tableStates["t"].wal
+	w.syncFile = failingSync
+
+	_, err := eng.Insert("t", nil, [][]any{{int64(2)}})
+	var ioErr *WALIOError
+	if !errors.As(err, &ioErr) || ioErr.Op != "fsync" {
+		t.Fatalf("Insert: expected fsync WALIOError, got %v", err)
+	}
+
+	// The failed insert may already have been seen, so the table cannot
+	// be read any more either.
+	if _, err := eng.Scan("t"); !errors.As(err, &ioErr) {
+		t.Fatalf("Scan after failure: expected WALIOError, got %v", err)
+	}
+
+	// A failed fsync is not retried: later writes keep failing even
+	// once the disk recovers.
+	w.syncFile = nil
+	if _, err := eng.Delete("t", nil); !errors.As(err, &ioErr) {
+		t.Fatalf("Delete after failure: expected WALIOError, got %v", err)
+	}
+	if _, err := eng.Update("t", map[string]any{"id": int64(3)}, nil); !errors.As(err, &ioErr) {
+		t.Fatalf("Update after failure: expected WALIOError, got %v", err)
+	}
+	if err := eng.Close(); !errors.As(err, &ioErr) {
+		t.Fatalf("Close: expected WALIOError, got %v", err)
+	}
+}
+
+// TestEngine_WALFsyncPendingHidesRows checks that rows whose fsync is
+// still pending are not visible to other sessions: a Scan waits for the
+// fsync, and when it fails, gets the error instead of the rows.
+func TestEngine_WALFsyncPendingHidesRows(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	w := eng.(*engine).tableStates["t"].wal
+	w.syncFile = func(*os.File) error {
+		once.Do(func() { close(started) })
+		<-release
+		return errors.New("injected fsync failure")
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := eng.Insert("t", nil, [][]any{{int64(2)}})
+		done <- err
+	}()
+
+	<-started
+	scanned := make(chan error, 1)
+	go func() {
+		_, err := eng.Scan("t")
+		scanned <- err
+	}()
+	select {
+	case err := <-scanned:
+		t.Fatalf("Scan returned during the fsync: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+
+	var ioErr *WALIOError
+	if err := <-done; !errors.As(err, &ioErr) || ioErr.Op != "fsync" {
+		t.Fatalf("Insert: expected fsync WALIOError, got %v", err)
+	}
+	if err := <-scanned; !errors.As(err, &ioErr) {
+		t.Fatalf("Scan: expected WALIOError, got %v", err)
+	}
+	if _, err := eng.Insert("t", nil, [][]any{{int64(3)}}); !errors.As(err, &ioErr) {
+		t.Fatalf("Insert after the failure: expected WALIOError, got %v", err)
+	}
+	eng.Close()
+}
+
+func TestEngine_GroupCommit(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A slow disk: writers arriving during an fsync share the next one.
+	var syncs atomic.Int64
+	w := eng.(*engine).tableStates["t"].wal
+	w.syncFile = func(f *os.File) error {
+		syncs.Add(1)
+		time.Sleep(5 * time.Millisecond)
+		return f.Sync()
+	}
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := eng.Insert("t", nil, [][]any{{int64(i)}}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := syncs.Load(); n >= writers {
+		t.Errorf("%d inserts took %d fsyncs, want fewer", writers, n)
+	}
+	w.syncFile = nil
+	if err := eng.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	if n, _ := eng.RowCount("t"); n != writers {
+		t.Errorf("after restart: %d rows, want %d", n, writers)
+	}
+}
+
+func TestEngine_WALFsyncFailureInTx(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
+	defer eng.Close()
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	eng.(*engine).tableStates["t"].wal.syncFile = failingSync
+
+	tx := NewTxEngine(eng)
+	if _, err := tx.Insert("t", nil, [][]any{{int64(1)}}); err != nil {
+		t.Fatal(err)
+	}
+	var ioErr *WALIOError
+	if err := tx.CommitOverlay(); !errors.As(err, &ioErr) {
+		t.Fatalf("CommitOverlay: expected WALIOError, got %v", err)
+	}
+}
+
+func TestEngine_CloseSyncsUnsyncedEntries(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	eng.SetFsync(false)
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var synced int
+	w := eng.(*engine).tableStates["t"].wal
+	w.syncFile = func(f *os.File) error {
+		synced++
+		return f.Sync()
+	}
+	if err := eng.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if synced != 1 {
+		t.Fatalf("expected Close to fsync the table WAL once, got %d", synced)
+	}
+}
+
+func TestEngine_Checkpoint(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	walPath := filepath.Join(dir, "tables", "t.wal")
+	walSize := func() int64 {
+		t.Helper()
+		info, err := os.Stat(walPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true, NotNull: true, Identity: IdentityByDefault},
+		{Name: "n", DataType: TypeInteger},
+	})
+	eng.CreateIndex("t", IndexDef{Name: "idx_n", Columns: []string{"n"}})
+	var rows [][]any
+	for i := range 2500 {
+		rows = append(rows, []any{int64(i)})
+	}
+	must(eng.Insert("t", []string{"n"}, rows))
+	for range 5 {
+		must(eng.Update("t", map[string]any{"n": int64(7)}, func(r Row) bool { return r.Values[0].(int64)%2 == 0 }))
+	}
+	// The newest ids go away; the sequence must not hand them out again.
+	must(eng.Delete("t", func(r Row) bool { return r.Values[0].(int64) > 2000 }))
+
+	before := walSize()
+	if err := eng.Checkpoint("t"); err != nil {
+		t.Fatal(err)
+	}
+	if after := walSize(); after >= before/2 {
+		t.Errorf("WAL is %d bytes after checkpoint, was %d", after, before)
+	}
+	if err := eng.Checkpoint("missing"); err == nil {
+		t.Error("expected error checkpointing a missing table")
+	}
+
+	// The identity entry keeps the sequence above the deleted ids, which
+	// no row shows any more.
+	eng.Close()
+	eng = openEngine(t, dir)
+	r, err := eng.InsertReturning("t", []string{"n"}, [][]any{{int64(0)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := r[0].Values[0]; id != int64(2501) {
+		t.Errorf("id after checkpoint and restart = %v, want 2501", id)
+	}
+
+	// Writes go on to the new file, also while checkpoints run.
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 25 {
+				if _, err := eng.Insert("t", []string{"n"}, [][]any{{int64(-1)}}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+	for range 10 {
+		if err := eng.Checkpoint("t"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	eng.Close()
+
+	// A checkpoint that crashed before its rename leaves a file behind,
+	// which the next start removes.
+	if err := os.WriteFile(walPath+checkpointSuffix, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	if fileExists(walPath + checkpointSuffix) {
+		t.Error("leftover checkpoint file was not removed")
+	}
+	if rs, _ := eng.LookupByIndex("t", "idx_n", int64(7)); len(rs) != 1000 {
+		t.Errorf("index finds %d rows with n = 7, want 1000", len(rs))
+	}
+	if n, _ := eng.RowCount("t"); n != 2101 {
+		t.Errorf("row count after restart = %d, want 2101", n)
+	}
+}
+
+func TestEngine_Snapshot(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	defer eng.Close()
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true, NotNull: true, Identity: IdentityByDefault},
+		{Name: "n", DataType: TypeInteger},
+	})
+	eng.CreateIndex("t", IndexDef{Name: "idx_n", Columns: []string{"n"}})
+	eng.CreateTable("gone", []ColumnDef{{Name: "id", DataType: TypeInteger}})
+	eng.DropTable("gone")
+	eng.CreateView("v", "SELECT n FROM t")
+	var rows [][]any
+	for i := range 100 {
+		rows = append(rows, []any{int64(i % 10)})
+	}
+	must(eng.Insert("t", []string{"n"}, rows))
+	must(eng.Update("t", map[string]any{"n": int64(7)}, func(r Row) bool { return r.Values[1].(int64) == 3 }))
+	must(eng.Delete("t", func(r Row) bool { return r.Values[0].(int64) > 90 }))
+
+	snapDir := filepath.Join(t.TempDir(), "snap")
+	if err := eng.Snapshot(snapDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Snapshot(snapDir); err == nil {
+		t.Error("expected error for a snapshot into a non-empty directory")
+	}
+	// Later changes are not part of the snapshot.
+	must(eng.Delete("t", func(Row) bool { return true }))
+
+	snap := openEngine(t, snapDir)
+	defer snap.Close()
+	if n, _ := snap.RowCount("t"); n != 90 {
+		t.Errorf("rows in snapshot = %d, want 90", n)
+	}
+	if rs, _ := snap.LookupByIndex("t", "idx_n", int64(7)); len(rs) != 18 {
+		t.Errorf("index finds %d rows with n = 7, want 18", len(rs))
+	}
+	if _, ok := snap.GetView("v"); !ok {
+		t.Error("view v is missing from the snapshot")
+	}
+	if _, ok := snap.GetTable("gone"); ok {
+		t.Error("dropped table is in the snapshot")
+	}
+	r, err := snap.InsertReturning("t", []string{"n"}, [][]any{{int64(0)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := r[0].Values[0]; id != int64(101) {
+		t.Errorf("id after restoring the snapshot = %v, want 101", id)
+	}
+}
+
+func TestEngine_BackupRestore(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
+	defer eng.Close()
+
+	eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true, NotNull: true, Identity: IdentityByDefault},
+		{Name: "n", DataType: TypeInteger},
+	})
+	eng.CreateIndex("t", IndexDef{Name: "idx_n", Columns: []string{"n"}})
+	eng.CreateTable("my table", []ColumnDef{{Name: "s", DataType: TypeText}})
+	var rows [][]any
+	for i := range 2500 {
+		rows = append(rows, []any{int64(i % 10)})
+	}
+	must(eng.Insert("t", []string{"n"}, rows))
+	must(eng.Delete("t", func(r Row) bool { return r.Values[0].(int64) > 2400 }))
+	must(eng.Insert("my table", nil, [][]any{{"x"}, {nil}}))
+
+	var buf bytes.Buffer
+	if err := eng.Backup(&buf); err != nil {
+		t.Fatal(err)
+	}
+	base := t.TempDir()
+	file := filepath.Join(base, "db.mbak")
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := filepath.Join(base, "restored")
+	if err := Restore(file, restored); err != nil {
+		t.Fatal(err)
+	}
+	if err := Restore(file, restored); err == nil {
+		t.Error("expected error restoring into a non-empty directory")
+	}
+	snap := openEngine(t, restored)
+	defer snap.Close()
+	if n, _ := snap.RowCount("t"); n != 2400 {
+		t.Errorf("rows of t after restore = %d, want 2400", n)
+	}
+	if n, _ := snap.RowCount("my table"); n != 2 {
+		t.Errorf("rows of \"my table\" after restore = %d, want 2", n)
+	}
+	if rs, _ := snap.LookupByIndex("t", "idx_n", int64(7)); len(rs) != 240 {
+		t.Errorf("index finds %d rows with n = 7, want 240", len(rs))
+	}
+	r, err := snap.InsertReturning("t", []string{"n"}, [][]any{{int64(0)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := r[0].Values[0]; id != int64(2501) {
+		t.Errorf("id after restore = %v, want 2501", id)
+	}
+
+	// A damaged, truncated or newer file restores nothing.
+	data := buf.Bytes()
+	damaged := bytes.Clone(data)
+	damaged[len(damaged)/2] ^= 0xff
+	newer := bytes.Clone(data)
+	binary.BigEndian.PutUint16(newer[4:], backupCurrentVersion+1)
+	for name, bad := range map[string][]byte{
+		"damaged":   damaged,
+		"truncated": data[:len(data)-100],
+		"newer":     newer,
+		"empty":     nil,
+	} {
+		file := filepath.Join(base, name+".mbak")
+		if err := os.WriteFile(file, bad, 0644); err != nil {
+			t.Fatal(err)
+		}
+		dir := filepath.Join(base, name)
+		if err := Restore(file, dir); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+		if fileExists(filepath.Join(dir, "catalog.wal")) {
+			t.Errorf("%s: catalog.wal left behind", name)
+		}
+	}
+	var verr *BackupVersionError
+	if err := Restore(filepath.Join(base, "newer.mbak"), filepath.Join(base, "newer")); !errors.As(err, &verr) {
+		t.Errorf("newer backup: got %v, want BackupVersionError", err)
+	}
+}
+
+// TestEngine_SnapshotConsistent takes snapshots while a writer commits
+// transactions that insert a row into each of two tables; every snapshot must hold as many rows in
+// one as in the other.
+func TestEngine_SnapshotConsistent(t *testing.T) {
+	eng := openEngine(t, tempDir(t))
+	defer eng.Close()
+	for _, name := range []string{"a", "b"} {
+		eng.CreateTable(name, []ColumnDef{{Name: "id", DataType: TypeInteger}})
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for i := range 200 {
+			tx := NewTxEngine(eng)
+			for _, name := range []string{"a", "b"} {
+				if _, err := tx.Insert(name, nil, [][]any{{int64(i)}}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			if err := tx.CommitOverlay(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+	base := t.TempDir()
+	var dirs []string
+	for i := range 10 {
+		dir := filepath.Join(base, strconv.Itoa(i))
+		if err := eng.Snapshot(dir); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+	}
+	wg.Wait()
+
+	for _, dir := range dirs {
+		snap := openEngine(t, dir)
+		na, _ := snap.RowCount("a")
+		nb, _ := snap.RowCount("b")
+		snap.Close()
+		if na != nb {
+			t.Errorf("snapshot %s has %d rows in a and %d in b", filepath.Base(dir), na, nb)
+		}
+	}
+}
+
+// TestEngine_MigrateLegacyIntegerValues opens a table whose WAL holds
+// strings, floats and booleans in an INTEGER column, as engines that kept
+// the column boxed stored them. Without --migrate the table is refused;
+// with it the values are converted as INSERT would, or become NULL, and
+// the WAL is rewritten so later opens need no flag.
+func TestEngine_MigrateLegacyIntegerValues(t *testing.T) {
+	dir := tempDir(t)
+	eng := openEngine(t, dir)
+	if err := eng.CreateTable("t", []ColumnDef{
+		{Name: "id", DataType: TypeInteger, PrimaryKey: true},
+		{Name: "n", DataType: TypeInteger},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eng.Insert("t", nil, [][]any{{int64(1), int64(10)}}); err != nil {
+		t.Fatal(err)
+	}
+	eng.Close()
+
+	walPath := filepath.Join(dir, tablesDirName, tableFileName("t"))
+	w, err := OpenWAL(walPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteInsertBatch("t", []rowInsert{
+		{RowID: 2, Values: []any{int64(2), " 42 "}},
+		{RowID: 3, Values: []any{int64(3), 6.6}},
+		{RowID: 4, Values: []any{"4", "x"}},
+		{RowID: 5, Values: []any{int64(5), true}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteUpdate("t", []rowUpdate{{RowID: 1, Values: []any{int64(1), "11"}}}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	_, err = Open(dir, false)
+	var mismatch *DatatypeMismatchError
+	if !errors.As(err, &mismatch) || !strings.Contains(err.Error(), "--migrate") {
+		t.Fatalf("Open without --migrate: got %v, want a DatatypeMismatchError naming --migrate", err)
+	}
+
+	want := map[int64]any{1: int64(11), 2: int64(42), 3: int64(7), 4: nil, 5: nil}
+	check := func(eng Engine) {
+		t.Helper()
+		rows := collectRows(t, must(eng.Scan("t")))
+		if len(rows) != len(want) {
+			t.Fatalf("got %d rows, want %d", len(rows), len(want))
+		}
+		for _, row := range rows {
+			id := row.Values[0].(int64)
+			if row.Values[1] != want[id] {
+				t.Errorf("row %d: n = %#v, want %#v", id, row.Values[1], want[id])
+			}
+		}
+		if row, _ := eng.LookupByPK("t", int64(4)); row == nil {
+			t.Error("row 4 not found by its converted key")
+		}
+	}
+
+	eng, err = Open(dir, true)
+	if err != nil {
+		t.Fatalf("Open with --migrate: %v", err)
+	}
+	check(eng)
+	eng.Close()
+
+	if _, err := os.Stat(walPath + ".bak"); err != nil {
+		t.Errorf("original WAL not kept: %v", err)
+	}
+	eng = openEngine(t, dir)
+	defer eng.Close()
+	check(eng)
+}